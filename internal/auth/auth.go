@@ -2,13 +2,109 @@ package auth
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
 
 	"cloud.google.com/go/pubsub/v2"
+	"golang.org/x/oauth2/google"
+	"google.golang.org/api/option"
 )
 
-// NewPubSubClient creates a Pub/Sub client using Application Default Credentials
-// Users must run: gcloud auth application-default login
-func NewPubSubClient(ctx context.Context, projectID string) (*pubsub.Client, error) {
-	// Uses Application Default Credentials automatically
-	return pubsub.NewClient(ctx, projectID)
+// ErrNoCredentials is returned when no credentials file is configured and
+// Application Default Credentials can't be found
+var ErrNoCredentials = errors.New("no Google Cloud credentials found: run 'gcloud auth application-default login', " +
+	"set GOOGLE_APPLICATION_CREDENTIALS, or set auth.credentials_file in the config")
+
+// Options configures how clients authenticate
+type Options struct {
+	// CredentialsFile is a service account key or external account JSON file.
+	// Empty uses Application Default Credentials.
+	CredentialsFile string
+
+	// Scopes overrides the OAuth scopes requested by the client
+	Scopes []string
+}
+
+// NewPubSubClient creates a Pub/Sub client using the configured credentials file,
+// or Application Default Credentials if none is set.
+// For ADC, users must run: gcloud auth application-default login
+// Extra client options, e.g. gRPC interceptors, are appended after the credentials.
+func NewPubSubClient(ctx context.Context, projectID string, opts Options, extra ...option.ClientOption) (*pubsub.Client, error) {
+	clientOpts, err := ClientOptions(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return pubsub.NewClient(ctx, projectID, append(clientOpts, extra...)...)
+}
+
+// DefaultScopes are requested when Options has no scopes, enough for every API the scan reads
+var DefaultScopes = []string{"https://www.googleapis.com/auth/cloud-platform"}
+
+// ClientOptions returns the Google API client options for opts. Credentials are
+// validated up front, so a missing or unreadable credentials file is reported
+// clearly instead of as a raw library error
+func ClientOptions(ctx context.Context, opts Options) ([]option.ClientOption, error) {
+	// The emulator doesn't need credentials
+	if opts.CredentialsFile == "" && os.Getenv("PUBSUB_EMULATOR_HOST") != "" {
+		var clientOpts []option.ClientOption
+		if len(opts.Scopes) > 0 {
+			clientOpts = append(clientOpts, option.WithScopes(opts.Scopes...))
+		}
+		return clientOpts, nil
+	}
+
+	creds, err := Credentials(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return []option.ClientOption{option.WithCredentials(creds)}, nil
+}
+
+// credentials caches the credentials of every Options, see Credentials
+var credentials sync.Map // credentialsKey -> *google.Credentials
+
+type credentialsKey struct {
+	file, scopes string
+}
+
+// Credentials returns the credentials for opts, found once per process. Every client created
+// with them shares their cached token, so scanning many projects exchanges a token once
+// instead of once per project client.
+func Credentials(ctx context.Context, opts Options) (*google.Credentials, error) {
+	scopes := opts.Scopes
+	if len(scopes) == 0 {
+		scopes = DefaultScopes
+	}
+	key := credentialsKey{file: opts.CredentialsFile, scopes: strings.Join(scopes, " ")}
+	if creds, ok := credentials.Load(key); ok {
+		return creds.(*google.Credentials), nil
+	}
+
+	// The credentials refresh their token with this context long after the caller is done
+	ctx = context.WithoutCancel(ctx)
+
+	var creds *google.Credentials
+	if opts.CredentialsFile != "" {
+		data, err := os.ReadFile(opts.CredentialsFile)
+		if err != nil {
+			return nil, fmt.Errorf("credentials file %s: %w", opts.CredentialsFile, err)
+		}
+		creds, err = google.CredentialsFromJSON(ctx, data, scopes...)
+		if err != nil {
+			return nil, fmt.Errorf("invalid credentials file %s: %w", opts.CredentialsFile, err)
+		}
+	} else {
+		var err error
+		creds, err = google.FindDefaultCredentials(ctx, scopes...)
+		if err != nil {
+			return nil, fmt.Errorf("%w (%v)", ErrNoCredentials, err)
+		}
+	}
+
+	// Concurrent callers agree on the credentials stored first
+	stored, _ := credentials.LoadOrStore(key, creds)
+	return stored.(*google.Credentials), nil
 }
@@ -2,6 +2,8 @@ package auth
 
 import (
 	"context"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -15,7 +17,7 @@ func TestNewPubSubClient(t *testing.T) {
 	}
 
 	ctx := context.Background()
-	client, err := NewPubSubClient(ctx, "test-project")
+	client, err := NewPubSubClient(ctx, "test-project", Options{})
 
 	// Should fail gracefully if no credentials
 	if err != nil {
@@ -30,3 +32,43 @@ func TestNewPubSubClient(t *testing.T) {
 		}()
 	}
 }
+
+func TestNewPubSubClient_MissingCredentialsFile(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "missing.json")
+
+	_, err := NewPubSubClient(context.Background(), "test-project", Options{CredentialsFile: missing})
+	require.Error(t, err)
+	require.ErrorIs(t, err, os.ErrNotExist)
+	require.Contains(t, err.Error(), missing)
+}
+
+func TestNewPubSubClient_Emulator(t *testing.T) {
+	t.Setenv("PUBSUB_EMULATOR_HOST", "localhost:8085")
+
+	// No credentials are needed, and the client connects lazily
+	client, err := NewPubSubClient(context.Background(), "test-project", Options{})
+	require.NoError(t, err)
+	require.NoError(t, client.Close())
+}
+
+func TestCredentials_Shared(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "credentials.json")
+	require.NoError(t, os.WriteFile(file, []byte(`{"type":"authorized_user","client_id":"id","client_secret":"secret","refresh_token":"token"}`), 0600))
+
+	// Every client of the same options shares the credentials and their cached token
+	first, err := Credentials(context.Background(), Options{CredentialsFile: file})
+	require.NoError(t, err)
+	second, err := Credentials(context.Background(), Options{CredentialsFile: file})
+	require.NoError(t, err)
+	require.Same(t, first, second)
+
+	other, err := Credentials(context.Background(), Options{CredentialsFile: file, Scopes: []string{"https://www.googleapis.com/auth/pubsub"}})
+	require.NoError(t, err)
+	require.NotSame(t, first, other)
+
+	invalid := filepath.Join(t.TempDir(), "invalid.json")
+	require.NoError(t, os.WriteFile(invalid, []byte(`not json`), 0600))
+	_, err = Credentials(context.Background(), Options{CredentialsFile: invalid})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "invalid credentials file")
+}
@@ -0,0 +1,393 @@
+package bigquery
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/auth"
+	bq "google.golang.org/api/bigquery/v2"
+	"google.golang.org/api/googleapi"
+)
+
+// Modes of an export
+const (
+	ModeAppend   = "append"   // add the rows as a new snapshot, keeping earlier exports
+	ModeTruncate = "truncate" // replace the table contents with the rows
+)
+
+// Tables written by Export
+const (
+	TableTopics        = "topics"
+	TableSubscriptions = "subscriptions"
+	TableEdges         = "edges"
+	TableScanRuns      = "scan_runs"
+)
+
+// Table is a BigQuery table loaded by an export
+type Table struct {
+	ProjectID string
+	DatasetID string
+	TableID   string
+	Schema    []*bq.TableFieldSchema
+}
+
+// API creates datasets, loads tables and reads them back. It is implemented by the
+// BigQuery REST client and can be faked in tests.
+type API interface {
+	// EnsureDataset creates the dataset in location unless it exists
+	EnsureDataset(ctx context.Context, projectID, datasetID, location string) error
+	// Load loads newline-delimited JSON rows into table, creating it with its schema if needed
+	Load(ctx context.Context, table Table, rows io.Reader, truncate bool) error
+	// ReadStrings returns the values of a string column of table, none if the table doesn't exist
+	ReadStrings(ctx context.Context, table Table, column string) ([]string, error)
+}
+
+// NewAPI creates an API backed by the BigQuery REST API
+func NewAPI(ctx context.Context, opts auth.Options) (API, error) {
+	clientOpts, err := auth.ClientOptions(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	svc, err := bq.NewService(ctx, clientOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create bigquery client: %w", err)
+	}
+	return &api{svc: svc, poll: time.Second}, nil
+}
+
+type api struct {
+	svc  *bq.Service
+	poll time.Duration // between checks of a running load job
+}
+
+func (a *api) EnsureDataset(ctx context.Context, projectID, datasetID, location string) error {
+	_, err := a.svc.Datasets.Get(projectID, datasetID).Context(ctx).Do()
+	var apiErr *googleapi.Error
+	if !errors.As(err, &apiErr) || apiErr.Code != http.StatusNotFound {
+		return err
+	}
+	_, err = a.svc.Datasets.Insert(projectID, &bq.Dataset{
+		DatasetReference: &bq.DatasetReference{ProjectId: projectID, DatasetId: datasetID},
+		Location:         location,
+	}).Context(ctx).Do()
+	return err
+}
+
+func (a *api) Load(ctx context.Context, table Table, rows io.Reader, truncate bool) error {
+	load := &bq.JobConfigurationLoad{
+		DestinationTable:  &bq.TableReference{ProjectId: table.ProjectID, DatasetId: table.DatasetID, TableId: table.TableID},
+		Schema:            &bq.TableSchema{Fields: table.Schema},
+		SourceFormat:      "NEWLINE_DELIMITED_JSON",
+		CreateDisposition: "CREATE_IF_NEEDED",
+		WriteDisposition:  "WRITE_APPEND",
+		// Tables created by an older version get the columns added since
+		SchemaUpdateOptions: []string{"ALLOW_FIELD_ADDITION"},
+	}
+	if truncate {
+		// Truncating replaces the schema too, and doesn't accept schema update options
+		load.WriteDisposition = "WRITE_TRUNCATE"
+		load.SchemaUpdateOptions = nil
+	}
+
+	job, err := a.svc.Jobs.Insert(table.ProjectID, &bq.Job{
+		Configuration: &bq.JobConfiguration{Load: load},
+	}).Media(rows).Context(ctx).Do()
+	if err != nil {
+		return err
+	}
+
+	for job.Status == nil || job.Status.State != "DONE" {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(a.poll):
+		}
+		job, err = a.svc.Jobs.Get(table.ProjectID, job.JobReference.JobId).
+			Location(job.JobReference.Location).Context(ctx).Do()
+		if err != nil {
+			return err
+		}
+	}
+	if job.Status.ErrorResult != nil {
+		return fmt.Errorf("load job %s failed: %s", job.JobReference.JobId, job.Status.ErrorResult.Message)
+	}
+	return nil
+}
+
+func (a *api) ReadStrings(ctx context.Context, table Table, column string) ([]string, error) {
+	var values []string
+	err := a.svc.Tabledata.List(table.ProjectID, table.DatasetID, table.TableID).SelectedFields(column).
+		Pages(ctx, func(resp *bq.TableDataList) error {
+			for _, row := range resp.Rows {
+				if len(row.F) == 0 {
+					continue
+				}
+				if value, ok := row.F[0].V.(string); ok {
+					values = append(values, value)
+				}
+			}
+			return nil
+		})
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound {
+		return nil, nil
+	}
+	return values, err
+}
+
+// Options configures an export
+type Options struct {
+	ProjectID string // owning the dataset
+	DatasetID string
+	Location  string // of the dataset when it is created, empty for the BigQuery default
+	Mode      string // ModeAppend or ModeTruncate
+}
+
+// ParseDataset splits a PROJECT.DATASET reference
+func ParseDataset(dataset string) (projectID, datasetID string, err error) {
+	projectID, datasetID, ok := strings.Cut(dataset, ".")
+	if !ok || projectID == "" || datasetID == "" || strings.Contains(datasetID, ".") {
+		return "", "", fmt.Errorf("invalid dataset %q, expected PROJECT.DATASET", dataset)
+	}
+	return projectID, datasetID, nil
+}
+
+// Label is a key and value of a resource label, a repeated record in BigQuery
+type Label struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// TopicRow is a row of the topics table
+type TopicRow struct {
+	ExportedAt                time.Time `json:"exported_at"`
+	Project                   string    `json:"project"`
+	Name                      string    `json:"name"`
+	FullResourceName          string    `json:"full_resource_name"`
+	Subscriptions             int       `json:"subscriptions"`
+	CrossProjectSubscriptions int       `json:"cross_project_subscriptions"`
+	MessageRetentionSeconds   int64     `json:"message_retention_seconds,omitempty"`
+	KMSKeyName                string    `json:"kms_key_name,omitempty"`
+	StorageRegions            []string  `json:"storage_regions,omitempty"`
+	Team                      string    `json:"team,omitempty"`
+	Labels                    []Label   `json:"labels,omitempty"`
+}
+
+// SubscriptionRow is a row of the subscriptions table
+type SubscriptionRow struct {
+	ExportedAt          time.Time `json:"exported_at"`
+	Project             string    `json:"project"`
+	Name                string    `json:"name"`
+	FullResourceName    string    `json:"full_resource_name"`
+	TopicProject        string    `json:"topic_project,omitempty"`
+	Topic               string    `json:"topic"`
+	CrossProject        bool      `json:"cross_project"`
+	Delivery            string    `json:"delivery"`
+	PushEndpoint        string    `json:"push_endpoint,omitempty"`
+	Destination         string    `json:"destination,omitempty"`
+	AckDeadlineSeconds  int       `json:"ack_deadline_seconds,omitempty"`
+	Filter              string    `json:"filter,omitempty"`
+	DeadLetterTopic     string    `json:"dead_letter_topic,omitempty"`
+	RetryMinimumBackoff string    `json:"retry_minimum_backoff,omitempty"`
+	RetryMaximumBackoff string    `json:"retry_maximum_backoff,omitempty"`
+	Team                string    `json:"team,omitempty"`
+	Labels              []Label   `json:"labels,omitempty"`
+}
+
+// EdgeRow is a row of the edges table, a relationship of the topology graph
+type EdgeRow struct {
+	ExportedAt    time.Time `json:"exported_at"`
+	Source        string    `json:"source"`
+	SourceType    string    `json:"source_type"`
+	SourceProject string    `json:"source_project,omitempty"`
+	Target        string    `json:"target"`
+	TargetType    string    `json:"target_type"`
+	TargetProject string    `json:"target_project,omitempty"`
+	Kind          string    `json:"kind"`
+	CrossProject  bool      `json:"cross_project"`
+	Inferred      bool      `json:"inferred"`
+}
+
+// ScanRunRow is a row of the scan_runs table
+type ScanRunRow struct {
+	ExportedAt          time.Time `json:"exported_at"`
+	RunID               string    `json:"run_id"`
+	StartedAt           time.Time `json:"started_at"`
+	FinishedAt          time.Time `json:"finished_at"`
+	ProjectsAttempted   int       `json:"projects_attempted"`
+	ProjectsSucceeded   int       `json:"projects_succeeded"`
+	ProjectsFailed      int       `json:"projects_failed"`
+	ProjectsSkipped     int       `json:"projects_skipped"`
+	ProjectsInterrupted int       `json:"projects_interrupted"`
+	Topics              int       `json:"topics"`
+	Subscriptions       int       `json:"subscriptions"`
+	APICalls            int64     `json:"api_calls"`
+	Version             string    `json:"version,omitempty"`
+}
+
+// Inventory is the contents of an export
+type Inventory struct {
+	Topics        []TopicRow
+	Subscriptions []SubscriptionRow
+	Edges         []EdgeRow
+	ScanRuns      []ScanRunRow
+}
+
+// Schemas of the tables, the columns match the JSON names of the rows
+var (
+	labelsField = &bq.TableFieldSchema{Name: "labels", Type: "RECORD", Mode: "REPEATED", Fields: []*bq.TableFieldSchema{
+		field("key", "STRING", "REQUIRED"),
+		field("value", "STRING", "NULLABLE"),
+	}}
+
+	topicSchema = []*bq.TableFieldSchema{
+		field("exported_at", "TIMESTAMP", "REQUIRED"),
+		field("project", "STRING", "REQUIRED"),
+		field("name", "STRING", "REQUIRED"),
+		field("full_resource_name", "STRING", "REQUIRED"),
+		field("subscriptions", "INTEGER", "NULLABLE"),
+		field("cross_project_subscriptions", "INTEGER", "NULLABLE"),
+		field("message_retention_seconds", "INTEGER", "NULLABLE"),
+		field("kms_key_name", "STRING", "NULLABLE"),
+		field("storage_regions", "STRING", "REPEATED"),
+		field("team", "STRING", "NULLABLE"),
+		labelsField,
+	}
+
+	subscriptionSchema = []*bq.TableFieldSchema{
+		field("exported_at", "TIMESTAMP", "REQUIRED"),
+		field("project", "STRING", "REQUIRED"),
+		field("name", "STRING", "REQUIRED"),
+		field("full_resource_name", "STRING", "REQUIRED"),
+		field("topic_project", "STRING", "NULLABLE"),
+		field("topic", "STRING", "NULLABLE"),
+		field("cross_project", "BOOLEAN", "NULLABLE"),
+		field("delivery", "STRING", "NULLABLE"),
+		field("push_endpoint", "STRING", "NULLABLE"),
+		field("destination", "STRING", "NULLABLE"),
+		field("ack_deadline_seconds", "INTEGER", "NULLABLE"),
+		field("filter", "STRING", "NULLABLE"),
+		field("dead_letter_topic", "STRING", "NULLABLE"),
+		field("retry_minimum_backoff", "STRING", "NULLABLE"),
+		field("retry_maximum_backoff", "STRING", "NULLABLE"),
+		field("team", "STRING", "NULLABLE"),
+		labelsField,
+	}
+
+	edgeSchema = []*bq.TableFieldSchema{
+		field("exported_at", "TIMESTAMP", "REQUIRED"),
+		field("source", "STRING", "REQUIRED"),
+		field("source_type", "STRING", "NULLABLE"),
+		field("source_project", "STRING", "NULLABLE"),
+		field("target", "STRING", "REQUIRED"),
+		field("target_type", "STRING", "NULLABLE"),
+		field("target_project", "STRING", "NULLABLE"),
+		field("kind", "STRING", "NULLABLE"),
+		field("cross_project", "BOOLEAN", "NULLABLE"),
+		field("inferred", "BOOLEAN", "NULLABLE"),
+	}
+
+	scanRunSchema = []*bq.TableFieldSchema{
+		field("exported_at", "TIMESTAMP", "REQUIRED"),
+		field("run_id", "STRING", "REQUIRED"),
+		field("started_at", "TIMESTAMP", "NULLABLE"),
+		field("finished_at", "TIMESTAMP", "NULLABLE"),
+		field("projects_attempted", "INTEGER", "NULLABLE"),
+		field("projects_succeeded", "INTEGER", "NULLABLE"),
+		field("projects_failed", "INTEGER", "NULLABLE"),
+		field("projects_skipped", "INTEGER", "NULLABLE"),
+		field("projects_interrupted", "INTEGER", "NULLABLE"),
+		field("topics", "INTEGER", "NULLABLE"),
+		field("subscriptions", "INTEGER", "NULLABLE"),
+		field("api_calls", "INTEGER", "NULLABLE"),
+		field("version", "STRING", "NULLABLE"),
+	}
+)
+
+func field(name, typ, mode string) *bq.TableFieldSchema {
+	return &bq.TableFieldSchema{Name: name, Type: typ, Mode: mode}
+}
+
+// Export loads inv into the topics, subscriptions, edges and scan_runs tables of the dataset,
+// creating the dataset and tables as needed. Every row is stamped with exportedAt, so appended
+// snapshots can be told apart, e.g. to chart the inventory over time in Looker Studio. Appending
+// skips the scan runs already in scan_runs, so exporting twice doesn't record a run twice.
+func Export(ctx context.Context, api API, opts Options, inv *Inventory, exportedAt time.Time) error {
+	if opts.Mode != ModeAppend && opts.Mode != ModeTruncate {
+		return fmt.Errorf("invalid mode %q, expected %s or %s", opts.Mode, ModeAppend, ModeTruncate)
+	}
+	// BigQuery timestamps have microsecond precision
+	exportedAt = exportedAt.UTC().Truncate(time.Microsecond)
+
+	if err := api.EnsureDataset(ctx, opts.ProjectID, opts.DatasetID, opts.Location); err != nil {
+		return fmt.Errorf("failed to create dataset %s.%s: %w", opts.ProjectID, opts.DatasetID, err)
+	}
+
+	if opts.Mode == ModeAppend && len(inv.ScanRuns) > 0 {
+		table := Table{ProjectID: opts.ProjectID, DatasetID: opts.DatasetID, TableID: TableScanRuns}
+		exported, err := api.ReadStrings(ctx, table, "run_id")
+		if err != nil {
+			return fmt.Errorf("failed to read exported scan runs: %w", err)
+		}
+		inv.ScanRuns = slices.DeleteFunc(inv.ScanRuns, func(run ScanRunRow) bool {
+			return slices.Contains(exported, run.RunID)
+		})
+	}
+
+	for i := range inv.Topics {
+		inv.Topics[i].ExportedAt = exportedAt
+	}
+	for i := range inv.Subscriptions {
+		inv.Subscriptions[i].ExportedAt = exportedAt
+	}
+	for i := range inv.Edges {
+		inv.Edges[i].ExportedAt = exportedAt
+	}
+	for i := range inv.ScanRuns {
+		run := &inv.ScanRuns[i]
+		run.ExportedAt = exportedAt
+		run.StartedAt = run.StartedAt.UTC().Truncate(time.Microsecond)
+		run.FinishedAt = run.FinishedAt.UTC().Truncate(time.Microsecond)
+	}
+
+	for _, load := range []struct {
+		table  string
+		schema []*bq.TableFieldSchema
+		encode func() (io.Reader, error)
+	}{
+		{TableTopics, topicSchema, func() (io.Reader, error) { return encodeRows(inv.Topics) }},
+		{TableSubscriptions, subscriptionSchema, func() (io.Reader, error) { return encodeRows(inv.Subscriptions) }},
+		{TableEdges, edgeSchema, func() (io.Reader, error) { return encodeRows(inv.Edges) }},
+		{TableScanRuns, scanRunSchema, func() (io.Reader, error) { return encodeRows(inv.ScanRuns) }},
+	} {
+		rows, err := load.encode()
+		if err != nil {
+			return fmt.Errorf("failed to encode %s: %w", load.table, err)
+		}
+		table := Table{ProjectID: opts.ProjectID, DatasetID: opts.DatasetID, TableID: load.table, Schema: load.schema}
+		if err := api.Load(ctx, table, rows, opts.Mode == ModeTruncate); err != nil {
+			return fmt.Errorf("failed to load %s: %w", load.table, err)
+		}
+	}
+	return nil
+}
+
+// encodeRows encodes rows as newline-delimited JSON
+func encodeRows[T any](rows []T) (io.Reader, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, row := range rows {
+		if err := enc.Encode(row); err != nil {
+			return nil, err
+		}
+	}
+	return &buf, nil
+}
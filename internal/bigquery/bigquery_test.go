@@ -0,0 +1,125 @@
+package bigquery
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	bq "google.golang.org/api/bigquery/v2"
+)
+
+// fakeAPI records the datasets and loads of an export
+type fakeAPI struct {
+	datasets []string
+	loads    map[string][]map[string]any // table -> rows
+	truncate map[string]bool
+	schemas  map[string][]*bq.TableFieldSchema
+}
+
+func (f *fakeAPI) EnsureDataset(ctx context.Context, projectID, datasetID, location string) error {
+	f.datasets = append(f.datasets, projectID+"."+datasetID+"@"+location)
+	return nil
+}
+
+func (f *fakeAPI) Load(ctx context.Context, table Table, rows io.Reader, truncate bool) error {
+	if f.loads == nil {
+		f.loads = make(map[string][]map[string]any)
+		f.truncate = make(map[string]bool)
+		f.schemas = make(map[string][]*bq.TableFieldSchema)
+	}
+	scanner := bufio.NewScanner(rows)
+	for scanner.Scan() {
+		var row map[string]any
+		if err := json.Unmarshal(scanner.Bytes(), &row); err != nil {
+			return err
+		}
+		f.loads[table.TableID] = append(f.loads[table.TableID], row)
+	}
+	f.truncate[table.TableID] = truncate
+	f.schemas[table.TableID] = table.Schema
+	return scanner.Err()
+}
+
+func (f *fakeAPI) ReadStrings(ctx context.Context, table Table, column string) ([]string, error) {
+	var values []string
+	for _, row := range f.loads[table.TableID] {
+		values = append(values, row[column].(string))
+	}
+	return values, nil
+}
+
+func TestExport(t *testing.T) {
+	exportedAt := time.Date(2025, 3, 1, 12, 0, 0, 123456789, time.UTC)
+	inv := &Inventory{
+		Topics: []TopicRow{{
+			Project: "project-a", Name: "orders", FullResourceName: "projects/project-a/topics/orders",
+			Subscriptions: 1, StorageRegions: []string{"europe-west1"}, Labels: []Label{{Key: "team", Value: "payments"}},
+		}},
+		Subscriptions: []SubscriptionRow{{
+			Project: "project-b", Name: "orders-email", FullResourceName: "projects/project-b/subscriptions/orders-email",
+			Topic: "projects/project-a/topics/orders", CrossProject: true, Delivery: "push",
+		}},
+		Edges:    []EdgeRow{{Source: "a", Target: "b", Kind: "publishes"}},
+		ScanRuns: []ScanRunRow{{RunID: "run-1", StartedAt: exportedAt.Add(-time.Minute), FinishedAt: exportedAt}},
+	}
+
+	api := &fakeAPI{}
+	opts := Options{ProjectID: "analytics", DatasetID: "pubsub", Location: "EU", Mode: ModeAppend}
+	require.NoError(t, Export(context.Background(), api, opts, inv, exportedAt))
+
+	assert.Equal(t, []string{"analytics.pubsub@EU"}, api.datasets)
+	require.Len(t, api.loads[TableTopics], 1)
+	topic := api.loads[TableTopics][0]
+	assert.Equal(t, "2025-03-01T12:00:00.123456Z", topic["exported_at"])
+	assert.Equal(t, []any{"europe-west1"}, topic["storage_regions"])
+	assert.Equal(t, []any{map[string]any{"key": "team", "value": "payments"}}, topic["labels"])
+	assert.Equal(t, true, api.loads[TableSubscriptions][0]["cross_project"])
+	assert.Len(t, api.loads[TableEdges], 1)
+	assert.Equal(t, "2025-03-01T12:00:00.123456Z", api.loads[TableScanRuns][0]["finished_at"])
+	assert.False(t, api.truncate[TableTopics])
+
+	// Every column of a row is in the schema of its table
+	for table, rows := range api.loads {
+		columns := make(map[string]bool)
+		for _, field := range api.schemas[table] {
+			columns[field.Name] = true
+		}
+		for _, row := range rows {
+			for column := range row {
+				assert.True(t, columns[column], "column %s of %s is not in the schema", column, table)
+			}
+		}
+	}
+
+	// Appending again adds a new snapshot of the inventory but no scan run that was exported before
+	inv.ScanRuns = append(inv.ScanRuns, ScanRunRow{RunID: "run-2", StartedAt: exportedAt, FinishedAt: exportedAt.Add(time.Minute)})
+	require.NoError(t, Export(context.Background(), api, opts, inv, exportedAt.Add(time.Hour)))
+	assert.Len(t, api.loads[TableTopics], 2)
+	require.Len(t, api.loads[TableScanRuns], 2)
+	assert.Equal(t, "run-2", api.loads[TableScanRuns][1]["run_id"])
+
+	api = &fakeAPI{}
+	opts.Mode = ModeTruncate
+	require.NoError(t, Export(context.Background(), api, opts, &Inventory{}, exportedAt))
+	assert.Equal(t, map[string]bool{TableTopics: true, TableSubscriptions: true, TableEdges: true, TableScanRuns: true}, api.truncate)
+
+	opts.Mode = "replace"
+	assert.ErrorContains(t, Export(context.Background(), &fakeAPI{}, opts, &Inventory{}, exportedAt), "invalid mode")
+}
+
+func TestParseDataset(t *testing.T) {
+	project, dataset, err := ParseDataset("analytics.pubsub_inventory")
+	require.NoError(t, err)
+	assert.Equal(t, "analytics", project)
+	assert.Equal(t, "pubsub_inventory", dataset)
+
+	for _, invalid := range []string{"pubsub", ".pubsub", "analytics.", "a.b.c"} {
+		_, _, err := ParseDataset(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
@@ -0,0 +1,98 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/config"
+	"github.com/NissesSenap/gcp-visualizer/internal/graph"
+	"github.com/NissesSenap/gcp-visualizer/internal/renderer"
+	"github.com/NissesSenap/gcp-visualizer/internal/storage"
+)
+
+type AnalyzeCmd struct {
+	Hotspots HotspotsCmd `cmd:"hotspots" help:"Rank topics by subscriber count and cross-project reach"`
+}
+
+type HotspotsCmd struct {
+	Projects     []string `help:"Filter by projects"`
+	Top          int      `help:"Number of topics to report" default:"10"`
+	SPOFProjects int      `name:"spof-projects" help:"Flag topics consumed by at least this many other projects as single points of failure" default:"2"`
+	Output       string   `help:"Also render a focused diagram of the top topics to this file"`
+	Format       string   `help:"Diagram output format" enum:"svg,png,pdf,html" default:"svg"`
+	Layout       string   `help:"Layout engine" enum:"fdp,dot,neato" default:"fdp"`
+	Theme        string   `help:"Color theme: light, dark, colorblind or the path of a custom theme YAML file (default: visualization.theme of the config, or light)"`
+	Renderer     string   `help:"Draw the diagram with the Graphviz binary, the built-in layout (svg and png only), or Graphviz when it is installed" enum:"auto,graphviz,embedded" default:"auto"`
+}
+
+func (c *HotspotsCmd) Run(cli *CLI) error {
+	if c.Theme == "" {
+		cfg, err := config.Load()
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+		c.Theme = cfg.Visualization.Theme
+	}
+
+	store, err := openStore()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = store.Close() }()
+
+	return c.analyze(cli.Context(), store, os.Stdout)
+}
+
+// analyze writes the hotspot report to w and optionally renders the focused diagram
+func (c *HotspotsCmd) analyze(ctx context.Context, store storage.Store, w io.Writer) error {
+	var r renderer.Renderer
+	if c.Output != "" {
+		theme, err := renderer.LoadTheme(c.Theme)
+		if err != nil {
+			return err
+		}
+		if r, err = newRenderer(c.Format, c.Layout, c.Renderer, renderer.Options{Theme: theme}); err != nil {
+			return err
+		}
+	}
+
+	g, err := graph.NewBuilder(store).Build(ctx, c.Projects)
+	if err != nil {
+		return fmt.Errorf("failed to build graph: %w", err)
+	}
+
+	stats := graph.Hotspots(g)
+	if c.Top > 0 && len(stats) > c.Top {
+		stats = stats[:c.Top]
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "RANK\tTOPIC\tPROJECT\tSUBSCRIBERS\tCROSS-PROJECT\tSPOF")
+	seeds := make([]string, 0, len(stats))
+	for i, s := range stats {
+		spof := ""
+		if c.SPOFProjects > 0 && s.CrossProjectReach >= c.SPOFProjects {
+			spof = "yes"
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%d\t%d\t%s\n", i+1, s.Label, s.Project, s.Subscribers, s.CrossProjectReach, spof)
+		seeds = append(seeds, s.NodeID)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if c.Output == "" {
+		return nil
+	}
+
+	// Topics, their subscriptions and the subscriptions' sinks
+	focused := graph.Neighborhood(g, seeds, 2)
+	if err := r.Render(ctx, focused, c.Output, c.Format); err != nil {
+		return fmt.Errorf("failed to render graph: %w", err)
+	}
+	fmt.Fprintf(w, "Focused diagram saved to %s\n", c.Output)
+	return nil
+}
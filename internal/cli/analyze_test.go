@@ -0,0 +1,42 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHotspotsCmd(t *testing.T) {
+	store := setupListStore(t)
+	ctx := context.Background()
+
+	// Add a second cross-project consumer of orders-created
+	require.NoError(t, store.SaveSubscription(ctx, &storage.Subscription{
+		Name:                  "orders-audit",
+		ProjectID:             "project-c",
+		TopicFullResourceName: "projects/project-a/topics/orders-created",
+		FullResourceName:      "projects/project-c/subscriptions/orders-audit",
+	}))
+
+	output := filepath.Join(t.TempDir(), "hotspots.html")
+	var buf bytes.Buffer
+	cmd := &HotspotsCmd{Top: 1, SPOFProjects: 2, Output: output, Format: "html"}
+	require.NoError(t, cmd.analyze(ctx, store, &buf))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 3, "header, one topic and the diagram note")
+	assert.Contains(t, lines[1], "orders-created")
+	assert.True(t, strings.HasSuffix(strings.TrimSpace(lines[1]), "yes"), "topic should be flagged as SPOF")
+
+	data, err := os.ReadFile(output)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "orders-audit")
+	assert.NotContains(t, string(data), `"label":"users"`)
+}
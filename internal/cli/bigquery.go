@@ -0,0 +1,150 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/bigquery"
+	"github.com/NissesSenap/gcp-visualizer/internal/graph"
+	"github.com/NissesSenap/gcp-visualizer/internal/storage"
+)
+
+// exportBigQuery loads the whole cache into the tables of c.Dataset with api. Appending adds
+// the inventory as a new snapshot, and the scan runs that weren't exported before; truncating
+// replaces the tables with the inventory and every recorded scan run.
+func (c *ExportCmd) exportBigQuery(ctx context.Context, store storage.Store, api bigquery.API) error {
+	projectID, datasetID, err := bigquery.ParseDataset(c.Dataset)
+	if err != nil {
+		return err
+	}
+
+	topics, err := store.GetAllTopics(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to get topics: %w", err)
+	}
+	subs, err := store.GetAllSubscriptions(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to get subscriptions: %w", err)
+	}
+	destinations, err := store.GetAllSubscriptionDestinations(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to get subscription destinations: %w", err)
+	}
+	g, err := graph.NewBuilder(store).Build(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to build graph: %w", err)
+	}
+	runs, err := store.GetScanRuns(ctx, 0)
+	if err != nil {
+		return fmt.Errorf("failed to get scan runs: %w", err)
+	}
+
+	team := func(string) string { return "" }
+	if hasOwnership(c.ownership) {
+		ownership, err := newOwnership(ctx, store, c.ownership)
+		if err != nil {
+			return err
+		}
+		team = ownership.Team
+	}
+
+	inv := &bigquery.Inventory{}
+	counts, crossProject := countSubscriptions(subs)
+	for _, topic := range topics {
+		meta := parseCSVMetadata(topic.Metadata)
+		inv.Topics = append(inv.Topics, bigquery.TopicRow{
+			Project:                   topic.ProjectID,
+			Name:                      topic.Name,
+			FullResourceName:          topic.FullResourceName,
+			Subscriptions:             counts[topic.FullResourceName],
+			CrossProjectSubscriptions: crossProject[topic.FullResourceName],
+			MessageRetentionSeconds:   int64(topic.MessageRetention / time.Second),
+			KMSKeyName:                topic.KMSKeyName,
+			StorageRegions:            topic.StorageRegions,
+			Team:                      team(topic.ProjectID),
+			Labels:                    bigQueryLabels(meta.Labels),
+		})
+	}
+
+	exports := subscriptionExports(destinations)
+	for _, sub := range subs {
+		meta := parseCSVMetadata(sub.Metadata)
+		topicProject, _ := graph.ParseTopicReference(sub.TopicFullResourceName)
+		delivery, destination := subscriptionDelivery(sub, meta, exports)
+		row := bigquery.SubscriptionRow{
+			Project:            sub.ProjectID,
+			Name:               sub.Name,
+			FullResourceName:   sub.FullResourceName,
+			TopicProject:       topicProject,
+			Topic:              sub.TopicFullResourceName,
+			CrossProject:       topicProject != "" && topicProject != sub.ProjectID,
+			Delivery:           delivery,
+			PushEndpoint:       meta.PushEndpoint,
+			Destination:        destination,
+			AckDeadlineSeconds: meta.AckDeadline,
+			Filter:             meta.Filter,
+			DeadLetterTopic:    meta.DeadLetterTopic,
+			Team:               team(sub.ProjectID),
+			Labels:             bigQueryLabels(meta.Labels),
+		}
+		if meta.RetryPolicy != nil {
+			row.RetryMinimumBackoff, row.RetryMaximumBackoff = meta.RetryPolicy.MinimumBackoff, meta.RetryPolicy.MaximumBackoff
+		}
+		inv.Subscriptions = append(inv.Subscriptions, row)
+	}
+
+	for _, edge := range sortedEdges(g) {
+		from, okFrom := g.Nodes[edge.From]
+		to, okTo := g.Nodes[edge.To]
+		if !okFrom || !okTo {
+			continue
+		}
+		inv.Edges = append(inv.Edges, bigquery.EdgeRow{
+			Source:        csvNodeName(from),
+			SourceType:    string(from.Type),
+			SourceProject: from.Project,
+			Target:        csvNodeName(to),
+			TargetType:    string(to.Type),
+			TargetProject: to.Project,
+			Kind:          string(edge.Type),
+			CrossProject:  from.Project != "" && to.Project != "" && from.Project != to.Project,
+			Inferred:      edge.Inferred,
+		})
+	}
+
+	for _, run := range runs {
+		inv.ScanRuns = append(inv.ScanRuns, bigquery.ScanRunRow{
+			RunID:               run.RunID,
+			StartedAt:           run.StartedAt,
+			FinishedAt:          run.FinishedAt,
+			ProjectsAttempted:   run.ProjectsAttempted,
+			ProjectsSucceeded:   run.ProjectsSucceeded,
+			ProjectsFailed:      run.ProjectsFailed,
+			ProjectsSkipped:     run.ProjectsSkipped,
+			ProjectsInterrupted: run.ProjectsInterrupted,
+			Topics:              run.Topics,
+			Subscriptions:       run.Subscriptions,
+			APICalls:            run.APICalls,
+			Version:             run.Version,
+		})
+	}
+
+	return bigquery.Export(ctx, api, bigquery.Options{
+		ProjectID: projectID,
+		DatasetID: datasetID,
+		Location:  c.Location,
+		Mode:      c.Mode,
+	}, inv, time.Now())
+}
+
+// bigQueryLabels converts labels to key and value records, sorted by key
+func bigQueryLabels(labels map[string]string) []bigquery.Label {
+	records := make([]bigquery.Label, 0, len(labels))
+	for key, value := range labels {
+		records = append(records, bigquery.Label{Key: key, Value: value})
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].Key < records[j].Key })
+	return records
+}
@@ -0,0 +1,92 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/storage"
+)
+
+type ChangesCmd struct {
+	List ChangesListCmd `cmd:"list" help:"List recorded changes"`
+}
+
+type ChangesListCmd struct {
+	Since    time.Duration `help:"Only list changes from this far back" default:"24h"`
+	Projects []string      `name:"project" help:"Only list changes in these projects" placeholder:"PROJECT_ID"`
+	JSON     bool          `name:"json" help:"Output as JSON, including before and after metadata"`
+}
+
+// changeItem is a single change in JSON output
+type changeItem struct {
+	RunID            string          `json:"run_id,omitempty"`
+	ResourceType     string          `json:"resource_type"`
+	FullResourceName string          `json:"full_resource_name"`
+	ProjectID        string          `json:"project_id"`
+	ChangeType       string          `json:"change_type"`
+	Before           json.RawMessage `json:"before,omitempty"`
+	After            json.RawMessage `json:"after,omitempty"`
+	ChangedAt        time.Time       `json:"changed_at"`
+}
+
+func (c *ChangesListCmd) Run(cli *CLI) error {
+	store, err := openStore()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = store.Close() }()
+
+	return c.list(cli.Context(), store, os.Stdout, time.Now())
+}
+
+// list writes the changes recorded within the last c.Since to w, oldest first
+func (c *ChangesListCmd) list(ctx context.Context, store storage.Store, w io.Writer, now time.Time) error {
+	changes, err := store.GetChanges(ctx, now.Add(-c.Since), c.Projects)
+	if err != nil {
+		return fmt.Errorf("failed to get changes: %w", err)
+	}
+
+	if c.JSON {
+		items := make([]changeItem, 0, len(changes))
+		for _, change := range changes {
+			items = append(items, changeItem{
+				RunID:            change.RunID,
+				ResourceType:     change.ResourceType,
+				FullResourceName: change.FullResourceName,
+				ProjectID:        change.ProjectID,
+				ChangeType:       change.ChangeType,
+				Before:           rawMetadata(change.BeforeMetadata),
+				After:            rawMetadata(change.AfterMetadata),
+				ChangedAt:        change.ChangedAt,
+			})
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(items)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TIME\tCHANGE\tTYPE\tRESOURCE\tRUN")
+	for _, change := range changes {
+		run := change.RunID
+		if run == "" {
+			run = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", change.ChangedAt.Local().Format(time.DateTime),
+			change.ChangeType, change.ResourceType, change.FullResourceName, run)
+	}
+	return tw.Flush()
+}
+
+// rawMetadata embeds stored JSON metadata as is, nil if there is none or it isn't valid JSON
+func rawMetadata(metadata string) json.RawMessage {
+	if metadata == "" || !json.Valid([]byte(metadata)) {
+		return nil
+	}
+	return json.RawMessage(metadata)
+}
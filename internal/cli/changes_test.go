@@ -0,0 +1,56 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChangesListCmd(t *testing.T) {
+	store := setupListStore(t)
+	ctx := storage.WithRunID(context.Background(), "run-1")
+	require.NoError(t, store.DeleteTopic(ctx, "projects/project-b/topics/users"))
+
+	var buf bytes.Buffer
+	cmd := &ChangesListCmd{Since: time.Hour}
+	require.NoError(t, cmd.list(context.Background(), store, &buf, time.Now()))
+	out := buf.String()
+	assert.Contains(t, out, "created")
+	assert.Contains(t, out, "projects/project-b/topics/users")
+	assert.Contains(t, out, "run-1")
+
+	// Seen from two hours later, nothing happened within the last hour
+	buf.Reset()
+	require.NoError(t, cmd.list(context.Background(), store, &buf, time.Now().Add(2*time.Hour)))
+	assert.NotContains(t, buf.String(), "projects/")
+}
+
+func TestChangesListCmd_JSON(t *testing.T) {
+	store := setupListStore(t)
+	require.NoError(t, store.SaveTopic(context.Background(), &storage.Topic{
+		Name:             "users",
+		ProjectID:        "project-b",
+		FullResourceName: "projects/project-b/topics/users",
+		Metadata:         `{"labels":{"team":"identity"}}`,
+	}))
+
+	var buf bytes.Buffer
+	cmd := &ChangesListCmd{Since: time.Hour, Projects: []string{"project-b"}, JSON: true}
+	require.NoError(t, cmd.list(context.Background(), store, &buf, time.Now()))
+
+	var items []changeItem
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &items))
+	require.NotEmpty(t, items)
+	last := items[len(items)-1]
+	assert.Equal(t, storage.ChangeTypeUpdated, last.ChangeType)
+	assert.JSONEq(t, `{"labels":{"team":"identity"}}`, string(last.After))
+	for _, item := range items {
+		assert.Equal(t, "project-b", item.ProjectID)
+	}
+}
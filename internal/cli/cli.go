@@ -2,19 +2,51 @@ package cli
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
 
+	"github.com/NissesSenap/gcp-visualizer/internal/config"
+	"github.com/NissesSenap/gcp-visualizer/internal/graph"
+	"github.com/NissesSenap/gcp-visualizer/internal/storage"
 	"github.com/alecthomas/kong"
 )
 
+// Version of the CLI, recorded with every scan. Release builds set it with
+// -ldflags "-X github.com/NissesSenap/gcp-visualizer/internal/cli.Version=v1.2.3".
+var Version = "dev"
+
 // CLI is the main CLI structure with embedded context
 type CLI struct {
 	ctx context.Context // Store context for commands to use
 
-	Scan     ScanCmd     `cmd:"scan" help:"Scan GCP projects for resources"`
-	Generate GenerateCmd `cmd:"generate" help:"Generate visualization from cached data"`
-	Sync     SyncCmd     `cmd:"sync" help:"Smart refresh of stale resources"`
-	Config   ConfigCmd   `cmd:"config" help:"Manage configuration"`
-	Version  VersionCmd  `cmd:"version" help:"Show version"`
+	Timeout time.Duration `help:"Cancel the command after this long, e.g. 10m, so automated runs can't hang; zero never times out"`
+	Profile string        `help:"Apply this profile of the config, overriding its organization, projects, cache and rate limits" env:"GCP_VISUALIZER_PROFILE"`
+
+	Scan        ScanCmd        `cmd:"scan" help:"Scan GCP projects for resources"`
+	Generate    GenerateCmd    `cmd:"generate" help:"Generate visualization from cached data"`
+	Sync        SyncCmd        `cmd:"sync" help:"Smart refresh of stale resources"`
+	List        ListCmd        `cmd:"list" help:"List cached resources"`
+	Analyze     AnalyzeCmd     `cmd:"analyze" help:"Analyze the cached topology"`
+	Report      ReportCmd      `cmd:"report" help:"Report relationships in the cached topology"`
+	Lint        LintCmd        `cmd:"lint" help:"Check the cached topology against messaging rules"`
+	Verify      VerifyCmd      `cmd:"verify" help:"Compare the cached topology with a declared architecture, failing on differences"`
+	Stats       StatsCmd       `cmd:"stats" help:"Report inventory counts of the cached resources"`
+	Freshness   FreshnessCmd   `cmd:"freshness" help:"Show how recently each project was synced as a heatmap"`
+	Changes     ChangesCmd     `cmd:"changes" help:"Inspect the changelog of resources created, updated or deleted in the cache"`
+	Runs        RunsCmd        `cmd:"runs" help:"Inspect the history of scans, when the cache was refreshed and how healthy the scans were"`
+	Diff        DiffCmd        `cmd:"diff" help:"Compare two JSON exports in an interactive HTML page"`
+	Follow      FollowCmd      `cmd:"follow" help:"Show a live terminal dashboard for a topic"`
+	Describe    DescribeCmd    `cmd:"describe" help:"Show what the cache knows about a topic or subscription and what points at it"`
+	Query       QueryCmd       `cmd:"query" help:"Answer access-review questions from the cache"`
+	Listen      ListenCmd      `cmd:"listen" help:"Receive Cloud Audit Log events and update the cache incrementally"`
+	Serve       ServeCmd       `cmd:"serve" help:"Serve a read-only topology viewer and JSON API over the cache"`
+	Export      ExportCmd      `cmd:"export" help:"Write the whole cache to a JSON dump that can be shared"`
+	Import      ImportCmd      `cmd:"import" help:"Replace the cache contents with a JSON dump from 'export'"`
+	Config      ConfigCmd      `cmd:"config" help:"Manage configuration"`
+	Permissions PermissionsCmd `cmd:"permissions" help:"Print the minimal IAM roles required by the enabled collectors"`
+	Version     VersionCmd     `cmd:"version" help:"Show version"`
 }
 
 // Context returns the CLI's context for use by commands.
@@ -25,15 +57,58 @@ func (c *CLI) Context() context.Context {
 }
 
 type ScanCmd struct {
-	Projects []string `help:"Projects to scan" placeholder:"PROJECT_ID"`
-	Force    bool     `help:"Force refresh even if cached"`
+	Projects        []string      `help:"Projects to scan" placeholder:"PROJECT_ID"`
+	Force           bool          `help:"Also scan the projects synced within cache.ttl_hours, which are skipped otherwise"`
+	KeepStale       bool          `help:"Keep cached resources that no longer exist in GCP"`
+	Incremental     bool          `help:"Only record topics and subscriptions unchanged since the previous scan as seen, instead of rewriting them"`
+	IgnoreWindows   bool          `help:"Scan projects even outside the scan_windows of the config"`
+	CredentialsFile string        `help:"Service account key or external account JSON file, overrides GOOGLE_APPLICATION_CREDENTIALS and the config for this run" type:"path"`
+	MetricsListen   string        `help:"Serve collection metrics for Prometheus on this address while scanning, e.g. :9090"`
+	Pushgateway     string        `help:"Push collection metrics to this Prometheus Pushgateway URL when the scan finishes"`
+	Demo            bool          `help:"Scan the built-in demo inventory instead of GCP, no credentials needed"`
+	DryRun          bool          `help:"Print the projects and collectors the scan would run and estimate its API requests from the cache, without calling GCP"`
+	ProjectTimeout  time.Duration `help:"Fail the collection of a project after this long, e.g. 2m, classified as a timeout; zero never times out"`
+	GracePeriod     time.Duration `help:"On SIGINT or SIGTERM, how long the projects in flight may finish before they are cancelled" default:"30s"`
+	Resume          bool          `help:"Only scan the projects an interrupted scan didn't complete"`
+	MaxAPICalls     int64         `name:"max-api-calls" help:"Stop the scan once it made this many rate-limited API requests, counted like --dry-run estimates them, leaving the remaining projects to 'scan --resume'; zero is unlimited"`
+	ErrorsJSON      string        `name:"errors-json" help:"Write the errors of the scan per project, classified as auth, quota, api_disabled, timeout or other, to this JSON file" type:"path"`
 }
 
 type GenerateCmd struct {
-	Output   string   `help:"Output file path" default:"output.svg"`
-	Format   string   `help:"Output format" enum:"svg,png,pdf,html" default:"svg"`
-	Projects []string `help:"Filter by projects"`
-	Layout   string   `help:"Layout engine" enum:"fdp,dot,neato" default:"fdp"`
+	Output             string   `help:"Output file path (default: output.<format>)"`
+	Format             string   `help:"Output format" enum:"svg,png,pdf,html,json,openlineage" default:"svg"`
+	Projects           []string `help:"Filter by projects"`
+	Layout             string   `help:"Layout engine" enum:"fdp,dot,neato" default:"fdp"`
+	Renderer           string   `help:"Draw svg, png and pdf diagrams with the Graphviz binary, the built-in layout (svg and png only), or Graphviz when it is installed" enum:"auto,graphviz,embedded" default:"auto"`
+	Legend             bool     `help:"Add a legend with the node and edge types, scan time, project count and filters: in the corner of svg, png and pdf diagrams, or as a sidebar of html"`
+	Theme              string   `help:"Color theme: light, dark, colorblind or the path of a custom theme YAML file (default: visualization.theme of the config, or light)"`
+	ColorBy            string   `help:"Color nodes and flows by resource type or data classification, or flows by the publish traffic of their topic" enum:"type,classification,traffic" default:"type"`
+	Where              string   `help:"Only include nodes matching this expression, e.g. 'project =~ \"prod-.*\" && fanout > 3'"`
+	Focus              []string `help:"Only include these resources and everything within --depth hops of them, by full resource name or topic or subscription name" placeholder:"RESOURCE"`
+	Depth              int      `help:"Number of hops from the --focus resources to include" default:"2"`
+	TopicFilter        []string `help:"Only include topics whose name matches these glob patterns, and the resources connected to them" placeholder:"PATTERN"`
+	SubscriptionFilter []string `help:"Only include subscriptions whose name matches these glob patterns, and the resources connected to them" placeholder:"PATTERN"`
+	RetryLabels        bool     `help:"Label subscription edges with the subscription's retry backoff range"`
+	EdgeLabels         bool     `help:"Label subscription edges with push or pull, the ack deadline, and whether the subscription has a filter or a dead-letter topic"`
+	FilterLabels       bool     `help:"Label the edges of filtered subscriptions with their filter expression"`
+	HighlightOrphans   bool     `help:"Color topics without subscriptions grey and subscriptions whose topic is gone red"`
+	HighlightBacklogs  bool     `help:"Outline subscriptions over the backlog thresholds of the metrics config in red"`
+	ShowInferred       bool     `help:"Also draw publishers and consumers inferred from IAM bindings, dotted, not only those seen in audit logs"`
+	TrafficWidth       bool     `help:"Scale the width of flows by the publish traffic of their topic, read by the metrics collector"`
+	View               string   `help:"Apply a saved view from the views section of the config, flags left at their defaults take the view's values"`
+	Demo               bool     `help:"Render the built-in demo inventory instead of the cache"`
+	Level              string   `help:"Draw every resource, or one node per project with edges weighted by the relationships between them" enum:"resource,project" default:"resource"`
+	NoSummarize        bool     `help:"Draw every resource even when the graph has more nodes than visualization.max_nodes, instead of a summary of its projects"`
+	GroupBy            string   `help:"Cluster resources by project, projects by the team owning them as set in the ownership config, or resources by the regions topics store messages in" enum:"project,team,region" default:"project"`
+	DiffAgainst        string   `help:"Color the resources added since an earlier JSON export (generate --format json) or date, e.g. 2024-05-01 or an RFC 3339 time, green and ghost the removed ones in red" placeholder:"SNAPSHOT|DATE"`
+	TerraformState     []string `help:"Outline topics and subscriptions missing from these Terraform state files or 'terraform show -json' outputs in magenta, glob patterns allowed (default: terraform.state_files of the config)" placeholder:"FILE"`
+
+	styles            []graph.StyleRule       // visualization.styles of the config
+	collapsePatterns  []string                // visualization.collapse_patterns of the config
+	maxNodes          int                     // visualization.max_nodes of the config
+	ownership         config.Ownership        // ownership of the config
+	classification    config.Classification   // classification of the config
+	backlogThresholds graph.BacklogThresholds // metrics.max_backlog and max_unacked_age of the config
 }
 
 type SyncCmd struct {
@@ -41,22 +116,68 @@ type SyncCmd struct {
 }
 
 type ConfigCmd struct {
-	// Config command fields will be implemented in Phase 3
+	Validate ConfigValidateCmd `cmd:"validate" help:"Check the config for invalid values and likely mistakes"`
+	Env      ConfigEnvCmd      `cmd:"env" help:"List the GCP_VISUALIZER_* environment variables with their types, defaults and effective values"`
+}
+
+type PermissionsCmd struct {
+	Verbose bool `help:"Also list the exact IAM permissions used by each collector" short:"v"`
 }
 
 type VersionCmd struct {
 	// Version command fields to be implemented
 }
 
+// openStore opens the cache of the config: a SQLite database, or a JSON file
+// with storage.backend file, at cache.path or else the backend's default file
+// in the cache directory. storage.busy_timeout only applies to SQLite.
+func openStore() (storage.Store, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+	store, err := storage.Open(cfg.Storage.Backend, cfg.Cache.Path, storage.SQLiteOptions{BusyTimeout: cfg.Storage.BusyTimeout})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	return store, nil
+}
+
 // ExecuteWithContext executes the CLI with a context that can be cancelled
 func ExecuteWithContext(ctx context.Context) error {
 	cli := &CLI{ctx: ctx}
 	kongCtx := kong.Parse(cli)
 
+	// config.Load reads the profile from the environment, like the path of the config itself
+	if cli.Profile != "" {
+		if err := os.Setenv("GCP_VISUALIZER_PROFILE", cli.Profile); err != nil {
+			return err
+		}
+	}
+
+	if cli.Timeout > 0 {
+		var cancel context.CancelFunc
+		cli.ctx, cancel = context.WithTimeout(ctx, cli.Timeout)
+		defer cancel()
+	}
+
 	// Bind CLI instance so commands can access the context
 	return kongCtx.Run(cli)
 }
 
+// ExitCode returns the process exit status for an error returned by Execute: 0 for nil,
+// the code of errors implementing kong.ExitCoder, such as a partially failed scan, and 1 otherwise
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var coder kong.ExitCoder
+	if errors.As(err, &coder) {
+		return coder.ExitCode()
+	}
+	return 1
+}
+
 // Execute executes the CLI with a background context (for backwards compatibility)
 func Execute() error {
 	return ExecuteWithContext(context.Background())
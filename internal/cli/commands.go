@@ -2,30 +2,10 @@ package cli
 
 import (
 	"fmt"
-)
-
-func (c *ScanCmd) Run(cli *CLI) error {
-	// Context is available via cli.Context() for cancellation
-	// TODO: Implement scan logic in Phase 6
-	// When collector is wired up, pass cli.Context() to CollectProject
-	fmt.Printf("Scanning projects: %v\n", c.Projects)
-	if c.Force {
-		fmt.Println("Force refresh enabled")
-	}
-	return nil
-}
 
-func (c *GenerateCmd) Run(cli *CLI) error {
-	// Context is available via cli.Context() for cancellation
-	// TODO: Implement generate logic in Phase 10
-	// When graph building is implemented, pass cli.Context() for cancellation support
-	fmt.Printf("Generating %s output to %s\n", c.Format, c.Output)
-	if len(c.Projects) > 0 {
-		fmt.Printf("Filtering by projects: %v\n", c.Projects)
-	}
-	fmt.Printf("Using layout engine: %s\n", c.Layout)
-	return nil
-}
+	"github.com/NissesSenap/gcp-visualizer/internal/collector"
+	"github.com/NissesSenap/gcp-visualizer/internal/config"
+)
 
 func (c *SyncCmd) Run(cli *CLI) error {
 	// Context is available via cli.Context() for cancellation
@@ -34,16 +14,54 @@ func (c *SyncCmd) Run(cli *CLI) error {
 	return nil
 }
 
-func (c *ConfigCmd) Run(cli *CLI) error {
-	// Context is available via cli.Context() for cancellation
-	// TODO: Implement config management in Phase 3
-	fmt.Println("Config management (to be implemented)")
+func (c *PermissionsCmd) Run(cli *CLI) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if err := collector.ValidateCollectorNames(cfg.Collectors); err != nil {
+		return err
+	}
+	specs := collector.SpecsFor(cfg.Collectors)
+
+	fmt.Println("Required IAM roles:")
+	for _, role := range collector.RequiredRoles(specs) {
+		fmt.Printf("  %s\n", role)
+	}
+
+	if c.Verbose {
+		fmt.Println("\nPermissions by collector:")
+		for _, spec := range specs {
+			fmt.Printf("  %s:\n", spec.Name)
+			for _, perm := range spec.Permissions {
+				fmt.Printf("    %s\n", perm)
+			}
+		}
+	}
+
+	// Exports writing outside the cache are listed apart, they need read_only disabled
+	fmt.Println("\nAlso required by export --format bigquery, which writes and needs read_only disabled:")
+	for _, role := range collector.RequiredRoles(collector.ExportSpecs()) {
+		fmt.Printf("  %s\n", role)
+	}
+	if c.Verbose {
+		for _, spec := range collector.ExportSpecs() {
+			fmt.Printf("  %s:\n", spec.Name)
+			for _, perm := range spec.Permissions {
+				fmt.Printf("    %s\n", perm)
+			}
+		}
+	}
+
+	// Mutating collectors should never be registered, fail loudly if one is
+	if err := collector.CheckReadOnly(specs); err != nil {
+		return err
+	}
 	return nil
 }
 
 func (c *VersionCmd) Run(cli *CLI) error {
 	// Context is available via cli.Context() if needed (though version doesn't need it)
-	// TODO: Add proper version from build
-	fmt.Println("gcp-visualizer version: dev")
+	fmt.Printf("gcp-visualizer version: %s\n", Version)
 	return nil
 }
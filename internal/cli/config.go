@@ -0,0 +1,80 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/collector"
+	"github.com/NissesSenap/gcp-visualizer/internal/config"
+)
+
+type ConfigValidateCmd struct{}
+
+type ConfigEnvCmd struct {
+	JSON bool `name:"json" help:"Output as JSON"`
+}
+
+func (c *ConfigValidateCmd) Run(cli *CLI) error {
+	return c.validate(os.Stdout)
+}
+
+// validate loads the config, which fails on invalid values, and writes its warnings to w
+func (c *ConfigValidateCmd) validate(w io.Writer) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	if err := collector.ValidateCollectorNames(cfg.Collectors); err != nil {
+		return fmt.Errorf("invalid config:\n  collectors: %w", err)
+	}
+
+	warnings, err := cfg.Validate()
+	if err != nil {
+		return err
+	}
+	for _, warning := range warnings {
+		fmt.Fprintf(w, "warning: %s\n", warning)
+	}
+
+	source := config.ConfigPath()
+	if _, err := os.Stat(source); err != nil {
+		source = "the defaults"
+	}
+	if cfg.Profile != "" {
+		fmt.Fprintf(w, "Config of %s with profile %s is valid\n", source, cfg.Profile)
+	} else {
+		fmt.Fprintf(w, "Config of %s is valid\n", source)
+	}
+	return nil
+}
+
+func (c *ConfigEnvCmd) Run(cli *CLI) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	return c.list(os.Stdout, config.EnvVars(cfg, os.LookupEnv))
+}
+
+// list writes vars to w, marking those set in the environment
+func (c *ConfigEnvCmd) list(w io.Writer, vars []config.EnvVar) error {
+	if c.JSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(vars)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "VARIABLE\tFIELD\tTYPE\tDEFAULT\tVALUE")
+	for _, v := range vars {
+		value := orDash(v.Value)
+		if v.Set {
+			value += " (set)"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", v.Name, v.Field, v.Type, orDash(v.Default), value)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,54 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigValidateCmd(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	t.Setenv("GCP_VISUALIZER_CONFIG", configPath)
+
+	require.NoError(t, os.WriteFile(configPath, []byte("read_only: false\n"), 0o600))
+	var buf bytes.Buffer
+	require.NoError(t, (&ConfigValidateCmd{}).validate(&buf))
+	assert.Contains(t, buf.String(), "warning: read_only is disabled")
+	assert.Contains(t, buf.String(), "is valid")
+
+	require.NoError(t, os.WriteFile(configPath, []byte(`
+rate_limits:
+  requests_per_second: -1
+  max_concurrent: 0
+visualization:
+  layout: circo
+`), 0o600))
+	err := (&ConfigValidateCmd{}).validate(&bytes.Buffer{})
+	var invalid *config.ValidationError
+	require.ErrorAs(t, err, &invalid)
+	assert.Equal(t, []string{
+		`visualization.layout must be one of fdp, dot, neato, not "circo"`,
+		"rate_limits.requests_per_second must be > 0",
+		"rate_limits.max_concurrent must be >= 1",
+	}, invalid.Problems)
+
+	require.NoError(t, os.WriteFile(configPath, []byte("collectors: [pubsub, bigtable]\n"), 0o600))
+	assert.ErrorContains(t, (&ConfigValidateCmd{}).validate(&bytes.Buffer{}), "collectors")
+}
+
+func TestConfigEnvCmd(t *testing.T) {
+	vars := []config.EnvVar{
+		{Name: "GCP_VISUALIZER_LAYOUT", Field: "visualization.layout", Type: "string", Default: "fdp", Value: "dot", Set: true},
+		{Name: "GCP_VISUALIZER_CACHE_PATH", Field: "cache.path", Type: "string"},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, (&ConfigEnvCmd{}).list(&buf, vars))
+	assert.Contains(t, buf.String(), "dot (set)")
+	assert.Regexp(t, `GCP_VISUALIZER_CACHE_PATH\s+cache.path\s+string\s+-\s+-`, buf.String())
+}
@@ -0,0 +1,225 @@
+package cli
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/graph"
+	"github.com/NissesSenap/gcp-visualizer/internal/storage"
+)
+
+// csvMetadata is the part of the stored JSON metadata of topics and subscriptions written to the CSV export
+type csvMetadata struct {
+	Labels          map[string]string `json:"labels"`
+	DeadLetterTopic string            `json:"dead_letter_topic"`
+	PushEndpoint    string            `json:"push_endpoint"`
+	AckDeadline     int               `json:"ack_deadline_seconds"`
+	Filter          string            `json:"filter"`
+	RetryPolicy     *struct {
+		MinimumBackoff string `json:"minimum_backoff"`
+		MaximumBackoff string `json:"maximum_backoff"`
+	} `json:"retry_policy"`
+}
+
+// exportCSV writes topics.csv, subscriptions.csv and edges.csv of the whole cache into dir, creating it if needed
+func exportCSV(ctx context.Context, store storage.Store, dir string) error {
+	if dir == "" {
+		return fmt.Errorf("--output DIR is required for csv, the export is three files")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+
+	topics, err := store.GetAllTopics(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to get topics: %w", err)
+	}
+	subs, err := store.GetAllSubscriptions(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to get subscriptions: %w", err)
+	}
+	destinations, err := store.GetAllSubscriptionDestinations(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to get subscription destinations: %w", err)
+	}
+	g, err := graph.NewBuilder(store).Build(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to build graph: %w", err)
+	}
+
+	for name, write := range map[string]func(w io.Writer) error{
+		"topics.csv":        func(w io.Writer) error { return writeTopicsCSV(w, topics, subs) },
+		"subscriptions.csv": func(w io.Writer) error { return writeSubscriptionsCSV(w, subs, destinations) },
+		"edges.csv":         func(w io.Writer) error { return writeEdgesCSV(w, g) },
+	} {
+		if err := writeFileAtomic(filepath.Join(dir, name), write); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeTopicsCSV writes a row per topic with its settings and the number of subscriptions reading it
+func writeTopicsCSV(w io.Writer, topics []*storage.Topic, subs []*storage.Subscription) error {
+	counts, crossProject := countSubscriptions(subs)
+	sorted := append([]*storage.Topic(nil), topics...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].FullResourceName < sorted[j].FullResourceName })
+
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"project", "name", "full_resource_name", "subscriptions", "cross_project_subscriptions",
+		"message_retention", "kms_key_name", "storage_regions", "labels"})
+	for _, topic := range sorted {
+		meta := parseCSVMetadata(topic.Metadata)
+		retention := ""
+		if topic.MessageRetention > 0 {
+			retention = topic.MessageRetention.String()
+		}
+		_ = cw.Write([]string{
+			topic.ProjectID, topic.Name, topic.FullResourceName,
+			strconv.Itoa(counts[topic.FullResourceName]), strconv.Itoa(crossProject[topic.FullResourceName]),
+			retention, topic.KMSKeyName, strings.Join(topic.StorageRegions, ";"), formatCSVLabels(meta.Labels),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// writeSubscriptionsCSV writes a row per subscription with its topic, delivery and dead-letter settings
+func writeSubscriptionsCSV(w io.Writer, subs []*storage.Subscription, destinations []*storage.SubscriptionDestination) error {
+	exports := subscriptionExports(destinations)
+	sorted := append([]*storage.Subscription(nil), subs...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].FullResourceName < sorted[j].FullResourceName })
+
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"project", "name", "full_resource_name", "topic_project", "topic", "cross_project",
+		"delivery", "push_endpoint", "destination", "ack_deadline_seconds", "filter", "dead_letter_topic",
+		"retry_minimum_backoff", "retry_maximum_backoff", "labels"})
+	for _, sub := range sorted {
+		meta := parseCSVMetadata(sub.Metadata)
+		topicProject, _ := graph.ParseTopicReference(sub.TopicFullResourceName)
+
+		delivery, destination := subscriptionDelivery(sub, meta, exports)
+		ackDeadline := ""
+		if meta.AckDeadline > 0 {
+			ackDeadline = strconv.Itoa(meta.AckDeadline)
+		}
+		minBackoff, maxBackoff := "", ""
+		if meta.RetryPolicy != nil {
+			minBackoff, maxBackoff = meta.RetryPolicy.MinimumBackoff, meta.RetryPolicy.MaximumBackoff
+		}
+
+		_ = cw.Write([]string{
+			sub.ProjectID, sub.Name, sub.FullResourceName, topicProject, sub.TopicFullResourceName,
+			strconv.FormatBool(topicProject != "" && topicProject != sub.ProjectID),
+			delivery, meta.PushEndpoint, destination, ackDeadline, meta.Filter, meta.DeadLetterTopic,
+			minBackoff, maxBackoff, formatCSVLabels(meta.Labels),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// writeEdgesCSV writes a row per edge of g, with the type and project of both ends
+func writeEdgesCSV(w io.Writer, g *graph.Graph) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"source", "source_type", "source_project", "target", "target_type", "target_project",
+		"kind", "cross_project", "inferred"})
+	for _, edge := range sortedEdges(g) {
+		from, okFrom := g.Nodes[edge.From]
+		to, okTo := g.Nodes[edge.To]
+		if !okFrom || !okTo {
+			continue
+		}
+		crossProject := from.Project != "" && to.Project != "" && from.Project != to.Project
+		_ = cw.Write([]string{
+			csvNodeName(from), string(from.Type), from.Project,
+			csvNodeName(to), string(to.Type), to.Project,
+			string(edge.Type), strconv.FormatBool(crossProject), strconv.FormatBool(edge.Inferred),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// countSubscriptions returns the number of subscriptions of every topic, and how many of them are in another project
+func countSubscriptions(subs []*storage.Subscription) (counts, crossProject map[string]int) {
+	counts = make(map[string]int)
+	crossProject = make(map[string]int)
+	for _, sub := range subs {
+		counts[sub.TopicFullResourceName]++
+		if project, _ := graph.ParseTopicReference(sub.TopicFullResourceName); project != sub.ProjectID {
+			crossProject[sub.TopicFullResourceName]++
+		}
+	}
+	return counts, crossProject
+}
+
+// subscriptionExports returns the BigQuery and Cloud Storage destinations by subscription
+func subscriptionExports(destinations []*storage.SubscriptionDestination) map[string]*storage.SubscriptionDestination {
+	exports := make(map[string]*storage.SubscriptionDestination)
+	for _, dest := range destinations {
+		if dest.Type == storage.DestinationTypeBigQuery || dest.Type == storage.DestinationTypeCloudStorage {
+			exports[dest.SubscriptionFullResourceName] = dest
+		}
+	}
+	return exports
+}
+
+// subscriptionDelivery returns how a subscription delivers messages, pull, push or the
+// type of its export, and the resource an export writes to
+func subscriptionDelivery(sub *storage.Subscription, meta csvMetadata, exports map[string]*storage.SubscriptionDestination) (delivery, destination string) {
+	if dest, ok := exports[sub.FullResourceName]; ok {
+		return dest.Type, dest.Resource
+	}
+	if meta.PushEndpoint != "" {
+		return "push", ""
+	}
+	return "pull", ""
+}
+
+// sortedEdges returns the edges of g sorted by their source and target
+func sortedEdges(g *graph.Graph) []*graph.Edge {
+	edges := append([]*graph.Edge(nil), g.Edges...)
+	sort.SliceStable(edges, func(i, j int) bool {
+		if edges[i].From != edges[j].From {
+			return edges[i].From < edges[j].From
+		}
+		return edges[i].To < edges[j].To
+	})
+	return edges
+}
+
+// csvNodeName names a node by its full resource name, or its label for resources without one
+func csvNodeName(node *graph.Node) string {
+	if name := node.Metadata["full_resource_name"]; name != "" {
+		return name
+	}
+	return node.Label
+}
+
+// parseCSVMetadata decodes stored JSON metadata, metadata that can't be decoded is left empty
+func parseCSVMetadata(raw string) csvMetadata {
+	var meta csvMetadata
+	if raw != "" {
+		_ = json.Unmarshal([]byte(raw), &meta)
+	}
+	return meta
+}
+
+// formatCSVLabels formats labels as "key=value" pairs separated by semicolons, sorted by key
+func formatCSVLabels(labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+	for key, value := range labels {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ";")
+}
@@ -0,0 +1,47 @@
+package cli
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportCmd_CSV(t *testing.T) {
+	store := setupListStore(t)
+	ctx := context.Background()
+	require.NoError(t, store.SaveSubscription(ctx, &storage.Subscription{
+		Name:                  "users-dlq",
+		ProjectID:             "project-b",
+		TopicFullResourceName: "projects/project-b/topics/users",
+		FullResourceName:      "projects/project-b/subscriptions/users-dlq",
+		Metadata:              `{"labels":{"team":"identity","env":"prod"},"dead_letter_topic":"projects/project-b/topics/users-dead","push_endpoint":"https://example.com/push","ack_deadline_seconds":30}`,
+	}))
+	dir := filepath.Join(t.TempDir(), "export")
+
+	require.NoError(t, (&ExportCmd{Output: dir, Format: "csv"}).export(ctx, store, nil))
+
+	read := func(name string) string {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		require.NoError(t, err)
+		return string(data)
+	}
+	assert.Equal(t, `project,name,full_resource_name,subscriptions,cross_project_subscriptions,message_retention,kms_key_name,storage_regions,labels
+project-a,orders-created,projects/project-a/topics/orders-created,1,1,,,,
+project-b,users,projects/project-b/topics/users,1,0,,,,
+`, read("topics.csv"))
+	assert.Equal(t, `project,name,full_resource_name,topic_project,topic,cross_project,delivery,push_endpoint,destination,ack_deadline_seconds,filter,dead_letter_topic,retry_minimum_backoff,retry_maximum_backoff,labels
+project-b,orders-email,projects/project-b/subscriptions/orders-email,project-a,projects/project-a/topics/orders-created,true,pull,,,,,,,,
+project-b,users-dlq,projects/project-b/subscriptions/users-dlq,project-b,projects/project-b/topics/users,false,push,https://example.com/push,,30,,projects/project-b/topics/users-dead,,,env=prod;team=identity
+`, read("subscriptions.csv"))
+	assert.Equal(t, `source,source_type,source_project,target,target_type,target_project,kind,cross_project,inferred
+projects/project-b/subscriptions/orders-email,subscription,project-b,projects/project-a/topics/orders-created,topic,project-a,cross_project,true,false
+projects/project-b/subscriptions/users-dlq,subscription,project-b,projects/project-b/topics/users,topic,project-b,subscribes,false,false
+`, read("edges.csv"))
+
+	assert.ErrorContains(t, (&ExportCmd{Format: "csv"}).export(ctx, store, nil), "--output DIR")
+}
@@ -0,0 +1,335 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/storage"
+)
+
+type DescribeCmd struct {
+	Resource string `arg:"" help:"Full resource name of a topic or subscription, e.g. projects/my-project/topics/orders"`
+	JSON     bool   `name:"json" help:"Output as JSON"`
+}
+
+// errResourceNotCached is returned by describe for a resource the cache doesn't hold
+var errResourceNotCached = errors.New("resource not found in the cache")
+
+// resourceDescription is everything the cache knows about a topic or subscription,
+// and the resources that point at it
+type resourceDescription struct {
+	FullResourceName string                 `json:"full_resource_name"`
+	Kind             string                 `json:"kind"` // storage.ResourceTypeTopic or storage.ResourceTypeSubscription
+	ProjectID        string                 `json:"project_id"`
+	LastSynced       *time.Time             `json:"last_synced,omitempty"`
+	Metadata         map[string]interface{} `json:"metadata,omitempty"`
+
+	// Subscriptions only
+	Topic string `json:"topic,omitempty"`
+
+	// Topics only, the subscriptions of every cached project attached to the topic
+	Subscriptions []describedSubscription `json:"subscriptions,omitempty"`
+
+	// Publishers of a topic or consumers of a subscription, from IAM bindings and audit logs
+	Access []describedAccess `json:"access,omitempty"`
+
+	// Functions, pipelines and sinks connected to the resource
+	Related []describedRelation `json:"related,omitempty"`
+}
+
+type describedSubscription struct {
+	FullResourceName string `json:"full_resource_name"`
+	ProjectID        string `json:"project_id"`
+	CrossProject     bool   `json:"cross_project"`
+}
+
+type describedAccess struct {
+	Principal string `json:"principal"`
+	Role      string `json:"role"`   // IAM role, audited method or the relation of the edge
+	Source    string `json:"source"` // storage.ConsumerSourceIAM or storage.ConsumerSourceAuditLog
+}
+
+type describedRelation struct {
+	Relation string `json:"relation"` // e.g. "triggers" or "delivers to"
+	Resource string `json:"resource"`
+}
+
+func (c *DescribeCmd) Run(cli *CLI) error {
+	store, err := openStore()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = store.Close() }()
+
+	return c.describe(cli.Context(), store, os.Stdout)
+}
+
+// describe writes what the cache knows about c.Resource to w
+func (c *DescribeCmd) describe(ctx context.Context, store storage.Store, w io.Writer) error {
+	project, collection, ok := splitFullResourceName(c.Resource)
+	if !ok {
+		return fmt.Errorf("invalid resource name %q, use projects/PROJECT/topics/NAME or projects/PROJECT/subscriptions/NAME", c.Resource)
+	}
+
+	var desc *resourceDescription
+	var err error
+	switch collection {
+	case "topics":
+		desc, err = describeTopic(ctx, store, project, c.Resource)
+	case "subscriptions":
+		desc, err = describeSubscription(ctx, store, project, c.Resource)
+	default:
+		return fmt.Errorf("cannot describe %s, only topics and subscriptions are supported", collection)
+	}
+	if err != nil {
+		return err
+	}
+
+	syncTimes, err := store.GetProjectSyncTimes(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get project sync times: %w", err)
+	}
+	if synced, ok := syncTimes[project]; ok {
+		desc.LastSynced = &synced
+	}
+
+	if c.JSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(desc)
+	}
+	return writeDescription(w, desc)
+}
+
+// splitFullResourceName returns the project and collection of "projects/{project}/{collection}/{name}"
+func splitFullResourceName(name string) (project, collection string, ok bool) {
+	parts := strings.Split(name, "/")
+	if len(parts) != 4 || parts[0] != "projects" || parts[1] == "" || parts[3] == "" {
+		return "", "", false
+	}
+	return parts[1], parts[2], true
+}
+
+func describeTopic(ctx context.Context, store storage.Store, project, name string) (*resourceDescription, error) {
+	topics, err := store.GetTopics(ctx, project)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get topics: %w", err)
+	}
+	i := slices.IndexFunc(topics, func(t *storage.Topic) bool { return t.FullResourceName == name })
+	if i < 0 {
+		return nil, fmt.Errorf("%w: %s", errResourceNotCached, name)
+	}
+	topic := topics[i]
+	desc := &resourceDescription{
+		FullResourceName: name,
+		Kind:             storage.ResourceTypeTopic,
+		ProjectID:        project,
+		Metadata:         parseDescribedMetadata(topic.Metadata),
+	}
+
+	// Every cached project, subscriptions and functions of other projects can point at the topic
+	subs, err := store.GetAllSubscriptions(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get subscriptions: %w", err)
+	}
+	for _, sub := range subs {
+		if sub.TopicFullResourceName != name {
+			continue
+		}
+		desc.Subscriptions = append(desc.Subscriptions, describedSubscription{
+			FullResourceName: sub.FullResourceName,
+			ProjectID:        sub.ProjectID,
+			CrossProject:     sub.ProjectID != project,
+		})
+	}
+	sort.Slice(desc.Subscriptions, func(i, j int) bool {
+		return desc.Subscriptions[i].FullResourceName < desc.Subscriptions[j].FullResourceName
+	})
+
+	edges, err := store.GetEdges(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get edges: %w", err)
+	}
+	for _, edge := range edges {
+		if edge.Target != name {
+			continue
+		}
+		switch edge.Relation {
+		case storage.RelationCanPublish:
+			desc.Access = append(desc.Access, describedAccess{Principal: edge.Source, Role: edge.Relation, Source: storage.ConsumerSourceIAM})
+		case storage.RelationPublishes:
+			desc.Access = append(desc.Access, describedAccess{Principal: edge.Source, Role: edge.Relation, Source: storage.ConsumerSourceAuditLog})
+		}
+	}
+
+	functions, err := store.GetAllCloudFunctions(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Cloud Functions: %w", err)
+	}
+	for _, fn := range functions {
+		if fn.TriggerTopic == name {
+			desc.Related = append(desc.Related, describedRelation{Relation: "triggers", Resource: fn.FullResourceName})
+		}
+	}
+	if err := addDataflowRelations(ctx, store, desc); err != nil {
+		return nil, err
+	}
+
+	sortDescription(desc)
+	return desc, nil
+}
+
+func describeSubscription(ctx context.Context, store storage.Store, project, name string) (*resourceDescription, error) {
+	subs, err := store.GetSubscriptions(ctx, project)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get subscriptions: %w", err)
+	}
+	i := slices.IndexFunc(subs, func(s *storage.Subscription) bool { return s.FullResourceName == name })
+	if i < 0 {
+		return nil, fmt.Errorf("%w: %s", errResourceNotCached, name)
+	}
+	sub := subs[i]
+	desc := &resourceDescription{
+		FullResourceName: name,
+		Kind:             storage.ResourceTypeSubscription,
+		ProjectID:        project,
+		Metadata:         parseDescribedMetadata(sub.Metadata),
+		Topic:            sub.TopicFullResourceName,
+	}
+
+	consumers, err := store.GetAllSubscriptionConsumers(ctx, []string{project})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get subscription consumers: %w", err)
+	}
+	for _, consumer := range consumers {
+		if consumer.SubscriptionFullResourceName == name {
+			desc.Access = append(desc.Access, describedAccess{Principal: consumer.Principal, Role: consumer.Role, Source: consumer.Source})
+		}
+	}
+
+	destinations, err := store.GetAllSubscriptionDestinations(ctx, []string{project})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get subscription destinations: %w", err)
+	}
+	for _, dest := range destinations {
+		if dest.SubscriptionFullResourceName == name {
+			desc.Related = append(desc.Related, describedRelation{Relation: "delivers to " + dest.Type, Resource: dest.Resource})
+		}
+	}
+	if err := addDataflowRelations(ctx, store, desc); err != nil {
+		return nil, err
+	}
+
+	sortDescription(desc)
+	return desc, nil
+}
+
+// addDataflowRelations adds the Dataflow jobs of every cached project reading or writing desc
+func addDataflowRelations(ctx context.Context, store storage.Store, desc *resourceDescription) error {
+	jobs, err := store.GetAllDataflowJobs(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to get Dataflow jobs: %w", err)
+	}
+	for _, job := range jobs {
+		if slices.Contains(job.Sources, desc.FullResourceName) {
+			desc.Related = append(desc.Related, describedRelation{Relation: "read by", Resource: job.FullResourceName})
+		}
+		if slices.Contains(job.Sinks, desc.FullResourceName) {
+			desc.Related = append(desc.Related, describedRelation{Relation: "written by", Resource: job.FullResourceName})
+		}
+	}
+	return nil
+}
+
+func sortDescription(desc *resourceDescription) {
+	sort.Slice(desc.Access, func(i, j int) bool {
+		a, b := desc.Access[i], desc.Access[j]
+		if a.Principal != b.Principal {
+			return a.Principal < b.Principal
+		}
+		return a.Source < b.Source
+	})
+	sort.Slice(desc.Related, func(i, j int) bool {
+		a, b := desc.Related[i], desc.Related[j]
+		if a.Relation != b.Relation {
+			return a.Relation < b.Relation
+		}
+		return a.Resource < b.Resource
+	})
+}
+
+// parseDescribedMetadata decodes the stored JSON metadata of a resource, nil if it has none
+func parseDescribedMetadata(metadata string) map[string]interface{} {
+	var m map[string]interface{}
+	if metadata == "" || json.Unmarshal([]byte(metadata), &m) != nil || len(m) == 0 {
+		return nil
+	}
+	return m
+}
+
+// writeDescription writes desc as text to w
+func writeDescription(w io.Writer, desc *resourceDescription) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Name:\t%s\n", desc.FullResourceName)
+	fmt.Fprintf(tw, "Kind:\t%s\n", desc.Kind)
+	fmt.Fprintf(tw, "Project:\t%s\n", desc.ProjectID)
+	if desc.LastSynced != nil {
+		fmt.Fprintf(tw, "Last synced:\t%s\n", desc.LastSynced.UTC().Format(time.RFC3339))
+	} else {
+		fmt.Fprintf(tw, "Last synced:\tnever\n")
+	}
+	if desc.Topic != "" {
+		fmt.Fprintf(tw, "Topic:\t%s\n", desc.Topic)
+	}
+
+	if len(desc.Metadata) > 0 {
+		fmt.Fprintln(tw, "\nMetadata:")
+		keys := make([]string, 0, len(desc.Metadata))
+		for key := range desc.Metadata {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			value, err := json.Marshal(desc.Metadata[key])
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(tw, "  %s:\t%s\n", key, strings.Trim(string(value), `"`))
+		}
+	}
+
+	if desc.Kind == storage.ResourceTypeTopic {
+		fmt.Fprintf(tw, "\nSubscriptions (%d):\n", len(desc.Subscriptions))
+		for _, sub := range desc.Subscriptions {
+			if sub.CrossProject {
+				fmt.Fprintf(tw, "  %s\tcross-project\n", sub.FullResourceName)
+			} else {
+				fmt.Fprintf(tw, "  %s\t\n", sub.FullResourceName)
+			}
+		}
+	}
+
+	if len(desc.Access) > 0 {
+		fmt.Fprintln(tw, "\nIAM and audit logs:")
+		for _, a := range desc.Access {
+			fmt.Fprintf(tw, "  %s\t%s\t%s\n", a.Principal, a.Role, a.Source)
+		}
+	}
+
+	if len(desc.Related) > 0 {
+		fmt.Fprintln(tw, "\nRelated:")
+		for _, r := range desc.Related {
+			fmt.Fprintf(tw, "  %s\t%s\n", r.Relation, r.Resource)
+		}
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,85 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDescribeCmd_Topic(t *testing.T) {
+	store := setupListStore(t)
+	ctx := context.Background()
+
+	require.NoError(t, store.SaveSubscription(ctx, &storage.Subscription{
+		Name:                  "orders-audit",
+		ProjectID:             "project-a",
+		TopicFullResourceName: "projects/project-a/topics/orders-created",
+		FullResourceName:      "projects/project-a/subscriptions/orders-audit",
+	}))
+	require.NoError(t, store.SaveEdges(ctx, []*storage.ResourceEdge{{
+		Source:    "serviceAccount:shop@project-a.iam.gserviceaccount.com",
+		Target:    "projects/project-a/topics/orders-created",
+		Relation:  storage.RelationCanPublish,
+		ProjectID: "project-a",
+	}}))
+	require.NoError(t, store.UpdateProjectSyncTime(ctx, "project-a"))
+
+	var buf bytes.Buffer
+	cmd := &DescribeCmd{Resource: "projects/project-a/topics/orders-created", JSON: true}
+	require.NoError(t, cmd.describe(ctx, store, &buf))
+
+	var desc resourceDescription
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &desc))
+	assert.Equal(t, storage.ResourceTypeTopic, desc.Kind)
+	assert.NotNil(t, desc.LastSynced)
+	assert.Equal(t, []describedSubscription{
+		{FullResourceName: "projects/project-a/subscriptions/orders-audit", ProjectID: "project-a"},
+		{FullResourceName: "projects/project-b/subscriptions/orders-email", ProjectID: "project-b", CrossProject: true},
+	}, desc.Subscriptions)
+	assert.Equal(t, []describedAccess{
+		{Principal: "serviceAccount:shop@project-a.iam.gserviceaccount.com", Role: storage.RelationCanPublish, Source: storage.ConsumerSourceIAM},
+	}, desc.Access)
+
+	buf.Reset()
+	cmd.JSON = false
+	require.NoError(t, cmd.describe(ctx, store, &buf))
+	assert.Contains(t, buf.String(), "Subscriptions (2):")
+	assert.Contains(t, buf.String(), "cross-project")
+}
+
+func TestDescribeCmd_Subscription(t *testing.T) {
+	store := setupListStore(t)
+	ctx := context.Background()
+	require.NoError(t, store.SaveSubscriptionConsumer(ctx, &storage.SubscriptionConsumer{
+		SubscriptionFullResourceName: "projects/project-b/subscriptions/orders-email",
+		ProjectID:                    "project-b",
+		Principal:                    "serviceAccount:mailer@project-b.iam.gserviceaccount.com",
+		Source:                       storage.ConsumerSourceIAM,
+		Role:                         "roles/pubsub.subscriber",
+	}))
+
+	var buf bytes.Buffer
+	require.NoError(t, (&DescribeCmd{Resource: "projects/project-b/subscriptions/orders-email"}).describe(ctx, store, &buf))
+	assert.Contains(t, buf.String(), "projects/project-a/topics/orders-created")
+	assert.Contains(t, buf.String(), "roles/pubsub.subscriber")
+	assert.Contains(t, buf.String(), "Last synced:")
+}
+
+func TestDescribeCmd_Errors(t *testing.T) {
+	store := setupListStore(t)
+	ctx := context.Background()
+
+	err := (&DescribeCmd{Resource: "projects/project-a/topics/missing"}).describe(ctx, store, &bytes.Buffer{})
+	assert.ErrorIs(t, err, errResourceNotCached)
+
+	err = (&DescribeCmd{Resource: "orders-created"}).describe(ctx, store, &bytes.Buffer{})
+	assert.ErrorContains(t, err, "invalid resource name")
+
+	err = (&DescribeCmd{Resource: "projects/project-a/snapshots/s"}).describe(ctx, store, &bytes.Buffer{})
+	assert.ErrorContains(t, err, "only topics and subscriptions")
+}
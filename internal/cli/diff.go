@@ -0,0 +1,66 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/config"
+	"github.com/NissesSenap/gcp-visualizer/internal/graph"
+	"github.com/NissesSenap/gcp-visualizer/internal/renderer"
+)
+
+type DiffCmd struct {
+	Before string `arg:"" help:"JSON export of the earlier run (generate --format json)" type:"existingfile"`
+	After  string `arg:"" help:"JSON export of the later run" type:"existingfile"`
+	Output string `help:"Output HTML file" default:"diff.html"`
+	Theme  string `help:"Color theme: light, dark, colorblind or the path of a custom theme YAML file (default: visualization.theme of the config, or light)"`
+}
+
+func (c *DiffCmd) Run(cli *CLI) error {
+	if c.Theme == "" {
+		cfg, err := config.Load()
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+		c.Theme = cfg.Visualization.Theme
+	}
+	return c.diff(os.Stdout)
+}
+
+// diff writes the HTML diff explorer to the output file and a summary to w
+func (c *DiffCmd) diff(w io.Writer) error {
+	theme, err := renderer.LoadTheme(c.Theme)
+	if err != nil {
+		return err
+	}
+	before, err := renderer.ReadJSONFile(c.Before)
+	if err != nil {
+		return err
+	}
+	after, err := renderer.ReadJSONFile(c.After)
+	if err != nil {
+		return err
+	}
+
+	d := graph.Compare(before, after)
+	for _, status := range []graph.ChangeStatus{graph.StatusAdded, graph.StatusRemoved, graph.StatusChanged} {
+		nodes, edges := d.Count(status)
+		fmt.Fprintf(w, "%-8s %d nodes, %d edges\n", status+":", nodes, edges)
+	}
+
+	f, err := os.Create(c.Output)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", c.Output, err)
+	}
+	if err := renderer.WriteHTMLDiff(f, d, renderer.Options{Theme: theme}); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "Diff explorer saved to %s\n", c.Output)
+	return nil
+}
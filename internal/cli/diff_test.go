@@ -0,0 +1,42 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffCmd(t *testing.T) {
+	store := setupListStore(t)
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	before := filepath.Join(dir, "before.json")
+	require.NoError(t, (&GenerateCmd{Output: before, Format: "json"}).generate(ctx, store))
+
+	require.NoError(t, store.SaveSubscription(ctx, &storage.Subscription{
+		Name:                  "orders-audit",
+		ProjectID:             "project-c",
+		TopicFullResourceName: "projects/project-a/topics/orders-created",
+		FullResourceName:      "projects/project-c/subscriptions/orders-audit",
+	}))
+	after := filepath.Join(dir, "after.json")
+	require.NoError(t, (&GenerateCmd{Output: after, Format: "json"}).generate(ctx, store))
+
+	output := filepath.Join(dir, "diff.html")
+	var buf bytes.Buffer
+	require.NoError(t, (&DiffCmd{Before: before, After: after, Output: output}).diff(&buf))
+
+	assert.Contains(t, buf.String(), "added:   1 nodes, 1 edges")
+	assert.Contains(t, buf.String(), "removed: 0 nodes, 0 edges")
+
+	data, err := os.ReadFile(output)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "orders-audit")
+}
@@ -0,0 +1,146 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/auth"
+	"github.com/NissesSenap/gcp-visualizer/internal/bigquery"
+	"github.com/NissesSenap/gcp-visualizer/internal/collector"
+	"github.com/NissesSenap/gcp-visualizer/internal/config"
+	"github.com/NissesSenap/gcp-visualizer/internal/graph"
+	"github.com/NissesSenap/gcp-visualizer/internal/renderer"
+	"github.com/NissesSenap/gcp-visualizer/internal/storage"
+)
+
+type ExportCmd struct {
+	Output   string `help:"Write the export to this file instead of stdout, or for csv into this directory" placeholder:"PATH"`
+	Format   string `help:"Write a JSON dump of the cache tables that 'import' reads, Cypher statements loading the topology into Neo4j, topics.csv, subscriptions.csv and edges.csv, a Backstage catalog-info.yaml, or load the inventory into BigQuery" enum:"dump,cypher,csv,backstage,bigquery" default:"dump"`
+	Dataset  string `help:"BigQuery dataset loaded by --format bigquery, created if missing" placeholder:"PROJECT.DATASET"`
+	Mode     string `help:"Append the inventory to the BigQuery tables as a new snapshot, or replace their contents" enum:"append,truncate" default:"append"`
+	Location string `help:"Location of the BigQuery dataset when it is created, e.g. EU"`
+	Owner    string `help:"Owner of the Backstage entities in projects without a team" default:"unknown"`
+
+	ownership config.Ownership
+	bigQuery  bigquery.API
+	readOnly  bool // refuse exports that write outside the cache
+}
+
+type ImportCmd struct {
+	File string `arg:"" help:"Dump written by 'export'" type:"existingfile"`
+}
+
+func (c *ExportCmd) Run(cli *CLI) error {
+	store, err := openStore()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = store.Close() }()
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	c.ownership = cfg.Ownership
+	c.readOnly = cfg.ReadOnly
+	if c.Format == "bigquery" {
+		c.bigQuery, err = bigquery.NewAPI(cli.Context(), auth.Options{
+			CredentialsFile: cfg.Auth.CredentialsFile,
+			Scopes:          cfg.Auth.Scopes,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return c.export(cli.Context(), store, os.Stdout)
+}
+
+// export writes the whole cache in c.Format to c.Output, or to w
+func (c *ExportCmd) export(ctx context.Context, store storage.Store, w io.Writer) error {
+	if c.Format == "csv" {
+		if err := exportCSV(ctx, store, c.Output); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Cache exported to %s\n", c.Output)
+		return nil
+	}
+
+	if c.Format == "bigquery" {
+		if c.Dataset == "" {
+			return fmt.Errorf("--dataset PROJECT.DATASET is required for bigquery")
+		}
+		if c.readOnly {
+			if err := collector.CheckReadOnly(collector.ExportSpecs()); err != nil {
+				return fmt.Errorf("%w; set read_only: false (GCP_VISUALIZER_READ_ONLY=false) to export to BigQuery", err)
+			}
+		}
+		if err := c.exportBigQuery(ctx, store, c.bigQuery); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Cache exported to BigQuery dataset %s\n", c.Dataset)
+		return nil
+	}
+
+	write := store.Export
+	switch c.Format {
+	case "cypher":
+		g, err := graph.NewBuilder(store).Build(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("failed to build graph: %w", err)
+		}
+		write = func(ctx context.Context, w io.Writer) error { return renderer.WriteCypher(w, g) }
+	case "backstage":
+		g, err := graph.NewBuilder(store).Build(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("failed to build graph: %w", err)
+		}
+		// Entities are owned by the group of their project's team
+		if hasOwnership(c.ownership) {
+			ownership, err := newOwnership(ctx, store, c.ownership)
+			if err != nil {
+				return err
+			}
+			ownership.Apply(g)
+		}
+		write = func(ctx context.Context, w io.Writer) error { return renderer.WriteBackstage(w, g, c.Owner) }
+	}
+
+	if c.Output == "" {
+		return write(ctx, w)
+	}
+	if err := writeFileAtomic(c.Output, func(out io.Writer) error {
+		return write(ctx, out)
+	}); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Cache exported to %s\n", c.Output)
+	return nil
+}
+
+func (c *ImportCmd) Run(cli *CLI) error {
+	store, err := openStore()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = store.Close() }()
+
+	if err := c.importDump(cli.Context(), store); err != nil {
+		return err
+	}
+	fmt.Printf("Cache imported from %s\n", c.File)
+	return nil
+}
+
+// importDump replaces the cache contents with the dump in c.File
+func (c *ImportCmd) importDump(ctx context.Context, store storage.Store) error {
+	f, err := os.Open(c.File)
+	if err != nil {
+		return fmt.Errorf("failed to open dump: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	return store.Import(ctx, f)
+}
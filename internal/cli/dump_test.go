@@ -0,0 +1,152 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/bigquery"
+	"github.com/NissesSenap/gcp-visualizer/internal/config"
+	"github.com/NissesSenap/gcp-visualizer/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportImportCmd(t *testing.T) {
+	source := setupListStore(t)
+	ctx := context.Background()
+	dump := filepath.Join(t.TempDir(), "dump.json")
+
+	require.NoError(t, (&ExportCmd{Output: dump}).export(ctx, source, nil))
+
+	target, err := storage.NewSQLite(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = target.Close() })
+	require.NoError(t, (&ImportCmd{File: dump}).importDump(ctx, target))
+
+	subs, err := target.GetAllSubscriptions(ctx, nil)
+	require.NoError(t, err)
+	require.Len(t, subs, 1)
+	assert.Equal(t, "orders-email", subs[0].Name)
+
+	projects, err := target.GetAllProjects(ctx)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"project-a", "project-b"}, projects)
+}
+
+func TestExportCmd_Cypher(t *testing.T) {
+	store := setupListStore(t)
+	output := filepath.Join(t.TempDir(), "graph.cypher")
+
+	require.NoError(t, (&ExportCmd{Output: output, Format: "cypher"}).export(context.Background(), store, nil))
+
+	data, err := os.ReadFile(output)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `MERGE (p:Project {id: "project-b"});`)
+	assert.Contains(t, string(data), `MERGE (a)-[r:CROSS_PROJECT]->(b) SET r.label = "subscribes";`)
+}
+
+func TestExportCmd_Backstage(t *testing.T) {
+	store := setupListStore(t)
+	output := filepath.Join(t.TempDir(), "catalog-info.yaml")
+
+	cmd := &ExportCmd{
+		Output: output, Format: "backstage", Owner: "unknown",
+		ownership: config.Ownership{Teams: map[string][]string{"payments": {"project-b"}}},
+	}
+	require.NoError(t, cmd.export(context.Background(), store, nil))
+
+	data, err := os.ReadFile(output)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `  name: subscription-orders-email
+  namespace: project-b`)
+	assert.Contains(t, string(data), "owner: group:default/payments")
+	assert.Contains(t, string(data), "- resource:project-a/topic-orders-created")
+}
+
+// fakeBigQuery records the rows loaded into each table
+type fakeBigQuery struct {
+	rows     map[string][]map[string]any
+	truncate bool
+}
+
+func (f *fakeBigQuery) EnsureDataset(ctx context.Context, projectID, datasetID, location string) error {
+	return nil
+}
+
+func (f *fakeBigQuery) Load(ctx context.Context, table bigquery.Table, rows io.Reader, truncate bool) error {
+	if f.rows == nil {
+		f.rows = make(map[string][]map[string]any)
+	}
+	f.truncate = truncate
+	scanner := bufio.NewScanner(rows)
+	for scanner.Scan() {
+		var row map[string]any
+		if err := json.Unmarshal(scanner.Bytes(), &row); err != nil {
+			return err
+		}
+		f.rows[table.TableID] = append(f.rows[table.TableID], row)
+	}
+	return scanner.Err()
+}
+
+func (f *fakeBigQuery) ReadStrings(ctx context.Context, table bigquery.Table, column string) ([]string, error) {
+	var values []string
+	for _, row := range f.rows[table.TableID] {
+		values = append(values, row[column].(string))
+	}
+	return values, nil
+}
+
+func TestExportCmd_BigQuery(t *testing.T) {
+	store := setupListStore(t)
+	ctx := context.Background()
+	started := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	for _, runID := range []string{"run-1", "run-2"} {
+		require.NoError(t, store.SaveScanRun(ctx, &storage.ScanRun{RunID: runID, StartedAt: started, FinishedAt: started.Add(time.Minute)}))
+		started = started.Add(time.Hour)
+	}
+
+	api := &fakeBigQuery{}
+	cmd := &ExportCmd{
+		Format: "bigquery", Dataset: "analytics.pubsub", Mode: bigquery.ModeAppend, bigQuery: api,
+		ownership: config.Ownership{Teams: map[string][]string{"payments": {"project-b"}}},
+	}
+	require.NoError(t, cmd.export(ctx, store, nil))
+
+	require.Len(t, api.rows[bigquery.TableTopics], 2)
+	require.Len(t, api.rows[bigquery.TableSubscriptions], 1)
+	sub := api.rows[bigquery.TableSubscriptions][0]
+	assert.Equal(t, "project-a", sub["topic_project"])
+	assert.Equal(t, true, sub["cross_project"])
+	assert.Equal(t, "pull", sub["delivery"])
+	assert.Equal(t, "payments", sub["team"])
+	assert.NotEmpty(t, api.rows[bigquery.TableEdges])
+	require.Len(t, api.rows[bigquery.TableScanRuns], 2)
+
+	// Appending again adds only the scan runs recorded since
+	require.NoError(t, store.SaveScanRun(ctx, &storage.ScanRun{RunID: "run-3", StartedAt: started, FinishedAt: started.Add(time.Minute)}))
+	require.NoError(t, cmd.export(ctx, store, nil))
+	assert.Len(t, api.rows[bigquery.TableTopics], 4)
+	require.Len(t, api.rows[bigquery.TableScanRuns], 3)
+	assert.Equal(t, "run-3", api.rows[bigquery.TableScanRuns][2]["run_id"])
+
+	api = &fakeBigQuery{}
+	cmd.Mode, cmd.bigQuery = bigquery.ModeTruncate, api
+	require.NoError(t, cmd.export(ctx, store, nil))
+	assert.True(t, api.truncate)
+	assert.Len(t, api.rows[bigquery.TableScanRuns], 3)
+
+	assert.ErrorContains(t, (&ExportCmd{Format: "bigquery"}).export(ctx, store, nil), "--dataset")
+
+	// The read-only guard refuses to create datasets and load tables
+	api = &fakeBigQuery{}
+	cmd.bigQuery, cmd.readOnly = api, true
+	assert.ErrorContains(t, cmd.export(ctx, store, nil), "read-only mode")
+	assert.Empty(t, api.rows)
+}
@@ -0,0 +1,308 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/auth"
+	"github.com/NissesSenap/gcp-visualizer/internal/config"
+	"github.com/NissesSenap/gcp-visualizer/internal/graph"
+	"github.com/NissesSenap/gcp-visualizer/internal/monitoring"
+	"github.com/NissesSenap/gcp-visualizer/internal/storage"
+)
+
+// clearScreen moves the cursor home and clears the terminal
+const clearScreen = "\033[H\033[2J"
+
+// maxFollowChanges is how many recent topology changes the dashboard keeps
+const maxFollowChanges = 10
+
+type FollowCmd struct {
+	Topic    string        `arg:"" help:"Topic name or full resource name (projects/PROJECT/topics/TOPIC)"`
+	Projects []string      `help:"Projects to search when TOPIC is a short name"`
+	Interval time.Duration `help:"Refresh interval" default:"5s"`
+	Once     bool          `help:"Print a single snapshot and exit"`
+}
+
+func (c *FollowCmd) Run(cli *CLI) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	store, err := openStore()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = store.Close() }()
+
+	src, err := monitoring.NewClient(cli.Context(), auth.Options{
+		CredentialsFile: cfg.Auth.CredentialsFile,
+		Scopes:          cfg.Auth.Scopes,
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { _ = src.Close() }()
+
+	return c.follow(cli.Context(), store, src, os.Stdout)
+}
+
+// follow redraws the dashboard every interval until ctx is cancelled
+func (c *FollowCmd) follow(ctx context.Context, store storage.Store, src monitoring.Source, w io.Writer) error {
+	state := &followState{}
+	ticker := time.NewTicker(max(c.Interval, time.Second))
+	defer ticker.Stop()
+
+	for {
+		view, err := state.refresh(ctx, store, src, c.Topic, c.Projects, time.Now())
+		if err != nil {
+			return err
+		}
+		if !c.Once {
+			fmt.Fprint(w, clearScreen)
+		}
+		if err := view.render(w); err != nil {
+			return err
+		}
+		if c.Once {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// followView is one snapshot of the dashboard
+type followView struct {
+	Topic         string // full resource name
+	Updated       time.Time
+	PublishRate   monitoring.Gauge
+	Subscriptions []followSubscription
+	Changes       []string
+	Errors        []string
+}
+
+// followSubscription is a subscriber of the followed topic
+type followSubscription struct {
+	Name             string // full resource name
+	DeadLetterTopic  string
+	Downstream       []string // sinks and consumer identities
+	Backlog          monitoring.Gauge
+	OldestUnackedAge monitoring.Gauge
+	fingerprint      string
+}
+
+// followState remembers the previous snapshot, so subscriptions that are added,
+// removed or reconfigured while following (e.g. by 'listen') show up as changes
+type followState struct {
+	previous map[string]string // subscription name to fingerprint, nil before the first refresh
+	changes  []string
+}
+
+// refresh rebuilds the cached topology around the topic and reads its live metrics.
+// Metric errors, such as a missing monitoring.viewer role, are shown on the dashboard
+// instead of stopping it.
+func (s *followState) refresh(ctx context.Context, store storage.Store, src monitoring.Source, topic string, projects []string, now time.Time) (*followView, error) {
+	g, err := graph.NewBuilder(store).Build(ctx, projects)
+	if err != nil {
+		return nil, err
+	}
+	topicNode, err := findTopicNode(g, topic)
+	if err != nil {
+		return nil, err
+	}
+
+	view := &followView{
+		Topic:         topicNode.Metadata["full_resource_name"],
+		Updated:       now,
+		Subscriptions: followSubscriptions(g, topicNode.ID),
+	}
+
+	if m, err := src.TopicMetrics(ctx, view.Topic); err != nil {
+		view.Errors = append(view.Errors, err.Error())
+	} else {
+		view.PublishRate = m.PublishRate
+	}
+	for i := range view.Subscriptions {
+		sub := &view.Subscriptions[i]
+		m, err := src.SubscriptionMetrics(ctx, sub.Name)
+		if err != nil {
+			view.Errors = append(view.Errors, err.Error())
+			continue
+		}
+		sub.Backlog = m.Backlog
+		sub.OldestUnackedAge = m.OldestUnackedAge
+	}
+
+	s.recordChanges(view.Subscriptions, now)
+	view.Changes = s.changes
+	return view, nil
+}
+
+// recordChanges compares the subscriptions with the previous refresh
+func (s *followState) recordChanges(subs []followSubscription, now time.Time) {
+	current := make(map[string]string, len(subs))
+	for _, sub := range subs {
+		current[sub.Name] = sub.fingerprint
+	}
+	defer func() { s.previous = current }()
+	if s.previous == nil {
+		return
+	}
+
+	stamp := now.Format(time.TimeOnly)
+	for _, sub := range subs {
+		before, existed := s.previous[sub.Name]
+		switch {
+		case !existed:
+			s.addChange(fmt.Sprintf("%s  + %s", stamp, sub.Name))
+		case before != sub.fingerprint:
+			s.addChange(fmt.Sprintf("%s  ~ %s", stamp, sub.Name))
+		}
+	}
+	var removed []string
+	for name := range s.previous {
+		if _, ok := current[name]; !ok {
+			removed = append(removed, name)
+		}
+	}
+	sort.Strings(removed)
+	for _, name := range removed {
+		s.addChange(fmt.Sprintf("%s  - %s", stamp, name))
+	}
+}
+
+func (s *followState) addChange(change string) {
+	s.changes = append(s.changes, change)
+	if len(s.changes) > maxFollowChanges {
+		s.changes = s.changes[len(s.changes)-maxFollowChanges:]
+	}
+}
+
+// findTopicNode finds a topic by full resource name, or by short name if it's unique
+func findTopicNode(g *graph.Graph, topic string) (*graph.Node, error) {
+	var matches []*graph.Node
+	for _, node := range g.Nodes {
+		if node.Type != graph.NodeTypeTopic {
+			continue
+		}
+		if node.Metadata["full_resource_name"] == topic {
+			return node, nil
+		}
+		if node.Label == topic {
+			matches = append(matches, node)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("topic %s not found in cache, run 'gcp-visualizer scan' first", topic)
+	case 1:
+		return matches[0], nil
+	}
+	names := make([]string, 0, len(matches))
+	for _, node := range matches {
+		names = append(names, node.Metadata["full_resource_name"])
+	}
+	sort.Strings(names)
+	return nil, fmt.Errorf("topic %s is ambiguous, use one of: %s", topic, strings.Join(names, ", "))
+}
+
+// followSubscriptions returns the subscriptions of a topic with their downstream
+// sinks and consumers, sorted by name
+func followSubscriptions(g *graph.Graph, topicNodeID string) []followSubscription {
+	var subs []followSubscription
+	for _, edge := range g.Edges {
+		if edge.To != topicNodeID || (edge.Type != graph.EdgeTypeSubscribes && edge.Type != graph.EdgeTypeCrossProject) {
+			continue
+		}
+		node := g.Nodes[edge.From]
+
+		var downstream []string
+		for _, e := range g.Edges {
+			switch {
+			case e.Type == graph.EdgeTypeDelivers && e.From == node.ID:
+				downstream = append(downstream, g.Nodes[e.To].Label)
+			case e.Type == graph.EdgeTypeConsumes && e.To == node.ID:
+				downstream = append(downstream, g.Nodes[e.From].Label)
+			}
+		}
+		sort.Strings(downstream)
+
+		keys := make([]string, 0, len(node.Metadata))
+		for k := range node.Metadata {
+			keys = append(keys, k+"="+node.Metadata[k])
+		}
+		sort.Strings(keys)
+
+		subs = append(subs, followSubscription{
+			Name:            node.Metadata["full_resource_name"],
+			DeadLetterTopic: node.Metadata[graph.DeadLetterTopicKey],
+			Downstream:      downstream,
+			fingerprint:     strings.Join(keys, ",") + "|" + strings.Join(downstream, ","),
+		})
+	}
+	sort.Slice(subs, func(i, j int) bool { return subs[i].Name < subs[j].Name })
+	return subs
+}
+
+// render writes the dashboard as plain text
+func (v *followView) render(w io.Writer) error {
+	fmt.Fprintf(w, "Topic:        %s\n", v.Topic)
+	fmt.Fprintf(w, "Publish rate: %s\n", formatGauge(v.PublishRate, "%.1f msg/s"))
+	fmt.Fprintf(w, "Updated:      %s\n\n", v.Updated.Format(time.TimeOnly))
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SUBSCRIPTION\tBACKLOG\tOLDEST UNACKED\tDEAD LETTER\tDOWNSTREAM")
+	for _, sub := range v.Subscriptions {
+		dlq := sub.DeadLetterTopic
+		if dlq == "" {
+			dlq = "-"
+		}
+		downstream := strings.Join(sub.Downstream, ", ")
+		if downstream == "" {
+			downstream = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", sub.Name,
+			formatGauge(sub.Backlog, "%.0f"), formatGauge(sub.OldestUnackedAge, "%.0fs"), dlq, downstream)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if len(v.Subscriptions) == 0 {
+		fmt.Fprintln(w, "(no subscriptions)")
+	}
+
+	if len(v.Changes) > 0 {
+		fmt.Fprintln(w, "\nRecent changes:")
+		for _, change := range v.Changes {
+			fmt.Fprintf(w, "  %s\n", change)
+		}
+	}
+	if len(v.Errors) > 0 {
+		fmt.Fprintln(w, "\nErrors:")
+		for _, e := range v.Errors {
+			fmt.Fprintf(w, "  %s\n", e)
+		}
+	}
+	return nil
+}
+
+// formatGauge formats a metric value, or "-" when there's no recent data
+func formatGauge(g monitoring.Gauge, format string) string {
+	if !g.Valid {
+		return "-"
+	}
+	return fmt.Sprintf(format, g.Value)
+}
@@ -0,0 +1,86 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/monitoring"
+	"github.com/NissesSenap/gcp-visualizer/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeMetricSource struct {
+	backlog map[string]float64
+	err     error
+}
+
+func (f *fakeMetricSource) TopicMetrics(ctx context.Context, topic string) (*monitoring.TopicMetrics, error) {
+	return &monitoring.TopicMetrics{PublishRate: monitoring.Gauge{Value: 12.5, Valid: true}}, nil
+}
+
+func (f *fakeMetricSource) SubscriptionMetrics(ctx context.Context, subscription string) (*monitoring.SubscriptionMetrics, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	backlog, ok := f.backlog[subscription]
+	return &monitoring.SubscriptionMetrics{Backlog: monitoring.Gauge{Value: backlog, Valid: ok}}, nil
+}
+
+func (f *fakeMetricSource) Close() error { return nil }
+
+func TestFollowCmd_Once(t *testing.T) {
+	store := setupListStore(t)
+	src := &fakeMetricSource{backlog: map[string]float64{"projects/project-b/subscriptions/orders-email": 42}}
+
+	var buf bytes.Buffer
+	cmd := &FollowCmd{Topic: "orders-created", Once: true}
+	require.NoError(t, cmd.follow(context.Background(), store, src, &buf))
+
+	out := buf.String()
+	assert.Contains(t, out, "projects/project-a/topics/orders-created")
+	assert.Contains(t, out, "12.5 msg/s")
+	assert.Contains(t, out, "projects/project-b/subscriptions/orders-email")
+	assert.Contains(t, out, "42")
+	assert.NotContains(t, out, clearScreen)
+}
+
+func TestFollowCmd_UnknownTopic(t *testing.T) {
+	store := setupListStore(t)
+
+	cmd := &FollowCmd{Topic: "missing", Once: true}
+	err := cmd.follow(context.Background(), store, &fakeMetricSource{}, &bytes.Buffer{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "not found in cache")
+}
+
+func TestFollowState_Changes(t *testing.T) {
+	store := setupListStore(t)
+	ctx := context.Background()
+	src := &fakeMetricSource{err: errors.New("permission denied")}
+	state := &followState{}
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	view, err := state.refresh(ctx, store, src, "projects/project-a/topics/orders-created", nil, now)
+	require.NoError(t, err)
+	assert.Empty(t, view.Changes, "first refresh has nothing to compare with")
+	assert.Equal(t, []string{"permission denied"}, view.Errors, "metric errors are shown, not returned")
+
+	require.NoError(t, store.SaveSubscription(ctx, &storage.Subscription{
+		Name:                  "orders-audit",
+		ProjectID:             "project-a",
+		TopicFullResourceName: "projects/project-a/topics/orders-created",
+		FullResourceName:      "projects/project-a/subscriptions/orders-audit",
+	}))
+	require.NoError(t, store.DeleteSubscription(ctx, "projects/project-b/subscriptions/orders-email"))
+
+	view, err = state.refresh(ctx, store, src, "projects/project-a/topics/orders-created", nil, now.Add(5*time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"12:00:05  + projects/project-a/subscriptions/orders-audit",
+		"12:00:05  - projects/project-b/subscriptions/orders-email",
+	}, view.Changes)
+}
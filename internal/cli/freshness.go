@@ -0,0 +1,86 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/config"
+	"github.com/NissesSenap/gcp-visualizer/internal/metrics"
+	"github.com/NissesSenap/gcp-visualizer/internal/renderer"
+	"github.com/NissesSenap/gcp-visualizer/internal/storage"
+)
+
+// freshnessSymbols draw one day of the text heatmap
+var freshnessSymbols = map[metrics.FreshnessLevel]string{
+	metrics.FreshnessFresh:   "#",
+	metrics.FreshnessStale:   "+",
+	metrics.FreshnessExpired: "!",
+	metrics.FreshnessNever:   ".",
+}
+
+type FreshnessCmd struct {
+	Projects []string `help:"Filter by projects"`
+	Days     int      `help:"Number of days to show, including today" default:"14"`
+	Output   string   `help:"Write an HTML heatmap to this file instead of printing a table"`
+}
+
+func (c *FreshnessCmd) Run(cli *CLI) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	store, err := openStore()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = store.Close() }()
+
+	thresholds := metrics.Thresholds{
+		TTL:    time.Duration(cfg.Cache.TTLHours) * time.Hour,
+		MaxAge: time.Duration(cfg.Cache.MaxAgeHours) * time.Hour,
+	}
+	return c.freshness(cli.Context(), store, os.Stdout, thresholds, time.Now())
+}
+
+// freshness writes the heatmap to c.Output, or as a table to w
+func (c *FreshnessCmd) freshness(ctx context.Context, store storage.Store, w io.Writer, thresholds metrics.Thresholds, now time.Time) error {
+	f, err := metrics.CollectFreshness(ctx, store, c.Projects, c.Days, thresholds, now)
+	if err != nil {
+		return err
+	}
+
+	if c.Output != "" {
+		if err := writeFileAtomic(c.Output, func(out io.Writer) error {
+			return renderer.WriteFreshnessHTML(out, f)
+		}); err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "Freshness heatmap saved to %s\n", c.Output)
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "PROJECT\tSTATUS\tLAST SYNCED\tLAST %d DAYS\n", len(f.Days))
+	for _, p := range f.Projects {
+		lastSynced := "never"
+		if !p.LastSynced.IsZero() {
+			lastSynced = now.Sub(p.LastSynced).Truncate(time.Second).String() + " ago"
+		}
+		var days strings.Builder
+		for _, level := range p.Days {
+			days.WriteString(freshnessSymbols[level])
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", p.Project, p.Level, lastSynced, days.String())
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "\n# within %s, + within %s, ! older, . never synced\n", thresholds.TTL, thresholds.MaxAge)
+	return nil
+}
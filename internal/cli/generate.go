@@ -0,0 +1,592 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/config"
+	"github.com/NissesSenap/gcp-visualizer/internal/demo"
+	"github.com/NissesSenap/gcp-visualizer/internal/graph"
+	"github.com/NissesSenap/gcp-visualizer/internal/query"
+	"github.com/NissesSenap/gcp-visualizer/internal/renderer"
+	"github.com/NissesSenap/gcp-visualizer/internal/storage"
+	"github.com/NissesSenap/gcp-visualizer/internal/terraform"
+)
+
+func (c *GenerateCmd) Run(cli *CLI) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if c.View != "" {
+		if err := c.applyView(cfg.Views); err != nil {
+			return err
+		}
+	}
+	if c.Theme == "" {
+		c.Theme = cfg.Visualization.Theme
+	}
+	c.styles = newStyleRules(cfg.Visualization.Styles)
+	c.collapsePatterns = cfg.Visualization.CollapsePatterns
+	c.maxNodes = cfg.Visualization.MaxNodes
+	c.ownership = cfg.Ownership
+	c.classification = cfg.Classification
+	c.backlogThresholds = backlogThresholds(cfg.Metrics)
+	if len(c.TerraformState) == 0 {
+		c.TerraformState = cfg.Terraform.StateFiles
+	}
+
+	if c.Demo {
+		return c.generateDemo(cli.Context())
+	}
+
+	store, err := openStore()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = store.Close() }()
+
+	return c.generate(cli.Context(), store)
+}
+
+// applyView copies the settings of the saved view c.View into the flags that
+// are still unset or at their default
+func (c *GenerateCmd) applyView(views map[string]config.View) error {
+	view, ok := views[c.View]
+	if !ok {
+		names := make([]string, 0, len(views))
+		for name := range views {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		if len(names) == 0 {
+			return fmt.Errorf("view %s not found, no views are configured", c.View)
+		}
+		return fmt.Errorf("view %s not found, the configured views are: %s", c.View, strings.Join(names, ", "))
+	}
+
+	if len(c.Projects) == 0 {
+		c.Projects = view.Projects
+	}
+	if len(c.Focus) == 0 {
+		c.Focus = view.Focus
+	}
+	if len(c.TopicFilter) == 0 {
+		c.TopicFilter = view.TopicFilter
+	}
+	if len(c.SubscriptionFilter) == 0 {
+		c.SubscriptionFilter = view.SubscriptionFilter
+	}
+	if c.Where == "" {
+		c.Where = view.Where
+	}
+	if c.Output == "" {
+		c.Output = view.Output
+	}
+	if c.Theme == "" {
+		c.Theme = view.Theme
+	}
+	// Kong defaults, see GenerateCmd
+	if view.Format != "" && c.Format == "svg" {
+		c.Format = view.Format
+	}
+	if view.Layout != "" && c.Layout == "fdp" {
+		c.Layout = view.Layout
+	}
+	if view.Depth != 0 && c.Depth == 2 {
+		c.Depth = view.Depth
+	}
+	if view.ColorBy != "" && c.ColorBy == "type" {
+		c.ColorBy = view.ColorBy
+	}
+	if view.Level != "" && c.Level == "resource" {
+		c.Level = view.Level
+	}
+	if view.GroupBy != "" && c.GroupBy == "project" {
+		c.GroupBy = view.GroupBy
+	}
+	if view.ShowInferred {
+		c.ShowInferred = true
+	}
+	if view.TrafficWidth {
+		c.TrafficWidth = true
+	}
+	return nil
+}
+
+// generateDemo renders the demo inventory, leaving the cache untouched
+func (c *GenerateCmd) generateDemo(ctx context.Context) error {
+	store, err := openDemoStore(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = store.Close() }()
+
+	return c.generate(ctx, store)
+}
+
+// openDemoStore opens an in-memory store holding the demo inventory
+func openDemoStore(ctx context.Context) (storage.Store, error) {
+	store, err := storage.NewSQLite(":memory:")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	if err := demo.Load(ctx, store); err != nil {
+		_ = store.Close()
+		return nil, err
+	}
+	return store, nil
+}
+
+// generate builds the graph from store and renders it to the output file
+func (c *GenerateCmd) generate(ctx context.Context, store storage.Store) error {
+	output := c.Output
+	if output == "" {
+		output = "output." + c.Format
+	}
+
+	if c.GroupBy == "team" && !hasOwnership(c.ownership) {
+		return fmt.Errorf("--group-by team needs the teams owning projects, set ownership.label_key or ownership.file in the config")
+	}
+
+	theme, err := renderer.LoadTheme(c.Theme)
+	if err != nil {
+		return err
+	}
+	// The legend describes the graph, so it's filled in once the graph is built
+	opts := renderer.Options{Theme: theme}
+	if c.Legend {
+		opts.Legend = &renderer.Legend{}
+	}
+	r, err := newRenderer(c.Format, c.Layout, c.Renderer, opts)
+	if err != nil {
+		return err
+	}
+
+	if len(c.Projects) > 0 {
+		fmt.Printf("Filtering by projects: %v\n", c.Projects)
+	}
+
+	g, err := c.build(ctx, store)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Graph contains %d nodes and %d edges\n", len(g.Nodes), len(g.Edges))
+
+	disabled, err := disabledProjects(ctx, store, c.Projects)
+	if err != nil {
+		return err
+	}
+	if len(disabled) > 0 {
+		fmt.Printf("Projects with the Pub/Sub API disabled: %s\n", strings.Join(disabled, ", "))
+	}
+
+	if opts.Legend != nil {
+		if err := c.fillLegend(ctx, store, g, opts.Legend); err != nil {
+			return err
+		}
+	}
+
+	if c.Level == "project" {
+		g = graph.SummarizeProjects(g)
+	}
+	// Diagrams only, exports keep every resource
+	if c.Level != "project" && c.maxNodes > 0 && len(g.Nodes) > c.maxNodes && !c.NoSummarize && c.Format != "json" && c.Format != "openlineage" {
+		fmt.Fprintf(os.Stderr, "Warning: the graph has %d nodes, more than visualization.max_nodes (%d), drawing a summary of its projects instead; "+
+			"narrow it down with --projects, --focus or --where, or pass --no-summarize\n", len(g.Nodes), c.maxNodes)
+		g = graph.SummarizeProjects(g)
+	}
+	switch c.GroupBy {
+	case "team":
+		graph.GroupByTeam(g)
+	case "region":
+		graph.GroupByRegion(g)
+	}
+
+	if err := r.Render(ctx, g, output, c.Format); err != nil {
+		return fmt.Errorf("failed to render graph: %w", err)
+	}
+
+	fmt.Printf("Visualization saved to %s\n", output)
+	return nil
+}
+
+// fillLegend sets the scan time and project count of the projects drawn in g, and the filters of c, in legend
+func (c *GenerateCmd) fillLegend(ctx context.Context, store storage.Store, g *graph.Graph, legend *renderer.Legend) error {
+	syncTimes, err := store.GetProjectSyncTimes(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get project sync times: %w", err)
+	}
+	for projectID := range g.Clusters {
+		if synced := syncTimes[projectID]; synced.After(legend.ScannedAt) {
+			legend.ScannedAt = synced
+		}
+	}
+	legend.Projects = len(g.Clusters)
+	legend.Filters = c.filterSummary()
+	return nil
+}
+
+// hasOwnership reports whether cfg assigns projects to teams
+func hasOwnership(cfg config.Ownership) bool {
+	return cfg.LabelKey != "" || cfg.File != "" || len(cfg.Teams) > 0
+}
+
+// newOwnership returns the ownership of cfg, with the project labels collected into store
+func newOwnership(ctx context.Context, store storage.Store, cfg config.Ownership) (*graph.Ownership, error) {
+	teams, err := cfg.LoadTeams()
+	if err != nil {
+		return nil, err
+	}
+	ownership := &graph.Ownership{Teams: teams, LabelKey: cfg.LabelKey}
+	if cfg.LabelKey != "" {
+		ownership.Labels, err = store.GetProjectLabels(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get project labels: %w", err)
+		}
+	}
+	return ownership, nil
+}
+
+// newStyleRules converts the style rules of the config for the graph
+func newStyleRules(rules []config.StyleRule) []graph.StyleRule {
+	styles := make([]graph.StyleRule, 0, len(rules))
+	for _, rule := range rules {
+		styles = append(styles, graph.StyleRule{
+			Type:   graph.NodeType(rule.Type),
+			Name:   rule.Name,
+			Labels: rule.Labels,
+			Color:  rule.Color,
+			Border: rule.Border,
+			Shape:  rule.Shape,
+		})
+	}
+	return styles
+}
+
+// filterSummary describes the flags of c that leave resources out of the graph, one line each
+func (c *GenerateCmd) filterSummary() []string {
+	var filters []string
+	if c.View != "" {
+		filters = append(filters, "view: "+c.View)
+	}
+	if len(c.Projects) > 0 {
+		filters = append(filters, "projects: "+strings.Join(c.Projects, ", "))
+	}
+	if c.Where != "" {
+		filters = append(filters, "where: "+c.Where)
+	}
+	if len(c.Focus) > 0 {
+		filters = append(filters, fmt.Sprintf("focus: %s (depth %d)", strings.Join(c.Focus, ", "), c.Depth))
+	}
+	if len(c.TopicFilter) > 0 {
+		filters = append(filters, "topics: "+strings.Join(c.TopicFilter, ", "))
+	}
+	if len(c.SubscriptionFilter) > 0 {
+		filters = append(filters, "subscriptions: "+strings.Join(c.SubscriptionFilter, ", "))
+	}
+	return filters
+}
+
+// build creates the graph from store with the projects, focus and filter of c applied
+func (c *GenerateCmd) build(ctx context.Context, store storage.Store) (*graph.Graph, error) {
+	var where *query.Expr
+	if c.Where != "" {
+		expr, err := query.Parse(c.Where)
+		if err != nil {
+			return nil, err
+		}
+		where = expr
+	}
+
+	g, err := graph.NewBuilder(store).Build(ctx, c.Projects)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build graph: %w", err)
+	}
+	if len(g.Nodes) == 0 {
+		return nil, fmt.Errorf("no resources found in cache, run 'scan' first")
+	}
+	if !c.ShowInferred {
+		g = graph.WithoutInferred(g)
+	}
+	var diff *graph.Diff
+	if c.DiffAgainst != "" {
+		before, err := c.diffBaseline(ctx, store)
+		if err != nil {
+			return nil, err
+		}
+		diff = graph.Compare(before, g)
+		g = diff.Graph
+	}
+	// Before the filters, so --where can select teams
+	if hasOwnership(c.ownership) {
+		ownership, err := newOwnership(ctx, store, c.ownership)
+		if err != nil {
+			return nil, err
+		}
+		ownership.Apply(g)
+	}
+	// Before the overlays, which take precedence
+	if err := graph.ApplyStyles(g, c.styles); err != nil {
+		return nil, err
+	}
+
+	if c.ColorBy == "classification" {
+		classifier := newClassifier(c.classification)
+		classifier.Apply(g)
+		classifier.Colorize(g)
+	}
+	if c.ColorBy == "traffic" {
+		graph.ColorByTraffic(g)
+	}
+	if c.TrafficWidth {
+		graph.ScaleByTraffic(g)
+	}
+	if c.HighlightOrphans {
+		orphans, err := graph.FindOrphans(ctx, store, c.Projects)
+		if err != nil {
+			return nil, err
+		}
+		graph.MarkOrphans(g, orphans)
+	}
+	// Before the backlogs, whose red outline takes precedence
+	if len(c.TerraformState) > 0 {
+		managed, err := terraform.Load(c.TerraformState)
+		if err != nil {
+			return nil, err
+		}
+		unmanaged, err := graph.FindUnmanaged(ctx, store, c.Projects, managed)
+		if err != nil {
+			return nil, err
+		}
+		graph.MarkTerraform(g, managed, unmanaged)
+	}
+	if c.HighlightBacklogs {
+		backlogs, err := graph.FindBacklogs(ctx, store, c.Projects, c.backlogThresholds)
+		if err != nil {
+			return nil, err
+		}
+		graph.MarkBacklogs(g, backlogs)
+	}
+	// Last of the overlays, a before/after review is about what changed
+	if diff != nil {
+		graph.MarkDiff(diff)
+	}
+
+	// Filter after classification so levels still propagate through excluded nodes
+	if len(c.Focus) > 0 {
+		if c.Depth < 0 {
+			return nil, fmt.Errorf("--depth must not be negative")
+		}
+		seeds := make([]string, 0, len(c.Focus))
+		for _, resource := range c.Focus {
+			node, err := findFocusNode(g, resource)
+			if err != nil {
+				return nil, err
+			}
+			seeds = append(seeds, node.ID)
+		}
+		// The default of two hops from a topic reaches its subscriptions and their sinks
+		g = graph.Neighborhood(g, seeds, c.Depth)
+	}
+	if len(c.TopicFilter) > 0 || len(c.SubscriptionFilter) > 0 {
+		var seeds []string
+		for _, f := range []struct {
+			nodeType graph.NodeType
+			patterns []string
+		}{
+			{graph.NodeTypeTopic, c.TopicFilter},
+			{graph.NodeTypeSubscription, c.SubscriptionFilter},
+		} {
+			matched, err := matchNodeNames(g, f.nodeType, f.patterns)
+			if err != nil {
+				return nil, err
+			}
+			seeds = append(seeds, matched...)
+		}
+		if len(seeds) == 0 {
+			return nil, fmt.Errorf("no topics or subscriptions match the name filters")
+		}
+		// Matching resources and what they are directly connected to
+		g = graph.Neighborhood(g, seeds, 1)
+	}
+	if where != nil {
+		g = query.Filter(g, where)
+		if len(g.Nodes) == 0 {
+			return nil, fmt.Errorf("no resources match %q", where)
+		}
+	}
+
+	if c.RetryLabels {
+		graph.AnnotateRetryPolicies(g)
+	}
+	if c.EdgeLabels {
+		graph.AnnotateSubscriptionAttributes(g)
+	}
+	if c.FilterLabels {
+		graph.AnnotateSubscriptionFilters(g)
+	}
+
+	// Last, filters and annotations need the metadata of the single resources
+	if len(c.collapsePatterns) > 0 {
+		g, err = graph.Collapse(g, c.collapsePatterns)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return g, nil
+}
+
+// diffBaseline returns the graph --diff-against compares with: a JSON export of an earlier run, or the
+// cached topics and subscriptions rewound to a date through the changelog
+func (c *GenerateCmd) diffBaseline(ctx context.Context, store storage.Store) (*graph.Graph, error) {
+	since, err := time.Parse(time.RFC3339, c.DiffAgainst)
+	if err != nil {
+		since, err = time.ParseInLocation(time.DateOnly, c.DiffAgainst, time.Local)
+	}
+	if err != nil {
+		if _, statErr := os.Stat(c.DiffAgainst); statErr != nil {
+			return nil, fmt.Errorf("--diff-against %s is neither a JSON export nor a date like 2024-05-01", c.DiffAgainst)
+		}
+		before, err := renderer.ReadJSONFile(c.DiffAgainst)
+		if err != nil {
+			return nil, err
+		}
+		if !c.ShowInferred {
+			before = graph.WithoutInferred(before)
+		}
+		return before, nil
+	}
+
+	changes, err := store.GetChanges(ctx, since, c.Projects)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get changes: %w", err)
+	}
+	current, err := graph.NewBuilder(store).Build(ctx, c.Projects)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build graph: %w", err)
+	}
+	if !c.ShowInferred {
+		current = graph.WithoutInferred(current)
+	}
+	return graph.Rewind(current, changes), nil
+}
+
+// findFocusNode looks up a resource by full resource name, or a topic or subscription
+// by name. Topics are tried first, so a bare name shared with a subscription is the topic.
+func findFocusNode(g *graph.Graph, resource string) (*graph.Node, error) {
+	var topics, subs []*graph.Node
+	for _, node := range g.Nodes {
+		if node.Metadata["full_resource_name"] == resource {
+			return node, nil
+		}
+		if node.Label != resource {
+			continue
+		}
+		switch node.Type {
+		case graph.NodeTypeTopic:
+			topics = append(topics, node)
+		case graph.NodeTypeSubscription:
+			subs = append(subs, node)
+		}
+	}
+
+	matches := topics
+	if len(matches) == 0 {
+		matches = subs
+	}
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("resource %s not found in cache, run 'gcp-visualizer scan' first", resource)
+	case 1:
+		return matches[0], nil
+	}
+	names := make([]string, 0, len(matches))
+	for _, node := range matches {
+		names = append(names, node.Metadata["full_resource_name"])
+	}
+	sort.Strings(names)
+	return nil, fmt.Errorf("%s is ambiguous, use one of: %s", resource, strings.Join(names, ", "))
+}
+
+// matchNodeNames returns the IDs of the nodes of nodeType whose name matches any of
+// the glob patterns, in path.Match syntax
+func matchNodeNames(g *graph.Graph, nodeType graph.NodeType, patterns []string) ([]string, error) {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid name pattern %q: %w", pattern, err)
+		}
+	}
+
+	var ids []string
+	for id, node := range g.Nodes {
+		if node.Type != nodeType {
+			continue
+		}
+		for _, pattern := range patterns {
+			if ok, _ := path.Match(pattern, node.Label); ok {
+				ids = append(ids, id)
+				break
+			}
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// newRenderer returns the renderer for an output format, drawing with opts. Diagrams are drawn by engine:
+// "graphviz", the pure-Go layout for "embedded", or for "auto" Graphviz if it's installed and the pure-Go
+// layout otherwise. A missing Graphviz binary is reported before anything is built.
+func newRenderer(format, layout, engine string, opts renderer.Options) (renderer.Renderer, error) {
+	switch format {
+	case "html":
+		return renderer.NewHTMLRenderer(opts), nil
+	case "json":
+		return renderer.NewJSONRenderer(), nil
+	case "openlineage":
+		return renderer.NewOpenLineageRenderer(), nil
+	}
+
+	switch engine {
+	case "graphviz":
+		if err := renderer.CheckGraphviz(); err != nil {
+			return nil, fmt.Errorf("%w: install Graphviz from https://graphviz.org/download/ or pass --renderer embedded", err)
+		}
+		return renderer.NewGraphvizRenderer(layout, opts), nil
+	case "embedded":
+		return renderer.NewEmbeddedRenderer(format, opts)
+	}
+	if err := renderer.CheckGraphviz(); err != nil {
+		if _, embeddedErr := renderer.NewEmbeddedRenderer(format, opts); embeddedErr != nil {
+			return nil, fmt.Errorf("%w, which %s output needs: install Graphviz from https://graphviz.org/download/ or use --format svg or png", err, format)
+		}
+	}
+	return renderer.NewFallbackRenderer(renderer.NewGraphvizRenderer(layout, opts), os.Stderr), nil
+}
+
+// disabledProjects returns the projects, out of projects or all if empty, that
+// had the Pub/Sub API disabled at their last scan, sorted
+func disabledProjects(ctx context.Context, store storage.Store, projects []string) ([]string, error) {
+	statuses, err := store.GetProjectStatuses(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get project statuses: %w", err)
+	}
+	wanted := make(map[string]bool, len(projects))
+	for _, p := range projects {
+		wanted[p] = true
+	}
+	var disabled []string
+	for project, status := range statuses {
+		if status == storage.ProjectStatusAPIDisabled && (len(wanted) == 0 || wanted[project]) {
+			disabled = append(disabled, project)
+		}
+	}
+	sort.Strings(disabled)
+	return disabled, nil
+}
@@ -0,0 +1,427 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/config"
+	"github.com/NissesSenap/gcp-visualizer/internal/graph"
+	"github.com/NissesSenap/gcp-visualizer/internal/renderer"
+	"github.com/NissesSenap/gcp-visualizer/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateCmd_HTML(t *testing.T) {
+	store := setupListStore(t)
+	output := filepath.Join(t.TempDir(), "graph.html")
+
+	cmd := &GenerateCmd{Output: output, Format: "html", Layout: "fdp"}
+	require.NoError(t, cmd.generate(context.Background(), store))
+
+	data, err := os.ReadFile(output)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "<html")
+	assert.Contains(t, string(data), "orders-created")
+}
+
+func TestGenerateCmd_Legend(t *testing.T) {
+	store := setupListStore(t)
+	require.NoError(t, store.UpdateProjectSyncTime(context.Background(), "project-a"))
+	output := filepath.Join(t.TempDir(), "graph.svg")
+
+	cmd := &GenerateCmd{Output: output, Format: "svg", Renderer: "embedded", Projects: []string{"project-a", "project-b"}, Legend: true}
+	require.NoError(t, cmd.generate(context.Background(), store))
+
+	data, err := os.ReadFile(output)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `<g class="legend">`)
+	assert.Contains(t, string(data), ">2 projects</text>")
+	assert.Contains(t, string(data), ">projects: project-a, project-b</text>")
+	assert.Contains(t, string(data), ">Scanned ")
+}
+
+func TestGenerateCmd_Styles(t *testing.T) {
+	store := setupListStore(t)
+	output := filepath.Join(t.TempDir(), "graph.svg")
+
+	cmd := &GenerateCmd{Output: output, Format: "svg", Renderer: "embedded", HighlightOrphans: true}
+	cmd.styles = newStyleRules([]config.StyleRule{
+		{Type: "topic", Name: "orders-*", Color: "#00aa00", Shape: "diamond"},
+		{Name: "users", Color: "#0000aa"},
+	})
+	require.NoError(t, cmd.generate(context.Background(), store))
+
+	data, err := os.ReadFile(output)
+	require.NoError(t, err)
+	assert.Regexp(t, `<polygon points="[^"]+" fill="#00aa00"`, string(data))
+	// The orphan overlay takes precedence over the style rules
+	assert.NotContains(t, string(data), "#0000aa")
+}
+
+func TestGenerateCmd_Classification(t *testing.T) {
+	store := setupListStore(t)
+
+	cmd := &GenerateCmd{ColorBy: "classification", Depth: 2}
+	cmd.classification = config.DefaultConfig().Classification
+	cmd.classification.Topics = map[string]string{
+		"projects/project-a/topics/orders-created": "restricted",
+	}
+	g, err := cmd.build(context.Background(), store)
+	require.NoError(t, err)
+
+	node, err := findFocusNode(g, "orders-created")
+	require.NoError(t, err)
+	assert.Equal(t, "restricted", node.Metadata[graph.ClassificationKey])
+	assert.NotEmpty(t, node.Color)
+}
+
+func TestGenerateCmd_HighlightBacklogs(t *testing.T) {
+	store := setupListStore(t)
+	ctx := context.Background()
+	name := "projects/project-b/subscriptions/orders-email"
+	require.NoError(t, store.SaveMetrics(ctx, []*storage.ResourceMetric{
+		{FullResourceName: name, ProjectID: "project-b", Metric: storage.MetricSubscriptionBacklog, Value: 40000, Window: time.Hour},
+	}))
+
+	cmd := &GenerateCmd{HighlightBacklogs: true, Depth: 2}
+	cmd.backlogThresholds = graph.BacklogThresholds{MaxMessages: 10000, MaxUnackedAge: time.Hour}
+	g, err := cmd.build(ctx, store)
+	require.NoError(t, err)
+
+	node, err := findFocusNode(g, "orders-email")
+	require.NoError(t, err)
+	assert.Equal(t, "backlog too large", node.Metadata[graph.BacklogAlertKey])
+}
+
+func TestGenerateCmd_MaxNodes(t *testing.T) {
+	store := setupListStore(t)
+	output := filepath.Join(t.TempDir(), "graph.html")
+
+	cmd := &GenerateCmd{Output: output, Format: "html", maxNodes: 2}
+	require.NoError(t, cmd.generate(context.Background(), store))
+	data, err := os.ReadFile(output)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"id":"project_project-a"`)
+	assert.NotContains(t, string(data), "orders-email")
+
+	cmd.NoSummarize = true
+	require.NoError(t, cmd.generate(context.Background(), store))
+	data, err = os.ReadFile(output)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "orders-email")
+}
+
+func TestGenerateCmd_LevelProject(t *testing.T) {
+	store := setupListStore(t)
+	output := filepath.Join(t.TempDir(), "graph.json")
+
+	cmd := &GenerateCmd{Output: output, Format: "json", Level: "project"}
+	require.NoError(t, cmd.generate(context.Background(), store))
+
+	var doc renderer.JSONDocument
+	data, err := os.ReadFile(output)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &doc))
+	require.Len(t, doc.Nodes, 2)
+	assert.Equal(t, renderer.JSONNode{ID: "project_project-a", Type: "project", Label: "project-a", Count: 1}, doc.Nodes[0])
+	assert.Equal(t, []renderer.JSONEdge{
+		{From: "project_project-b", To: "project_project-a", Kind: "cross_project", Label: "1 edge"},
+	}, doc.Edges)
+}
+
+func TestGenerateCmd_GroupByTeam(t *testing.T) {
+	store := setupListStore(t)
+	ctx := context.Background()
+	require.NoError(t, store.SetProjectLabels(ctx, "project-b", map[string]string{"team": "identity"}))
+	teams := filepath.Join(t.TempDir(), "teams.yaml")
+	require.NoError(t, os.WriteFile(teams, []byte("orders: [project-a]\n"), 0o600))
+	output := filepath.Join(t.TempDir(), "graph.json")
+
+	cmd := &GenerateCmd{Output: output, Format: "json", GroupBy: "team"}
+	require.ErrorContains(t, cmd.generate(ctx, store), "ownership")
+
+	cmd.ownership = config.Ownership{LabelKey: "team", File: teams}
+	require.NoError(t, cmd.generate(ctx, store))
+
+	var doc renderer.JSONDocument
+	data, err := os.ReadFile(output)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &doc))
+	assert.Equal(t, []string{"project-a", "project-b"}, doc.Projects)
+	teamOf := map[string]string{}
+	for _, node := range doc.Nodes {
+		teamOf[node.Label] = node.Team
+	}
+	assert.Equal(t, map[string]string{"orders-created": "orders", "users": "identity", "orders-email": "identity"}, teamOf)
+}
+
+func TestGenerateCmd_EmptyCache(t *testing.T) {
+	store, err := storage.NewSQLite(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = store.Close() })
+
+	cmd := &GenerateCmd{Output: filepath.Join(t.TempDir(), "graph.html"), Format: "html"}
+	err = cmd.generate(context.Background(), store)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "scan")
+}
+
+func TestGenerateCmd_Where(t *testing.T) {
+	store := setupListStore(t)
+	output := filepath.Join(t.TempDir(), "graph.json")
+
+	cmd := &GenerateCmd{Output: output, Format: "json", Where: `type == "topic" && fanout > 0`}
+	require.NoError(t, cmd.generate(context.Background(), store))
+
+	data, err := os.ReadFile(output)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "orders-created")
+	assert.NotContains(t, string(data), `"label": "users"`)
+	assert.NotContains(t, string(data), "orders-email")
+
+	cmd.Where = `type ==`
+	err = cmd.generate(context.Background(), store)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid expression")
+}
+
+func TestGenerateCmd_DiffAgainst(t *testing.T) {
+	store := setupListStore(t)
+	ctx := context.Background()
+	dir := t.TempDir()
+	snapshot := filepath.Join(dir, "before.json")
+	require.NoError(t, (&GenerateCmd{Output: snapshot, Format: "json"}).generate(ctx, store))
+
+	require.NoError(t, store.SaveTopic(ctx, &storage.Topic{Name: "payments", ProjectID: "project-a", FullResourceName: "projects/project-a/topics/payments"}))
+	require.NoError(t, store.DeleteTopic(ctx, "projects/project-b/topics/users"))
+
+	output := filepath.Join(dir, "graph.json")
+	cmd := &GenerateCmd{Output: output, Format: "json", DiffAgainst: snapshot, Where: "diff"}
+	require.NoError(t, cmd.generate(ctx, store))
+	g, err := renderer.ReadJSONFile(output)
+	require.NoError(t, err)
+	require.Len(t, g.Nodes, 2)
+	assert.Equal(t, "added", g.Nodes["topic_project-a_payments"].Metadata[graph.DiffKey])
+	assert.Equal(t, "removed", g.Nodes["topic_project-b_users"].Metadata[graph.DiffKey])
+
+	cmd.DiffAgainst = "last tuesday"
+	err = cmd.generate(ctx, store)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "neither a JSON export nor a date")
+}
+
+func TestGenerateCmd_Demo(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "graph.json")
+
+	cmd := &GenerateCmd{Output: output, Format: "json", Demo: true}
+	require.NoError(t, cmd.generateDemo(context.Background()))
+
+	data, err := os.ReadFile(output)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "demo-shop")
+	assert.Contains(t, string(data), "orders-to-bigquery")
+}
+
+func TestGenerateCmd_ShowInferred(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "graph.json")
+
+	cmd := &GenerateCmd{Output: output, Format: "json", Demo: true}
+	require.NoError(t, cmd.generateDemo(context.Background()))
+	data, err := os.ReadFile(output)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "checkout@demo-shop", "IAM-only publishers are hidden by default")
+	assert.NotContains(t, string(data), `"inferred": true`)
+
+	cmd.ShowInferred = true
+	require.NoError(t, cmd.generateDemo(context.Background()))
+	data, err = os.ReadFile(output)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "checkout@demo-shop")
+	assert.Contains(t, string(data), `"label": "can publish"`)
+	assert.Contains(t, string(data), `"inferred": true`)
+}
+
+func TestGenerateCmd_Focus(t *testing.T) {
+	store := setupListStore(t)
+	output := filepath.Join(t.TempDir(), "graph.json")
+
+	cmd := &GenerateCmd{Output: output, Format: "json", Focus: []string{"users"}}
+	require.NoError(t, cmd.generate(context.Background(), store))
+
+	data, err := os.ReadFile(output)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "users")
+	assert.NotContains(t, string(data), "orders-created")
+
+	cmd.Focus = []string{"missing"}
+	err = cmd.generate(context.Background(), store)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "not found")
+}
+
+func TestGenerateCmd_FocusDepth(t *testing.T) {
+	store := setupListStore(t)
+	ctx := context.Background()
+	require.NoError(t, store.SaveSubscriptionDestination(ctx, &storage.SubscriptionDestination{
+		SubscriptionFullResourceName: "projects/project-b/subscriptions/orders-email", ProjectID: "project-b",
+		Type: storage.DestinationTypeBigQuery, Resource: "project-b.mail.orders",
+	}))
+
+	// Any resource can be the focus, by full resource name
+	cmd := &GenerateCmd{Focus: []string{"projects/project-a/topics/orders-created"}, Depth: 1}
+	g, err := cmd.build(ctx, store)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"topic_project-a_orders-created", "sub_project-b_orders-email"}, nodeIDs(g))
+
+	cmd = &GenerateCmd{Focus: []string{"projects/project-a/topics/orders-created"}, Depth: 2}
+	g, err = cmd.build(ctx, store)
+	require.NoError(t, err)
+	assert.Len(t, g.Nodes, 3, "the topic, its subscription and the subscription's table")
+
+	// Subscriptions can be focused by name too
+	cmd = &GenerateCmd{Focus: []string{"orders-email"}, Depth: 0}
+	g, err = cmd.build(ctx, store)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"sub_project-b_orders-email"}, nodeIDs(g))
+
+	cmd = &GenerateCmd{Focus: []string{"orders-email"}, Depth: -1}
+	_, err = cmd.build(ctx, store)
+	require.Error(t, err)
+}
+
+func TestGenerateCmd_NameFilters(t *testing.T) {
+	store := setupListStore(t)
+	ctx := context.Background()
+	require.NoError(t, store.SaveSubscription(ctx, &storage.Subscription{
+		Name: "users-sync", ProjectID: "project-b", TopicFullResourceName: "projects/project-b/topics/users",
+		FullResourceName: "projects/project-b/subscriptions/users-sync",
+	}))
+
+	// Matching topics and their subscriptions
+	cmd := &GenerateCmd{TopicFilter: []string{"orders-*"}}
+	g, err := cmd.build(ctx, store)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"topic_project-a_orders-created", "sub_project-b_orders-email"}, nodeIDs(g))
+
+	// Matching subscriptions and their topics
+	cmd = &GenerateCmd{SubscriptionFilter: []string{"*-sync"}}
+	g, err = cmd.build(ctx, store)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"topic_project-b_users", "sub_project-b_users-sync"}, nodeIDs(g))
+
+	cmd = &GenerateCmd{TopicFilter: []string{"payments-*"}}
+	_, err = cmd.build(ctx, store)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no topics or subscriptions match")
+
+	cmd = &GenerateCmd{TopicFilter: []string{"orders-["}}
+	_, err = cmd.build(ctx, store)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid name pattern")
+}
+
+func nodeIDs(g *graph.Graph) []string {
+	ids := make([]string, 0, len(g.Nodes))
+	for id := range g.Nodes {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func TestGenerateCmd_ApplyView(t *testing.T) {
+	views := map[string]config.View{
+		"payments-prod": {
+			Projects:    []string{"payments-prod"},
+			Focus:       []string{"payments"},
+			TopicFilter: []string{"payments-*"},
+			Layout:      "dot",
+			Format:      "html",
+			ColorBy:     "classification",
+			Theme:       "dark",
+			Output:      "payments.html",
+		},
+	}
+
+	// Defaults take the view's values
+	cmd := &GenerateCmd{Format: "svg", Layout: "fdp", ColorBy: "type", View: "payments-prod"}
+	require.NoError(t, cmd.applyView(views))
+	assert.Equal(t, []string{"payments-prod"}, cmd.Projects)
+	assert.Equal(t, []string{"payments"}, cmd.Focus)
+	assert.Equal(t, []string{"payments-*"}, cmd.TopicFilter)
+	assert.Equal(t, "dot", cmd.Layout)
+	assert.Equal(t, "html", cmd.Format)
+	assert.Equal(t, "classification", cmd.ColorBy)
+	assert.Equal(t, "dark", cmd.Theme)
+	assert.Equal(t, "payments.html", cmd.Output)
+
+	// Flags override the view
+	cmd = &GenerateCmd{Format: "json", Layout: "fdp", ColorBy: "type", Theme: "colorblind", Output: "out.json", View: "payments-prod"}
+	require.NoError(t, cmd.applyView(views))
+	assert.Equal(t, "json", cmd.Format)
+	assert.Equal(t, "colorblind", cmd.Theme)
+	assert.Equal(t, "out.json", cmd.Output)
+
+	cmd.View = "missing"
+	err := cmd.applyView(views)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "payments-prod")
+}
+
+func TestRenderView(t *testing.T) {
+	store := setupListStore(t)
+	output := filepath.Join(t.TempDir(), "users.svg")
+	cfg := &config.Config{Views: map[string]config.View{
+		"users": {Focus: []string{"users"}, Format: "html"},
+	}}
+
+	// The requested format wins over the view's
+	require.NoError(t, renderView(cfg, nil)(context.Background(), store, "users", "svg", output))
+	data, err := os.ReadFile(output)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "<svg")
+	assert.Contains(t, string(data), "users")
+	assert.NotContains(t, string(data), "orders-created")
+
+	// A view drawn in its own theme
+	cfg.Views["users-dark"] = config.View{Focus: []string{"users"}, Theme: "dark"}
+	require.NoError(t, renderView(cfg, nil)(context.Background(), store, "users-dark", "svg", output))
+	data, err = os.ReadFile(output)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "#1e1e1e")
+
+	err = renderView(cfg, nil)(context.Background(), store, "missing", "svg", output)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "view missing not found")
+}
+
+func TestNewRenderer_NoGraphviz(t *testing.T) {
+	// Empty PATH guarantees the dot binary can't be found
+	t.Setenv("PATH", "")
+
+	for _, engine := range []string{"auto", "embedded"} {
+		_, err := newRenderer("png", "fdp", engine, renderer.Options{})
+		assert.NoError(t, err, engine)
+	}
+
+	_, err := newRenderer("png", "fdp", "graphviz", renderer.Options{})
+	assert.ErrorIs(t, err, renderer.ErrGraphvizNotFound)
+	assert.ErrorContains(t, err, "--renderer embedded")
+
+	// PDF needs Graphviz, which is reported up front
+	_, err = newRenderer("pdf", "fdp", "auto", renderer.Options{})
+	assert.ErrorIs(t, err, renderer.ErrGraphvizNotFound)
+	_, err = newRenderer("pdf", "fdp", "embedded", renderer.Options{})
+	assert.ErrorIs(t, err, renderer.ErrFormatNeedsGraphviz)
+
+	store := setupListStore(t)
+	output := filepath.Join(t.TempDir(), "graph.pdf")
+	cmd := &GenerateCmd{Output: output, Format: "pdf", Layout: "fdp", Renderer: "auto"}
+	require.ErrorIs(t, cmd.generate(context.Background(), store), renderer.ErrGraphvizNotFound)
+	_, err = os.Stat(output)
+	assert.True(t, os.IsNotExist(err))
+}
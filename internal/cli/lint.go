@@ -0,0 +1,156 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"text/tabwriter"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/config"
+	"github.com/NissesSenap/gcp-visualizer/internal/graph"
+	"github.com/NissesSenap/gcp-visualizer/internal/lint"
+	"github.com/NissesSenap/gcp-visualizer/internal/query"
+	"github.com/NissesSenap/gcp-visualizer/internal/storage"
+)
+
+type LintCmd struct {
+	Projects           []string `help:"Filter by projects"`
+	RequireRetryPolicy bool     `help:"Also flag subscriptions without a retry policy, which redeliver failed messages immediately"`
+	Format             string   `help:"Output format, sarif for CI annotations" enum:"table,json,sarif" default:"table"`
+	Output             string   `help:"Write to this file instead of stdout"`
+}
+
+func (c *LintCmd) Run(cli *CLI) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	store, err := openStore()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = store.Close() }()
+
+	if c.Output == "" {
+		return c.lint(cli.Context(), store, cfg, os.Stdout)
+	}
+	findings, err := c.findings(cli.Context(), store, cfg)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(c.Output, func(w io.Writer) error {
+		return c.write(w, cfg, findings)
+	}); err != nil {
+		return err
+	}
+	return lintError(findings)
+}
+
+// lint writes every finding to w and returns an error if there were any
+func (c *LintCmd) lint(ctx context.Context, store storage.Store, cfg *config.Config, w io.Writer) error {
+	findings, err := c.findings(ctx, store, cfg)
+	if err != nil {
+		return err
+	}
+	if err := c.write(w, cfg, findings); err != nil {
+		return err
+	}
+	return lintError(findings)
+}
+
+// findings checks the cached topology against the built-in rules and the rules of the config
+func (c *LintCmd) findings(ctx context.Context, store storage.Store, cfg *config.Config) ([]lint.Finding, error) {
+	rules, err := newRules(cfg.Rules)
+	if err != nil {
+		return nil, err
+	}
+	g, err := graph.NewBuilder(store).Build(ctx, c.Projects)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build graph: %w", err)
+	}
+
+	classifier := newClassifier(cfg.Classification)
+	classifier.Apply(g)
+
+	findings := lint.SensitiveConsumers(g, classifier, cfg.Classification.AllowedProjects)
+	retryLimits := lint.DefaultRetryLimits
+	retryLimits.RequirePolicy = c.RequireRetryPolicy
+	findings = append(findings, lint.RetryPolicies(g, retryLimits)...)
+	findings = append(findings, lint.Rules(g, rules)...)
+	lint.Sort(findings)
+	return findings, nil
+}
+
+// write writes findings to w in the output format
+func (c *LintCmd) write(w io.Writer, cfg *config.Config, findings []lint.Finding) error {
+	switch c.Format {
+	case "json":
+		if findings == nil {
+			findings = []lint.Finding{}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(findings)
+	case "sarif":
+		descriptions := maps.Clone(lint.Descriptions)
+		for _, rule := range cfg.Rules {
+			descriptions[rule.Name] = rule.Message
+		}
+		return lint.WriteSARIF(w, findings, descriptions, Version)
+	}
+
+	if len(findings) == 0 {
+		fmt.Fprintln(w, "No issues found")
+		return nil
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SEVERITY\tRULE\tRESOURCE\tMESSAGE")
+	for _, f := range findings {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", f.Severity, f.Rule, f.NodeID, f.Message)
+	}
+	return tw.Flush()
+}
+
+// lintError returns the error failing lint, nil without findings
+func lintError(findings []lint.Finding) error {
+	if len(findings) == 0 {
+		return nil
+	}
+	return fmt.Errorf("lint found %d issue(s)", len(findings))
+}
+
+// newRules parses the rules of the config, which Validate has already checked
+func newRules(rules []config.Rule) ([]lint.Rule, error) {
+	parsed := make([]lint.Rule, 0, len(rules))
+	for _, r := range rules {
+		rule := lint.Rule{Name: r.Name, Message: r.Message, Severity: lint.SeverityError}
+		if r.Severity != "" {
+			rule.Severity = lint.Severity(r.Severity)
+		}
+		var err error
+		if r.Where != "" {
+			if rule.Where, err = query.Parse(r.Where); err != nil {
+				return nil, fmt.Errorf("rule %s: where: %w", r.Name, err)
+			}
+		}
+		if rule.Require, err = query.Parse(r.Require); err != nil {
+			return nil, fmt.Errorf("rule %s: require: %w", r.Name, err)
+		}
+		parsed = append(parsed, rule)
+	}
+	return parsed, nil
+}
+
+// newClassifier creates a graph classifier from the classification config
+func newClassifier(cfg config.Classification) *graph.Classifier {
+	return &graph.Classifier{
+		LabelKey:       cfg.LabelKey,
+		Levels:         cfg.Levels,
+		SensitiveLevel: cfg.SensitiveLevel,
+		Topics:         cfg.Topics,
+	}
+}
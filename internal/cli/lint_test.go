@@ -0,0 +1,75 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/config"
+	"github.com/NissesSenap/gcp-visualizer/internal/lint"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLintCmd_SensitiveConsumer(t *testing.T) {
+	store := setupListStore(t)
+
+	cfg := config.DefaultConfig()
+	cfg.Classification.Topics = map[string]string{
+		"projects/project-a/topics/orders-created": "restricted",
+	}
+
+	var buf bytes.Buffer
+	err := (&LintCmd{}).lint(context.Background(), store, cfg, &buf)
+	require.Error(t, err)
+	assert.Contains(t, buf.String(), "sensitive-topic-consumer")
+	assert.Contains(t, buf.String(), "orders-email")
+
+	// Allowing the consuming project clears the finding
+	cfg.Classification.AllowedProjects = []string{"project-b"}
+	buf.Reset()
+	require.NoError(t, (&LintCmd{}).lint(context.Background(), store, cfg, &buf))
+	assert.Contains(t, buf.String(), "No issues found")
+}
+
+func TestLintCmd_RetryPolicy(t *testing.T) {
+	store := setupListStore(t)
+	cfg := config.DefaultConfig()
+
+	var buf bytes.Buffer
+	require.NoError(t, (&LintCmd{}).lint(context.Background(), store, cfg, &buf), "missing retry policies are only flagged on request")
+
+	buf.Reset()
+	err := (&LintCmd{RequireRetryPolicy: true}).lint(context.Background(), store, cfg, &buf)
+	require.Error(t, err)
+	assert.Contains(t, buf.String(), "subscription-retry-policy")
+	assert.Contains(t, buf.String(), "orders-email")
+}
+
+func TestLintCmd_Rules(t *testing.T) {
+	store := setupListStore(t)
+	cfg := config.DefaultConfig()
+	cfg.Rules = []config.Rule{{
+		Name:     "subscription-dlq",
+		Message:  "subscriptions need a dead-letter topic",
+		Severity: "warning",
+		Where:    `type == "subscription" && label == "orders-email"`,
+		Require:  "has_dlq",
+	}}
+
+	var buf bytes.Buffer
+	err := (&LintCmd{Format: "json"}).lint(context.Background(), store, cfg, &buf)
+	require.Error(t, err)
+	var findings []lint.Finding
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &findings))
+	require.Len(t, findings, 1)
+	assert.Equal(t, "subscription-dlq", findings[0].Rule)
+	assert.Equal(t, lint.SeverityWarning, findings[0].Severity)
+	assert.Contains(t, findings[0].Resource, "subscriptions/orders-email")
+
+	buf.Reset()
+	require.Error(t, (&LintCmd{Format: "sarif"}).lint(context.Background(), store, cfg, &buf))
+	assert.Contains(t, buf.String(), `"ruleId": "subscription-dlq"`)
+	assert.Contains(t, buf.String(), `"text": "subscriptions need a dead-letter topic"`)
+}
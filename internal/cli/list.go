@@ -0,0 +1,256 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/storage"
+)
+
+type ListCmd struct {
+	Kind     string   `arg:"" enum:"topics,subscriptions,projects" help:"Resource kind to list (topics, subscriptions, projects)"`
+	Projects []string `name:"project" help:"Only list resources in these projects" placeholder:"PROJECT_ID"`
+	JSON     bool     `name:"json" help:"Output as JSON"`
+	Filter   string   `help:"Filter by field regex, e.g. name~^orders- (fields: name, project, topic)" placeholder:"FIELD~REGEX"`
+
+	// Topic settings, matched by the storage backend
+	KMSKey        string        `name:"kms-key" help:"Only list topics encrypted with this Cloud KMS key" placeholder:"KEY_NAME"`
+	WithoutKMSKey bool          `name:"without-kms-key" help:"Only list topics with Google-managed encryption"`
+	MinRetention  time.Duration `help:"Only list topics retaining acknowledged messages at least this long, e.g. 24h"`
+	Region        string        `help:"Only list topics whose message storage policy allows this region"`
+
+	// Paging of topics and subscriptions, read from the storage backend a page at a time
+	Sort   string `help:"Sort topics and subscriptions by name or project instead of full resource name" enum:",name,project" default:""`
+	Desc   bool   `help:"Sort in descending order"`
+	Limit  int    `help:"List at most this many resources, zero lists all"`
+	Offset int    `help:"Skip this many matching resources"`
+}
+
+// listItem is a single row of list output
+type listItem struct {
+	Name             string `json:"name"`
+	ProjectID        string `json:"project_id"`
+	FullResourceName string `json:"full_resource_name,omitempty"`
+	Topic            string `json:"topic,omitempty"`
+
+	// Projects only
+	Status string `json:"status,omitempty"`
+
+	// Topics only
+	MessageRetention string   `json:"message_retention_duration,omitempty"`
+	KMSKeyName       string   `json:"kms_key_name,omitempty"`
+	StorageRegions   []string `json:"storage_regions,omitempty"`
+}
+
+// listFilter matches a single field of a listItem against a regex
+type listFilter struct {
+	field string
+	re    *regexp.Regexp
+}
+
+func (c *ListCmd) Run(cli *CLI) error {
+	store, err := openStore()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = store.Close() }()
+
+	return c.list(cli.Context(), store, os.Stdout)
+}
+
+// list writes the requested resources from store to w
+func (c *ListCmd) list(ctx context.Context, store storage.Store, w io.Writer) error {
+	filter, err := parseListFilter(c.Filter)
+	if err != nil {
+		return err
+	}
+	if c.Kind != "topics" && (c.KMSKey != "" || c.WithoutKMSKey || c.MinRetention > 0 || c.Region != "") {
+		return fmt.Errorf("--kms-key, --without-kms-key, --min-retention and --region only apply to topics")
+	}
+	if c.Kind == "projects" && (c.Sort != "" || c.Desc) {
+		return fmt.Errorf("--sort and --desc only apply to topics and subscriptions")
+	}
+
+	matched, err := c.load(ctx, store, filter)
+	if err != nil {
+		return err
+	}
+
+	if c.JSON {
+		if matched == nil {
+			matched = []listItem{}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(matched)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	switch c.Kind {
+	case "projects":
+		fmt.Fprintln(tw, "PROJECT\tSTATUS")
+		for _, item := range matched {
+			fmt.Fprintf(tw, "%s\t%s\n", item.ProjectID, projectStatus(item.Status))
+		}
+	case "subscriptions":
+		fmt.Fprintln(tw, "NAME\tPROJECT\tTOPIC")
+		for _, item := range matched {
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", item.Name, item.ProjectID, item.Topic)
+		}
+	default:
+		fmt.Fprintln(tw, "NAME\tPROJECT\tRETENTION\tKMS KEY\tREGIONS")
+		for _, item := range matched {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", item.Name, item.ProjectID,
+				orDash(item.MessageRetention), orDash(item.KMSKeyName), orDash(strings.Join(item.StorageRegions, ",")))
+		}
+	}
+	return tw.Flush()
+}
+
+// load reads the resources of the requested kind from store, topics and subscriptions a page
+// at a time, and returns those matching filter within --offset and --limit
+func (c *ListCmd) load(ctx context.Context, store storage.Store, filter *listFilter) ([]listItem, error) {
+	var items []listItem
+	skipped := 0
+	// add keeps item if it matches and is within --offset and --limit, and reports whether to read on
+	add := func(item listItem) bool {
+		if filter != nil && !filter.match(item) {
+			return true
+		}
+		if skipped < c.Offset {
+			skipped++
+			return true
+		}
+		items = append(items, item)
+		return c.Limit < 1 || len(items) < c.Limit
+	}
+	page := storage.Page{Sort: c.Sort, Desc: c.Desc, Limit: storage.PageSize}
+
+	switch c.Kind {
+	case "topics":
+		q := storage.TopicQuery{
+			Projects:      c.Projects,
+			KMSKeyName:    c.KMSKey,
+			WithoutKMSKey: c.WithoutKMSKey,
+			MinRetention:  c.MinRetention,
+			Region:        c.Region,
+		}
+		for ; ; page.Offset += page.Limit {
+			topics, err := store.ListTopics(ctx, q, page)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get topics: %w", err)
+			}
+			for _, t := range topics {
+				item := listItem{
+					Name:             t.Name,
+					ProjectID:        t.ProjectID,
+					FullResourceName: t.FullResourceName,
+					KMSKeyName:       t.KMSKeyName,
+					StorageRegions:   t.StorageRegions,
+				}
+				if t.MessageRetention > 0 {
+					item.MessageRetention = t.MessageRetention.String()
+				}
+				if !add(item) {
+					return items, nil
+				}
+			}
+			if len(topics) < page.Limit {
+				return items, nil
+			}
+		}
+	case "subscriptions":
+		for ; ; page.Offset += page.Limit {
+			subs, err := store.ListSubscriptions(ctx, c.Projects, page)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get subscriptions: %w", err)
+			}
+			for _, s := range subs {
+				if !add(listItem{
+					Name:             s.Name,
+					ProjectID:        s.ProjectID,
+					FullResourceName: s.FullResourceName,
+					Topic:            s.TopicFullResourceName,
+				}) {
+					return items, nil
+				}
+			}
+			if len(subs) < page.Limit {
+				return items, nil
+			}
+		}
+	case "projects":
+		projects, err := store.GetAllProjects(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get projects: %w", err)
+		}
+		statuses, err := store.GetProjectStatuses(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get project statuses: %w", err)
+		}
+		wanted := make(map[string]bool, len(c.Projects))
+		for _, p := range c.Projects {
+			wanted[p] = true
+		}
+		for _, p := range projects {
+			if len(wanted) == 0 || wanted[p] {
+				if !add(listItem{Name: p, ProjectID: p, Status: statuses[p]}) {
+					break
+				}
+			}
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("unknown resource kind %q", c.Kind)
+	}
+}
+
+// parseListFilter parses a "field~regex" expression.
+// Returns nil if expr is empty.
+func parseListFilter(expr string) (*listFilter, error) {
+	if expr == "" {
+		return nil, nil
+	}
+
+	field, pattern, ok := strings.Cut(expr, "~")
+	if !ok {
+		return nil, fmt.Errorf("invalid filter %q: expected FIELD~REGEX", expr)
+	}
+	switch field {
+	case "name", "project", "topic":
+	default:
+		return nil, fmt.Errorf("invalid filter field %q: must be one of name, project, topic", field)
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid filter regex %q: %w", pattern, err)
+	}
+	return &listFilter{field: field, re: re}, nil
+}
+
+func (f *listFilter) match(item listItem) bool {
+	switch f.field {
+	case "project":
+		return f.re.MatchString(item.ProjectID)
+	case "topic":
+		return f.re.MatchString(item.Topic)
+	default:
+		return f.re.MatchString(item.Name)
+	}
+}
+
+// orDash returns s, or "-" for an empty table cell
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,211 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/storage"
+	"github.com/alecthomas/kong"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupListStore(t *testing.T) storage.Store {
+	store, err := storage.NewSQLite(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = store.Close() })
+
+	ctx := context.Background()
+	for _, topic := range []*storage.Topic{
+		{Name: "orders-created", ProjectID: "project-a", FullResourceName: "projects/project-a/topics/orders-created"},
+		{Name: "users", ProjectID: "project-b", FullResourceName: "projects/project-b/topics/users"},
+	} {
+		require.NoError(t, store.SaveTopic(ctx, topic))
+	}
+	require.NoError(t, store.SaveSubscription(ctx, &storage.Subscription{
+		Name:                  "orders-email",
+		ProjectID:             "project-b",
+		TopicFullResourceName: "projects/project-a/topics/orders-created",
+		FullResourceName:      "projects/project-b/subscriptions/orders-email",
+	}))
+	return store
+}
+
+func TestListCmd_Topics(t *testing.T) {
+	store := setupListStore(t)
+
+	var buf bytes.Buffer
+	cmd := &ListCmd{Kind: "topics"}
+	require.NoError(t, cmd.list(context.Background(), store, &buf))
+
+	assert.Contains(t, buf.String(), "orders-created")
+	assert.Contains(t, buf.String(), "users")
+}
+
+func TestListCmd_ProjectFilter(t *testing.T) {
+	store := setupListStore(t)
+
+	var buf bytes.Buffer
+	cmd := &ListCmd{Kind: "topics", Projects: []string{"project-b"}}
+	require.NoError(t, cmd.list(context.Background(), store, &buf))
+
+	assert.NotContains(t, buf.String(), "orders-created")
+	assert.Contains(t, buf.String(), "users")
+}
+
+func TestListCmd_JSONWithRegex(t *testing.T) {
+	store := setupListStore(t)
+
+	var buf bytes.Buffer
+	cmd := &ListCmd{Kind: "subscriptions", JSON: true, Filter: "topic~orders"}
+	require.NoError(t, cmd.list(context.Background(), store, &buf))
+
+	var items []listItem
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &items))
+	require.Len(t, items, 1)
+	assert.Equal(t, "orders-email", items[0].Name)
+	assert.Equal(t, "projects/project-a/topics/orders-created", items[0].Topic)
+}
+
+func TestListCmd_TopicSettings(t *testing.T) {
+	store := setupListStore(t)
+	require.NoError(t, store.SaveTopic(context.Background(), &storage.Topic{
+		Name: "audit", ProjectID: "project-a", FullResourceName: "projects/project-a/topics/audit",
+		MessageRetention: 7 * 24 * time.Hour, KMSKeyName: "projects/kms/locations/europe/keyRings/pubsub/cryptoKeys/audit",
+		StorageRegions: []string{"europe-west1"},
+	}))
+
+	var buf bytes.Buffer
+	cmd := &ListCmd{Kind: "topics", MinRetention: 24 * time.Hour, Region: "europe-west1", JSON: true}
+	require.NoError(t, cmd.list(context.Background(), store, &buf))
+	assert.JSONEq(t, `[{
+		"name": "audit",
+		"project_id": "project-a",
+		"full_resource_name": "projects/project-a/topics/audit",
+		"message_retention_duration": "168h0m0s",
+		"kms_key_name": "projects/kms/locations/europe/keyRings/pubsub/cryptoKeys/audit",
+		"storage_regions": ["europe-west1"]
+	}]`, buf.String())
+
+	buf.Reset()
+	cmd = &ListCmd{Kind: "topics", WithoutKMSKey: true}
+	require.NoError(t, cmd.list(context.Background(), store, &buf))
+	assert.Contains(t, buf.String(), "orders-created")
+	assert.NotContains(t, buf.String(), "audit")
+
+	cmd = &ListCmd{Kind: "subscriptions", Region: "europe-west1"}
+	assert.Error(t, cmd.list(context.Background(), store, &buf))
+}
+
+func TestListCmd_EmptyJSON(t *testing.T) {
+	store := setupListStore(t)
+
+	var buf bytes.Buffer
+	cmd := &ListCmd{Kind: "topics", JSON: true, Filter: "name~^nothing$"}
+	require.NoError(t, cmd.list(context.Background(), store, &buf))
+	assert.JSONEq(t, "[]", buf.String())
+}
+
+func TestListCmd_Projects(t *testing.T) {
+	store := setupListStore(t)
+
+	var buf bytes.Buffer
+	cmd := &ListCmd{Kind: "projects", JSON: true}
+	require.NoError(t, cmd.list(context.Background(), store, &buf))
+
+	var items []listItem
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &items))
+	assert.Len(t, items, 2)
+	for _, item := range items {
+		assert.Empty(t, item.Status, item.ProjectID)
+	}
+
+	require.NoError(t, store.SetProjectStatus(context.Background(), "project-b", storage.ProjectStatusAPIDisabled))
+	buf.Reset()
+	cmd.JSON = false
+	require.NoError(t, cmd.list(context.Background(), store, &buf))
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 3)
+	assert.Equal(t, []string{"project-a", "ok"}, strings.Fields(lines[1]))
+	assert.Equal(t, "project-b", strings.Fields(lines[2])[0])
+	assert.Contains(t, lines[2], "Pub/Sub API disabled")
+}
+
+func TestListCmd_Paging(t *testing.T) {
+	store := setupListStore(t)
+	require.NoError(t, store.SaveTopic(context.Background(), &storage.Topic{
+		Name: "audit", ProjectID: "project-c", FullResourceName: "projects/project-c/topics/audit",
+	}))
+
+	names := func(cmd *ListCmd) []string {
+		var buf bytes.Buffer
+		cmd.JSON = true
+		require.NoError(t, cmd.list(context.Background(), store, &buf))
+		var items []listItem
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &items))
+		var names []string
+		for _, item := range items {
+			names = append(names, item.Name)
+		}
+		return names
+	}
+	assert.Equal(t, []string{"orders-created", "users", "audit"}, names(&ListCmd{Kind: "topics"}))
+	assert.Equal(t, []string{"audit", "orders-created", "users"}, names(&ListCmd{Kind: "topics", Sort: storage.SortName}))
+	assert.Equal(t, []string{"users"}, names(&ListCmd{Kind: "topics", Sort: storage.SortProject, Desc: true, Offset: 1, Limit: 1}))
+	// --offset and --limit count the resources matching --filter
+	assert.Equal(t, []string{"users"}, names(&ListCmd{Kind: "topics", Filter: "name~r", Offset: 1}))
+	assert.Equal(t, []string{"project-b"}, names(&ListCmd{Kind: "projects", Offset: 1, Limit: 1}))
+
+	assert.Error(t, (&ListCmd{Kind: "projects", Sort: storage.SortName}).list(context.Background(), store, &bytes.Buffer{}))
+}
+
+func TestParseListFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		expr    string
+		wantErr bool
+	}{
+		{name: "empty", expr: ""},
+		{name: "name regex", expr: "name~^orders-"},
+		{name: "missing separator", expr: "orders", wantErr: true},
+		{name: "unknown field", expr: "labels~x", wantErr: true},
+		{name: "invalid regex", expr: "name~(", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseListFilter(tt.expr)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestListCmd_Parse(t *testing.T) {
+	cli := &CLI{}
+	parser, err := kong.New(cli)
+	require.NoError(t, err)
+
+	_, err = parser.Parse([]string{"list", "subscriptions", "--project", "p1", "--json", "--filter", "name~^a"})
+	require.NoError(t, err)
+	assert.Equal(t, "subscriptions", cli.List.Kind)
+	assert.Equal(t, []string{"p1"}, cli.List.Projects)
+	assert.True(t, cli.List.JSON)
+	assert.Equal(t, "name~^a", cli.List.Filter)
+	assert.Empty(t, cli.List.Sort)
+
+	_, err = parser.Parse([]string{"list", "topics", "--sort", "project", "--desc", "--limit", "10"})
+	require.NoError(t, err)
+	assert.Equal(t, storage.SortProject, cli.List.Sort)
+	assert.Equal(t, 10, cli.List.Limit)
+
+	_, err = parser.Parse([]string{"list", "topics", "--sort", "size"})
+	assert.Error(t, err)
+}
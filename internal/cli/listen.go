@@ -0,0 +1,110 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/config"
+	"github.com/NissesSenap/gcp-visualizer/internal/renderer"
+	"github.com/NissesSenap/gcp-visualizer/internal/snapshot"
+	"github.com/NissesSenap/gcp-visualizer/internal/storage"
+	"github.com/NissesSenap/gcp-visualizer/internal/webhook"
+)
+
+type ListenCmd struct {
+	Addr       string `help:"Address to listen on" default:":8080"`
+	Path       string `help:"HTTP path receiving Pub/Sub push requests" default:"/pubsub/push"`
+	Token      string `help:"Shared secret expected in the 'token' query parameter, required" env:"GCP_VISUALIZER_WEBHOOK_TOKEN"`
+	ServeViews bool   `help:"Also serve the saved views as embeddable images at /views/<name>.svg and /views/<name>.png"`
+	ViewsToken string `help:"Shared secret expected in the 'token' query parameter of view images" env:"GCP_VISUALIZER_VIEWS_TOKEN"`
+}
+
+func (c *ListenCmd) Run(cli *CLI) error {
+	// Without a token anyone reaching the port could create and delete cached resources
+	if c.Token == "" {
+		return fmt.Errorf("--token or GCP_VISUALIZER_WEBHOOK_TOKEN is required, set it on the push subscription's endpoint as ?token=...")
+	}
+
+	store, err := openStore()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = store.Close() }()
+
+	mux := http.NewServeMux()
+	mux.Handle(c.Path, webhook.NewHandler(store, c.Token))
+	if c.ServeViews {
+		cfg, err := config.Load()
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+		if len(cfg.Views) == 0 {
+			return fmt.Errorf("--serve-views needs views in the config")
+		}
+		names := make([]string, 0, len(cfg.Views))
+		for name := range cfg.Views {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		theme, err := renderer.LoadTheme(cfg.Visualization.Theme)
+		if err != nil {
+			return err
+		}
+		mux.Handle("/views/", snapshot.NewHandler(store, names, renderView(cfg, theme), c.ViewsToken))
+		fmt.Printf("Serving views %v on %s/views/<name>.svg\n", names, c.Addr)
+	}
+
+	server := &http.Server{
+		Addr:              c.Addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	// Shut down when the CLI context is cancelled
+	ctx := cli.Context()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+
+	fmt.Printf("Listening for audit log events on %s%s\n", c.Addr, c.Path)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// renderView returns a snapshot.RenderFunc rendering saved views the way 'generate --view' does,
+// in the view's theme or else theme. Without Graphviz both SVG and PNG fall back to the built-in renderers.
+func renderView(cfg *config.Config, theme *renderer.Theme) snapshot.RenderFunc {
+	return func(ctx context.Context, store storage.Store, view, format, output string) error {
+		c := &GenerateCmd{View: view, Format: "svg", Layout: "fdp", Renderer: "auto", ColorBy: "type", Depth: 2}
+		c.classification = cfg.Classification
+		c.backlogThresholds = backlogThresholds(cfg.Metrics)
+		if err := c.applyView(cfg.Views); err != nil {
+			return err
+		}
+		g, err := c.build(ctx, store)
+		if err != nil {
+			return err
+		}
+
+		viewTheme := theme
+		if c.Theme != "" {
+			if viewTheme, err = renderer.LoadTheme(c.Theme); err != nil {
+				return err
+			}
+		}
+		r, err := newRenderer(format, c.Layout, c.Renderer, renderer.Options{Theme: viewTheme})
+		if err != nil {
+			return err
+		}
+		return r.Render(ctx, g, output, format)
+	}
+}
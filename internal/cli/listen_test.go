@@ -0,0 +1,13 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListenCmd_RequiresToken(t *testing.T) {
+	// Refused before opening the cache or listening
+	err := (&ListenCmd{Addr: ":0", Path: "/pubsub/push"}).Run(nil)
+	assert.ErrorContains(t, err, "--token")
+}
@@ -0,0 +1,115 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/storage"
+)
+
+type QueryCmd struct {
+	Principal QueryPrincipalCmd `cmd:"principal" help:"List the subscriptions a principal can consume from or was seen pulling from"`
+}
+
+type QueryPrincipalCmd struct {
+	Principal string   `arg:"" help:"IAM member, e.g. serviceAccount:app@project.iam.gserviceaccount.com, the type prefix is optional"`
+	Projects  []string `name:"project" help:"Only search subscriptions in these projects" placeholder:"PROJECT_ID"`
+	JSON      bool     `name:"json" help:"Output as JSON"`
+}
+
+// principalAccess is one subscription a principal has access to
+type principalAccess struct {
+	Principal    string `json:"principal"`
+	Subscription string `json:"subscription"`
+	ProjectID    string `json:"project_id"`
+	Topic        string `json:"topic,omitempty"`
+	Role         string `json:"role"`
+	Source       string `json:"source"`
+}
+
+func (c *QueryPrincipalCmd) Run(cli *CLI) error {
+	store, err := openStore()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = store.Close() }()
+
+	return c.query(cli.Context(), store, os.Stdout)
+}
+
+// query writes every cached subscription binding or audit log sighting of c.Principal to w
+func (c *QueryPrincipalCmd) query(ctx context.Context, store storage.Store, w io.Writer) error {
+	consumers, err := store.GetAllSubscriptionConsumers(ctx, c.Projects)
+	if err != nil {
+		return fmt.Errorf("failed to get subscription consumers: %w", err)
+	}
+	subs, err := store.GetAllSubscriptions(ctx, c.Projects)
+	if err != nil {
+		return fmt.Errorf("failed to get subscriptions: %w", err)
+	}
+	topics := make(map[string]string, len(subs))
+	for _, sub := range subs {
+		topics[sub.FullResourceName] = sub.TopicFullResourceName
+	}
+
+	access := make([]principalAccess, 0)
+	for _, consumer := range consumers {
+		if !matchesPrincipal(consumer.Principal, c.Principal) {
+			continue
+		}
+		access = append(access, principalAccess{
+			Principal:    consumer.Principal,
+			Subscription: consumer.SubscriptionFullResourceName,
+			ProjectID:    consumer.ProjectID,
+			Topic:        topics[consumer.SubscriptionFullResourceName],
+			Role:         consumer.Role,
+			Source:       consumer.Source,
+		})
+	}
+	sort.Slice(access, func(i, j int) bool {
+		if access[i].Subscription != access[j].Subscription {
+			return access[i].Subscription < access[j].Subscription
+		}
+		return access[i].Source < access[j].Source
+	})
+
+	if c.JSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(access)
+	}
+
+	if len(access) == 0 {
+		fmt.Fprintf(w, "No cached access found for %s, scan the projects with the IAM collector first\n", c.Principal)
+		return nil
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SUBSCRIPTION\tTOPIC\tROLE\tSOURCE\tPRINCIPAL")
+	for _, a := range access {
+		topic := a.Topic
+		if topic == "" {
+			topic = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", a.Subscription, topic, a.Role, a.Source, a.Principal)
+	}
+	return tw.Flush()
+}
+
+// matchesPrincipal reports whether an IAM member matches the queried principal.
+// A query without a type prefix, e.g. "app@p.iam.gserviceaccount.com", matches any member type.
+func matchesPrincipal(member, query string) bool {
+	if strings.EqualFold(member, query) {
+		return true
+	}
+	if strings.Contains(query, ":") {
+		return false
+	}
+	_, email, ok := strings.Cut(member, ":")
+	return ok && strings.EqualFold(email, query)
+}
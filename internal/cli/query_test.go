@@ -0,0 +1,46 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryPrincipalCmd(t *testing.T) {
+	store := setupListStore(t)
+	ctx := context.Background()
+
+	const mailer = "serviceAccount:mailer@project-b.iam.gserviceaccount.com"
+	for _, consumer := range []*storage.SubscriptionConsumer{
+		{SubscriptionFullResourceName: "projects/project-b/subscriptions/orders-email", ProjectID: "project-b", Principal: mailer, Source: storage.ConsumerSourceIAM, Role: "roles/pubsub.subscriber"},
+		{SubscriptionFullResourceName: "projects/project-b/subscriptions/orders-email", ProjectID: "project-b", Principal: mailer, Source: storage.ConsumerSourceAuditLog, Role: "google.pubsub.v1.Subscriber.Pull"},
+		{SubscriptionFullResourceName: "projects/project-b/subscriptions/orders-email", ProjectID: "project-b", Principal: "user:alice@example.com", Source: storage.ConsumerSourceIAM, Role: "roles/pubsub.subscriber"},
+	} {
+		require.NoError(t, store.SaveSubscriptionConsumer(ctx, consumer))
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, (&QueryPrincipalCmd{Principal: mailer}).query(ctx, store, &buf))
+	assert.Contains(t, buf.String(), "projects/project-b/subscriptions/orders-email")
+	assert.Contains(t, buf.String(), "projects/project-a/topics/orders-created")
+	assert.Contains(t, buf.String(), "audit_log")
+	assert.NotContains(t, buf.String(), "alice")
+
+	// The member type prefix is optional
+	buf.Reset()
+	require.NoError(t, (&QueryPrincipalCmd{Principal: "alice@example.com", JSON: true}).query(ctx, store, &buf))
+	var access []principalAccess
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &access))
+	require.Len(t, access, 1)
+	assert.Equal(t, "user:alice@example.com", access[0].Principal)
+	assert.Equal(t, "roles/pubsub.subscriber", access[0].Role)
+
+	buf.Reset()
+	require.NoError(t, (&QueryPrincipalCmd{Principal: "group:alice@example.com"}).query(ctx, store, &buf))
+	assert.Contains(t, buf.String(), "No cached access found")
+}
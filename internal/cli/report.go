@@ -0,0 +1,561 @@
+package cli
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/config"
+	"github.com/NissesSenap/gcp-visualizer/internal/graph"
+	"github.com/NissesSenap/gcp-visualizer/internal/storage"
+	"github.com/NissesSenap/gcp-visualizer/internal/terraform"
+)
+
+type ReportCmd struct {
+	CrossProject ReportCrossProjectCmd `cmd:"cross-project" help:"List the topics consumed by subscriptions in other projects"`
+	Orphans      ReportOrphansCmd      `cmd:"orphans" help:"List topics without subscriptions and subscriptions whose topic is gone"`
+	Backlog      ReportBacklogCmd      `cmd:"backlog" help:"List the subscriptions with the largest or oldest backlogs, from the metrics collector"`
+	Unmanaged    ReportUnmanagedCmd    `cmd:"unmanaged" help:"List the topics and subscriptions missing from the Terraform state, created by hand"`
+	Residency    ReportResidencyCmd    `cmd:"residency" help:"List the topics allowed to store messages outside the regions of the data-residency policy"`
+	Filters      ReportFiltersCmd      `cmd:"filters" help:"List the topics whose subscribers filter messages by their attributes, with the filters"`
+	Lint         LintCmd               `cmd:"lint" help:"Check the cached topology against messaging rules, same as the lint command"`
+}
+
+type ReportCrossProjectCmd struct {
+	Projects []string `name:"project" help:"Only report relationships where these projects consume or produce" placeholder:"PROJECT_ID"`
+	Format   string   `help:"Output format" enum:"table,csv,json" default:"table"`
+	Output   string   `help:"Write to this file instead of stdout"`
+}
+
+// crossProjectLink is a topic consumed from another project, with the consuming subscriptions
+type crossProjectLink struct {
+	ConsumerProject string   `json:"consumer_project"`
+	ProducerProject string   `json:"producer_project"`
+	Topic           string   `json:"topic"`
+	Subscriptions   []string `json:"subscriptions"`
+}
+
+func (c *ReportCrossProjectCmd) Run(cli *CLI) error {
+	store, err := openStore()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = store.Close() }()
+
+	if c.Output == "" {
+		return c.report(cli.Context(), store, os.Stdout)
+	}
+	return writeFileAtomic(c.Output, func(w io.Writer) error {
+		return c.report(cli.Context(), store, w)
+	})
+}
+
+// report writes the consumer project -> producer project -> topic relationships to w
+func (c *ReportCrossProjectCmd) report(ctx context.Context, store storage.Store, w io.Writer) error {
+	wanted := make(map[string]bool, len(c.Projects))
+	for _, p := range c.Projects {
+		wanted[p] = true
+	}
+
+	// A project filter matches either side, so subscriptions of every project are read, a page at a time
+	byTopic := make(map[[2]string]*crossProjectLink)
+	err := storage.EachSubscription(ctx, store, nil, func(sub *storage.Subscription) error {
+		producer, topic := graph.ParseTopicReference(sub.TopicFullResourceName)
+		if topic == "" || producer == sub.ProjectID {
+			return nil
+		}
+		if len(wanted) > 0 && !wanted[producer] && !wanted[sub.ProjectID] {
+			return nil
+		}
+		key := [2]string{sub.ProjectID, sub.TopicFullResourceName}
+		link, ok := byTopic[key]
+		if !ok {
+			link = &crossProjectLink{ConsumerProject: sub.ProjectID, ProducerProject: producer, Topic: topic}
+			byTopic[key] = link
+		}
+		link.Subscriptions = append(link.Subscriptions, sub.Name)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get subscriptions: %w", err)
+	}
+
+	links := make([]crossProjectLink, 0, len(byTopic))
+	for _, link := range byTopic {
+		sort.Strings(link.Subscriptions)
+		links = append(links, *link)
+	}
+	sort.Slice(links, func(i, j int) bool {
+		if links[i].ConsumerProject != links[j].ConsumerProject {
+			return links[i].ConsumerProject < links[j].ConsumerProject
+		}
+		if links[i].ProducerProject != links[j].ProducerProject {
+			return links[i].ProducerProject < links[j].ProducerProject
+		}
+		return links[i].Topic < links[j].Topic
+	})
+
+	switch c.Format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(links)
+	case "csv":
+		cw := csv.NewWriter(w)
+		_ = cw.Write([]string{"consumer_project", "producer_project", "topic", "subscriptions"})
+		for _, link := range links {
+			_ = cw.Write([]string{link.ConsumerProject, link.ProducerProject, link.Topic, strings.Join(link.Subscriptions, ";")})
+		}
+		cw.Flush()
+		return cw.Error()
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "CONSUMER PROJECT\tPRODUCER PROJECT\tTOPIC\tSUBSCRIPTIONS")
+	for _, link := range links {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", link.ConsumerProject, link.ProducerProject, link.Topic, strings.Join(link.Subscriptions, ", "))
+	}
+	return tw.Flush()
+}
+
+type ReportOrphansCmd struct {
+	Projects []string `name:"project" help:"Only report orphans in these projects" placeholder:"PROJECT_ID"`
+	Format   string   `help:"Output format" enum:"table,csv,json" default:"table"`
+	Output   string   `help:"Write to this file instead of stdout"`
+}
+
+// orphanItem is a single orphan in JSON output
+type orphanItem struct {
+	Type             string `json:"type"`
+	FullResourceName string `json:"full_resource_name"`
+	ProjectID        string `json:"project_id"`
+	Topic            string `json:"topic,omitempty"`
+	Reason           string `json:"reason"`
+}
+
+func (c *ReportOrphansCmd) Run(cli *CLI) error {
+	store, err := openStore()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = store.Close() }()
+
+	if c.Output == "" {
+		return c.report(cli.Context(), store, os.Stdout)
+	}
+	return writeFileAtomic(c.Output, func(w io.Writer) error {
+		return c.report(cli.Context(), store, w)
+	})
+}
+
+// report writes the orphaned topics and subscriptions to w
+func (c *ReportOrphansCmd) report(ctx context.Context, store storage.Store, w io.Writer) error {
+	orphans, err := graph.FindOrphans(ctx, store, c.Projects)
+	if err != nil {
+		return err
+	}
+
+	switch c.Format {
+	case "json":
+		items := make([]orphanItem, 0, len(orphans))
+		for _, o := range orphans {
+			items = append(items, orphanItem{
+				Type:             string(o.Type),
+				FullResourceName: o.FullResourceName,
+				ProjectID:        o.ProjectID,
+				Topic:            o.Topic,
+				Reason:           o.Reason,
+			})
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(items)
+	case "csv":
+		cw := csv.NewWriter(w)
+		_ = cw.Write([]string{"type", "full_resource_name", "project_id", "topic", "reason"})
+		for _, o := range orphans {
+			_ = cw.Write([]string{string(o.Type), o.FullResourceName, o.ProjectID, o.Topic, o.Reason})
+		}
+		cw.Flush()
+		return cw.Error()
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TYPE\tRESOURCE\tPROJECT\tREASON")
+	for _, o := range orphans {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", o.Type, o.FullResourceName, o.ProjectID, o.Reason)
+	}
+	return tw.Flush()
+}
+
+type ReportBacklogCmd struct {
+	Projects []string `name:"project" help:"Only report subscriptions in these projects" placeholder:"PROJECT_ID"`
+	Top      int      `help:"List at most this many subscriptions, zero lists all" default:"20"`
+	Format   string   `help:"Output format" enum:"table,csv,json" default:"table"`
+	Output   string   `help:"Write to this file instead of stdout"`
+
+	thresholds graph.BacklogThresholds // from the metrics config, set by Run
+}
+
+// backlogItem is a single subscription in JSON output
+type backlogItem struct {
+	FullResourceName        string   `json:"full_resource_name"`
+	ProjectID               string   `json:"project_id"`
+	Topic                   string   `json:"topic"`
+	Messages                int64    `json:"messages"`
+	OldestUnackedAgeSeconds int64    `json:"oldest_unacked_age_seconds"`
+	Reasons                 []string `json:"reasons"`
+}
+
+func (c *ReportBacklogCmd) Run(cli *CLI) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	c.thresholds = backlogThresholds(cfg.Metrics)
+
+	store, err := openStore()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = store.Close() }()
+
+	if c.Output == "" {
+		return c.report(cli.Context(), store, os.Stdout)
+	}
+	return writeFileAtomic(c.Output, func(w io.Writer) error {
+		return c.report(cli.Context(), store, w)
+	})
+}
+
+// report writes the subscriptions over the backlog thresholds to w, worst first
+func (c *ReportBacklogCmd) report(ctx context.Context, store storage.Store, w io.Writer) error {
+	backlogs, err := graph.FindBacklogs(ctx, store, c.Projects, c.thresholds)
+	if err != nil {
+		return err
+	}
+	if c.Top > 0 && len(backlogs) > c.Top {
+		backlogs = backlogs[:c.Top]
+	}
+
+	switch c.Format {
+	case "json":
+		items := make([]backlogItem, 0, len(backlogs))
+		for _, b := range backlogs {
+			items = append(items, backlogItem{
+				FullResourceName:        b.FullResourceName,
+				ProjectID:               b.ProjectID,
+				Topic:                   b.Topic,
+				Messages:                b.Messages,
+				OldestUnackedAgeSeconds: int64(b.OldestUnackedAge / time.Second),
+				Reasons:                 b.Reasons,
+			})
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(items)
+	case "csv":
+		cw := csv.NewWriter(w)
+		_ = cw.Write([]string{"full_resource_name", "project_id", "topic", "messages", "oldest_unacked_age_seconds", "reasons"})
+		for _, b := range backlogs {
+			_ = cw.Write([]string{b.FullResourceName, b.ProjectID, b.Topic, strconv.FormatInt(b.Messages, 10),
+				strconv.FormatInt(int64(b.OldestUnackedAge/time.Second), 10), strings.Join(b.Reasons, ";")})
+		}
+		cw.Flush()
+		return cw.Error()
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SUBSCRIPTION\tPROJECT\tMESSAGES\tOLDEST UNACKED\tREASONS")
+	for _, b := range backlogs {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\n", b.Name, b.ProjectID, b.Messages, b.OldestUnackedAge.Round(time.Second), strings.Join(b.Reasons, ", "))
+	}
+	return tw.Flush()
+}
+
+// backlogThresholds converts the backlog thresholds of the metrics config
+func backlogThresholds(cfg config.Metrics) graph.BacklogThresholds {
+	return graph.BacklogThresholds{MaxMessages: cfg.MaxBacklog, MaxUnackedAge: cfg.MaxUnackedAge}
+}
+
+type ReportUnmanagedCmd struct {
+	Projects       []string `name:"project" help:"Only report resources in these projects" placeholder:"PROJECT_ID"`
+	TerraformState []string `help:"Terraform state files or 'terraform show -json' outputs managing the resources, glob patterns allowed (default: terraform.state_files of the config)" placeholder:"FILE"`
+	Format         string   `help:"Output format" enum:"table,csv,json" default:"table"`
+	Output         string   `help:"Write to this file instead of stdout"`
+}
+
+// unmanagedItem is a single unmanaged resource in JSON output
+type unmanagedItem struct {
+	Type             string `json:"type"`
+	FullResourceName string `json:"full_resource_name"`
+	ProjectID        string `json:"project_id"`
+}
+
+func (c *ReportUnmanagedCmd) Run(cli *CLI) error {
+	if len(c.TerraformState) == 0 {
+		cfg, err := config.Load()
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+		c.TerraformState = cfg.Terraform.StateFiles
+	}
+
+	store, err := openStore()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = store.Close() }()
+
+	if c.Output == "" {
+		return c.report(cli.Context(), store, os.Stdout)
+	}
+	return writeFileAtomic(c.Output, func(w io.Writer) error {
+		return c.report(cli.Context(), store, w)
+	})
+}
+
+// report writes the topics and subscriptions missing from the Terraform state to w
+func (c *ReportUnmanagedCmd) report(ctx context.Context, store storage.Store, w io.Writer) error {
+	if len(c.TerraformState) == 0 {
+		return fmt.Errorf("no Terraform state to compare with, pass --terraform-state or set terraform.state_files in the config")
+	}
+	managed, err := terraform.Load(c.TerraformState)
+	if err != nil {
+		return err
+	}
+	unmanaged, err := graph.FindUnmanaged(ctx, store, c.Projects, managed)
+	if err != nil {
+		return err
+	}
+
+	switch c.Format {
+	case "json":
+		items := make([]unmanagedItem, 0, len(unmanaged))
+		for _, u := range unmanaged {
+			items = append(items, unmanagedItem{Type: string(u.Type), FullResourceName: u.FullResourceName, ProjectID: u.ProjectID})
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(items)
+	case "csv":
+		cw := csv.NewWriter(w)
+		_ = cw.Write([]string{"type", "full_resource_name", "project_id"})
+		for _, u := range unmanaged {
+			_ = cw.Write([]string{string(u.Type), u.FullResourceName, u.ProjectID})
+		}
+		cw.Flush()
+		return cw.Error()
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TYPE\tRESOURCE\tPROJECT")
+	for _, u := range unmanaged {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", u.Type, u.FullResourceName, u.ProjectID)
+	}
+	return tw.Flush()
+}
+
+type ReportResidencyCmd struct {
+	Projects []string `name:"project" help:"Only report topics in these projects" placeholder:"PROJECT_ID"`
+	Format   string   `help:"Output format" enum:"table,csv,json" default:"table"`
+	Output   string   `help:"Write to this file instead of stdout"`
+
+	residency graph.Residency // residency of the config
+}
+
+// residencyItem is a topic violating the data-residency policy
+type residencyItem struct {
+	ProjectID      string   `json:"project_id"`
+	Topic          string   `json:"topic"`
+	StorageRegions []string `json:"storage_regions"` // empty if messages may be stored in any region
+	AllowedRegions []string `json:"allowed_regions"`
+	Outside        []string `json:"outside"` // storage regions the policy doesn't allow
+}
+
+func (c *ReportResidencyCmd) Run(cli *CLI) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	c.residency = graph.Residency{AllowedRegions: cfg.Residency.AllowedRegions, Projects: cfg.Residency.Projects}
+
+	store, err := openStore()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = store.Close() }()
+
+	if c.Output == "" {
+		return c.report(cli.Context(), store, os.Stdout)
+	}
+	return writeFileAtomic(c.Output, func(w io.Writer) error {
+		return c.report(cli.Context(), store, w)
+	})
+}
+
+// report writes the topics whose message storage policy violates the data-residency policy to w
+func (c *ReportResidencyCmd) report(ctx context.Context, store storage.Store, w io.Writer) error {
+	if len(c.residency.AllowedRegions) == 0 && len(c.residency.Projects) == 0 {
+		return fmt.Errorf("no data-residency policy to check, set residency.allowed_regions or residency.projects in the config")
+	}
+
+	items := []residencyItem{}
+	err := storage.EachTopic(ctx, store, storage.TopicQuery{Projects: c.Projects}, func(t *storage.Topic) error {
+		outside, ok := c.residency.Check(t.ProjectID, t.StorageRegions)
+		if ok {
+			return nil
+		}
+		items = append(items, residencyItem{
+			ProjectID:      t.ProjectID,
+			Topic:          t.Name,
+			StorageRegions: t.StorageRegions,
+			AllowedRegions: c.residency.Allowed(t.ProjectID),
+			Outside:        outside,
+		})
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get topics: %w", err)
+	}
+
+	switch c.Format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(items)
+	case "csv":
+		cw := csv.NewWriter(w)
+		_ = cw.Write([]string{"project_id", "topic", "storage_regions", "allowed_regions", "outside"})
+		for _, item := range items {
+			_ = cw.Write([]string{item.ProjectID, item.Topic, strings.Join(item.StorageRegions, ";"),
+				strings.Join(item.AllowedRegions, ";"), strings.Join(item.Outside, ";")})
+		}
+		cw.Flush()
+		return cw.Error()
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PROJECT\tTOPIC\tSTORAGE REGIONS\tALLOWED REGIONS\tOUTSIDE")
+	for _, item := range items {
+		stored, outside := strings.Join(item.StorageRegions, ", "), strings.Join(item.Outside, ", ")
+		if len(item.StorageRegions) == 0 {
+			stored, outside = "any", "unrestricted"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", item.ProjectID, item.Topic, stored, strings.Join(item.AllowedRegions, ", "), outside)
+	}
+	return tw.Flush()
+}
+
+type ReportFiltersCmd struct {
+	Projects []string `name:"project" help:"Only report topics in these projects" placeholder:"PROJECT_ID"`
+	Format   string   `help:"Output format" enum:"table,csv,json" default:"table"`
+	Output   string   `help:"Write to this file instead of stdout"`
+}
+
+// filteredTopic is a topic with subscribers that filter its messages
+type filteredTopic struct {
+	ProjectID     string               `json:"project_id"`
+	Topic         string               `json:"topic"`
+	Subscriptions int                  `json:"subscriptions"` // all subscriptions of the topic, filtered or not
+	Filtered      []filteredSubscriber `json:"filtered"`
+}
+
+// filteredSubscriber is a subscription receiving only the messages matching its filter
+type filteredSubscriber struct {
+	ProjectID    string `json:"project_id"`
+	Subscription string `json:"subscription"`
+	Filter       string `json:"filter"`
+}
+
+func (c *ReportFiltersCmd) Run(cli *CLI) error {
+	store, err := openStore()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = store.Close() }()
+
+	if c.Output == "" {
+		return c.report(cli.Context(), store, os.Stdout)
+	}
+	return writeFileAtomic(c.Output, func(w io.Writer) error {
+		return c.report(cli.Context(), store, w)
+	})
+}
+
+// report writes the topics with filtered subscriptions, and the filters, to w
+func (c *ReportFiltersCmd) report(ctx context.Context, store storage.Store, w io.Writer) error {
+	wanted := make(map[string]bool, len(c.Projects))
+	for _, p := range c.Projects {
+		wanted[p] = true
+	}
+
+	// Subscribers of a topic may live in any project, so every subscription is read, a page at a time
+	byTopic := make(map[string]*filteredTopic)
+	err := storage.EachSubscription(ctx, store, nil, func(sub *storage.Subscription) error {
+		project, topic := graph.ParseTopicReference(sub.TopicFullResourceName)
+		if topic == "" || (len(wanted) > 0 && !wanted[project]) {
+			return nil
+		}
+		t, ok := byTopic[sub.TopicFullResourceName]
+		if !ok {
+			t = &filteredTopic{ProjectID: project, Topic: topic, Filtered: []filteredSubscriber{}}
+			byTopic[sub.TopicFullResourceName] = t
+		}
+		t.Subscriptions++
+		if sub.Filter != "" {
+			t.Filtered = append(t.Filtered, filteredSubscriber{ProjectID: sub.ProjectID, Subscription: sub.Name, Filter: sub.Filter})
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get subscriptions: %w", err)
+	}
+
+	topics := []filteredTopic{}
+	for _, t := range byTopic {
+		if len(t.Filtered) > 0 {
+			topics = append(topics, *t)
+		}
+	}
+	sort.Slice(topics, func(i, j int) bool {
+		if topics[i].ProjectID != topics[j].ProjectID {
+			return topics[i].ProjectID < topics[j].ProjectID
+		}
+		return topics[i].Topic < topics[j].Topic
+	})
+
+	switch c.Format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(topics)
+	case "csv":
+		cw := csv.NewWriter(w)
+		_ = cw.Write([]string{"project_id", "topic", "subscriptions", "subscription_project_id", "subscription", "filter"})
+		for _, t := range topics {
+			for _, f := range t.Filtered {
+				_ = cw.Write([]string{t.ProjectID, t.Topic, strconv.Itoa(t.Subscriptions), f.ProjectID, f.Subscription, f.Filter})
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PROJECT\tTOPIC\tFILTERED\tSUBSCRIPTION\tFILTER")
+	for _, t := range topics {
+		for _, f := range t.Filtered {
+			fmt.Fprintf(tw, "%s\t%s\t%d of %d\t%s/%s\t%s\n", t.ProjectID, t.Topic, len(t.Filtered), t.Subscriptions, f.ProjectID, f.Subscription, f.Filter)
+		}
+	}
+	return tw.Flush()
+}
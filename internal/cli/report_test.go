@@ -0,0 +1,194 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/graph"
+	"github.com/NissesSenap/gcp-visualizer/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReportCrossProjectCmd(t *testing.T) {
+	store := setupListStore(t)
+	ctx := context.Background()
+	for _, sub := range []*storage.Subscription{
+		{Name: "orders-archive", ProjectID: "project-b", TopicFullResourceName: "projects/project-a/topics/orders-created", FullResourceName: "projects/project-b/subscriptions/orders-archive"},
+		{Name: "orders-local", ProjectID: "project-a", TopicFullResourceName: "projects/project-a/topics/orders-created", FullResourceName: "projects/project-a/subscriptions/orders-local"},
+		{Name: "users-sync", ProjectID: "project-c", TopicFullResourceName: "projects/project-b/topics/users", FullResourceName: "projects/project-c/subscriptions/users-sync"},
+		{Name: "orphan", ProjectID: "project-c", TopicFullResourceName: "_deleted-topic_", FullResourceName: "projects/project-c/subscriptions/orphan"},
+	} {
+		require.NoError(t, store.SaveSubscription(ctx, sub))
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, (&ReportCrossProjectCmd{Format: "table"}).report(ctx, store, &buf))
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 3)
+	assert.Equal(t, []string{"project-b", "project-a", "orders-created", "orders-archive,", "orders-email"}, strings.Fields(lines[1]))
+	assert.Equal(t, []string{"project-c", "project-b", "users", "users-sync"}, strings.Fields(lines[2]))
+
+	// The filter matches consumers and producers
+	buf.Reset()
+	require.NoError(t, (&ReportCrossProjectCmd{Projects: []string{"project-c"}, Format: "json"}).report(ctx, store, &buf))
+	var links []crossProjectLink
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &links))
+	assert.Equal(t, []crossProjectLink{
+		{ConsumerProject: "project-c", ProducerProject: "project-b", Topic: "users", Subscriptions: []string{"users-sync"}},
+	}, links)
+
+	buf.Reset()
+	require.NoError(t, (&ReportCrossProjectCmd{Projects: []string{"project-a"}, Format: "csv"}).report(ctx, store, &buf))
+	assert.Equal(t, "consumer_project,producer_project,topic,subscriptions\nproject-b,project-a,orders-created,orders-archive;orders-email\n", buf.String())
+}
+
+func TestReportOrphansCmd(t *testing.T) {
+	store := setupListStore(t)
+	ctx := context.Background()
+	require.NoError(t, store.SaveSubscription(ctx, &storage.Subscription{
+		Name: "detached", ProjectID: "project-b", TopicFullResourceName: "_deleted-topic_", FullResourceName: "projects/project-b/subscriptions/detached",
+	}))
+
+	var buf bytes.Buffer
+	require.NoError(t, (&ReportOrphansCmd{Format: "table"}).report(ctx, store, &buf))
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 3)
+	assert.Equal(t, []string{"subscription", "projects/project-b/subscriptions/detached", "project-b", "topic", "deleted"}, strings.Fields(lines[1]))
+	assert.Equal(t, []string{"topic", "projects/project-b/topics/users", "project-b", "no", "subscriptions"}, strings.Fields(lines[2]))
+
+	buf.Reset()
+	require.NoError(t, (&ReportOrphansCmd{Projects: []string{"project-a"}, Format: "json"}).report(ctx, store, &buf))
+	assert.JSONEq(t, `[]`, buf.String())
+}
+
+func TestReportUnmanagedCmd(t *testing.T) {
+	store := setupListStore(t)
+	ctx := context.Background()
+	state := filepath.Join(t.TempDir(), "terraform.tfstate")
+	require.NoError(t, os.WriteFile(state, []byte(`{"version": 4, "resources": [
+		{"mode": "managed", "type": "google_pubsub_topic", "name": "orders",
+		 "instances": [{"attributes": {"id": "projects/project-a/topics/orders-created"}}]}
+	]}`), 0o600))
+
+	var buf bytes.Buffer
+	require.NoError(t, (&ReportUnmanagedCmd{TerraformState: []string{state}, Format: "table"}).report(ctx, store, &buf))
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 3)
+	assert.Equal(t, []string{"subscription", "projects/project-b/subscriptions/orders-email", "project-b"}, strings.Fields(lines[1]))
+	assert.Equal(t, []string{"topic", "projects/project-b/topics/users", "project-b"}, strings.Fields(lines[2]))
+
+	buf.Reset()
+	require.NoError(t, (&ReportUnmanagedCmd{TerraformState: []string{state}, Projects: []string{"project-a"}, Format: "json"}).report(ctx, store, &buf))
+	assert.JSONEq(t, `[]`, buf.String())
+
+	assert.ErrorContains(t, (&ReportUnmanagedCmd{Format: "table"}).report(ctx, store, &buf), "--terraform-state")
+}
+
+func TestReportResidencyCmd(t *testing.T) {
+	store := setupListStore(t)
+	ctx := context.Background()
+	require.NoError(t, store.SaveTopic(ctx, &storage.Topic{
+		Name: "audit", ProjectID: "project-a", FullResourceName: "projects/project-a/topics/audit", StorageRegions: []string{"europe-west1", "us-east1"},
+	}))
+	require.NoError(t, store.SaveTopic(ctx, &storage.Topic{
+		Name: "payments", ProjectID: "project-a", FullResourceName: "projects/project-a/topics/payments", StorageRegions: []string{"europe-west1"},
+	}))
+	cmd := &ReportResidencyCmd{Format: "table", residency: graph.Residency{
+		AllowedRegions: []string{"europe-west1"},
+		Projects:       map[string][]string{"project-b": {}},
+	}}
+
+	var buf bytes.Buffer
+	require.NoError(t, cmd.report(ctx, store, &buf))
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 3)
+	assert.Equal(t, []string{"project-a", "audit", "europe-west1,", "us-east1", "europe-west1", "us-east1"}, strings.Fields(lines[1]))
+	assert.Equal(t, []string{"project-a", "orders-created", "any", "europe-west1", "unrestricted"}, strings.Fields(lines[2]))
+
+	buf.Reset()
+	cmd.Format = "json"
+	cmd.Projects = []string{"project-b"}
+	require.NoError(t, cmd.report(ctx, store, &buf))
+	assert.JSONEq(t, `[]`, buf.String(), "project-b has no allowed regions")
+
+	assert.ErrorContains(t, (&ReportResidencyCmd{Format: "table"}).report(ctx, store, &buf), "residency.allowed_regions")
+}
+
+func TestReportFiltersCmd(t *testing.T) {
+	store := setupListStore(t)
+	ctx := context.Background()
+	require.NoError(t, store.SaveSubscription(ctx, &storage.Subscription{
+		Name: "orders-eu", ProjectID: "project-a", TopicFullResourceName: "projects/project-a/topics/orders-created", FullResourceName: "projects/project-a/subscriptions/orders-eu",
+		Filter: `attributes.region = "eu"`,
+	}))
+	require.NoError(t, store.SaveSubscription(ctx, &storage.Subscription{
+		Name: "users-all", ProjectID: "project-b", TopicFullResourceName: "projects/project-b/topics/users", FullResourceName: "projects/project-b/subscriptions/users-all",
+	}))
+	cmd := &ReportFiltersCmd{Format: "table"}
+
+	var buf bytes.Buffer
+	require.NoError(t, cmd.report(ctx, store, &buf))
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2, "topics without filtered subscribers are left out")
+	assert.Equal(t, []string{"project-a", "orders-created", "1", "of", "2", "project-a/orders-eu", "attributes.region", "=", `"eu"`}, strings.Fields(lines[1]))
+
+	buf.Reset()
+	cmd.Format = "json"
+	require.NoError(t, cmd.report(ctx, store, &buf))
+	assert.JSONEq(t, `[{"project_id":"project-a","topic":"orders-created","subscriptions":2,"filtered":[
+		{"project_id":"project-a","subscription":"orders-eu","filter":"attributes.region = \"eu\""}]}]`, buf.String())
+
+	buf.Reset()
+	cmd.Projects = []string{"project-b"}
+	require.NoError(t, cmd.report(ctx, store, &buf))
+	assert.JSONEq(t, `[]`, buf.String())
+}
+
+func TestReportBacklogCmd(t *testing.T) {
+	store := setupListStore(t)
+	ctx := context.Background()
+	require.NoError(t, store.SaveSubscription(ctx, &storage.Subscription{
+		Name: "orders-archive", ProjectID: "project-b", TopicFullResourceName: "projects/project-a/topics/orders-created", FullResourceName: "projects/project-b/subscriptions/orders-archive",
+	}))
+	var metrics []*storage.ResourceMetric
+	for _, m := range []struct {
+		sub     string
+		backlog float64
+		age     float64
+	}{
+		{"orders-archive", 40000, 30},
+		{"orders-email", 12, 86400},
+	} {
+		name := "projects/project-b/subscriptions/" + m.sub
+		metrics = append(metrics,
+			&storage.ResourceMetric{FullResourceName: name, ProjectID: "project-b", Metric: storage.MetricSubscriptionBacklog, Value: m.backlog, Window: time.Hour},
+			&storage.ResourceMetric{FullResourceName: name, ProjectID: "project-b", Metric: storage.MetricSubscriptionUnackedAge, Value: m.age, Window: time.Hour})
+	}
+	require.NoError(t, store.SaveMetrics(ctx, metrics))
+	thresholds := graph.BacklogThresholds{MaxMessages: 10000, MaxUnackedAge: time.Hour}
+
+	var buf bytes.Buffer
+	require.NoError(t, (&ReportBacklogCmd{Format: "table", thresholds: thresholds}).report(ctx, store, &buf))
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 3)
+	assert.Equal(t, []string{"orders-email", "project-b", "12", "24h0m0s", "unacked", "too", "long"}, strings.Fields(lines[1]))
+	assert.Equal(t, []string{"orders-archive", "project-b", "40000", "30s", "backlog", "too", "large"}, strings.Fields(lines[2]))
+
+	buf.Reset()
+	require.NoError(t, (&ReportBacklogCmd{Top: 1, Format: "json", thresholds: thresholds}).report(ctx, store, &buf))
+	assert.JSONEq(t, `[{
+		"full_resource_name": "projects/project-b/subscriptions/orders-email",
+		"project_id": "project-b",
+		"topic": "projects/project-a/topics/orders-created",
+		"messages": 12,
+		"oldest_unacked_age_seconds": 86400,
+		"reasons": ["unacked too long"]
+	}]`, buf.String())
+}
@@ -0,0 +1,152 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/storage"
+)
+
+type RunsCmd struct {
+	List RunsListCmd `cmd:"list" help:"List the latest scans, newest first"`
+	Show RunsShowCmd `cmd:"show" help:"Show a single scan and the changes it made"`
+}
+
+type RunsListCmd struct {
+	Limit int  `help:"Number of scans to list, 0 lists every scan" default:"20"`
+	JSON  bool `name:"json" help:"Output as JSON"`
+}
+
+type RunsShowCmd struct {
+	RunID string `arg:"" help:"Run ID of the scan, as printed by scan and listed by 'runs list'"`
+	JSON  bool   `name:"json" help:"Output as JSON"`
+}
+
+// runItem is a single scan in JSON output
+type runItem struct {
+	RunID               string         `json:"run_id"`
+	StartedAt           time.Time      `json:"started_at"`
+	FinishedAt          time.Time      `json:"finished_at"`
+	ProjectsAttempted   int            `json:"projects_attempted"`
+	ProjectsSucceeded   int            `json:"projects_succeeded"`
+	ProjectsFailed      int            `json:"projects_failed"`
+	ProjectsSkipped     int            `json:"projects_skipped"`
+	ProjectsInterrupted int            `json:"projects_interrupted"`
+	Topics              int            `json:"topics"`
+	Subscriptions       int            `json:"subscriptions"`
+	APICalls            int64          `json:"api_calls"`
+	Version             string         `json:"version"`
+	Changes             map[string]int `json:"changes,omitempty"` // by change type, only shown for a single scan
+}
+
+func newRunItem(run *storage.ScanRun) runItem {
+	return runItem{
+		RunID:               run.RunID,
+		StartedAt:           run.StartedAt,
+		FinishedAt:          run.FinishedAt,
+		ProjectsAttempted:   run.ProjectsAttempted,
+		ProjectsSucceeded:   run.ProjectsSucceeded,
+		ProjectsFailed:      run.ProjectsFailed,
+		ProjectsSkipped:     run.ProjectsSkipped,
+		ProjectsInterrupted: run.ProjectsInterrupted,
+		Topics:              run.Topics,
+		Subscriptions:       run.Subscriptions,
+		APICalls:            run.APICalls,
+		Version:             run.Version,
+	}
+}
+
+func (c *RunsListCmd) Run(cli *CLI) error {
+	store, err := openStore()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = store.Close() }()
+
+	return c.list(cli.Context(), store, os.Stdout)
+}
+
+// list writes the latest c.Limit scans to w
+func (c *RunsListCmd) list(ctx context.Context, store storage.Store, w io.Writer) error {
+	runs, err := store.GetScanRuns(ctx, c.Limit)
+	if err != nil {
+		return fmt.Errorf("failed to get scan runs: %w", err)
+	}
+
+	if c.JSON {
+		items := make([]runItem, 0, len(runs))
+		for _, run := range runs {
+			items = append(items, newRunItem(run))
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(items)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "STARTED\tDURATION\tPROJECTS\tSUCCEEDED\tFAILED\tSKIPPED\tINTERRUPTED\tTOPICS\tSUBSCRIPTIONS\tAPI CALLS\tRUN")
+	for _, run := range runs {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%d\t%d\t%d\t%d\t%d\t%d\t%s\n", run.StartedAt.Local().Format(time.DateTime),
+			run.FinishedAt.Sub(run.StartedAt).Round(time.Second), run.ProjectsAttempted, run.ProjectsSucceeded,
+			run.ProjectsFailed, run.ProjectsSkipped, run.ProjectsInterrupted, run.Topics, run.Subscriptions, run.APICalls, run.RunID)
+	}
+	return tw.Flush()
+}
+
+func (c *RunsShowCmd) Run(cli *CLI) error {
+	store, err := openStore()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = store.Close() }()
+
+	return c.show(cli.Context(), store, os.Stdout)
+}
+
+// show writes the scan c.RunID and the number of changes it recorded to w
+func (c *RunsShowCmd) show(ctx context.Context, store storage.Store, w io.Writer) error {
+	run, err := store.GetScanRun(ctx, c.RunID)
+	if errors.Is(err, storage.ErrScanRunNotFound) {
+		return fmt.Errorf("no scan with run ID %s, see 'runs list'", c.RunID)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get scan run: %w", err)
+	}
+
+	changes, err := store.GetChanges(ctx, run.StartedAt, nil)
+	if err != nil {
+		return fmt.Errorf("failed to get changes: %w", err)
+	}
+	item := newRunItem(run)
+	item.Changes = make(map[string]int)
+	for _, change := range changes {
+		if change.RunID == run.RunID {
+			item.Changes[change.ChangeType]++
+		}
+	}
+
+	if c.JSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(item)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Run:\t%s\n", run.RunID)
+	fmt.Fprintf(tw, "Version:\t%s\n", orDash(run.Version))
+	fmt.Fprintf(tw, "Started:\t%s\n", run.StartedAt.Local().Format(time.DateTime))
+	fmt.Fprintf(tw, "Finished:\t%s (%s)\n", run.FinishedAt.Local().Format(time.DateTime), run.FinishedAt.Sub(run.StartedAt).Round(time.Second))
+	fmt.Fprintf(tw, "Projects:\t%d attempted, %d succeeded, %d failed, %d skipped, %d interrupted\n",
+		run.ProjectsAttempted, run.ProjectsSucceeded, run.ProjectsFailed, run.ProjectsSkipped, run.ProjectsInterrupted)
+	fmt.Fprintf(tw, "Resources:\t%d topics, %d subscriptions\n", run.Topics, run.Subscriptions)
+	fmt.Fprintf(tw, "API calls:\t%d\n", run.APICalls)
+	fmt.Fprintf(tw, "Changes:\t%d created, %d updated, %d deleted\n",
+		item.Changes[storage.ChangeTypeCreated], item.Changes[storage.ChangeTypeUpdated], item.Changes[storage.ChangeTypeDeleted])
+	return tw.Flush()
+}
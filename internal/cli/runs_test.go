@@ -0,0 +1,45 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunsCmd(t *testing.T) {
+	store := setupListStore(t)
+	started := time.Now().Add(-time.Minute)
+	ctx := storage.WithRunID(context.Background(), "run-1")
+	require.NoError(t, store.DeleteTopic(ctx, "projects/project-b/topics/users"))
+
+	outcome := scanOutcome{failures: []scanFailure{{Project: "project-b", Err: errors.New("permission denied")}}}
+	require.NoError(t, recordScanRun(ctx, store, "run-1", started, time.Now(), []string{"project-a", "project-b"}, outcome))
+
+	var buf bytes.Buffer
+	require.NoError(t, (&RunsListCmd{Limit: 20}).list(context.Background(), store, &buf))
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2, "header and one line per scan")
+	fields := strings.Fields(lines[1])
+	assert.Equal(t, "run-1", fields[len(fields)-1])
+
+	buf.Reset()
+	require.NoError(t, (&RunsShowCmd{RunID: "run-1", JSON: true}).show(context.Background(), store, &buf))
+	var item runItem
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &item))
+	assert.Equal(t, 2, item.ProjectsAttempted)
+	assert.Equal(t, 1, item.ProjectsSucceeded)
+	assert.Equal(t, 1, item.ProjectsFailed)
+	assert.Equal(t, Version, item.Version)
+	assert.Equal(t, map[string]int{storage.ChangeTypeDeleted: 1}, item.Changes)
+
+	err := (&RunsShowCmd{RunID: "run-2"}).show(context.Background(), store, &buf)
+	assert.ErrorContains(t, err, "no scan with run ID run-2")
+}
@@ -0,0 +1,792 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/auth"
+	"github.com/NissesSenap/gcp-visualizer/internal/cmdb"
+	"github.com/NissesSenap/gcp-visualizer/internal/collector"
+	"github.com/NissesSenap/gcp-visualizer/internal/config"
+	"github.com/NissesSenap/gcp-visualizer/internal/demo"
+	"github.com/NissesSenap/gcp-visualizer/internal/metrics"
+	"github.com/NissesSenap/gcp-visualizer/internal/notify"
+	"github.com/NissesSenap/gcp-visualizer/internal/storage"
+	"github.com/google/uuid"
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+)
+
+func (c *ScanCmd) Run(cli *CLI) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if err := collector.ValidateCollectorNames(cfg.Collectors); err != nil {
+		return err
+	}
+
+	// Determine projects
+	projects := c.Projects
+	if len(projects) == 0 && c.Demo {
+		projects = demo.Projects()
+	}
+	if len(projects) == 0 {
+		// Explicit --projects are scanned as given, the config's list is scoped by the patterns
+		projects, err = cfg.FilterProjects(cfg.Projects)
+		if err != nil {
+			return err
+		}
+		if len(projects) == 0 && len(cfg.Projects) > 0 {
+			return fmt.Errorf("all %d configured projects are excluded by projects_include/projects_exclude", len(cfg.Projects))
+		}
+	}
+	if len(projects) == 0 {
+		return fmt.Errorf("no projects specified, pass --projects or set projects in the config")
+	}
+
+	// Projects outside their scan window wait for a scan inside it
+	if !c.IgnoreWindows && !c.Demo {
+		var deferred []string
+		projects, deferred, err = cfg.SplitByScanWindow(projects, time.Now())
+		if err != nil {
+			return err
+		}
+		if len(deferred) > 0 {
+			fmt.Printf("Deferred %d projects outside their scan window: %s\n", len(deferred), strings.Join(deferred, ", "))
+		}
+		if len(projects) == 0 {
+			fmt.Println("Nothing to scan, every project is outside its scan window")
+			return nil
+		}
+	}
+
+	store, err := openStore()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = store.Close() }()
+
+	if c.Resume {
+		projects, err = resumeProjects(cli.Context(), store, projects)
+		if err != nil {
+			return err
+		}
+		if len(projects) == 0 {
+			fmt.Println("Nothing to resume, no project was interrupted")
+			return nil
+		}
+		fmt.Printf("Resuming %d interrupted projects: %s\n", len(projects), strings.Join(projects, ", "))
+	}
+
+	// Projects synced within cache.ttl_hours are fresh enough, --force rescans them. Resumed
+	// projects were interrupted, so they are scanned regardless.
+	if !c.Force && !c.Resume && cfg.Cache.TTLHours > 0 {
+		var fresh []string
+		projects, fresh, err = freshProjects(cli.Context(), store, projects, time.Duration(cfg.Cache.TTLHours)*time.Hour, time.Now())
+		if err != nil {
+			return err
+		}
+		if len(fresh) > 0 {
+			fmt.Printf("Skipped %d projects synced within cache.ttl_hours (%dh), rescan them with --force: %s\n",
+				len(fresh), cfg.Cache.TTLHours, strings.Join(fresh, ", "))
+		}
+		if len(projects) == 0 {
+			fmt.Println("Nothing to scan, every project was synced within cache.ttl_hours")
+			return nil
+		}
+	}
+
+	if c.DryRun {
+		return dryRun(cli.Context(), os.Stdout, store, projects, c.collectors(cfg), len(cfg.PubSubLite.Locations), cfg.RateLimits.RequestsPerSecond)
+	}
+
+	authOpts := auth.Options{
+		CredentialsFile: cfg.Auth.CredentialsFile,
+		Scopes:          cfg.Auth.Scopes,
+	}
+	if c.CredentialsFile != "" {
+		authOpts.CredentialsFile = c.CredentialsFile
+	}
+
+	scanMetrics := metrics.NewScanMetrics()
+	if c.MetricsListen != "" {
+		stop, err := serveMetrics(c.MetricsListen, scanMetrics)
+		if err != nil {
+			return err
+		}
+		defer stop()
+	}
+
+	newAPI := collector.NewPubSubAPIFactory(authOpts,
+		option.WithGRPCDialOption(grpc.WithChainUnaryInterceptor(scanMetrics.UnaryClientInterceptor())))
+	if c.Demo {
+		newAPI = demo.NewAPI
+	}
+	coll := collector.NewWithAPI(store, cfg.RateLimits.RequestsPerSecond, newAPI)
+	defer func() { _ = coll.Close() }()
+	coll.SetObserver(scanMetrics)
+	coll.SetReadOnly(cfg.ReadOnly)
+	coll.SetBatchSize(cfg.RateLimits.BatchSize)
+	coll.SetWriteTimeout(cfg.Storage.WriteTimeout)
+	coll.SetProjectTimeout(c.ProjectTimeout)
+	coll.SetKeepStale(c.KeepStale)
+	coll.SetIncremental(c.Incremental)
+	coll.SetAdaptiveRateLimit(cfg.RateLimits.Adaptive)
+	coll.SetClientLimits(cfg.RateLimits.MaxClients, cfg.RateLimits.ClientIdleTimeout)
+	coll.SetAPICallBudget(c.MaxAPICalls)
+	coll.SetRetryPolicy(collector.RetryPolicy{
+		MaxAttempts:    cfg.Retries.MaxAttempts,
+		InitialBackoff: cfg.Retries.InitialBackoff,
+		MaxBackoff:     cfg.Retries.MaxBackoff,
+		Jitter:         cfg.Retries.Jitter,
+	})
+
+	// The emulator and the demo have no Service Usage API to probe
+	if os.Getenv("PUBSUB_EMULATOR_HOST") == "" && !c.Demo {
+		check, err := collector.NewServiceUsageChecker(cli.Context(), authOpts)
+		if err != nil {
+			return err
+		}
+		coll.SetServiceChecker(check)
+	}
+
+	// Pub/Sub is registered by default, the other collectors need their own APIs
+	// and run only when listed in the config
+	enabled := func(name string) bool { return slices.Contains(cfg.Collectors, name) }
+	if !enabled(collector.CollectorPubSub) {
+		coll.Unregister(collector.CollectorPubSub)
+	}
+	if enabled(collector.CollectorCloudRun) && !c.Demo {
+		cloudRun, err := collector.NewCloudRunAPI(cli.Context(), authOpts)
+		if err != nil {
+			return err
+		}
+		coll.SetCloudRunAPI(cloudRun)
+	}
+	if enabled(collector.CollectorCloudFunctions) && !c.Demo {
+		functions, err := collector.NewCloudFunctionsAPI(cli.Context(), authOpts)
+		if err != nil {
+			return err
+		}
+		coll.SetCloudFunctionsAPI(functions)
+	}
+	if enabled(collector.CollectorDataflow) && !c.Demo {
+		jobs, err := collector.NewDataflowAPI(cli.Context(), authOpts)
+		if err != nil {
+			return err
+		}
+		coll.SetDataflowAPI(jobs)
+	}
+	if enabled(collector.CollectorPublishers) && !c.Demo {
+		logs, err := collector.NewAuditLogAPI(cli.Context(), authOpts, cfg.Publishers.MaxEntries)
+		if err != nil {
+			return err
+		}
+		coll.SetAuditLogAPI(logs, cfg.Publishers.Window)
+	}
+	if enabled(collector.CollectorMetrics) && !c.Demo {
+		series, err := collector.NewMonitoringAPI(cli.Context(), authOpts)
+		if err != nil {
+			return err
+		}
+		coll.SetMonitoringAPI(series, cfg.Metrics.Window, cfg.Metrics.Resolution)
+	}
+	if enabled(collector.CollectorProjects) && !c.Demo {
+		projectsAPI, err := collector.NewProjectsAPI(cli.Context(), authOpts)
+		if err != nil {
+			return err
+		}
+		coll.SetProjectsAPI(projectsAPI)
+	}
+	if enabled(collector.CollectorPubSubLite) && !c.Demo {
+		lite, err := collector.NewPubSubLiteAPI(cli.Context(), authOpts)
+		if err != nil {
+			return err
+		}
+		coll.SetPubSubLiteAPI(lite, cfg.PubSubLite.Locations)
+	}
+
+	runID := uuid.NewString()
+	ctx := storage.WithRunID(cli.Context(), runID)
+	fmt.Printf("Scanning %d projects (run %s)...\n", len(projects), runID)
+
+	// The cache before the scan is the baseline for the growth guardrail
+	started := time.Now()
+	before, err := metrics.Collect(ctx, store, projects, time.Now())
+	if err != nil {
+		return err
+	}
+
+	// The first SIGINT or SIGTERM stops launching projects, see collectProjects
+	signals, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
+
+	outcome := collectProjects(ctx, signals, coll, projects, cfg.RateLimits.MaxConcurrent, c.GracePeriod)
+	outcome.apiCalls = coll.APICalls()
+	failures, skipped := outcome.failures, outcome.skipped
+	failedProjects := len(failures)
+	fmt.Printf("Made %d API calls\n", outcome.apiCalls)
+
+	reportRolledBack(os.Stdout, failures)
+	if len(skipped) > 0 {
+		fmt.Printf("Skipped %d projects with the Pub/Sub API disabled: %s\n", len(skipped), strings.Join(skipped, ", "))
+	}
+
+	// A partial scan would trip the guardrails and push a partial inventory
+	interrupted := len(outcome.interrupted) > 0
+	if !interrupted {
+		if err := checkGuardrails(ctx, store, projects, before, cfg.Guardrails); err != nil {
+			failures = append(failures, scanFailure{Err: err})
+		}
+		if cfg.CMDB.URL != "" {
+			if err := pushInventoryChanges(ctx, store, runID, started, cfg.CMDB); err != nil {
+				failures = append(failures, scanFailure{Err: err})
+			}
+		}
+	}
+	if c.Pushgateway != "" {
+		if err := scanMetrics.Push(cli.Context(), c.Pushgateway); err != nil {
+			failures = append(failures, scanFailure{Err: err})
+		}
+	}
+	if err := recordScanRun(ctx, store, runID, started, time.Now(), projects, outcome); err != nil {
+		failures = append(failures, scanFailure{Err: err})
+	}
+	// Scheduled scans report to chat, an interrupted one is resumed and reported then
+	if !interrupted && (cfg.Notifications.SlackWebhookURL != "" || cfg.Notifications.WebhookURL != "") {
+		if err := notifyScan(ctx, store, cfg, runID, started, projects, failures); err != nil {
+			failures = append(failures, scanFailure{Err: err})
+		}
+	}
+
+	if c.ErrorsJSON != "" {
+		err := writeFileAtomic(c.ErrorsJSON, func(w io.Writer) error {
+			return writeErrorReport(w, runID, projects, skipped, failures)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if interrupted {
+		return interruptScan(ctx, os.Stdout, store, runID, len(projects), outcome)
+	}
+	if len(failures) > 0 {
+		errs := make([]error, 0, len(failures))
+		for _, f := range failures {
+			errs = append(errs, f.error())
+		}
+		return &scanError{
+			err:   fmt.Errorf("scan failed: %w", errors.Join(errs...)),
+			total: failedProjects > 0 && failedProjects == len(projects)-len(skipped),
+		}
+	}
+
+	fmt.Println("Scan complete!")
+	return nil
+}
+
+// projectCollector collects a single project, implemented by collector.Collector
+type projectCollector interface {
+	CollectProject(ctx context.Context, projectID string) error
+	ProjectAPICalls(projectID string) int64
+}
+
+// scanOutcome is the result of collecting the projects of a scan, each list sorted by project
+type scanOutcome struct {
+	failures    []scanFailure
+	skipped     []string // the Pub/Sub API is disabled
+	interrupted []string // cut short or never started because the scan was stopped
+	overBudget  bool     // stopped by the API call budget rather than a signal, see --max-api-calls
+	apiCalls    int64
+}
+
+// collectProjects collects projects on up to concurrency goroutines. Projects are collected
+// independently, a failing project doesn't stop the others. Once stopping is done, or a project
+// runs out of API call budget, no more projects are started, and those in flight get the grace
+// period to finish before they are cancelled.
+func collectProjects(ctx, stopping context.Context, coll projectCollector, projects []string, concurrency int, grace time.Duration) scanOutcome {
+	stopping, stop := context.WithCancel(stopping)
+	defer stop()
+	collectCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-stopping.Done():
+		case <-collectCtx.Done():
+			return
+		}
+		// Stopped on the way out, once every project finished
+		if collectCtx.Err() != nil {
+			return
+		}
+		fmt.Printf("Stopping, waiting up to %s for the projects in flight...\n", grace)
+		timer := time.NewTimer(grace)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+			cancel()
+		case <-collectCtx.Done():
+		}
+	}()
+
+	var (
+		mu      sync.Mutex
+		outcome scanOutcome
+	)
+	var g errgroup.Group
+	g.SetLimit(max(concurrency, 1))
+	for _, project := range projects {
+		g.Go(func() error {
+			if stopping.Err() != nil {
+				mu.Lock()
+				outcome.interrupted = append(outcome.interrupted, project)
+				mu.Unlock()
+				return nil
+			}
+
+			err := coll.CollectProject(collectCtx, project)
+			switch {
+			case errors.Is(err, collector.ErrBudgetExhausted):
+				// The projects in flight run out of budget too, they are resumed with the rest
+				mu.Lock()
+				if !outcome.overBudget {
+					fmt.Println("API call budget exhausted, not starting the remaining projects")
+				}
+				outcome.overBudget = true
+				outcome.interrupted = append(outcome.interrupted, project)
+				mu.Unlock()
+				stop()
+				fmt.Printf("Interrupted %s: %v\n", project, err)
+				return nil
+			case errors.Is(err, collector.ErrAPIDisabled):
+				mu.Lock()
+				outcome.skipped = append(outcome.skipped, project)
+				mu.Unlock()
+				fmt.Printf("Skipped %s: %v\n", project, err)
+				return nil
+			case err != nil && ctx.Err() == nil && (collectCtx.Err() != nil || errors.Is(err, context.Canceled)):
+				// Cut short by the grace period rather than failed. Projects failing for other
+				// reasons after the stop, e.g. a denied permission, are failures like any other.
+				mu.Lock()
+				outcome.interrupted = append(outcome.interrupted, project)
+				mu.Unlock()
+				fmt.Printf("Interrupted %s: %v\n", project, err)
+				return nil
+			case err != nil:
+				mu.Lock()
+				outcome.failures = append(outcome.failures, scanFailure{Project: project, Err: err})
+				mu.Unlock()
+				return nil
+			}
+			fmt.Printf("Scanned %s (%d API calls)\n", project, coll.ProjectAPICalls(project))
+			return nil
+		})
+	}
+	_ = g.Wait()
+
+	sort.Slice(outcome.failures, func(i, j int) bool { return outcome.failures[i].Project < outcome.failures[j].Project })
+	sort.Strings(outcome.skipped)
+	sort.Strings(outcome.interrupted)
+	return outcome
+}
+
+// interruptScan marks the projects a stopped scan didn't complete as interrupted, so
+// 'scan --resume' picks them up, and prints how far the scan got. Every write of the
+// projects in flight has finished, completed or rolled back, by the time it is called.
+func interruptScan(ctx context.Context, w io.Writer, store storage.Store, runID string, total int, outcome scanOutcome) error {
+	// The marks must be written even if ctx was cancelled
+	ctx = context.WithoutCancel(ctx)
+	for _, project := range outcome.interrupted {
+		if err := store.SetProjectStatus(ctx, project, storage.ProjectStatusInterrupted); err != nil {
+			return fmt.Errorf("failed to mark project %s as interrupted: %w", project, err)
+		}
+	}
+
+	completed := total - len(outcome.interrupted) - len(outcome.failures) - len(outcome.skipped)
+	stopped := "interrupted"
+	if outcome.overBudget {
+		stopped = fmt.Sprintf("stopped by the API call budget after %d calls", outcome.apiCalls)
+	}
+	fmt.Fprintf(w, "Scan %s %s: %d of %d projects completed, %d failed, %d skipped\n",
+		runID, stopped, completed, total, len(outcome.failures), len(outcome.skipped))
+	fmt.Fprintf(w, "Not completed: %s\n", strings.Join(outcome.interrupted, ", "))
+	// Failed projects aren't resumed, they need fixing first
+	errs := make([]error, 0, len(outcome.failures)+1)
+	for _, f := range outcome.failures {
+		fmt.Fprintf(w, "Failed %s: %v\n", f.Project, f.Err)
+		errs = append(errs, f.error())
+	}
+	fmt.Fprintln(w, "Resume with: gcp-visualizer scan --resume")
+	if outcome.overBudget {
+		return &scanError{err: errors.Join(append([]error{errScanOverBudget}, errs...)...)}
+	}
+	return &scanError{err: errors.Join(append([]error{errScanInterrupted}, errs...)...), interrupted: true}
+}
+
+// recordScanRun writes the history entry of a scan, with the resources cached for its projects once it finished
+func recordScanRun(ctx context.Context, store storage.Store, runID string, started, finished time.Time, projects []string, outcome scanOutcome) error {
+	// Interrupted scans are recorded too
+	ctx = context.WithoutCancel(ctx)
+	inv, err := metrics.Collect(ctx, store, projects, finished)
+	if err != nil {
+		return err
+	}
+	failed := 0
+	for _, f := range outcome.failures {
+		if f.Project != "" {
+			failed++
+		}
+	}
+	run := &storage.ScanRun{
+		RunID:               runID,
+		StartedAt:           started,
+		FinishedAt:          finished,
+		ProjectsAttempted:   len(projects),
+		ProjectsSucceeded:   len(projects) - failed - len(outcome.skipped) - len(outcome.interrupted),
+		ProjectsFailed:      failed,
+		ProjectsSkipped:     len(outcome.skipped),
+		ProjectsInterrupted: len(outcome.interrupted),
+		APICalls:            outcome.apiCalls,
+		Version:             Version,
+	}
+	for _, p := range inv.Projects {
+		run.Topics += p.Topics
+		run.Subscriptions += p.Subscriptions
+	}
+	if err := store.SaveScanRun(ctx, run); err != nil {
+		return fmt.Errorf("failed to record scan run: %w", err)
+	}
+	return nil
+}
+
+// resumeProjects returns the projects, out of projects, that an interrupted scan didn't complete
+func resumeProjects(ctx context.Context, store storage.Store, projects []string) ([]string, error) {
+	statuses, err := store.GetProjectStatuses(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get project statuses: %w", err)
+	}
+	var resumed []string
+	for _, project := range projects {
+		if statuses[project] == storage.ProjectStatusInterrupted {
+			resumed = append(resumed, project)
+		}
+	}
+	return resumed, nil
+}
+
+// freshProjects splits projects into those to scan and those with a completed sync within ttl of now
+func freshProjects(ctx context.Context, store storage.Store, projects []string, ttl time.Duration, now time.Time) (stale, fresh []string, err error) {
+	// Only completed syncs count, saving a resource, e.g. from an audit log event, doesn't make a project fresh
+	history, err := store.GetProjectSyncHistory(ctx, now.Add(-ttl))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get project sync history: %w", err)
+	}
+	for _, project := range projects {
+		if len(history[project]) > 0 {
+			fresh = append(fresh, project)
+		} else {
+			stale = append(stale, project)
+		}
+	}
+	return stale, fresh, nil
+}
+
+// errScanInterrupted is returned by a scan stopped by SIGINT or SIGTERM
+var errScanInterrupted = errors.New("scan interrupted, resume it with 'scan --resume'")
+
+// errScanOverBudget is returned by a scan stopped by --max-api-calls
+var errScanOverBudget = errors.New("scan stopped by the API call budget, resume it with 'scan --resume'")
+
+// scanFailure is an error of a scan, of a single project or of a step after collection
+type scanFailure struct {
+	Project string // empty for errors outside of collecting a project
+	Err     error
+}
+
+func (f scanFailure) error() error {
+	if f.Project == "" {
+		return f.Err
+	}
+	return fmt.Errorf("project %s: %w", f.Project, f.Err)
+}
+
+// Exit codes of a failed scan, see scanError
+const (
+	exitScanFailed  = 1 // every project failed
+	exitScanPartial = 2 // some projects or the steps after collection failed
+
+	exitScanInterrupted = 130 // stopped by a signal, as shells report SIGINT
+)
+
+// scanError is returned by a scan that didn't fully succeed. Kong exits with its ExitCode,
+// so CI pipelines can tell a partial failure from a total one.
+type scanError struct {
+	err         error
+	total       bool // no project was collected
+	interrupted bool // stopped by a signal before every project was collected
+}
+
+func (e *scanError) Error() string { return e.err.Error() }
+func (e *scanError) Unwrap() error { return e.err }
+
+func (e *scanError) ExitCode() int {
+	if e.interrupted {
+		return exitScanInterrupted
+	}
+	if e.total {
+		return exitScanFailed
+	}
+	return exitScanPartial
+}
+
+// errorReport is the --errors-json report of a scan
+type errorReport struct {
+	RunID    string            `json:"run_id"`
+	Projects int               `json:"projects"` // scanned, including the failed and skipped ones
+	Failed   int               `json:"failed"`   // projects that failed
+	Errors   []errorReportItem `json:"errors"`
+}
+
+// errorReportItem is a single error of an errorReport
+type errorReportItem struct {
+	Project string `json:"project,omitempty"` // empty for errors after collection, e.g. the CMDB push
+	Class   string `json:"class"`             // one of the collector.ErrorClass constants
+	Skipped bool   `json:"skipped,omitempty"` // the project was skipped rather than failed
+	Error   string `json:"error"`
+}
+
+// writeErrorReport writes the failures and skipped projects of a scan to w as JSON, classified
+// so CI pipelines can react to e.g. missing permissions differently from exhausted quota
+func writeErrorReport(w io.Writer, runID string, projects, skipped []string, failures []scanFailure) error {
+	report := errorReport{RunID: runID, Projects: len(projects), Errors: []errorReportItem{}}
+	for _, project := range skipped {
+		report.Errors = append(report.Errors, errorReportItem{
+			Project: project,
+			Class:   collector.ErrorClassAPIDisabled,
+			Skipped: true,
+			Error:   collector.ErrAPIDisabled.Error(),
+		})
+	}
+	for _, f := range failures {
+		if f.Project != "" {
+			report.Failed++
+		}
+		report.Errors = append(report.Errors, errorReportItem{
+			Project: f.Project,
+			Class:   collector.ClassifyError(f.Err),
+			Error:   f.Err.Error(),
+		})
+	}
+	// By project, the errors after collection last
+	sort.SliceStable(report.Errors, func(i, j int) bool {
+		a, b := report.Errors[i].Project, report.Errors[j].Project
+		if a == "" || b == "" {
+			return a != "" && b == ""
+		}
+		return a < b
+	})
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
+
+// collectors returns the collectors the scan runs, in the order they run.
+// Only Pub/Sub has a demo inventory.
+func (c *ScanCmd) collectors(cfg *config.Config) []string {
+	var names []string
+	for _, name := range collector.CollectorNames() {
+		if !slices.Contains(cfg.Collectors, name) || (c.Demo && name != collector.CollectorPubSub) {
+			continue
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+// dryRun prints the projects a scan would collect with the given collectors, and an estimate
+// of its API requests and duration from the resources cached by the previous scans and the
+// number of locations the pubsublite collector lists
+func dryRun(ctx context.Context, w io.Writer, store storage.Store, projects, collectors []string, liteLocations int, requestsPerSecond float64) error {
+	inv, err := metrics.Collect(ctx, store, projects, time.Now())
+	if err != nil {
+		return err
+	}
+	cached := make(map[string]*metrics.ProjectInventory, len(inv.Projects))
+	for _, p := range inv.Projects {
+		cached[p.Project] = p
+	}
+	jobs, err := store.GetAllDataflowJobs(ctx, projects)
+	if err != nil {
+		return fmt.Errorf("failed to get dataflow jobs: %w", err)
+	}
+	jobCounts := make(map[string]int)
+	for _, job := range jobs {
+		jobCounts[job.ProjectID]++
+	}
+
+	fmt.Fprintf(w, "Dry run, nothing is collected. Collectors: %s\n\n", strings.Join(collectors, ", "))
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PROJECT\tLAST SCAN\tTOPICS\tSUBSCRIPTIONS\tREQUESTS")
+	var total, unscanned int
+	for _, project := range projects {
+		p, ok := cached[project]
+		if !ok {
+			p = &metrics.ProjectInventory{Project: project}
+		}
+		requests := 0
+		for _, n := range collector.EstimateRequests(collectors, collector.ResourceCounts{
+			Topics:        p.Topics,
+			Subscriptions: p.Subscriptions,
+			DataflowJobs:  jobCounts[project],
+			LiteLocations: liteLocations,
+		}) {
+			requests += n
+		}
+		total += requests
+
+		lastScan := "never"
+		if !p.LastSynced.IsZero() {
+			lastScan = p.LastSynced.Local().Format(time.DateTime)
+		} else {
+			unscanned++
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%d\n", project, lastScan, p.Topics, p.Subscriptions, requests)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "\nAbout %d API requests", total)
+	if requestsPerSecond > 0 {
+		duration := time.Duration(float64(total) / requestsPerSecond * float64(time.Second))
+		fmt.Fprintf(w, ", taking at least %s at %g requests per second", duration.Round(time.Second), requestsPerSecond)
+	}
+	fmt.Fprintln(w)
+	if unscanned > 0 {
+		fmt.Fprintf(w, "%d projects have never been scanned, their resources aren't counted\n", unscanned)
+	}
+	return nil
+}
+
+// reportRolledBack summarizes the batches of the failed projects that were rolled back,
+// since a cancelled or timed out scan leaves those resources as they were before it
+func reportRolledBack(w io.Writer, failures []scanFailure) {
+	var batches, resources int
+	for _, f := range failures {
+		for _, batch := range collector.RolledBack(f.Err) {
+			batches++
+			resources += batch.Size
+		}
+	}
+	if batches > 0 {
+		fmt.Fprintf(w, "Rolled back %d partial batches (%d resources), they keep their previously cached state until the next scan\n", batches, resources)
+	}
+}
+
+// checkGuardrails warns about the scanned projects exceeding the configured guardrails,
+// and posts the warnings to the notify URL if one is set
+func checkGuardrails(ctx context.Context, store storage.Store, projects []string, before *metrics.Inventory, cfg config.Guardrails) error {
+	after, err := metrics.Collect(ctx, store, projects, time.Now())
+	if err != nil {
+		return err
+	}
+
+	guardrails := metrics.Guardrails{
+		MaxTopicsPerProject:        cfg.MaxTopicsPerProject,
+		MaxSubscriptionsPerProject: cfg.MaxSubscriptionsPerProject,
+		MaxGrowthPercent:           cfg.MaxGrowthPercent,
+	}
+	warnings := guardrails.Check(before, after)
+	for _, w := range warnings {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", w)
+	}
+	if len(warnings) == 0 || cfg.NotifyURL == "" {
+		return nil
+	}
+	return metrics.NotifyGuardrails(ctx, cfg.NotifyURL, warnings, time.Now())
+}
+
+// pushInventoryChanges sends the topics and subscriptions created or removed by the scan
+// to the CMDB webhook, so the asset inventory follows the cache
+func pushInventoryChanges(ctx context.Context, store storage.Store, runID string, started time.Time, cfg config.CMDB) error {
+	records, err := cmdb.Reconcile(ctx, store, runID, started, cmdb.Classes{
+		Topic:        cfg.TopicClass,
+		Subscription: cfg.SubscriptionClass,
+	})
+	if err != nil {
+		return err
+	}
+	if len(records) == 0 {
+		return nil
+	}
+	if err := cmdb.Push(ctx, cfg.URL, cfg.Token, records); err != nil {
+		return err
+	}
+	fmt.Printf("Pushed %d inventory changes to the CMDB\n", len(records))
+	return nil
+}
+
+// notifyScan posts the summary of the scan, with its failed projects and the lint findings in the
+// scanned projects, to the Slack and generic webhooks of the config
+func notifyScan(ctx context.Context, store storage.Store, cfg *config.Config, runID string, started time.Time, projects []string, failures []scanFailure) error {
+	summary, err := notify.NewSummary(ctx, store, runID, started, time.Now(), len(projects))
+	if err != nil {
+		return err
+	}
+	for _, f := range failures {
+		if f.Project != "" {
+			summary.Failed = append(summary.Failed, notify.Failure{ProjectID: f.Project, Error: f.Err.Error()})
+		}
+	}
+	violations, err := (&LintCmd{Projects: projects}).findings(ctx, store, cfg)
+	if err != nil {
+		return err
+	}
+	summary.Violations = append(summary.Violations, violations...)
+	if cfg.Notifications.OnlyChanges && summary.Empty() {
+		return nil
+	}
+
+	var errs []error
+	if url := cfg.Notifications.SlackWebhookURL; url != "" {
+		errs = append(errs, notify.Slack(ctx, url, summary))
+	}
+	if url := cfg.Notifications.WebhookURL; url != "" {
+		errs = append(errs, notify.Webhook(ctx, url, summary))
+	}
+	return errors.Join(errs...)
+}
+
+// serveMetrics serves m on addr under /metrics until stop is called.
+// The address is bound up front so a port conflict fails the scan before it starts.
+func serveMetrics(addr string, m *metrics.ScanMetrics) (stop func(), err error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", m)
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() { _ = srv.Serve(ln) }()
+
+	return func() { _ = srv.Close() }, nil
+}
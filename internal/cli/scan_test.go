@@ -0,0 +1,244 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/collector"
+	"github.com/NissesSenap/gcp-visualizer/internal/config"
+	"github.com/NissesSenap/gcp-visualizer/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestScanCmd_Collectors(t *testing.T) {
+	cfg := &config.Config{Collectors: []string{collector.CollectorDataflow, collector.CollectorPubSub}}
+
+	assert.Equal(t, []string{collector.CollectorPubSub, collector.CollectorDataflow}, (&ScanCmd{}).collectors(cfg))
+	assert.Equal(t, []string{collector.CollectorPubSub}, (&ScanCmd{Demo: true}).collectors(cfg))
+}
+
+func TestDryRun(t *testing.T) {
+	store := setupListStore(t)
+	ctx := context.Background()
+	require.NoError(t, store.SaveDataflowJobs(ctx, []*storage.DataflowJob{
+		{Name: "enrich", ProjectID: "project-b", Region: "europe-west1", FullResourceName: "projects/project-b/locations/europe-west1/jobs/1"},
+	}))
+
+	var buf bytes.Buffer
+	collectors := []string{collector.CollectorPubSub, collector.CollectorDataflow}
+	require.NoError(t, dryRun(ctx, &buf, store, []string{"project-a", "project-b", "project-new"}, collectors, 0, 2))
+	out := buf.String()
+
+	assert.Contains(t, out, "Collectors: pubsub, dataflow")
+	lines := strings.Split(out, "\n")
+	// 1 enablement check, 2 per topic and subscription, 1 Dataflow listing and 1 per job
+	assert.Equal(t, "4", lastField(t, lines, "project-a"))
+	assert.Equal(t, "7", lastField(t, lines, "project-b"))
+	assert.Equal(t, "2", lastField(t, lines, "project-new"))
+	assert.Contains(t, out, "About 13 API requests, taking at least 7s at 2 requests per second")
+	assert.Contains(t, out, "1 projects have never been scanned")
+}
+
+// lastField returns the last column of the table row starting with project
+func lastField(t *testing.T, lines []string, project string) string {
+	t.Helper()
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) > 0 && fields[0] == project {
+			return fields[len(fields)-1]
+		}
+	}
+	t.Fatalf("no row for %s", project)
+	return ""
+}
+
+func TestWriteErrorReport(t *testing.T) {
+	failures := []scanFailure{
+		{Project: "project-b", Err: fmt.Errorf("failed to collect topics: %w", status.Error(codes.PermissionDenied, "denied"))},
+		{Project: "project-c", Err: status.Error(codes.ResourceExhausted, "quota")},
+		{Err: errors.New("failed to push to the CMDB")},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, writeErrorReport(&buf, "run-1", []string{"project-a", "project-b", "project-c", "project-d"}, []string{"project-a"}, failures))
+	assert.JSONEq(t, `{
+		"run_id": "run-1",
+		"projects": 4,
+		"failed": 2,
+		"errors": [
+			{"project": "project-a", "class": "api_disabled", "skipped": true, "error": "Pub/Sub API is disabled"},
+			{"project": "project-b", "class": "auth", "error": "failed to collect topics: rpc error: code = PermissionDenied desc = denied"},
+			{"project": "project-c", "class": "quota", "error": "rpc error: code = ResourceExhausted desc = quota"},
+			{"class": "other", "error": "failed to push to the CMDB"}
+		]
+	}`, buf.String())
+
+	buf.Reset()
+	require.NoError(t, writeErrorReport(&buf, "run-2", []string{"project-a"}, nil, nil))
+	assert.JSONEq(t, `{"run_id": "run-2", "projects": 1, "failed": 0, "errors": []}`, buf.String())
+}
+
+func TestExitCode(t *testing.T) {
+	assert.Equal(t, 0, ExitCode(nil))
+	assert.Equal(t, 1, ExitCode(errors.New("failed to load config")))
+	assert.Equal(t, 1, ExitCode(&scanError{err: errors.New("scan failed"), total: true}))
+	assert.Equal(t, 2, ExitCode(fmt.Errorf("wrapped: %w", &scanError{err: errors.New("scan failed")})))
+}
+
+// blockingCollector collects every project instantly except block, which waits for release or its context
+type blockingCollector struct {
+	block   string
+	started chan struct{}
+	release chan struct{}
+	err     error // returned by block once released
+}
+
+func (b *blockingCollector) ProjectAPICalls(string) int64 { return 0 }
+
+func (b *blockingCollector) CollectProject(ctx context.Context, projectID string) error {
+	if projectID != b.block {
+		return nil
+	}
+	close(b.started)
+	select {
+	case <-b.release:
+		return b.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func TestCollectProjects_Stopped(t *testing.T) {
+	projects := []string{"project-a", "project-b", "project-c"}
+
+	tests := []struct {
+		name        string
+		grace       time.Duration
+		finish      bool  // the project in flight finishes within the grace period
+		err         error // of the project in flight once it finishes
+		failed      []string
+		interrupted []string
+	}{
+		{name: "in flight finishes", grace: time.Minute, finish: true, interrupted: []string{"project-b", "project-c"}},
+		{name: "grace period expires", grace: 10 * time.Millisecond, interrupted: projects},
+		{
+			name: "in flight fails", grace: time.Minute, finish: true,
+			err:    status.Error(codes.PermissionDenied, "caller does not have permission"),
+			failed: []string{"project-a"}, interrupted: []string{"project-b", "project-c"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			coll := &blockingCollector{block: "project-a", started: make(chan struct{}), release: make(chan struct{}), err: tt.err}
+			stopping, stop := context.WithCancel(context.Background())
+			go func() {
+				<-coll.started
+				stop()
+				if tt.finish {
+					close(coll.release)
+				}
+			}()
+
+			outcome := collectProjects(context.Background(), stopping, coll, projects, 1, tt.grace)
+			var failed []string
+			for _, f := range outcome.failures {
+				failed = append(failed, f.Project)
+			}
+			assert.Equal(t, tt.failed, failed)
+			assert.Equal(t, tt.interrupted, outcome.interrupted)
+		})
+	}
+}
+
+// budgetCollector runs out of API call budget at the project over
+type budgetCollector struct {
+	over string
+}
+
+func (b *budgetCollector) ProjectAPICalls(string) int64 { return 10 }
+
+func (b *budgetCollector) CollectProject(ctx context.Context, projectID string) error {
+	if projectID == b.over {
+		return fmt.Errorf("failed to collect topics: %w", collector.ErrBudgetExhausted)
+	}
+	return nil
+}
+
+func TestCollectProjects_OverBudget(t *testing.T) {
+	projects := []string{"project-a", "project-b", "project-c"}
+	outcome := collectProjects(context.Background(), context.Background(), &budgetCollector{over: "project-b"}, projects, 1, time.Minute)
+	assert.Empty(t, outcome.failures)
+	assert.True(t, outcome.overBudget)
+	assert.Equal(t, []string{"project-b", "project-c"}, outcome.interrupted)
+
+	store := setupListStore(t)
+	var buf bytes.Buffer
+	outcome.apiCalls = 20
+	err := interruptScan(context.Background(), &buf, store, "run-1", len(projects), outcome)
+	assert.ErrorIs(t, err, errScanOverBudget)
+	assert.Equal(t, exitScanPartial, ExitCode(err))
+	assert.Contains(t, buf.String(), "stopped by the API call budget after 20 calls: 1 of 3 projects completed")
+
+	resumed, err := resumeProjects(context.Background(), store, projects)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"project-b", "project-c"}, resumed)
+}
+
+func TestInterruptScan(t *testing.T) {
+	store := setupListStore(t)
+	ctx := context.Background()
+
+	var buf bytes.Buffer
+	outcome := scanOutcome{interrupted: []string{"project-b"}}
+	err := interruptScan(ctx, &buf, store, "run-1", 2, outcome)
+	assert.ErrorIs(t, err, errScanInterrupted)
+	assert.Equal(t, exitScanInterrupted, ExitCode(err))
+	assert.Contains(t, buf.String(), "1 of 2 projects completed")
+	assert.Contains(t, buf.String(), "scan --resume")
+
+	// Projects that failed while the scan stopped are reported, and not resumed
+	buf.Reset()
+	failure := scanFailure{Project: "project-a", Err: errors.New("permission denied")}
+	err = interruptScan(ctx, &buf, store, "run-1", 2, scanOutcome{interrupted: []string{"project-b"}, failures: []scanFailure{failure}})
+	assert.ErrorIs(t, err, errScanInterrupted)
+	assert.Contains(t, err.Error(), "project project-a: permission denied")
+	assert.Contains(t, buf.String(), "0 of 2 projects completed, 1 failed")
+	assert.Contains(t, buf.String(), "Failed project-a: permission denied")
+
+	resumed, err := resumeProjects(ctx, store, []string{"project-a", "project-b"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"project-b"}, resumed)
+
+	// A completed sync clears the mark
+	require.NoError(t, store.UpdateProjectSyncTime(ctx, "project-b"))
+	resumed, err = resumeProjects(ctx, store, []string{"project-a", "project-b"})
+	require.NoError(t, err)
+	assert.Empty(t, resumed)
+}
+
+func TestFreshProjects(t *testing.T) {
+	store := setupListStore(t)
+	ctx := context.Background()
+	// Saving resources alone, as setupListStore does, isn't a completed sync
+	require.NoError(t, store.UpdateProjectSyncTime(ctx, "project-a"))
+
+	stale, fresh, err := freshProjects(ctx, store, []string{"project-a", "project-b", "project-c"}, time.Hour, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"project-b", "project-c"}, stale)
+	assert.Equal(t, []string{"project-a"}, fresh)
+
+	// Once the TTL passed, the project is due again
+	stale, fresh, err = freshProjects(ctx, store, []string{"project-a"}, time.Hour, time.Now().Add(2*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"project-a"}, stale)
+	assert.Empty(t, fresh)
+}
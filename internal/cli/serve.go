@@ -0,0 +1,79 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/config"
+	"github.com/NissesSenap/gcp-visualizer/internal/renderer"
+	"github.com/NissesSenap/gcp-visualizer/internal/server"
+	"github.com/NissesSenap/gcp-visualizer/internal/snapshot"
+	"github.com/NissesSenap/gcp-visualizer/internal/storage"
+)
+
+type ServeCmd struct {
+	Listen     string `help:"Address to serve the topology viewer on" default:":8080"`
+	ViewsToken string `help:"Shared secret expected in the 'token' query parameter of view images" env:"GCP_VISUALIZER_VIEWS_TOKEN"`
+	Demo       bool   `help:"Serve the built-in demo inventory instead of the cache, no credentials or scan needed"`
+}
+
+func (c *ServeCmd) Run(cli *CLI) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	store, err := c.store(cli.Context())
+	if err != nil {
+		return err
+	}
+	defer func() { _ = store.Close() }()
+
+	theme, err := renderer.LoadTheme(cfg.Visualization.Theme)
+	if err != nil {
+		return err
+	}
+
+	srv := server.New(store)
+	srv.SetTheme(theme)
+	if len(cfg.Views) > 0 {
+		names := make([]string, 0, len(cfg.Views))
+		for name := range cfg.Views {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		srv.Handle("GET /views/", snapshot.NewHandler(store, names, renderView(cfg, theme), c.ViewsToken))
+	}
+
+	httpServer := &http.Server{
+		Addr:              c.Listen,
+		Handler:           srv,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	// Shut down when the CLI context is cancelled
+	ctx := cli.Context()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = httpServer.Shutdown(shutdownCtx)
+	}()
+
+	fmt.Printf("Serving the topology viewer on %s\n", c.Listen)
+	if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// store opens the cache, or with --demo an in-memory store holding the demo inventory
+func (c *ServeCmd) store(ctx context.Context) (storage.Store, error) {
+	if c.Demo {
+		return openDemoStore(ctx)
+	}
+	return openStore()
+}
@@ -0,0 +1,23 @@
+package cli
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServeCmd_Demo(t *testing.T) {
+	ctx := context.Background()
+	store, err := (&ServeCmd{Demo: true}).store(ctx)
+	require.NoError(t, err)
+	defer func() { _ = store.Close() }()
+
+	projects, err := store.GetAllProjects(ctx)
+	require.NoError(t, err)
+	assert.Contains(t, projects, "demo-shop")
+	topics, err := store.GetAllTopics(ctx, nil)
+	require.NoError(t, err)
+	assert.NotEmpty(t, topics)
+}
@@ -0,0 +1,97 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/metrics"
+	"github.com/NissesSenap/gcp-visualizer/internal/storage"
+)
+
+type StatsCmd struct {
+	Projects   []string `help:"Filter by projects"`
+	Prometheus bool     `help:"Write inventory gauges in the Prometheus text exposition format"`
+	Output     string   `help:"Write to this file instead of stdout, e.g. a node_exporter textfile collector path ending in .prom"`
+}
+
+func (c *StatsCmd) Run(cli *CLI) error {
+	store, err := openStore()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = store.Close() }()
+
+	if c.Output == "" {
+		return c.stats(cli.Context(), store, os.Stdout, time.Now())
+	}
+	return writeFileAtomic(c.Output, func(w io.Writer) error {
+		return c.stats(cli.Context(), store, w, time.Now())
+	})
+}
+
+// stats writes the inventory snapshot to w, as a table or in Prometheus format
+func (c *StatsCmd) stats(ctx context.Context, store storage.Store, w io.Writer, now time.Time) error {
+	inv, err := metrics.Collect(ctx, store, c.Projects, now)
+	if err != nil {
+		return err
+	}
+
+	if c.Prometheus {
+		return inv.WritePrometheus(w)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PROJECT\tTOPICS\tSUBSCRIPTIONS\tWITHOUT DLQ\tCROSS-PROJECT\tCACHE AGE\tSTATUS")
+	for _, p := range inv.Projects {
+		age := "never"
+		if !p.LastSynced.IsZero() {
+			age = now.Sub(p.LastSynced).Truncate(time.Second).String()
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t%s\t%s\n", p.Project, p.Topics, p.Subscriptions, p.SubscriptionsWithoutDLQ, p.CrossProjectEdges, age, projectStatus(p.Status))
+	}
+	return tw.Flush()
+}
+
+// projectStatus describes the status of a project stored by the last scan
+func projectStatus(status string) string {
+	switch status {
+	case "":
+		return "ok"
+	case storage.ProjectStatusAPIDisabled:
+		return "Pub/Sub API disabled"
+	case storage.ProjectStatusInterrupted:
+		return "scan interrupted"
+	default:
+		return status
+	}
+}
+
+// writeFileAtomic writes path through a temporary file in the same directory, so
+// readers such as the node_exporter textfile collector never see a partial file
+func writeFileAtomic(path string, write func(w io.Writer) error) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if err := write(tmp); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write output file: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return fmt.Errorf("failed to write output file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write output file: %w", err)
+	}
+	return nil
+}
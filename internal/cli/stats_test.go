@@ -0,0 +1,69 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatsCmd(t *testing.T) {
+	store := setupListStore(t)
+
+	var buf bytes.Buffer
+	cmd := &StatsCmd{}
+	require.NoError(t, cmd.stats(context.Background(), store, &buf, time.Now()))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 3, "header and one line per project")
+	assert.Contains(t, lines[0], "WITHOUT DLQ")
+	assert.True(t, strings.HasPrefix(lines[1], "project-a"))
+	assert.True(t, strings.HasPrefix(lines[2], "project-b"))
+	assert.True(t, strings.HasSuffix(lines[1], "ok"))
+}
+
+func TestStatsCmdPrometheusFile(t *testing.T) {
+	store := setupListStore(t)
+	output := filepath.Join(t.TempDir(), "gcp_visualizer.prom")
+
+	cmd := &StatsCmd{Prometheus: true, Output: output}
+	require.NoError(t, writeFileAtomic(output, func(w io.Writer) error {
+		return cmd.stats(context.Background(), store, w, time.Now())
+	}))
+
+	data, err := os.ReadFile(output)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `gcp_visualizer_cross_project_edges{project="project-b"} 1`)
+
+	entries, err := os.ReadDir(filepath.Dir(output))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "temporary file should be renamed into place")
+}
+
+func TestFreshnessCmd(t *testing.T) {
+	store := setupListStore(t)
+	require.NoError(t, store.UpdateProjectSyncTime(context.Background(), "project-a"))
+	thresholds := metrics.Thresholds{TTL: time.Hour, MaxAge: 24 * time.Hour}
+
+	var buf bytes.Buffer
+	cmd := &FreshnessCmd{Days: 7}
+	require.NoError(t, cmd.freshness(context.Background(), store, &buf, thresholds, time.Now()))
+	assert.Contains(t, buf.String(), "LAST 7 DAYS")
+	assert.Regexp(t, `project-a\s+fresh`, buf.String())
+
+	output := filepath.Join(t.TempDir(), "freshness.html")
+	cmd.Output = output
+	require.NoError(t, cmd.freshness(context.Background(), store, &buf, thresholds, time.Now()))
+	data, err := os.ReadFile(output)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `<td>project-a</td>`)
+	assert.Contains(t, string(data), `class="cell fresh"`)
+}
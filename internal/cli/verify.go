@@ -0,0 +1,69 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/storage"
+	"github.com/NissesSenap/gcp-visualizer/internal/verify"
+)
+
+type VerifyCmd struct {
+	File     string   `arg:"" help:"YAML declaration of the expected topics, their subscribers and the allowed cross-project links" type:"existingfile"`
+	Projects []string `help:"Only verify resources in these projects"`
+	Format   string   `help:"Output format" enum:"table,json" default:"table"`
+}
+
+func (c *VerifyCmd) Run(cli *CLI) error {
+	store, err := openStore()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = store.Close() }()
+
+	return c.verify(cli.Context(), store, os.Stdout)
+}
+
+// verify writes the differences between the declaration and the cache to w and returns an error if there were any
+func (c *VerifyCmd) verify(ctx context.Context, store storage.Store, w io.Writer) error {
+	declaration, err := verify.Load(c.File)
+	if err != nil {
+		return err
+	}
+	findings, err := verify.Verify(ctx, store, declaration, c.Projects)
+	if err != nil {
+		return err
+	}
+
+	if c.Format == "json" {
+		if findings == nil {
+			findings = []verify.Finding{}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(findings); err != nil {
+			return err
+		}
+	} else {
+		if len(findings) == 0 {
+			fmt.Fprintln(w, "The topology matches the declaration")
+			return nil
+		}
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "DIFFERENCE\tRESOURCE\tMESSAGE")
+		for _, f := range findings {
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", f.Kind, f.Resource, f.Message)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+	if len(findings) > 0 {
+		return fmt.Errorf("verify found %d difference(s) from %s", len(findings), c.File)
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyCmd(t *testing.T) {
+	store := setupListStore(t)
+	declaration := filepath.Join(t.TempDir(), "architecture.yaml")
+	require.NoError(t, os.WriteFile(declaration, []byte(`
+topics:
+  - name: projects/project-a/topics/orders-created
+    subscribers: [projects/project-b/subscriptions/orders-email]
+allowed_cross_project:
+  - from: project-b
+    to: project-a
+`), 0o600))
+
+	var buf bytes.Buffer
+	require.NoError(t, (&VerifyCmd{File: declaration, Format: "table"}).verify(context.Background(), store, &buf))
+	assert.Equal(t, "The topology matches the declaration\n", buf.String())
+
+	require.NoError(t, os.WriteFile(declaration, []byte(`
+topics:
+  - name: projects/project-a/topics/orders-created
+    require_dead_letter: true
+allowed_cross_project: []
+`), 0o600))
+
+	buf.Reset()
+	err := (&VerifyCmd{File: declaration, Format: "table"}).verify(context.Background(), store, &buf)
+	assert.ErrorContains(t, err, "verify found 3 difference(s)")
+	assert.Contains(t, buf.String(), "unexpected-subscription")
+	assert.Contains(t, buf.String(), "missing-dead-letter")
+	assert.Contains(t, buf.String(), "unexpected-cross-project")
+
+	buf.Reset()
+	err = (&VerifyCmd{File: declaration, Format: "json"}).verify(context.Background(), store, &buf)
+	assert.Error(t, err)
+	assert.Contains(t, buf.String(), `"kind": "missing-dead-letter"`)
+}
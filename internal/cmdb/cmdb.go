@@ -0,0 +1,142 @@
+package cmdb
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/send"
+	"github.com/NissesSenap/gcp-visualizer/internal/storage"
+)
+
+// Operations of a reconciliation record
+const (
+	OperationInsert = "insert"
+	OperationDelete = "delete"
+)
+
+// DiscoverySource identifies the records pushed by gcp-visualizer in the CMDB
+const DiscoverySource = "gcp-visualizer"
+
+// Classes are the CMDB configuration item classes of topics and subscriptions
+type Classes struct {
+	Topic        string
+	Subscription string
+}
+
+// Record is a configuration item created or removed by a scan, in the style of
+// a ServiceNow import set row. Custom columns carry the u_ prefix.
+type Record struct {
+	Operation       string            `json:"operation"` // OperationInsert or OperationDelete
+	Class           string            `json:"sys_class_name"`
+	Name            string            `json:"name"`
+	ObjectID        string            `json:"object_id"` // full resource name
+	ProjectID       string            `json:"u_project_id"`
+	Topic           string            `json:"u_topic,omitempty"` // full resource name of a subscription's topic
+	Labels          map[string]string `json:"u_labels,omitempty"`
+	RunID           string            `json:"u_run_id"`
+	DiscoverySource string            `json:"discovery_source"`
+	Discovered      time.Time         `json:"last_discovered"`
+}
+
+// Reconcile returns a record for every topic and subscription the scan runID
+// created or removed, as recorded in the changelog since the scan started.
+// Updates don't change the inventory and are left out.
+func Reconcile(ctx context.Context, store storage.Store, runID string, since time.Time, classes Classes) ([]Record, error) {
+	changes, err := store.GetChanges(ctx, since, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get changes: %w", err)
+	}
+
+	// The changelog has no topic for subscriptions, created ones are still in the cache
+	var projects []string
+	seen := make(map[string]bool)
+	for _, change := range changes {
+		if change.RunID == runID && !seen[change.ProjectID] {
+			seen[change.ProjectID] = true
+			projects = append(projects, change.ProjectID)
+		}
+	}
+	topics := make(map[string]string)
+	if len(projects) > 0 {
+		subs, err := store.GetAllSubscriptions(ctx, projects)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get subscriptions: %w", err)
+		}
+		for _, sub := range subs {
+			topics[sub.FullResourceName] = sub.TopicFullResourceName
+		}
+	}
+
+	var records []Record
+	for _, change := range changes {
+		if change.RunID != runID {
+			continue
+		}
+		record := Record{
+			Name:            resourceName(change.FullResourceName),
+			ObjectID:        change.FullResourceName,
+			ProjectID:       change.ProjectID,
+			RunID:           change.RunID,
+			DiscoverySource: DiscoverySource,
+			Discovered:      change.ChangedAt,
+		}
+		metadata := change.AfterMetadata
+		switch change.ChangeType {
+		case storage.ChangeTypeCreated:
+			record.Operation = OperationInsert
+		case storage.ChangeTypeDeleted:
+			record.Operation = OperationDelete
+			metadata = change.BeforeMetadata
+		default:
+			continue
+		}
+		switch change.ResourceType {
+		case storage.ResourceTypeTopic:
+			record.Class = classes.Topic
+		case storage.ResourceTypeSubscription:
+			record.Class = classes.Subscription
+			record.Topic = topics[change.FullResourceName]
+		}
+		record.Labels = labels(metadata)
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// Push posts the records to a CMDB webhook as {"records": [...]}, the body of
+// the ServiceNow import set API. A non-empty token is sent as a bearer token.
+func Push(ctx context.Context, url, token string, records []Record) error {
+	header := http.Header{"Accept": {"application/json"}}
+	if token != "" {
+		header.Set("Authorization", "Bearer "+token)
+	}
+	err := send.JSON(ctx, url, struct {
+		Records []Record `json:"records"`
+	}{records}, header)
+	if err != nil {
+		return fmt.Errorf("failed to push inventory changes: %w", err)
+	}
+	return nil
+}
+
+// resourceName returns the last segment of a full resource name
+func resourceName(fullResourceName string) string {
+	return fullResourceName[strings.LastIndex(fullResourceName, "/")+1:]
+}
+
+// labels returns the labels in resource metadata, nil if it has none
+func labels(metadata string) map[string]string {
+	var m struct {
+		Labels map[string]string `json:"labels"`
+	}
+	// Metadata is written by the collector, a resource without it just has no labels
+	_ = json.Unmarshal([]byte(metadata), &m)
+	if len(m.Labels) == 0 {
+		return nil
+	}
+	return m.Labels
+}
@@ -0,0 +1,90 @@
+package cmdb
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var classes = Classes{Topic: "topic_class", Subscription: "subscription_class"}
+
+func TestReconcile(t *testing.T) {
+	store, err := storage.NewSQLite(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = store.Close() })
+
+	// An earlier scan
+	previous := storage.WithRunID(context.Background(), "run-1")
+	require.NoError(t, store.SaveTopics(previous, []*storage.Topic{
+		{Name: "orders", ProjectID: "project-a", FullResourceName: "projects/project-a/topics/orders", Metadata: `{"labels":{}}`},
+		{Name: "legacy", ProjectID: "project-a", FullResourceName: "projects/project-a/topics/legacy", Metadata: `{"labels":{"team":"core"}}`},
+	}))
+
+	since := time.Now()
+	ctx := storage.WithRunID(context.Background(), "run-2")
+	require.NoError(t, store.SaveTopic(ctx, &storage.Topic{Name: "orders", ProjectID: "project-a", FullResourceName: "projects/project-a/topics/orders", Metadata: `{"labels":{"team":"checkout"}}`}))
+	require.NoError(t, store.SaveSubscription(ctx, &storage.Subscription{
+		Name: "orders-email", ProjectID: "project-b", TopicFullResourceName: "projects/project-a/topics/orders",
+		FullResourceName: "projects/project-b/subscriptions/orders-email", Metadata: `{"labels":{}}`,
+	}))
+	require.NoError(t, store.DeleteTopic(ctx, "projects/project-a/topics/legacy"))
+
+	// An incremental update during the scan
+	require.NoError(t, store.SaveTopic(context.Background(), &storage.Topic{Name: "users", ProjectID: "project-a", FullResourceName: "projects/project-a/topics/users"}))
+
+	records, err := Reconcile(ctx, store, "run-2", since, classes)
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+
+	assert.Equal(t, OperationInsert, records[0].Operation)
+	assert.Equal(t, "subscription_class", records[0].Class)
+	assert.Equal(t, "orders-email", records[0].Name)
+	assert.Equal(t, "projects/project-b/subscriptions/orders-email", records[0].ObjectID)
+	assert.Equal(t, "project-b", records[0].ProjectID)
+	assert.Equal(t, "projects/project-a/topics/orders", records[0].Topic)
+	assert.Nil(t, records[0].Labels)
+	assert.Equal(t, "run-2", records[0].RunID)
+	assert.Equal(t, DiscoverySource, records[0].DiscoverySource)
+
+	assert.Equal(t, OperationDelete, records[1].Operation)
+	assert.Equal(t, "topic_class", records[1].Class)
+	assert.Equal(t, "legacy", records[1].Name)
+	assert.Equal(t, map[string]string{"team": "core"}, records[1].Labels)
+}
+
+func TestPush(t *testing.T) {
+	var (
+		auth string
+		body struct {
+			Records []Record `json:"records"`
+		}
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+
+	records := []Record{{Operation: OperationInsert, Class: "topic_class", Name: "orders", ObjectID: "projects/project-a/topics/orders"}}
+	require.NoError(t, Push(context.Background(), srv.URL, "secret", records))
+	assert.Equal(t, "Bearer secret", auth)
+	require.Len(t, body.Records, 1)
+	assert.Equal(t, "orders", body.Records[0].Name)
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer failing.Close()
+	err := Push(context.Background(), failing.URL, "", records)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "403")
+}
@@ -0,0 +1,82 @@
+package collector
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// adaptiveInterval is the minimum time between two changes of the adaptive rate,
+	// so a burst of concurrent rate-limit errors halves the rate only once
+	adaptiveInterval = time.Second
+
+	// adaptiveSteps is the number of increases it takes to ramp from nothing to the configured rate
+	adaptiveSteps = 10
+
+	// adaptiveFloor is the lowest rate the adaptive limiter backs off to, in requests per second
+	adaptiveFloor = 0.1
+)
+
+// aimdLimiter adjusts a rate limiter with additive increase, multiplicative decrease:
+// every rate-limit error halves the rate, every interval without one raises it
+// by a tenth of the configured rate, up to the configured rate
+type aimdLimiter struct {
+	mu         sync.Mutex
+	limiter    *rate.Limiter
+	max        rate.Limit
+	lastChange time.Time
+	now        func() time.Time
+}
+
+func newAIMDLimiter(limiter *rate.Limiter) *aimdLimiter {
+	return &aimdLimiter{
+		limiter: limiter,
+		max:     limiter.Limit(),
+		now:     time.Now,
+	}
+}
+
+// observe adjusts the rate after an API call that returned err
+func (a *aimdLimiter) observe(err error) {
+	throttled := status.Code(err) == codes.ResourceExhausted
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := a.now()
+	if now.Sub(a.lastChange) < adaptiveInterval {
+		return
+	}
+
+	current := a.limiter.Limit()
+	switch {
+	case throttled:
+		a.limiter.SetLimitAt(now, max(current/2, adaptiveFloor))
+	case err == nil && current < a.max:
+		a.limiter.SetLimitAt(now, min(current+a.max/adaptiveSteps, a.max))
+	default:
+		return
+	}
+	a.lastChange = now
+}
+
+// SetAdaptiveRateLimit makes the collector halve its request rate whenever the API
+// reports RESOURCE_EXHAUSTED, and slowly ramp back up to the configured rate
+func (c *Collector) SetAdaptiveRateLimit(adaptive bool) {
+	if !adaptive {
+		c.adaptive = nil
+		return
+	}
+	c.adaptive = newAIMDLimiter(c.limiter)
+}
+
+// observeCall feeds the outcome of an API call to the adaptive rate limiter, if enabled
+func (c *Collector) observeCall(err error) {
+	if c.adaptive != nil {
+		c.adaptive.observe(err)
+	}
+}
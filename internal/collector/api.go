@@ -0,0 +1,102 @@
+package collector
+
+import (
+	"context"
+
+	"cloud.google.com/go/iam/apiv1/iampb"
+	"cloud.google.com/go/pubsub/v2"
+	"cloud.google.com/go/pubsub/v2/apiv1/pubsubpb"
+	"github.com/NissesSenap/gcp-visualizer/internal/auth"
+	"github.com/googleapis/gax-go/v2"
+	"google.golang.org/api/option"
+)
+
+// TopicIterator iterates over listed topics, returning iterator.Done at the end
+type TopicIterator interface {
+	Next() (*pubsubpb.Topic, error)
+}
+
+// SubscriptionIterator iterates over listed subscriptions, returning iterator.Done at the end
+type SubscriptionIterator interface {
+	Next() (*pubsubpb.Subscription, error)
+}
+
+// TopicLister lists the topics of a project and reads their IAM policies
+type TopicLister interface {
+	ListTopics(ctx context.Context, req *pubsubpb.ListTopicsRequest) TopicIterator
+	GetIamPolicy(ctx context.Context, req *iampb.GetIamPolicyRequest) (*iampb.Policy, error)
+}
+
+// SubscriptionLister lists the subscriptions of a project and reads their IAM policies
+type SubscriptionLister interface {
+	ListSubscriptions(ctx context.Context, req *pubsubpb.ListSubscriptionsRequest) SubscriptionIterator
+	GetIamPolicy(ctx context.Context, req *iampb.GetIamPolicyRequest) (*iampb.Policy, error)
+}
+
+// PubSubAPI is the subset of the Pub/Sub admin API used by the collector.
+// It is implemented by the real client and can be faked in tests.
+type PubSubAPI interface {
+	TopicLister
+	SubscriptionLister
+	Close() error
+}
+
+// APIFactory creates the PubSubAPI used for a project
+type APIFactory func(ctx context.Context, projectID string) (PubSubAPI, error)
+
+// NewPubSubAPI creates a PubSubAPI backed by a real Pub/Sub client using
+// Application Default Credentials
+func NewPubSubAPI(ctx context.Context, projectID string) (PubSubAPI, error) {
+	return NewPubSubAPIFactory(auth.Options{})(ctx, projectID)
+}
+
+// NewPubSubAPIFactory returns an APIFactory creating real Pub/Sub clients
+// that authenticate with opts, with any extra client options applied
+func NewPubSubAPIFactory(opts auth.Options, extra ...option.ClientOption) APIFactory {
+	return func(ctx context.Context, projectID string) (PubSubAPI, error) {
+		client, err := auth.NewPubSubClient(ctx, projectID, opts, extra...)
+		if err != nil {
+			return nil, err
+		}
+		return &pubsubAPI{client: client}, nil
+	}
+}
+
+// pubsubAPI adapts *pubsub.Client to PubSubAPI
+type pubsubAPI struct {
+	client *pubsub.Client
+
+	// listOpts apply to every page of a listing, see setRetry
+	listOpts []gax.CallOption
+}
+
+// retryingAPI is implemented by APIs that retry failed calls inside their client
+type retryingAPI interface {
+	// setRetry retries failed list pages with policy, passing every retried error to observe
+	setRetry(policy RetryPolicy, observe func(error))
+}
+
+// setRetry replaces the client's default retries of list pages, which give up on RESOURCE_EXHAUSTED
+// and keep retrying others for up to a minute. IAM policies are read once per resource and
+// retried by the collector, between waits for the rate limiter.
+func (a *pubsubAPI) setRetry(policy RetryPolicy, observe func(error)) {
+	a.listOpts = []gax.CallOption{gax.WithRetry(newGaxRetryer(policy, observe))}
+}
+
+func (a *pubsubAPI) ListTopics(ctx context.Context, req *pubsubpb.ListTopicsRequest) TopicIterator {
+	return a.client.TopicAdminClient.ListTopics(ctx, req, a.listOpts...)
+}
+
+func (a *pubsubAPI) ListSubscriptions(ctx context.Context, req *pubsubpb.ListSubscriptionsRequest) SubscriptionIterator {
+	return a.client.SubscriptionAdminClient.ListSubscriptions(ctx, req, a.listOpts...)
+}
+
+// GetIamPolicy reads the policy of a topic or subscription, the IAM service
+// behind both admin clients accepts either resource
+func (a *pubsubAPI) GetIamPolicy(ctx context.Context, req *iampb.GetIamPolicyRequest) (*iampb.Policy, error) {
+	return a.client.SubscriptionAdminClient.GetIamPolicy(ctx, req)
+}
+
+func (a *pubsubAPI) Close() error {
+	return a.client.Close()
+}
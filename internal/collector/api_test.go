@@ -0,0 +1,1100 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/iam/apiv1/iampb"
+	"cloud.google.com/go/pubsub/v2/apiv1/pubsubpb"
+	"github.com/NissesSenap/gcp-visualizer/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	cloudfunctions "google.golang.org/api/cloudfunctions/v2"
+	dataflow "google.golang.org/api/dataflow/v1b3"
+	"google.golang.org/api/eventarc/v1"
+	"google.golang.org/api/iterator"
+	logging "google.golang.org/api/logging/v2"
+	monitoring "google.golang.org/api/monitoring/v3"
+	pubsublite "google.golang.org/api/pubsublite/v1"
+	run "google.golang.org/api/run/v2"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakeAPI is an in-memory PubSubAPI
+type fakeAPI struct {
+	project       string
+	topics        []*pubsubpb.Topic
+	subscriptions []*pubsubpb.Subscription
+	policies      map[string]*iampb.Policy // keyed by topic or subscription full resource name
+	listErr       error                    // returned by both listings after their items
+	iamErrs       []error                  // returned by GetIamPolicy of subscriptions, one per call, before succeeding
+
+	mu       sync.Mutex
+	closed   bool
+	iamCalls int // GetIamPolicy calls for subscriptions
+}
+
+// fakeIterator returns its items, then err or iterator.Done
+type fakeIterator[T any] struct {
+	items []T
+	err   error
+}
+
+func (it *fakeIterator[T]) Next() (T, error) {
+	var zero T
+	if len(it.items) == 0 {
+		if it.err != nil {
+			return zero, it.err
+		}
+		return zero, iterator.Done
+	}
+	item := it.items[0]
+	it.items = it.items[1:]
+	return item, nil
+}
+
+func (f *fakeAPI) ListTopics(ctx context.Context, req *pubsubpb.ListTopicsRequest) TopicIterator {
+	return &fakeIterator[*pubsubpb.Topic]{items: f.topics, err: f.listErr}
+}
+
+func (f *fakeAPI) ListSubscriptions(ctx context.Context, req *pubsubpb.ListSubscriptionsRequest) SubscriptionIterator {
+	return &fakeIterator[*pubsubpb.Subscription]{items: f.subscriptions, err: f.listErr}
+}
+
+func (f *fakeAPI) GetIamPolicy(ctx context.Context, req *iampb.GetIamPolicyRequest) (*iampb.Policy, error) {
+	if strings.Contains(req.Resource, "/subscriptions/") {
+		f.mu.Lock()
+		f.iamCalls++
+		if len(f.iamErrs) > 0 {
+			err := f.iamErrs[0]
+			f.iamErrs = f.iamErrs[1:]
+			f.mu.Unlock()
+			return nil, err
+		}
+		f.mu.Unlock()
+	}
+
+	if policy, ok := f.policies[req.Resource]; ok {
+		return policy, nil
+	}
+	return &iampb.Policy{}, nil
+}
+
+func (f *fakeAPI) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.closed = true
+	return nil
+}
+
+func (f *fakeAPI) isClosed() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.closed
+}
+
+// newFakeCollector returns a collector whose every project is served by api
+func newFakeCollector(t *testing.T, api *fakeAPI, requestsPerSecond float64) (*Collector, storage.Store) {
+	store, err := storage.NewSQLite(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = store.Close() })
+
+	collector := NewWithAPI(store, requestsPerSecond, func(ctx context.Context, projectID string) (PubSubAPI, error) {
+		return api, nil
+	})
+	t.Cleanup(func() { _ = collector.Close() })
+	return collector, store
+}
+
+func projectAAPI() *fakeAPI {
+	return &fakeAPI{
+		topics: []*pubsubpb.Topic{
+			{Name: "projects/project-a/topics/orders", Labels: map[string]string{"team": "checkout"}},
+			{Name: "projects/project-a/topics/payments"},
+			{Name: "projects/project-a/topics/orders-dlq"},
+		},
+		subscriptions: []*pubsubpb.Subscription{
+			{
+				Name:  "projects/project-a/subscriptions/orders-bq",
+				Topic: "projects/project-a/topics/orders",
+				BigqueryConfig: &pubsubpb.BigQueryConfig{
+					Table: "project-a.analytics.orders",
+				},
+				DeadLetterPolicy: &pubsubpb.DeadLetterPolicy{DeadLetterTopic: "projects/project-a/topics/orders-dlq"},
+			},
+			{
+				Name:  "projects/project-a/subscriptions/invoices",
+				Topic: "projects/project-b/topics/invoices",
+			},
+		},
+		policies: map[string]*iampb.Policy{
+			"projects/project-a/topics/orders": {
+				Bindings: []*iampb.Binding{
+					{
+						Role:    "roles/pubsub.publisher",
+						Members: []string{"serviceAccount:checkout@project-a.iam.gserviceaccount.com", "allUsers"},
+					},
+					{
+						Role:    "roles/pubsub.viewer",
+						Members: []string{"group:support@example.com"},
+					},
+				},
+			},
+			"projects/project-a/subscriptions/invoices": {
+				Bindings: []*iampb.Binding{{
+					Role:    "roles/pubsub.subscriber",
+					Members: []string{"serviceAccount:billing@project-a.iam.gserviceaccount.com"},
+				}},
+			},
+		},
+	}
+}
+
+func TestCollectProject(t *testing.T) {
+	collector, store := newFakeCollector(t, projectAAPI(), 1000)
+	collector.SetBatchSize(2) // spread the resources over several batches
+	ctx := context.Background()
+
+	require.NoError(t, collector.CollectProject(ctx, "project-a"))
+
+	topics, err := store.GetTopics(ctx, "project-a")
+	require.NoError(t, err)
+	assert.Len(t, topics, 3)
+
+	subs, err := store.GetSubscriptions(ctx, "project-a")
+	require.NoError(t, err)
+	assert.Len(t, subs, 2)
+
+	dests, err := store.GetAllSubscriptionDestinations(ctx, nil)
+	require.NoError(t, err)
+	require.Len(t, dests, 1)
+	assert.Equal(t, "project-a.analytics.orders", dests[0].Resource)
+
+	consumers, err := store.GetAllSubscriptionConsumers(ctx, nil)
+	require.NoError(t, err)
+	require.Len(t, consumers, 1)
+	assert.Equal(t, "projects/project-a/subscriptions/invoices", consumers[0].SubscriptionFullResourceName)
+
+	edges, err := store.GetEdges(ctx, nil)
+	require.NoError(t, err)
+	var publishers []*storage.ResourceEdge
+	for _, edge := range edges {
+		if edge.Relation == storage.RelationCanPublish {
+			publishers = append(publishers, edge)
+		}
+	}
+	require.Len(t, publishers, 1, "only principals with a publisher role, public members skipped")
+	assert.Equal(t, "serviceAccount:checkout@project-a.iam.gserviceaccount.com", publishers[0].Source)
+	assert.Equal(t, "projects/project-a/topics/orders", publishers[0].Target)
+
+	projects, err := store.GetAllProjects(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"project-a"}, projects)
+}
+
+func TestCollectProject_StaleResources(t *testing.T) {
+	ctx := context.Background()
+	gone := &storage.Topic{
+		Name:             "gone",
+		ProjectID:        "project-a",
+		FullResourceName: "projects/project-a/topics/gone",
+	}
+
+	t.Run("removed", func(t *testing.T) {
+		collector, store := newFakeCollector(t, projectAAPI(), 1000)
+		require.NoError(t, store.SaveTopic(ctx, gone))
+		time.Sleep(5 * time.Millisecond)
+
+		require.NoError(t, collector.CollectProject(ctx, "project-a"))
+		topics, err := store.GetTopics(ctx, "project-a")
+		require.NoError(t, err)
+		assert.Len(t, topics, 3)
+	})
+
+	t.Run("kept", func(t *testing.T) {
+		collector, store := newFakeCollector(t, projectAAPI(), 1000)
+		collector.SetKeepStale(true)
+		require.NoError(t, store.SaveTopic(ctx, gone))
+		time.Sleep(5 * time.Millisecond)
+
+		require.NoError(t, collector.CollectProject(ctx, "project-a"))
+		topics, err := store.GetTopics(ctx, "project-a")
+		require.NoError(t, err)
+		assert.Len(t, topics, 4)
+	})
+
+	t.Run("other collectors disabled", func(t *testing.T) {
+		// Only the Pub/Sub collector is registered, as with collectors: [pubsub]
+		collector, store := newFakeCollector(t, projectAAPI(), 1000)
+		collector.SetCloudRunAPI(nil)
+		require.NoError(t, store.SaveTopic(ctx, gone))
+		require.NoError(t, store.SaveCloudRunServices(ctx, []*storage.CloudRunService{
+			{Name: "worker", ProjectID: "project-a", Region: "europe-west1", FullResourceName: "projects/project-a/locations/europe-west1/services/worker"},
+		}))
+		require.NoError(t, store.SaveMetrics(ctx, []*storage.ResourceMetric{
+			{FullResourceName: "projects/project-a/topics/orders", ProjectID: "project-a", Metric: storage.MetricTopicPublishOperations, Value: 12, Window: time.Hour},
+		}))
+		time.Sleep(5 * time.Millisecond)
+
+		require.NoError(t, collector.CollectProject(ctx, "project-a"))
+		topics, err := store.GetTopics(ctx, "project-a")
+		require.NoError(t, err)
+		assert.Len(t, topics, 3)
+		services, err := store.GetAllCloudRunServices(ctx, nil)
+		require.NoError(t, err)
+		assert.Len(t, services, 1)
+		metrics, err := store.GetMetrics(ctx, nil)
+		require.NoError(t, err)
+		assert.Len(t, metrics, 1)
+	})
+}
+
+// countingStore counts the topics and subscriptions written and touched
+type countingStore struct {
+	storage.Store
+	saved, touched atomic.Int64
+}
+
+func (s *countingStore) SaveTopics(ctx context.Context, topics []*storage.Topic) error {
+	s.saved.Add(int64(len(topics)))
+	return s.Store.SaveTopics(ctx, topics)
+}
+
+func (s *countingStore) SaveSubscriptions(ctx context.Context, subs []*storage.Subscription) error {
+	s.saved.Add(int64(len(subs)))
+	return s.Store.SaveSubscriptions(ctx, subs)
+}
+
+func (s *countingStore) TouchTopics(ctx context.Context, names []string) error {
+	s.touched.Add(int64(len(names)))
+	return s.Store.TouchTopics(ctx, names)
+}
+
+func (s *countingStore) TouchSubscriptions(ctx context.Context, names []string) error {
+	s.touched.Add(int64(len(names)))
+	return s.Store.TouchSubscriptions(ctx, names)
+}
+
+func TestCollectProject_Incremental(t *testing.T) {
+	ctx := context.Background()
+	api := projectAAPI()
+	collector, store := newFakeCollector(t, api, 1000)
+	counting := &countingStore{Store: store}
+	collector.storage = counting
+	collector.SetIncremental(true)
+
+	require.NoError(t, collector.CollectProject(ctx, "project-a"))
+	assert.EqualValues(t, 5, counting.saved.Load())
+	assert.Zero(t, counting.touched.Load())
+
+	// Only the relabelled topic is rewritten; the removed one goes stale even though nothing else was written
+	api.topics[0].Labels = map[string]string{"team": "payments"}
+	api.topics = api.topics[:2]
+	counting.saved.Store(0)
+	counting.touched.Store(0)
+	time.Sleep(5 * time.Millisecond)
+
+	require.NoError(t, collector.CollectProject(ctx, "project-a"))
+	assert.EqualValues(t, 1, counting.saved.Load())
+	assert.EqualValues(t, 3, counting.touched.Load())
+
+	topics, err := store.GetTopics(ctx, "project-a")
+	require.NoError(t, err)
+	require.Len(t, topics, 2)
+	for _, topic := range topics {
+		if topic.Name == "orders" {
+			assert.JSONEq(t, `{"labels":{"team":"payments"}}`, topic.Metadata)
+		}
+	}
+	subs, err := store.GetSubscriptions(ctx, "project-a")
+	require.NoError(t, err)
+	assert.Len(t, subs, 2, "unchanged subscriptions survive the stale cleanup")
+	dests, err := store.GetAllSubscriptionDestinations(ctx, nil)
+	require.NoError(t, err)
+	assert.Len(t, dests, 1)
+}
+
+func TestCollectProject_ListError(t *testing.T) {
+	api := projectAAPI()
+	api.listErr = errors.New("permission denied")
+	collector, store := newFakeCollector(t, api, 1000)
+	ctx := context.Background()
+
+	gone := &storage.Topic{Name: "gone", ProjectID: "project-a", FullResourceName: "projects/project-a/topics/gone"}
+	require.NoError(t, store.SaveTopic(ctx, gone))
+
+	err := collector.CollectProject(ctx, "project-a")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "permission denied")
+
+	// A failed collection must not remove anything
+	topics, err := store.GetTopics(ctx, "project-a")
+	require.NoError(t, err)
+	var names []string
+	for _, topic := range topics {
+		names = append(names, topic.Name)
+	}
+	assert.Contains(t, names, "gone")
+}
+
+// failingCollector fails every project collection
+type failingCollector struct{}
+
+func (failingCollector) Name() string { return "failing" }
+
+func (failingCollector) Collect(ctx context.Context, projectID string) error {
+	return errors.New("quota exceeded")
+}
+
+func TestCollectProject_Transactional(t *testing.T) {
+	api := projectAAPI()
+	collector, store := newFakeCollector(t, api, 1000)
+	ctx := context.Background()
+	require.NoError(t, collector.CollectProject(ctx, "project-a"))
+	before, err := store.GetTopics(ctx, "project-a")
+	require.NoError(t, err)
+	// Changes are stored to the millisecond, so those of the first collection come before since
+	time.Sleep(5 * time.Millisecond)
+	since := time.Now()
+
+	// A collection failing after the topics and subscriptions were listed writes none of them
+	api.topics = append(api.topics[1:], &pubsubpb.Topic{Name: "projects/project-a/topics/refunds"})
+	api.subscriptions = nil
+	collector.Register(failingCollector{})
+	err = collector.CollectProject(ctx, "project-a")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "quota exceeded")
+
+	after, err := store.GetTopics(ctx, "project-a")
+	require.NoError(t, err)
+	assert.Equal(t, before, after)
+	subs, err := store.GetSubscriptions(ctx, "project-a")
+	require.NoError(t, err)
+	assert.Len(t, subs, 2)
+	changes, err := store.GetChanges(ctx, since, nil)
+	require.NoError(t, err)
+	assert.Empty(t, changes)
+	history, err := store.GetProjectSyncHistory(ctx, time.Time{})
+	require.NoError(t, err)
+	assert.Len(t, history["project-a"], 1)
+}
+
+func TestCollectProject_RateLimited(t *testing.T) {
+	// One request per second with a burst of two can't list five resources in 100ms
+	collector, _ := newFakeCollector(t, projectAAPI(), 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	err := collector.CollectProject(ctx, "project-a")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "rate limiter error")
+}
+
+func TestCollectProject_RetriesIAM(t *testing.T) {
+	fastRetries := RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: 2 * time.Millisecond}
+	unavailable := status.Error(codes.Unavailable, "try again")
+
+	t.Run("recovers", func(t *testing.T) {
+		api := projectAAPI()
+		api.iamErrs = []error{unavailable, status.Error(codes.ResourceExhausted, "quota")}
+		collector, _ := newFakeCollector(t, api, 1000)
+		collector.SetRetryPolicy(fastRetries)
+
+		require.NoError(t, collector.CollectProject(context.Background(), "project-a"))
+		// Two failures, then one call per subscription
+		assert.Equal(t, 4, api.iamCalls)
+	})
+
+	t.Run("gives up", func(t *testing.T) {
+		api := projectAAPI()
+		api.iamErrs = []error{unavailable, unavailable, unavailable}
+		collector, _ := newFakeCollector(t, api, 1000)
+		collector.SetRetryPolicy(fastRetries)
+
+		err := collector.CollectProject(context.Background(), "project-a")
+		require.Error(t, err)
+		assert.Equal(t, codes.Unavailable, status.Code(err))
+		assert.Equal(t, 3, api.iamCalls)
+	})
+
+	t.Run("not retryable", func(t *testing.T) {
+		api := projectAAPI()
+		api.iamErrs = []error{status.Error(codes.PermissionDenied, "denied")}
+		collector, _ := newFakeCollector(t, api, 1000)
+		collector.SetRetryPolicy(fastRetries)
+
+		err := collector.CollectProject(context.Background(), "project-a")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "denied")
+		assert.Equal(t, 1, api.iamCalls)
+	})
+}
+
+func TestGaxRetryer(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3, InitialBackoff: 10 * time.Millisecond, MaxBackoff: 15 * time.Millisecond}
+	var observed []error
+	newRetryer := newGaxRetryer(policy, func(err error) { observed = append(observed, err) })
+
+	unavailable := status.Error(codes.Unavailable, "try again")
+	r := newRetryer()
+	wait, ok := r.Retry(unavailable)
+	assert.True(t, ok)
+	assert.Equal(t, 10*time.Millisecond, wait)
+	wait, ok = r.Retry(unavailable)
+	assert.True(t, ok)
+	assert.Equal(t, 15*time.Millisecond, wait)
+	// The third attempt was the last
+	_, ok = r.Retry(unavailable)
+	assert.False(t, ok)
+	assert.Equal(t, []error{unavailable, unavailable}, observed)
+
+	// Every call starts over
+	_, ok = newRetryer().Retry(status.Error(codes.ResourceExhausted, "quota"))
+	assert.True(t, ok)
+	_, ok = newRetryer().Retry(status.Error(codes.PermissionDenied, "denied"))
+	assert.False(t, ok)
+	assert.Len(t, observed, 3)
+}
+
+func TestCollectProject_APICallBudget(t *testing.T) {
+	collector, _ := newFakeCollector(t, projectAAPI(), 1000)
+	require.NoError(t, collector.CollectProject(context.Background(), "project-a"))
+	calls := collector.APICalls()
+	require.Positive(t, calls)
+	assert.Equal(t, calls, collector.ProjectAPICalls("project-a"))
+	assert.Zero(t, collector.ProjectAPICalls("project-b"))
+
+	// One call short of the budget the project needs
+	collector, store := newFakeCollector(t, projectAAPI(), 1000)
+	collector.SetAPICallBudget(calls - 1)
+	err := collector.CollectProject(context.Background(), "project-a")
+	require.ErrorIs(t, err, ErrBudgetExhausted)
+	assert.Equal(t, ErrorClassQuota, ClassifyError(err))
+	assert.Equal(t, calls-1, collector.APICalls())
+
+	// The collection is rolled back
+	topics, err := store.GetTopics(context.Background(), "project-a")
+	require.NoError(t, err)
+	assert.Empty(t, topics)
+}
+
+func TestCollectProject_APIDisabled(t *testing.T) {
+	collector, store := newFakeCollector(t, projectAAPI(), 1000)
+	collector.SetServiceChecker(func(ctx context.Context, projectID string) (bool, error) {
+		return false, nil
+	})
+	ctx := context.Background()
+
+	err := collector.CollectProject(ctx, "project-a")
+	require.ErrorIs(t, err, ErrAPIDisabled)
+
+	topics, err := store.GetTopics(ctx, "project-a")
+	require.NoError(t, err)
+	assert.Empty(t, topics, "a skipped project is not collected")
+	statuses, err := store.GetProjectStatuses(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"project-a": storage.ProjectStatusAPIDisabled}, statuses)
+
+	// Once the API is enabled, the next scan clears the status
+	collector.SetServiceChecker(nil)
+	require.NoError(t, collector.CollectProject(ctx, "project-a"))
+	statuses, err = store.GetProjectStatuses(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, statuses)
+}
+
+func TestCollectProject_ServiceCheckFails(t *testing.T) {
+	collector, store := newFakeCollector(t, projectAAPI(), 1000)
+	collector.SetServiceChecker(func(ctx context.Context, projectID string) (bool, error) {
+		return false, errors.New("serviceusage.services.get denied")
+	})
+	ctx := context.Background()
+
+	// The probe is best effort, the project is still listed
+	require.NoError(t, collector.CollectProject(ctx, "project-a"))
+	topics, err := store.GetTopics(ctx, "project-a")
+	require.NoError(t, err)
+	assert.Len(t, topics, 3)
+}
+
+// recordingObserver records what the collector reports
+type recordingObserver struct {
+	mu       sync.Mutex
+	stored   map[string]int
+	projects map[string]error
+}
+
+func (o *recordingObserver) ObserveProject(projectID string, duration time.Duration, err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.projects[projectID] = err
+}
+
+func (o *recordingObserver) AddStored(projectID, kind string, n int) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.stored[kind] += n
+}
+
+func TestCollectProject_Observer(t *testing.T) {
+	collector, _ := newFakeCollector(t, projectAAPI(), 1000)
+	collector.SetBatchSize(2)
+	observer := &recordingObserver{stored: map[string]int{}, projects: map[string]error{}}
+	collector.SetObserver(observer)
+
+	require.NoError(t, collector.CollectProject(context.Background(), "project-a"))
+	assert.Equal(t, map[string]int{"topic": 3, "subscription": 2}, observer.stored)
+	assert.Contains(t, observer.projects, "project-a")
+	assert.NoError(t, observer.projects["project-a"])
+
+	// A disabled API is a skip, not a failed collection
+	collector.SetServiceChecker(func(ctx context.Context, projectID string) (bool, error) {
+		return false, nil
+	})
+	require.ErrorIs(t, collector.CollectProject(context.Background(), "project-b"), ErrAPIDisabled)
+	assert.Contains(t, observer.projects, "project-b")
+	assert.NoError(t, observer.projects["project-b"])
+}
+
+// stuckStore never finishes saving topics until the write is cancelled
+type stuckStore struct {
+	storage.Store
+}
+
+func (s stuckStore) SaveTopics(ctx context.Context, topics []*storage.Topic) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestCollectProject_ProjectTimeout(t *testing.T) {
+	// The rate limiter refuses to wait past the deadline without wrapping context.DeadlineExceeded
+	collector, store := newFakeCollector(t, projectAAPI(), 1)
+	collector.SetProjectTimeout(100 * time.Millisecond)
+
+	err := collector.CollectProject(context.Background(), "project-a")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "rate limiter error")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, ErrorClassTimeout, ClassifyError(err))
+
+	history, err := store.GetProjectSyncHistory(context.Background(), time.Time{})
+	require.NoError(t, err)
+	assert.Empty(t, history["project-a"])
+}
+
+func TestCollectProject_WriteTimeout(t *testing.T) {
+	api := projectAAPI()
+	collector, store := newFakeCollector(t, api, 1000)
+	collector.storage = stuckStore{Store: store}
+	collector.SetWriteTimeout(20 * time.Millisecond)
+
+	err := fmt.Errorf("project project-a: %w", collector.CollectProject(context.Background(), "project-a"))
+	require.Error(t, err)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// The project's writes are committed together, so all of them are rolled back
+	batches := RolledBack(err)
+	require.Len(t, batches, 1)
+	assert.Equal(t, "resource", batches[0].Kind)
+	assert.Equal(t, 8, batches[0].Size)
+	topics, err := store.GetTopics(context.Background(), "project-a")
+	require.NoError(t, err)
+	assert.Empty(t, topics)
+
+	// The failed project isn't recorded as a completed sync
+	history, err := store.GetProjectSyncHistory(context.Background(), time.Time{})
+	require.NoError(t, err)
+	assert.Empty(t, history["project-a"])
+}
+
+// fakeCloudRunAPI is an in-memory CloudRunAPI
+type fakeCloudRunAPI struct {
+	services []*run.GoogleCloudRunV2Service
+	triggers []*eventarc.Trigger
+}
+
+func (f *fakeCloudRunAPI) ListServices(ctx context.Context, projectID string) ([]*run.GoogleCloudRunV2Service, error) {
+	return f.services, nil
+}
+
+func (f *fakeCloudRunAPI) ListTriggers(ctx context.Context, projectID string) ([]*eventarc.Trigger, error) {
+	return f.triggers, nil
+}
+
+func TestCollectProject_CloudRun(t *testing.T) {
+	api := projectAAPI()
+	api.subscriptions = append(api.subscriptions, &pubsubpb.Subscription{
+		Name:       "projects/project-a/subscriptions/eventarc-europe-west1-mailer-sub-123",
+		Topic:      "projects/project-a/topics/orders",
+		PushConfig: &pubsubpb.PushConfig{PushEndpoint: "https://mailer-abc123-ew.a.run.app/events"},
+	})
+	collector, store := newFakeCollector(t, api, 1000)
+	collector.SetCloudRunAPI(&fakeCloudRunAPI{
+		services: []*run.GoogleCloudRunV2Service{{
+			Name:     "projects/project-a/locations/europe-west1/services/mailer",
+			Uri:      "https://mailer-abc123-ew.a.run.app",
+			Urls:     []string{"https://mailer-123456789.europe-west1.run.app", "https://mailer-abc123-ew.a.run.app"},
+			Labels:   map[string]string{"team": "mail"},
+			Template: &run.GoogleCloudRunV2RevisionTemplate{ServiceAccount: "mailer@project-a.iam.gserviceaccount.com"},
+		}},
+		triggers: []*eventarc.Trigger{
+			{
+				Name:        "projects/project-a/locations/europe-west1/triggers/mailer",
+				Transport:   &eventarc.Transport{Pubsub: &eventarc.Pubsub{Subscription: "projects/project-a/subscriptions/eventarc-europe-west1-mailer-sub-123"}},
+				Destination: &eventarc.Destination{CloudRun: &eventarc.CloudRun{Service: "mailer", Region: "europe-west1", Path: "/events"}},
+			},
+			// Workflows aren't collected
+			{
+				Name:        "projects/project-a/locations/europe-west1/triggers/workflow",
+				Transport:   &eventarc.Transport{Pubsub: &eventarc.Pubsub{Subscription: "projects/project-a/subscriptions/eventarc-workflow"}},
+				Destination: &eventarc.Destination{Workflow: "projects/project-a/locations/europe-west1/workflows/audit"},
+			},
+		},
+	})
+	ctx := context.Background()
+
+	require.NoError(t, collector.CollectProject(ctx, "project-a"))
+
+	services, err := store.GetAllCloudRunServices(ctx, nil)
+	require.NoError(t, err)
+	require.Len(t, services, 1)
+	assert.Equal(t, "mailer", services[0].Name)
+	assert.Equal(t, "europe-west1", services[0].Region)
+	assert.Equal(t, []string{"https://mailer-123456789.europe-west1.run.app", "https://mailer-abc123-ew.a.run.app"}, services[0].URLs)
+	assert.JSONEq(t, `{"labels":{"team":"mail"},"ingress":"","service_account":"mailer@project-a.iam.gserviceaccount.com"}`, services[0].Metadata)
+
+	dests, err := store.GetAllSubscriptionDestinations(ctx, nil)
+	require.NoError(t, err)
+	byType := map[string]*storage.SubscriptionDestination{}
+	for _, d := range dests {
+		byType[d.Type] = d
+	}
+	require.Len(t, byType, 2)
+	trigger := byType[storage.DestinationTypeCloudRun]
+	require.NotNil(t, trigger)
+	assert.Equal(t, "projects/project-a/subscriptions/eventarc-europe-west1-mailer-sub-123", trigger.SubscriptionFullResourceName)
+	assert.Equal(t, "projects/project-a/locations/europe-west1/services/mailer", trigger.Resource)
+
+	subs, err := store.GetSubscriptions(ctx, "project-a")
+	require.NoError(t, err)
+	for _, sub := range subs {
+		if sub.Name == "eventarc-europe-west1-mailer-sub-123" {
+			assert.Contains(t, sub.Metadata, `"push_endpoint":"https://mailer-abc123-ew.a.run.app/events"`)
+		}
+	}
+}
+
+// fakeCloudFunctionsAPI is an in-memory CloudFunctionsAPI
+type fakeCloudFunctionsAPI struct {
+	functions []*cloudfunctions.Function
+}
+
+func (f *fakeCloudFunctionsAPI) ListFunctions(ctx context.Context, projectID string) ([]*cloudfunctions.Function, error) {
+	return f.functions, nil
+}
+
+func TestCollectProject_CloudFunctions(t *testing.T) {
+	collector, store := newFakeCollector(t, projectAAPI(), 1000)
+	collector.SetCloudFunctionsAPI(&fakeCloudFunctionsAPI{functions: []*cloudfunctions.Function{
+		{
+			Name:        "projects/project-a/locations/europe-west1/functions/resize",
+			Environment: "GEN_1",
+			EventTrigger: &cloudfunctions.EventTrigger{
+				EventType:   "google.pubsub.topic.publish",
+				PubsubTopic: "projects/project-a/topics/orders",
+			},
+			BuildConfig: &cloudfunctions.BuildConfig{Runtime: "go122"},
+		},
+		{
+			Name:        "projects/project-a/locations/us-central1/functions/notify",
+			Environment: "GEN_2",
+			Labels:      map[string]string{"team": "mail"},
+			EventTrigger: &cloudfunctions.EventTrigger{
+				EventType:   "google.cloud.pubsub.topic.v1.messagePublished",
+				PubsubTopic: "projects/project-b/topics/invoices",
+				Trigger:     "projects/project-a/locations/us-central1/triggers/notify-123",
+				RetryPolicy: "RETRY_POLICY_RETRY",
+			},
+			ServiceConfig: &cloudfunctions.ServiceConfig{Service: "projects/project-a/locations/us-central1/services/notify"},
+		},
+		{Name: "projects/project-a/locations/europe-west1/functions/http", Environment: "GEN_2"},
+	}})
+	ctx := context.Background()
+
+	require.NoError(t, collector.CollectProject(ctx, "project-a"))
+
+	functions, err := store.GetAllCloudFunctions(ctx, nil)
+	require.NoError(t, err)
+	require.Len(t, functions, 3)
+	byName := map[string]*storage.CloudFunction{}
+	for _, fn := range functions {
+		byName[fn.Name] = fn
+	}
+
+	resize := byName["resize"]
+	assert.Equal(t, 1, resize.Generation)
+	assert.Equal(t, "europe-west1", resize.Region)
+	assert.Equal(t, "projects/project-a/topics/orders", resize.TriggerTopic)
+	assert.JSONEq(t, `{"labels":{},"state":"","runtime":"go122"}`, resize.Metadata)
+
+	notify := byName["notify"]
+	assert.Equal(t, 2, notify.Generation)
+	assert.Equal(t, "projects/project-b/topics/invoices", notify.TriggerTopic)
+	assert.JSONEq(t, `{"labels":{"team":"mail"},"state":"","trigger":"projects/project-a/locations/us-central1/triggers/notify-123","trigger_retry_policy":"RETRY_POLICY_RETRY","service":"projects/project-a/locations/us-central1/services/notify"}`, notify.Metadata)
+
+	assert.Empty(t, byName["http"].TriggerTopic)
+	assert.Empty(t, byName["http"].EventType)
+}
+
+// fakeDataflowAPI is an in-memory DataflowAPI listing the summaries of jobs
+type fakeDataflowAPI struct {
+	jobs []*dataflow.Job
+}
+
+func (f *fakeDataflowAPI) ListJobs(ctx context.Context, projectID string) ([]*dataflow.Job, error) {
+	summaries := make([]*dataflow.Job, 0, len(f.jobs))
+	for _, job := range f.jobs {
+		summaries = append(summaries, &dataflow.Job{Id: job.Id, Name: job.Name, Location: job.Location})
+	}
+	return summaries, nil
+}
+
+func (f *fakeDataflowAPI) GetJob(ctx context.Context, projectID, region, jobID string) (*dataflow.Job, error) {
+	for _, job := range f.jobs {
+		if job.Location == region && job.Id == jobID {
+			return job, nil
+		}
+	}
+	return nil, fmt.Errorf("job %s not found in %s", jobID, region)
+}
+
+func TestCollectProject_Dataflow(t *testing.T) {
+	collector, store := newFakeCollector(t, projectAAPI(), 1000)
+	collector.SetDataflowAPI(&fakeDataflowAPI{jobs: []*dataflow.Job{
+		{
+			Id:           "2024-01-01_00_00_00-1",
+			Name:         "enrich-orders",
+			Location:     "europe-west1",
+			CurrentState: "JOB_STATE_RUNNING",
+			Type:         "JOB_TYPE_STREAMING",
+			Environment: &dataflow.Environment{
+				ServiceAccountEmail: "dataflow@project-a.iam.gserviceaccount.com",
+				SdkPipelineOptions: []byte(`{"options":{
+					"inputSubscription":"projects/project-a/subscriptions/orders-sub",
+					"outputTopic":"projects/project-b/topics/enriched",
+					"outputDeadletterTopic":"projects/project-a/topics/dead-letter",
+					"tempLocation":"gs://bucket/tmp",
+					"numWorkers":2}}`),
+			},
+		},
+		{
+			Id:       "2024-01-01_00_00_00-2",
+			Name:     "archive",
+			Location: "us-central1",
+			Labels:   map[string]string{"team": "data"},
+			Environment: &dataflow.Environment{
+				SdkPipelineOptions: []byte(`{"options":{"topic":"projects/project-a/topics/orders"}}`),
+			},
+			JobMetadata: &dataflow.JobMetadata{PubsubDetails: []*dataflow.PubSubIODetails{
+				{Topic: "projects/project-a/topics/clicks", Subscription: "projects/project-a/subscriptions/clicks-sub"},
+			}},
+		},
+	}})
+	ctx := context.Background()
+
+	require.NoError(t, collector.CollectProject(ctx, "project-a"))
+
+	jobs, err := store.GetAllDataflowJobs(ctx, nil)
+	require.NoError(t, err)
+	require.Len(t, jobs, 2)
+	byName := map[string]*storage.DataflowJob{}
+	for _, job := range jobs {
+		byName[job.Name] = job
+	}
+
+	enrich := byName["enrich-orders"]
+	assert.Equal(t, "europe-west1", enrich.Region)
+	assert.Equal(t, "projects/project-a/locations/europe-west1/jobs/2024-01-01_00_00_00-1", enrich.FullResourceName)
+	assert.Equal(t, []string{"projects/project-a/subscriptions/orders-sub"}, enrich.Sources)
+	assert.Equal(t, []string{"projects/project-a/topics/dead-letter", "projects/project-b/topics/enriched"}, enrich.Sinks)
+	assert.JSONEq(t, `{"labels":{},"job_id":"2024-01-01_00_00_00-1","state":"JOB_STATE_RUNNING","job_type":"JOB_TYPE_STREAMING","service_account":"dataflow@project-a.iam.gserviceaccount.com"}`, enrich.Metadata)
+
+	archive := byName["archive"]
+	assert.Equal(t, []string{"projects/project-a/subscriptions/clicks-sub", "projects/project-a/topics/orders"}, archive.Sources)
+	assert.Empty(t, archive.Sinks)
+}
+
+// fakeAuditLogAPI is an in-memory AuditLogAPI returning the same entries for every project
+type fakeAuditLogAPI struct {
+	entries []*logging.LogEntry
+	since   time.Time
+}
+
+func (f *fakeAuditLogAPI) ListPublishEntries(ctx context.Context, projectID string, since time.Time) ([]*logging.LogEntry, error) {
+	f.since = since
+	return f.entries, nil
+}
+
+func publishEntry(principal, topic string, code int) *logging.LogEntry {
+	return &logging.LogEntry{ProtoPayload: []byte(fmt.Sprintf(`{
+		"methodName":"google.pubsub.v1.Publisher.Publish",
+		"resourceName":%q,
+		"authenticationInfo":{"principalEmail":%q},
+		"status":{"code":%d}}`, topic, principal, code))}
+}
+
+func TestCollectProject_Publishers(t *testing.T) {
+	collector, store := newFakeCollector(t, projectAAPI(), 1000)
+	logs := &fakeAuditLogAPI{entries: []*logging.LogEntry{
+		publishEntry("orders@project-a.iam.gserviceaccount.com", "projects/project-a/topics/orders", 0),
+		publishEntry("orders@project-a.iam.gserviceaccount.com", "projects/project-a/topics/orders", 0),
+		publishEntry("dev@example.com", "projects/project-b/topics/clicks", 0),
+		publishEntry("denied@project-a.iam.gserviceaccount.com", "projects/project-a/topics/orders", 7),
+		publishEntry("", "projects/project-a/topics/orders", 0),
+		{ProtoPayload: []byte(`{"methodName":"google.pubsub.v1.Subscriber.Pull"}`)},
+	}}
+	collector.SetAuditLogAPI(logs, 6*time.Hour)
+	ctx := context.Background()
+
+	require.NoError(t, collector.CollectProject(ctx, "project-a"))
+	assert.WithinDuration(t, time.Now().Add(-6*time.Hour), logs.since, time.Minute)
+
+	edges, err := store.GetEdges(ctx, []string{"project-a"})
+	require.NoError(t, err)
+	var publishers []string
+	for _, edge := range edges {
+		if edge.Relation == storage.RelationPublishes {
+			publishers = append(publishers, edge.Source+" -> "+edge.Target)
+		}
+	}
+	assert.Equal(t, []string{
+		"serviceAccount:orders@project-a.iam.gserviceaccount.com -> projects/project-a/topics/orders",
+		"user:dev@example.com -> projects/project-b/topics/clicks",
+	}, publishers)
+}
+
+// fakeMonitoringAPI is an in-memory MonitoringAPI, keyed by metric type. Like the API it
+// returns the newest point of a series first, the one of the period ending at end.
+type fakeMonitoringAPI struct {
+	series map[string][]*monitoring.TimeSeries
+	starts map[string][]time.Time // the start of every call
+}
+
+func (f *fakeMonitoringAPI) ListTimeSeries(ctx context.Context, projectID, metricType, aligner string, start, end time.Time, period time.Duration) ([]*monitoring.TimeSeries, error) {
+	if f.starts == nil {
+		f.starts = make(map[string][]time.Time)
+	}
+	f.starts[metricType] = append(f.starts[metricType], start)
+
+	var series []*monitoring.TimeSeries
+	for _, ts := range f.series[metricType] {
+		aligned := &monitoring.TimeSeries{Resource: ts.Resource}
+		for i, point := range ts.Points {
+			pointEnd := end.Add(-time.Duration(i) * period)
+			aligned.Points = append(aligned.Points, &monitoring.Point{
+				Interval: &monitoring.TimeInterval{EndTime: pointEnd.Format(time.RFC3339)},
+				Value:    point.Value,
+			})
+		}
+		series = append(series, aligned)
+	}
+	return series, nil
+}
+
+// fakeProjectsAPI is an in-memory ProjectsAPI, keyed by project
+type fakeProjectsAPI struct {
+	labels map[string]map[string]string
+}
+
+func (f *fakeProjectsAPI) GetLabels(ctx context.Context, projectID string) (map[string]string, error) {
+	return f.labels[projectID], nil
+}
+
+func TestCollectProject_ProjectLabels(t *testing.T) {
+	collector, store := newFakeCollector(t, projectAAPI(), 1000)
+	collector.SetProjectsAPI(&fakeProjectsAPI{labels: map[string]map[string]string{
+		"project-a": {"team": "payments"},
+	}})
+	ctx := context.Background()
+
+	require.NoError(t, collector.CollectProject(ctx, "project-a"))
+
+	labels, err := store.GetProjectLabels(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]map[string]string{"project-a": {"team": "payments"}}, labels)
+}
+
+// fakePubSubLiteAPI is an in-memory PubSubLiteAPI, keyed by location. Names use the
+// project number like the admin API does.
+type fakePubSubLiteAPI struct {
+	topics map[string][]*pubsublite.Topic
+	subs   map[string][]*pubsublite.Subscription
+}
+
+func (f *fakePubSubLiteAPI) ListTopics(ctx context.Context, projectID, location string) ([]*pubsublite.Topic, error) {
+	return f.topics[location], nil
+}
+
+func (f *fakePubSubLiteAPI) ListSubscriptions(ctx context.Context, projectID, location string) ([]*pubsublite.Subscription, error) {
+	return f.subs[location], nil
+}
+
+func TestCollectProject_PubSubLite(t *testing.T) {
+	collector, store := newFakeCollector(t, projectAAPI(), 1000)
+	collector.SetPubSubLiteAPI(&fakePubSubLiteAPI{
+		topics: map[string][]*pubsublite.Topic{
+			"europe-west1-b": {{
+				Name: "projects/123456/locations/europe-west1-b/topics/clicks",
+				PartitionConfig: &pubsublite.PartitionConfig{
+					Count:    2,
+					Capacity: &pubsublite.Capacity{PublishMibPerSec: 4, SubscribeMibPerSec: 8},
+				},
+				RetentionConfig: &pubsublite.RetentionConfig{PerPartitionBytes: 32212254720, Period: "86400s"},
+			}},
+		},
+		subs: map[string][]*pubsublite.Subscription{
+			"europe-west1-b": {
+				{
+					Name:           "projects/123456/locations/europe-west1-b/subscriptions/clicks-export",
+					Topic:          "projects/123456/locations/europe-west1-b/topics/clicks",
+					DeliveryConfig: &pubsublite.DeliveryConfig{DeliveryRequirement: "DELIVER_AFTER_STORED"},
+					ExportConfig: &pubsublite.ExportConfig{
+						CurrentState: "ACTIVE",
+						PubsubConfig: &pubsublite.PubSubConfig{Topic: "projects/123456/topics/clicks"},
+					},
+				},
+			},
+		},
+	}, []string{"europe-west1-b", "us-central1"})
+	collector.Unregister(CollectorPubSub)
+	ctx := context.Background()
+
+	require.NoError(t, collector.CollectProject(ctx, "project-a"))
+	assert.Equal(t, int64(4), collector.ProjectAPICalls("project-a"), "topics and subscriptions are listed in every location")
+
+	topics, err := store.GetResources(ctx, storage.ResourceKindLiteTopic, nil)
+	require.NoError(t, err)
+	require.Len(t, topics, 1)
+	assert.Equal(t, "clicks", topics[0].Name)
+	assert.Equal(t, "project-a", topics[0].ProjectID)
+	assert.Equal(t, "projects/project-a/locations/europe-west1-b/topics/clicks", topics[0].FullResourceName)
+	assert.JSONEq(t, `{"location":"europe-west1-b","partitions":2,"publish_mib_per_sec":4,"subscribe_mib_per_sec":8,
+		"retention_bytes_per_partition":"32212254720","retention_period":"86400s"}`, topics[0].Metadata)
+
+	subs, err := store.GetResources(ctx, storage.ResourceKindLiteSubscription, nil)
+	require.NoError(t, err)
+	require.Len(t, subs, 1)
+	assert.JSONEq(t, `{"location":"europe-west1-b","delivery_requirement":"DELIVER_AFTER_STORED","export_state":"ACTIVE"}`, subs[0].Metadata)
+
+	edges, err := store.GetEdges(ctx, nil)
+	require.NoError(t, err)
+	var lite []string
+	for _, e := range edges {
+		if strings.Contains(e.Source, "/locations/") {
+			lite = append(lite, e.Relation+" "+e.Target)
+		}
+	}
+	assert.ElementsMatch(t, []string{
+		"subscribes projects/project-a/locations/europe-west1-b/topics/clicks",
+		"exports projects/project-a/topics/clicks",
+	}, lite, "project numbers are stored as the project ID")
+}
+
+func timeSeries(label, id string, values ...int64) *monitoring.TimeSeries {
+	ts := &monitoring.TimeSeries{Resource: &monitoring.MonitoredResource{
+		Labels: map[string]string{"project_id": "project-a", label: id},
+	}}
+	for _, v := range values {
+		ts.Points = append(ts.Points, &monitoring.Point{Value: &monitoring.TypedValue{Int64Value: &v}})
+	}
+	return ts
+}
+
+func TestCollectProject_Metrics(t *testing.T) {
+	collector, store := newFakeCollector(t, projectAAPI(), 1000)
+	collector.SetMonitoringAPI(&fakeMonitoringAPI{series: map[string][]*monitoring.TimeSeries{
+		storage.MetricTopicPublishOperations: {
+			// One series per response code
+			timeSeries("topic_id", "orders", 1200, 30),
+			timeSeries("topic_id", "orders", 20),
+			timeSeries("topic_id", "payments", 5),
+		},
+		storage.MetricSubscriptionBacklog: {
+			timeSeries("subscription_id", "orders-bq", 40, 75),
+			timeSeries("subscription_id", ""),
+		},
+		storage.MetricSubscriptionUnackedAge: {
+			timeSeries("subscription_id", "orders-bq", 600, 5400),
+		},
+	}}, 30*time.Minute, 5*time.Minute)
+	ctx := context.Background()
+
+	require.NoError(t, collector.CollectProject(ctx, "project-a"))
+
+	metrics, err := store.GetMetrics(ctx, []string{"project-a"})
+	require.NoError(t, err)
+	values := map[string]float64{}
+	for _, m := range metrics {
+		values[m.FullResourceName+" "+m.Metric] = m.Value
+		assert.Equal(t, 30*time.Minute, m.Window)
+	}
+	assert.Equal(t, map[string]float64{
+		"projects/project-a/topics/orders " + storage.MetricTopicPublishOperations:           1250,
+		"projects/project-a/topics/payments " + storage.MetricTopicPublishOperations:         5,
+		"projects/project-a/subscriptions/orders-bq " + storage.MetricSubscriptionBacklog:    75,
+		"projects/project-a/subscriptions/orders-bq " + storage.MetricSubscriptionUnackedAge: 5400,
+	}, values)
+}
+
+func TestCollectProject_MetricsIncremental(t *testing.T) {
+	collector, store := newFakeCollector(t, projectAAPI(), 1000)
+	api := &fakeMonitoringAPI{series: map[string][]*monitoring.TimeSeries{
+		storage.MetricTopicPublishOperations: {timeSeries("topic_id", "orders", 30, 1200)},
+	}}
+	now := time.Date(2024, 1, 1, 12, 2, 0, 0, time.UTC)
+	collector.Register(&metricsCollector{c: collector, api: api, window: 15 * time.Minute, resolution: 5 * time.Minute,
+		now: func() time.Time { return now }})
+	ctx := context.Background()
+	at := func(minute int) time.Time { return time.Date(2024, 1, 1, 11, 0, 0, 0, time.UTC).Add(time.Duration(minute) * time.Minute) }
+
+	publishOps := func() float64 {
+		t.Helper()
+		metrics, err := store.GetMetrics(ctx, []string{"project-a"})
+		require.NoError(t, err)
+		for _, m := range metrics {
+			if m.FullResourceName == "projects/project-a/topics/orders" && m.Metric == storage.MetricTopicPublishOperations {
+				return m.Value
+			}
+		}
+		return 0
+	}
+
+	// The first scan fetches the whole window ending at the last whole period, 12:00
+	require.NoError(t, collector.CollectProject(ctx, "project-a"))
+	assert.Equal(t, []time.Time{at(45)}, api.starts[storage.MetricTopicPublishOperations])
+	assert.Equal(t, 1230.0, publishOps())
+
+	// Ten minutes later only the newest stored period onwards is fetched, and the 11:50 one
+	// fell out of the window
+	now = now.Add(10 * time.Minute)
+	api.series[storage.MetricTopicPublishOperations] = []*monitoring.TimeSeries{timeSeries("topic_id", "orders", 7, 3, 40)}
+	require.NoError(t, collector.CollectProject(ctx, "project-a"))
+	assert.Equal(t, []time.Time{at(45), at(55)}, api.starts[storage.MetricTopicPublishOperations])
+	assert.Equal(t, 50.0, publishOps())
+
+	points, err := store.GetMetricPoints(ctx, "project-a", time.Time{})
+	require.NoError(t, err)
+	var starts []time.Time
+	for _, p := range points {
+		starts = append(starts, p.Start)
+	}
+	assert.Equal(t, []time.Time{at(55), at(60), at(65)}, starts)
+}
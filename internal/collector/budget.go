@@ -0,0 +1,65 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrBudgetExhausted is returned by the API calls a collection attempts once the collector
+// made as many as SetAPICallBudget allows
+var ErrBudgetExhausted = errors.New("API call budget exhausted")
+
+// apiCalls counts the rate-limited API requests of a collector, overall and per project
+type apiCalls struct {
+	mu        sync.Mutex
+	total     int64
+	byProject map[string]int64
+	budget    int64 // zero is unlimited
+}
+
+// SetAPICallBudget fails every API call once the collector made budget of them, so a scan
+// stops before it eats the quota of the projects it scans. Calls are counted like
+// EstimateRequests counts them, one per rate-limited request. Values below 1 disable the budget.
+func (c *Collector) SetAPICallBudget(budget int64) {
+	c.calls.mu.Lock()
+	defer c.calls.mu.Unlock()
+	c.calls.budget = max(budget, 0)
+}
+
+// APICalls returns the rate-limited API requests the collector made
+func (c *Collector) APICalls() int64 {
+	c.calls.mu.Lock()
+	defer c.calls.mu.Unlock()
+	return c.calls.total
+}
+
+// ProjectAPICalls returns the rate-limited API requests the collector made for a project
+func (c *Collector) ProjectAPICalls(projectID string) int64 {
+	c.calls.mu.Lock()
+	defer c.calls.mu.Unlock()
+	return c.calls.byProject[projectID]
+}
+
+// wait counts an API call of the project against the budget, then waits for the rate limiter.
+// A call that fails to wait isn't made and isn't counted.
+func (c *Collector) wait(ctx context.Context, projectID string) error {
+	c.calls.mu.Lock()
+	if c.calls.budget > 0 && c.calls.total >= c.calls.budget {
+		c.calls.mu.Unlock()
+		return fmt.Errorf("%w: %d calls made", ErrBudgetExhausted, c.calls.budget)
+	}
+	c.calls.total++
+	c.calls.byProject[projectID]++
+	c.calls.mu.Unlock()
+
+	if err := c.limiter.Wait(ctx); err != nil {
+		c.calls.mu.Lock()
+		c.calls.total--
+		c.calls.byProject[projectID]--
+		c.calls.mu.Unlock()
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/googleapis/gax-go/v2/apierror"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Classes of collection errors, for reports read by CI pipelines
+const (
+	ErrorClassAuth        = "auth"         // missing credentials or IAM permissions
+	ErrorClassQuota       = "quota"        // API quota or rate limit exhausted
+	ErrorClassAPIDisabled = "api_disabled" // the API isn't enabled in the project
+	ErrorClassTimeout     = "timeout"      // deadline exceeded
+	ErrorClassOther       = "other"
+)
+
+// ClassifyError returns the class of an error returned by CollectProject, from the
+// gRPC status or HTTP code of the API error it wraps
+func ClassifyError(err error) string {
+	if errors.Is(err, ErrAPIDisabled) {
+		return ErrorClassAPIDisabled
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrorClassTimeout
+	}
+	if errors.Is(err, ErrBudgetExhausted) {
+		return ErrorClassQuota
+	}
+
+	// A disabled API is reported as a permission error with this reason
+	var apiErr *apierror.APIError
+	if errors.As(err, &apiErr) {
+		if apiErr.Reason() == "SERVICE_DISABLED" {
+			return ErrorClassAPIDisabled
+		}
+		if class := httpClass(apiErr.HTTPCode()); class != "" {
+			return class
+		}
+	}
+	var httpErr *googleapi.Error
+	if errors.As(err, &httpErr) {
+		if class := httpClass(httpErr.Code); class != "" {
+			return class
+		}
+	}
+
+	switch status.Code(err) {
+	case codes.Unauthenticated, codes.PermissionDenied:
+		return ErrorClassAuth
+	case codes.ResourceExhausted:
+		return ErrorClassQuota
+	case codes.DeadlineExceeded:
+		return ErrorClassTimeout
+	}
+	return ErrorClassOther
+}
+
+// httpClass returns the class of a REST API error by its HTTP code, empty if it has none
+func httpClass(code int) string {
+	switch code {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return ErrorClassAuth
+	case http.StatusTooManyRequests:
+		return ErrorClassQuota
+	}
+	return ""
+}
+
+// timedOut reports whether a collection under ctx failed with err because of its deadline.
+// Calls cut short by it don't always say so: the rate limiter refuses to wait past the
+// deadline, before it is reached, with an error that doesn't wrap context.DeadlineExceeded.
+func timedOut(ctx context.Context, err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return true
+	}
+	_, hasDeadline := ctx.Deadline()
+	return hasDeadline && strings.Contains(err.Error(), "would exceed context deadline")
+}
@@ -0,0 +1,81 @@
+package collector
+
+import (
+	"container/list"
+	"time"
+)
+
+// pooledClient is a cached Pub/Sub client of a project
+type pooledClient struct {
+	projectID string
+	api       PubSubAPI
+	users     int           // collections using the client, it's only closed at zero
+	released  time.Time     // when the last user released it
+	idle      *list.Element // in Collector.idleClients while nobody uses the client
+}
+
+// SetClientLimits bounds the Pub/Sub clients kept open between project collections, so a scan
+// of hundreds of projects doesn't hold a gRPC connection per project until it ends. Clients no
+// collection uses are closed, least recently used first, when more than maxClients are open or
+// once they have been idle for idleTimeout. Values below 1 disable either limit.
+func (c *Collector) SetClientLimits(maxClients int, idleTimeout time.Duration) {
+	c.mu.Lock()
+	c.maxClients = max(maxClients, 0)
+	c.clientIdleTimeout = max(idleTimeout, 0)
+	evicted := c.evictClients(time.Now())
+	c.mu.Unlock()
+
+	closeClients(evicted)
+}
+
+// acquireClient marks pc as in use. The caller holds c.mu.
+func (c *Collector) acquireClient(pc *pooledClient) {
+	pc.users++
+	if pc.idle != nil {
+		c.idleClients.Remove(pc.idle)
+		pc.idle = nil
+	}
+}
+
+// releaseClient gives pc back once a collection is done with it, closing the clients
+// that are then beyond the limits
+func (c *Collector) releaseClient(pc *pooledClient) {
+	now := time.Now()
+	c.mu.Lock()
+	pc.users--
+	if pc.users == 0 {
+		pc.released = now
+		pc.idle = c.idleClients.PushBack(pc)
+	}
+	evicted := c.evictClients(now)
+	c.mu.Unlock()
+
+	closeClients(evicted)
+}
+
+// evictClients removes the idle clients beyond the limits from the cache and returns them
+// for the caller to close outside the lock. Idle timeouts are checked whenever a client is
+// taken or given back. The caller holds c.mu.
+func (c *Collector) evictClients(now time.Time) []*pooledClient {
+	var evicted []*pooledClient
+	for e := c.idleClients.Front(); e != nil; e = c.idleClients.Front() {
+		pc := e.Value.(*pooledClient)
+		expired := c.clientIdleTimeout > 0 && now.Sub(pc.released) >= c.clientIdleTimeout
+		full := c.maxClients > 0 && len(c.clients) > c.maxClients
+		if !expired && !full {
+			break
+		}
+		c.idleClients.Remove(e)
+		pc.idle = nil
+		delete(c.clients, pc.projectID)
+		evicted = append(evicted, pc)
+	}
+	return evicted
+}
+
+// closeClients closes evicted clients. A client failing to close is dropped all the same.
+func closeClients(evicted []*pooledClient) {
+	for _, pc := range evicted {
+		_ = pc.api.Close()
+	}
+}
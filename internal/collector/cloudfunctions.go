@@ -0,0 +1,176 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/auth"
+	"github.com/NissesSenap/gcp-visualizer/internal/storage"
+	cloudfunctions "google.golang.org/api/cloudfunctions/v2"
+)
+
+// CloudFunctionsAPI lists the Cloud Functions of a project in every region.
+// The v2 API returns both 1st and 2nd gen functions. It is implemented by the
+// Cloud Functions REST client and can be faked in tests.
+type CloudFunctionsAPI interface {
+	ListFunctions(ctx context.Context, projectID string) ([]*cloudfunctions.Function, error)
+}
+
+// NewCloudFunctionsAPI creates a CloudFunctionsAPI backed by the Cloud Functions API
+func NewCloudFunctionsAPI(ctx context.Context, opts auth.Options) (CloudFunctionsAPI, error) {
+	clientOpts, err := auth.ClientOptions(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	svc, err := cloudfunctions.NewService(ctx, clientOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cloud functions client: %w", err)
+	}
+	return &cloudFunctionsAPI{svc: svc}, nil
+}
+
+type cloudFunctionsAPI struct {
+	svc *cloudfunctions.Service
+}
+
+func (a *cloudFunctionsAPI) ListFunctions(ctx context.Context, projectID string) ([]*cloudfunctions.Function, error) {
+	var functions []*cloudfunctions.Function
+	err := a.svc.Projects.Locations.Functions.List(allLocations(projectID)).Pages(ctx, func(resp *cloudfunctions.ListFunctionsResponse) error {
+		functions = append(functions, resp.Functions...)
+		return nil
+	})
+	return functions, err
+}
+
+// SetCloudFunctionsAPI registers the "functions" collector, collecting the Cloud
+// Functions of every project with api so functions triggered by a topic are drawn
+// next to its subscriptions. A nil api, the default, skips Cloud Functions.
+func (c *Collector) SetCloudFunctionsAPI(api CloudFunctionsAPI) {
+	if api == nil {
+		c.Unregister(CollectorCloudFunctions)
+		return
+	}
+	c.Register(&cloudFunctionsCollector{c: c, api: api})
+}
+
+// cloudFunctionsCollector collects the Cloud Functions of a project
+type cloudFunctionsCollector struct {
+	c   *Collector
+	api CloudFunctionsAPI
+}
+
+func (f *cloudFunctionsCollector) Name() string { return CollectorCloudFunctions }
+
+func (f *cloudFunctionsCollector) Tables() []storage.Stale {
+	return []storage.Stale{{Table: storage.TableCloudFunctions}}
+}
+
+func (f *cloudFunctionsCollector) Collect(ctx context.Context, projectID string) error {
+	if err := f.c.collectCloudFunctions(ctx, f.api, projectID); err != nil {
+		return fmt.Errorf("failed to collect cloud functions: %w", err)
+	}
+	return nil
+}
+
+// collectCloudFunctions stores the Cloud Functions of a project with their triggers
+func (c *Collector) collectCloudFunctions(ctx context.Context, api CloudFunctionsAPI, projectID string) error {
+	var listed []*cloudfunctions.Function
+	err := c.retryWithBackoff(ctx, func() error {
+		if err := c.wait(ctx, projectID); err != nil {
+			return fmt.Errorf("rate limiter error: %w", err)
+		}
+
+		var err error
+		listed, err = api.ListFunctions(ctx, projectID)
+		c.observeCall(err)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list functions: %w", err)
+	}
+
+	functions := make([]*storage.CloudFunction, 0, len(listed))
+	for _, fn := range listed {
+		record, err := newCloudFunction(projectID, fn)
+		if err != nil {
+			return err
+		}
+		functions = append(functions, record)
+	}
+	err = c.write(ctx, func(ctx context.Context) error {
+		return c.store(ctx).SaveCloudFunctions(ctx, functions)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save functions: %w", err)
+	}
+	c.observer.AddStored(projectID, "cloud_function", len(functions))
+	return nil
+}
+
+// functionGenerations maps the environment of a function to its generation
+var functionGenerations = map[string]int{
+	"GEN_1": 1,
+	"GEN_2": 2,
+}
+
+// newCloudFunction converts a listed function into its storage representation
+func newCloudFunction(projectID string, fn *cloudfunctions.Function) (*storage.CloudFunction, error) {
+	// fn.Name is in format "projects/{project}/locations/{region}/functions/{function}"
+	name := extractResourceName(fn.Name)
+	region := ""
+	if parts := strings.Split(fn.Name, "/"); len(parts) == 6 {
+		region = parts[3]
+	}
+
+	labels := fn.Labels
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	metadata := map[string]interface{}{
+		"labels": labels,
+		"state":  fn.State,
+	}
+	if fn.BuildConfig != nil && fn.BuildConfig.Runtime != "" {
+		metadata["runtime"] = fn.BuildConfig.Runtime
+	}
+	if cfg := fn.ServiceConfig; cfg != nil {
+		if cfg.ServiceAccountEmail != "" {
+			metadata["service_account"] = cfg.ServiceAccountEmail
+		}
+		// 2nd gen functions run as a Cloud Run service
+		if cfg.Service != "" {
+			metadata["service"] = cfg.Service
+		}
+	}
+
+	var topic, eventType string
+	if trigger := fn.EventTrigger; trigger != nil {
+		topic = trigger.PubsubTopic
+		eventType = trigger.EventType
+		// 2nd gen triggers are Eventarc triggers, which own the subscription delivering to the function
+		if trigger.Trigger != "" {
+			metadata["trigger"] = trigger.Trigger
+		}
+		if trigger.RetryPolicy != "" {
+			metadata["trigger_retry_policy"] = trigger.RetryPolicy
+		}
+	}
+
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode metadata of function %s: %w", name, err)
+	}
+
+	return &storage.CloudFunction{
+		Name:             name,
+		ProjectID:        projectID,
+		Region:           region,
+		FullResourceName: fn.Name,
+		Generation:       functionGenerations[fn.Environment],
+		TriggerTopic:     topic,
+		EventType:        eventType,
+		Metadata:         string(data),
+	}, nil
+}
@@ -0,0 +1,237 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/auth"
+	"github.com/NissesSenap/gcp-visualizer/internal/storage"
+	"google.golang.org/api/eventarc/v1"
+	run "google.golang.org/api/run/v2"
+)
+
+// CloudRunAPI lists the Cloud Run services of a project and the Eventarc triggers
+// delivering to them, in every region. It is implemented by the Cloud Run and
+// Eventarc REST clients and can be faked in tests.
+type CloudRunAPI interface {
+	ListServices(ctx context.Context, projectID string) ([]*run.GoogleCloudRunV2Service, error)
+	ListTriggers(ctx context.Context, projectID string) ([]*eventarc.Trigger, error)
+}
+
+// NewCloudRunAPI creates a CloudRunAPI backed by the Cloud Run and Eventarc APIs
+func NewCloudRunAPI(ctx context.Context, opts auth.Options) (CloudRunAPI, error) {
+	clientOpts, err := auth.ClientOptions(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	runService, err := run.NewService(ctx, clientOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cloud run client: %w", err)
+	}
+	eventarcService, err := eventarc.NewService(ctx, clientOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create eventarc client: %w", err)
+	}
+	return &cloudRunAPI{run: runService, eventarc: eventarcService}, nil
+}
+
+type cloudRunAPI struct {
+	run      *run.Service
+	eventarc *eventarc.Service
+}
+
+func (a *cloudRunAPI) ListServices(ctx context.Context, projectID string) ([]*run.GoogleCloudRunV2Service, error) {
+	var services []*run.GoogleCloudRunV2Service
+	err := a.run.Projects.Locations.Services.List(allLocations(projectID)).Pages(ctx, func(resp *run.GoogleCloudRunV2ListServicesResponse) error {
+		services = append(services, resp.Services...)
+		return nil
+	})
+	return services, err
+}
+
+func (a *cloudRunAPI) ListTriggers(ctx context.Context, projectID string) ([]*eventarc.Trigger, error) {
+	var triggers []*eventarc.Trigger
+	err := a.eventarc.Projects.Locations.Triggers.List(allLocations(projectID)).Pages(ctx, func(resp *eventarc.ListTriggersResponse) error {
+		triggers = append(triggers, resp.Triggers...)
+		return nil
+	})
+	return triggers, err
+}
+
+// allLocations is the parent listing a project's resources in every region
+func allLocations(projectID string) string {
+	return "projects/" + projectID + "/locations/-"
+}
+
+// SetCloudRunAPI registers the "cloudrun" collector, collecting the Cloud Run
+// services of every project with api so push subscriptions and Eventarc triggers
+// can be drawn to the services consuming them. A nil api, the default, skips Cloud Run.
+func (c *Collector) SetCloudRunAPI(api CloudRunAPI) {
+	if api == nil {
+		c.Unregister(CollectorCloudRun)
+		return
+	}
+	c.Register(&cloudRunCollector{c: c, api: api})
+}
+
+// cloudRunCollector collects the Cloud Run services and Eventarc triggers of a project
+type cloudRunCollector struct {
+	c   *Collector
+	api CloudRunAPI
+}
+
+func (r *cloudRunCollector) Name() string { return CollectorCloudRun }
+
+func (r *cloudRunCollector) Tables() []storage.Stale {
+	return []storage.Stale{
+		{Table: storage.TableCloudRunServices},
+		{Table: storage.TableDestinations, Kind: storage.DestinationTypeCloudRun},
+	}
+}
+
+func (r *cloudRunCollector) Collect(ctx context.Context, projectID string) error {
+	if err := r.c.collectCloudRun(ctx, r.api, projectID); err != nil {
+		return fmt.Errorf("failed to collect cloud run services: %w", err)
+	}
+	return nil
+}
+
+// collectCloudRun stores the Cloud Run services of a project, and the subscriptions
+// of its Eventarc triggers as destinations delivering to those services
+func (c *Collector) collectCloudRun(ctx context.Context, api CloudRunAPI, projectID string) error {
+	var listed []*run.GoogleCloudRunV2Service
+	err := c.retryWithBackoff(ctx, func() error {
+		if err := c.wait(ctx, projectID); err != nil {
+			return fmt.Errorf("rate limiter error: %w", err)
+		}
+
+		var err error
+		listed, err = api.ListServices(ctx, projectID)
+		c.observeCall(err)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list services: %w", err)
+	}
+
+	services := make([]*storage.CloudRunService, 0, len(listed))
+	for _, svc := range listed {
+		record, err := newCloudRunService(projectID, svc)
+		if err != nil {
+			return err
+		}
+		services = append(services, record)
+	}
+	err = c.write(ctx, func(ctx context.Context) error {
+		return c.store(ctx).SaveCloudRunServices(ctx, services)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save services: %w", err)
+	}
+	c.observer.AddStored(projectID, "cloud_run_service", len(services))
+
+	var triggers []*eventarc.Trigger
+	err = c.retryWithBackoff(ctx, func() error {
+		if err := c.wait(ctx, projectID); err != nil {
+			return fmt.Errorf("rate limiter error: %w", err)
+		}
+
+		var err error
+		triggers, err = api.ListTriggers(ctx, projectID)
+		c.observeCall(err)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list eventarc triggers: %w", err)
+	}
+
+	for _, trigger := range triggers {
+		dest, err := triggerDestination(projectID, trigger)
+		if err != nil {
+			return fmt.Errorf("failed to read destination of trigger %s: %w", trigger.Name, err)
+		}
+		if dest == nil {
+			continue
+		}
+		err = c.write(ctx, func(ctx context.Context) error {
+			return c.store(ctx).SaveSubscriptionDestination(ctx, dest)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to save destination of trigger %s: %w", trigger.Name, err)
+		}
+	}
+	return nil
+}
+
+// newCloudRunService converts a listed Cloud Run service into its storage representation
+func newCloudRunService(projectID string, svc *run.GoogleCloudRunV2Service) (*storage.CloudRunService, error) {
+	// svc.Name is in format "projects/{project}/locations/{region}/services/{service}"
+	name := extractResourceName(svc.Name)
+	region := ""
+	if parts := strings.Split(svc.Name, "/"); len(parts) == 6 {
+		region = parts[3]
+	}
+
+	urls := svc.Urls
+	if svc.Uri != "" && !slices.Contains(urls, svc.Uri) {
+		urls = append([]string{svc.Uri}, urls...)
+	}
+
+	labels := svc.Labels
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	metadata := map[string]interface{}{
+		"labels":  labels,
+		"ingress": svc.Ingress,
+	}
+	if svc.Template != nil && svc.Template.ServiceAccount != "" {
+		metadata["service_account"] = svc.Template.ServiceAccount
+	}
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode metadata of service %s: %w", name, err)
+	}
+
+	return &storage.CloudRunService{
+		Name:             name,
+		ProjectID:        projectID,
+		Region:           region,
+		FullResourceName: svc.Name,
+		URLs:             urls,
+		Metadata:         string(data),
+	}, nil
+}
+
+// triggerDestination returns the Cloud Run service an Eventarc trigger delivers the
+// messages of its Pub/Sub subscription to, or nil if the trigger has no Pub/Sub
+// transport or delivers somewhere else
+func triggerDestination(projectID string, trigger *eventarc.Trigger) (*storage.SubscriptionDestination, error) {
+	if trigger.Transport == nil || trigger.Transport.Pubsub == nil || trigger.Transport.Pubsub.Subscription == "" {
+		return nil, nil
+	}
+	if trigger.Destination == nil || trigger.Destination.CloudRun == nil {
+		return nil, nil
+	}
+	target := trigger.Destination.CloudRun
+
+	data, err := json.Marshal(map[string]string{
+		"trigger": trigger.Name,
+		"path":    target.Path,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The service runs in the trigger's project
+	return &storage.SubscriptionDestination{
+		SubscriptionFullResourceName: trigger.Transport.Pubsub.Subscription,
+		ProjectID:                    projectID,
+		Type:                         storage.DestinationTypeCloudRun,
+		Resource:                     fmt.Sprintf("projects/%s/locations/%s/services/%s", projectID, target.Region, target.Service),
+		Metadata:                     string(data),
+	}, nil
+}
@@ -1,96 +1,368 @@
 package collector
 
 import (
+	"container/list"
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 	"sync"
+	"time"
 
-	"cloud.google.com/go/pubsub/v2"
-	"github.com/NissesSenap/gcp-visualizer/internal/auth"
 	"github.com/NissesSenap/gcp-visualizer/internal/storage"
+	"golang.org/x/sync/errgroup"
 	"golang.org/x/time/rate"
 )
 
+const (
+	// defaultSaveWorkers bounds how many batches of one kind are written to storage concurrently
+	defaultSaveWorkers = 4
+
+	// defaultBatchSize is the number of resources written per storage transaction
+	defaultBatchSize = 500
+
+	// defaultWriteTimeout bounds a single storage write
+	defaultWriteTimeout = 30 * time.Second
+
+	// defaultMaxClients bounds the Pub/Sub clients kept open, see SetClientLimits
+	defaultMaxClients = 20
+
+	// defaultClientIdleTimeout closes Pub/Sub clients no collection used for this long
+	defaultClientIdleTimeout = time.Minute
+)
+
 // Collector manages GCP resource collection
 type Collector struct {
-	mu      sync.RWMutex // Protects clients map for concurrent access
-	clients map[string]*pubsub.Client
+	mu      sync.Mutex // Protects clients and idleClients for concurrent access
+	clients map[string]*pooledClient
+	newAPI  APIFactory
+
+	// idleClients are the clients no collection uses, least recently released first
+	idleClients *list.List
+
+	// maxClients bounds the clients kept open, zero keeps every client
+	maxClients int
+
+	// clientIdleTimeout closes clients idle for longer, zero keeps idle clients
+	clientIdleTimeout time.Duration
+
 	storage storage.Store
 	limiter *rate.Limiter
+
+	// saveWorkers bounds the concurrent batch saves within one collector
+	saveWorkers int
+
+	// batchSize is the number of resources written per storage transaction
+	batchSize int
+
+	// writeTimeout bounds a single storage write, zero disables it
+	writeTimeout time.Duration
+
+	// projectTimeout bounds the collection of a single project, zero disables it
+	projectTimeout time.Duration
+
+	// keepStale skips removing resources that the latest scan didn't see
+	keepStale bool
+
+	// incremental only records topics and subscriptions whose listing matches the cache as seen, instead of rewriting them
+	incremental bool
+
+	// readOnly refuses to run any collector registered as mutating
+	readOnly bool
+
+	// checkService probes whether the Pub/Sub API is enabled before listing, nil skips the probe
+	checkService ServiceChecker
+
+	// collectors run in order for every project, see Register
+	collectors []ResourceCollector
+
+	// observer receives per-project collection metrics
+	observer Observer
+
+	// retry controls how failed API calls are retried
+	retry RetryPolicy
+
+	// adaptive lowers the limiter's rate on rate-limit errors, nil keeps it fixed
+	adaptive *aimdLimiter
+
+	// calls counts the API calls waiting on the limiter, see SetAPICallBudget
+	calls apiCalls
+}
+
+// Observer receives metrics about collections, e.g. to export them to Prometheus
+type Observer interface {
+	// ObserveProject is called once a project collection finishes
+	ObserveProject(projectID string, duration time.Duration, err error)
+
+	// AddStored is called after n resources of a kind were collected for storage. They are
+	// written once the project collection succeeds, and dropped if it fails.
+	AddStored(projectID, kind string, n int)
 }
 
-// New creates a new Collector with the provided storage and rate limiter
+type nopObserver struct{}
+
+func (nopObserver) ObserveProject(string, time.Duration, error) {}
+func (nopObserver) AddStored(string, string, int)               {}
+
+// New creates a new Collector with the provided storage and rate limiter,
+// talking to Pub/Sub with Application Default Credentials
 func New(store storage.Store, requestsPerSecond float64) *Collector {
-	return &Collector{
-		clients: make(map[string]*pubsub.Client),
-		storage: store,
-		limiter: rate.NewLimiter(rate.Limit(requestsPerSecond), int(requestsPerSecond*2)),
+	return NewWithAPI(store, requestsPerSecond, NewPubSubAPI)
+}
+
+// NewWithAPI creates a new Collector that creates its per-project Pub/Sub API with newAPI
+func NewWithAPI(store storage.Store, requestsPerSecond float64, newAPI APIFactory) *Collector {
+	c := &Collector{
+		clients:           make(map[string]*pooledClient),
+		newAPI:            newAPI,
+		idleClients:       list.New(),
+		maxClients:        defaultMaxClients,
+		clientIdleTimeout: defaultClientIdleTimeout,
+		storage:           store,
+		limiter:           rate.NewLimiter(rate.Limit(requestsPerSecond), int(requestsPerSecond*2)),
+		saveWorkers:       defaultSaveWorkers,
+		batchSize:         defaultBatchSize,
+		writeTimeout:      defaultWriteTimeout,
+		readOnly:          true,
+		observer:          nopObserver{},
+		retry:             DefaultRetryPolicy,
+		calls:             apiCalls{byProject: make(map[string]int64)},
+	}
+	c.Register(&pubsubCollector{c: c})
+	return c
+}
+
+// SetReadOnly enables or disables the read-only guard (enabled by default)
+func (c *Collector) SetReadOnly(readOnly bool) {
+	c.readOnly = readOnly
+}
+
+// SetBatchSize sets the number of resources written per storage transaction.
+// Values below 1 restore the default.
+func (c *Collector) SetBatchSize(size int) {
+	if size < 1 {
+		size = defaultBatchSize
 	}
+	c.batchSize = size
+}
+
+// SetKeepStale keeps cached resources that a project collection no longer sees,
+// instead of removing them once the collection succeeds
+func (c *Collector) SetKeepStale(keepStale bool) {
+	c.keepStale = keepStale
+}
+
+// SetIncremental skips rewriting the topics and subscriptions a listing shows unchanged
+// since they were cached, only recording that they were seen. Their destinations and
+// consumers are still collected.
+func (c *Collector) SetIncremental(incremental bool) {
+	c.incremental = incremental
 }
 
-// getClient returns a cached client for the project, or creates a new one.
-// This method is thread-safe and uses double-checked locking for optimal performance.
-// The client creation I/O operation happens outside the lock to avoid blocking other goroutines.
-func (c *Collector) getClient(ctx context.Context, projectID string) (*pubsub.Client, error) {
-	// First check with read lock (fast path for existing clients)
-	c.mu.RLock()
-	client, exists := c.clients[projectID]
-	c.mu.RUnlock()
-	if exists {
-		return client, nil
+// SetProjectTimeout bounds the collection of every project, so a pathological project
+// fails instead of stalling the scan. Values below 1 disable the timeout.
+func (c *Collector) SetProjectTimeout(timeout time.Duration) {
+	c.projectTimeout = max(timeout, 0)
+}
+
+// SetObserver reports collection metrics to o
+func (c *Collector) SetObserver(o Observer) {
+	if o == nil {
+		o = nopObserver{}
 	}
+	c.observer = o
+}
+
+// getClient returns a cached client for the project, or creates a new one, and a release
+// function to call once the caller is done with it. Released clients are kept open for the
+// next collection of the project until they're evicted, see SetClientLimits.
+// This method is thread-safe; the client creation I/O operation happens outside the lock
+// to avoid blocking other goroutines.
+func (c *Collector) getClient(ctx context.Context, projectID string) (PubSubAPI, func(), error) {
+	// Fast path for existing clients
+	c.mu.Lock()
+	if pc, exists := c.clients[projectID]; exists {
+		c.acquireClient(pc)
+		c.mu.Unlock()
+		return pc.api, func() { c.releaseClient(pc) }, nil
+	}
+	c.mu.Unlock()
 
 	// Create new client WITHOUT holding the lock
 	// This allows other goroutines to proceed with their own I/O operations concurrently
-	newClient, err := auth.NewPubSubClient(ctx, projectID)
+	newClient, err := c.newAPI(ctx, projectID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create pubsub client for project %s: %w", projectID, err)
+		return nil, nil, fmt.Errorf("failed to create pubsub client for project %s: %w", projectID, err)
+	}
+	if r, ok := newClient.(retryingAPI); ok {
+		r.setRetry(c.retry, c.observeCall)
 	}
 
-	// Acquire write lock only to store the client in the map
 	c.mu.Lock()
-	defer c.mu.Unlock()
-
 	// Double-check: another goroutine might have created and stored a client
 	// while we were creating ours (race condition handling)
-	if existingClient, exists := c.clients[projectID]; exists {
+	if pc, exists := c.clients[projectID]; exists {
 		// Another goroutine won the race and stored their client first
 		// Close our client to avoid resource leak and return the existing one
+		c.acquireClient(pc)
+		c.mu.Unlock()
 		_ = newClient.Close()
-		return existingClient, nil
+		return pc.api, func() { c.releaseClient(pc) }, nil
 	}
 
-	// We won the race (or there was no race) - store our client
-	c.clients[projectID] = newClient
-	return newClient, nil
+	// We won the race (or there was no race) - store our client, making room for it
+	pc := &pooledClient{projectID: projectID, api: newClient}
+	c.clients[projectID] = pc
+	c.acquireClient(pc)
+	evicted := c.evictClients(time.Now())
+	c.mu.Unlock()
+
+	closeClients(evicted)
+	return newClient, func() { c.releaseClient(pc) }, nil
 }
 
-// CollectProject collects all Pub/Sub resources from a single project
+// CollectProject runs every registered collector for a single project
 func (c *Collector) CollectProject(ctx context.Context, projectID string) error {
-	client, err := c.getClient(ctx, projectID)
-	if err != nil {
-		return err
+	start := time.Now()
+	if c.projectTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.projectTimeout)
+		defer cancel()
+	}
+	err := c.collectProject(ctx, projectID)
+
+	// Make sure a timed out project is classified as one, see timedOut
+	if timedOut(ctx, err) && !errors.Is(err, context.DeadlineExceeded) {
+		err = fmt.Errorf("%w: %w", context.DeadlineExceeded, err)
+	}
+
+	// A project skipped because the API is disabled isn't a failed collection
+	observed := err
+	if errors.Is(err, ErrAPIDisabled) {
+		observed = nil
 	}
+	c.observer.ObserveProject(projectID, time.Since(start), observed)
+	return err
+}
 
-	// Collect topics
-	if err := c.collectTopics(ctx, client, projectID); err != nil {
-		return fmt.Errorf("failed to collect topics: %w", err)
+func (c *Collector) collectProject(ctx context.Context, projectID string) error {
+	if c.readOnly {
+		if err := CheckReadOnly(collectorSpecs); err != nil {
+			return err
+		}
 	}
 
-	// Collect subscriptions
-	if err := c.collectSubscriptions(ctx, client, projectID); err != nil {
-		return fmt.Errorf("failed to collect subscriptions: %w", err)
+	// Everything this collection writes is synced at or after started
+	started := time.Now()
+
+	// Hold the writes back and apply them in one transaction once every collector succeeded,
+	// so a failed collection leaves the project as the previous one cached it
+	staged := storage.NewStaged(c.storage)
+	ctx = context.WithValue(ctx, stagedKey{}, staged)
+
+	// Collectors describe their own failures, e.g. "failed to collect topics: ..."
+	for _, rc := range c.collectors {
+		if err := rc.Collect(ctx, projectID); err != nil {
+			return err
+		}
+	}
+
+	// Remove resources deleted in GCP since the previous scan, from the tables of the
+	// collectors that ran only. Disabling a collector must not look like its resources were deleted.
+	if stale := staleTables(c.collectors); !c.keepStale && len(stale) > 0 {
+		err := c.write(ctx, func(ctx context.Context) error {
+			_, err := c.store(ctx).DeleteStaleResources(ctx, projectID, started, stale)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to delete stale resources: %w", err)
+		}
 	}
 
 	// Update project sync time
-	if err := c.storage.UpdateProjectSyncTime(ctx, projectID); err != nil {
+	err := c.write(ctx, func(ctx context.Context) error {
+		return c.store(ctx).UpdateProjectSyncTime(ctx, projectID)
+	})
+	if err != nil {
 		return fmt.Errorf("failed to update project sync time: %w", err)
 	}
 
+	resources := staged.Resources()
+	if err := c.write(ctx, staged.Commit); err != nil {
+		return &BatchError{ProjectID: projectID, Kind: "resource", Size: resources, Err: err}
+	}
 	return nil
 }
 
+// stagedKey carries the staged writes of a project collection
+type stagedKey struct{}
+
+// store returns the store to write to: the staged writes of the project collection ctx
+// belongs to, committed together once it succeeds, or the storage itself outside of one
+func (c *Collector) store(ctx context.Context) storage.Store {
+	if staged, ok := ctx.Value(stagedKey{}).(*storage.Staged); ok {
+		return staged
+	}
+	return c.storage
+}
+
+// pubsubCollector collects the topics and subscriptions of a project, with the
+// destinations and consumers of each subscription
+type pubsubCollector struct {
+	c *Collector
+}
+
+func (p *pubsubCollector) Name() string { return CollectorPubSub }
+
+func (p *pubsubCollector) Tables() []storage.Stale {
+	return []storage.Stale{
+		{Table: storage.TableTopics},
+		{Table: storage.TableSubscriptions},
+		{Table: storage.TableDestinations, Kind: storage.DestinationTypeBigQuery},
+		{Table: storage.TableDestinations, Kind: storage.DestinationTypeCloudStorage},
+		{Table: storage.TableEdges, Kind: storage.RelationCanPublish},
+	}
+}
+
+func (p *pubsubCollector) Collect(ctx context.Context, projectID string) error {
+	c := p.c
+	if err := c.checkEnabled(ctx, projectID); err != nil {
+		// Recorded so reports can tell the project apart from one that was never scanned. Written
+		// directly, since the collection fails and its staged writes are dropped.
+		if errors.Is(err, ErrAPIDisabled) {
+			if err := c.write(ctx, func(ctx context.Context) error {
+				return c.storage.SetProjectStatus(ctx, projectID, storage.ProjectStatusAPIDisabled)
+			}); err != nil {
+				return fmt.Errorf("failed to save project status: %w", err)
+			}
+		}
+		return err
+	}
+
+	client, release, err := c.getClient(ctx, projectID)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	// Collect topics and subscriptions concurrently; they share the rate limiter,
+	// and the first failure cancels the other
+	g, gctx := errgroup.WithContext(ctx)
+	g.Go(func() error {
+		if err := c.collectTopics(gctx, client, projectID); err != nil {
+			return fmt.Errorf("failed to collect topics: %w", err)
+		}
+		return nil
+	})
+	g.Go(func() error {
+		if err := c.collectSubscriptions(gctx, client, projectID); err != nil {
+			return fmt.Errorf("failed to collect subscriptions: %w", err)
+		}
+		return nil
+	})
+	return g.Wait()
+}
+
 // Close closes all Pub/Sub clients, collecting all errors.
 // Even if some clients fail to close, all others will still be closed.
 func (c *Collector) Close() error {
@@ -98,8 +370,8 @@ func (c *Collector) Close() error {
 	defer c.mu.Unlock()
 
 	var errs []error
-	for projectID, client := range c.clients {
-		if err := client.Close(); err != nil {
+	for projectID, pc := range c.clients {
+		if err := pc.api.Close(); err != nil {
 			errs = append(errs, fmt.Errorf("failed to close client for project %s: %w", projectID, err))
 		}
 	}
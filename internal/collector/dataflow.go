@@ -0,0 +1,234 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/auth"
+	"github.com/NissesSenap/gcp-visualizer/internal/storage"
+	dataflow "google.golang.org/api/dataflow/v1b3"
+)
+
+// DataflowAPI lists the active Dataflow jobs of a project in every region and
+// reads their pipeline options. It is implemented by the Dataflow REST client
+// and can be faked in tests.
+type DataflowAPI interface {
+	// ListJobs returns summaries of the active jobs, without their pipeline options
+	ListJobs(ctx context.Context, projectID string) ([]*dataflow.Job, error)
+	GetJob(ctx context.Context, projectID, region, jobID string) (*dataflow.Job, error)
+}
+
+// NewDataflowAPI creates a DataflowAPI backed by the Dataflow API
+func NewDataflowAPI(ctx context.Context, opts auth.Options) (DataflowAPI, error) {
+	clientOpts, err := auth.ClientOptions(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	svc, err := dataflow.NewService(ctx, clientOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dataflow client: %w", err)
+	}
+	return &dataflowAPI{svc: svc}, nil
+}
+
+type dataflowAPI struct {
+	svc *dataflow.Service
+}
+
+func (a *dataflowAPI) ListJobs(ctx context.Context, projectID string) ([]*dataflow.Job, error) {
+	var jobs []*dataflow.Job
+	err := a.svc.Projects.Jobs.Aggregated(projectID).Filter("ACTIVE").Pages(ctx, func(resp *dataflow.ListJobsResponse) error {
+		jobs = append(jobs, resp.Jobs...)
+		return nil
+	})
+	return jobs, err
+}
+
+func (a *dataflowAPI) GetJob(ctx context.Context, projectID, region, jobID string) (*dataflow.Job, error) {
+	return a.svc.Projects.Locations.Jobs.Get(projectID, region, jobID).View("JOB_VIEW_ALL").Context(ctx).Do()
+}
+
+// SetDataflowAPI registers the "dataflow" collector, collecting the active Dataflow
+// jobs of every project with api so streaming pipelines are drawn between the
+// topics they read and write. A nil api, the default, skips Dataflow.
+func (c *Collector) SetDataflowAPI(api DataflowAPI) {
+	if api == nil {
+		c.Unregister(CollectorDataflow)
+		return
+	}
+	c.Register(&dataflowCollector{c: c, api: api})
+}
+
+// dataflowCollector collects the active Dataflow jobs of a project
+type dataflowCollector struct {
+	c   *Collector
+	api DataflowAPI
+}
+
+func (d *dataflowCollector) Name() string { return CollectorDataflow }
+
+func (d *dataflowCollector) Tables() []storage.Stale {
+	return []storage.Stale{{Table: storage.TableDataflowJobs}}
+}
+
+func (d *dataflowCollector) Collect(ctx context.Context, projectID string) error {
+	if err := d.c.collectDataflow(ctx, d.api, projectID); err != nil {
+		return fmt.Errorf("failed to collect dataflow jobs: %w", err)
+	}
+	return nil
+}
+
+// collectDataflow stores the active Dataflow jobs of a project with the Pub/Sub
+// resources they read and write
+func (c *Collector) collectDataflow(ctx context.Context, api DataflowAPI, projectID string) error {
+	var listed []*dataflow.Job
+	err := c.retryWithBackoff(ctx, func() error {
+		if err := c.wait(ctx, projectID); err != nil {
+			return fmt.Errorf("rate limiter error: %w", err)
+		}
+
+		var err error
+		listed, err = api.ListJobs(ctx, projectID)
+		c.observeCall(err)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list jobs: %w", err)
+	}
+
+	jobs := make([]*storage.DataflowJob, 0, len(listed))
+	for _, summary := range listed {
+		// Summaries leave out the pipeline options holding the Pub/Sub parameters
+		var job *dataflow.Job
+		err := c.retryWithBackoff(ctx, func() error {
+			if err := c.wait(ctx, projectID); err != nil {
+				return fmt.Errorf("rate limiter error: %w", err)
+			}
+
+			var err error
+			job, err = api.GetJob(ctx, projectID, summary.Location, summary.Id)
+			c.observeCall(err)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to get job %s: %w", summary.Name, err)
+		}
+
+		record, err := newDataflowJob(projectID, job)
+		if err != nil {
+			return err
+		}
+		jobs = append(jobs, record)
+	}
+	err = c.write(ctx, func(ctx context.Context) error {
+		return c.store(ctx).SaveDataflowJobs(ctx, jobs)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save jobs: %w", err)
+	}
+	c.observer.AddStored(projectID, "dataflow_job", len(jobs))
+	return nil
+}
+
+// newDataflowJob converts a Dataflow job into its storage representation
+func newDataflowJob(projectID string, job *dataflow.Job) (*storage.DataflowJob, error) {
+	sources, sinks, err := dataflowPubSub(job)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pipeline options of job %s: %w", job.Name, err)
+	}
+
+	labels := job.Labels
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	metadata := map[string]interface{}{
+		"labels":   labels,
+		"job_id":   job.Id,
+		"state":    job.CurrentState,
+		"job_type": job.Type,
+	}
+	if job.Environment != nil && job.Environment.ServiceAccountEmail != "" {
+		metadata["service_account"] = job.Environment.ServiceAccountEmail
+	}
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode metadata of job %s: %w", job.Name, err)
+	}
+
+	return &storage.DataflowJob{
+		Name:             job.Name,
+		ProjectID:        projectID,
+		Region:           job.Location,
+		FullResourceName: fmt.Sprintf("projects/%s/locations/%s/jobs/%s", projectID, job.Location, job.Id),
+		Sources:          sources,
+		Sinks:            sinks,
+		Metadata:         string(data),
+	}, nil
+}
+
+// dataflowPubSub returns the topics and subscriptions a job reads and the topics it
+// writes. They are read from the pipeline options, where templates such as
+// "Pub/Sub to Pub/Sub" take them as parameters like inputSubscription and
+// outputTopic. Topics in an option named like an output, sink or dead-letter are
+// written, every other topic and subscription is read. Subscriptions the Dataflow
+// service reports reading from are included, in case the pipeline hard-codes them.
+func dataflowPubSub(job *dataflow.Job) (sources, sinks []string, err error) {
+	read := make(map[string]bool)
+	written := make(map[string]bool)
+
+	if job.Environment != nil && len(job.Environment.SdkPipelineOptions) > 0 {
+		var pipeline struct {
+			Options map[string]interface{} `json:"options"`
+		}
+		if err := json.Unmarshal(job.Environment.SdkPipelineOptions, &pipeline); err != nil {
+			return nil, nil, err
+		}
+		for key, value := range pipeline.Options {
+			resource, ok := value.(string)
+			if !ok {
+				continue
+			}
+			switch parts := strings.Split(resource, "/"); {
+			case len(parts) != 4 || parts[0] != "projects":
+			case parts[2] == "subscriptions":
+				read[resource] = true
+			case parts[2] == "topics" && isSinkOption(key):
+				written[resource] = true
+			case parts[2] == "topics":
+				read[resource] = true
+			}
+		}
+	}
+	if job.JobMetadata != nil {
+		for _, details := range job.JobMetadata.PubsubDetails {
+			if details.Subscription != "" {
+				read[details.Subscription] = true
+			}
+		}
+	}
+
+	return sortedKeys(read), sortedKeys(written), nil
+}
+
+// isSinkOption reports whether a pipeline option names a topic the job writes
+func isSinkOption(key string) bool {
+	key = strings.ToLower(key)
+	for _, marker := range []string{"output", "sink", "deadletter", "dead_letter"} {
+		if strings.Contains(key, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// sortedKeys returns the keys of a set in order, nil if it is empty
+func sortedKeys(set map[string]bool) []string {
+	if len(set) == 0 {
+		return nil
+	}
+	return slices.Sorted(maps.Keys(set))
+}
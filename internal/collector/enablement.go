@@ -0,0 +1,63 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/auth"
+	"google.golang.org/api/serviceusage/v1"
+)
+
+// pubsubService is the Service Usage name of the Pub/Sub API
+const pubsubService = "pubsub.googleapis.com"
+
+// ErrAPIDisabled is returned by CollectProject when the Pub/Sub API isn't enabled
+// in the project. Scans report it as a skip rather than a failure, retrying won't help.
+var ErrAPIDisabled = errors.New("Pub/Sub API is disabled")
+
+// ServiceChecker reports whether the Pub/Sub API is enabled in a project
+type ServiceChecker func(ctx context.Context, projectID string) (bool, error)
+
+// NewServiceUsageChecker returns a ServiceChecker backed by the Service Usage API
+func NewServiceUsageChecker(ctx context.Context, opts auth.Options) (ServiceChecker, error) {
+	clientOpts, err := auth.ClientOptions(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	svc, err := serviceusage.NewService(ctx, clientOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create service usage client: %w", err)
+	}
+
+	return func(ctx context.Context, projectID string) (bool, error) {
+		service, err := svc.Services.Get("projects/" + projectID + "/services/" + pubsubService).Context(ctx).Do()
+		if err != nil {
+			return false, err
+		}
+		return service.State == "ENABLED", nil
+	}, nil
+}
+
+// SetServiceChecker probes every project with check before listing it.
+// A nil check, the default, lists projects without probing.
+func (c *Collector) SetServiceChecker(check ServiceChecker) {
+	c.checkService = check
+}
+
+// checkEnabled returns ErrAPIDisabled if the probe says the Pub/Sub API is disabled.
+// The probe is best effort: if it fails, e.g. without serviceusage.services.get,
+// the project is listed anyway and any real problem surfaces there.
+func (c *Collector) checkEnabled(ctx context.Context, projectID string) error {
+	if c.checkService == nil {
+		return nil
+	}
+	enabled, err := c.checkService(ctx, projectID)
+	if err != nil {
+		return nil
+	}
+	if !enabled {
+		return ErrAPIDisabled
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package collector
+
+// ResourceCounts are the resources of a project that the number of API requests of a scan depends on,
+// usually taken from the cache as of the previous scan
+type ResourceCounts struct {
+	Topics        int
+	Subscriptions int
+	DataflowJobs  int
+	LiteLocations int // locations the pubsublite collector lists, from the config
+}
+
+// EstimateRequests returns the rate-limited API requests each of the named collectors makes
+// for a project with the given resources. Listings wait on the rate limiter once per listed
+// resource, so every topic and subscription counts twice with its IAM policy lookup.
+// The publishers collector counts one request, its audit log pages aren't known in advance.
+func EstimateRequests(collectors []string, counts ResourceCounts) map[string]int {
+	requests := make(map[string]int, len(collectors))
+	for _, name := range collectors {
+		switch name {
+		case CollectorPubSub:
+			// The API enablement check and one per resource and IAM policy
+			requests[name] = 1 + 2*(counts.Topics+counts.Subscriptions)
+		case CollectorCloudRun:
+			requests[name] = 2 // services and Eventarc triggers
+		case CollectorCloudFunctions:
+			requests[name] = 1
+		case CollectorDataflow:
+			requests[name] = 1 + counts.DataflowJobs
+		case CollectorPublishers:
+			requests[name] = 1
+		case CollectorMetrics:
+			requests[name] = len(pubsubMetrics)
+		case CollectorProjects:
+			requests[name] = 1
+		case CollectorPubSubLite:
+			requests[name] = 2 * counts.LiteLocations // topics and subscriptions
+		}
+	}
+	return requests
+}
@@ -0,0 +1,120 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"cloud.google.com/go/iam/apiv1/iampb"
+	"github.com/NissesSenap/gcp-visualizer/internal/storage"
+)
+
+// consumerRoles are the roles that allow a principal to pull from a subscription
+var consumerRoles = map[string]bool{
+	"roles/pubsub.subscriber": true,
+}
+
+// collectSubscriptionIAM stores the principals holding a subscriber role on a subscription
+func (c *Collector) collectSubscriptionIAM(ctx context.Context, client SubscriptionLister, projectID, subscription string) error {
+	var policy *iampb.Policy
+	err := c.retryWithBackoff(ctx, func() error {
+		if err := c.wait(ctx, projectID); err != nil {
+			return fmt.Errorf("rate limiter error: %w", err)
+		}
+
+		var err error
+		policy, err = client.GetIamPolicy(ctx, &iampb.GetIamPolicyRequest{
+			Resource: subscription,
+		})
+		c.observeCall(err)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get IAM policy: %w", err)
+	}
+
+	return c.write(ctx, func(ctx context.Context) error {
+		return c.store(ctx).ReplaceSubscriptionConsumers(ctx, subscription, storage.ConsumerSourceIAM,
+			subscriptionConsumers(policy, projectID))
+	})
+}
+
+// subscriptionConsumers returns one consumer per principal and consumer role in the policy.
+// Public members (allUsers, allAuthenticatedUsers) are skipped as they aren't identities.
+func subscriptionConsumers(policy *iampb.Policy, projectID string) []*storage.SubscriptionConsumer {
+	var consumers []*storage.SubscriptionConsumer
+	for _, binding := range policy.GetBindings() {
+		if !consumerRoles[binding.GetRole()] {
+			continue
+		}
+		for _, member := range binding.GetMembers() {
+			if member == "allUsers" || member == "allAuthenticatedUsers" {
+				continue
+			}
+			consumers = append(consumers, &storage.SubscriptionConsumer{
+				ProjectID: projectID,
+				Principal: member,
+				Source:    storage.ConsumerSourceIAM,
+				Role:      binding.GetRole(),
+			})
+		}
+	}
+	sort.Slice(consumers, func(i, j int) bool { return consumers[i].Principal < consumers[j].Principal })
+	return consumers
+}
+
+// publisherRoles are the roles that allow a principal to publish to a topic
+var publisherRoles = map[string]bool{
+	"roles/pubsub.publisher": true,
+}
+
+// collectTopicIAM stores an edge from every principal holding a publisher role on a topic
+// to the topic. They are inferred publishers, the audit logs tell who actually publishes.
+func (c *Collector) collectTopicIAM(ctx context.Context, client TopicLister, projectID, topic string) error {
+	var policy *iampb.Policy
+	err := c.retryWithBackoff(ctx, func() error {
+		if err := c.wait(ctx, projectID); err != nil {
+			return fmt.Errorf("rate limiter error: %w", err)
+		}
+
+		var err error
+		policy, err = client.GetIamPolicy(ctx, &iampb.GetIamPolicyRequest{
+			Resource: topic,
+		})
+		c.observeCall(err)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get IAM policy: %w", err)
+	}
+
+	return c.write(ctx, func(ctx context.Context) error {
+		return c.store(ctx).SaveEdges(ctx, topicPublishers(policy, projectID, topic))
+	})
+}
+
+// topicPublishers returns one edge per principal with a publisher role in the policy of a topic,
+// skipping public members like subscriptionConsumers does
+func topicPublishers(policy *iampb.Policy, projectID, topic string) []*storage.ResourceEdge {
+	seen := make(map[string]bool)
+	var edges []*storage.ResourceEdge
+	for _, binding := range policy.GetBindings() {
+		if !publisherRoles[binding.GetRole()] {
+			continue
+		}
+		for _, member := range binding.GetMembers() {
+			if member == "allUsers" || member == "allAuthenticatedUsers" || seen[member] {
+				continue
+			}
+			seen[member] = true
+			edges = append(edges, &storage.ResourceEdge{
+				Source:    member,
+				Target:    topic,
+				Relation:  storage.RelationCanPublish,
+				ProjectID: projectID,
+			})
+		}
+	}
+	sort.Slice(edges, func(i, j int) bool { return edges[i].Source < edges[j].Source })
+	return edges
+}
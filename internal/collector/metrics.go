@@ -0,0 +1,284 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/auth"
+	"github.com/NissesSenap/gcp-visualizer/internal/storage"
+	monitoring "google.golang.org/api/monitoring/v3"
+)
+
+// MonitoringAPI lists the Pub/Sub time series of a project between start and end,
+// with every series aligned to a point per period. It is implemented by the Cloud
+// Monitoring REST client and can be faked in tests.
+type MonitoringAPI interface {
+	ListTimeSeries(ctx context.Context, projectID, metricType, aligner string, start, end time.Time, period time.Duration) ([]*monitoring.TimeSeries, error)
+}
+
+// NewMonitoringAPI creates a MonitoringAPI backed by the Cloud Monitoring API
+func NewMonitoringAPI(ctx context.Context, opts auth.Options) (MonitoringAPI, error) {
+	clientOpts, err := auth.ClientOptions(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	svc, err := monitoring.NewService(ctx, clientOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create monitoring client: %w", err)
+	}
+	return &monitoringAPI{svc: svc}, nil
+}
+
+type monitoringAPI struct {
+	svc *monitoring.Service
+}
+
+func (a *monitoringAPI) ListTimeSeries(ctx context.Context, projectID, metricType, aligner string, start, end time.Time, period time.Duration) ([]*monitoring.TimeSeries, error) {
+	var series []*monitoring.TimeSeries
+	err := a.svc.Projects.TimeSeries.List("projects/"+projectID).
+		Filter(fmt.Sprintf(`metric.type = "pubsub.googleapis.com/%s"`, metricType)).
+		IntervalStartTime(start.UTC().Format(time.RFC3339)).
+		IntervalEndTime(end.UTC().Format(time.RFC3339)).
+		AggregationAlignmentPeriod(fmt.Sprintf("%ds", int64(period/time.Second))).
+		AggregationPerSeriesAligner(aligner).
+		Pages(ctx, func(resp *monitoring.ListTimeSeriesResponse) error {
+			series = append(series, resp.TimeSeries...)
+			return nil
+		})
+	return series, err
+}
+
+// pubsubMetric is a metric read by the "metrics" collector
+type pubsubMetric struct {
+	metricType string // one of the storage.Metric constants
+	collection string // "topics" or "subscriptions", as in the full resource name
+	label      string // resource label holding the resource's ID
+	aligner    string
+	sum        bool // sum the points of a resource, otherwise keep the highest
+}
+
+// pubsubMetrics are the metrics stored per topic and subscription
+var pubsubMetrics = []pubsubMetric{
+	{storage.MetricTopicPublishOperations, "topics", "topic_id", "ALIGN_SUM", true},
+	{storage.MetricSubscriptionBacklog, "subscriptions", "subscription_id", "ALIGN_MAX", false},
+	{storage.MetricSubscriptionUnackedAge, "subscriptions", "subscription_id", "ALIGN_MAX", false},
+}
+
+// SetMonitoringAPI registers the "metrics" collector, storing the publish operations of every
+// topic, and the backlog and oldest unacked message age of every subscription, over the last window with api.
+// Datapoints are kept per resolution, so a scan only fetches those since the previous one; a zero
+// resolution keeps one per window. A nil api, the default, skips Cloud Monitoring.
+func (c *Collector) SetMonitoringAPI(api MonitoringAPI, window, resolution time.Duration) {
+	if api == nil {
+		c.Unregister(CollectorMetrics)
+		return
+	}
+	if resolution <= 0 || resolution > window {
+		resolution = window
+	}
+	c.Register(&metricsCollector{c: c, api: api, window: window, resolution: resolution, now: time.Now})
+}
+
+// metricsCollector collects the traffic metrics of the topics and subscriptions of a project
+type metricsCollector struct {
+	c          *Collector
+	api        MonitoringAPI
+	window     time.Duration
+	resolution time.Duration
+	now        func() time.Time
+}
+
+func (m *metricsCollector) Name() string { return CollectorMetrics }
+
+// Tables leaves out metric_points, which collectMetrics prunes by age instead
+func (m *metricsCollector) Tables() []storage.Stale {
+	return []storage.Stale{{Table: storage.TableResourceMetrics}}
+}
+
+func (m *metricsCollector) Collect(ctx context.Context, projectID string) error {
+	if err := m.collectMetrics(ctx, projectID); err != nil {
+		return fmt.Errorf("failed to collect metrics: %w", err)
+	}
+	return nil
+}
+
+// collectMetrics stores one value per topic or subscription for each of pubsubMetrics, over the
+// window ending at the last whole resolution period. It is aggregated from the stored datapoints
+// of the window and those fetched since the newest of them, which is fetched again as it may have
+// been incomplete. Datapoints that fell out of the window are removed.
+func (m *metricsCollector) collectMetrics(ctx context.Context, projectID string) error {
+	c := m.c
+	end := m.now().UTC().Truncate(m.resolution)
+	windowStart := end.Add(-m.window)
+	stored, err := c.storage.GetMetricPoints(ctx, projectID, windowStart)
+	if err != nil {
+		return fmt.Errorf("failed to get metric points: %w", err)
+	}
+
+	var fetched []*storage.MetricPoint
+	var metrics []*storage.ResourceMetric
+	for _, metric := range pubsubMetrics {
+		start := windowStart
+		var points []*storage.MetricPoint
+		for _, p := range stored {
+			if p.Metric == metric.metricType {
+				points = append(points, p)
+				if p.Start.After(start) {
+					start = p.Start
+				}
+			}
+		}
+
+		var series []*monitoring.TimeSeries
+		err := c.retryWithBackoff(ctx, func() error {
+			if err := c.wait(ctx, projectID); err != nil {
+				return fmt.Errorf("rate limiter error: %w", err)
+			}
+
+			var err error
+			series, err = m.api.ListTimeSeries(ctx, projectID, metric.metricType, metric.aligner, start, end, m.resolution)
+			c.observeCall(err)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to list %s: %w", metric.metricType, err)
+		}
+		latest := metric.downsample(projectID, series, start, end, m.resolution)
+		fetched = append(fetched, latest...)
+		metrics = append(metrics, metric.reduce(append(points, latest...), m.window)...)
+	}
+
+	err = c.write(ctx, func(ctx context.Context) error {
+		store := c.store(ctx)
+		if err := store.SaveMetricPoints(ctx, fetched); err != nil {
+			return err
+		}
+		if err := store.DeleteMetricPoints(ctx, projectID, windowStart); err != nil {
+			return err
+		}
+		return store.SaveMetrics(ctx, metrics)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save metrics: %w", err)
+	}
+	c.observer.AddStored(projectID, "metric", len(metrics))
+	return nil
+}
+
+// pointKey identifies the datapoint of a resource for a period, starting in Unix milliseconds
+type pointKey struct {
+	name  string
+	start int64
+}
+
+// downsample combines the series of each resource into a datapoint per period between start
+// and end. A resource has a series per metric label value, e.g. the response code of publish calls.
+func (m pubsubMetric) downsample(projectID string, series []*monitoring.TimeSeries, start, end time.Time, period time.Duration) []*storage.MetricPoint {
+	byKey := make(map[pointKey]*storage.MetricPoint)
+	for _, ts := range series {
+		if ts.Resource == nil || ts.Resource.Labels[m.label] == "" {
+			continue
+		}
+		project := ts.Resource.Labels["project_id"]
+		if project == "" {
+			project = projectID
+		}
+		name := fmt.Sprintf("projects/%s/%s/%s", project, m.collection, ts.Resource.Labels[m.label])
+
+		for _, point := range ts.Points {
+			if point.Interval == nil {
+				continue
+			}
+			pointEnd, err := time.Parse(time.RFC3339, point.Interval.EndTime)
+			if err != nil {
+				continue
+			}
+			// An aligned point ends at the end of its period
+			periodStart := pointEnd.UTC().Add(-period).Truncate(period)
+			if periodStart.Before(start) || !periodStart.Before(end) {
+				continue
+			}
+
+			stored, ok := byKey[pointKey{name, periodStart.UnixMilli()}]
+			if !ok {
+				stored = &storage.MetricPoint{
+					FullResourceName: name,
+					ProjectID:        projectID,
+					Metric:           m.metricType,
+					Start:            periodStart,
+				}
+				byKey[pointKey{name, periodStart.UnixMilli()}] = stored
+			}
+			m.add(&stored.Value, pointValue(point))
+		}
+	}
+
+	points := make([]*storage.MetricPoint, 0, len(byKey))
+	for _, p := range byKey {
+		points = append(points, p)
+	}
+	sort.Slice(points, func(i, j int) bool {
+		if !points[i].Start.Equal(points[j].Start) {
+			return points[i].Start.Before(points[j].Start)
+		}
+		return points[i].FullResourceName < points[j].FullResourceName
+	})
+	return points
+}
+
+// reduce combines the datapoints of each resource into one value over the window. A
+// fetched datapoint replaces a stored one of the same period, they come later in points.
+func (m pubsubMetric) reduce(points []*storage.MetricPoint, window time.Duration) []*storage.ResourceMetric {
+	latest := make(map[pointKey]*storage.MetricPoint, len(points))
+	for _, p := range points {
+		latest[pointKey{p.FullResourceName, p.Start.UnixMilli()}] = p
+	}
+
+	byName := make(map[string]*storage.ResourceMetric)
+	for _, p := range latest {
+		stored, ok := byName[p.FullResourceName]
+		if !ok {
+			stored = &storage.ResourceMetric{
+				FullResourceName: p.FullResourceName,
+				ProjectID:        p.ProjectID,
+				Metric:           m.metricType,
+				Window:           window,
+			}
+			byName[p.FullResourceName] = stored
+		}
+		m.add(&stored.Value, p.Value)
+	}
+
+	metrics := make([]*storage.ResourceMetric, 0, len(byName))
+	for _, metric := range byName {
+		metrics = append(metrics, metric)
+	}
+	sort.Slice(metrics, func(i, j int) bool { return metrics[i].FullResourceName < metrics[j].FullResourceName })
+	return metrics
+}
+
+// add sums value into total, or keeps the highest of them
+func (m pubsubMetric) add(total *float64, value float64) {
+	switch {
+	case m.sum:
+		*total += value
+	case value > *total:
+		*total = value
+	}
+}
+
+// pointValue returns the numeric value of a point, zero for other value types
+func pointValue(point *monitoring.Point) float64 {
+	switch {
+	case point.Value == nil:
+		return 0
+	case point.Value.Int64Value != nil:
+		return float64(*point.Value.Int64Value)
+	case point.Value.DoubleValue != nil:
+		return *point.Value.DoubleValue
+	default:
+		return 0
+	}
+}
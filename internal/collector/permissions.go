@@ -0,0 +1,180 @@
+package collector
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// CollectorSpec describes a resource collector and the API access it requires
+type CollectorSpec struct {
+	Name        string
+	Collector   string   // Registered collector making the calls, one of CollectorNames
+	Mutating    bool     // True if the collector performs any create/update/delete API call
+	Roles       []string // Minimal predefined IAM roles granting the permissions below
+	Permissions []string // Exact IAM permissions used by the collector
+}
+
+// collectorSpecs lists the API access of every built-in collector.
+// New collectors must be added here so the read-only guard and the
+// permissions command stay accurate.
+var collectorSpecs = []CollectorSpec{
+	{
+		Name:        "pubsub-api-enablement",
+		Collector:   CollectorPubSub,
+		Roles:       []string{"roles/serviceusage.serviceUsageViewer"},
+		Permissions: []string{"serviceusage.services.get"},
+	},
+	{
+		Name:        "pubsub-topics",
+		Collector:   CollectorPubSub,
+		Roles:       []string{"roles/pubsub.viewer"},
+		Permissions: []string{"pubsub.topics.list"},
+	},
+	{
+		Name:        "pubsub-subscriptions",
+		Collector:   CollectorPubSub,
+		Roles:       []string{"roles/pubsub.viewer"},
+		Permissions: []string{"pubsub.subscriptions.list"},
+	},
+	{
+		Name:        "pubsub-subscription-iam",
+		Collector:   CollectorPubSub,
+		Roles:       []string{"roles/iam.securityReviewer"},
+		Permissions: []string{"pubsub.subscriptions.getIamPolicy"},
+	},
+	{
+		Name:        "pubsub-topic-iam",
+		Collector:   CollectorPubSub,
+		Roles:       []string{"roles/iam.securityReviewer"},
+		Permissions: []string{"pubsub.topics.getIamPolicy"},
+	},
+	{
+		Name:        "cloud-run-services",
+		Collector:   CollectorCloudRun,
+		Roles:       []string{"roles/run.viewer"},
+		Permissions: []string{"run.services.list"},
+	},
+	{
+		Name:        "eventarc-triggers",
+		Collector:   CollectorCloudRun,
+		Roles:       []string{"roles/eventarc.viewer"},
+		Permissions: []string{"eventarc.triggers.list"},
+	},
+	{
+		Name:        "cloud-functions",
+		Collector:   CollectorCloudFunctions,
+		Roles:       []string{"roles/cloudfunctions.viewer"},
+		Permissions: []string{"cloudfunctions.functions.list"},
+	},
+	{
+		Name:        "dataflow-jobs",
+		Collector:   CollectorDataflow,
+		Roles:       []string{"roles/dataflow.viewer"},
+		Permissions: []string{"dataflow.jobs.list", "dataflow.jobs.get"},
+	},
+	{
+		Name:        "pubsub-publish-audit-logs",
+		Collector:   CollectorPublishers,
+		Roles:       []string{"roles/logging.privateLogViewer"},
+		Permissions: []string{"logging.privateLogEntries.list"},
+	},
+	{
+		Name:        "pubsub-metrics",
+		Collector:   CollectorMetrics,
+		Roles:       []string{"roles/monitoring.viewer"},
+		Permissions: []string{"monitoring.timeSeries.list"},
+	},
+	{
+		Name:        "project-labels",
+		Collector:   CollectorProjects,
+		Roles:       []string{"roles/browser"},
+		Permissions: []string{"resourcemanager.projects.get"},
+	},
+	{
+		Name:        "pubsub-lite",
+		Collector:   CollectorPubSubLite,
+		Roles:       []string{"roles/pubsublite.viewer"},
+		Permissions: []string{"pubsublite.topics.list", "pubsublite.subscriptions.list"},
+	},
+}
+
+// exportSpecs lists the API access of the exports writing outside the cache. They create
+// and change resources, so the read-only guard refuses them unless read_only is disabled.
+var exportSpecs = []CollectorSpec{
+	{
+		Name:     "bigquery-export",
+		Mutating: true,
+		Roles:    []string{"roles/bigquery.dataEditor", "roles/bigquery.jobUser"},
+		Permissions: []string{
+			"bigquery.datasets.get", "bigquery.datasets.create",
+			"bigquery.tables.create", "bigquery.tables.update", "bigquery.tables.updateData", "bigquery.tables.getData",
+			"bigquery.jobs.create",
+		},
+	},
+}
+
+// Specs returns the specs of all collectors
+func Specs() []CollectorSpec {
+	specs := make([]CollectorSpec, len(collectorSpecs))
+	copy(specs, collectorSpecs)
+	return specs
+}
+
+// ExportSpecs returns the specs of the exports writing outside the cache, such as to BigQuery
+func ExportSpecs() []CollectorSpec {
+	specs := make([]CollectorSpec, len(exportSpecs))
+	copy(specs, exportSpecs)
+	return specs
+}
+
+// SpecsFor returns the specs of the named collectors, see CollectorNames
+func SpecsFor(names []string) []CollectorSpec {
+	var specs []CollectorSpec
+	for _, spec := range collectorSpecs {
+		if slices.Contains(names, spec.Collector) {
+			specs = append(specs, spec)
+		}
+	}
+	return specs
+}
+
+// CheckReadOnly returns an error naming every collector or export that is registered as mutating
+func CheckReadOnly(specs []CollectorSpec) error {
+	var mutating []string
+	for _, spec := range specs {
+		if spec.Mutating {
+			mutating = append(mutating, spec.Name)
+		}
+	}
+	if len(mutating) > 0 {
+		return fmt.Errorf("read-only mode: refusing to run mutating API calls of %s", strings.Join(mutating, ", "))
+	}
+	return nil
+}
+
+// RequiredRoles returns the sorted, de-duplicated set of IAM roles needed by the given collectors
+func RequiredRoles(specs []CollectorSpec) []string {
+	return collect(specs, func(spec CollectorSpec) []string { return spec.Roles })
+}
+
+// RequiredPermissions returns the sorted, de-duplicated set of IAM permissions needed by the given collectors
+func RequiredPermissions(specs []CollectorSpec) []string {
+	return collect(specs, func(spec CollectorSpec) []string { return spec.Permissions })
+}
+
+func collect(specs []CollectorSpec, field func(CollectorSpec) []string) []string {
+	seen := make(map[string]bool)
+	var values []string
+	for _, spec := range specs {
+		for _, v := range field(spec) {
+			if !seen[v] {
+				seen[v] = true
+				values = append(values, v)
+			}
+		}
+	}
+	sort.Strings(values)
+	return values
+}
@@ -0,0 +1,91 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/auth"
+	cloudresourcemanager "google.golang.org/api/cloudresourcemanager/v3"
+)
+
+// ProjectsAPI reads the labels of a project. It is implemented by the
+// Resource Manager REST client and can be faked in tests.
+type ProjectsAPI interface {
+	GetLabels(ctx context.Context, projectID string) (map[string]string, error)
+}
+
+// NewProjectsAPI creates a ProjectsAPI backed by the Resource Manager API
+func NewProjectsAPI(ctx context.Context, opts auth.Options) (ProjectsAPI, error) {
+	clientOpts, err := auth.ClientOptions(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	svc, err := cloudresourcemanager.NewService(ctx, clientOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create resource manager client: %w", err)
+	}
+	return &projectsAPI{svc: svc}, nil
+}
+
+type projectsAPI struct {
+	svc *cloudresourcemanager.Service
+}
+
+func (a *projectsAPI) GetLabels(ctx context.Context, projectID string) (map[string]string, error) {
+	project, err := a.svc.Projects.Get("projects/" + projectID).Context(ctx).Do()
+	if err != nil {
+		return nil, err
+	}
+	return project.Labels, nil
+}
+
+// SetProjectsAPI registers the "projects" collector, storing the labels of every project
+// with api, e.g. the team owning it. A nil api, the default, skips the project labels.
+func (c *Collector) SetProjectsAPI(api ProjectsAPI) {
+	if api == nil {
+		c.Unregister(CollectorProjects)
+		return
+	}
+	c.Register(&projectsCollector{c: c, api: api})
+}
+
+// projectsCollector collects the labels of a project
+type projectsCollector struct {
+	c   *Collector
+	api ProjectsAPI
+}
+
+func (p *projectsCollector) Name() string { return CollectorProjects }
+
+func (p *projectsCollector) Collect(ctx context.Context, projectID string) error {
+	if err := p.c.collectProjectLabels(ctx, p.api, projectID); err != nil {
+		return fmt.Errorf("failed to collect project labels: %w", err)
+	}
+	return nil
+}
+
+// collectProjectLabels replaces the stored labels of a project
+func (c *Collector) collectProjectLabels(ctx context.Context, api ProjectsAPI, projectID string) error {
+	var labels map[string]string
+	err := c.retryWithBackoff(ctx, func() error {
+		if err := c.wait(ctx, projectID); err != nil {
+			return fmt.Errorf("rate limiter error: %w", err)
+		}
+
+		var err error
+		labels, err = api.GetLabels(ctx, projectID)
+		c.observeCall(err)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get project: %w", err)
+	}
+
+	err = c.write(ctx, func(ctx context.Context) error {
+		return c.store(ctx).SetProjectLabels(ctx, projectID, labels)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save project labels: %w", err)
+	}
+	return nil
+}
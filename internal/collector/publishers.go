@@ -0,0 +1,183 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/auth"
+	"github.com/NissesSenap/gcp-visualizer/internal/storage"
+	logging "google.golang.org/api/logging/v2"
+)
+
+// methodPublish is the audited method of a Pub/Sub publish call
+const methodPublish = "google.pubsub.v1.Publisher.Publish"
+
+// defaultMaxPublishEntries bounds the audit log entries read per project, publish
+// calls are logged once per request and busy topics log millions a day
+const defaultMaxPublishEntries = 10000
+
+// AuditLogAPI lists the Data Access audit log entries of Pub/Sub publish calls in a
+// project. It is implemented by the Cloud Logging REST client and can be faked in tests.
+type AuditLogAPI interface {
+	ListPublishEntries(ctx context.Context, projectID string, since time.Time) ([]*logging.LogEntry, error)
+}
+
+// NewAuditLogAPI creates an AuditLogAPI backed by the Cloud Logging API, reading at most
+// maxEntries entries per project. Values below 1 use a default of 10000.
+func NewAuditLogAPI(ctx context.Context, opts auth.Options, maxEntries int) (AuditLogAPI, error) {
+	clientOpts, err := auth.ClientOptions(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	svc, err := logging.NewService(ctx, clientOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create logging client: %w", err)
+	}
+	if maxEntries < 1 {
+		maxEntries = defaultMaxPublishEntries
+	}
+	return &auditLogAPI{svc: svc, maxEntries: maxEntries}, nil
+}
+
+type auditLogAPI struct {
+	svc        *logging.Service
+	maxEntries int
+}
+
+// errEnoughEntries stops paging once maxEntries entries were read
+var errEnoughEntries = errors.New("enough entries")
+
+func (a *auditLogAPI) ListPublishEntries(ctx context.Context, projectID string, since time.Time) ([]*logging.LogEntry, error) {
+	req := &logging.ListLogEntriesRequest{
+		ResourceNames: []string{"projects/" + projectID},
+		Filter: fmt.Sprintf(`logName="projects/%s/logs/cloudaudit.googleapis.com%%2Fdata_access" AND protoPayload.methodName="%s" AND timestamp>="%s"`,
+			projectID, methodPublish, since.UTC().Format(time.RFC3339)),
+		OrderBy:  "timestamp desc",
+		PageSize: 1000,
+	}
+	var entries []*logging.LogEntry
+	err := a.svc.Entries.List(req).Pages(ctx, func(resp *logging.ListLogEntriesResponse) error {
+		entries = append(entries, resp.Entries...)
+		if len(entries) >= a.maxEntries {
+			return errEnoughEntries
+		}
+		return nil
+	})
+	if errors.Is(err, errEnoughEntries) {
+		err = nil
+	}
+	return entries, err
+}
+
+// SetAuditLogAPI registers the "publishers" collector, recording the identities seen
+// publishing to each topic in the audit logs of the last window with api.
+// A nil api, the default, skips publisher discovery.
+func (c *Collector) SetAuditLogAPI(api AuditLogAPI, window time.Duration) {
+	if api == nil {
+		c.Unregister(CollectorPublishers)
+		return
+	}
+	c.Register(&publishersCollector{c: c, api: api, window: window})
+}
+
+// publishersCollector records who publishes to the topics of a project from its audit logs
+type publishersCollector struct {
+	c      *Collector
+	api    AuditLogAPI
+	window time.Duration
+}
+
+func (p *publishersCollector) Name() string { return CollectorPublishers }
+
+func (p *publishersCollector) Tables() []storage.Stale {
+	return []storage.Stale{{Table: storage.TableEdges, Kind: storage.RelationPublishes}}
+}
+
+func (p *publishersCollector) Collect(ctx context.Context, projectID string) error {
+	if err := p.c.collectPublishers(ctx, p.api, projectID, time.Now().Add(-p.window)); err != nil {
+		return fmt.Errorf("failed to collect publishers: %w", err)
+	}
+	return nil
+}
+
+// collectPublishers stores an edge from every identity that published to a topic of
+// the project since the given time to the topic
+func (c *Collector) collectPublishers(ctx context.Context, api AuditLogAPI, projectID string, since time.Time) error {
+	var entries []*logging.LogEntry
+	err := c.retryWithBackoff(ctx, func() error {
+		if err := c.wait(ctx, projectID); err != nil {
+			return fmt.Errorf("rate limiter error: %w", err)
+		}
+
+		var err error
+		entries, err = api.ListPublishEntries(ctx, projectID, since)
+		c.observeCall(err)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list audit logs: %w", err)
+	}
+
+	seen := make(map[storage.ResourceEdge]bool)
+	var edges []*storage.ResourceEdge
+	for _, entry := range entries {
+		edge, ok := publishEdge(projectID, entry)
+		if !ok || seen[*edge] {
+			continue
+		}
+		seen[*edge] = true
+		edges = append(edges, edge)
+	}
+
+	err = c.write(ctx, func(ctx context.Context) error {
+		return c.store(ctx).SaveEdges(ctx, edges)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save publishers: %w", err)
+	}
+	c.observer.AddStored(projectID, "publisher", len(edges))
+	return nil
+}
+
+// publishEdge returns the edge from the caller of a successful publish call to its topic,
+// false for entries of failed calls or without a caller
+func publishEdge(projectID string, entry *logging.LogEntry) (*storage.ResourceEdge, bool) {
+	var payload struct {
+		MethodName   string `json:"methodName"`
+		ResourceName string `json:"resourceName"`
+		AuthInfo     struct {
+			PrincipalEmail string `json:"principalEmail"`
+		} `json:"authenticationInfo"`
+		Status *struct {
+			Code int `json:"code"`
+		} `json:"status"`
+	}
+	if len(entry.ProtoPayload) == 0 || json.Unmarshal(entry.ProtoPayload, &payload) != nil {
+		return nil, false
+	}
+	if payload.MethodName != methodPublish || (payload.Status != nil && payload.Status.Code != 0) {
+		return nil, false
+	}
+	if payload.AuthInfo.PrincipalEmail == "" || !strings.HasPrefix(payload.ResourceName, "projects/") {
+		return nil, false
+	}
+
+	return &storage.ResourceEdge{
+		Source:    auditPrincipal(payload.AuthInfo.PrincipalEmail),
+		Target:    payload.ResourceName,
+		Relation:  storage.RelationPublishes,
+		ProjectID: projectID,
+	}, true
+}
+
+// auditPrincipal converts the principal email of an audit log entry to an IAM member
+func auditPrincipal(email string) string {
+	if strings.HasSuffix(email, ".gserviceaccount.com") {
+		return "serviceAccount:" + email
+	}
+	return "user:" + email
+}
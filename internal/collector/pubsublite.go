@@ -0,0 +1,335 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/auth"
+	"github.com/NissesSenap/gcp-visualizer/internal/storage"
+	"google.golang.org/api/option"
+	pubsublite "google.golang.org/api/pubsublite/v1"
+)
+
+// PubSubLiteAPI lists the Pub/Sub Lite topics and subscriptions of a project in one
+// location, a region or a zone. It is implemented by the Pub/Sub Lite admin REST client
+// and can be faked in tests.
+type PubSubLiteAPI interface {
+	ListTopics(ctx context.Context, projectID, location string) ([]*pubsublite.Topic, error)
+	ListSubscriptions(ctx context.Context, projectID, location string) ([]*pubsublite.Subscription, error)
+}
+
+// NewPubSubLiteAPI creates a PubSubLiteAPI backed by the Pub/Sub Lite admin API. The admin
+// API is served from regional endpoints, a client is created for each region on first use.
+func NewPubSubLiteAPI(ctx context.Context, opts auth.Options) (PubSubLiteAPI, error) {
+	clientOpts, err := auth.ClientOptions(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &pubsubLiteAPI{opts: clientOpts, services: make(map[string]*pubsublite.Service)}, nil
+}
+
+type pubsubLiteAPI struct {
+	opts []option.ClientOption
+
+	mu       sync.Mutex
+	services map[string]*pubsublite.Service // by region
+}
+
+// service returns the client of the regional endpoint serving location
+func (a *pubsubLiteAPI) service(ctx context.Context, location string) (*pubsublite.Service, error) {
+	region := liteRegion(location)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if svc, ok := a.services[region]; ok {
+		return svc, nil
+	}
+	opts := append([]option.ClientOption{option.WithEndpoint("https://" + region + "-pubsublite.googleapis.com/")}, a.opts...)
+	svc, err := pubsublite.NewService(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create pubsub lite client for %s: %w", region, err)
+	}
+	a.services[region] = svc
+	return svc, nil
+}
+
+func (a *pubsubLiteAPI) ListTopics(ctx context.Context, projectID, location string) ([]*pubsublite.Topic, error) {
+	svc, err := a.service(ctx, location)
+	if err != nil {
+		return nil, err
+	}
+	var topics []*pubsublite.Topic
+	err = svc.Admin.Projects.Locations.Topics.List(liteParent(projectID, location)).Pages(ctx, func(resp *pubsublite.ListTopicsResponse) error {
+		topics = append(topics, resp.Topics...)
+		return nil
+	})
+	return topics, err
+}
+
+func (a *pubsubLiteAPI) ListSubscriptions(ctx context.Context, projectID, location string) ([]*pubsublite.Subscription, error) {
+	svc, err := a.service(ctx, location)
+	if err != nil {
+		return nil, err
+	}
+	var subs []*pubsublite.Subscription
+	err = svc.Admin.Projects.Locations.Subscriptions.List(liteParent(projectID, location)).Pages(ctx, func(resp *pubsublite.ListSubscriptionsResponse) error {
+		subs = append(subs, resp.Subscriptions...)
+		return nil
+	})
+	return subs, err
+}
+
+// liteParent returns the parent of the Lite resources of a project in a location
+func liteParent(projectID, location string) string {
+	return fmt.Sprintf("projects/%s/locations/%s", projectID, location)
+}
+
+// liteRegion returns the region of a Pub/Sub Lite location, which is either a region
+// such as "europe-west1" or one of its zones such as "europe-west1-b"
+func liteRegion(location string) string {
+	parts := strings.Split(location, "-")
+	if len(parts) == 3 && len(parts[2]) == 1 {
+		return parts[0] + "-" + parts[1]
+	}
+	return location
+}
+
+// SetPubSubLiteAPI registers the "pubsublite" collector, collecting the Pub/Sub Lite topics
+// and subscriptions of every project in the given locations with api, so Lite resources are
+// drawn next to the Pub/Sub ones. Lite has no aggregated listing, every location is listed
+// on its own. A nil api, the default, skips Pub/Sub Lite.
+func (c *Collector) SetPubSubLiteAPI(api PubSubLiteAPI, locations []string) {
+	if api == nil {
+		c.Unregister(CollectorPubSubLite)
+		return
+	}
+	c.Register(&pubsubLiteCollector{c: c, api: api, locations: locations})
+}
+
+// pubsubLiteCollector collects the Pub/Sub Lite topics and subscriptions of a project
+type pubsubLiteCollector struct {
+	c         *Collector
+	api       PubSubLiteAPI
+	locations []string
+}
+
+func (p *pubsubLiteCollector) Name() string { return CollectorPubSubLite }
+
+func (p *pubsubLiteCollector) Tables() []storage.Stale {
+	return []storage.Stale{
+		{Table: storage.TableResources, Kind: storage.ResourceKindLiteTopic},
+		{Table: storage.TableResources, Kind: storage.ResourceKindLiteSubscription},
+		{Table: storage.TableEdges, Kind: storage.RelationSubscribes},
+		{Table: storage.TableEdges, Kind: storage.RelationExports},
+	}
+}
+
+func (p *pubsubLiteCollector) Collect(ctx context.Context, projectID string) error {
+	for _, location := range p.locations {
+		if err := p.c.collectPubSubLite(ctx, p.api, projectID, location); err != nil {
+			return fmt.Errorf("failed to collect pubsub lite resources in %s: %w", location, err)
+		}
+	}
+	return nil
+}
+
+// collectPubSubLite stores the Lite topics and subscriptions of a project in one location,
+// with an edge from every subscription to its topic and to the Pub/Sub topic it exports to
+func (c *Collector) collectPubSubLite(ctx context.Context, api PubSubLiteAPI, projectID, location string) error {
+	var topics []*pubsublite.Topic
+	err := c.retryWithBackoff(ctx, func() error {
+		if err := c.wait(ctx, projectID); err != nil {
+			return fmt.Errorf("rate limiter error: %w", err)
+		}
+
+		var err error
+		topics, err = api.ListTopics(ctx, projectID, location)
+		c.observeCall(err)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list topics: %w", err)
+	}
+
+	var subs []*pubsublite.Subscription
+	err = c.retryWithBackoff(ctx, func() error {
+		if err := c.wait(ctx, projectID); err != nil {
+			return fmt.Errorf("rate limiter error: %w", err)
+		}
+
+		var err error
+		subs, err = api.ListSubscriptions(ctx, projectID, location)
+		c.observeCall(err)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list subscriptions: %w", err)
+	}
+
+	// The admin API names resources by project number, they are stored by project ID like
+	// the Pub/Sub ones. Pub/Sub topics Lite exports to are named by number as well.
+	project := liteProject{id: projectID}
+	resources := make([]*storage.Resource, 0, len(topics)+len(subs))
+	for _, topic := range topics {
+		r, err := newLiteTopic(&project, topic)
+		if err != nil {
+			return err
+		}
+		resources = append(resources, r)
+	}
+	var edges []*storage.ResourceEdge
+	for _, sub := range subs {
+		r, err := newLiteSubscription(&project, sub)
+		if err != nil {
+			return err
+		}
+		resources = append(resources, r)
+
+		if sub.Topic != "" {
+			edges = append(edges, &storage.ResourceEdge{
+				Source:    r.FullResourceName,
+				Target:    project.normalize(sub.Topic),
+				Relation:  storage.RelationSubscribes,
+				ProjectID: projectID,
+			})
+		}
+		if target := liteExportTopic(sub); target != "" {
+			edges = append(edges, &storage.ResourceEdge{
+				Source:    r.FullResourceName,
+				Target:    project.normalize(target),
+				Relation:  storage.RelationExports,
+				ProjectID: projectID,
+			})
+		}
+	}
+
+	err = c.write(ctx, func(ctx context.Context) error {
+		if err := c.store(ctx).SaveResources(ctx, resources); err != nil {
+			return err
+		}
+		return c.store(ctx).SaveEdges(ctx, edges)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save resources: %w", err)
+	}
+	c.observer.AddStored(projectID, storage.ResourceKindLiteTopic, len(topics))
+	c.observer.AddStored(projectID, storage.ResourceKindLiteSubscription, len(subs))
+	return nil
+}
+
+// liteProject rewrites the project numbers of Lite resource names to the ID of the
+// scanned project, learning the number from the names the admin API returns
+type liteProject struct {
+	id     string
+	number string
+}
+
+// learn records the project of a listed resource and returns its normalized name
+func (p *liteProject) learn(name string) string {
+	if parts := strings.Split(name, "/"); len(parts) > 1 && parts[0] == "projects" && parts[1] != p.id {
+		p.number = parts[1]
+	}
+	return p.normalize(name)
+}
+
+// normalize replaces the scanned project's number in a resource name with its ID,
+// names of other projects are kept as they are
+func (p *liteProject) normalize(name string) string {
+	parts := strings.Split(name, "/")
+	if len(parts) > 1 && parts[0] == "projects" && p.number != "" && parts[1] == p.number {
+		parts[1] = p.id
+	}
+	return strings.Join(parts, "/")
+}
+
+// liteExportTopic returns the Pub/Sub topic a Lite subscription exports its messages to,
+// empty unless it has an export config
+func liteExportTopic(sub *pubsublite.Subscription) string {
+	if sub.ExportConfig == nil || sub.ExportConfig.PubsubConfig == nil {
+		return ""
+	}
+	return sub.ExportConfig.PubsubConfig.Topic
+}
+
+// newLiteTopic converts a Lite topic into a generic resource of the scanned project
+func newLiteTopic(project *liteProject, topic *pubsublite.Topic) (*storage.Resource, error) {
+	fullResourceName := project.learn(topic.Name)
+	location, name := liteLocationName(fullResourceName)
+	metadata := map[string]interface{}{
+		"location": location,
+	}
+	if pc := topic.PartitionConfig; pc != nil {
+		metadata["partitions"] = pc.Count
+		if pc.Capacity != nil {
+			metadata["publish_mib_per_sec"] = pc.Capacity.PublishMibPerSec
+			metadata["subscribe_mib_per_sec"] = pc.Capacity.SubscribeMibPerSec
+		}
+	}
+	if rc := topic.RetentionConfig; rc != nil {
+		if rc.PerPartitionBytes > 0 {
+			metadata["retention_bytes_per_partition"] = strconv.FormatInt(rc.PerPartitionBytes, 10)
+		}
+		if rc.Period != "" {
+			metadata["retention_period"] = rc.Period
+		}
+	}
+	if topic.ReservationConfig != nil && topic.ReservationConfig.ThroughputReservation != "" {
+		metadata["reservation"] = topic.ReservationConfig.ThroughputReservation
+	}
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode metadata of lite topic %s: %w", name, err)
+	}
+
+	return &storage.Resource{
+		Kind:             storage.ResourceKindLiteTopic,
+		Name:             name,
+		ProjectID:        project.id,
+		FullResourceName: fullResourceName,
+		Metadata:         string(data),
+	}, nil
+}
+
+// newLiteSubscription converts a Lite subscription into a generic resource of the scanned project
+func newLiteSubscription(project *liteProject, sub *pubsublite.Subscription) (*storage.Resource, error) {
+	fullResourceName := project.learn(sub.Name)
+	location, name := liteLocationName(fullResourceName)
+	metadata := map[string]interface{}{
+		"location": location,
+	}
+	if sub.DeliveryConfig != nil && sub.DeliveryConfig.DeliveryRequirement != "" {
+		metadata["delivery_requirement"] = sub.DeliveryConfig.DeliveryRequirement
+	}
+	if ec := sub.ExportConfig; ec != nil {
+		metadata["export_state"] = ec.CurrentState
+		if ec.DeadLetterTopic != "" {
+			metadata["dead_letter_topic"] = project.normalize(ec.DeadLetterTopic)
+		}
+	}
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode metadata of lite subscription %s: %w", name, err)
+	}
+
+	return &storage.Resource{
+		Kind:             storage.ResourceKindLiteSubscription,
+		Name:             name,
+		ProjectID:        project.id,
+		FullResourceName: fullResourceName,
+		Metadata:         string(data),
+	}, nil
+}
+
+// liteLocationName returns the location and short name of a Lite resource named
+// "projects/{project}/locations/{location}/{topics|subscriptions}/{name}"
+func liteLocationName(fullResourceName string) (location, name string) {
+	parts := strings.Split(fullResourceName, "/")
+	if len(parts) != 6 {
+		return "", fullResourceName
+	}
+	return parts[3], parts[5]
+}
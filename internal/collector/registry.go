@@ -0,0 +1,100 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/storage"
+)
+
+// ResourceCollector collects the resources of one GCP service in a project into storage.
+// Collectors run one after the other for each project, sharing the rate limiter,
+// retries, storage writes and observer of the Collector they are registered with.
+type ResourceCollector interface {
+	// Name identifies the collector in the config, e.g. "pubsub"
+	Name() string
+
+	// Collect stores the resources of the project. An error fails the project collection.
+	Collect(ctx context.Context, projectID string) error
+}
+
+// TableOwner is implemented by collectors that rewrite their cached rows on every run.
+// The rows a successful run no longer saw were deleted in GCP, and are removed from the
+// cache unless the scan keeps stale resources. Rows of collectors that didn't run are kept.
+type TableOwner interface {
+	// Tables selects the cached rows of a project the collector rewrites
+	Tables() []storage.Stale
+}
+
+// Names of the built-in collectors, as listed in the collectors config
+const (
+	CollectorPubSub         = "pubsub"
+	CollectorCloudRun       = "cloudrun"
+	CollectorCloudFunctions = "functions"
+	CollectorDataflow       = "dataflow"
+	CollectorPublishers     = "publishers"
+	CollectorMetrics        = "metrics"
+	CollectorProjects       = "projects"
+	CollectorPubSubLite     = "pubsublite"
+)
+
+// CollectorNames returns the names of the built-in collectors, in the order they run
+func CollectorNames() []string {
+	return []string{CollectorPubSub, CollectorCloudRun, CollectorCloudFunctions, CollectorDataflow, CollectorPublishers, CollectorMetrics, CollectorProjects, CollectorPubSubLite}
+}
+
+// ValidateCollectorNames returns an error naming every unknown collector in names
+func ValidateCollectorNames(names []string) error {
+	var unknown []string
+	for _, name := range names {
+		if !slices.Contains(CollectorNames(), name) {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		return fmt.Errorf("unknown collectors %s, expected any of %s",
+			strings.Join(unknown, ", "), strings.Join(CollectorNames(), ", "))
+	}
+	return nil
+}
+
+// Register adds rc to the collectors run by CollectProject, after the ones already
+// registered. A collector with the name of a registered one replaces it in place.
+func (c *Collector) Register(rc ResourceCollector) {
+	for i, registered := range c.collectors {
+		if registered.Name() == rc.Name() {
+			c.collectors[i] = rc
+			return
+		}
+	}
+	c.collectors = append(c.collectors, rc)
+}
+
+// Unregister removes the collector with the given name, if it is registered
+func (c *Collector) Unregister(name string) {
+	c.collectors = slices.DeleteFunc(c.collectors, func(rc ResourceCollector) bool {
+		return rc.Name() == name
+	})
+}
+
+// Registered returns the names of the registered collectors, in the order they run
+func (c *Collector) Registered() []string {
+	names := make([]string, 0, len(c.collectors))
+	for _, rc := range c.collectors {
+		names = append(names, rc.Name())
+	}
+	return names
+}
+
+// staleTables selects the cached rows of the collectors that ran, see TableOwner
+func staleTables(ran []ResourceCollector) []storage.Stale {
+	var stale []storage.Stale
+	for _, rc := range ran {
+		if owner, ok := rc.(TableOwner); ok {
+			stale = append(stale, owner.Tables()...)
+		}
+	}
+	return stale
+}
@@ -0,0 +1,113 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingCollector is a ResourceCollector appending its name to ran
+type recordingCollector struct {
+	name string
+	ran  *[]string
+	err  error
+}
+
+func (r *recordingCollector) Name() string { return r.name }
+
+func (r *recordingCollector) Collect(ctx context.Context, projectID string) error {
+	*r.ran = append(*r.ran, r.name+":"+projectID)
+	return r.err
+}
+
+func TestRegister(t *testing.T) {
+	collector, _ := setupTestCollector(t)
+	assert.Equal(t, []string{CollectorPubSub}, collector.Registered(), "Pub/Sub is registered by default")
+
+	var ran []string
+	collector.Register(&recordingCollector{name: "scheduler", ran: &ran})
+	collector.Register(&recordingCollector{name: "workflows", ran: &ran})
+	assert.Equal(t, []string{CollectorPubSub, "scheduler", "workflows"}, collector.Registered())
+
+	// Registering a name again replaces the collector in place
+	collector.Register(&recordingCollector{name: "scheduler", ran: &ran, err: errors.New("boom")})
+	assert.Equal(t, []string{CollectorPubSub, "scheduler", "workflows"}, collector.Registered())
+
+	err := collector.CollectProject(context.Background(), "project-a")
+	require.ErrorContains(t, err, "boom")
+	assert.Equal(t, []string{"scheduler:project-a"}, ran, "a failing collector stops the ones after it")
+
+	collector.Unregister("scheduler")
+	collector.SetCloudRunAPI(nil)
+	assert.Equal(t, []string{CollectorPubSub, "workflows"}, collector.Registered())
+}
+
+func TestCollectProject_WithoutPubSub(t *testing.T) {
+	ctx := context.Background()
+	collector, store := newFakeCollector(t, projectAAPI(), 1000)
+	collector.Unregister(CollectorPubSub)
+
+	var ran []string
+	collector.Register(&recordingCollector{name: "scheduler", ran: &ran})
+
+	require.NoError(t, store.SaveTopic(ctx, &storage.Topic{
+		Name:             "cached",
+		ProjectID:        "project-a",
+		FullResourceName: "projects/project-a/topics/cached",
+	}))
+	time.Sleep(5 * time.Millisecond)
+
+	require.NoError(t, collector.CollectProject(ctx, "project-a"))
+	assert.Equal(t, []string{"scheduler:project-a"}, ran)
+
+	topics, err := store.GetTopics(ctx, "project-a")
+	require.NoError(t, err)
+	assert.Len(t, topics, 1, "cached topics aren't stale when Pub/Sub isn't collected")
+
+	synced, err := store.GetProjectSyncTimes(ctx)
+	require.NoError(t, err)
+	assert.Contains(t, synced, "project-a")
+}
+
+func TestValidateCollectorNames(t *testing.T) {
+	assert.NoError(t, ValidateCollectorNames(CollectorNames()))
+
+	err := ValidateCollectorNames([]string{CollectorPubSub, "cloud_run", "bigtable"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "cloud_run, bigtable")
+	assert.NotContains(t, err.Error(), "collectors pubsub")
+}
+
+func TestSpecsFor(t *testing.T) {
+	for _, spec := range Specs() {
+		assert.Contains(t, CollectorNames(), spec.Collector, spec.Name)
+	}
+
+	var names []string
+	for _, spec := range SpecsFor([]string{CollectorCloudRun}) {
+		names = append(names, spec.Name)
+	}
+	assert.Equal(t, []string{"cloud-run-services", "eventarc-triggers"}, names)
+}
+
+func TestEstimateRequests(t *testing.T) {
+	counts := ResourceCounts{Topics: 10, Subscriptions: 25, DataflowJobs: 3, LiteLocations: 2}
+
+	assert.Equal(t, map[string]int{CollectorPubSub: 71}, EstimateRequests([]string{CollectorPubSub}, counts))
+	assert.Equal(t, map[string]int{
+		CollectorPubSub:         71,
+		CollectorCloudRun:       2,
+		CollectorCloudFunctions: 1,
+		CollectorDataflow:       4,
+		CollectorPublishers:     1,
+		CollectorMetrics:        3,
+		CollectorProjects:       1,
+		CollectorPubSubLite:     4,
+	}, EstimateRequests(CollectorNames(), counts))
+	assert.Equal(t, map[string]int{CollectorPubSub: 1}, EstimateRequests([]string{CollectorPubSub}, ResourceCounts{}))
+}
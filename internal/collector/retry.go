@@ -0,0 +1,95 @@
+package collector
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+
+	"github.com/googleapis/gax-go/v2"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// RetryPolicy controls how API calls failing with a retryable error are retried
+type RetryPolicy struct {
+	MaxAttempts    int           // including the first call, 1 disables retries
+	InitialBackoff time.Duration // wait before the first retry, doubled for every further retry
+	MaxBackoff     time.Duration // upper bound of the wait between retries
+	Jitter         bool          // randomize waits between half and all of the backoff
+}
+
+// DefaultRetryPolicy retries three times, after 1s, 2s and 4s
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    4,
+	InitialBackoff: time.Second,
+	MaxBackoff:     4 * time.Second,
+	Jitter:         true,
+}
+
+// retryableCodes are the errors worth retrying: quota exhaustion and transient failures
+var retryableCodes = map[codes.Code]bool{
+	codes.ResourceExhausted: true,
+	codes.Unavailable:       true,
+	codes.Aborted:           true,
+}
+
+// SetRetryPolicy sets how API calls are retried. Attempts below 1 are treated as 1.
+func (c *Collector) SetRetryPolicy(policy RetryPolicy) {
+	policy.MaxAttempts = max(policy.MaxAttempts, 1)
+	c.retry = policy
+}
+
+// retryWithBackoff calls fn until it succeeds, fails with a non-retryable error,
+// runs out of attempts or ctx is done. It returns the last error.
+func (c *Collector) retryWithBackoff(ctx context.Context, fn func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= c.retry.MaxAttempts || !retryableCodes[status.Code(err)] {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(c.retry.wait(attempt)):
+		}
+	}
+}
+
+// wait returns how long to wait before the given retry, counting from 1
+func (p RetryPolicy) wait(retry int) time.Duration {
+	backoff := p.InitialBackoff
+	for i := 1; i < retry && backoff < p.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	wait := min(backoff, p.MaxBackoff)
+	if p.Jitter && wait > 0 {
+		wait = wait/2 + rand.N(wait/2+1)
+	}
+	return wait
+}
+
+// gaxRetryer retries a call inside the Google API client with a RetryPolicy. The clients
+// fetch the pages of a listing one call at a time, so a failing page is fetched again
+// instead of the whole listing failing.
+type gaxRetryer struct {
+	policy  RetryPolicy
+	observe func(error) // sees every error that is retried, e.g. the adaptive rate limiter
+	retries int
+}
+
+// newGaxRetryer returns a gax.Retryer factory for policy, a call needs a retryer of its own
+func newGaxRetryer(policy RetryPolicy, observe func(error)) func() gax.Retryer {
+	return func() gax.Retryer {
+		return &gaxRetryer{policy: policy, observe: observe}
+	}
+}
+
+func (r *gaxRetryer) Retry(err error) (time.Duration, bool) {
+	if r.retries+1 >= r.policy.MaxAttempts || !retryableCodes[status.Code(err)] {
+		return 0, false
+	}
+	r.retries++
+	r.observe(err)
+	return r.policy.wait(r.retries), true
+}
@@ -2,58 +2,268 @@ package collector
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"time"
 
-	"cloud.google.com/go/pubsub/v2"
 	"cloud.google.com/go/pubsub/v2/apiv1/pubsubpb"
 	"github.com/NissesSenap/gcp-visualizer/internal/storage"
+	"golang.org/x/sync/errgroup"
 	"google.golang.org/api/iterator"
 )
 
-// collectSubscriptions collects all subscriptions from a GCP project
-func (c *Collector) collectSubscriptions(ctx context.Context, client *pubsub.Client, projectID string) error {
+// collectSubscriptions collects all subscriptions from a GCP project.
+// Listing is sequential; subscriptions are saved in batches of batchSize on up
+// to saveWorkers goroutines. Collecting incrementally, subscriptions unchanged
+// since they were cached are only touched.
+func (c *Collector) collectSubscriptions(ctx context.Context, client SubscriptionLister, projectID string) error {
+	cached, err := c.cachedSubscriptions(ctx, projectID)
+	if err != nil {
+		return err
+	}
+
 	// Create list request
 	req := &pubsubpb.ListSubscriptionsRequest{
 		Project: fmt.Sprintf("projects/%s", projectID),
 	}
 
-	it := client.SubscriptionAdminClient.ListSubscriptions(ctx, req)
+	saves, saveCtx := errgroup.WithContext(ctx)
+	saves.SetLimit(c.saveWorkers)
+
+	it := client.ListSubscriptions(saveCtx, req)
+
+	var failed batchErrors
+	batch := make([]*pubsubpb.Subscription, 0, c.batchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		subs := batch
+		saves.Go(func() error {
+			return failed.add(c.saveSubscriptions(saveCtx, client, projectID, subs, cached))
+		})
+		batch = make([]*pubsubpb.Subscription, 0, c.batchSize)
+	}
 
+	var listErr error
 	for {
-		// Rate limiting
-		if err := c.limiter.Wait(ctx); err != nil {
-			return fmt.Errorf("rate limiter error: %w", err)
+		// Rate limiting; also stops listing once a save has failed
+		if err := c.wait(saveCtx, projectID); err != nil {
+			listErr = fmt.Errorf("rate limiter error: %w", err)
+			break
 		}
 
 		sub, err := it.Next()
 		if err == iterator.Done {
 			break
 		}
+		c.observeCall(err)
 		if err != nil {
-			return fmt.Errorf("failed to iterate subscriptions: %w", err)
+			listErr = fmt.Errorf("failed to iterate subscriptions: %w", err)
+			break
 		}
 
-		// Extract subscription name from the full name
-		// sub.Name is in format "projects/{project}/subscriptions/{subscription}"
-		fullResourceName := sub.Name
-		subName := extractResourceName(fullResourceName)
+		batch = append(batch, sub)
+		if len(batch) >= c.batchSize {
+			flush()
+		}
+	}
+	if listErr == nil {
+		flush()
+	}
+
+	// A failed save cancels saveCtx, so report the batches ahead of the listing error it caused
+	_ = saves.Wait()
+	if err := failed.join(); err != nil {
+		return err
+	}
+	return listErr
+}
 
-		// Get topic reference from subscription
-		// sub.Topic is in format "projects/{project}/topics/{topic}"
-		topicFullResourceName := sub.Topic
+// saveSubscriptions stores a batch of listed subscriptions, touching those that match
+// cached instead, then the destination and consumers of each
+func (c *Collector) saveSubscriptions(ctx context.Context, client SubscriptionLister, projectID string, subs []*pubsubpb.Subscription, cached map[string]*storage.Subscription) error {
+	names := make([]string, 0, len(subs))
+	records := make([]*storage.Subscription, 0, len(subs))
+	var unchanged []string
+	for _, sub := range subs {
+		record, err := newSubscription(projectID, sub)
+		if err != nil {
+			return err
+		}
+		names = append(names, record.Name)
+		if prev, ok := cached[record.FullResourceName]; ok &&
+			prev.Metadata == record.Metadata && prev.TopicFullResourceName == record.TopicFullResourceName {
+			unchanged = append(unchanged, record.FullResourceName)
+			continue
+		}
+		records = append(records, record)
+	}
 
-		// Save to storage
-		err = c.storage.SaveSubscription(ctx, &storage.Subscription{
-			Name:                  subName,
-			ProjectID:             projectID,
-			TopicFullResourceName: topicFullResourceName,
-			FullResourceName:      fullResourceName,
-			Metadata:              "{}",
+	if len(records) > 0 {
+		err := c.write(ctx, func(ctx context.Context) error {
+			return c.store(ctx).SaveSubscriptions(ctx, records)
 		})
 		if err != nil {
-			return fmt.Errorf("failed to save subscription %s: %w", subName, err)
+			return &BatchError{ProjectID: projectID, Kind: "subscription", Size: len(records), Err: err}
+		}
+		c.observer.AddStored(projectID, "subscription", len(records))
+	}
+	if err := c.touch(ctx, projectID, "subscription", unchanged, c.store(ctx).TouchSubscriptions); err != nil {
+		return err
+	}
+
+	for i, sub := range subs {
+		subName := names[i]
+
+		// Save BigQuery / Cloud Storage sink if the subscription exports directly
+		dest, err := subscriptionDestination(sub, projectID)
+		if err != nil {
+			return fmt.Errorf("failed to read destination of subscription %s: %w", subName, err)
+		}
+		if dest != nil {
+			err := c.write(ctx, func(ctx context.Context) error {
+				return c.store(ctx).SaveSubscriptionDestination(ctx, dest)
+			})
+			if err != nil {
+				return fmt.Errorf("failed to save destination of subscription %s: %w", subName, err)
+			}
+		}
+
+		// Resolve which identities are allowed to consume the subscription
+		if err := c.collectSubscriptionIAM(ctx, client, projectID, sub.Name); err != nil {
+			return fmt.Errorf("failed to collect consumers of subscription %s: %w", subName, err)
 		}
 	}
 
 	return nil
 }
+
+// cachedSubscriptions returns the cached subscriptions of a project by full resource name when
+// collecting incrementally, nil otherwise
+func (c *Collector) cachedSubscriptions(ctx context.Context, projectID string) (map[string]*storage.Subscription, error) {
+	if !c.incremental {
+		return nil, nil
+	}
+	subs, err := c.storage.GetSubscriptions(ctx, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cached subscriptions: %w", err)
+	}
+	cached := make(map[string]*storage.Subscription, len(subs))
+	for _, sub := range subs {
+		cached[sub.FullResourceName] = sub
+	}
+	return cached, nil
+}
+
+// newSubscription converts a listed subscription into its storage representation
+func newSubscription(projectID string, sub *pubsubpb.Subscription) (*storage.Subscription, error) {
+	// Extract subscription name from the full name
+	// sub.Name is in format "projects/{project}/subscriptions/{subscription}"
+	fullResourceName := sub.Name
+	subName := extractResourceName(fullResourceName)
+
+	metadata, err := subscriptionMetadata(sub)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode metadata of subscription %s: %w", subName, err)
+	}
+
+	// sub.Topic is in format "projects/{project}/topics/{topic}"
+	return &storage.Subscription{
+		Name:                  subName,
+		ProjectID:             projectID,
+		TopicFullResourceName: sub.Topic,
+		FullResourceName:      fullResourceName,
+		Filter:                sub.GetFilter(),
+		Metadata:              metadata,
+	}, nil
+}
+
+// subscriptionMetadata encodes the labels, dead-letter topic, push endpoint, ack deadline,
+// filter and retry policy of a subscription as the JSON metadata stored with it
+func subscriptionMetadata(sub *pubsubpb.Subscription) (string, error) {
+	labels := sub.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	metadata := map[string]interface{}{"labels": labels}
+	if topic := sub.GetDeadLetterPolicy().GetDeadLetterTopic(); topic != "" {
+		metadata["dead_letter_topic"] = topic
+	}
+	if endpoint := sub.GetPushConfig().GetPushEndpoint(); endpoint != "" {
+		metadata["push_endpoint"] = endpoint
+	}
+	if deadline := sub.GetAckDeadlineSeconds(); deadline > 0 {
+		metadata["ack_deadline_seconds"] = deadline
+	}
+	if filter := sub.GetFilter(); filter != "" {
+		metadata["filter"] = filter
+	}
+	// Without a retry policy Pub/Sub redelivers nacked messages immediately
+	if policy := sub.GetRetryPolicy(); policy != nil {
+		// Unset bounds take Pub/Sub's defaults
+		minimum, maximum := 10*time.Second, 600*time.Second
+		if policy.GetMinimumBackoff() != nil {
+			minimum = policy.GetMinimumBackoff().AsDuration()
+		}
+		if policy.GetMaximumBackoff() != nil {
+			maximum = policy.GetMaximumBackoff().AsDuration()
+		}
+		metadata["retry_policy"] = map[string]string{
+			"minimum_backoff": minimum.String(),
+			"maximum_backoff": maximum.String(),
+		}
+	}
+
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// subscriptionDestination returns the BigQuery or Cloud Storage destination of a
+// subscription, or nil if the subscription is a pull or push subscription.
+func subscriptionDestination(sub *pubsubpb.Subscription, projectID string) (*storage.SubscriptionDestination, error) {
+	var destType, resource string
+	var metadata map[string]interface{}
+
+	switch {
+	case sub.GetBigqueryConfig() != nil:
+		cfg := sub.GetBigqueryConfig()
+		destType = storage.DestinationTypeBigQuery
+		resource = cfg.GetTable()
+		metadata = map[string]interface{}{
+			"state":                 cfg.GetState().String(),
+			"use_topic_schema":      cfg.GetUseTopicSchema(),
+			"use_table_schema":      cfg.GetUseTableSchema(),
+			"write_metadata":        cfg.GetWriteMetadata(),
+			"service_account_email": cfg.GetServiceAccountEmail(),
+		}
+	case sub.GetCloudStorageConfig() != nil:
+		cfg := sub.GetCloudStorageConfig()
+		destType = storage.DestinationTypeCloudStorage
+		resource = cfg.GetBucket()
+		metadata = map[string]interface{}{
+			"state":                 cfg.GetState().String(),
+			"filename_prefix":       cfg.GetFilenamePrefix(),
+			"filename_suffix":       cfg.GetFilenameSuffix(),
+			"service_account_email": cfg.GetServiceAccountEmail(),
+		}
+	default:
+		return nil, nil
+	}
+
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	return &storage.SubscriptionDestination{
+		SubscriptionFullResourceName: sub.GetName(),
+		ProjectID:                    projectID,
+		Type:                         destType,
+		Resource:                     resource,
+		Metadata:                     string(data),
+	}, nil
+}
@@ -2,53 +2,181 @@ package collector
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 
-	"cloud.google.com/go/pubsub/v2"
 	"cloud.google.com/go/pubsub/v2/apiv1/pubsubpb"
 	"github.com/NissesSenap/gcp-visualizer/internal/storage"
+	"golang.org/x/sync/errgroup"
 	"google.golang.org/api/iterator"
 )
 
-// collectTopics collects all topics from a GCP project
-func (c *Collector) collectTopics(ctx context.Context, client *pubsub.Client, projectID string) error {
+// collectTopics collects all topics from a GCP project.
+// Listing is sequential; topics are saved in batches of batchSize on up to
+// saveWorkers goroutines. Their publishers are read from IAM once all are saved.
+// Collecting incrementally, topics unchanged since they were cached are only touched.
+func (c *Collector) collectTopics(ctx context.Context, client TopicLister, projectID string) error {
+	cached, err := c.cachedTopics(ctx, projectID)
+	if err != nil {
+		return err
+	}
+
 	// Create list request
 	req := &pubsubpb.ListTopicsRequest{
 		Project: fmt.Sprintf("projects/%s", projectID),
 	}
 
-	it := client.TopicAdminClient.ListTopics(ctx, req)
+	saves, saveCtx := errgroup.WithContext(ctx)
+	saves.SetLimit(c.saveWorkers)
+
+	it := client.ListTopics(saveCtx, req)
 
+	var failed batchErrors
+	var names, unchanged []string
+	batch := make([]*storage.Topic, 0, c.batchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		topics := batch
+		saves.Go(func() error {
+			err := c.write(saveCtx, func(ctx context.Context) error {
+				return c.store(ctx).SaveTopics(ctx, topics)
+			})
+			if err != nil {
+				return failed.add(&BatchError{ProjectID: projectID, Kind: "topic", Size: len(topics), Err: err})
+			}
+			c.observer.AddStored(projectID, "topic", len(topics))
+			return nil
+		})
+		batch = make([]*storage.Topic, 0, c.batchSize)
+	}
+
+	var listErr error
 	for {
-		// Rate limiting
-		if err := c.limiter.Wait(ctx); err != nil {
-			return fmt.Errorf("rate limiter error: %w", err)
+		// Rate limiting; also stops listing once a save has failed
+		if err := c.wait(saveCtx, projectID); err != nil {
+			listErr = fmt.Errorf("rate limiter error: %w", err)
+			break
 		}
 
 		topic, err := it.Next()
 		if err == iterator.Done {
 			break
 		}
+		c.observeCall(err)
 		if err != nil {
-			return fmt.Errorf("failed to iterate topics: %w", err)
+			listErr = fmt.Errorf("failed to iterate topics: %w", err)
+			break
 		}
 
-		// Extract topic name from the full name
-		// topic.Name is in format "projects/{project}/topics/{topic}"
-		fullResourceName := topic.Name
-		topicName := extractResourceName(fullResourceName)
-
-		// Save to storage
-		err = c.storage.SaveTopic(ctx, &storage.Topic{
-			Name:             topicName,
-			ProjectID:        projectID,
-			FullResourceName: fullResourceName,
-			Metadata:         "{}",
-		})
+		t, err := newTopic(projectID, topic)
 		if err != nil {
-			return fmt.Errorf("failed to save topic %s: %w", topicName, err)
+			listErr = err
+			break
+		}
+
+		names = append(names, t.FullResourceName)
+		if metadata, ok := cached[t.FullResourceName]; ok && metadata == t.Metadata {
+			unchanged = append(unchanged, t.FullResourceName)
+			continue
 		}
+		batch = append(batch, t)
+		if len(batch) >= c.batchSize {
+			flush()
+		}
+	}
+	if listErr == nil {
+		flush()
 	}
 
+	// A failed save cancels saveCtx, so report the batches ahead of the listing error it caused
+	_ = saves.Wait()
+	if err := failed.join(); err != nil {
+		return err
+	}
+	if listErr != nil {
+		return listErr
+	}
+	if err := c.touch(ctx, projectID, "topic", unchanged, c.store(ctx).TouchTopics); err != nil {
+		return err
+	}
+
+	// Resolve which identities are allowed to publish to the topics
+	for _, name := range names {
+		if err := c.collectTopicIAM(ctx, client, projectID, name); err != nil {
+			return fmt.Errorf("failed to collect publishers of topic %s: %w", extractResourceName(name), err)
+		}
+	}
 	return nil
 }
+
+// cachedTopics returns the metadata of the cached topics of a project by full resource name when
+// collecting incrementally, nil otherwise. The metadata holds every setting a topic is stored with.
+func (c *Collector) cachedTopics(ctx context.Context, projectID string) (map[string]string, error) {
+	if !c.incremental {
+		return nil, nil
+	}
+	topics, err := c.storage.GetTopics(ctx, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cached topics: %w", err)
+	}
+	cached := make(map[string]string, len(topics))
+	for _, t := range topics {
+		cached[t.FullResourceName] = t.Metadata
+	}
+	return cached, nil
+}
+
+// newTopic converts a listed topic into its storage representation
+func newTopic(projectID string, topic *pubsubpb.Topic) (*storage.Topic, error) {
+	// Extract topic name from the full name
+	// topic.Name is in format "projects/{project}/topics/{topic}"
+	fullResourceName := topic.Name
+	topicName := extractResourceName(fullResourceName)
+
+	metadata, err := topicMetadata(topic)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode metadata of topic %s: %w", topicName, err)
+	}
+
+	regions := topic.GetMessageStoragePolicy().GetAllowedPersistenceRegions()
+	return &storage.Topic{
+		Name:             topicName,
+		ProjectID:        projectID,
+		FullResourceName: fullResourceName,
+		Metadata:         metadata,
+		MessageRetention: topic.GetMessageRetentionDuration().AsDuration(),
+		KMSKeyName:       topic.GetKmsKeyName(),
+		StorageRegions:   append([]string(nil), regions...),
+	}, nil
+}
+
+// topicMetadata encodes the labels of a topic as the JSON metadata stored with it,
+// along with the settings that also have their own columns so the changelog
+// records changes to them
+func topicMetadata(topic *pubsubpb.Topic) (string, error) {
+	labels := topic.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	metadata := map[string]interface{}{"labels": labels}
+	if retention := topic.GetMessageRetentionDuration(); retention != nil {
+		metadata["message_retention_duration"] = retention.AsDuration().String()
+	}
+	if key := topic.GetKmsKeyName(); key != "" {
+		metadata["kms_key_name"] = key
+	}
+	if regions := topic.GetMessageStoragePolicy().GetAllowedPersistenceRegions(); len(regions) > 0 {
+		metadata["storage_regions"] = regions
+	}
+	if schema := topic.GetSchemaSettings().GetSchema(); schema != "" {
+		metadata["schema"] = schema
+	}
+
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
@@ -0,0 +1,104 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// SetWriteTimeout bounds every storage write, so a stuck transaction fails the
+// project instead of stalling the scan. Values below 1 disable the timeout.
+func (c *Collector) SetWriteTimeout(timeout time.Duration) {
+	c.writeTimeout = max(timeout, 0)
+}
+
+// write runs a single storage write under the write timeout. A cancelled
+// collection doesn't start new writes.
+func (c *Collector) write(ctx context.Context, fn func(ctx context.Context) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if c.writeTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.writeTimeout)
+		defer cancel()
+	}
+	return fn(ctx)
+}
+
+// touch records the unchanged resources of a kind as seen, in batches of batchSize
+func (c *Collector) touch(ctx context.Context, projectID, kind string, names []string, touch func(ctx context.Context, names []string) error) error {
+	for start := 0; start < len(names); start += c.batchSize {
+		batch := names[start:min(start+c.batchSize, len(names))]
+		err := c.write(ctx, func(ctx context.Context) error {
+			return touch(ctx, batch)
+		})
+		if err != nil {
+			return &BatchError{ProjectID: projectID, Kind: kind, Size: len(batch), Err: err}
+		}
+	}
+	return nil
+}
+
+// BatchError reports a batch of resources that wasn't stored, because the
+// collection was cancelled or the write failed or timed out. Its transaction
+// was rolled back, so none of the batch is in the cache.
+type BatchError struct {
+	ProjectID string
+	Kind      string // "topic" or "subscription", or "resource" for every write of a project collection
+	Size      int
+	Err       error
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("failed to save %d %ss, rolled back: %v", e.Size, e.Kind, e.Err)
+}
+
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
+// RolledBack returns every BatchError in err, including those joined with errors.Join
+func RolledBack(err error) []*BatchError {
+	var batches []*BatchError
+	var walk func(err error)
+	walk = func(err error) {
+		switch e := err.(type) {
+		case nil:
+		case *BatchError:
+			batches = append(batches, e)
+		case interface{ Unwrap() []error }:
+			for _, err := range e.Unwrap() {
+				walk(err)
+			}
+		default:
+			walk(errors.Unwrap(err))
+		}
+	}
+	walk(err)
+	return batches
+}
+
+// batchErrors collects the errors of concurrent batch saves, so every rolled
+// back batch is reported rather than only the first
+type batchErrors struct {
+	mu   sync.Mutex
+	errs []error
+}
+
+func (b *batchErrors) add(err error) error {
+	if err != nil {
+		b.mu.Lock()
+		b.errs = append(b.errs, err)
+		b.mu.Unlock()
+	}
+	return err
+}
+
+func (b *batchErrors) join() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return errors.Join(b.errs...)
+}
@@ -1,36 +1,254 @@
 package config
 
 import (
+	"fmt"
+	"maps"
 	"os"
+	"path"
 	"path/filepath"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/kelseyhightower/envconfig"
 	"gopkg.in/yaml.v3"
 )
 
 type Config struct {
-	OrganizationID string   `yaml:"organization_id" envconfig:"ORGANIZATION_ID"`
-	Projects       []string `yaml:"projects" envconfig:"PROJECTS"`
-	Cache          Cache    `yaml:"cache"`
-	Visualization  Visual   `yaml:"visualization"`
-	RateLimits     Limits   `yaml:"rate_limits"`
+	OrganizationID  string          `yaml:"organization_id" envconfig:"ORGANIZATION_ID"`
+	Projects        []string        `yaml:"projects" envconfig:"PROJECTS"`
+	ProjectsInclude []string        `yaml:"projects_include" envconfig:"PROJECTS_INCLUDE"` // glob patterns, empty includes every project
+	ProjectsExclude []string        `yaml:"projects_exclude" envconfig:"PROJECTS_EXCLUDE"` // glob patterns, applied after the include patterns
+	ScanWindows     []ScanWindow    `yaml:"scan_windows" ignored:"true"`                   // restrict when matching projects are scanned
+	ReadOnly        bool            `yaml:"read_only" envconfig:"READ_ONLY"`
+	Cache           Cache           `yaml:"cache"`
+	Storage         Storage         `yaml:"storage"`
+	Visualization   Visual          `yaml:"visualization"`
+	RateLimits      Limits          `yaml:"rate_limits"`
+	Retries         Retries         `yaml:"retries"`
+	Guardrails      Guardrails      `yaml:"guardrails"`
+	CMDB            CMDB            `yaml:"cmdb"`
+	Notifications   Notifications   `yaml:"notifications"`
+	Collectors      []string        `yaml:"collectors" envconfig:"COLLECTORS"` // pubsub, cloudrun, functions, dataflow, publishers, metrics, projects or pubsublite
+	Publishers      Publishers      `yaml:"publishers"`
+	Metrics         Metrics         `yaml:"metrics"`
+	PubSubLite      PubSubLite      `yaml:"pubsub_lite"`
+	Auth            Auth            `yaml:"auth"`
+	Classification  Classification  `yaml:"classification"`
+	Ownership       Ownership       `yaml:"ownership"`
+	Terraform       Terraform       `yaml:"terraform"`
+	Residency       Residency       `yaml:"residency"`
+	Rules           []Rule          `yaml:"rules" ignored:"true"` // messaging-hygiene rules checked by lint
+	Views           map[string]View `yaml:"views"`
+
+	// Profiles are named overrides for organizations or environments, see Profile
+	Profiles map[string]yaml.Node `yaml:"profiles" ignored:"true"`
+	Profile  string               `yaml:"-" ignored:"true"` // name of the profile Load applied, empty if none
+}
+
+// Profile overrides the organization, projects, cache and rate limits of the config for one organization
+// or environment. It's selected with GCP_VISUALIZER_PROFILE, or --profile, and applied over the rest of
+// the file, so the fields a profile leaves unset keep their top-level values. Environment variables
+// still take precedence over the profile.
+type Profile struct {
+	OrganizationID  string   `yaml:"organization_id"`
+	Projects        []string `yaml:"projects"`
+	ProjectsInclude []string `yaml:"projects_include"`
+	ProjectsExclude []string `yaml:"projects_exclude"`
+	Cache           Cache    `yaml:"cache"`
+	RateLimits      Limits   `yaml:"rate_limits"`
 }
 
 type Cache struct {
-	TTLHours    int `yaml:"ttl_hours" envconfig:"TTL_HOURS"`
-	MaxAgeHours int `yaml:"max_age_hours" envconfig:"MAX_AGE_HOURS"`
+	Path        string `yaml:"path" envconfig:"CACHE_PATH"` // empty uses $XDG_CACHE_HOME/gcp-visualizer/cache.db, or cache.json for the file backend
+	TTLHours    int    `yaml:"ttl_hours" envconfig:"TTL_HOURS"`
+	MaxAgeHours int    `yaml:"max_age_hours" envconfig:"MAX_AGE_HOURS"`
+}
+
+// Storage selects the backend the cache is kept in, see Cache.Path for its location
+type Storage struct {
+	Backend      string        `yaml:"backend" envconfig:"STORAGE_BACKEND"`             // "sqlite" or "file"
+	WriteTimeout time.Duration `yaml:"write_timeout" envconfig:"STORAGE_WRITE_TIMEOUT"` // per storage transaction during scans, zero disables it
+	BusyTimeout  time.Duration `yaml:"busy_timeout" envconfig:"STORAGE_BUSY_TIMEOUT"`   // how long a SQLite write waits for another process's lock, zero fails at once
 }
 
 type Visual struct {
-	Layout         string `yaml:"layout" envconfig:"LAYOUT"`
-	OutputFormat   string `yaml:"output_format" envconfig:"OUTPUT_FORMAT"`
-	IncludeIcons   bool   `yaml:"include_icons" envconfig:"INCLUDE_ICONS"`
-	ShowIAMDetails bool   `yaml:"show_iam_details" envconfig:"SHOW_IAM_DETAILS"`
+	Layout         string      `yaml:"layout" envconfig:"LAYOUT"`
+	OutputFormat   string      `yaml:"output_format" envconfig:"OUTPUT_FORMAT"`
+	IncludeIcons   bool        `yaml:"include_icons" envconfig:"INCLUDE_ICONS"`
+	ShowIAMDetails bool        `yaml:"show_iam_details" envconfig:"SHOW_IAM_DETAILS"`
+	Theme          string      `yaml:"theme" envconfig:"THEME"` // light, dark, colorblind or the path of a custom theme file, empty is light
+	Styles         []StyleRule `yaml:"styles" ignored:"true"`   // applied in order, a later rule overrides an earlier one
+
+	// Glob patterns of resource names, e.g. "events-tenant-*", whose matches in a project are drawn as one node
+	CollapsePatterns []string `yaml:"collapse_patterns" envconfig:"COLLAPSE_PATTERNS"`
+
+	// Diagrams of graphs with more nodes are drawn as a summary of their projects, zero disables the limit
+	MaxNodes int `yaml:"max_nodes" envconfig:"MAX_NODES"`
+}
+
+// StyleRule styles the resources matching all of its conditions in generated diagrams,
+// e.g. topics labeled domain=payments green or resources named "*-dlq" as diamonds
+type StyleRule struct {
+	Type   string            `yaml:"type"`   // node type, e.g. topic or subscription, empty matches every type
+	Name   string            `yaml:"name"`   // glob pattern of the resource name
+	Labels map[string]string `yaml:"labels"` // labels the resource must have, all of them
+	Color  string            `yaml:"color"`  // fill color
+	Border string            `yaml:"border"` // outline color
+	Shape  string            `yaml:"shape"`  // box, ellipse or diamond
 }
 
 type Limits struct {
 	RequestsPerSecond float64 `yaml:"requests_per_second" envconfig:"REQUESTS_PER_SECOND"`
 	MaxConcurrent     int     `yaml:"max_concurrent" envconfig:"MAX_CONCURRENT"`
+	BatchSize         int     `yaml:"batch_size" envconfig:"BATCH_SIZE"`        // resources written per storage transaction
+	Adaptive          bool    `yaml:"adaptive" envconfig:"ADAPTIVE_RATE_LIMIT"` // back off on RESOURCE_EXHAUSTED, then ramp up to requests_per_second
+
+	// Per-project Pub/Sub clients no collection uses are closed, least recently used first, beyond
+	// MaxClients or once idle for ClientIdleTimeout. Zero disables either limit.
+	MaxClients        int           `yaml:"max_clients" envconfig:"MAX_CLIENTS"`
+	ClientIdleTimeout time.Duration `yaml:"client_idle_timeout" envconfig:"CLIENT_IDLE_TIMEOUT"`
+}
+
+// Retries configures how API calls failing with a retryable error are retried
+type Retries struct {
+	MaxAttempts    int           `yaml:"max_attempts" envconfig:"RETRY_MAX_ATTEMPTS"` // including the first call
+	InitialBackoff time.Duration `yaml:"initial_backoff" envconfig:"RETRY_INITIAL_BACKOFF"`
+	MaxBackoff     time.Duration `yaml:"max_backoff" envconfig:"RETRY_MAX_BACKOFF"`
+	Jitter         bool          `yaml:"jitter" envconfig:"RETRY_JITTER"`
+}
+
+// View is a named set of generate options, so recurring diagrams can be
+// rendered with 'generate --view <name>'. Empty fields keep the flag values.
+type View struct {
+	Projects           []string `yaml:"projects"`
+	Where              string   `yaml:"where"`
+	Focus              []string `yaml:"focus"`               // resources to center the diagram on
+	Depth              int      `yaml:"depth"`               // hops from the focus resources
+	TopicFilter        []string `yaml:"topic_filter"`        // glob patterns of topic names
+	SubscriptionFilter []string `yaml:"subscription_filter"` // glob patterns of subscription names
+	Layout             string   `yaml:"layout"`
+	Format             string   `yaml:"format"`
+	ColorBy            string   `yaml:"color_by"`
+	Theme              string   `yaml:"theme"`         // light, dark, colorblind or a custom theme file
+	ShowInferred       bool     `yaml:"show_inferred"` // draw publishers and consumers inferred from IAM
+	TrafficWidth       bool     `yaml:"traffic_width"` // scale flows by the publish traffic of their topic
+	Level              string   `yaml:"level"`         // resource or project
+	GroupBy            string   `yaml:"group_by"`      // project, team or region
+	Output             string   `yaml:"output"`
+}
+
+// Guardrails are inventory limits that trigger warnings after a scan, zero disables a limit
+type Guardrails struct {
+	MaxTopicsPerProject        int     `yaml:"max_topics_per_project" envconfig:"GUARDRAIL_MAX_TOPICS"`
+	MaxSubscriptionsPerProject int     `yaml:"max_subscriptions_per_project" envconfig:"GUARDRAIL_MAX_SUBSCRIPTIONS"`
+	MaxGrowthPercent           float64 `yaml:"max_growth_percent" envconfig:"GUARDRAIL_MAX_GROWTH_PERCENT"` // since the last scan
+	NotifyURL                  string  `yaml:"notify_url" envconfig:"GUARDRAIL_NOTIFY_URL"`                 // webhook receiving warnings as JSON
+}
+
+// CMDB configures the inventory reconciliation pushed to an ITSM/CMDB webhook after each scan
+type CMDB struct {
+	URL               string `yaml:"url" envconfig:"CMDB_URL"`     // empty disables the push
+	Token             string `yaml:"token" envconfig:"CMDB_TOKEN"` // sent as a bearer token
+	TopicClass        string `yaml:"topic_class" envconfig:"CMDB_TOPIC_CLASS"`
+	SubscriptionClass string `yaml:"subscription_class" envconfig:"CMDB_SUBSCRIPTION_CLASS"`
+}
+
+// Notifications configures the summary of every scan posted to Slack or a webhook, for scans run on a schedule
+type Notifications struct {
+	SlackWebhookURL string `yaml:"slack_webhook_url" envconfig:"NOTIFY_SLACK_WEBHOOK_URL"` // Slack incoming webhook, empty disables it
+	WebhookURL      string `yaml:"webhook_url" envconfig:"NOTIFY_WEBHOOK_URL"`             // receives the summary as JSON, empty disables it
+	OnlyChanges     bool   `yaml:"only_changes" envconfig:"NOTIFY_ONLY_CHANGES"`           // skip scans without changes, failed projects or violations
+}
+
+// Publishers configures the "publishers" collector reading Pub/Sub publish calls from Data Access audit logs
+type Publishers struct {
+	Window     time.Duration `yaml:"window" envconfig:"PUBLISHERS_WINDOW"`           // how far back publish calls are looked up
+	MaxEntries int           `yaml:"max_entries" envconfig:"PUBLISHERS_MAX_ENTRIES"` // audit log entries read per project
+}
+
+// PubSubLite configures the "pubsublite" collector reading Pub/Sub Lite topics and subscriptions
+type PubSubLite struct {
+	Locations []string `yaml:"locations" envconfig:"PUBSUB_LITE_LOCATIONS"` // regions or zones listed in every project, e.g. europe-west1-b
+}
+
+// Metrics configures the "metrics" collector reading topic throughput and subscription backlog from Cloud Monitoring
+type Metrics struct {
+	Window     time.Duration `yaml:"window" envconfig:"METRICS_WINDOW"`         // traffic is summed, and the backlog peak taken, over this window
+	Resolution time.Duration `yaml:"resolution" envconfig:"METRICS_RESOLUTION"` // datapoints are stored per resolution, a scan only fetches the newest
+
+	// Subscriptions over either threshold are unhealthy, zero disables a threshold
+	MaxBacklog    int64         `yaml:"max_backlog" envconfig:"METRICS_MAX_BACKLOG"`         // undelivered messages
+	MaxUnackedAge time.Duration `yaml:"max_unacked_age" envconfig:"METRICS_MAX_UNACKED_AGE"` // age of the oldest unacknowledged message
+}
+
+// Auth configures the credentials used to call Google Cloud APIs
+type Auth struct {
+	CredentialsFile string   `yaml:"credentials_file" envconfig:"CREDENTIALS_FILE"` // empty uses Application Default Credentials
+	Scopes          []string `yaml:"scopes" envconfig:"SCOPES"`
+}
+
+// Classification configures the data-classification overlay and lint rule
+type Classification struct {
+	LabelKey        string            `yaml:"label_key" envconfig:"CLASSIFICATION_LABEL_KEY"`
+	Levels          []string          `yaml:"levels" envconfig:"CLASSIFICATION_LEVELS"` // least to most sensitive
+	SensitiveLevel  string            `yaml:"sensitive_level" envconfig:"CLASSIFICATION_SENSITIVE_LEVEL"`
+	AllowedProjects []string          `yaml:"allowed_projects" envconfig:"CLASSIFICATION_ALLOWED_PROJECTS"`
+	Topics          map[string]string `yaml:"topics" ignored:"true"` // topic full resource name -> level
+}
+
+// Ownership configures the teams owning projects, from a project label or a mapping of teams to projects
+type Ownership struct {
+	LabelKey string              `yaml:"label_key" envconfig:"OWNERSHIP_LABEL_KEY"` // project label holding the team, collected by the "projects" collector
+	File     string              `yaml:"file" envconfig:"OWNERSHIP_FILE"`           // YAML file mapping teams to glob patterns of their projects
+	Teams    map[string][]string `yaml:"teams" ignored:"true"`                      // same as the file, takes precedence over it
+}
+
+// LoadTeams returns the mapping of teams to the glob patterns of their projects, read from
+// File and merged with Teams
+func (o Ownership) LoadTeams() (map[string][]string, error) {
+	teams := make(map[string][]string)
+	if o.File != "" {
+		data, err := os.ReadFile(o.File)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read ownership file: %w", err)
+		}
+		if err := yaml.Unmarshal(data, &teams); err != nil {
+			return nil, fmt.Errorf("invalid ownership file %s: %w", o.File, err)
+		}
+		for team, patterns := range teams {
+			for _, pattern := range patterns {
+				if _, err := path.Match(pattern, ""); err != nil {
+					return nil, fmt.Errorf("invalid ownership file %s: team %s: invalid pattern %q", o.File, team, pattern)
+				}
+			}
+		}
+	}
+	maps.Copy(teams, o.Teams)
+	return teams, nil
+}
+
+// Terraform configures the state files telling Terraform-managed topics and subscriptions from those created by hand
+type Terraform struct {
+	StateFiles []string `yaml:"state_files" envconfig:"TERRAFORM_STATE_FILES"` // state files or "terraform show -json" outputs, glob patterns allowed
+}
+
+// Residency is the data-residency policy topics are checked against by 'report residency': the regions
+// their message storage policy may allow
+type Residency struct {
+	AllowedRegions []string            `yaml:"allowed_regions" envconfig:"RESIDENCY_ALLOWED_REGIONS"` // for every project not matched by Projects
+	Projects       map[string][]string `yaml:"projects" ignored:"true"`                               // glob pattern of projects -> their allowed regions
+}
+
+// Rule is a messaging-hygiene rule checked by lint: every resource matching Where must also match Require.
+// Both are expressions of the --where language, e.g. where 'type == "subscription" && project =~ ".*-prod"'
+// and require 'has_dlq' for "every production subscription must have a dead-letter topic".
+type Rule struct {
+	Name     string `yaml:"name"`
+	Message  string `yaml:"message"`  // describes a violation, e.g. "production subscriptions need a dead-letter topic"
+	Severity string `yaml:"severity"` // warning or error, empty is error
+	Where    string `yaml:"where"`    // resources the rule applies to, empty is every resource
+	Require  string `yaml:"require"`
 }
 
 // ConfigPath returns the configuration file path
@@ -43,6 +261,11 @@ func ConfigPath() string {
 	return filepath.Join(home, ".config", "gcp-visualizer", "config.yaml")
 }
 
+// ProfileName returns the name of the profile to apply, empty for none
+func ProfileName() string {
+	return os.Getenv("GCP_VISUALIZER_PROFILE")
+}
+
 func Load() (*Config, error) {
 	cfg := DefaultConfig()
 
@@ -54,26 +277,145 @@ func Load() (*Config, error) {
 		}
 	}
 
+	if name := ProfileName(); name != "" {
+		if err := cfg.applyProfile(name); err != nil {
+			return nil, err
+		}
+	}
+
 	// Override with environment variables
 	// Process top-level fields
-	if err := envconfig.Process("GCP_VISUALIZER", cfg); err != nil {
+	if err := envconfig.Process(EnvPrefix, cfg); err != nil {
 		return nil, err
 	}
 
 	// Process nested structs with the same prefix to support flat env var names
-	if err := envconfig.Process("GCP_VISUALIZER", &cfg.Cache); err != nil {
+	if err := envconfig.Process(EnvPrefix, &cfg.Cache); err != nil {
+		return nil, err
+	}
+	if err := envconfig.Process(EnvPrefix, &cfg.Storage); err != nil {
+		return nil, err
+	}
+	if err := envconfig.Process(EnvPrefix, &cfg.Visualization); err != nil {
+		return nil, err
+	}
+	if err := envconfig.Process(EnvPrefix, &cfg.RateLimits); err != nil {
+		return nil, err
+	}
+	if err := envconfig.Process(EnvPrefix, &cfg.Retries); err != nil {
+		return nil, err
+	}
+	if err := envconfig.Process(EnvPrefix, &cfg.Guardrails); err != nil {
+		return nil, err
+	}
+	if err := envconfig.Process(EnvPrefix, &cfg.CMDB); err != nil {
+		return nil, err
+	}
+	if err := envconfig.Process(EnvPrefix, &cfg.Notifications); err != nil {
+		return nil, err
+	}
+	if err := envconfig.Process(EnvPrefix, &cfg.Publishers); err != nil {
+		return nil, err
+	}
+	if err := envconfig.Process(EnvPrefix, &cfg.Metrics); err != nil {
+		return nil, err
+	}
+	if err := envconfig.Process(EnvPrefix, &cfg.PubSubLite); err != nil {
+		return nil, err
+	}
+	if err := envconfig.Process(EnvPrefix, &cfg.Auth); err != nil {
 		return nil, err
 	}
-	if err := envconfig.Process("GCP_VISUALIZER", &cfg.Visualization); err != nil {
+	if err := envconfig.Process(EnvPrefix, &cfg.Classification); err != nil {
 		return nil, err
 	}
-	if err := envconfig.Process("GCP_VISUALIZER", &cfg.RateLimits); err != nil {
+	if err := envconfig.Process(EnvPrefix, &cfg.Ownership); err != nil {
+		return nil, err
+	}
+	if err := envconfig.Process(EnvPrefix, &cfg.Terraform); err != nil {
+		return nil, err
+	}
+	if err := envconfig.Process(EnvPrefix, &cfg.Residency); err != nil {
+		return nil, err
+	}
+
+	if _, err := cfg.Validate(); err != nil {
 		return nil, err
 	}
 
 	return cfg, nil
 }
 
+// applyProfile overrides the config with the fields set by the profile called name
+func (c *Config) applyProfile(name string) error {
+	node, ok := c.Profiles[name]
+	if !ok {
+		names := slices.Sorted(maps.Keys(c.Profiles))
+		if len(names) == 0 {
+			return fmt.Errorf("unknown profile %s, %s defines no profiles", name, ConfigPath())
+		}
+		return fmt.Errorf("unknown profile %s, use %s", name, strings.Join(names, ", "))
+	}
+
+	profile := Profile{
+		OrganizationID:  c.OrganizationID,
+		Projects:        c.Projects,
+		ProjectsInclude: c.ProjectsInclude,
+		ProjectsExclude: c.ProjectsExclude,
+		Cache:           c.Cache,
+		RateLimits:      c.RateLimits,
+	}
+	if err := node.Decode(&profile); err != nil {
+		return fmt.Errorf("invalid profile %s: %w", name, err)
+	}
+	c.OrganizationID = profile.OrganizationID
+	c.Projects = profile.Projects
+	c.ProjectsInclude = profile.ProjectsInclude
+	c.ProjectsExclude = profile.ProjectsExclude
+	c.Cache = profile.Cache
+	c.RateLimits = profile.RateLimits
+	c.Profile = name
+	return nil
+}
+
+// FilterProjects returns the projects matching any include pattern and no exclude pattern,
+// in their original order. Patterns use path.Match syntax, e.g. "*-sandbox".
+func (c *Config) FilterProjects(projects []string) ([]string, error) {
+	matchAny := func(patterns []string, project string) (bool, error) {
+		for _, pattern := range patterns {
+			ok, err := path.Match(pattern, project)
+			if err != nil {
+				return false, fmt.Errorf("invalid project pattern %q: %w", pattern, err)
+			}
+			if ok {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+
+	var filtered []string
+	for _, project := range projects {
+		if len(c.ProjectsInclude) > 0 {
+			included, err := matchAny(c.ProjectsInclude, project)
+			if err != nil {
+				return nil, err
+			}
+			if !included {
+				continue
+			}
+		}
+		excluded, err := matchAny(c.ProjectsExclude, project)
+		if err != nil {
+			return nil, err
+		}
+		if !excluded {
+			filtered = append(filtered, project)
+		}
+	}
+	return filtered, nil
+}
+
 func (c *Config) Save() error {
 	configPath := ConfigPath()
 
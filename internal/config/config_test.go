@@ -4,6 +4,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -17,6 +18,43 @@ func TestDefaultConfig(t *testing.T) {
 	assert.Equal(t, 24, cfg.Cache.MaxAgeHours)
 	assert.Equal(t, "svg", cfg.Visualization.OutputFormat)
 	assert.Equal(t, 5, cfg.RateLimits.MaxConcurrent)
+	assert.Equal(t, 500, cfg.RateLimits.BatchSize)
+	assert.Equal(t, 5000, cfg.Guardrails.MaxSubscriptionsPerProject)
+	assert.Equal(t, 20.0, cfg.Guardrails.MaxGrowthPercent)
+	assert.True(t, cfg.ReadOnly)
+	assert.Equal(t, "data_classification", cfg.Classification.LabelKey)
+	assert.Equal(t, "confidential", cfg.Classification.SensitiveLevel)
+}
+
+func TestLoadConfig_Classification(t *testing.T) {
+	tempDir := t.TempDir()
+	configPath := filepath.Join(tempDir, "config.yaml")
+
+	yamlContent := `
+classification:
+  levels: ["low", "high"]
+  sensitive_level: "high"
+  allowed_projects: ["secure-project"]
+  topics:
+    projects/p/topics/payments: "high"
+`
+
+	err := os.WriteFile(configPath, []byte(yamlContent), 0644)
+	require.NoError(t, err)
+
+	t.Setenv("GCP_VISUALIZER_CONFIG", configPath)
+	t.Setenv("GCP_VISUALIZER_CLASSIFICATION_ALLOWED_PROJECTS", "env-project")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"low", "high"}, cfg.Classification.Levels)
+	assert.Equal(t, "high", cfg.Classification.SensitiveLevel)
+	assert.Equal(t, "high", cfg.Classification.Topics["projects/p/topics/payments"])
+	// Default kept when not set in YAML
+	assert.Equal(t, "data_classification", cfg.Classification.LabelKey)
+	// Environment overrides YAML
+	assert.Equal(t, []string{"env-project"}, cfg.Classification.AllowedProjects)
 }
 
 func TestLoadConfig_Defaults(t *testing.T) {
@@ -214,6 +252,85 @@ func TestConfigSave_CreatesDirectory(t *testing.T) {
 	require.NoError(t, err)
 }
 
+func TestLoadConfig_Auth(t *testing.T) {
+	tempDir := t.TempDir()
+	configPath := filepath.Join(tempDir, "config.yaml")
+
+	yamlContent := `
+auth:
+  credentials_file: /etc/gcp/scanner.json
+  scopes:
+    - https://www.googleapis.com/auth/pubsub
+`
+	require.NoError(t, os.WriteFile(configPath, []byte(yamlContent), 0644))
+	t.Setenv("GCP_VISUALIZER_CONFIG", configPath)
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, "/etc/gcp/scanner.json", cfg.Auth.CredentialsFile)
+	assert.Equal(t, []string{"https://www.googleapis.com/auth/pubsub"}, cfg.Auth.Scopes)
+
+	t.Setenv("GCP_VISUALIZER_CREDENTIALS_FILE", "/tmp/override.json")
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, "/tmp/override.json", cfg.Auth.CredentialsFile)
+}
+
+func TestLoadConfig_Profiles(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	yamlContent := `
+organization_id: "default-org"
+projects: ["shared"]
+cache:
+  ttl_hours: 3
+rate_limits:
+  requests_per_second: 10
+profiles:
+  prod:
+    organization_id: "prod-org"
+    projects: ["prod-a", "prod-b"]
+    cache:
+      path: /var/cache/prod.db
+    rate_limits:
+      requests_per_second: 2
+  staging:
+    projects_include: ["staging-*"]
+`
+	require.NoError(t, os.WriteFile(configPath, []byte(yamlContent), 0644))
+	t.Setenv("GCP_VISUALIZER_CONFIG", configPath)
+
+	// Without a profile the top-level values apply
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, "default-org", cfg.OrganizationID)
+	assert.Empty(t, cfg.Profile)
+
+	t.Setenv("GCP_VISUALIZER_PROFILE", "prod")
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, "prod", cfg.Profile)
+	assert.Equal(t, "prod-org", cfg.OrganizationID)
+	assert.Equal(t, []string{"prod-a", "prod-b"}, cfg.Projects)
+	assert.Equal(t, "/var/cache/prod.db", cfg.Cache.Path)
+	assert.Equal(t, 2.0, cfg.RateLimits.RequestsPerSecond)
+	// Fields the profile leaves unset keep their top-level values
+	assert.Equal(t, 3, cfg.Cache.TTLHours)
+	assert.Equal(t, 5, cfg.RateLimits.MaxConcurrent)
+
+	t.Setenv("GCP_VISUALIZER_PROFILE", "staging")
+	t.Setenv("GCP_VISUALIZER_REQUESTS_PER_SECOND", "4")
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, "default-org", cfg.OrganizationID)
+	assert.Equal(t, []string{"staging-*"}, cfg.ProjectsInclude)
+	// Environment variables override the profile
+	assert.Equal(t, 4.0, cfg.RateLimits.RequestsPerSecond)
+
+	t.Setenv("GCP_VISUALIZER_PROFILE", "dev")
+	_, err = Load()
+	assert.ErrorContains(t, err, "unknown profile dev, use prod, staging")
+}
+
 func TestLoadConfig_InvalidYAML(t *testing.T) {
 	tempDir := t.TempDir()
 	configPath := filepath.Join(tempDir, "invalid.yaml")
@@ -231,3 +348,305 @@ this is not: valid: yaml: content
 	_, err = Load()
 	require.Error(t, err)
 }
+
+func TestLoadConfig_Retries(t *testing.T) {
+	tempDir := t.TempDir()
+	configPath := filepath.Join(tempDir, "config.yaml")
+
+	yamlContent := `
+retries:
+  max_attempts: 6
+  initial_backoff: 2s
+  max_backoff: 30s
+`
+
+	err := os.WriteFile(configPath, []byte(yamlContent), 0644)
+	require.NoError(t, err)
+
+	t.Setenv("GCP_VISUALIZER_CONFIG", configPath)
+	t.Setenv("GCP_VISUALIZER_RETRY_JITTER", "false")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+
+	assert.Equal(t, 6, cfg.Retries.MaxAttempts)
+	assert.Equal(t, 2*time.Second, cfg.Retries.InitialBackoff)
+	assert.Equal(t, 30*time.Second, cfg.Retries.MaxBackoff)
+	assert.False(t, cfg.Retries.Jitter)
+}
+
+func TestLoadConfig_Storage(t *testing.T) {
+	t.Setenv("GCP_VISUALIZER_CONFIG", filepath.Join(t.TempDir(), "missing.yaml"))
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, "sqlite", cfg.Storage.Backend)
+	assert.Equal(t, 30*time.Second, cfg.Storage.WriteTimeout)
+
+	t.Setenv("GCP_VISUALIZER_STORAGE_BACKEND", "file")
+	t.Setenv("GCP_VISUALIZER_STORAGE_WRITE_TIMEOUT", "2m")
+	t.Setenv("GCP_VISUALIZER_CACHE_PATH", "/var/cache/gcp-visualizer/cache.json")
+
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, "file", cfg.Storage.Backend)
+	assert.Equal(t, 2*time.Minute, cfg.Storage.WriteTimeout)
+	assert.Equal(t, "/var/cache/gcp-visualizer/cache.json", cfg.Cache.Path)
+}
+
+func TestLoadConfig_Collectors(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	t.Setenv("GCP_VISUALIZER_CONFIG", filepath.Join(t.TempDir(), "missing.yaml"))
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"pubsub"}, cfg.Collectors, "only Pub/Sub is collected by default")
+
+	require.NoError(t, os.WriteFile(configPath, []byte("collectors: [pubsub, cloudrun]\n"), 0644))
+	t.Setenv("GCP_VISUALIZER_CONFIG", configPath)
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"pubsub", "cloudrun"}, cfg.Collectors)
+
+	t.Setenv("GCP_VISUALIZER_COLLECTORS", "pubsub,functions,dataflow")
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"pubsub", "functions", "dataflow"}, cfg.Collectors)
+}
+
+func TestLoadConfig_Publishers(t *testing.T) {
+	t.Setenv("GCP_VISUALIZER_CONFIG", filepath.Join(t.TempDir(), "missing.yaml"))
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, Publishers{Window: 24 * time.Hour, MaxEntries: 10000}, cfg.Publishers)
+
+	t.Setenv("GCP_VISUALIZER_PUBLISHERS_WINDOW", "72h")
+	t.Setenv("GCP_VISUALIZER_PUBLISHERS_MAX_ENTRIES", "500")
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, Publishers{Window: 72 * time.Hour, MaxEntries: 500}, cfg.Publishers)
+}
+
+func TestLoadConfig_Metrics(t *testing.T) {
+	t.Setenv("GCP_VISUALIZER_CONFIG", filepath.Join(t.TempDir(), "missing.yaml"))
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, Metrics{Window: time.Hour, Resolution: 5 * time.Minute, MaxBacklog: 10000, MaxUnackedAge: time.Hour}, cfg.Metrics)
+
+	t.Setenv("GCP_VISUALIZER_METRICS_WINDOW", "6h")
+	t.Setenv("GCP_VISUALIZER_METRICS_RESOLUTION", "1m")
+	t.Setenv("GCP_VISUALIZER_METRICS_MAX_BACKLOG", "0")
+	t.Setenv("GCP_VISUALIZER_METRICS_MAX_UNACKED_AGE", "15m")
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, Metrics{Window: 6 * time.Hour, Resolution: time.Minute, MaxUnackedAge: 15 * time.Minute}, cfg.Metrics)
+}
+
+func TestLoadConfig_ScanWindows(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	yamlContent := `
+scan_windows:
+  - projects: ["*-prod"]
+    start: "22:00"
+    end: "06:00"
+    days: [sat, sun]
+    timezone: Europe/Stockholm
+`
+	require.NoError(t, os.WriteFile(configPath, []byte(yamlContent), 0644))
+	t.Setenv("GCP_VISUALIZER_CONFIG", configPath)
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	require.Len(t, cfg.ScanWindows, 1)
+	assert.Equal(t, ScanWindow{
+		Projects: []string{"*-prod"},
+		Start:    "22:00",
+		End:      "06:00",
+		Days:     []string{"sat", "sun"},
+		Timezone: "Europe/Stockholm",
+	}, cfg.ScanWindows[0])
+}
+
+func TestScanWindowContains(t *testing.T) {
+	utc := func(day, hour, minute int) time.Time {
+		// 2026-10-17 is a Saturday
+		return time.Date(2026, 10, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	for name, tc := range map[string]struct {
+		window ScanWindow
+		at     time.Time
+		want   bool
+	}{
+		"inside":                  {ScanWindow{Start: "09:00", End: "17:00"}, utc(17, 12, 0), true},
+		"end is exclusive":        {ScanWindow{Start: "09:00", End: "17:00"}, utc(17, 17, 0), false},
+		"overnight before":        {ScanWindow{Start: "22:00", End: "06:00"}, utc(17, 23, 30), true},
+		"overnight after":         {ScanWindow{Start: "22:00", End: "06:00"}, utc(17, 5, 59), true},
+		"overnight outside":       {ScanWindow{Start: "22:00", End: "06:00"}, utc(17, 12, 0), false},
+		"day":                     {ScanWindow{Start: "00:00", End: "23:59", Days: []string{"sat"}}, utc(17, 12, 0), true},
+		"other day":               {ScanWindow{Start: "00:00", End: "23:59", Days: []string{"Mon", "Tue"}}, utc(17, 12, 0), false},
+		"overnight from friday":   {ScanWindow{Start: "22:00", End: "06:00", Days: []string{"fri"}}, utc(17, 2, 0), true},
+		"overnight from saturday": {ScanWindow{Start: "22:00", End: "06:00", Days: []string{"sat"}}, utc(17, 2, 0), false},
+		"timezone":                {ScanWindow{Start: "13:00", End: "15:00", Timezone: "Europe/Stockholm"}, utc(17, 12, 0), true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			got, err := tc.window.Contains(tc.at)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+
+	_, err := ScanWindow{Start: "9am", End: "17:00"}.Contains(utc(17, 12, 0))
+	assert.ErrorContains(t, err, "expected HH:MM")
+	_, err = ScanWindow{Start: "09:00", End: "17:00", Days: []string{"someday"}}.Contains(utc(17, 12, 0))
+	assert.ErrorContains(t, err, "invalid scan window day")
+}
+
+func TestSplitByScanWindow(t *testing.T) {
+	cfg := &Config{ScanWindows: []ScanWindow{
+		{Projects: []string{"*-prod"}, Start: "22:00", End: "06:00"},
+		{Projects: []string{"payments-prod"}, Start: "12:00", End: "13:00"},
+	}}
+	projects := []string{"shop-prod", "payments-prod", "shop-dev"}
+
+	allowed, deferred, err := cfg.SplitByScanWindow(projects, time.Date(2026, 10, 17, 12, 30, 0, 0, time.Local))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"payments-prod", "shop-dev"}, allowed)
+	assert.Equal(t, []string{"shop-prod"}, deferred)
+
+	allowed, deferred, err = cfg.SplitByScanWindow(projects, time.Date(2026, 10, 17, 23, 0, 0, 0, time.Local))
+	require.NoError(t, err)
+	assert.Equal(t, projects, allowed)
+	assert.Empty(t, deferred)
+}
+
+func TestFilterProjects(t *testing.T) {
+	projects := []string{"shop-prod", "shop-sandbox", "analytics-prod", "analytics-sandbox", "legacy"}
+
+	cfg := &Config{}
+	filtered, err := cfg.FilterProjects(projects)
+	require.NoError(t, err)
+	assert.Equal(t, projects, filtered, "no patterns keep every project")
+
+	cfg.ProjectsExclude = []string{"*-sandbox"}
+	filtered, err = cfg.FilterProjects(projects)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"shop-prod", "analytics-prod", "legacy"}, filtered)
+
+	cfg.ProjectsInclude = []string{"shop-*", "analytics-*"}
+	filtered, err = cfg.FilterProjects(projects)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"shop-prod", "analytics-prod"}, filtered)
+
+	cfg.ProjectsExclude = []string{"["}
+	_, err = cfg.FilterProjects(projects)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid project pattern")
+}
+
+func TestValidate(t *testing.T) {
+	warnings, err := DefaultConfig().Validate()
+	require.NoError(t, err)
+	assert.Empty(t, warnings)
+
+	tests := map[string]struct {
+		modify  func(*Config)
+		problem string
+	}{
+		"negative rps":      {func(c *Config) { c.RateLimits.RequestsPerSecond = -1 }, "rate_limits.requests_per_second must be > 0"},
+		"zero concurrency":  {func(c *Config) { c.RateLimits.MaxConcurrent = 0 }, "rate_limits.max_concurrent must be >= 1"},
+		"unknown layout":    {func(c *Config) { c.Visualization.Layout = "circo" }, `visualization.layout must be one of fdp, dot, neato, not "circo"`},
+		"unknown format":    {func(c *Config) { c.Visualization.OutputFormat = "gif" }, `visualization.output_format must be one of svg, png, pdf, html, json, openlineage, not "gif"`},
+		"unknown backend":   {func(c *Config) { c.Storage.Backend = "postgres" }, `storage.backend must be one of sqlite, file, not "postgres"`},
+		"backoff order":     {func(c *Config) { c.Retries.MaxBackoff = time.Millisecond }, "retries.max_backoff must be >= retries.initial_backoff (1s)"},
+		"resolution order":  {func(c *Config) { c.Metrics.Resolution = 2 * time.Hour }, "metrics.resolution must be <= metrics.window (1h0m0s)"},
+		"sensitive level":   {func(c *Config) { c.Classification.SensitiveLevel = "secret" }, `classification.sensitive_level must be one of public, internal, confidential, restricted, not "secret"`},
+		"view format":       {func(c *Config) { c.Views = map[string]View{"prod": {Format: "gif"}} }, `views.prod.format must be one of svg, png, pdf, html, json, openlineage, not "gif"`},
+		"scan window clock": {func(c *Config) { c.ScanWindows = []ScanWindow{{Projects: []string{"*"}, Start: "25:00", End: "06:00"}} }, `scan_windows[0].start must be HH:MM, not "25:00"`},
+		"project pattern":   {func(c *Config) { c.ProjectsExclude = []string{"["} }, `projects_exclude: invalid pattern "["`},
+		"style shape":       {func(c *Config) { c.Visualization.Styles = []StyleRule{{Shape: "star"}} }, `visualization.styles[0].shape must be one of box, ellipse, diamond, not "star"`},
+		"ownership pattern": {func(c *Config) { c.Ownership.Teams = map[string][]string{"payments": {"pay-["}} }, `ownership.teams[payments]: invalid pattern "pay-["`},
+		"rule severity":     {func(c *Config) { c.Rules = []Rule{{Name: "dlq", Severity: "fatal", Require: "has_dlq"}} }, `rules[0].severity must be one of warning, error, not "fatal"`},
+		"rule require":      {func(c *Config) { c.Rules = []Rule{{Name: "dlq", Where: `type == "subscription"`}} }, "rules[0].require is required"},
+		"style type":        {func(c *Config) { c.Visualization.Styles = []StyleRule{{Type: "queue", Color: "red"}} }, `visualization.styles[0].type must be one of topic, subscription, bigquery_table, storage_bucket, identity, cloud_run_service, cloud_function, dataflow_job, pubsub_lite_topic, pubsub_lite_subscription, not "queue"`},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			tt.modify(cfg)
+			_, err := cfg.Validate()
+			var invalid *ValidationError
+			require.ErrorAs(t, err, &invalid)
+			assert.Equal(t, []string{tt.problem}, invalid.Problems)
+		})
+	}
+
+	cfg := DefaultConfig()
+	cfg.ReadOnly = false
+	cfg.Cache.MaxAgeHours = 0
+	cfg.Cache.TTLHours = 2
+	cfg.Retries.MaxAttempts = 1
+	cfg.RateLimits.MaxClients = 2
+	warnings, err = cfg.Validate()
+	require.NoError(t, err)
+	assert.Len(t, warnings, 3)
+}
+
+func TestOwnership_LoadTeams(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "teams.yaml")
+	require.NoError(t, os.WriteFile(file, []byte("payments: [payments-*]\nsearch: [search-prod]\n"), 0644))
+
+	teams, err := Ownership{File: file, Teams: map[string][]string{"search": {"search-*"}}}.LoadTeams()
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]string{"payments": {"payments-*"}, "search": {"search-*"}}, teams)
+
+	require.NoError(t, os.WriteFile(file, []byte("payments: [\"pay-[\"]\n"), 0644))
+	_, err = Ownership{File: file}.LoadTeams()
+	assert.ErrorContains(t, err, `team payments: invalid pattern "pay-["`)
+}
+
+func TestLoadConfig_Invalid(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(configPath, []byte("rate_limits:\n  max_concurrent: 0\n"), 0644))
+	t.Setenv("GCP_VISUALIZER_CONFIG", configPath)
+	t.Setenv("GCP_VISUALIZER_REQUESTS_PER_SECOND", "-5")
+
+	_, err := Load()
+	assert.ErrorContains(t, err, "rate_limits.requests_per_second must be > 0\n  rate_limits.max_concurrent must be >= 1")
+}
+
+func TestEnvVars(t *testing.T) {
+	t.Setenv("GCP_VISUALIZER_CONFIG", filepath.Join(t.TempDir(), "config.yaml"))
+	t.Setenv("GCP_VISUALIZER_MAX_CONCURRENT", "8")
+	t.Setenv("GCP_VISUALIZER_CMDB_TOKEN", "s3cret")
+	t.Setenv("GCP_VISUALIZER_RETRY_MAX_BACKOFF", "10s")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	vars := make(map[string]EnvVar)
+	for _, v := range EnvVars(cfg, os.LookupEnv) {
+		vars[v.Name] = v
+	}
+
+	assert.Equal(t, EnvVar{
+		Name: "GCP_VISUALIZER_MAX_CONCURRENT", Field: "rate_limits.max_concurrent", Type: "int", Default: "5", Value: "8", Set: true,
+	}, vars["GCP_VISUALIZER_MAX_CONCURRENT"])
+	assert.Equal(t, EnvVar{
+		Name: "GCP_VISUALIZER_RETRY_MAX_BACKOFF", Field: "retries.max_backoff", Type: "time.Duration", Default: "4s", Value: "10s", Set: true,
+	}, vars["GCP_VISUALIZER_RETRY_MAX_BACKOFF"])
+	assert.Equal(t, EnvVar{
+		Name: "GCP_VISUALIZER_COLLECTORS", Field: "collectors", Type: "[]string", Default: "pubsub", Value: "pubsub",
+	}, vars["GCP_VISUALIZER_COLLECTORS"])
+	assert.Equal(t, "********", vars["GCP_VISUALIZER_CMDB_TOKEN"].Value)
+	assert.Contains(t, vars, "GCP_VISUALIZER_PROFILE")
+
+	// Every variable of the nested sections is listed, but not the ignored fields
+	assert.Contains(t, vars, "GCP_VISUALIZER_CLASSIFICATION_LABEL_KEY")
+	assert.Contains(t, vars, "GCP_VISUALIZER_STORAGE_WRITE_TIMEOUT")
+	for name := range vars {
+		assert.NotContains(t, name, "SCAN_WINDOWS")
+		assert.NotContains(t, name, "CLASSIFICATION_TOPICS")
+	}
+}
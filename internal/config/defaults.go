@@ -1,18 +1,60 @@
 package config
 
+import "time"
+
 func DefaultConfig() *Config {
 	return &Config{
+		ReadOnly: true,
 		Cache: Cache{
 			TTLHours:    1,
 			MaxAgeHours: 24,
 		},
+		Storage: Storage{
+			Backend:      "sqlite",
+			WriteTimeout: 30 * time.Second,
+			BusyTimeout:  5 * time.Second,
+		},
 		Visualization: Visual{
 			Layout:       "fdp",
 			OutputFormat: "svg",
+			MaxNodes:     1000,
 		},
 		RateLimits: Limits{
 			RequestsPerSecond: 10,
 			MaxConcurrent:     5,
+			BatchSize:         500,
+			MaxClients:        20,
+			ClientIdleTimeout: time.Minute,
+		},
+		Retries: Retries{
+			MaxAttempts:    4,
+			InitialBackoff: time.Second,
+			MaxBackoff:     4 * time.Second,
+			Jitter:         true,
+		},
+		Guardrails: Guardrails{
+			MaxSubscriptionsPerProject: 5000,
+			MaxGrowthPercent:           20,
+		},
+		Collectors: []string{"pubsub"},
+		Publishers: Publishers{
+			Window:     24 * time.Hour,
+			MaxEntries: 10000,
+		},
+		Metrics: Metrics{
+			Window:        time.Hour,
+			Resolution:    5 * time.Minute,
+			MaxBacklog:    10000,
+			MaxUnackedAge: time.Hour,
+		},
+		CMDB: CMDB{
+			TopicClass:        "u_cmdb_ci_gcp_pubsub_topic",
+			SubscriptionClass: "u_cmdb_ci_gcp_pubsub_subscription",
+		},
+		Classification: Classification{
+			LabelKey:       "data_classification",
+			Levels:         []string{"public", "internal", "confidential", "restricted"},
+			SensitiveLevel: "confidential",
 		},
 	}
 }
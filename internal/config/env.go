@@ -0,0 +1,89 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// EnvPrefix is the prefix of every environment variable read by Load
+const EnvPrefix = "GCP_VISUALIZER"
+
+// EnvVar is an environment variable read by Load, the config field it sets and its values
+type EnvVar struct {
+	Name    string `json:"name"`    // e.g. "GCP_VISUALIZER_MAX_CONCURRENT"
+	Field   string `json:"field"`   // YAML path of the field, e.g. "rate_limits.max_concurrent"
+	Type    string `json:"type"`    // Go type, e.g. "int" or "time.Duration"
+	Default string `json:"default"` // of DefaultConfig
+	Value   string `json:"value"`   // effective value of the config, masked for secrets
+	Set     bool   `json:"set"`     // the variable is set in the environment
+}
+
+// secretEnvVars are the variables whose values EnvVars masks
+var secretEnvVars = map[string]bool{
+	EnvPrefix + "_CMDB_TOKEN":               true,
+	EnvPrefix + "_NOTIFY_SLACK_WEBHOOK_URL": true, // the URL holds the token of the webhook
+}
+
+// EnvVars returns every environment variable Load reads, in the order of the fields of Config,
+// with the values of cfg. The top-level fields and those of the nested sections all use flat names.
+func EnvVars(cfg *Config, lookup func(string) (string, bool)) []EnvVar {
+	vars := []EnvVar{
+		{Name: EnvPrefix + "_CONFIG", Field: "-", Type: "string", Default: ConfigPath(), Value: ConfigPath()},
+		{Name: EnvPrefix + "_PROFILE", Field: "-", Type: "string", Value: cfg.Profile},
+	}
+	vars = append(vars, envVarsOf(reflect.ValueOf(DefaultConfig()).Elem(), reflect.ValueOf(cfg).Elem(), "")...)
+	for i := range vars {
+		_, vars[i].Set = lookup(vars[i].Name)
+		if secretEnvVars[vars[i].Name] && vars[i].Value != "" {
+			vars[i].Value = "********"
+		}
+	}
+	return vars
+}
+
+// envVarsOf returns the variables of the fields of the struct values def and cur, and of the structs
+// nested in them, prefixing their YAML names with section
+func envVarsOf(def, cur reflect.Value, section string) []EnvVar {
+	var vars []EnvVar
+	t := cur.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() || field.Tag.Get("ignored") == "true" {
+			continue
+		}
+		name := section + strings.Split(field.Tag.Get("yaml"), ",")[0]
+
+		key := field.Tag.Get("envconfig")
+		if key == "" {
+			if field.Type.Kind() == reflect.Struct {
+				vars = append(vars, envVarsOf(def.Field(i), cur.Field(i), name+".")...)
+			}
+			continue
+		}
+		vars = append(vars, EnvVar{
+			Name:    EnvPrefix + "_" + key,
+			Field:   name,
+			Type:    field.Type.String(),
+			Default: formatEnvValue(def.Field(i)),
+			Value:   formatEnvValue(cur.Field(i)),
+		})
+	}
+	return vars
+}
+
+// formatEnvValue formats v as it would be written in the environment, lists comma separated
+func formatEnvValue(v reflect.Value) string {
+	if d, ok := v.Interface().(time.Duration); ok {
+		return d.String()
+	}
+	if v.Kind() == reflect.Slice {
+		items := make([]string, v.Len())
+		for i := range items {
+			items[i] = fmt.Sprint(v.Index(i).Interface())
+		}
+		return strings.Join(items, ",")
+	}
+	return fmt.Sprint(v.Interface())
+}
@@ -0,0 +1,247 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/query"
+)
+
+// Values accepted by the visualization and views sections, as by the flags of generate
+var (
+	layoutEngines = []string{"fdp", "dot", "neato"}
+	outputFormats = []string{"svg", "png", "pdf", "html", "json", "openlineage"}
+	colorModes    = []string{"type", "classification", "traffic"}
+	backends      = []string{"sqlite", "file"}
+	nodeShapes    = []string{"box", "ellipse", "diamond"}
+	nodeTypes     = []string{"topic", "subscription", "bigquery_table", "storage_bucket", "identity", "cloud_run_service", "cloud_function", "dataflow_job", "pubsub_lite_topic", "pubsub_lite_subscription"}
+)
+
+// ValidationError lists every invalid field of a config, each qualified by its YAML path
+type ValidationError struct {
+	Problems []string // e.g. "rate_limits.max_concurrent must be >= 1"
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid config:\n  " + strings.Join(e.Problems, "\n  ")
+}
+
+// validation collects the problems found by Validate
+type validation struct {
+	errors   []string
+	warnings []string
+}
+
+func (v *validation) errorf(format string, args ...interface{}) {
+	v.errors = append(v.errors, fmt.Sprintf(format, args...))
+}
+
+func (v *validation) warnf(format string, args ...interface{}) {
+	v.warnings = append(v.warnings, fmt.Sprintf(format, args...))
+}
+
+// oneOf checks that value, if set, is one of allowed
+func (v *validation) oneOf(field, value string, allowed []string) {
+	if value != "" && !slices.Contains(allowed, value) {
+		v.errorf("%s must be one of %s, not %q", field, strings.Join(allowed, ", "), value)
+	}
+}
+
+// patterns checks that every glob pattern has valid path.Match syntax
+func (v *validation) patterns(field string, patterns []string) {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			v.errorf("%s: invalid pattern %q", field, pattern)
+		}
+	}
+}
+
+func (v *validation) notNegative(field string, value int64) {
+	if value < 0 {
+		v.errorf("%s must be >= 0", field)
+	}
+}
+
+// Validate checks the config for values that can't work, such as a negative rate limit or an unknown
+// layout engine. It returns a *ValidationError listing every invalid field, and warnings about values
+// that work but are likely mistakes. Load fails on the errors, 'config validate' also prints the warnings.
+func (c *Config) Validate() (warnings []string, err error) {
+	v := &validation{}
+
+	v.patterns("projects_include", c.ProjectsInclude)
+	v.patterns("projects_exclude", c.ProjectsExclude)
+	for i, w := range c.ScanWindows {
+		validateScanWindow(v, fmt.Sprintf("scan_windows[%d]", i), w)
+	}
+	if !c.ReadOnly {
+		v.warnf("read_only is disabled, collectors registered as mutating are allowed to run")
+	}
+
+	v.notNegative("cache.ttl_hours", int64(c.Cache.TTLHours))
+	v.notNegative("cache.max_age_hours", int64(c.Cache.MaxAgeHours))
+	if c.Cache.MaxAgeHours > 0 && c.Cache.MaxAgeHours < c.Cache.TTLHours {
+		v.warnf("cache.max_age_hours (%d) is below cache.ttl_hours (%d)", c.Cache.MaxAgeHours, c.Cache.TTLHours)
+	}
+
+	v.oneOf("storage.backend", c.Storage.Backend, backends)
+	v.notNegative("storage.write_timeout", int64(c.Storage.WriteTimeout))
+	v.notNegative("storage.busy_timeout", int64(c.Storage.BusyTimeout))
+
+	v.oneOf("visualization.layout", c.Visualization.Layout, layoutEngines)
+	v.oneOf("visualization.output_format", c.Visualization.OutputFormat, outputFormats)
+	v.patterns("visualization.collapse_patterns", c.Visualization.CollapsePatterns)
+	v.notNegative("visualization.max_nodes", int64(c.Visualization.MaxNodes))
+	for i, rule := range c.Visualization.Styles {
+		field := fmt.Sprintf("visualization.styles[%d]", i)
+		v.oneOf(field+".type", rule.Type, nodeTypes)
+		v.patterns(field+".name", []string{rule.Name})
+		v.oneOf(field+".shape", rule.Shape, nodeShapes)
+		if rule.Color == "" && rule.Border == "" && rule.Shape == "" {
+			v.warnf("%s sets no color, border or shape and has no effect", field)
+		}
+	}
+
+	if c.RateLimits.RequestsPerSecond <= 0 {
+		v.errorf("rate_limits.requests_per_second must be > 0")
+	}
+	if c.RateLimits.MaxConcurrent < 1 {
+		v.errorf("rate_limits.max_concurrent must be >= 1")
+	}
+	// Zero restores the default batch size
+	v.notNegative("rate_limits.batch_size", int64(c.RateLimits.BatchSize))
+	v.notNegative("rate_limits.max_clients", int64(c.RateLimits.MaxClients))
+	v.notNegative("rate_limits.client_idle_timeout", int64(c.RateLimits.ClientIdleTimeout))
+	if c.RateLimits.MaxClients > 0 && c.RateLimits.MaxClients < c.RateLimits.MaxConcurrent {
+		v.warnf("rate_limits.max_clients is below rate_limits.max_concurrent (%d), clients in use are kept open regardless", c.RateLimits.MaxConcurrent)
+	}
+
+	v.notNegative("retries.max_attempts", int64(c.Retries.MaxAttempts))
+	if c.Retries.MaxAttempts <= 1 {
+		v.warnf("retries.max_attempts is %d, failed API calls are never retried", c.Retries.MaxAttempts)
+	}
+	v.notNegative("retries.initial_backoff", int64(c.Retries.InitialBackoff))
+	v.notNegative("retries.max_backoff", int64(c.Retries.MaxBackoff))
+	if c.Retries.MaxBackoff < c.Retries.InitialBackoff {
+		v.errorf("retries.max_backoff must be >= retries.initial_backoff (%s)", c.Retries.InitialBackoff)
+	}
+
+	v.notNegative("guardrails.max_topics_per_project", int64(c.Guardrails.MaxTopicsPerProject))
+	v.notNegative("guardrails.max_subscriptions_per_project", int64(c.Guardrails.MaxSubscriptionsPerProject))
+	if c.Guardrails.MaxGrowthPercent < 0 {
+		v.errorf("guardrails.max_growth_percent must be >= 0")
+	}
+
+	if c.CMDB.URL == "" && c.CMDB.Token != "" {
+		v.warnf("cmdb.token is set without cmdb.url, nothing is pushed to the CMDB")
+	}
+
+	v.notNegative("publishers.window", int64(c.Publishers.Window))
+	v.notNegative("publishers.max_entries", int64(c.Publishers.MaxEntries))
+	v.notNegative("metrics.window", int64(c.Metrics.Window))
+	v.notNegative("metrics.resolution", int64(c.Metrics.Resolution))
+	if c.Metrics.Resolution > c.Metrics.Window {
+		v.errorf("metrics.resolution must be <= metrics.window (%s)", c.Metrics.Window)
+	}
+	v.notNegative("metrics.max_backlog", c.Metrics.MaxBacklog)
+	v.notNegative("metrics.max_unacked_age", int64(c.Metrics.MaxUnackedAge))
+	if slices.Contains(c.Collectors, "pubsublite") && len(c.PubSubLite.Locations) == 0 {
+		v.warnf("the pubsublite collector is enabled without pubsub_lite.locations, no Lite resources are collected")
+	}
+
+	if len(c.Classification.Levels) > 0 {
+		v.oneOf("classification.sensitive_level", c.Classification.SensitiveLevel, c.Classification.Levels)
+		for _, topic := range sortedKeys(c.Classification.Topics) {
+			v.oneOf(fmt.Sprintf("classification.topics[%s]", topic), c.Classification.Topics[topic], c.Classification.Levels)
+		}
+	}
+
+	for _, team := range sortedKeys(c.Ownership.Teams) {
+		v.patterns(fmt.Sprintf("ownership.teams[%s]", team), c.Ownership.Teams[team])
+	}
+	v.patterns("residency.projects", sortedKeys(c.Residency.Projects))
+	if c.Ownership.LabelKey != "" && !slices.Contains(c.Collectors, "projects") {
+		v.warnf("ownership.label_key is set without the projects collector, project labels are never collected")
+	}
+	v.patterns("terraform.state_files", c.Terraform.StateFiles)
+
+	rules := make(map[string]bool, len(c.Rules))
+	for i, rule := range c.Rules {
+		validateRule(v, fmt.Sprintf("rules[%d]", i), rule)
+		if rules[rule.Name] {
+			v.errorf("rules[%d].name %q is used by another rule", i, rule.Name)
+		}
+		rules[rule.Name] = true
+	}
+
+	for _, name := range sortedKeys(c.Views) {
+		view := c.Views[name]
+		field := "views." + name
+		v.oneOf(field+".layout", view.Layout, layoutEngines)
+		v.oneOf(field+".format", view.Format, outputFormats)
+		v.oneOf(field+".color_by", view.ColorBy, colorModes)
+		v.oneOf(field+".level", view.Level, []string{"resource", "project"})
+		v.oneOf(field+".group_by", view.GroupBy, []string{"project", "team", "region"})
+		v.notNegative(field+".depth", int64(view.Depth))
+		if view.Depth > 0 && len(view.Focus) == 0 {
+			v.warnf("%s.depth is set without %s.focus and has no effect", field, field)
+		}
+	}
+
+	if len(v.errors) > 0 {
+		return v.warnings, &ValidationError{Problems: v.errors}
+	}
+	return v.warnings, nil
+}
+
+func validateRule(v *validation, field string, r Rule) {
+	if r.Name == "" {
+		v.errorf("%s.name is required", field)
+	}
+	v.oneOf(field+".severity", r.Severity, []string{"warning", "error"})
+	if r.Where != "" {
+		if _, err := query.Parse(r.Where); err != nil {
+			v.errorf("%s.where: %v", field, err)
+		}
+	}
+	if r.Require == "" {
+		v.errorf("%s.require is required", field)
+	} else if _, err := query.Parse(r.Require); err != nil {
+		v.errorf("%s.require: %v", field, err)
+	}
+}
+
+func validateScanWindow(v *validation, field string, w ScanWindow) {
+	if len(w.Projects) == 0 {
+		v.warnf("%s.projects is empty, the window applies to no project", field)
+	}
+	v.patterns(field+".projects", w.Projects)
+	if _, err := clockMinutes(w.Start); err != nil {
+		v.errorf("%s.start must be HH:MM, not %q", field, w.Start)
+	}
+	if _, err := clockMinutes(w.End); err != nil {
+		v.errorf("%s.end must be HH:MM, not %q", field, w.End)
+	}
+	for _, day := range w.Days {
+		if _, ok := weekdays[strings.ToLower(day)]; !ok {
+			v.errorf("%s.days must be mon, tue, wed, thu, fri, sat or sun, not %q", field, day)
+		}
+	}
+	if w.Timezone != "" {
+		if _, err := time.LoadLocation(w.Timezone); err != nil {
+			v.errorf("%s.timezone: unknown time zone %q", field, w.Timezone)
+		}
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
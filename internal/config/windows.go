@@ -0,0 +1,136 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	// Scan window time zones resolve in containers without a zoneinfo database
+	_ "time/tzdata"
+)
+
+// ScanWindow restricts when the projects matching its patterns may be scanned,
+// e.g. production projects only off-peak. A window whose end is before its
+// start spans midnight.
+type ScanWindow struct {
+	Projects []string `yaml:"projects"` // glob patterns, path.Match syntax
+	Start    string   `yaml:"start"`    // "HH:MM"
+	End      string   `yaml:"end"`      // "HH:MM", exclusive
+	Days     []string `yaml:"days"`     // "mon" to "sun" on which the window starts, empty is every day
+	Timezone string   `yaml:"timezone"` // IANA name, empty is the local time zone
+}
+
+// Matches reports whether the window applies to project
+func (w ScanWindow) Matches(project string) (bool, error) {
+	for _, pattern := range w.Projects {
+		ok, err := path.Match(pattern, project)
+		if err != nil {
+			return false, fmt.Errorf("invalid scan window pattern %q: %w", pattern, err)
+		}
+		if ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Contains reports whether t falls inside the window
+func (w ScanWindow) Contains(t time.Time) (bool, error) {
+	start, err := clockMinutes(w.Start)
+	if err != nil {
+		return false, err
+	}
+	end, err := clockMinutes(w.End)
+	if err != nil {
+		return false, err
+	}
+	if w.Timezone != "" {
+		loc, err := time.LoadLocation(w.Timezone)
+		if err != nil {
+			return false, fmt.Errorf("invalid scan window timezone %q: %w", w.Timezone, err)
+		}
+		t = t.In(loc)
+	}
+
+	now := t.Hour()*60 + t.Minute()
+	day := t.Weekday()
+	switch {
+	case start < end:
+		if now < start || now >= end {
+			return false, nil
+		}
+	case start > end:
+		// Past midnight the window started the day before
+		if now >= end && now < start {
+			return false, nil
+		}
+		if now < end {
+			day = (day + 6) % 7
+		}
+	}
+	return w.onDay(day)
+}
+
+// onDay reports whether the window starts on day
+func (w ScanWindow) onDay(day time.Weekday) (bool, error) {
+	if len(w.Days) == 0 {
+		return true, nil
+	}
+	for _, name := range w.Days {
+		d, ok := weekdays[strings.ToLower(name)]
+		if !ok {
+			return false, fmt.Errorf("invalid scan window day %q, expected mon, tue, wed, thu, fri, sat or sun", name)
+		}
+		if d == day {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// clockMinutes parses "HH:MM" into minutes since midnight
+func clockMinutes(clock string) (int, error) {
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		return 0, fmt.Errorf("invalid scan window time %q, expected HH:MM", clock)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// SplitByScanWindow returns the projects that may be scanned at now, and those deferred
+// because every scan window matching them is closed. Projects without a window are always allowed.
+func (c *Config) SplitByScanWindow(projects []string, now time.Time) (allowed, deferred []string, err error) {
+	for _, project := range projects {
+		restricted, open := false, false
+		for _, w := range c.ScanWindows {
+			matches, err := w.Matches(project)
+			if err != nil {
+				return nil, nil, err
+			}
+			if !matches {
+				continue
+			}
+			restricted = true
+			inside, err := w.Contains(now)
+			if err != nil {
+				return nil, nil, err
+			}
+			if inside {
+				open = true
+				break
+			}
+		}
+		if restricted && !open {
+			deferred = append(deferred, project)
+			continue
+		}
+		allowed = append(allowed, project)
+	}
+	return allowed, deferred, nil
+}
@@ -0,0 +1,172 @@
+// Package demo ships a small embedded Pub/Sub inventory that the collector can
+// scan without any GCP credentials, so every feature can be tried out offline.
+package demo
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+
+	"cloud.google.com/go/iam/apiv1/iampb"
+	"cloud.google.com/go/pubsub/v2/apiv1/pubsubpb"
+	"github.com/NissesSenap/gcp-visualizer/internal/collector"
+	"github.com/NissesSenap/gcp-visualizer/internal/storage"
+	"google.golang.org/api/iterator"
+)
+
+//go:embed inventory.json
+var inventoryJSON []byte
+
+// inventory is the format of inventory.json
+type inventory struct {
+	Projects []struct {
+		ID     string `json:"id"`
+		Topics []struct {
+			Name       string            `json:"name"`
+			Labels     map[string]string `json:"labels"`
+			Publishers []string          `json:"publishers"`
+		} `json:"topics"`
+		Subscriptions []struct {
+			Name               string            `json:"name"`
+			Topic              string            `json:"topic"`
+			Labels             map[string]string `json:"labels"`
+			DeadLetterTopic    string            `json:"dead_letter_topic"`
+			BigQueryTable      string            `json:"bigquery_table"`
+			CloudStorageBucket string            `json:"cloud_storage_bucket"`
+			Subscribers        []string          `json:"subscribers"`
+		} `json:"subscriptions"`
+	} `json:"projects"`
+}
+
+// project is the demo inventory of one project, as the Pub/Sub API would list it
+type project struct {
+	id            string
+	topics        []*pubsubpb.Topic
+	subscriptions []*pubsubpb.Subscription
+	policies      map[string]*iampb.Policy // keyed by topic or subscription full resource name
+}
+
+// projects holds the parsed demo inventory in file order
+var projects = mustParse(inventoryJSON)
+
+func mustParse(data []byte) []*project {
+	var inv inventory
+	if err := json.Unmarshal(data, &inv); err != nil {
+		panic(fmt.Sprintf("demo: invalid inventory.json: %v", err))
+	}
+
+	var parsed []*project
+	for _, p := range inv.Projects {
+		proj := &project{id: p.ID, policies: make(map[string]*iampb.Policy)}
+		for _, t := range p.Topics {
+			topic := &pubsubpb.Topic{
+				Name:   fmt.Sprintf("projects/%s/topics/%s", p.ID, t.Name),
+				Labels: t.Labels,
+			}
+			if len(t.Publishers) > 0 {
+				proj.policies[topic.Name] = &iampb.Policy{Bindings: []*iampb.Binding{{
+					Role:    "roles/pubsub.publisher",
+					Members: t.Publishers,
+				}}}
+			}
+			proj.topics = append(proj.topics, topic)
+		}
+		for _, s := range p.Subscriptions {
+			sub := &pubsubpb.Subscription{
+				Name:   fmt.Sprintf("projects/%s/subscriptions/%s", p.ID, s.Name),
+				Topic:  s.Topic,
+				Labels: s.Labels,
+			}
+			if s.DeadLetterTopic != "" {
+				sub.DeadLetterPolicy = &pubsubpb.DeadLetterPolicy{DeadLetterTopic: s.DeadLetterTopic}
+			}
+			if s.BigQueryTable != "" {
+				sub.BigqueryConfig = &pubsubpb.BigQueryConfig{Table: s.BigQueryTable, State: pubsubpb.BigQueryConfig_ACTIVE}
+			}
+			if s.CloudStorageBucket != "" {
+				sub.CloudStorageConfig = &pubsubpb.CloudStorageConfig{Bucket: s.CloudStorageBucket, State: pubsubpb.CloudStorageConfig_ACTIVE}
+			}
+			if len(s.Subscribers) > 0 {
+				proj.policies[sub.Name] = &iampb.Policy{Bindings: []*iampb.Binding{{
+					Role:    "roles/pubsub.subscriber",
+					Members: s.Subscribers,
+				}}}
+			}
+			proj.subscriptions = append(proj.subscriptions, sub)
+		}
+		parsed = append(parsed, proj)
+	}
+	return parsed
+}
+
+// Projects returns the IDs of the demo projects
+func Projects() []string {
+	ids := make([]string, 0, len(projects))
+	for _, p := range projects {
+		ids = append(ids, p.id)
+	}
+	return ids
+}
+
+// NewAPI is a collector.APIFactory serving the demo inventory of a project
+func NewAPI(ctx context.Context, projectID string) (collector.PubSubAPI, error) {
+	for _, p := range projects {
+		if p.id == projectID {
+			return &api{project: p}, nil
+		}
+	}
+	return nil, fmt.Errorf("%s is not a demo project, the demo projects are %v", projectID, Projects())
+}
+
+// Load scans the demo inventory into store
+func Load(ctx context.Context, store storage.Store) error {
+	coll := collector.NewWithAPI(store, 1000, NewAPI)
+	defer func() { _ = coll.Close() }()
+
+	for _, id := range Projects() {
+		if err := coll.CollectProject(ctx, id); err != nil {
+			return fmt.Errorf("failed to load demo project %s: %w", id, err)
+		}
+	}
+	return nil
+}
+
+// api serves one demo project through the collector's PubSubAPI
+type api struct {
+	project *project
+}
+
+// sliceIterator returns its items, then iterator.Done
+type sliceIterator[T any] struct {
+	items []T
+}
+
+func (it *sliceIterator[T]) Next() (T, error) {
+	var zero T
+	if len(it.items) == 0 {
+		return zero, iterator.Done
+	}
+	item := it.items[0]
+	it.items = it.items[1:]
+	return item, nil
+}
+
+func (a *api) ListTopics(ctx context.Context, req *pubsubpb.ListTopicsRequest) collector.TopicIterator {
+	return &sliceIterator[*pubsubpb.Topic]{items: a.project.topics}
+}
+
+func (a *api) ListSubscriptions(ctx context.Context, req *pubsubpb.ListSubscriptionsRequest) collector.SubscriptionIterator {
+	return &sliceIterator[*pubsubpb.Subscription]{items: a.project.subscriptions}
+}
+
+func (a *api) GetIamPolicy(ctx context.Context, req *iampb.GetIamPolicyRequest) (*iampb.Policy, error) {
+	if policy, ok := a.project.policies[req.Resource]; ok {
+		return policy, nil
+	}
+	return &iampb.Policy{}, nil
+}
+
+func (a *api) Close() error {
+	return nil
+}
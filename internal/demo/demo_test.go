@@ -0,0 +1,49 @@
+package demo
+
+import (
+	"context"
+	"testing"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/graph"
+	"github.com/NissesSenap/gcp-visualizer/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad(t *testing.T) {
+	store, err := storage.NewSQLite(":memory:")
+	require.NoError(t, err)
+	defer func() { _ = store.Close() }()
+	ctx := context.Background()
+
+	require.NoError(t, Load(ctx, store))
+
+	cached, err := store.GetAllProjects(ctx)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, Projects(), cached)
+
+	g, err := graph.NewBuilder(store).Build(ctx, nil)
+	require.NoError(t, err)
+
+	// The demo should show off every kind of node and edge
+	nodeTypes := make(map[graph.NodeType]bool)
+	for _, n := range g.Nodes {
+		nodeTypes[n.Type] = true
+	}
+	edgeTypes := make(map[graph.EdgeType]bool)
+	for _, e := range g.Edges {
+		edgeTypes[e.Type] = true
+	}
+	for _, nt := range []graph.NodeType{graph.NodeTypeTopic, graph.NodeTypeSubscription, graph.NodeTypeBigQueryTable, graph.NodeTypeStorageBucket, graph.NodeTypeIdentity} {
+		assert.True(t, nodeTypes[nt], "missing %s nodes", nt)
+	}
+	for _, et := range []graph.EdgeType{graph.EdgeTypeSubscribes, graph.EdgeTypeCrossProject, graph.EdgeTypeDelivers, graph.EdgeTypeConsumes, graph.EdgeTypePublishes} {
+		assert.True(t, edgeTypes[et], "missing %s edges", et)
+	}
+}
+
+func TestNewAPI_UnknownProject(t *testing.T) {
+	_, err := NewAPI(context.Background(), "my-project")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "not a demo project")
+}
@@ -0,0 +1,246 @@
+package graph
+
+import (
+	"slices"
+	"sort"
+	"strings"
+)
+
+// TopicStats describes how widely a topic is consumed
+type TopicStats struct {
+	NodeID             string
+	Label              string
+	Project            string
+	Subscribers        int
+	SubscriberProjects []string // Distinct projects with a subscription, sorted
+	CrossProjectReach  int      // Number of subscriber projects other than the topic's own
+}
+
+// Hotspots ranks topics by subscriber count, then cross-project reach.
+// Ties are broken by node ID so the order is deterministic.
+func Hotspots(g *Graph) []TopicStats {
+	stats := make(map[string]*TopicStats)
+	projects := make(map[string]map[string]bool)
+
+	for id, node := range g.Nodes {
+		if node.Type != NodeTypeTopic {
+			continue
+		}
+		stats[id] = &TopicStats{NodeID: id, Label: node.Label, Project: node.Project}
+		projects[id] = make(map[string]bool)
+	}
+
+	for _, edge := range g.Edges {
+		if edge.Type != EdgeTypeSubscribes && edge.Type != EdgeTypeCrossProject {
+			continue
+		}
+		s, ok := stats[edge.To]
+		if !ok {
+			continue
+		}
+		s.Subscribers++
+		if sub, ok := g.Nodes[edge.From]; ok {
+			projects[edge.To][sub.Project] = true
+		}
+	}
+
+	result := make([]TopicStats, 0, len(stats))
+	for id, s := range stats {
+		for p := range projects[id] {
+			s.SubscriberProjects = append(s.SubscriberProjects, p)
+			if p != s.Project {
+				s.CrossProjectReach++
+			}
+		}
+		sort.Strings(s.SubscriberProjects)
+		result = append(result, *s)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Subscribers != result[j].Subscribers {
+			return result[i].Subscribers > result[j].Subscribers
+		}
+		if result[i].CrossProjectReach != result[j].CrossProjectReach {
+			return result[i].CrossProjectReach > result[j].CrossProjectReach
+		}
+		return result[i].NodeID < result[j].NodeID
+	})
+	return result
+}
+
+// Neighborhood returns the subgraph containing the seed nodes and every node
+// reachable from them within depth hops, following edges in either direction.
+func Neighborhood(g *Graph, seeds []string, depth int) *Graph {
+	adjacent := make(map[string][]string)
+	for _, edge := range g.Edges {
+		adjacent[edge.From] = append(adjacent[edge.From], edge.To)
+		adjacent[edge.To] = append(adjacent[edge.To], edge.From)
+	}
+
+	keep := make(map[string]bool)
+	frontier := make([]string, 0, len(seeds))
+	for _, id := range seeds {
+		if _, ok := g.Nodes[id]; ok && !keep[id] {
+			keep[id] = true
+			frontier = append(frontier, id)
+		}
+	}
+
+	for d := 0; d < depth && len(frontier) > 0; d++ {
+		var next []string
+		for _, id := range frontier {
+			for _, n := range adjacent[id] {
+				if !keep[n] {
+					keep[n] = true
+					next = append(next, n)
+				}
+			}
+		}
+		frontier = next
+	}
+
+	return g.Subgraph(keep)
+}
+
+// Subgraph returns a new graph with only the given nodes and the edges between them
+func (g *Graph) Subgraph(keep map[string]bool) *Graph {
+	sub := New()
+
+	// Add nodes in sorted order so cluster membership order is deterministic
+	ids := make([]string, 0, len(keep))
+	for id := range keep {
+		if _, ok := g.Nodes[id]; ok {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		sub.AddNode(g.Nodes[id])
+	}
+	// Keep the labels of the clusters, which may be annotated
+	for project, cluster := range sub.Clusters {
+		cluster.Label = g.Clusters[project].Label
+	}
+
+	for _, edge := range g.Edges {
+		if keep[edge.From] && keep[edge.To] {
+			sub.AddEdge(edge)
+		}
+	}
+	return sub
+}
+
+// WithoutInferred returns a new graph without the inferred edges, and without the
+// identities that are left with no edges once they are removed
+func WithoutInferred(g *Graph) *Graph {
+	keep := make(map[string]bool, len(g.Nodes))
+	for id, node := range g.Nodes {
+		if node.Type != NodeTypeIdentity {
+			keep[id] = true
+		}
+	}
+	for _, edge := range g.Edges {
+		if !edge.Inferred {
+			keep[edge.From] = true
+			keep[edge.To] = true
+		}
+	}
+
+	sub := g.Subgraph(keep)
+	sub.Edges = slices.DeleteFunc(sub.Edges, func(edge *Edge) bool { return edge.Inferred })
+	return sub
+}
+
+// AnnotateRetryPolicies labels every subscribes and cross-project edge with the
+// backoff range of the subscription's retry policy, or "no retry policy"
+func AnnotateRetryPolicies(g *Graph) {
+	for _, edge := range g.Edges {
+		if edge.Type != EdgeTypeSubscribes && edge.Type != EdgeTypeCrossProject {
+			continue
+		}
+		sub, ok := g.Nodes[edge.From]
+		if !ok {
+			continue
+		}
+		minimum, ok := sub.Metadata[RetryMinimumBackoffKey]
+		if !ok {
+			edge.Label = "no retry policy"
+			continue
+		}
+		edge.Label = "retry " + minimum + "-" + sub.Metadata[RetryMaximumBackoffKey]
+	}
+}
+
+// AnnotateSubscriptionAttributes labels every subscribes and cross-project edge with the delivery
+// of the subscription (push, pull, bigquery or cloud storage), its ack deadline and whether it has
+// a filter or a dead-letter topic, e.g. "push, ack 30s, filtered, DLQ". Labels already on the
+// edges, such as the retry policy, are kept in front.
+func AnnotateSubscriptionAttributes(g *Graph) {
+	// Export subscriptions deliver to a sink node instead of being pushed to or pulled from
+	exports := make(map[string]string)
+	for _, edge := range g.Edges {
+		if edge.Type != EdgeTypeDelivers {
+			continue
+		}
+		switch g.Nodes[edge.To].Type {
+		case NodeTypeBigQueryTable:
+			exports[edge.From] = "bigquery"
+		case NodeTypeStorageBucket:
+			exports[edge.From] = "cloud storage"
+		}
+	}
+
+	for _, edge := range g.Edges {
+		if edge.Type != EdgeTypeSubscribes && edge.Type != EdgeTypeCrossProject {
+			continue
+		}
+		sub, ok := g.Nodes[edge.From]
+		if !ok || sub.Type != NodeTypeSubscription {
+			continue
+		}
+
+		var attrs []string
+		if edge.Label != "" {
+			attrs = append(attrs, edge.Label)
+		}
+		switch {
+		case sub.Metadata[PushEndpointKey] != "":
+			attrs = append(attrs, "push")
+		case exports[sub.ID] != "":
+			attrs = append(attrs, exports[sub.ID])
+		default:
+			attrs = append(attrs, "pull")
+		}
+		if deadline := sub.Metadata[AckDeadlineKey]; deadline != "" {
+			attrs = append(attrs, "ack "+deadline)
+		}
+		if sub.Metadata[FilterKey] != "" {
+			attrs = append(attrs, "filtered")
+		}
+		if sub.Metadata[DeadLetterTopicKey] != "" {
+			attrs = append(attrs, "DLQ")
+		}
+		edge.Label = strings.Join(attrs, ", ")
+	}
+}
+
+// AnnotateSubscriptionFilters labels the subscribes and cross-project edges of filtered subscriptions
+// with their filter expression, e.g. `attributes.kind = "email"`, so the messages each subscriber of a
+// topic receives can be told apart. Labels already on the edges are kept in front.
+func AnnotateSubscriptionFilters(g *Graph) {
+	for _, edge := range g.Edges {
+		if edge.Type != EdgeTypeSubscribes && edge.Type != EdgeTypeCrossProject {
+			continue
+		}
+		sub, ok := g.Nodes[edge.From]
+		if !ok || sub.Type != NodeTypeSubscription || sub.Metadata[FilterKey] == "" {
+			continue
+		}
+		filter := "filter: " + sub.Metadata[FilterKey]
+		if edge.Label == "" {
+			edge.Label = filter
+		} else {
+			edge.Label += ", " + filter
+		}
+	}
+}
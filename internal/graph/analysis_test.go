@@ -0,0 +1,145 @@
+package graph
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// meshGraph returns a graph with one widely consumed topic and one quiet topic
+func meshGraph() *Graph {
+	g := New()
+	g.AddNode(&Node{ID: "topic_a_orders", Label: "orders", Type: NodeTypeTopic, Project: "a"})
+	g.AddNode(&Node{ID: "topic_a_quiet", Label: "quiet", Type: NodeTypeTopic, Project: "a"})
+
+	subs := []struct{ id, project string }{
+		{"sub_a_local", "a"},
+		{"sub_b_billing", "b"},
+		{"sub_c_email", "c"},
+	}
+	for _, s := range subs {
+		g.AddNode(&Node{ID: s.id, Label: s.id, Type: NodeTypeSubscription, Project: s.project})
+		edgeType := EdgeTypeSubscribes
+		if s.project != "a" {
+			edgeType = EdgeTypeCrossProject
+		}
+		g.AddEdge(&Edge{From: s.id, To: "topic_a_orders", Type: edgeType})
+	}
+
+	g.AddNode(&Node{ID: "gcs_archive", Label: "gs://archive", Type: NodeTypeStorageBucket})
+	g.AddEdge(&Edge{From: "sub_c_email", To: "gcs_archive", Type: EdgeTypeDelivers})
+
+	g.AddNode(&Node{ID: "sub_a_quiet", Label: "quiet-sub", Type: NodeTypeSubscription, Project: "a"})
+	g.AddEdge(&Edge{From: "sub_a_quiet", To: "topic_a_quiet", Type: EdgeTypeSubscribes})
+	return g
+}
+
+func TestHotspots(t *testing.T) {
+	stats := Hotspots(meshGraph())
+	require.Len(t, stats, 2)
+
+	top := stats[0]
+	assert.Equal(t, "topic_a_orders", top.NodeID)
+	assert.Equal(t, 3, top.Subscribers)
+	assert.Equal(t, []string{"a", "b", "c"}, top.SubscriberProjects)
+	assert.Equal(t, 2, top.CrossProjectReach)
+
+	assert.Equal(t, "topic_a_quiet", stats[1].NodeID)
+	assert.Equal(t, 1, stats[1].Subscribers)
+	assert.Equal(t, 0, stats[1].CrossProjectReach)
+}
+
+func TestNeighborhood(t *testing.T) {
+	g := meshGraph()
+
+	direct := Neighborhood(g, []string{"topic_a_orders"}, 1)
+	assert.Len(t, direct.Nodes, 4)
+	assert.NotContains(t, direct.Nodes, "gcs_archive")
+	assert.Len(t, direct.Edges, 3)
+
+	withSinks := Neighborhood(g, []string{"topic_a_orders"}, 2)
+	assert.Contains(t, withSinks.Nodes, "gcs_archive")
+	assert.NotContains(t, withSinks.Nodes, "topic_a_quiet")
+	assert.Len(t, withSinks.Edges, 4)
+
+	assert.Empty(t, Neighborhood(g, []string{"missing"}, 2).Nodes)
+}
+
+func TestWithoutInferred(t *testing.T) {
+	g := meshGraph()
+	g.AddNode(&Node{ID: "identity_user:dev@example.com", Type: NodeTypeIdentity})
+	g.AddNode(&Node{ID: "identity_serviceAccount:app@a.iam.gserviceaccount.com", Type: NodeTypeIdentity})
+	g.AddEdge(&Edge{From: "identity_user:dev@example.com", To: "topic_a_orders", Type: EdgeTypePublishes, Inferred: true})
+	g.AddEdge(&Edge{From: "identity_serviceAccount:app@a.iam.gserviceaccount.com", To: "topic_a_orders", Type: EdgeTypePublishes})
+	g.AddEdge(&Edge{From: "identity_serviceAccount:app@a.iam.gserviceaccount.com", To: "sub_a_local", Type: EdgeTypeConsumes, Inferred: true})
+
+	observed := WithoutInferred(g)
+	assert.Len(t, observed.Nodes, len(g.Nodes)-1)
+	assert.NotContains(t, observed.Nodes, "identity_user:dev@example.com", "identities only connected by inferred edges are dropped")
+	assert.Len(t, observed.Edges, len(g.Edges)-2)
+	for _, e := range observed.Edges {
+		assert.False(t, e.Inferred)
+	}
+	assert.Len(t, g.Edges, 8, "the graph itself is left as is")
+}
+
+func TestAnnotateRetryPolicies(t *testing.T) {
+	g := meshGraph()
+	g.Nodes["sub_b_billing"].Metadata = map[string]string{
+		RetryMinimumBackoffKey: "10s",
+		RetryMaximumBackoffKey: "10m0s",
+	}
+
+	AnnotateRetryPolicies(g)
+
+	labels := make(map[string]string)
+	for _, edge := range g.Edges {
+		labels[edge.From+">"+edge.To] = edge.Label
+	}
+	assert.Equal(t, "retry 10s-10m0s", labels["sub_b_billing>topic_a_orders"])
+	assert.Equal(t, "no retry policy", labels["sub_a_local>topic_a_orders"])
+	assert.Empty(t, labels["sub_c_email>gcs_archive"], "sink edges keep their label")
+}
+
+func TestAnnotateSubscriptionAttributes(t *testing.T) {
+	g := meshGraph()
+	g.Nodes["sub_a_local"].Metadata = map[string]string{AckDeadlineKey: "10s"}
+	g.Nodes["sub_b_billing"].Metadata = map[string]string{
+		PushEndpointKey:        "https://billing.example.com/push",
+		AckDeadlineKey:         "1m0s",
+		FilterKey:              `attributes.region = "eu"`,
+		DeadLetterTopicKey:     "projects/b/topics/billing-dlq",
+		RetryMinimumBackoffKey: "10s",
+		RetryMaximumBackoffKey: "10m0s",
+	}
+
+	AnnotateRetryPolicies(g)
+	AnnotateSubscriptionAttributes(g)
+
+	labels := make(map[string]string)
+	for _, edge := range g.Edges {
+		labels[edge.From+">"+edge.To] = edge.Label
+	}
+	assert.Equal(t, "no retry policy, pull, ack 10s", labels["sub_a_local>topic_a_orders"])
+	assert.Equal(t, "retry 10s-10m0s, push, ack 1m0s, filtered, DLQ", labels["sub_b_billing>topic_a_orders"])
+	assert.Equal(t, "no retry policy, cloud storage", labels["sub_c_email>topic_a_orders"])
+	assert.Empty(t, labels["sub_c_email>gcs_archive"], "sink edges keep their label")
+}
+
+func TestAnnotateSubscriptionFilters(t *testing.T) {
+	g := meshGraph()
+	g.Edges[0].Label = "subscribes"
+	g.Nodes["sub_a_local"].Metadata = map[string]string{FilterKey: `attributes.kind = "email"`}
+	g.Nodes["sub_b_billing"].Metadata = map[string]string{FilterKey: `hasPrefix(attributes.type, "invoice")`}
+
+	AnnotateSubscriptionFilters(g)
+
+	labels := make(map[string]string)
+	for _, edge := range g.Edges {
+		labels[edge.From+">"+edge.To] = edge.Label
+	}
+	assert.Equal(t, `subscribes, filter: attributes.kind = "email"`, labels["sub_a_local>topic_a_orders"])
+	assert.Equal(t, `filter: hasPrefix(attributes.type, "invoice")`, labels["sub_b_billing>topic_a_orders"])
+	assert.Empty(t, labels["sub_c_email>topic_a_orders"], "unfiltered subscriptions keep their label")
+}
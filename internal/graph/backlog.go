@@ -0,0 +1,122 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/storage"
+)
+
+// Reasons a subscription is over the backlog thresholds
+const (
+	BacklogTooLarge = "backlog too large" // more undelivered messages than BacklogThresholds.MaxMessages
+	BacklogTooOld   = "unacked too long"  // oldest unacked message older than BacklogThresholds.MaxUnackedAge
+)
+
+// BacklogAlertKey is the node metadata key holding the reasons a subscription is over the backlog thresholds
+const BacklogAlertKey = "backlog_alert"
+
+// backlogBorder outlines subscriptions over the backlog thresholds
+const backlogBorder = "red"
+
+// BacklogThresholds are the limits a healthy subscription stays within, zero disables a limit
+type BacklogThresholds struct {
+	MaxMessages   int64
+	MaxUnackedAge time.Duration
+}
+
+// Backlog is a subscription over the backlog thresholds, measured by the "metrics" collector
+type Backlog struct {
+	FullResourceName string
+	ProjectID        string
+	Name             string
+	Topic            string // full resource name of the topic
+	Messages         int64  // highest count of undelivered messages in the metrics window
+	OldestUnackedAge time.Duration
+	Reasons          []string // BacklogTooLarge and/or BacklogTooOld
+}
+
+// FindBacklogs returns the cached subscriptions in the given projects that are over t,
+// the oldest unacked messages first, then the largest backlogs. An empty projects slice
+// includes every cached project. Subscriptions without metrics are left out.
+func FindBacklogs(ctx context.Context, store storage.Store, projects []string, t BacklogThresholds) ([]Backlog, error) {
+	subs, err := store.GetAllSubscriptions(ctx, projects)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get subscriptions: %w", err)
+	}
+	metrics, err := store.GetMetrics(ctx, projects)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get metrics: %w", err)
+	}
+
+	byName := make(map[string]*Backlog, len(subs))
+	for _, sub := range subs {
+		byName[sub.FullResourceName] = &Backlog{
+			FullResourceName: sub.FullResourceName,
+			ProjectID:        sub.ProjectID,
+			Name:             sub.Name,
+			Topic:            sub.TopicFullResourceName,
+		}
+	}
+	for _, metric := range metrics {
+		b, ok := byName[metric.FullResourceName]
+		if !ok {
+			continue
+		}
+		switch metric.Metric {
+		case storage.MetricSubscriptionBacklog:
+			b.Messages = int64(metric.Value)
+		case storage.MetricSubscriptionUnackedAge:
+			b.OldestUnackedAge = time.Duration(metric.Value * float64(time.Second))
+		}
+	}
+
+	var backlogs []Backlog
+	for _, b := range byName {
+		if t.MaxMessages > 0 && b.Messages > t.MaxMessages {
+			b.Reasons = append(b.Reasons, BacklogTooLarge)
+		}
+		if t.MaxUnackedAge > 0 && b.OldestUnackedAge > t.MaxUnackedAge {
+			b.Reasons = append(b.Reasons, BacklogTooOld)
+		}
+		if len(b.Reasons) > 0 {
+			backlogs = append(backlogs, *b)
+		}
+	}
+
+	sort.Slice(backlogs, func(i, j int) bool {
+		if backlogs[i].OldestUnackedAge != backlogs[j].OldestUnackedAge {
+			return backlogs[i].OldestUnackedAge > backlogs[j].OldestUnackedAge
+		}
+		if backlogs[i].Messages != backlogs[j].Messages {
+			return backlogs[i].Messages > backlogs[j].Messages
+		}
+		return backlogs[i].FullResourceName < backlogs[j].FullResourceName
+	})
+	return backlogs, nil
+}
+
+// MarkBacklogs records the reasons of every backlog in the metadata of its subscription
+// node and outlines it in red
+func MarkBacklogs(g *Graph, backlogs []Backlog) {
+	nodes := make(map[string]*Node, len(g.Nodes))
+	for _, node := range g.Nodes {
+		if node.Type == NodeTypeSubscription {
+			nodes[node.Metadata["full_resource_name"]] = node
+		}
+	}
+	for _, b := range backlogs {
+		node, ok := nodes[b.FullResourceName]
+		if !ok {
+			continue
+		}
+		if node.Metadata == nil {
+			node.Metadata = make(map[string]string)
+		}
+		node.Metadata[BacklogAlertKey] = strings.Join(b.Reasons, ", ")
+		node.Border = backlogBorder
+	}
+}
@@ -0,0 +1,66 @@
+package graph
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindBacklogs(t *testing.T) {
+	store := setupTestStore(t)
+	ctx := context.Background()
+
+	for _, sub := range []*storage.Subscription{
+		{Name: "stuck", ProjectID: "project-a", TopicFullResourceName: "projects/project-a/topics/orders", FullResourceName: "projects/project-a/subscriptions/stuck"},
+		{Name: "busy", ProjectID: "project-a", TopicFullResourceName: "projects/project-a/topics/orders", FullResourceName: "projects/project-a/subscriptions/busy"},
+		{Name: "healthy", ProjectID: "project-a", TopicFullResourceName: "projects/project-a/topics/orders", FullResourceName: "projects/project-a/subscriptions/healthy"},
+		{Name: "unmeasured", ProjectID: "project-b", TopicFullResourceName: "projects/project-a/topics/orders", FullResourceName: "projects/project-b/subscriptions/unmeasured"},
+	} {
+		require.NoError(t, store.SaveSubscription(ctx, sub))
+	}
+	metric := func(sub, metric string, value float64) *storage.ResourceMetric {
+		return &storage.ResourceMetric{FullResourceName: "projects/project-a/subscriptions/" + sub, ProjectID: "project-a", Metric: metric, Value: value, Window: time.Hour}
+	}
+	require.NoError(t, store.SaveMetrics(ctx, []*storage.ResourceMetric{
+		metric("stuck", storage.MetricSubscriptionBacklog, 50),
+		metric("stuck", storage.MetricSubscriptionUnackedAge, 7200),
+		metric("busy", storage.MetricSubscriptionBacklog, 25000),
+		metric("busy", storage.MetricSubscriptionUnackedAge, 120),
+		metric("healthy", storage.MetricSubscriptionBacklog, 3),
+		metric("healthy", storage.MetricSubscriptionUnackedAge, 4),
+		// Deleted since the metrics were read
+		metric("gone", storage.MetricSubscriptionBacklog, 90000),
+	}))
+
+	thresholds := BacklogThresholds{MaxMessages: 10000, MaxUnackedAge: time.Hour}
+	backlogs, err := FindBacklogs(ctx, store, nil, thresholds)
+	require.NoError(t, err)
+	assert.Equal(t, []Backlog{
+		{FullResourceName: "projects/project-a/subscriptions/stuck", ProjectID: "project-a", Name: "stuck", Topic: "projects/project-a/topics/orders", Messages: 50, OldestUnackedAge: 2 * time.Hour, Reasons: []string{BacklogTooOld}},
+		{FullResourceName: "projects/project-a/subscriptions/busy", ProjectID: "project-a", Name: "busy", Topic: "projects/project-a/topics/orders", Messages: 25000, OldestUnackedAge: 2 * time.Minute, Reasons: []string{BacklogTooLarge}},
+	}, backlogs)
+
+	// A zero threshold is disabled
+	backlogs, err = FindBacklogs(ctx, store, []string{"project-a"}, BacklogThresholds{MaxMessages: 10})
+	require.NoError(t, err)
+	require.Len(t, backlogs, 2)
+	assert.Equal(t, "stuck", backlogs[0].Name)
+	assert.Equal(t, []string{BacklogTooLarge}, backlogs[0].Reasons)
+
+	g, err := NewBuilder(store).Build(ctx, nil)
+	require.NoError(t, err)
+	backlogs, err = FindBacklogs(ctx, store, nil, thresholds)
+	require.NoError(t, err)
+	MarkBacklogs(g, backlogs)
+
+	stuck := g.Nodes["sub_project-a_stuck"]
+	assert.Equal(t, "red", stuck.Border)
+	assert.Equal(t, BacklogTooOld, stuck.Metadata[BacklogAlertKey])
+	assert.Equal(t, "7200", stuck.Metadata[UnackedAgeKey])
+	assert.Empty(t, g.Nodes["sub_project-a_healthy"].Border)
+	assert.NotContains(t, g.Nodes["sub_project-a_healthy"].Metadata, BacklogAlertKey)
+}
@@ -1,4 +1,611 @@
 package graph
 
-// Graph package placeholder
-// TODO: Implement graph builder
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/storage"
+)
+
+// Builder builds graphs from cached storage data
+type Builder struct {
+	storage storage.Store
+}
+
+// NewBuilder creates a new Builder reading from the provided storage
+func NewBuilder(store storage.Store) *Builder {
+	return &Builder{storage: store}
+}
+
+// Build creates a graph of all resources in the given projects.
+// An empty projects slice includes every cached project.
+func (b *Builder) Build(ctx context.Context, projects []string) (*Graph, error) {
+	g := New()
+
+	// Build nodes from topics
+	topics, err := b.storage.GetAllTopics(ctx, projects)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get topics: %w", err)
+	}
+
+	for _, topic := range topics {
+		metadata := map[string]string{
+			"full_resource_name": topic.FullResourceName,
+		}
+		if len(topic.StorageRegions) > 0 {
+			metadata[StorageRegionsKey] = strings.Join(topic.StorageRegions, ",")
+		}
+		g.AddNode(&Node{
+			ID:       TopicNodeID(topic.ProjectID, topic.Name),
+			Label:    topic.Name,
+			Type:     NodeTypeTopic,
+			Project:  topic.ProjectID,
+			Metadata: withStoredMetadata(metadata, topic.Metadata),
+		})
+	}
+
+	// Build nodes and edges from subscriptions
+	subs, err := b.storage.GetAllSubscriptions(ctx, projects)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get subscriptions: %w", err)
+	}
+
+	subNodeIDs := make(map[string]string, len(subs))
+	for _, sub := range subs {
+		subNodeID := SubscriptionNodeID(sub.ProjectID, sub.Name)
+		subNodeIDs[sub.FullResourceName] = subNodeID
+		metadata := map[string]string{
+			"full_resource_name": sub.FullResourceName,
+			"topic":              sub.TopicFullResourceName,
+		}
+		if sub.Filter != "" {
+			metadata[FilterKey] = sub.Filter
+		}
+		g.AddNode(&Node{
+			ID:       subNodeID,
+			Label:    sub.Name,
+			Type:     NodeTypeSubscription,
+			Project:  sub.ProjectID,
+			Metadata: withStoredMetadata(metadata, sub.Metadata),
+		})
+
+		topicProject, topicName := ParseTopicReference(sub.TopicFullResourceName)
+		if topicName == "" {
+			// Subscription whose topic has been deleted
+			continue
+		}
+
+		// The topic may live in a project that wasn't included, add it so the edge has a target
+		topicNodeID := TopicNodeID(topicProject, topicName)
+		g.AddNode(&Node{
+			ID:      topicNodeID,
+			Label:   topicName,
+			Type:    NodeTypeTopic,
+			Project: topicProject,
+			Metadata: map[string]string{
+				"full_resource_name": sub.TopicFullResourceName,
+			},
+		})
+
+		edgeType := EdgeTypeSubscribes
+		if topicProject != sub.ProjectID {
+			edgeType = EdgeTypeCrossProject
+		}
+
+		g.AddEdge(&Edge{
+			From:  subNodeID,
+			To:    topicNodeID,
+			Type:  edgeType,
+			Label: "subscribes",
+		})
+	}
+
+	// Build sink nodes from BigQuery / Cloud Storage subscriptions
+	destinations, err := b.storage.GetAllSubscriptionDestinations(ctx, projects)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get subscription destinations: %w", err)
+	}
+
+	// Build function nodes with an edge from the topic triggering each
+	functions, err := b.storage.GetAllCloudFunctions(ctx, projects)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cloud functions: %w", err)
+	}
+
+	functionNodes := make(map[string]*Node) // 2nd gen functions, keyed by the Cloud Run service running them
+	for _, fn := range functions {
+		node := functionNode(fn)
+		if service := functionService(fn.Metadata); service != "" {
+			functionNodes[service] = node
+		}
+
+		topic := topicReferenceNode(fn.TriggerTopic)
+		if topic == nil {
+			// HTTP or other event functions only show up when a subscription pushes to them
+			continue
+		}
+		g.AddNode(topic)
+		g.AddNode(node)
+		g.AddEdge(&Edge{
+			From:  topic.ID,
+			To:    node.ID,
+			Type:  EdgeTypeTriggers,
+			Label: "triggers",
+		})
+	}
+
+	// consumerNode returns the node receiving pushes for a Cloud Run service,
+	// the function it runs if it is a 2nd gen function
+	consumerNode := func(fullResourceName string, svc *storage.CloudRunService) *Node {
+		if node, ok := functionNodes[fullResourceName]; ok {
+			return node
+		}
+		return cloudRunNode(fullResourceName, svc)
+	}
+
+	// Cloud Run services are read from every project, push subscriptions often deliver across projects
+	services, err := b.storage.GetAllCloudRunServices(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cloud run services: %w", err)
+	}
+	servicesByName := make(map[string]*storage.CloudRunService, len(services))
+	servicesByHost := make(map[string]*storage.CloudRunService)
+	for _, svc := range services {
+		servicesByName[svc.FullResourceName] = svc
+		for _, u := range svc.URLs {
+			if parsed, err := url.Parse(u); err == nil && parsed.Host != "" {
+				servicesByHost[parsed.Host] = svc
+			}
+		}
+	}
+
+	pushed := make(map[string]bool) // subscriptions already linked to their Cloud Run service
+	for _, dest := range destinations {
+		subNodeID, ok := subNodeIDs[dest.SubscriptionFullResourceName]
+		if !ok {
+			continue
+		}
+
+		label := "writes to"
+		var node *Node
+		if dest.Type == storage.DestinationTypeCloudRun {
+			label = "pushes to"
+			node = consumerNode(dest.Resource, servicesByName[dest.Resource])
+			pushed[dest.SubscriptionFullResourceName] = true
+		} else {
+			node = destinationNode(dest)
+		}
+		if node == nil {
+			continue
+		}
+		g.AddNode(node)
+		g.AddEdge(&Edge{
+			From:  subNodeID,
+			To:    node.ID,
+			Type:  EdgeTypeDelivers,
+			Label: label,
+		})
+	}
+
+	// Match push endpoints to the URLs of the collected Cloud Run services
+	for _, sub := range subs {
+		if pushed[sub.FullResourceName] {
+			continue
+		}
+		subNodeID := subNodeIDs[sub.FullResourceName]
+		endpoint, err := url.Parse(g.Nodes[subNodeID].Metadata[PushEndpointKey])
+		if err != nil || endpoint.Host == "" {
+			continue
+		}
+		svc, ok := servicesByHost[endpoint.Host]
+		if !ok {
+			continue
+		}
+
+		node := consumerNode(svc.FullResourceName, svc)
+		g.AddNode(node)
+		g.AddEdge(&Edge{
+			From:  subNodeID,
+			To:    node.ID,
+			Type:  EdgeTypeDelivers,
+			Label: "pushes to",
+		})
+	}
+
+	// Build Dataflow job nodes between the topics and subscriptions they read and the topics they write
+	jobs, err := b.storage.GetAllDataflowJobs(ctx, projects)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get dataflow jobs: %w", err)
+	}
+
+	for _, job := range jobs {
+		node := dataflowNode(job)
+		g.AddNode(node)
+		for _, source := range job.Sources {
+			// Subscriptions of projects that weren't included have no node to read from
+			fromID, ok := subNodeIDs[source]
+			if !ok {
+				topic := topicReferenceNode(source)
+				if topic == nil {
+					continue
+				}
+				g.AddNode(topic)
+				fromID = topic.ID
+			}
+			g.AddEdge(&Edge{
+				From:  fromID,
+				To:    node.ID,
+				Type:  EdgeTypeReads,
+				Label: "reads",
+			})
+		}
+		for _, sink := range job.Sinks {
+			topic := topicReferenceNode(sink)
+			if topic == nil {
+				continue
+			}
+			g.AddNode(topic)
+			g.AddEdge(&Edge{
+				From:  node.ID,
+				To:    topic.ID,
+				Type:  EdgeTypePublishes,
+				Label: "publishes",
+			})
+		}
+	}
+
+	// Build consumer identity nodes, merging IAM and audit log evidence per subscription
+	consumers, err := b.storage.GetAllSubscriptionConsumers(ctx, projects)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get subscription consumers: %w", err)
+	}
+
+	type consumerKey struct{ principal, subscription string }
+	observed := make(map[consumerKey]bool)
+	var keys []consumerKey
+	for _, consumer := range consumers {
+		key := consumerKey{consumer.Principal, consumer.SubscriptionFullResourceName}
+		if _, seen := observed[key]; !seen {
+			keys = append(keys, key)
+		}
+		observed[key] = observed[key] || consumer.Source == storage.ConsumerSourceAuditLog
+	}
+
+	for _, key := range keys {
+		subNodeID, ok := subNodeIDs[key.subscription]
+		if !ok {
+			continue
+		}
+
+		node := identityNode(key.principal)
+		g.AddNode(node)
+
+		label := "can pull"
+		if observed[key] {
+			label = "pulls"
+		}
+		g.AddEdge(&Edge{
+			From:     node.ID,
+			To:       subNodeID,
+			Type:     EdgeTypeConsumes,
+			Label:    label,
+			Inferred: !observed[key],
+		})
+	}
+
+	// Build publisher identity nodes, merging IAM and audit log evidence per topic
+	edges, err := b.storage.GetEdges(ctx, projects)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get edges: %w", err)
+	}
+
+	type publisherKey struct{ principal, topic string }
+	published := make(map[publisherKey]bool)
+	var publishers []publisherKey
+	for _, edge := range edges {
+		if edge.Relation != storage.RelationPublishes && edge.Relation != storage.RelationCanPublish {
+			continue
+		}
+		key := publisherKey{edge.Source, edge.Target}
+		if _, seen := published[key]; !seen {
+			publishers = append(publishers, key)
+		}
+		published[key] = published[key] || edge.Relation == storage.RelationPublishes
+	}
+
+	for _, key := range publishers {
+		topic := topicReferenceNode(key.topic)
+		if topic == nil {
+			continue
+		}
+
+		node := identityNode(key.principal)
+		g.AddNode(topic)
+		g.AddNode(node)
+
+		label := "can publish"
+		if published[key] {
+			label = "publishes"
+		}
+		g.AddEdge(&Edge{
+			From:     node.ID,
+			To:       topic.ID,
+			Type:     EdgeTypePublishes,
+			Label:    label,
+			Inferred: !published[key],
+		})
+	}
+
+	// Build Pub/Sub Lite nodes, next to the Pub/Sub topics their subscriptions export to
+	var lite []*storage.Resource
+	for _, kind := range []string{storage.ResourceKindLiteTopic, storage.ResourceKindLiteSubscription} {
+		resources, err := b.storage.GetResources(ctx, kind, projects)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get %s resources: %w", kind, err)
+		}
+		lite = append(lite, resources...)
+	}
+	addPubSubLite(g, lite, edges)
+
+	// Annotate topics and subscriptions with their traffic from Cloud Monitoring
+	metrics, err := b.storage.GetMetrics(ctx, projects)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get metrics: %w", err)
+	}
+	annotateMetrics(g, metrics)
+
+	// Note the projects the last scan couldn't collect on their clusters
+	statuses, err := b.storage.GetProjectStatuses(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get project statuses: %w", err)
+	}
+	annotateProjectStatuses(g, statuses)
+
+	return g, nil
+}
+
+// TopicNodeID returns the node ID used for a topic
+func TopicNodeID(projectID, name string) string {
+	return fmt.Sprintf("topic_%s_%s", projectID, name)
+}
+
+// SubscriptionNodeID returns the node ID used for a subscription
+func SubscriptionNodeID(projectID, name string) string {
+	return fmt.Sprintf("sub_%s_%s", projectID, name)
+}
+
+// topicReferenceNode creates the node for a topic referenced by its full resource name,
+// which may live in a project that wasn't included. It returns nil for a malformed name.
+func topicReferenceNode(fullResourceName string) *Node {
+	project, name := ParseTopicReference(fullResourceName)
+	if name == "" {
+		return nil
+	}
+	return &Node{
+		ID:      TopicNodeID(project, name),
+		Label:   name,
+		Type:    NodeTypeTopic,
+		Project: project,
+		Metadata: map[string]string{
+			"full_resource_name": fullResourceName,
+		},
+	}
+}
+
+// destinationNode creates the sink node for a subscription destination
+func destinationNode(dest *storage.SubscriptionDestination) *Node {
+	switch dest.Type {
+	case storage.DestinationTypeBigQuery:
+		return &Node{
+			ID:      "bq_" + dest.Resource,
+			Label:   dest.Resource,
+			Type:    NodeTypeBigQueryTable,
+			Project: parseBigQueryProject(dest.Resource),
+		}
+	case storage.DestinationTypeCloudStorage:
+		// Buckets live in a global namespace, so they are not clustered by project
+		return &Node{
+			ID:    "gcs_" + dest.Resource,
+			Label: "gs://" + dest.Resource,
+			Type:  NodeTypeStorageBucket,
+		}
+	default:
+		return nil
+	}
+}
+
+// cloudRunNode creates the node for the Cloud Run service with the given full resource name,
+// "projects/{project}/locations/{region}/services/{service}". svc is nil if the service
+// wasn't collected, e.g. when it is the target of a trigger in a project without Cloud Run
+// collection. It returns nil for a malformed name.
+func cloudRunNode(fullResourceName string, svc *storage.CloudRunService) *Node {
+	parts := strings.Split(fullResourceName, "/")
+	if len(parts) != 6 || parts[0] != "projects" || parts[2] != "locations" || parts[4] != "services" {
+		return nil
+	}
+	project, region, name := parts[1], parts[3], parts[5]
+
+	metadata := map[string]string{
+		"full_resource_name": fullResourceName,
+		"region":             region,
+	}
+	if svc != nil {
+		metadata = withStoredMetadata(metadata, svc.Metadata)
+		if len(svc.URLs) > 0 {
+			metadata["url"] = svc.URLs[0]
+		}
+	}
+	return &Node{
+		ID:       fmt.Sprintf("run_%s_%s_%s", project, region, name),
+		Label:    name,
+		Type:     NodeTypeCloudRunService,
+		Project:  project,
+		Metadata: metadata,
+	}
+}
+
+// functionNode creates the node for a Cloud Function
+func functionNode(fn *storage.CloudFunction) *Node {
+	metadata := withStoredMetadata(map[string]string{
+		"full_resource_name": fn.FullResourceName,
+		"region":             fn.Region,
+	}, fn.Metadata)
+	if fn.Generation > 0 {
+		metadata["generation"] = strconv.Itoa(fn.Generation)
+	}
+	if fn.EventType != "" {
+		metadata["event_type"] = fn.EventType
+	}
+	return &Node{
+		ID:       fmt.Sprintf("function_%s_%s_%s", fn.ProjectID, fn.Region, fn.Name),
+		Label:    fn.Name,
+		Type:     NodeTypeCloudFunction,
+		Project:  fn.ProjectID,
+		Metadata: metadata,
+	}
+}
+
+// dataflowNode creates the node for a Dataflow job
+func dataflowNode(job *storage.DataflowJob) *Node {
+	return &Node{
+		ID:      fmt.Sprintf("dataflow_%s_%s_%s", job.ProjectID, job.Region, job.Name),
+		Label:   job.Name,
+		Type:    NodeTypeDataflowJob,
+		Project: job.ProjectID,
+		Metadata: withStoredMetadata(map[string]string{
+			"full_resource_name": job.FullResourceName,
+			"region":             job.Region,
+		}, job.Metadata),
+	}
+}
+
+// functionService returns the Cloud Run service running a 2nd gen function,
+// read from its stored metadata, or empty for 1st gen functions
+func functionService(raw string) string {
+	var stored struct {
+		Service string `json:"service"`
+	}
+	if raw == "" || json.Unmarshal([]byte(raw), &stored) != nil {
+		return ""
+	}
+	return stored.Service
+}
+
+// LabelPrefix prefixes resource labels copied into node metadata
+const LabelPrefix = "labels."
+
+// DeadLetterTopicKey is the node metadata key holding a subscription's dead-letter topic
+const DeadLetterTopicKey = "dead_letter_topic"
+
+// PushEndpointKey is the node metadata key holding the endpoint of a push subscription
+const PushEndpointKey = "push_endpoint"
+
+// AckDeadlineKey is the node metadata key holding a subscription's ack deadline, as a Go duration string
+const AckDeadlineKey = "ack_deadline"
+
+// FilterKey is the node metadata key holding a subscription's filter expression
+const FilterKey = "filter"
+
+// SchemaKey is the node metadata key holding the schema a topic validates messages against
+const SchemaKey = "schema"
+
+// Node metadata keys holding the backoff bounds of a subscription's retry policy,
+// as Go duration strings. Both are missing if the subscription has no retry policy.
+const (
+	RetryMinimumBackoffKey = "retry_minimum_backoff"
+	RetryMaximumBackoffKey = "retry_maximum_backoff"
+)
+
+// withStoredMetadata copies the labels, dead-letter topic, push endpoint, ack deadline, filter
+// and retry policy of a resource's stored JSON metadata into node metadata. Metadata that can't be
+// decoded is ignored, all of them are optional.
+func withStoredMetadata(metadata map[string]string, raw string) map[string]string {
+	var stored struct {
+		Labels          map[string]string `json:"labels"`
+		DeadLetterTopic string            `json:"dead_letter_topic"`
+		PushEndpoint    string            `json:"push_endpoint"`
+		AckDeadline     int               `json:"ack_deadline_seconds"`
+		Filter          string            `json:"filter"`
+		Schema          string            `json:"schema"`
+		RetryPolicy     *struct {
+			MinimumBackoff string `json:"minimum_backoff"`
+			MaximumBackoff string `json:"maximum_backoff"`
+		} `json:"retry_policy"`
+	}
+	if raw == "" || json.Unmarshal([]byte(raw), &stored) != nil {
+		return metadata
+	}
+	for k, v := range stored.Labels {
+		metadata[LabelPrefix+k] = v
+	}
+	if stored.DeadLetterTopic != "" {
+		metadata[DeadLetterTopicKey] = stored.DeadLetterTopic
+	}
+	if stored.PushEndpoint != "" {
+		metadata[PushEndpointKey] = stored.PushEndpoint
+	}
+	if stored.AckDeadline > 0 {
+		metadata[AckDeadlineKey] = (time.Duration(stored.AckDeadline) * time.Second).String()
+	}
+	if stored.Filter != "" {
+		metadata[FilterKey] = stored.Filter
+	}
+	if stored.Schema != "" {
+		metadata[SchemaKey] = stored.Schema
+	}
+	if stored.RetryPolicy != nil {
+		metadata[RetryMinimumBackoffKey] = stored.RetryPolicy.MinimumBackoff
+		metadata[RetryMaximumBackoffKey] = stored.RetryPolicy.MaximumBackoff
+	}
+	return metadata
+}
+
+// identityNode creates the node for an IAM principal such as "serviceAccount:app@p.iam.gserviceaccount.com".
+// Service accounts are clustered in the project that owns them.
+func identityNode(principal string) *Node {
+	email := principal
+	if _, after, ok := strings.Cut(principal, ":"); ok {
+		email = after
+	}
+
+	project := ""
+	if name, domain, ok := strings.Cut(email, "@"); ok && name != "" {
+		if p, ok := strings.CutSuffix(domain, ".iam.gserviceaccount.com"); ok {
+			project = p
+		}
+	}
+
+	return &Node{
+		ID:      "identity_" + principal,
+		Label:   email,
+		Type:    NodeTypeIdentity,
+		Project: project,
+		Metadata: map[string]string{
+			"principal": principal,
+		},
+	}
+}
+
+// ParseTopicReference splits "projects/{project}/topics/{topic}" into project and topic name.
+// Returns empty strings if the reference is malformed (e.g. "_deleted-topic_").
+func ParseTopicReference(fullResourceName string) (string, string) {
+	parts := strings.Split(fullResourceName, "/")
+	if len(parts) != 4 || parts[0] != "projects" || parts[2] != "topics" {
+		return "", ""
+	}
+	return parts[1], parts[3]
+}
+
+// parseBigQueryProject returns the project of a BigQuery table reference in
+// either "project.dataset.table" or "project:dataset.table" form
+func parseBigQueryProject(table string) string {
+	if i := strings.IndexAny(table, ":."); i > 0 {
+		return table[:i]
+	}
+	return ""
+}
@@ -0,0 +1,597 @@
+package graph
+
+import (
+	"context"
+	"testing"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupTestStore(t *testing.T) storage.Store {
+	store, err := storage.NewSQLite(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = store.Close() })
+	return store
+}
+
+func TestBuild(t *testing.T) {
+	store := setupTestStore(t)
+	ctx := context.Background()
+
+	require.NoError(t, store.SaveTopic(ctx, &storage.Topic{
+		Name:             "events",
+		ProjectID:        "project-a",
+		FullResourceName: "projects/project-a/topics/events",
+	}))
+	require.NoError(t, store.SaveSubscription(ctx, &storage.Subscription{
+		Name:                  "local-sub",
+		ProjectID:             "project-a",
+		TopicFullResourceName: "projects/project-a/topics/events",
+		FullResourceName:      "projects/project-a/subscriptions/local-sub",
+	}))
+	require.NoError(t, store.SaveSubscription(ctx, &storage.Subscription{
+		Name:                  "remote-sub",
+		ProjectID:             "project-b",
+		TopicFullResourceName: "projects/project-a/topics/events",
+		FullResourceName:      "projects/project-b/subscriptions/remote-sub",
+	}))
+
+	g, err := NewBuilder(store).Build(ctx, nil)
+	require.NoError(t, err)
+
+	assert.Len(t, g.Nodes, 3)
+	assert.Len(t, g.Edges, 2)
+	assert.Len(t, g.Clusters, 2)
+
+	edgeTypes := map[string]EdgeType{}
+	for _, e := range g.Edges {
+		edgeTypes[e.From] = e.Type
+		assert.Equal(t, TopicNodeID("project-a", "events"), e.To)
+	}
+	assert.Equal(t, EdgeTypeSubscribes, edgeTypes[SubscriptionNodeID("project-a", "local-sub")])
+	assert.Equal(t, EdgeTypeCrossProject, edgeTypes[SubscriptionNodeID("project-b", "remote-sub")])
+}
+
+func TestBuild_ExternalTopic(t *testing.T) {
+	store := setupTestStore(t)
+	ctx := context.Background()
+
+	// Subscription to a topic in a project that is not part of the graph
+	require.NoError(t, store.SaveSubscription(ctx, &storage.Subscription{
+		Name:                  "sub",
+		ProjectID:             "project-b",
+		TopicFullResourceName: "projects/project-x/topics/external",
+		FullResourceName:      "projects/project-b/subscriptions/sub",
+	}))
+
+	g, err := NewBuilder(store).Build(ctx, []string{"project-b"})
+	require.NoError(t, err)
+
+	topic, ok := g.Nodes[TopicNodeID("project-x", "external")]
+	require.True(t, ok, "external topic should be added as a node")
+	assert.Equal(t, NodeTypeTopic, topic.Type)
+	assert.Contains(t, g.Clusters, "project-x")
+}
+
+func TestBuild_DeletedTopic(t *testing.T) {
+	store := setupTestStore(t)
+	ctx := context.Background()
+
+	require.NoError(t, store.SaveSubscription(ctx, &storage.Subscription{
+		Name:                  "orphan",
+		ProjectID:             "project-a",
+		TopicFullResourceName: "_deleted-topic_",
+		FullResourceName:      "projects/project-a/subscriptions/orphan",
+	}))
+
+	g, err := NewBuilder(store).Build(ctx, nil)
+	require.NoError(t, err)
+	assert.Len(t, g.Nodes, 1)
+	assert.Empty(t, g.Edges)
+}
+
+func TestBuild_StoredMetadata(t *testing.T) {
+	store := setupTestStore(t)
+	ctx := context.Background()
+
+	require.NoError(t, store.SaveTopic(ctx, &storage.Topic{
+		Name:             "orders",
+		ProjectID:        "project-a",
+		FullResourceName: "projects/project-a/topics/orders",
+		StorageRegions:   []string{"europe-west1", "europe-west4"},
+	}))
+	require.NoError(t, store.SaveSubscription(ctx, &storage.Subscription{
+		Name:                  "orders-email",
+		ProjectID:             "project-a",
+		TopicFullResourceName: "projects/project-a/topics/orders",
+		FullResourceName:      "projects/project-a/subscriptions/orders-email",
+		Metadata:              `{"labels":{"team":"mail"},"dead_letter_topic":"projects/project-a/topics/orders-dlq","ack_deadline_seconds":60,"filter":"attributes.kind = \"email\"","retry_policy":{"minimum_backoff":"10s","maximum_backoff":"10m0s"}}`,
+	}))
+
+	g, err := NewBuilder(store).Build(ctx, nil)
+	require.NoError(t, err)
+	sub := g.Nodes[SubscriptionNodeID("project-a", "orders-email")]
+	require.NotNil(t, sub)
+	assert.Equal(t, "mail", sub.Metadata[LabelPrefix+"team"])
+	assert.Equal(t, "projects/project-a/topics/orders-dlq", sub.Metadata[DeadLetterTopicKey])
+	assert.Equal(t, "10s", sub.Metadata[RetryMinimumBackoffKey])
+	assert.Equal(t, "10m0s", sub.Metadata[RetryMaximumBackoffKey])
+	assert.Equal(t, "1m0s", sub.Metadata[AckDeadlineKey])
+	assert.Equal(t, `attributes.kind = "email"`, sub.Metadata[FilterKey])
+
+	topic := g.Nodes[TopicNodeID("project-a", "orders")]
+	require.NotNil(t, topic)
+	assert.Equal(t, "europe-west1,europe-west4", topic.Metadata[StorageRegionsKey])
+}
+
+func TestBuild_SinkNodes(t *testing.T) {
+	store := setupTestStore(t)
+	ctx := context.Background()
+
+	for _, name := range []string{"bq-sub", "gcs-sub"} {
+		require.NoError(t, store.SaveSubscription(ctx, &storage.Subscription{
+			Name:                  name,
+			ProjectID:             "project-a",
+			TopicFullResourceName: "projects/project-a/topics/events",
+			FullResourceName:      "projects/project-a/subscriptions/" + name,
+		}))
+	}
+	require.NoError(t, store.SaveSubscriptionDestination(ctx, &storage.SubscriptionDestination{
+		SubscriptionFullResourceName: "projects/project-a/subscriptions/bq-sub",
+		ProjectID:                    "project-a",
+		Type:                         storage.DestinationTypeBigQuery,
+		Resource:                     "analytics-project.dataset.events",
+	}))
+	require.NoError(t, store.SaveSubscriptionDestination(ctx, &storage.SubscriptionDestination{
+		SubscriptionFullResourceName: "projects/project-a/subscriptions/gcs-sub",
+		ProjectID:                    "project-a",
+		Type:                         storage.DestinationTypeCloudStorage,
+		Resource:                     "archive-bucket",
+	}))
+
+	g, err := NewBuilder(store).Build(ctx, nil)
+	require.NoError(t, err)
+
+	bq, ok := g.Nodes["bq_analytics-project.dataset.events"]
+	require.True(t, ok)
+	assert.Equal(t, NodeTypeBigQueryTable, bq.Type)
+	assert.Equal(t, "analytics-project", bq.Project)
+
+	gcs, ok := g.Nodes["gcs_archive-bucket"]
+	require.True(t, ok)
+	assert.Equal(t, NodeTypeStorageBucket, gcs.Type)
+	assert.Equal(t, "gs://archive-bucket", gcs.Label)
+	assert.Empty(t, gcs.Project)
+
+	var delivers int
+	for _, e := range g.Edges {
+		if e.Type == EdgeTypeDelivers {
+			delivers++
+		}
+	}
+	assert.Equal(t, 2, delivers)
+}
+
+func TestParseTopicReference(t *testing.T) {
+	tests := []struct {
+		input   string
+		project string
+		topic   string
+	}{
+		{"projects/p/topics/t", "p", "t"},
+		{"_deleted-topic_", "", ""},
+		{"", "", ""},
+		{"projects/p/subscriptions/s", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			project, topic := ParseTopicReference(tt.input)
+			assert.Equal(t, tt.project, project)
+			assert.Equal(t, tt.topic, topic)
+		})
+	}
+}
+
+func TestBuild_ConsumerIdentities(t *testing.T) {
+	store := setupTestStore(t)
+	ctx := context.Background()
+	subName := "projects/project-a/subscriptions/orders-worker"
+
+	require.NoError(t, store.SaveSubscription(ctx, &storage.Subscription{
+		Name:                  "orders-worker",
+		ProjectID:             "project-a",
+		TopicFullResourceName: "projects/project-a/topics/orders",
+		FullResourceName:      subName,
+	}))
+	require.NoError(t, store.ReplaceSubscriptionConsumers(ctx, subName, storage.ConsumerSourceIAM, []*storage.SubscriptionConsumer{
+		{ProjectID: "project-a", Principal: "serviceAccount:worker@project-b.iam.gserviceaccount.com", Role: "roles/pubsub.subscriber"},
+		{ProjectID: "project-a", Principal: "group:team@example.com", Role: "roles/pubsub.subscriber"},
+	}))
+	require.NoError(t, store.SaveSubscriptionConsumer(ctx, &storage.SubscriptionConsumer{
+		SubscriptionFullResourceName: subName,
+		ProjectID:                    "project-a",
+		Principal:                    "serviceAccount:worker@project-b.iam.gserviceaccount.com",
+		Source:                       storage.ConsumerSourceAuditLog,
+		Role:                         "google.pubsub.v1.Subscriber.Pull",
+	}))
+
+	g, err := NewBuilder(store).Build(ctx, nil)
+	require.NoError(t, err)
+
+	sa, ok := g.Nodes["identity_serviceAccount:worker@project-b.iam.gserviceaccount.com"]
+	require.True(t, ok)
+	assert.Equal(t, NodeTypeIdentity, sa.Type)
+	assert.Equal(t, "project-b", sa.Project)
+	assert.Equal(t, "worker@project-b.iam.gserviceaccount.com", sa.Label)
+
+	group, ok := g.Nodes["identity_group:team@example.com"]
+	require.True(t, ok)
+	assert.Empty(t, group.Project)
+
+	labels := map[string]string{}
+	for _, e := range g.Edges {
+		if e.Type == EdgeTypeConsumes {
+			labels[e.From] = e.Label
+			assert.Equal(t, SubscriptionNodeID("project-a", "orders-worker"), e.To)
+		}
+	}
+	assert.Len(t, labels, 2, "one edge per identity even with multiple evidence sources")
+	assert.Equal(t, "pulls", labels[sa.ID])
+	assert.Equal(t, "can pull", labels[group.ID])
+	for _, e := range g.Edges {
+		if e.Type == EdgeTypeConsumes {
+			assert.Equal(t, e.From == group.ID, e.Inferred, "only IAM evidence is inferred")
+		}
+	}
+}
+
+func TestBuild_CloudRunServices(t *testing.T) {
+	store := setupTestStore(t)
+	ctx := context.Background()
+
+	require.NoError(t, store.SaveSubscriptions(ctx, []*storage.Subscription{
+		{
+			Name:                  "orders-push",
+			ProjectID:             "project-a",
+			TopicFullResourceName: "projects/project-a/topics/orders",
+			FullResourceName:      "projects/project-a/subscriptions/orders-push",
+			Metadata:              `{"labels":{},"push_endpoint":"https://mailer-123456789.europe-west1.run.app/push?token=x"}`,
+		},
+		{
+			Name:                  "orders-eventarc",
+			ProjectID:             "project-a",
+			TopicFullResourceName: "projects/project-a/topics/orders",
+			FullResourceName:      "projects/project-a/subscriptions/orders-eventarc",
+			Metadata:              `{"labels":{},"push_endpoint":"https://billing-abc123-ew.a.run.app/"}`,
+		},
+		{
+			Name:                  "orders-elsewhere",
+			ProjectID:             "project-a",
+			TopicFullResourceName: "projects/project-a/topics/orders",
+			FullResourceName:      "projects/project-a/subscriptions/orders-elsewhere",
+			Metadata:              `{"labels":{},"push_endpoint":"https://example.com/hook"}`,
+		},
+	}))
+	require.NoError(t, store.SaveCloudRunServices(ctx, []*storage.CloudRunService{{
+		Name:             "mailer",
+		ProjectID:        "project-b",
+		Region:           "europe-west1",
+		FullResourceName: "projects/project-b/locations/europe-west1/services/mailer",
+		URLs:             []string{"https://mailer-123456789.europe-west1.run.app", "https://mailer-xyz789-ew.a.run.app"},
+		Metadata:         `{"labels":{"team":"mail"}}`,
+	}}))
+	// The trigger's service wasn't collected, its node is built from the name alone
+	require.NoError(t, store.SaveSubscriptionDestination(ctx, &storage.SubscriptionDestination{
+		SubscriptionFullResourceName: "projects/project-a/subscriptions/orders-eventarc",
+		ProjectID:                    "project-a",
+		Type:                         storage.DestinationTypeCloudRun,
+		Resource:                     "projects/project-a/locations/europe-west1/services/billing",
+	}))
+
+	g, err := NewBuilder(store).Build(ctx, []string{"project-a"})
+	require.NoError(t, err)
+
+	mailer, ok := g.Nodes["run_project-b_europe-west1_mailer"]
+	require.True(t, ok, "push endpoint should be matched to the service in another project")
+	assert.Equal(t, NodeTypeCloudRunService, mailer.Type)
+	assert.Equal(t, "project-b", mailer.Project)
+	assert.Equal(t, "mail", mailer.Metadata[LabelPrefix+"team"])
+	assert.Equal(t, "https://mailer-123456789.europe-west1.run.app", mailer.Metadata["url"])
+
+	billing, ok := g.Nodes["run_project-a_europe-west1_billing"]
+	require.True(t, ok)
+	assert.Equal(t, "europe-west1", billing.Metadata["region"])
+
+	pushes := map[string]string{}
+	for _, e := range g.Edges {
+		if e.Type == EdgeTypeDelivers {
+			assert.Equal(t, "pushes to", e.Label)
+			pushes[e.From] = e.To
+		}
+	}
+	assert.Equal(t, map[string]string{
+		SubscriptionNodeID("project-a", "orders-push"):     mailer.ID,
+		SubscriptionNodeID("project-a", "orders-eventarc"): billing.ID,
+	}, pushes)
+	assert.Equal(t, "https://example.com/hook", g.Nodes[SubscriptionNodeID("project-a", "orders-elsewhere")].Metadata[PushEndpointKey])
+}
+
+func TestBuild_CloudFunctions(t *testing.T) {
+	store := setupTestStore(t)
+	ctx := context.Background()
+
+	require.NoError(t, store.SaveTopic(ctx, &storage.Topic{
+		Name:             "uploads",
+		ProjectID:        "project-a",
+		FullResourceName: "projects/project-a/topics/uploads",
+	}))
+	require.NoError(t, store.SaveSubscription(ctx, &storage.Subscription{
+		Name:                  "eventarc-us-central1-notify-123-sub-456",
+		ProjectID:             "project-a",
+		TopicFullResourceName: "projects/project-b/topics/invoices",
+		FullResourceName:      "projects/project-a/subscriptions/eventarc-us-central1-notify-123-sub-456",
+	}))
+	require.NoError(t, store.SaveSubscriptionDestination(ctx, &storage.SubscriptionDestination{
+		SubscriptionFullResourceName: "projects/project-a/subscriptions/eventarc-us-central1-notify-123-sub-456",
+		ProjectID:                    "project-a",
+		Type:                         storage.DestinationTypeCloudRun,
+		Resource:                     "projects/project-a/locations/us-central1/services/notify",
+	}))
+	require.NoError(t, store.SaveCloudFunctions(ctx, []*storage.CloudFunction{
+		{
+			Name:             "resize",
+			ProjectID:        "project-a",
+			Region:           "europe-west1",
+			FullResourceName: "projects/project-a/locations/europe-west1/functions/resize",
+			Generation:       1,
+			TriggerTopic:     "projects/project-a/topics/uploads",
+			EventType:        "google.pubsub.topic.publish",
+			Metadata:         `{"labels":{"team":"media"}}`,
+		},
+		{
+			Name:             "notify",
+			ProjectID:        "project-a",
+			Region:           "us-central1",
+			FullResourceName: "projects/project-a/locations/us-central1/functions/notify",
+			Generation:       2,
+			TriggerTopic:     "projects/project-b/topics/invoices",
+			EventType:        "google.cloud.pubsub.topic.v1.messagePublished",
+			Metadata:         `{"labels":{},"service":"projects/project-a/locations/us-central1/services/notify"}`,
+		},
+		{
+			Name:             "http",
+			ProjectID:        "project-a",
+			Region:           "europe-west1",
+			FullResourceName: "projects/project-a/locations/europe-west1/functions/http",
+			Generation:       2,
+			Metadata:         `{"labels":{}}`,
+		},
+	}))
+
+	g, err := NewBuilder(store).Build(ctx, []string{"project-a"})
+	require.NoError(t, err)
+
+	resize, ok := g.Nodes["function_project-a_europe-west1_resize"]
+	require.True(t, ok)
+	assert.Equal(t, NodeTypeCloudFunction, resize.Type)
+	assert.Equal(t, "1", resize.Metadata["generation"])
+	assert.Equal(t, "media", resize.Metadata[LabelPrefix+"team"])
+
+	notify, ok := g.Nodes["function_project-a_us-central1_notify"]
+	require.True(t, ok)
+	assert.Contains(t, g.Nodes, TopicNodeID("project-b", "invoices"), "the triggering topic is added from another project")
+	assert.NotContains(t, g.Nodes, "run_project-a_us-central1_notify", "the service running a 2nd gen function is drawn as the function")
+	assert.NotContains(t, g.Nodes, "function_project-a_europe-west1_http", "functions without a topic trigger are left out")
+
+	triggers := map[string]string{}
+	for _, e := range g.Edges {
+		switch e.Type {
+		case EdgeTypeTriggers:
+			triggers[e.To] = e.From
+		case EdgeTypeDelivers:
+			assert.Equal(t, notify.ID, e.To)
+		}
+	}
+	assert.Equal(t, map[string]string{
+		resize.ID: TopicNodeID("project-a", "uploads"),
+		notify.ID: TopicNodeID("project-b", "invoices"),
+	}, triggers)
+}
+
+func TestBuild_DataflowJobs(t *testing.T) {
+	store := setupTestStore(t)
+	ctx := context.Background()
+
+	require.NoError(t, store.SaveTopic(ctx, &storage.Topic{
+		Name:             "orders",
+		ProjectID:        "project-a",
+		FullResourceName: "projects/project-a/topics/orders",
+	}))
+	require.NoError(t, store.SaveSubscription(ctx, &storage.Subscription{
+		Name:                  "orders-dataflow",
+		ProjectID:             "project-a",
+		TopicFullResourceName: "projects/project-a/topics/orders",
+		FullResourceName:      "projects/project-a/subscriptions/orders-dataflow",
+	}))
+	require.NoError(t, store.SaveDataflowJobs(ctx, []*storage.DataflowJob{
+		{
+			Name:             "enrich-orders",
+			ProjectID:        "project-a",
+			Region:           "europe-west1",
+			FullResourceName: "projects/project-a/locations/europe-west1/jobs/2024-01-01_00_00_00-1",
+			Sources:          []string{"projects/project-a/subscriptions/orders-dataflow", "projects/project-c/subscriptions/excluded"},
+			Sinks:            []string{"projects/project-b/topics/enriched"},
+			Metadata:         `{"labels":{"team":"data"},"state":"JOB_STATE_RUNNING"}`,
+		},
+		{
+			Name:             "mirror",
+			ProjectID:        "project-a",
+			Region:           "us-central1",
+			FullResourceName: "projects/project-a/locations/us-central1/jobs/2024-01-01_00_00_00-2",
+			Sources:          []string{"projects/project-b/topics/enriched"},
+		},
+		{
+			Name:             "elsewhere",
+			ProjectID:        "project-b",
+			Region:           "europe-west1",
+			FullResourceName: "projects/project-b/locations/europe-west1/jobs/2024-01-01_00_00_00-3",
+			Sources:          []string{"projects/project-a/topics/orders"},
+		},
+	}))
+
+	g, err := NewBuilder(store).Build(ctx, []string{"project-a"})
+	require.NoError(t, err)
+
+	enrich, ok := g.Nodes["dataflow_project-a_europe-west1_enrich-orders"]
+	require.True(t, ok)
+	assert.Equal(t, NodeTypeDataflowJob, enrich.Type)
+	assert.Equal(t, "europe-west1", enrich.Metadata["region"])
+	assert.Equal(t, "data", enrich.Metadata[LabelPrefix+"team"])
+	assert.Contains(t, g.Nodes, "dataflow_project-a_us-central1_mirror")
+	assert.NotContains(t, g.Nodes, "dataflow_project-b_europe-west1_elsewhere", "jobs are filtered by project")
+	assert.Contains(t, g.Nodes, TopicNodeID("project-b", "enriched"), "the written topic is added from another project")
+
+	var flows [][2]string
+	for _, e := range g.Edges {
+		switch e.Type {
+		case EdgeTypeReads, EdgeTypePublishes:
+			flows = append(flows, [2]string{e.From, e.To})
+		}
+	}
+	assert.ElementsMatch(t, [][2]string{
+		{SubscriptionNodeID("project-a", "orders-dataflow"), enrich.ID},
+		{enrich.ID, TopicNodeID("project-b", "enriched")},
+		{TopicNodeID("project-b", "enriched"), "dataflow_project-a_us-central1_mirror"},
+	}, flows, "subscriptions of excluded projects have no node to read from")
+}
+
+func TestBuild_Publishers(t *testing.T) {
+	store := setupTestStore(t)
+	ctx := context.Background()
+
+	require.NoError(t, store.SaveTopic(ctx, &storage.Topic{
+		Name:             "orders",
+		ProjectID:        "project-a",
+		FullResourceName: "projects/project-a/topics/orders",
+	}))
+	require.NoError(t, store.SaveEdges(ctx, []*storage.ResourceEdge{
+		{
+			Source:    "serviceAccount:orders@project-a.iam.gserviceaccount.com",
+			Target:    "projects/project-a/topics/orders",
+			Relation:  storage.RelationPublishes,
+			ProjectID: "project-a",
+		},
+		{
+			Source:    "serviceAccount:orders@project-a.iam.gserviceaccount.com",
+			Target:    "projects/project-a/topics/orders",
+			Relation:  storage.RelationCanPublish,
+			ProjectID: "project-a",
+		},
+		{
+			Source:    "group:checkout@example.com",
+			Target:    "projects/project-a/topics/orders",
+			Relation:  storage.RelationCanPublish,
+			ProjectID: "project-a",
+		},
+		{
+			Source:    "user:dev@example.com",
+			Target:    "projects/project-b/topics/clicks",
+			Relation:  storage.RelationPublishes,
+			ProjectID: "project-a",
+		},
+	}))
+
+	g, err := NewBuilder(store).Build(ctx, nil)
+	require.NoError(t, err)
+
+	sa, ok := g.Nodes["identity_serviceAccount:orders@project-a.iam.gserviceaccount.com"]
+	require.True(t, ok)
+	assert.Equal(t, "project-a", sa.Project)
+	_, ok = g.Nodes[TopicNodeID("project-b", "clicks")]
+	assert.True(t, ok, "topics of projects that weren't scanned get a node")
+
+	publishes := map[string]*Edge{}
+	for _, e := range g.Edges {
+		if e.Type == EdgeTypePublishes {
+			publishes[e.From+" -> "+e.To] = e
+		}
+	}
+	require.Len(t, publishes, 3, "one edge per identity even with multiple evidence sources")
+
+	observed := publishes[sa.ID+" -> "+TopicNodeID("project-a", "orders")]
+	require.NotNil(t, observed)
+	assert.Equal(t, "publishes", observed.Label)
+	assert.False(t, observed.Inferred)
+
+	inferred := publishes["identity_group:checkout@example.com -> "+TopicNodeID("project-a", "orders")]
+	require.NotNil(t, inferred)
+	assert.Equal(t, "can publish", inferred.Label)
+	assert.True(t, inferred.Inferred)
+
+	assert.Contains(t, publishes, "identity_user:dev@example.com -> "+TopicNodeID("project-b", "clicks"))
+}
+
+func TestBuild_PubSubLite(t *testing.T) {
+	store := setupTestStore(t)
+	ctx := context.Background()
+
+	require.NoError(t, store.SaveResources(ctx, []*storage.Resource{
+		{
+			Kind:             storage.ResourceKindLiteTopic,
+			Name:             "clicks",
+			ProjectID:        "project-a",
+			FullResourceName: "projects/project-a/locations/europe-west1-b/topics/clicks",
+			Metadata:         `{"location":"europe-west1-b","partitions":2}`,
+		},
+		{
+			Kind:             storage.ResourceKindLiteSubscription,
+			Name:             "clicks-export",
+			ProjectID:        "project-a",
+			FullResourceName: "projects/project-a/locations/europe-west1-b/subscriptions/clicks-export",
+			Metadata:         `{"location":"europe-west1-b","delivery_requirement":"DELIVER_AFTER_STORED"}`,
+		},
+	}))
+	require.NoError(t, store.SaveEdges(ctx, []*storage.ResourceEdge{
+		{
+			Source:    "projects/project-a/locations/europe-west1-b/subscriptions/clicks-export",
+			Target:    "projects/project-a/locations/europe-west1-b/topics/clicks",
+			Relation:  storage.RelationSubscribes,
+			ProjectID: "project-a",
+		},
+		{
+			Source:    "projects/project-a/locations/europe-west1-b/subscriptions/clicks-export",
+			Target:    "projects/project-b/topics/clicks",
+			Relation:  storage.RelationExports,
+			ProjectID: "project-a",
+		},
+	}))
+
+	g, err := NewBuilder(store).Build(ctx, nil)
+	require.NoError(t, err)
+
+	topic, ok := g.Nodes["lite_topic_project-a_europe-west1-b_clicks"]
+	require.True(t, ok)
+	assert.Equal(t, NodeTypeLiteTopic, topic.Type)
+	assert.Equal(t, "2", topic.Metadata["partitions"])
+	assert.Equal(t, "europe-west1-b", topic.Metadata[LiteLocationKey])
+
+	sub, ok := g.Nodes["lite_sub_project-a_europe-west1-b_clicks-export"]
+	require.True(t, ok)
+	assert.Equal(t, NodeTypeLiteSubscription, sub.Type)
+	assert.Equal(t, "DELIVER_AFTER_STORED", sub.Metadata["delivery_requirement"])
+
+	_, ok = g.Nodes[TopicNodeID("project-b", "clicks")]
+	assert.True(t, ok, "the Pub/Sub topic a Lite subscription exports to gets a node")
+
+	flows := map[string]string{}
+	for _, e := range g.Edges {
+		flows[e.From+" -> "+e.To] = e.Label
+	}
+	assert.Equal(t, map[string]string{
+		sub.ID + " -> " + topic.ID:                           "subscribes",
+		sub.ID + " -> " + TopicNodeID("project-b", "clicks"): "exports to",
+	}, flows)
+}
@@ -0,0 +1,111 @@
+package graph
+
+// ClassificationKey is the node metadata key holding the data classification level
+const ClassificationKey = "classification"
+
+// classificationPalette colors levels from least to most sensitive
+var classificationPalette = []string{"palegreen", "gold", "orange", "red"}
+
+// Classifier assigns data classification levels to topics and the flows consuming them
+type Classifier struct {
+	LabelKey       string            // Topic label holding the level
+	Levels         []string          // Known levels, least to most sensitive
+	SensitiveLevel string            // Levels at or above this one are sensitive
+	Topics         map[string]string // Topic full resource name -> level, takes precedence over labels
+}
+
+// Rank returns the position of level in Levels, or -1 if the level is unknown
+func (c *Classifier) Rank(level string) int {
+	for i, l := range c.Levels {
+		if l == level {
+			return i
+		}
+	}
+	return -1
+}
+
+// IsSensitive reports whether level is at or above SensitiveLevel
+func (c *Classifier) IsSensitive(level string) bool {
+	threshold := c.Rank(c.SensitiveLevel)
+	return threshold >= 0 && c.Rank(level) >= threshold
+}
+
+// Apply sets the classification of every topic from the config mapping or its labels
+// and propagates it downstream to subscriptions, their sinks and their consumers.
+// Downstream nodes take the most sensitive level of everything flowing into them.
+func (c *Classifier) Apply(g *Graph) {
+	for _, node := range g.Nodes {
+		if node.Type != NodeTypeTopic {
+			continue
+		}
+		level := c.Topics[node.Metadata["full_resource_name"]]
+		if level == "" && c.LabelKey != "" {
+			level = node.Metadata[LabelPrefix+c.LabelKey]
+		}
+		if c.Rank(level) >= 0 {
+			node.Metadata[ClassificationKey] = level
+		}
+	}
+
+	// Topic -> subscription first, so sinks and consumers see the subscription's level
+	for _, edge := range g.Edges {
+		if edge.Type == EdgeTypeSubscribes || edge.Type == EdgeTypeCrossProject {
+			c.propagate(g.Nodes[edge.To], g.Nodes[edge.From])
+		}
+	}
+	for _, edge := range g.Edges {
+		switch edge.Type {
+		case EdgeTypeDelivers:
+			c.propagate(g.Nodes[edge.From], g.Nodes[edge.To])
+		case EdgeTypeConsumes:
+			c.propagate(g.Nodes[edge.To], g.Nodes[edge.From])
+		}
+	}
+}
+
+// propagate copies the classification of from to to if it is more sensitive
+func (c *Classifier) propagate(from, to *Node) {
+	if from == nil || to == nil {
+		return
+	}
+	level, ok := from.Metadata[ClassificationKey]
+	if !ok || c.Rank(level) <= c.Rank(to.Metadata[ClassificationKey]) {
+		return
+	}
+	if to.Metadata == nil {
+		to.Metadata = make(map[string]string)
+	}
+	to.Metadata[ClassificationKey] = level
+}
+
+// Colorize colors nodes and edges by sensitivity. Apply must be called first.
+// Unclassified nodes are white and edges take the color of their most sensitive end.
+func (c *Classifier) Colorize(g *Graph) {
+	for _, node := range g.Nodes {
+		node.Color = c.color(c.Rank(node.Metadata[ClassificationKey]))
+	}
+	for _, edge := range g.Edges {
+		rank := -1
+		for _, id := range []string{edge.From, edge.To} {
+			if node, ok := g.Nodes[id]; ok {
+				rank = max(rank, c.Rank(node.Metadata[ClassificationKey]))
+			}
+		}
+		if rank >= 0 {
+			edge.Color = c.color(rank)
+		} else {
+			edge.Color = "grey"
+		}
+	}
+}
+
+// color maps a level rank onto the palette, spreading levels across the full range
+func (c *Classifier) color(rank int) string {
+	if rank < 0 {
+		return "white"
+	}
+	if len(c.Levels) <= 1 {
+		return classificationPalette[len(classificationPalette)-1]
+	}
+	return classificationPalette[rank*(len(classificationPalette)-1)/(len(c.Levels)-1)]
+}
@@ -0,0 +1,75 @@
+package graph
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testClassifier() *Classifier {
+	return &Classifier{
+		LabelKey:       "data_classification",
+		Levels:         []string{"public", "internal", "confidential", "restricted"},
+		SensitiveLevel: "confidential",
+		Topics:         map[string]string{"projects/a/topics/quiet": "internal"},
+	}
+}
+
+func TestClassifier_Apply(t *testing.T) {
+	g := meshGraph()
+	g.Nodes["topic_a_orders"].Metadata = map[string]string{LabelPrefix + "data_classification": "restricted"}
+	g.Nodes["topic_a_quiet"].Metadata = map[string]string{
+		"full_resource_name":                "projects/a/topics/quiet",
+		LabelPrefix + "data_classification": "public",
+	}
+
+	c := testClassifier()
+	c.Apply(g)
+
+	assert.Equal(t, "restricted", g.Nodes["topic_a_orders"].Metadata[ClassificationKey])
+	// Config mapping takes precedence over labels
+	assert.Equal(t, "internal", g.Nodes["topic_a_quiet"].Metadata[ClassificationKey])
+	// Propagated downstream to subscriptions and sinks
+	assert.Equal(t, "restricted", g.Nodes["sub_b_billing"].Metadata[ClassificationKey])
+	assert.Equal(t, "restricted", g.Nodes["gcs_archive"].Metadata[ClassificationKey])
+	assert.Equal(t, "internal", g.Nodes["sub_a_quiet"].Metadata[ClassificationKey])
+}
+
+func TestClassifier_UnknownLevelIgnored(t *testing.T) {
+	g := meshGraph()
+	g.Nodes["topic_a_orders"].Metadata = map[string]string{LabelPrefix + "data_classification": "top-secret"}
+
+	testClassifier().Apply(g)
+	assert.NotContains(t, g.Nodes["topic_a_orders"].Metadata, ClassificationKey)
+}
+
+func TestClassifier_IsSensitive(t *testing.T) {
+	c := testClassifier()
+	assert.False(t, c.IsSensitive("internal"))
+	assert.True(t, c.IsSensitive("confidential"))
+	assert.True(t, c.IsSensitive("restricted"))
+	assert.False(t, c.IsSensitive(""))
+
+	c.SensitiveLevel = "unknown"
+	assert.False(t, c.IsSensitive("restricted"))
+}
+
+func TestClassifier_Colorize(t *testing.T) {
+	g := meshGraph()
+	g.Nodes["topic_a_orders"].Metadata = map[string]string{LabelPrefix + "data_classification": "restricted"}
+
+	c := testClassifier()
+	c.Apply(g)
+	c.Colorize(g)
+
+	assert.Equal(t, "red", g.Nodes["topic_a_orders"].Color)
+	assert.Equal(t, "white", g.Nodes["topic_a_quiet"].Color)
+	for _, e := range g.Edges {
+		if e.To == "topic_a_orders" {
+			assert.Equal(t, "red", e.Color)
+		}
+		if e.To == "topic_a_quiet" {
+			assert.Equal(t, "grey", e.Color)
+		}
+	}
+}
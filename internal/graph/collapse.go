@@ -0,0 +1,130 @@
+package graph
+
+import (
+	"fmt"
+	"path"
+	"sort"
+)
+
+// CollapsedKey is the node metadata key holding the name pattern a summary node stands for
+const CollapsedKey = "collapsed"
+
+// Collapse returns a new graph in which the resources of a project and type whose names match the
+// same glob pattern, e.g. per-tenant topics "events-tenant-*", are replaced by a single summary node
+// counting them. A resource is collapsed by the first pattern it matches, and patterns matching a
+// single resource of a project and type leave it as it is. The edges of the collapsed resources are
+// moved to their summary node, merging those that end up connecting the same nodes.
+func Collapse(g *Graph, patterns []string) (*Graph, error) {
+	type groupKey struct {
+		project  string
+		nodeType NodeType
+		pattern  string
+	}
+	groups := make(map[groupKey][]*Node)
+	for _, node := range g.Nodes {
+		for _, pattern := range patterns {
+			ok, err := path.Match(pattern, node.Label)
+			if err != nil {
+				return nil, fmt.Errorf("invalid collapse pattern %q: %w", pattern, err)
+			}
+			if ok {
+				key := groupKey{node.Project, node.Type, pattern}
+				groups[key] = append(groups[key], node)
+				break
+			}
+		}
+	}
+
+	// ID of the summary node replacing each collapsed resource
+	replaced := make(map[string]string)
+	var summaries []*Node
+	for key, members := range groups {
+		if len(members) < 2 {
+			continue
+		}
+		summary := &Node{
+			ID:       fmt.Sprintf("collapsed_%s_%s_%s", key.project, key.nodeType, key.pattern),
+			Label:    key.pattern,
+			Type:     key.nodeType,
+			Project:  key.project,
+			Metadata: map[string]string{CollapsedKey: key.pattern},
+			Count:    len(members),
+		}
+		// Members share the project, so its team, see Ownership
+		if team := members[0].Metadata[TeamKey]; team != "" {
+			summary.Metadata[TeamKey] = team
+		}
+		// Styles and overlays all members share carry over
+		summary.Color, summary.Border, summary.Shape = members[0].Color, members[0].Border, members[0].Shape
+		for _, member := range members {
+			replaced[member.ID] = summary.ID
+			if member.Color != summary.Color {
+				summary.Color = ""
+			}
+			if member.Border != summary.Border {
+				summary.Border = ""
+			}
+			if member.Shape != summary.Shape {
+				summary.Shape = ""
+			}
+		}
+		summaries = append(summaries, summary)
+	}
+
+	collapsed := New()
+	nodes := summaries
+	for id, node := range g.Nodes {
+		if _, ok := replaced[id]; !ok {
+			nodes = append(nodes, node)
+		}
+	}
+	// Sorted so cluster membership order is deterministic
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].ID < nodes[j].ID })
+	for _, node := range nodes {
+		collapsed.AddNode(node)
+	}
+	for project, cluster := range collapsed.Clusters {
+		cluster.Label = g.Clusters[project].Label
+	}
+
+	type edgeKey struct {
+		from, to string
+		edgeType EdgeType
+		inferred bool
+	}
+	merged := make(map[edgeKey]*Edge)
+	for _, edge := range g.Edges {
+		from, to := edge.From, edge.To
+		if id, ok := replaced[from]; ok {
+			from = id
+		}
+		if id, ok := replaced[to]; ok {
+			to = id
+		}
+		if from == to {
+			// Between resources collapsed into the same node
+			continue
+		}
+		if from == edge.From && to == edge.To {
+			collapsed.AddEdge(edge)
+			continue
+		}
+
+		key := edgeKey{from, to, edge.Type, edge.Inferred}
+		if existing, ok := merged[key]; ok {
+			if existing.Label != edge.Label {
+				existing.Label = ""
+			}
+			if existing.Color != edge.Color {
+				existing.Color = ""
+			}
+			existing.Width = max(existing.Width, edge.Width)
+			continue
+		}
+		moved := *edge
+		moved.From, moved.To = from, to
+		merged[key] = &moved
+		collapsed.AddEdge(&moved)
+	}
+	return collapsed, nil
+}
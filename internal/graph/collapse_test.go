@@ -0,0 +1,66 @@
+package graph
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollapse(t *testing.T) {
+	g := New()
+	for _, node := range []*Node{
+		{ID: "t1", Label: "events-tenant-1", Type: NodeTypeTopic, Project: "p", Color: "green"},
+		{ID: "t2", Label: "events-tenant-2", Type: NodeTypeTopic, Project: "p", Color: "green"},
+		{ID: "t3", Label: "events-tenant-3", Type: NodeTypeTopic, Project: "p", Color: "red"},
+		{ID: "t4", Label: "events-tenant-4", Type: NodeTypeTopic, Project: "q"},
+		{ID: "s1", Label: "events-tenant-1-sub", Type: NodeTypeSubscription, Project: "p"},
+		{ID: "s2", Label: "events-tenant-2-sub", Type: NodeTypeSubscription, Project: "p"},
+		{ID: "audit", Label: "audit", Type: NodeTypeSubscription, Project: "p"},
+	} {
+		g.AddNode(node)
+	}
+	g.Clusters["p"].Label = "p (annotated)"
+	g.AddEdge(&Edge{From: "s1", To: "t1", Type: EdgeTypeSubscribes, Label: "push"})
+	g.AddEdge(&Edge{From: "s2", To: "t2", Type: EdgeTypeSubscribes, Label: "push"})
+	g.AddEdge(&Edge{From: "audit", To: "t1", Type: EdgeTypeSubscribes, Label: "pull"})
+	g.AddEdge(&Edge{From: "audit", To: "t3", Type: EdgeTypeSubscribes, Label: "push"})
+
+	collapsed, err := Collapse(g, []string{"events-tenant-*-sub", "events-tenant-*"})
+	require.NoError(t, err)
+
+	topics := collapsed.Nodes["collapsed_p_topic_events-tenant-*"]
+	require.NotNil(t, topics)
+	assert.Equal(t, 3, topics.Count)
+	assert.Equal(t, "events-tenant-*", topics.Label)
+	assert.Equal(t, "events-tenant-*", topics.Metadata[CollapsedKey])
+	// The members disagree on the color
+	assert.Empty(t, topics.Color)
+	subs := collapsed.Nodes["collapsed_p_subscription_events-tenant-*-sub"]
+	require.NotNil(t, subs)
+	assert.Equal(t, 2, subs.Count)
+
+	// A single match in a project is left as it is
+	assert.Contains(t, collapsed.Nodes, "t4")
+	assert.NotContains(t, collapsed.Nodes, "t1")
+	assert.Len(t, collapsed.Nodes, 4)
+	assert.Equal(t, "p (annotated)", collapsed.Clusters["p"].Label)
+	assert.Len(t, collapsed.Clusters["p"].Nodes, 3)
+
+	// Duplicate edges are merged, their labels only kept if they agree
+	require.Len(t, collapsed.Edges, 2)
+	for _, edge := range collapsed.Edges {
+		switch edge.From {
+		case subs.ID:
+			assert.Equal(t, "push", edge.Label)
+		case "audit":
+			assert.Empty(t, edge.Label)
+		}
+		assert.Equal(t, topics.ID, edge.To)
+	}
+	// The input graph is untouched
+	assert.Equal(t, "s1", g.Edges[0].From)
+
+	_, err = Collapse(g, []string{"["})
+	assert.Error(t, err)
+}
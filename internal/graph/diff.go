@@ -0,0 +1,154 @@
+package graph
+
+import (
+	"maps"
+	"sort"
+)
+
+// ChangeStatus describes how a node or edge differs between two graphs
+type ChangeStatus string
+
+const (
+	StatusUnchanged ChangeStatus = "unchanged"
+	StatusAdded     ChangeStatus = "added"
+	StatusRemoved   ChangeStatus = "removed"
+	StatusChanged   ChangeStatus = "changed" // nodes only: label or metadata differ
+)
+
+// Diff is the union of two graphs with the change status of every element
+type Diff struct {
+	Graph *Graph
+	Nodes map[string]ChangeStatus // keyed by node ID
+	Edges map[string]ChangeStatus // keyed by EdgeKey
+}
+
+// EdgeKey identifies an edge by its endpoints and type
+func EdgeKey(edge *Edge) string {
+	return edge.From + "|" + edge.To + "|" + string(edge.Type)
+}
+
+// Compare returns the union of before and after, marking each element as
+// added, removed, changed or unchanged. Elements present in both graphs are
+// taken from after.
+func Compare(before, after *Graph) *Diff {
+	d := &Diff{
+		Graph: New(),
+		Nodes: make(map[string]ChangeStatus),
+		Edges: make(map[string]ChangeStatus),
+	}
+
+	for _, id := range sortedNodeIDs(after) {
+		node := after.Nodes[id]
+		d.Graph.AddNode(node)
+
+		old, ok := before.Nodes[id]
+		switch {
+		case !ok:
+			d.Nodes[id] = StatusAdded
+		case old.Label != node.Label || !maps.Equal(old.Metadata, node.Metadata):
+			d.Nodes[id] = StatusChanged
+		default:
+			d.Nodes[id] = StatusUnchanged
+		}
+	}
+	for _, id := range sortedNodeIDs(before) {
+		if _, ok := after.Nodes[id]; !ok {
+			d.Graph.AddNode(before.Nodes[id])
+			d.Nodes[id] = StatusRemoved
+		}
+	}
+
+	beforeEdges := make(map[string]bool, len(before.Edges))
+	for _, edge := range before.Edges {
+		beforeEdges[EdgeKey(edge)] = true
+	}
+	for _, edge := range after.Edges {
+		key := EdgeKey(edge)
+		if _, seen := d.Edges[key]; seen {
+			continue
+		}
+		d.Graph.AddEdge(edge)
+		if beforeEdges[key] {
+			d.Edges[key] = StatusUnchanged
+		} else {
+			d.Edges[key] = StatusAdded
+		}
+	}
+	for _, edge := range before.Edges {
+		key := EdgeKey(edge)
+		if _, seen := d.Edges[key]; seen {
+			continue
+		}
+		d.Graph.AddEdge(edge)
+		d.Edges[key] = StatusRemoved
+	}
+
+	return d
+}
+
+// Count returns the number of nodes and edges with the given status
+func (d *Diff) Count(status ChangeStatus) (nodes, edges int) {
+	for _, s := range d.Nodes {
+		if s == status {
+			nodes++
+		}
+	}
+	for _, s := range d.Edges {
+		if s == status {
+			edges++
+		}
+	}
+	return nodes, edges
+}
+
+// sortedNodeIDs returns the node IDs of g in sorted order, so cluster
+// membership of the union graph is deterministic
+func sortedNodeIDs(g *Graph) []string {
+	ids := make([]string, 0, len(g.Nodes))
+	for id := range g.Nodes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// DiffKey is the node metadata key holding StatusAdded or StatusRemoved for the nodes marked by MarkDiff
+const DiffKey = "diff"
+
+// Colors of MarkDiff, removed elements are ghosted in pale red
+const (
+	addedFill     = "palegreen"
+	addedBorder   = "green"
+	addedEdge     = "green"
+	removedFill   = "#fde2e2"
+	removedBorder = "#e57373"
+	removedEdge   = "#f4a6a6"
+)
+
+// MarkDiff colors the added nodes and edges of d green and ghosts the removed ones in pale red,
+// recording the status of the nodes under DiffKey. Changed and unchanged elements keep their colors.
+func MarkDiff(d *Diff) {
+	for id, status := range d.Nodes {
+		node := d.Graph.Nodes[id]
+		switch status {
+		case StatusAdded:
+			node.Color, node.Border = addedFill, addedBorder
+		case StatusRemoved:
+			node.Color, node.Border = removedFill, removedBorder
+		default:
+			continue
+		}
+		if node.Metadata == nil {
+			node.Metadata = make(map[string]string)
+		}
+		node.Metadata[DiffKey] = string(status)
+	}
+	for _, edge := range d.Graph.Edges {
+		switch d.Edges[EdgeKey(edge)] {
+		case StatusAdded:
+			edge.Color, edge.Width = addedEdge, 2
+		case StatusRemoved:
+			edge.Color = removedEdge
+		}
+	}
+}
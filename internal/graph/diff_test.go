@@ -0,0 +1,77 @@
+package graph
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompare(t *testing.T) {
+	before := New()
+	before.AddNode(&Node{ID: "topic_a_orders", Label: "orders", Type: NodeTypeTopic, Project: "a"})
+	before.AddNode(&Node{ID: "sub_a_old", Label: "old", Type: NodeTypeSubscription, Project: "a"})
+	before.AddNode(&Node{ID: "sub_a_billing", Label: "billing", Type: NodeTypeSubscription, Project: "a",
+		Metadata: map[string]string{"labels.team": "billing"}})
+	before.AddEdge(&Edge{From: "sub_a_old", To: "topic_a_orders", Type: EdgeTypeSubscribes})
+	before.AddEdge(&Edge{From: "sub_a_billing", To: "topic_a_orders", Type: EdgeTypeSubscribes})
+
+	after := New()
+	after.AddNode(&Node{ID: "topic_a_orders", Label: "orders", Type: NodeTypeTopic, Project: "a"})
+	after.AddNode(&Node{ID: "sub_b_new", Label: "new", Type: NodeTypeSubscription, Project: "b"})
+	after.AddNode(&Node{ID: "sub_a_billing", Label: "billing", Type: NodeTypeSubscription, Project: "a",
+		Metadata: map[string]string{"labels.team": "finance"}})
+	after.AddEdge(&Edge{From: "sub_b_new", To: "topic_a_orders", Type: EdgeTypeCrossProject})
+	after.AddEdge(&Edge{From: "sub_a_billing", To: "topic_a_orders", Type: EdgeTypeSubscribes})
+
+	d := Compare(before, after)
+
+	require.Len(t, d.Graph.Nodes, 4)
+	assert.Equal(t, StatusUnchanged, d.Nodes["topic_a_orders"])
+	assert.Equal(t, StatusAdded, d.Nodes["sub_b_new"])
+	assert.Equal(t, StatusRemoved, d.Nodes["sub_a_old"])
+	assert.Equal(t, StatusChanged, d.Nodes["sub_a_billing"])
+	assert.Equal(t, "finance", d.Graph.Nodes["sub_a_billing"].Metadata["labels.team"], "after wins for shared nodes")
+
+	require.Len(t, d.Graph.Edges, 3)
+	assert.Equal(t, StatusAdded, d.Edges["sub_b_new|topic_a_orders|cross_project"])
+	assert.Equal(t, StatusRemoved, d.Edges["sub_a_old|topic_a_orders|subscribes"])
+	assert.Equal(t, StatusUnchanged, d.Edges["sub_a_billing|topic_a_orders|subscribes"])
+
+	nodes, edges := d.Count(StatusAdded)
+	assert.Equal(t, 1, nodes)
+	assert.Equal(t, 1, edges)
+	assert.Contains(t, d.Graph.Clusters, "b")
+}
+
+func TestMarkDiff(t *testing.T) {
+	before := New()
+	before.AddNode(&Node{ID: "topic_a_orders", Label: "orders", Type: NodeTypeTopic, Project: "a", Color: "gold"})
+	before.AddNode(&Node{ID: "sub_a_old", Label: "old", Type: NodeTypeSubscription, Project: "a"})
+	before.AddEdge(&Edge{From: "sub_a_old", To: "topic_a_orders", Type: EdgeTypeSubscribes})
+
+	after := New()
+	after.AddNode(&Node{ID: "topic_a_orders", Label: "orders", Type: NodeTypeTopic, Project: "a", Color: "gold"})
+	after.AddNode(&Node{ID: "sub_a_new", Label: "new", Type: NodeTypeSubscription, Project: "a", Metadata: map[string]string{}})
+	after.AddEdge(&Edge{From: "sub_a_new", To: "topic_a_orders", Type: EdgeTypeSubscribes})
+
+	d := Compare(before, after)
+	MarkDiff(d)
+
+	added, removed := d.Graph.Nodes["sub_a_new"], d.Graph.Nodes["sub_a_old"]
+	assert.Equal(t, "palegreen", added.Color)
+	assert.Equal(t, "added", added.Metadata[DiffKey])
+	assert.Equal(t, removedFill, removed.Color)
+	assert.Equal(t, removedBorder, removed.Border)
+	assert.Equal(t, "removed", removed.Metadata[DiffKey])
+	assert.Equal(t, "gold", d.Graph.Nodes["topic_a_orders"].Color, "unchanged nodes keep their color")
+	assert.NotContains(t, d.Graph.Nodes["topic_a_orders"].Metadata, DiffKey)
+
+	for _, edge := range d.Graph.Edges {
+		if edge.From == "sub_a_new" {
+			assert.Equal(t, "green", edge.Color)
+		} else {
+			assert.Equal(t, removedEdge, edge.Color)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package graph
+
+// Graph is the internal representation of collected resources and their connections
+type Graph struct {
+	Nodes    map[string]*Node
+	Edges    []*Edge
+	Clusters map[string]*Cluster // project clusters, keyed by project ID
+}
+
+// Node is a single resource in the graph
+type Node struct {
+	ID       string
+	Label    string
+	Type     NodeType
+	Project  string // Empty for resources that don't belong to a project (e.g. buckets)
+	Metadata map[string]string
+	Color    string // Fill color set by overlays, empty uses the default for the node type
+	Border   string // Outline color set by overlays, empty uses the default
+	Shape    string // ShapeBox, ShapeEllipse or ShapeDiamond set by style rules, empty uses the default
+	Count    int    // Resources a summary node made by Collapse stands for, zero for a single resource
+}
+
+// Edge is a directed connection between two nodes
+type Edge struct {
+	From  string
+	To    string
+	Label string
+	Type  EdgeType
+	Color string  // Line color set by overlays, empty uses the default for the edge type
+	Width float64 // Line width set by overlays, zero uses the default
+
+	// Inferred is set for edges derived from IAM bindings alone, which allow
+	// the access without it being seen in the audit logs
+	Inferred bool
+}
+
+// Cluster groups the nodes of a single project
+type Cluster struct {
+	ID    string
+	Label string
+	Nodes []string // node IDs
+}
+
+type NodeType string
+
+const (
+	NodeTypeTopic            NodeType = "topic"
+	NodeTypeSubscription     NodeType = "subscription"
+	NodeTypeBigQueryTable    NodeType = "bigquery_table"
+	NodeTypeStorageBucket    NodeType = "storage_bucket"
+	NodeTypeIdentity         NodeType = "identity"
+	NodeTypeCloudRunService  NodeType = "cloud_run_service"
+	NodeTypeCloudFunction    NodeType = "cloud_function"
+	NodeTypeDataflowJob      NodeType = "dataflow_job"
+	NodeTypeLiteTopic        NodeType = "pubsub_lite_topic"
+	NodeTypeLiteSubscription NodeType = "pubsub_lite_subscription"
+	NodeTypeProject          NodeType = "project" // a whole project, in summaries of large graphs
+)
+
+type EdgeType string
+
+const (
+	EdgeTypeSubscribes   EdgeType = "subscribes"
+	EdgeTypeCrossProject EdgeType = "cross_project"
+	EdgeTypeDelivers     EdgeType = "delivers"
+	EdgeTypeConsumes     EdgeType = "consumes"
+	EdgeTypeTriggers     EdgeType = "triggers"
+	EdgeTypeReads        EdgeType = "reads"
+	EdgeTypePublishes    EdgeType = "publishes"
+)
+
+// New creates an empty graph
+func New() *Graph {
+	return &Graph{
+		Nodes:    make(map[string]*Node),
+		Edges:    make([]*Edge, 0),
+		Clusters: make(map[string]*Cluster),
+	}
+}
+
+// AddNode adds a node to the graph and to its project cluster.
+// Adding a node with an ID that already exists is a no-op.
+func (g *Graph) AddNode(node *Node) {
+	if _, exists := g.Nodes[node.ID]; exists {
+		return
+	}
+	g.Nodes[node.ID] = node
+
+	if node.Project == "" {
+		return
+	}
+	if _, exists := g.Clusters[node.Project]; !exists {
+		g.Clusters[node.Project] = &Cluster{
+			ID:    "cluster_" + node.Project,
+			Label: node.Project,
+			Nodes: []string{},
+		}
+	}
+	g.Clusters[node.Project].Nodes = append(g.Clusters[node.Project].Nodes, node.ID)
+}
+
+// ClusterOf returns the key of the cluster holding each clustered node, by node ID
+func (g *Graph) ClusterOf() map[string]string {
+	keys := make(map[string]string, len(g.Nodes))
+	for key, cluster := range g.Clusters {
+		for _, id := range cluster.Nodes {
+			keys[id] = key
+		}
+	}
+	return keys
+}
+
+// AddEdge adds a directed edge to the graph
+func (g *Graph) AddEdge(edge *Edge) {
+	g.Edges = append(g.Edges, edge)
+}
@@ -0,0 +1,86 @@
+package graph
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/storage"
+)
+
+// LiteLocationKey is the node metadata key holding the region or zone of a Pub/Sub Lite resource
+const LiteLocationKey = "location"
+
+// addPubSubLite adds the Pub/Sub Lite topics and subscriptions among resources, each
+// subscription linked to its Lite topic and to the Pub/Sub topic it exports to. Edges
+// from resources that aren't Lite subscriptions in the graph are ignored.
+func addPubSubLite(g *Graph, resources []*storage.Resource, edges []*storage.ResourceEdge) {
+	nodeIDs := make(map[string]string) // by full resource name
+	for _, r := range resources {
+		var nodeType NodeType
+		var prefix string
+		switch r.Kind {
+		case storage.ResourceKindLiteTopic:
+			nodeType, prefix = NodeTypeLiteTopic, "lite_topic"
+		case storage.ResourceKindLiteSubscription:
+			nodeType, prefix = NodeTypeLiteSubscription, "lite_sub"
+		default:
+			continue
+		}
+		node := liteNode(r, nodeType, prefix)
+		g.AddNode(node)
+		nodeIDs[r.FullResourceName] = node.ID
+	}
+
+	for _, edge := range edges {
+		fromID, ok := nodeIDs[edge.Source]
+		if !ok || g.Nodes[fromID].Type != NodeTypeLiteSubscription {
+			continue
+		}
+		switch edge.Relation {
+		case storage.RelationSubscribes:
+			toID, ok := nodeIDs[edge.Target]
+			if !ok {
+				continue
+			}
+			g.AddEdge(&Edge{
+				From:  fromID,
+				To:    toID,
+				Type:  EdgeTypeSubscribes,
+				Label: "subscribes",
+			})
+		case storage.RelationExports:
+			topic := topicReferenceNode(edge.Target)
+			if topic == nil {
+				continue
+			}
+			g.AddNode(topic)
+			g.AddEdge(&Edge{
+				From:  fromID,
+				To:    topic.ID,
+				Type:  EdgeTypeDelivers,
+				Label: "exports to",
+			})
+		}
+	}
+}
+
+// liteNode creates the node for a Pub/Sub Lite topic or subscription, with the
+// fields of its stored metadata, such as its location and partitions
+func liteNode(r *storage.Resource, nodeType NodeType, prefix string) *Node {
+	metadata := map[string]string{
+		"full_resource_name": r.FullResourceName,
+	}
+	var stored map[string]interface{}
+	if r.Metadata != "" && json.Unmarshal([]byte(r.Metadata), &stored) == nil {
+		for k, v := range stored {
+			metadata[k] = fmt.Sprint(v)
+		}
+	}
+	return &Node{
+		ID:       fmt.Sprintf("%s_%s_%s_%s", prefix, r.ProjectID, metadata[LiteLocationKey], r.Name),
+		Label:    r.Name,
+		Type:     nodeType,
+		Project:  r.ProjectID,
+		Metadata: metadata,
+	}
+}
@@ -0,0 +1,139 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/storage"
+)
+
+// Reasons a resource is orphaned
+const (
+	OrphanNoSubscriptions = "no subscriptions" // topic nothing subscribes to
+	OrphanTopicDeleted    = "topic deleted"    // subscription detached from its deleted topic
+	OrphanTopicMissing    = "topic missing"    // subscription whose topic is gone from a scanned project
+)
+
+// OrphanKey is the node metadata key holding the reason a resource is orphaned
+const OrphanKey = "orphan"
+
+// orphanColors are the fill colors of orphaned nodes
+var orphanColors = map[NodeType]string{
+	NodeTypeTopic:        "lightgrey",
+	NodeTypeSubscription: "red",
+}
+
+// Orphan is a topic or subscription that no longer takes part in a flow
+type Orphan struct {
+	FullResourceName string
+	ProjectID        string
+	Type             NodeType // NodeTypeTopic or NodeTypeSubscription
+	Name             string
+	Topic            string // subscriptions only: full resource name of the topic
+	Reason           string // OrphanNoSubscriptions, OrphanTopicDeleted or OrphanTopicMissing
+}
+
+// FindOrphans returns the orphaned topics and subscriptions in the given projects,
+// sorted by full resource name. An empty projects slice includes every cached project.
+//
+// Subscriptions of every project are read, since a topic is in use as long as any
+// project subscribes to it. A topic only counts as missing if its project has been
+// scanned, otherwise the cache can't tell.
+func FindOrphans(ctx context.Context, store storage.Store, projects []string) ([]Orphan, error) {
+	subs, err := store.GetAllSubscriptions(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get subscriptions: %w", err)
+	}
+	topics, err := store.GetAllTopics(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get topics: %w", err)
+	}
+	// Completed scans, saving a resource alone also records its project
+	scans, err := store.GetProjectSyncHistory(ctx, time.Time{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get project sync history: %w", err)
+	}
+
+	included := func(project string) bool {
+		if len(projects) == 0 {
+			return true
+		}
+		for _, p := range projects {
+			if p == project {
+				return true
+			}
+		}
+		return false
+	}
+
+	cached := make(map[string]bool, len(topics))
+	for _, t := range topics {
+		cached[t.FullResourceName] = true
+	}
+	subscribed := make(map[string]bool)
+	var orphans []Orphan
+	for _, sub := range subs {
+		subscribed[sub.TopicFullResourceName] = true
+		if !included(sub.ProjectID) {
+			continue
+		}
+
+		reason := ""
+		topicProject, topicName := ParseTopicReference(sub.TopicFullResourceName)
+		switch {
+		case topicName == "":
+			reason = OrphanTopicDeleted
+		case !cached[sub.TopicFullResourceName]:
+			if len(scans[topicProject]) > 0 {
+				reason = OrphanTopicMissing
+			}
+		}
+		if reason != "" {
+			orphans = append(orphans, Orphan{
+				FullResourceName: sub.FullResourceName,
+				ProjectID:        sub.ProjectID,
+				Type:             NodeTypeSubscription,
+				Name:             sub.Name,
+				Topic:            sub.TopicFullResourceName,
+				Reason:           reason,
+			})
+		}
+	}
+
+	for _, t := range topics {
+		if !subscribed[t.FullResourceName] && included(t.ProjectID) {
+			orphans = append(orphans, Orphan{
+				FullResourceName: t.FullResourceName,
+				ProjectID:        t.ProjectID,
+				Type:             NodeTypeTopic,
+				Name:             t.Name,
+				Reason:           OrphanNoSubscriptions,
+			})
+		}
+	}
+
+	sort.Slice(orphans, func(i, j int) bool { return orphans[i].FullResourceName < orphans[j].FullResourceName })
+	return orphans, nil
+}
+
+// MarkOrphans records the reason of every orphan in the metadata of its node and
+// colors it: unused topics grey and subscriptions without a topic red.
+func MarkOrphans(g *Graph, orphans []Orphan) {
+	nodes := make(map[string]*Node, len(g.Nodes))
+	for _, node := range g.Nodes {
+		nodes[node.Metadata["full_resource_name"]] = node
+	}
+	for _, o := range orphans {
+		node, ok := nodes[o.FullResourceName]
+		if !ok || node.Type != o.Type {
+			continue
+		}
+		if node.Metadata == nil {
+			node.Metadata = make(map[string]string)
+		}
+		node.Metadata[OrphanKey] = o.Reason
+		node.Color = orphanColors[o.Type]
+	}
+}
@@ -0,0 +1,82 @@
+package graph
+
+import (
+	"context"
+	"testing"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindOrphans(t *testing.T) {
+	store := setupTestStore(t)
+	ctx := context.Background()
+
+	for _, topic := range []*storage.Topic{
+		{Name: "events", ProjectID: "project-a", FullResourceName: "projects/project-a/topics/events"},
+		{Name: "unused", ProjectID: "project-a", FullResourceName: "projects/project-a/topics/unused"},
+		{Name: "remote", ProjectID: "project-b", FullResourceName: "projects/project-b/topics/remote"},
+	} {
+		require.NoError(t, store.SaveTopic(ctx, topic))
+	}
+	for _, sub := range []*storage.Subscription{
+		// remote is only subscribed to from project-a
+		{Name: "remote-sub", ProjectID: "project-a", TopicFullResourceName: "projects/project-b/topics/remote", FullResourceName: "projects/project-a/subscriptions/remote-sub"},
+		{Name: "events-sub", ProjectID: "project-a", TopicFullResourceName: "projects/project-a/topics/events", FullResourceName: "projects/project-a/subscriptions/events-sub"},
+		{Name: "detached", ProjectID: "project-a", TopicFullResourceName: "_deleted-topic_", FullResourceName: "projects/project-a/subscriptions/detached"},
+		{Name: "stale", ProjectID: "project-a", TopicFullResourceName: "projects/project-a/topics/gone", FullResourceName: "projects/project-a/subscriptions/stale"},
+		// project-c was never scanned, so its topics may well exist
+		{Name: "unknown", ProjectID: "project-a", TopicFullResourceName: "projects/project-c/topics/elsewhere", FullResourceName: "projects/project-a/subscriptions/unknown"},
+	} {
+		require.NoError(t, store.SaveSubscription(ctx, sub))
+	}
+	require.NoError(t, store.UpdateProjectSyncTime(ctx, "project-a"))
+
+	orphans, err := FindOrphans(ctx, store, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []Orphan{
+		{FullResourceName: "projects/project-a/subscriptions/detached", ProjectID: "project-a", Type: NodeTypeSubscription, Name: "detached", Topic: "_deleted-topic_", Reason: OrphanTopicDeleted},
+		{FullResourceName: "projects/project-a/subscriptions/stale", ProjectID: "project-a", Type: NodeTypeSubscription, Name: "stale", Topic: "projects/project-a/topics/gone", Reason: OrphanTopicMissing},
+		{FullResourceName: "projects/project-a/topics/unused", ProjectID: "project-a", Type: NodeTypeTopic, Name: "unused", Reason: OrphanNoSubscriptions},
+	}, orphans)
+
+	// Subscriptions outside the filtered projects still count as usage
+	orphans, err = FindOrphans(ctx, store, []string{"project-b"})
+	require.NoError(t, err)
+	assert.Empty(t, orphans)
+
+}
+
+func TestMarkOrphans(t *testing.T) {
+	store := setupTestStore(t)
+	ctx := context.Background()
+	for _, topic := range []*storage.Topic{
+		{Name: "events", ProjectID: "project-a", FullResourceName: "projects/project-a/topics/events"},
+		{Name: "unused", ProjectID: "project-a", FullResourceName: "projects/project-a/topics/unused"},
+	} {
+		require.NoError(t, store.SaveTopic(ctx, topic))
+	}
+	for _, sub := range []*storage.Subscription{
+		{Name: "events-sub", ProjectID: "project-a", TopicFullResourceName: "projects/project-a/topics/events", FullResourceName: "projects/project-a/subscriptions/events-sub"},
+		{Name: "detached", ProjectID: "project-a", TopicFullResourceName: "_deleted-topic_", FullResourceName: "projects/project-a/subscriptions/detached"},
+	} {
+		require.NoError(t, store.SaveSubscription(ctx, sub))
+	}
+
+	g, err := NewBuilder(store).Build(ctx, nil)
+	require.NoError(t, err)
+	orphans, err := FindOrphans(ctx, store, nil)
+	require.NoError(t, err)
+	MarkOrphans(g, orphans)
+
+	unused := g.Nodes[TopicNodeID("project-a", "unused")]
+	assert.Equal(t, OrphanNoSubscriptions, unused.Metadata[OrphanKey])
+	assert.Equal(t, "lightgrey", unused.Color)
+	detached := g.Nodes[SubscriptionNodeID("project-a", "detached")]
+	assert.Equal(t, OrphanTopicDeleted, detached.Metadata[OrphanKey])
+	assert.Equal(t, "red", detached.Color)
+	events := g.Nodes[TopicNodeID("project-a", "events")]
+	assert.Empty(t, events.Color)
+	assert.NotContains(t, events.Metadata, OrphanKey)
+}
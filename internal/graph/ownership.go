@@ -0,0 +1,92 @@
+package graph
+
+import (
+	"path"
+	"sort"
+)
+
+// TeamKey is the node metadata key holding the team owning the node's project
+const TeamKey = "team"
+
+// teamClusterPrefix keys the clusters of GroupByTeam, apart from the project clusters
+const teamClusterPrefix = "team:"
+
+// Ownership assigns projects to the teams owning them
+type Ownership struct {
+	Teams    map[string][]string          // Team -> glob patterns of its projects, takes precedence over labels
+	LabelKey string                       // Project label holding the team
+	Labels   map[string]map[string]string // Project -> labels
+}
+
+// Team returns the team owning project, empty if it has none. A project matched by the
+// patterns of several teams belongs to the first of them by name.
+func (o *Ownership) Team(project string) string {
+	if project == "" {
+		return ""
+	}
+	teams := make([]string, 0, len(o.Teams))
+	for team := range o.Teams {
+		teams = append(teams, team)
+	}
+	sort.Strings(teams)
+	for _, team := range teams {
+		for _, pattern := range o.Teams[team] {
+			if ok, _ := path.Match(pattern, project); ok {
+				return team
+			}
+		}
+	}
+	if o.LabelKey != "" {
+		return o.Labels[project][o.LabelKey]
+	}
+	return ""
+}
+
+// Apply sets the team of every node in a project with an owner
+func (o *Ownership) Apply(g *Graph) {
+	teams := make(map[string]string)
+	for _, node := range g.Nodes {
+		team, ok := teams[node.Project]
+		if !ok {
+			team = o.Team(node.Project)
+			teams[node.Project] = team
+		}
+		if team == "" {
+			continue
+		}
+		if node.Metadata == nil {
+			node.Metadata = make(map[string]string)
+		}
+		node.Metadata[TeamKey] = team
+	}
+}
+
+// GroupByTeam replaces the project clusters of g with one cluster per team, holding
+// the nodes of every project the team owns. Nodes without a team keep their project cluster.
+// Apply must be called first.
+func GroupByTeam(g *Graph) {
+	ids := make([]string, 0, len(g.Nodes))
+	for id := range g.Nodes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	clusters := make(map[string]*Cluster)
+	for _, id := range ids {
+		node := g.Nodes[id]
+		key := node.Project
+		cluster, ok := g.Clusters[key]
+		if team := node.Metadata[TeamKey]; team != "" {
+			key = teamClusterPrefix + team
+			cluster, ok = &Cluster{ID: "cluster_team_" + team, Label: team}, true
+		}
+		if !ok {
+			continue
+		}
+		if _, exists := clusters[key]; !exists {
+			clusters[key] = &Cluster{ID: cluster.ID, Label: cluster.Label, Nodes: []string{}}
+		}
+		clusters[key].Nodes = append(clusters[key].Nodes, id)
+	}
+	g.Clusters = clusters
+}
@@ -0,0 +1,57 @@
+package graph
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOwnership_Team(t *testing.T) {
+	o := &Ownership{
+		Teams:    map[string][]string{"payments": {"payments-*"}, "checkout": {"payments-checkout"}},
+		LabelKey: "team",
+		Labels: map[string]map[string]string{
+			"payments-prod": {"team": "platform"},
+			"search-prod":   {"team": "search"},
+			"infra-prod":    {"env": "prod"},
+		},
+	}
+
+	// The mapping wins over labels, the first team by name over later ones
+	assert.Equal(t, "payments", o.Team("payments-prod"))
+	assert.Equal(t, "checkout", o.Team("payments-checkout"))
+	assert.Equal(t, "search", o.Team("search-prod"))
+	assert.Empty(t, o.Team("infra-prod"))
+	assert.Empty(t, o.Team(""))
+}
+
+func TestGroupByTeam(t *testing.T) {
+	g := New()
+	for _, node := range []*Node{
+		{ID: "t1", Type: NodeTypeTopic, Project: "payments-prod"},
+		{ID: "t2", Type: NodeTypeTopic, Project: "payments-dev"},
+		{ID: "s1", Type: NodeTypeSubscription, Project: "infra-prod"},
+		{ID: "sa", Type: NodeTypeIdentity},
+	} {
+		g.AddNode(node)
+	}
+	g.Clusters["infra-prod"].Label = "infra-prod (scan interrupted)"
+
+	(&Ownership{Teams: map[string][]string{"payments": {"payments-*"}}}).Apply(g)
+	assert.Equal(t, "payments", g.Nodes["t1"].Metadata[TeamKey])
+	assert.NotContains(t, g.Nodes["s1"].Metadata, TeamKey)
+
+	GroupByTeam(g)
+
+	require.Len(t, g.Clusters, 2)
+	assert.Equal(t, &Cluster{ID: "cluster_team_payments", Label: "payments", Nodes: []string{"t1", "t2"}}, g.Clusters[teamClusterPrefix+"payments"])
+	assert.Equal(t, &Cluster{ID: "cluster_infra-prod", Label: "infra-prod (scan interrupted)", Nodes: []string{"s1"}}, g.Clusters["infra-prod"])
+	assert.Equal(t, map[string]string{"t1": "team:payments", "t2": "team:payments", "s1": "infra-prod"}, g.ClusterOf())
+
+	// Project summaries keep the team and are grouped the same way
+	summary := SummarizeProjects(g)
+	GroupByTeam(summary)
+	assert.Equal(t, []string{"project_payments-dev", "project_payments-prod"}, summary.Clusters[teamClusterPrefix+"payments"].Nodes)
+	assert.Len(t, summary.Clusters, 1)
+}
@@ -0,0 +1,111 @@
+package graph
+
+import (
+	"path"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// StorageRegionsKey is the node metadata key holding the regions a topic's message storage
+// policy allows, comma-separated and sorted. It's missing if messages may be stored in any region.
+const StorageRegionsKey = "storage_regions"
+
+// RegionKey is the node metadata key holding the region of a Cloud Run service, function or Dataflow job
+const RegionKey = "region"
+
+// regionClusterPrefix keys the clusters of GroupByRegion, apart from the project clusters
+const regionClusterPrefix = "region:"
+
+// nodeRegions returns the regions a node's messages are stored or processed in, comma-separated,
+// empty if they aren't known. Subscriptions store their messages where their topic allows.
+func nodeRegions(g *Graph, node *Node) string {
+	switch node.Type {
+	case NodeTypeTopic:
+		return node.Metadata[StorageRegionsKey]
+	case NodeTypeSubscription:
+		project, name := ParseTopicReference(node.Metadata["topic"])
+		if topic, ok := g.Nodes[TopicNodeID(project, name)]; ok && name != "" {
+			return topic.Metadata[StorageRegionsKey]
+		}
+		return ""
+	case NodeTypeLiteTopic, NodeTypeLiteSubscription:
+		return node.Metadata[LiteLocationKey]
+	}
+	return node.Metadata[RegionKey]
+}
+
+// GroupByRegion replaces the project clusters of g with one cluster per set of regions,
+// holding the topics allowed to store messages in exactly those regions, their subscriptions
+// and the services, functions and jobs running there. Topics without a message storage policy,
+// and nodes without a region, keep their project cluster.
+func GroupByRegion(g *Graph) {
+	ids := make([]string, 0, len(g.Nodes))
+	for id := range g.Nodes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	clusters := make(map[string]*Cluster)
+	for _, id := range ids {
+		node := g.Nodes[id]
+		key := node.Project
+		cluster, ok := g.Clusters[key]
+		if regions := nodeRegions(g, node); regions != "" {
+			key = regionClusterPrefix + regions
+			cluster, ok = &Cluster{
+				ID:    "cluster_region_" + strings.ReplaceAll(regions, ",", "_"),
+				Label: strings.ReplaceAll(regions, ",", ", "),
+			}, true
+		}
+		if !ok {
+			continue
+		}
+		if _, exists := clusters[key]; !exists {
+			clusters[key] = &Cluster{ID: cluster.ID, Label: cluster.Label, Nodes: []string{}}
+		}
+		clusters[key].Nodes = append(clusters[key].Nodes, id)
+	}
+	g.Clusters = clusters
+}
+
+// Residency is a data-residency policy, the regions the topics of each project may store messages in
+type Residency struct {
+	AllowedRegions []string            // for the projects matching none of Projects
+	Projects       map[string][]string // glob pattern of projects -> their allowed regions
+}
+
+// Allowed returns the regions the topics of project may store messages in, empty if the policy
+// doesn't restrict them. A project matched by several patterns gets the regions of the first by name.
+func (r *Residency) Allowed(project string) []string {
+	patterns := make([]string, 0, len(r.Projects))
+	for pattern := range r.Projects {
+		patterns = append(patterns, pattern)
+	}
+	sort.Strings(patterns)
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, project); ok {
+			return r.Projects[pattern]
+		}
+	}
+	return r.AllowedRegions
+}
+
+// Check returns the regions a topic of project is allowed to store messages in that the policy
+// doesn't allow. ok is false if the topic violates the policy, which a topic without a message
+// storage policy does whenever its project is restricted, having no outside regions to return.
+func (r *Residency) Check(project string, storageRegions []string) (outside []string, ok bool) {
+	allowed := r.Allowed(project)
+	if len(allowed) == 0 {
+		return nil, true
+	}
+	if len(storageRegions) == 0 {
+		return nil, false
+	}
+	for _, region := range storageRegions {
+		if !slices.Contains(allowed, region) {
+			outside = append(outside, region)
+		}
+	}
+	return outside, len(outside) == 0
+}
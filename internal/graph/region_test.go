@@ -0,0 +1,56 @@
+package graph
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroupByRegion(t *testing.T) {
+	g := New()
+	for _, node := range []*Node{
+		{ID: "t1", Type: NodeTypeTopic, Project: "project-a", Metadata: map[string]string{StorageRegionsKey: "europe-west1,europe-west4"}},
+		{ID: TopicNodeID("project-a", "orders"), Type: NodeTypeTopic, Project: "project-a", Metadata: map[string]string{StorageRegionsKey: "europe-west1"}},
+		{ID: "t3", Type: NodeTypeTopic, Project: "project-b"},
+		{ID: "s1", Type: NodeTypeSubscription, Project: "project-b", Metadata: map[string]string{"topic": "projects/project-a/topics/orders"}},
+		{ID: "run", Type: NodeTypeCloudRunService, Project: "project-b", Metadata: map[string]string{RegionKey: "europe-west1"}},
+		{ID: "sa", Type: NodeTypeIdentity},
+	} {
+		g.AddNode(node)
+	}
+
+	GroupByRegion(g)
+
+	require.Len(t, g.Clusters, 3)
+	assert.Equal(t, &Cluster{ID: "cluster_region_europe-west1_europe-west4", Label: "europe-west1, europe-west4", Nodes: []string{"t1"}},
+		g.Clusters[regionClusterPrefix+"europe-west1,europe-west4"])
+	assert.Equal(t, []string{"run", "s1", TopicNodeID("project-a", "orders")}, g.Clusters[regionClusterPrefix+"europe-west1"].Nodes,
+		"subscriptions are stored where their topic allows")
+	assert.Equal(t, []string{"t3"}, g.Clusters["project-b"].Nodes, "unrestricted topics keep their project")
+}
+
+func TestResidency_Check(t *testing.T) {
+	r := &Residency{
+		AllowedRegions: []string{"europe-west1", "europe-west4"},
+		Projects:       map[string][]string{"us-*": {"us-central1"}, "sandbox-*": {}},
+	}
+
+	assert.Equal(t, []string{"us-central1"}, r.Allowed("us-payments"))
+	assert.Equal(t, []string{"europe-west1", "europe-west4"}, r.Allowed("payments"))
+
+	outside, ok := r.Check("payments", []string{"europe-west1"})
+	assert.True(t, ok)
+	assert.Empty(t, outside)
+
+	outside, ok = r.Check("payments", []string{"europe-west1", "us-east1"})
+	assert.False(t, ok)
+	assert.Equal(t, []string{"us-east1"}, outside)
+
+	outside, ok = r.Check("us-payments", nil)
+	assert.False(t, ok, "a topic storing messages in any region violates a restricted project")
+	assert.Empty(t, outside)
+
+	_, ok = r.Check("sandbox-1", nil)
+	assert.True(t, ok, "projects without allowed regions aren't restricted")
+}
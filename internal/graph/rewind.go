@@ -0,0 +1,78 @@
+package graph
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/storage"
+)
+
+// Rewind returns the graph g, built from the cache, as it was before changes, the changelog entries
+// since a point in time, oldest first. The changelog only records topics and subscriptions, so other
+// resources are left as they are: topics and subscriptions created since are dropped with their edges,
+// updated ones get their earlier settings back and removed ones return, a subscription without the
+// edge to its topic, which the changelog doesn't record.
+func Rewind(g *Graph, changes []*storage.Change) *Graph {
+	nodes := make(map[string]*Node, len(g.Nodes))
+	for id, node := range g.Nodes {
+		nodes[id] = node
+	}
+
+	for i := len(changes) - 1; i >= 0; i-- {
+		change := changes[i]
+		node := changedNode(change)
+		if node == nil {
+			continue
+		}
+		switch change.ChangeType {
+		case storage.ChangeTypeCreated:
+			delete(nodes, node.ID)
+		case storage.ChangeTypeUpdated, storage.ChangeTypeDeleted:
+			if current, ok := nodes[node.ID]; ok && current.Metadata["topic"] != "" {
+				node.Metadata["topic"] = current.Metadata["topic"]
+			}
+			node.Metadata = withStoredMetadata(node.Metadata, change.BeforeMetadata)
+			nodes[node.ID] = node
+		}
+	}
+
+	rewound := New()
+	ids := make([]string, 0, len(nodes))
+	for id := range nodes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		rewound.AddNode(nodes[id])
+	}
+	for project, cluster := range rewound.Clusters {
+		if original, ok := g.Clusters[project]; ok {
+			cluster.Label = original.Label
+		}
+	}
+	for _, edge := range g.Edges {
+		if nodes[edge.From] != nil && nodes[edge.To] != nil {
+			rewound.AddEdge(edge)
+		}
+	}
+	return rewound
+}
+
+// changedNode returns a node without stored metadata for the topic or subscription of a change,
+// nil for a malformed resource name
+func changedNode(change *storage.Change) *Node {
+	parts := strings.Split(change.FullResourceName, "/")
+	if len(parts) != 4 || parts[0] != "projects" {
+		return nil
+	}
+	project, name := parts[1], parts[3]
+	metadata := map[string]string{"full_resource_name": change.FullResourceName}
+
+	switch {
+	case change.ResourceType == storage.ResourceTypeTopic && parts[2] == "topics":
+		return &Node{ID: TopicNodeID(project, name), Label: name, Type: NodeTypeTopic, Project: project, Metadata: metadata}
+	case change.ResourceType == storage.ResourceTypeSubscription && parts[2] == "subscriptions":
+		return &Node{ID: SubscriptionNodeID(project, name), Label: name, Type: NodeTypeSubscription, Project: project, Metadata: metadata}
+	}
+	return nil
+}
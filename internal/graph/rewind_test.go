@@ -0,0 +1,47 @@
+package graph
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRewind(t *testing.T) {
+	store, err := storage.NewSQLite(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = store.Close() })
+
+	ctx := context.Background()
+	require.NoError(t, store.SaveTopic(ctx, &storage.Topic{Name: "orders", ProjectID: "a", FullResourceName: "projects/a/topics/orders", Metadata: `{"labels":{"team":"shop"}}`}))
+	require.NoError(t, store.SaveSubscription(ctx, &storage.Subscription{
+		Name: "email", ProjectID: "a", TopicFullResourceName: "projects/a/topics/orders", FullResourceName: "projects/a/subscriptions/email",
+	}))
+
+	// Changes after the first two, the state to rewind to
+	require.NoError(t, store.SaveTopic(ctx, &storage.Topic{Name: "orders", ProjectID: "a", FullResourceName: "projects/a/topics/orders", Metadata: `{"labels":{"team":"checkout"}}`}))
+	require.NoError(t, store.SaveSubscription(ctx, &storage.Subscription{
+		Name: "billing", ProjectID: "a", TopicFullResourceName: "projects/a/topics/orders", FullResourceName: "projects/a/subscriptions/billing",
+	}))
+	require.NoError(t, store.DeleteSubscription(ctx, "projects/a/subscriptions/email"))
+
+	current, err := NewBuilder(store).Build(ctx, nil)
+	require.NoError(t, err)
+	changes, err := store.GetChanges(ctx, time.Time{}, nil)
+	require.NoError(t, err)
+	require.Len(t, changes, 5)
+	changes = changes[2:]
+
+	g := Rewind(current, changes)
+	require.Len(t, g.Nodes, 2)
+	assert.Equal(t, "shop", g.Nodes["topic_a_orders"].Metadata["labels.team"], "updates are undone")
+	assert.Equal(t, "checkout", current.Nodes["topic_a_orders"].Metadata["labels.team"], "the current graph is left as it is")
+	require.Contains(t, g.Nodes, "sub_a_email", "removed resources return")
+	assert.Equal(t, NodeTypeSubscription, g.Nodes["sub_a_email"].Type)
+	assert.NotContains(t, g.Nodes, "sub_a_billing", "created resources are dropped")
+	assert.Empty(t, g.Edges, "the edges of created resources are dropped")
+	assert.Equal(t, []string{"sub_a_email", "topic_a_orders"}, g.Clusters["a"].Nodes)
+}
@@ -0,0 +1,25 @@
+package graph
+
+import "github.com/NissesSenap/gcp-visualizer/internal/storage"
+
+// projectStatusLabels describe the project statuses stored by scans in cluster labels
+var projectStatusLabels = map[string]string{
+	storage.ProjectStatusAPIDisabled: "Pub/Sub API disabled",
+	storage.ProjectStatusInterrupted: "scan interrupted",
+}
+
+// annotateProjectStatuses appends the status of each project to the label of its
+// cluster. Projects without nodes have no cluster and are left out of the graph.
+func annotateProjectStatuses(g *Graph, statuses map[string]string) {
+	for project, status := range statuses {
+		cluster, ok := g.Clusters[project]
+		if !ok {
+			continue
+		}
+		label, ok := projectStatusLabels[status]
+		if !ok {
+			label = status
+		}
+		cluster.Label = project + " (" + label + ")"
+	}
+}
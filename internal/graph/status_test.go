@@ -0,0 +1,39 @@
+package graph
+
+import (
+	"context"
+	"testing"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuild_ProjectStatuses(t *testing.T) {
+	store := setupTestStore(t)
+	ctx := context.Background()
+
+	require.NoError(t, store.SaveTopic(ctx, &storage.Topic{
+		Name:             "events",
+		ProjectID:        "project-a",
+		FullResourceName: "projects/project-a/topics/events",
+	}))
+	require.NoError(t, store.SaveSubscription(ctx, &storage.Subscription{
+		Name:                  "remote-sub",
+		ProjectID:             "project-b",
+		TopicFullResourceName: "projects/project-a/topics/events",
+		FullResourceName:      "projects/project-b/subscriptions/remote-sub",
+	}))
+	require.NoError(t, store.SetProjectStatus(ctx, "project-a", storage.ProjectStatusAPIDisabled))
+	require.NoError(t, store.SetProjectStatus(ctx, "project-c", storage.ProjectStatusAPIDisabled))
+
+	g, err := NewBuilder(store).Build(ctx, nil)
+	require.NoError(t, err)
+
+	require.Len(t, g.Clusters, 2, "projects without nodes get no cluster")
+	assert.Equal(t, "project-a (Pub/Sub API disabled)", g.Clusters["project-a"].Label)
+	assert.Equal(t, "project-b", g.Clusters["project-b"].Label)
+
+	sub := g.Subgraph(map[string]bool{TopicNodeID("project-a", "events"): true})
+	assert.Equal(t, "project-a (Pub/Sub API disabled)", sub.Clusters["project-a"].Label)
+}
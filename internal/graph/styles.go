@@ -0,0 +1,75 @@
+package graph
+
+import (
+	"fmt"
+	"path"
+)
+
+// Shapes a StyleRule can give nodes, drawn by every renderer
+const (
+	ShapeBox     = "box"
+	ShapeEllipse = "ellipse"
+	ShapeDiamond = "diamond"
+)
+
+// StyleRule styles the nodes matching all of its conditions, e.g. topics labeled domain=payments
+// or resources named "*-dlq". Empty conditions match every node, empty styles are left as they are.
+type StyleRule struct {
+	Type   NodeType
+	Name   string            // glob pattern of the resource name, path.Match syntax
+	Labels map[string]string // resource labels the node must have, all of them
+
+	Color  string // fill
+	Border string // outline
+	Shape  string // ShapeBox, ShapeEllipse or ShapeDiamond
+}
+
+// matches reports whether node meets every condition of the rule
+func (r *StyleRule) matches(node *Node) (bool, error) {
+	if r.Type != "" && node.Type != r.Type {
+		return false, nil
+	}
+	if r.Name != "" {
+		ok, err := path.Match(r.Name, node.Label)
+		if err != nil {
+			return false, fmt.Errorf("invalid style name pattern %q: %w", r.Name, err)
+		}
+		if !ok {
+			return false, nil
+		}
+	}
+	for key, value := range r.Labels {
+		if got, ok := node.Metadata[LabelPrefix+key]; !ok || got != value {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// ApplyStyles styles the nodes of g with every rule they match. Rules are applied in order,
+// so a later rule overrides the styles an earlier one set on the same node. Apply them before
+// overlays such as MarkOrphans, which take precedence.
+func ApplyStyles(g *Graph, rules []StyleRule) error {
+	for _, node := range g.Nodes {
+		for i := range rules {
+			rule := &rules[i]
+			ok, err := rule.matches(node)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				continue
+			}
+			if rule.Color != "" {
+				node.Color = rule.Color
+			}
+			if rule.Border != "" {
+				node.Border = rule.Border
+			}
+			if rule.Shape != "" {
+				node.Shape = rule.Shape
+			}
+		}
+	}
+	return nil
+}
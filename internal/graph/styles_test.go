@@ -0,0 +1,38 @@
+package graph
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyStyles(t *testing.T) {
+	g := New()
+	payments := &Node{ID: "t1", Label: "payments", Type: NodeTypeTopic, Metadata: map[string]string{LabelPrefix + "domain": "payments"}}
+	dlq := &Node{ID: "t2", Label: "payments-dlq", Type: NodeTypeTopic, Metadata: map[string]string{LabelPrefix + "domain": "payments"}}
+	sub := &Node{ID: "s1", Label: "orders-dlq", Type: NodeTypeSubscription, Metadata: map[string]string{}}
+	plain := &Node{ID: "t3", Label: "users", Type: NodeTypeTopic, Metadata: map[string]string{}}
+	for _, node := range []*Node{payments, dlq, sub, plain} {
+		g.AddNode(node)
+	}
+
+	require.NoError(t, ApplyStyles(g, []StyleRule{
+		{Type: NodeTypeTopic, Labels: map[string]string{"domain": "payments"}, Color: "green"},
+		{Name: "*-dlq", Shape: ShapeDiamond, Border: "red"},
+		{Type: NodeTypeTopic, Name: "*-dlq", Color: "grey"},
+	}))
+
+	assert.Equal(t, "green", payments.Color)
+	assert.Empty(t, payments.Shape)
+	// The later rule overrides the color, the shape of the earlier one stays
+	assert.Equal(t, "grey", dlq.Color)
+	assert.Equal(t, ShapeDiamond, dlq.Shape)
+	assert.Equal(t, "red", dlq.Border)
+	assert.Equal(t, ShapeDiamond, sub.Shape)
+	assert.Empty(t, sub.Color)
+	assert.Empty(t, plain.Color)
+	assert.Empty(t, plain.Shape)
+
+	assert.Error(t, ApplyStyles(g, []StyleRule{{Name: "["}}))
+}
@@ -0,0 +1,77 @@
+package graph
+
+import (
+	"fmt"
+	"sort"
+)
+
+// noProjectID is the ID of the summary node of the resources without a project, such as identities
+const noProjectID = "no_project"
+
+// SummarizeProjects returns a graph of the projects of g: a node per project counting its resources,
+// and an edge per pair of connected projects labelled with the number of edges between their resources
+// and widened by it, on the log scale of ScaleByTraffic. Resources without a project are summarized
+// in a node of their own, edges within a project are left out. Project nodes keep the team of their
+// resources, see Ownership.
+func SummarizeProjects(g *Graph) *Graph {
+	summary := New()
+	projectNode := func(project string) *Node {
+		id, label := "project_"+project, project
+		if project == "" {
+			id, label = noProjectID, "no project"
+		}
+		if node, ok := summary.Nodes[id]; ok {
+			return node
+		}
+		node := &Node{ID: id, Label: label, Type: NodeTypeProject, Metadata: map[string]string{}}
+		summary.AddNode(node)
+		return node
+	}
+
+	ids := make([]string, 0, len(g.Nodes))
+	for id := range g.Nodes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		node := projectNode(g.Nodes[id].Project)
+		node.Count++
+		if team := g.Nodes[id].Metadata[TeamKey]; team != "" {
+			node.Metadata[TeamKey] = team
+		}
+	}
+
+	type pair struct{ from, to string }
+	counts := make(map[pair]int)
+	var pairs []pair
+	for _, edge := range g.Edges {
+		from, okFrom := g.Nodes[edge.From]
+		to, okTo := g.Nodes[edge.To]
+		if !okFrom || !okTo || from.Project == to.Project {
+			continue
+		}
+		key := pair{projectNode(from.Project).ID, projectNode(to.Project).ID}
+		if counts[key] == 0 {
+			pairs = append(pairs, key)
+		}
+		counts[key]++
+	}
+	most := 0
+	for _, count := range counts {
+		most = max(most, count)
+	}
+	for _, key := range pairs {
+		label := "1 edge"
+		if counts[key] > 1 {
+			label = fmt.Sprintf("%d edges", counts[key])
+		}
+		summary.AddEdge(&Edge{
+			From:  key.from,
+			To:    key.to,
+			Type:  EdgeTypeCrossProject,
+			Label: label,
+			Width: minTrafficWidth + trafficLevel(float64(counts[key]), float64(most))*(maxTrafficWidth-minTrafficWidth),
+		})
+	}
+	return summary
+}
@@ -0,0 +1,42 @@
+package graph
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSummarizeProjects(t *testing.T) {
+	g := New()
+	for _, node := range []*Node{
+		{ID: "t1", Type: NodeTypeTopic, Project: "a"},
+		{ID: "t2", Type: NodeTypeTopic, Project: "a"},
+		{ID: "s1", Type: NodeTypeSubscription, Project: "a"},
+		{ID: "s2", Type: NodeTypeSubscription, Project: "b"},
+		{ID: "s3", Type: NodeTypeSubscription, Project: "b"},
+		{ID: "sa", Type: NodeTypeIdentity},
+	} {
+		g.AddNode(node)
+	}
+	g.AddEdge(&Edge{From: "s1", To: "t1", Type: EdgeTypeSubscribes})
+	g.AddEdge(&Edge{From: "s2", To: "t1", Type: EdgeTypeCrossProject})
+	g.AddEdge(&Edge{From: "s3", To: "t2", Type: EdgeTypeCrossProject})
+	g.AddEdge(&Edge{From: "sa", To: "s2", Type: EdgeTypeConsumes})
+
+	summary := SummarizeProjects(g)
+
+	require.Len(t, summary.Nodes, 3)
+	assert.Equal(t, 3, summary.Nodes["project_a"].Count)
+	assert.Equal(t, NodeTypeProject, summary.Nodes["project_a"].Type)
+	assert.Equal(t, 2, summary.Nodes["project_b"].Count)
+	assert.Equal(t, "no project", summary.Nodes[noProjectID].Label)
+	assert.Empty(t, summary.Clusters)
+
+	require.Len(t, summary.Edges, 2)
+	busiest, single := summary.Edges[0], summary.Edges[1]
+	assert.Equal(t, Edge{From: "project_b", To: "project_a", Type: EdgeTypeCrossProject, Label: "2 edges", Width: maxTrafficWidth}, *busiest)
+	assert.Equal(t, "1 edge", single.Label)
+	assert.Equal(t, noProjectID, single.From)
+	assert.InDelta(t, 3.52, single.Width, 0.01)
+}
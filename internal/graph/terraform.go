@@ -0,0 +1,80 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/storage"
+)
+
+// TerraformKey is the node metadata key holding the Terraform address of a managed topic or
+// subscription, or TerraformUnmanaged for one missing from the Terraform state
+const TerraformKey = "terraform"
+
+// TerraformUnmanaged marks topics and subscriptions created outside Terraform
+const TerraformUnmanaged = "unmanaged"
+
+// unmanagedBorder outlines topics and subscriptions created outside Terraform
+const unmanagedBorder = "magenta"
+
+// Unmanaged is a collected topic or subscription missing from the Terraform state, e.g. created in the console
+type Unmanaged struct {
+	FullResourceName string
+	ProjectID        string
+	Type             NodeType // NodeTypeTopic or NodeTypeSubscription
+	Name             string
+}
+
+// FindUnmanaged returns the topics and subscriptions in the given projects that aren't in managed,
+// the Terraform addresses by full resource name, sorted by full resource name. An empty projects
+// slice includes every cached project.
+func FindUnmanaged(ctx context.Context, store storage.Store, projects []string, managed map[string]string) ([]Unmanaged, error) {
+	topics, err := store.GetAllTopics(ctx, projects)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get topics: %w", err)
+	}
+	subs, err := store.GetAllSubscriptions(ctx, projects)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get subscriptions: %w", err)
+	}
+
+	var unmanaged []Unmanaged
+	for _, t := range topics {
+		if _, ok := managed[t.FullResourceName]; !ok {
+			unmanaged = append(unmanaged, Unmanaged{FullResourceName: t.FullResourceName, ProjectID: t.ProjectID, Type: NodeTypeTopic, Name: t.Name})
+		}
+	}
+	for _, sub := range subs {
+		if _, ok := managed[sub.FullResourceName]; !ok {
+			unmanaged = append(unmanaged, Unmanaged{FullResourceName: sub.FullResourceName, ProjectID: sub.ProjectID, Type: NodeTypeSubscription, Name: sub.Name})
+		}
+	}
+
+	sort.Slice(unmanaged, func(i, j int) bool { return unmanaged[i].FullResourceName < unmanaged[j].FullResourceName })
+	return unmanaged, nil
+}
+
+// MarkTerraform records the Terraform address of every managed topic and subscription in the metadata
+// of its node, and outlines the unmanaged ones in magenta
+func MarkTerraform(g *Graph, managed map[string]string, unmanaged []Unmanaged) {
+	nodes := make(map[string]*Node, len(g.Nodes))
+	for _, node := range g.Nodes {
+		if node.Type == NodeTypeTopic || node.Type == NodeTypeSubscription {
+			nodes[node.Metadata["full_resource_name"]] = node
+		}
+	}
+	for name, address := range managed {
+		if node, ok := nodes[name]; ok {
+			node.Metadata[TerraformKey] = address
+		}
+	}
+	for _, u := range unmanaged {
+		node, ok := nodes[u.FullResourceName]
+		if !ok || node.Type != u.Type {
+			continue
+		}
+		node.Metadata[TerraformKey] = TerraformUnmanaged
+		node.Border = unmanagedBorder
+	}
+}
@@ -0,0 +1,53 @@
+package graph
+
+import (
+	"context"
+	"testing"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindUnmanaged(t *testing.T) {
+	store := setupTestStore(t)
+	ctx := context.Background()
+	for _, topic := range []*storage.Topic{
+		{Name: "events", ProjectID: "project-a", FullResourceName: "projects/project-a/topics/events"},
+		{Name: "manual", ProjectID: "project-a", FullResourceName: "projects/project-a/topics/manual"},
+	} {
+		require.NoError(t, store.SaveTopic(ctx, topic))
+	}
+	for _, sub := range []*storage.Subscription{
+		{Name: "events-sub", ProjectID: "project-a", TopicFullResourceName: "projects/project-a/topics/events", FullResourceName: "projects/project-a/subscriptions/events-sub"},
+		{Name: "debug", ProjectID: "project-b", TopicFullResourceName: "projects/project-a/topics/events", FullResourceName: "projects/project-b/subscriptions/debug"},
+	} {
+		require.NoError(t, store.SaveSubscription(ctx, sub))
+	}
+	managed := map[string]string{
+		"projects/project-a/topics/events":            "google_pubsub_topic.events",
+		"projects/project-a/subscriptions/events-sub": "google_pubsub_subscription.events",
+	}
+
+	unmanaged, err := FindUnmanaged(ctx, store, nil, managed)
+	require.NoError(t, err)
+	assert.Equal(t, []Unmanaged{
+		{FullResourceName: "projects/project-a/topics/manual", ProjectID: "project-a", Type: NodeTypeTopic, Name: "manual"},
+		{FullResourceName: "projects/project-b/subscriptions/debug", ProjectID: "project-b", Type: NodeTypeSubscription, Name: "debug"},
+	}, unmanaged)
+
+	g, err := NewBuilder(store).Build(ctx, nil)
+	require.NoError(t, err)
+	MarkTerraform(g, managed, unmanaged)
+
+	events := g.Nodes[TopicNodeID("project-a", "events")]
+	assert.Equal(t, "google_pubsub_topic.events", events.Metadata[TerraformKey])
+	assert.Empty(t, events.Border)
+	manual := g.Nodes[TopicNodeID("project-a", "manual")]
+	assert.Equal(t, TerraformUnmanaged, manual.Metadata[TerraformKey])
+	assert.Equal(t, unmanagedBorder, manual.Border)
+
+	unmanaged, err = FindUnmanaged(ctx, store, []string{"project-a"}, managed)
+	require.NoError(t, err)
+	assert.Len(t, unmanaged, 1)
+}
@@ -0,0 +1,128 @@
+package graph
+
+import (
+	"math"
+	"strconv"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/storage"
+)
+
+// Node metadata keys holding the traffic read from Cloud Monitoring
+const (
+	PublishOperationsKey = "publish_operations" // publish calls to a topic over the metrics window
+	BacklogKey           = "backlog"            // highest count of undelivered messages of a subscription
+	UnackedAgeKey        = "oldest_unacked_age" // highest age of the oldest unacknowledged message of a subscription, in seconds
+)
+
+// metricKeys maps the stored metric types onto node metadata keys
+var metricKeys = map[string]string{
+	storage.MetricTopicPublishOperations: PublishOperationsKey,
+	storage.MetricSubscriptionBacklog:    BacklogKey,
+	storage.MetricSubscriptionUnackedAge: UnackedAgeKey,
+}
+
+// trafficPalette colors flows from the quietest to the busiest
+var trafficPalette = []string{"lightblue", "deepskyblue", "royalblue", "navy"}
+
+// Edge widths spanned by ScaleByTraffic
+const (
+	minTrafficWidth = 1.0
+	maxTrafficWidth = 5.0
+)
+
+// annotateMetrics sets the metadata of the nodes the metrics were read for.
+// Metrics of resources that are no longer in the graph are ignored.
+func annotateMetrics(g *Graph, metrics []*storage.ResourceMetric) {
+	byName := make(map[string]*Node)
+	for _, node := range g.Nodes {
+		if node.Type == NodeTypeTopic || node.Type == NodeTypeSubscription {
+			byName[node.Metadata["full_resource_name"]] = node
+		}
+	}
+	for _, metric := range metrics {
+		node, ok := byName[metric.FullResourceName]
+		key, known := metricKeys[metric.Metric]
+		if !ok || !known {
+			continue
+		}
+		node.Metadata[key] = strconv.FormatFloat(metric.Value, 'f', -1, 64)
+	}
+}
+
+// edgeTraffic returns the publish operations of the topic each edge carries messages of,
+// reached directly or through a subscription. Edges without a measured topic are left out.
+func edgeTraffic(g *Graph) map[*Edge]float64 {
+	published := func(id string) (float64, bool) {
+		node, ok := g.Nodes[id]
+		if !ok || node.Type != NodeTypeTopic {
+			return 0, false
+		}
+		value, err := strconv.ParseFloat(node.Metadata[PublishOperationsKey], 64)
+		return value, err == nil
+	}
+
+	// Subscriptions carry the traffic of their topic
+	subscriptions := make(map[string]float64)
+	for _, edge := range g.Edges {
+		if edge.Type != EdgeTypeSubscribes && edge.Type != EdgeTypeCrossProject {
+			continue
+		}
+		if value, ok := published(edge.To); ok {
+			subscriptions[edge.From] = value
+		}
+	}
+
+	traffic := make(map[*Edge]float64)
+	for _, edge := range g.Edges {
+		for _, id := range []string{edge.From, edge.To} {
+			value, ok := published(id)
+			if !ok {
+				value, ok = subscriptions[id]
+			}
+			if ok {
+				traffic[edge] = max(traffic[edge], value)
+			}
+		}
+	}
+	return traffic
+}
+
+// trafficLevel places value on a log scale from 0 for no traffic to 1 for busiest
+func trafficLevel(value, busiest float64) float64 {
+	if busiest <= 0 {
+		return 0
+	}
+	return math.Log1p(value) / math.Log1p(busiest)
+}
+
+// ScaleByTraffic widens edges by the publish operations of the topic whose messages they carry,
+// on a log scale relative to the busiest topic in the graph. Edges without metrics keep the default width.
+func ScaleByTraffic(g *Graph) {
+	traffic := edgeTraffic(g)
+	var busiest float64
+	for _, value := range traffic {
+		busiest = max(busiest, value)
+	}
+	for edge, value := range traffic {
+		edge.Width = minTrafficWidth + trafficLevel(value, busiest)*(maxTrafficWidth-minTrafficWidth)
+	}
+}
+
+// ColorByTraffic colors edges by the publish operations of the topic whose messages they carry,
+// on the same scale as ScaleByTraffic. Edges without metrics are grey.
+func ColorByTraffic(g *Graph) {
+	traffic := edgeTraffic(g)
+	var busiest float64
+	for _, value := range traffic {
+		busiest = max(busiest, value)
+	}
+	for _, edge := range g.Edges {
+		value, ok := traffic[edge]
+		if !ok {
+			edge.Color = "grey"
+			continue
+		}
+		level := trafficLevel(value, busiest)
+		edge.Color = trafficPalette[int(math.Round(level*float64(len(trafficPalette)-1)))]
+	}
+}
@@ -0,0 +1,86 @@
+package graph
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// trafficGraph builds a graph of a busy and a quiet topic, each with a subscription,
+// and an unmeasured topic
+func trafficGraph(t *testing.T) *Graph {
+	t.Helper()
+	store := setupTestStore(t)
+	ctx := context.Background()
+
+	for _, name := range []string{"busy", "quiet", "unmeasured"} {
+		require.NoError(t, store.SaveTopic(ctx, &storage.Topic{
+			Name:             name,
+			ProjectID:        "project-a",
+			FullResourceName: "projects/project-a/topics/" + name,
+		}))
+		require.NoError(t, store.SaveSubscription(ctx, &storage.Subscription{
+			Name:                  name + "-sub",
+			ProjectID:             "project-a",
+			TopicFullResourceName: "projects/project-a/topics/" + name,
+			FullResourceName:      "projects/project-a/subscriptions/" + name + "-sub",
+		}))
+	}
+	require.NoError(t, store.SaveMetrics(ctx, []*storage.ResourceMetric{
+		{FullResourceName: "projects/project-a/topics/busy", ProjectID: "project-a", Metric: storage.MetricTopicPublishOperations, Value: 100000, Window: time.Hour},
+		{FullResourceName: "projects/project-a/topics/quiet", ProjectID: "project-a", Metric: storage.MetricTopicPublishOperations, Value: 2, Window: time.Hour},
+		{FullResourceName: "projects/project-a/subscriptions/busy-sub", ProjectID: "project-a", Metric: storage.MetricSubscriptionBacklog, Value: 1500, Window: time.Hour},
+		// Resources gone since the metrics were read are ignored
+		{FullResourceName: "projects/project-a/topics/deleted", ProjectID: "project-a", Metric: storage.MetricTopicPublishOperations, Value: 7, Window: time.Hour},
+	}))
+
+	g, err := NewBuilder(store).Build(ctx, nil)
+	require.NoError(t, err)
+	return g
+}
+
+func TestBuild_Metrics(t *testing.T) {
+	g := trafficGraph(t)
+
+	assert.Equal(t, "100000", g.Nodes["topic_project-a_busy"].Metadata[PublishOperationsKey])
+	assert.Equal(t, "2", g.Nodes["topic_project-a_quiet"].Metadata[PublishOperationsKey])
+	assert.NotContains(t, g.Nodes["topic_project-a_unmeasured"].Metadata, PublishOperationsKey)
+	assert.Equal(t, "1500", g.Nodes["sub_project-a_busy-sub"].Metadata[BacklogKey])
+	assert.NotContains(t, g.Nodes, "topic_project-a_deleted")
+}
+
+// edgeFrom returns the edge leaving the node with the given ID
+func edgeFrom(t *testing.T, g *Graph, id string) *Edge {
+	t.Helper()
+	for _, edge := range g.Edges {
+		if edge.From == id {
+			return edge
+		}
+	}
+	t.Fatalf("no edge from %s", id)
+	return nil
+}
+
+func TestScaleByTraffic(t *testing.T) {
+	g := trafficGraph(t)
+	ScaleByTraffic(g)
+
+	assert.InDelta(t, maxTrafficWidth, edgeFrom(t, g, "sub_project-a_busy-sub").Width, 0.001)
+	quiet := edgeFrom(t, g, "sub_project-a_quiet-sub").Width
+	assert.Greater(t, quiet, minTrafficWidth)
+	assert.Less(t, quiet, 2.0)
+	assert.Zero(t, edgeFrom(t, g, "sub_project-a_unmeasured-sub").Width)
+}
+
+func TestColorByTraffic(t *testing.T) {
+	g := trafficGraph(t)
+	ColorByTraffic(g)
+
+	assert.Equal(t, "navy", edgeFrom(t, g, "sub_project-a_busy-sub").Color)
+	assert.Equal(t, "lightblue", edgeFrom(t, g, "sub_project-a_quiet-sub").Color)
+	assert.Equal(t, "grey", edgeFrom(t, g, "sub_project-a_unmeasured-sub").Color)
+}
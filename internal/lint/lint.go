@@ -0,0 +1,141 @@
+package lint
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/graph"
+)
+
+// Severity of a lint finding
+type Severity string
+
+const (
+	SeverityWarning Severity = "warning"
+	SeverityError   Severity = "error"
+)
+
+// Finding is a single rule violation on a graph node
+type Finding struct {
+	Rule     string   `json:"rule"`
+	Severity Severity `json:"severity"`
+	NodeID   string   `json:"node_id"`
+	Resource string   `json:"resource"` // full resource name, or the node ID of nodes without one
+	Message  string   `json:"message"`
+}
+
+// Descriptions of the built-in rules, by rule name
+var Descriptions = map[string]string{
+	RuleSensitiveConsumer: "Sensitive topics are only consumed from their own project or an allowed project",
+	RuleRetryPolicy:       "Subscription retry policies back off before redelivering failed messages",
+}
+
+// RuleSensitiveConsumer flags sensitive topics consumed from projects outside the allowed list
+const RuleSensitiveConsumer = "sensitive-topic-consumer"
+
+// SensitiveConsumers returns a finding for every subscription that consumes a sensitive
+// topic from a project that is neither the topic's own project nor in allowed.
+// The classifier must already have been applied to g.
+func SensitiveConsumers(g *graph.Graph, c *graph.Classifier, allowed []string) []Finding {
+	allowedSet := make(map[string]bool, len(allowed))
+	for _, p := range allowed {
+		allowedSet[p] = true
+	}
+
+	var findings []Finding
+	for _, edge := range g.Edges {
+		if edge.Type != graph.EdgeTypeSubscribes && edge.Type != graph.EdgeTypeCrossProject {
+			continue
+		}
+		sub, topic := g.Nodes[edge.From], g.Nodes[edge.To]
+		if sub == nil || topic == nil {
+			continue
+		}
+
+		level := topic.Metadata[graph.ClassificationKey]
+		if !c.IsSensitive(level) || sub.Project == topic.Project || allowedSet[sub.Project] {
+			continue
+		}
+
+		findings = append(findings, Finding{
+			Rule:     RuleSensitiveConsumer,
+			Severity: SeverityError,
+			NodeID:   sub.ID,
+			Resource: resource(sub),
+			Message: fmt.Sprintf("subscription %s in project %s consumes %s topic %s/%s, project is not in the allowed list",
+				sub.Label, sub.Project, level, topic.Project, topic.Label),
+		})
+	}
+
+	Sort(findings)
+	return findings
+}
+
+// RuleRetryPolicy flags subscriptions whose retry policy redelivers failed messages too aggressively
+const RuleRetryPolicy = "subscription-retry-policy"
+
+// RetryLimits are the lowest acceptable backoff bounds of a subscription retry policy
+type RetryLimits struct {
+	MinimumBackoff time.Duration // first redelivery
+	MaximumBackoff time.Duration // redeliveries once the backoff has grown
+	RequirePolicy  bool          // also flag subscriptions without a retry policy, which redeliver immediately
+}
+
+// DefaultRetryLimits flags policies retrying within a second, or never backing off beyond ten seconds
+var DefaultRetryLimits = RetryLimits{MinimumBackoff: time.Second, MaximumBackoff: 10 * time.Second}
+
+// RetryPolicies returns a finding for every subscription whose retry policy is missing
+// (if required) or has backoff bounds below limits
+func RetryPolicies(g *graph.Graph, limits RetryLimits) []Finding {
+	var findings []Finding
+	for _, node := range g.Nodes {
+		if node.Type != graph.NodeTypeSubscription {
+			continue
+		}
+		finding := func(format string, args ...any) {
+			findings = append(findings, Finding{
+				Rule:     RuleRetryPolicy,
+				Severity: SeverityWarning,
+				NodeID:   node.ID,
+				Resource: resource(node),
+				Message:  fmt.Sprintf("subscription %s in project %s ", node.Label, node.Project) + fmt.Sprintf(format, args...),
+			})
+		}
+
+		minimum, hasPolicy := node.Metadata[graph.RetryMinimumBackoffKey]
+		if !hasPolicy {
+			if limits.RequirePolicy {
+				finding("has no retry policy, failed messages are redelivered immediately")
+			}
+			continue
+		}
+		if d, err := time.ParseDuration(minimum); err == nil && d < limits.MinimumBackoff {
+			finding("retries after %s, below the minimum backoff of %s", d, limits.MinimumBackoff)
+		}
+		if d, err := time.ParseDuration(node.Metadata[graph.RetryMaximumBackoffKey]); err == nil && d < limits.MaximumBackoff {
+			finding("backs off at most %s, below the maximum backoff of %s", d, limits.MaximumBackoff)
+		}
+	}
+
+	Sort(findings)
+	return findings
+}
+
+// Sort orders findings by rule, then node ID
+func Sort(findings []Finding) {
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].Rule != findings[j].Rule {
+			return findings[i].Rule < findings[j].Rule
+		}
+		return findings[i].NodeID < findings[j].NodeID
+	})
+}
+
+// resource returns the full resource name of a node, or its ID if it has none
+func resource(node *graph.Node) string {
+	if name := node.Metadata["full_resource_name"]; name != "" {
+		return name
+	}
+	return node.ID
+}
@@ -0,0 +1,78 @@
+package lint
+
+import (
+	"testing"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/graph"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func classifiedGraph(level string) (*graph.Graph, *graph.Classifier) {
+	g := graph.New()
+	g.AddNode(&graph.Node{
+		ID: "topic_a_payments", Label: "payments", Type: graph.NodeTypeTopic, Project: "a",
+		Metadata: map[string]string{graph.LabelPrefix + "data_classification": level},
+	})
+	for _, project := range []string{"a", "b", "c"} {
+		id := "sub_" + project + "_payments"
+		g.AddNode(&graph.Node{ID: id, Label: "payments-" + project, Type: graph.NodeTypeSubscription, Project: project, Metadata: map[string]string{}})
+		g.AddEdge(&graph.Edge{From: id, To: "topic_a_payments", Type: graph.EdgeTypeCrossProject})
+	}
+
+	c := &graph.Classifier{
+		LabelKey:       "data_classification",
+		Levels:         []string{"public", "internal", "confidential", "restricted"},
+		SensitiveLevel: "confidential",
+	}
+	c.Apply(g)
+	return g, c
+}
+
+func TestSensitiveConsumers(t *testing.T) {
+	g, c := classifiedGraph("restricted")
+
+	findings := SensitiveConsumers(g, c, []string{"b"})
+	require.Len(t, findings, 1)
+	assert.Equal(t, RuleSensitiveConsumer, findings[0].Rule)
+	assert.Equal(t, SeverityError, findings[0].Severity)
+	assert.Equal(t, "sub_c_payments", findings[0].NodeID)
+	assert.Contains(t, findings[0].Message, "restricted")
+}
+
+func TestSensitiveConsumers_NotSensitive(t *testing.T) {
+	g, c := classifiedGraph("internal")
+	assert.Empty(t, SensitiveConsumers(g, c, nil))
+}
+
+func TestRetryPolicies(t *testing.T) {
+	g := graph.New()
+	subs := map[string]map[string]string{
+		"sub_a_default":    {graph.RetryMinimumBackoffKey: "10s", graph.RetryMaximumBackoffKey: "10m0s"},
+		"sub_a_hot":        {graph.RetryMinimumBackoffKey: "100ms", graph.RetryMaximumBackoffKey: "5s"},
+		"sub_a_unset":      {},
+		"sub_a_short_tail": {graph.RetryMinimumBackoffKey: "2s", graph.RetryMaximumBackoffKey: "2s"},
+	}
+	for id, metadata := range subs {
+		g.AddNode(&graph.Node{ID: id, Label: id, Type: graph.NodeTypeSubscription, Project: "a", Metadata: metadata})
+	}
+
+	findings := RetryPolicies(g, DefaultRetryLimits)
+	require.Len(t, findings, 3)
+	assert.Equal(t, "sub_a_hot", findings[0].NodeID)
+	assert.Contains(t, findings[0].Message, "retries after 100ms")
+	assert.Equal(t, "sub_a_hot", findings[1].NodeID)
+	assert.Contains(t, findings[1].Message, "backs off at most 5s")
+	assert.Equal(t, "sub_a_short_tail", findings[2].NodeID)
+	for _, f := range findings {
+		assert.Equal(t, RuleRetryPolicy, f.Rule)
+		assert.Equal(t, SeverityWarning, f.Severity)
+	}
+
+	limits := DefaultRetryLimits
+	limits.RequirePolicy = true
+	findings = RetryPolicies(g, limits)
+	require.Len(t, findings, 4)
+	assert.Equal(t, "sub_a_unset", findings[3].NodeID)
+	assert.Contains(t, findings[3].Message, "no retry policy")
+}
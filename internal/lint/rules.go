@@ -0,0 +1,50 @@
+package lint
+
+import (
+	"fmt"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/graph"
+	"github.com/NissesSenap/gcp-visualizer/internal/query"
+)
+
+// Rule is a configured rule: every node matching Where must also match Require
+type Rule struct {
+	Name     string
+	Message  string // describes a violation, empty reports the Require expression
+	Severity Severity
+	Where    *query.Expr // nil selects every node
+	Require  *query.Expr
+}
+
+// Rules returns a finding for every node matching the Where expression of a rule but not its Require expression
+func Rules(g *graph.Graph, rules []Rule) []Finding {
+	var findings []Finding
+	for _, rule := range rules {
+		selected := g
+		if rule.Where != nil {
+			selected = query.Filter(g, rule.Where)
+		}
+		// Require is evaluated on the whole graph, so edge-derived attributes such as fanout count every edge
+		passed := query.Filter(g, rule.Require)
+
+		message := rule.Message
+		if message == "" {
+			message = "does not match " + rule.Require.String()
+		}
+		for id, node := range selected.Nodes {
+			if _, ok := passed.Nodes[id]; ok {
+				continue
+			}
+			findings = append(findings, Finding{
+				Rule:     rule.Name,
+				Severity: rule.Severity,
+				NodeID:   id,
+				Resource: resource(node),
+				Message:  fmt.Sprintf("%s %s: %s", node.Type, node.Label, message),
+			})
+		}
+	}
+
+	Sort(findings)
+	return findings
+}
@@ -0,0 +1,47 @@
+package lint
+
+import (
+	"testing"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/graph"
+	"github.com/NissesSenap/gcp-visualizer/internal/query"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRules(t *testing.T) {
+	g := graph.New()
+	g.AddNode(&graph.Node{ID: "topic_prod_orders", Label: "orders", Type: graph.NodeTypeTopic, Project: "shop-prod",
+		Metadata: map[string]string{"full_resource_name": "projects/shop-prod/topics/orders", graph.SchemaKey: "projects/shop-prod/schemas/order"}})
+	g.AddNode(&graph.Node{ID: "topic_dev_orders", Label: "orders", Type: graph.NodeTypeTopic, Project: "shop-dev", Metadata: map[string]string{}})
+	g.AddNode(&graph.Node{ID: "sub_prod_email", Label: "email", Type: graph.NodeTypeSubscription, Project: "shop-prod",
+		Metadata: map[string]string{"full_resource_name": "projects/shop-prod/subscriptions/email", graph.PushEndpointKey: "https://mailer.example.com/push"}})
+	g.AddNode(&graph.Node{ID: "sub_prod_audit", Label: "audit", Type: graph.NodeTypeSubscription, Project: "shop-prod",
+		Metadata: map[string]string{graph.DeadLetterTopicKey: "projects/shop-prod/topics/dlq", graph.PushEndpointKey: "https://audit-abc.a.run.app/push"}})
+	g.AddNode(&graph.Node{ID: "sub_dev_email", Label: "email", Type: graph.NodeTypeSubscription, Project: "shop-dev", Metadata: map[string]string{}})
+
+	rules := []Rule{
+		{Name: "prod-dlq", Severity: SeverityError, Message: "production subscriptions need a dead-letter topic",
+			Where: mustParse(t, `type == "subscription" && project =~ ".*-prod"`), Require: mustParse(t, "has_dlq")},
+		{Name: "push-run-app", Severity: SeverityWarning,
+			Where: mustParse(t, "push_endpoint"), Require: mustParse(t, `push_endpoint =~ "https://[^/]+\\.run\\.app(/.*)?"`)},
+		{Name: "topic-schema", Severity: SeverityWarning, Where: mustParse(t, `type == "topic"`), Require: mustParse(t, "schema")},
+	}
+
+	findings := Rules(g, rules)
+	require.Len(t, findings, 3)
+	assert.Equal(t, Finding{Rule: "prod-dlq", Severity: SeverityError, NodeID: "sub_prod_email", Resource: "projects/shop-prod/subscriptions/email",
+		Message: "subscription email: production subscriptions need a dead-letter topic"}, findings[0])
+	assert.Equal(t, "push-run-app", findings[1].Rule)
+	assert.Equal(t, "sub_prod_email", findings[1].NodeID)
+	assert.Contains(t, findings[1].Message, `does not match push_endpoint =~`)
+	assert.Equal(t, "topic-schema", findings[2].Rule)
+	assert.Equal(t, "topic_dev_orders", findings[2].Resource, "nodes without a full resource name are reported by ID")
+}
+
+func mustParse(t *testing.T, src string) *query.Expr {
+	t.Helper()
+	expr, err := query.Parse(src)
+	require.NoError(t, err)
+	return expr
+}
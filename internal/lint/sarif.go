@@ -0,0 +1,120 @@
+package lint
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+)
+
+// SARIF 2.1.0, the static analysis format CI systems such as GitHub code scanning annotate from
+const (
+	sarifVersion  = "2.1.0"
+	sarifSchema   = "https://json.schemastore.org/sarif-2.1.0.json"
+	sarifToolName = "gcp-visualizer"
+)
+
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name    string      `json:"name"`
+	Version string      `json:"version,omitempty"`
+	Rules   []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription *sarifText   `json:"shortDescription,omitempty"`
+	DefaultConfig    *sarifConfig `json:"defaultConfiguration,omitempty"`
+}
+
+type sarifConfig struct {
+	Level string `json:"level"`
+}
+
+type sarifText struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifText       `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifLocation struct {
+	LogicalLocations []sarifLogicalLocation `json:"logicalLocations"`
+}
+
+type sarifLogicalLocation struct {
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+	Kind               string `json:"kind"`
+}
+
+// WriteSARIF writes findings as a SARIF 2.1.0 log of a single run, locating each finding by the full
+// resource name of its resource. descriptions describe the rules by name, version is the tool version.
+func WriteSARIF(w io.Writer, findings []Finding, descriptions map[string]string, version string) error {
+	levels := make(map[string]Severity)
+	results := make([]sarifResult, 0, len(findings))
+	for _, f := range findings {
+		if levels[f.Rule] != SeverityError {
+			levels[f.Rule] = f.Severity
+		}
+		results = append(results, sarifResult{
+			RuleID:  f.Rule,
+			Level:   string(f.Severity),
+			Message: sarifText{Text: f.Message},
+			Locations: []sarifLocation{{
+				LogicalLocations: []sarifLogicalLocation{{FullyQualifiedName: f.Resource, Kind: "resource"}},
+			}},
+		})
+	}
+
+	// Every described rule is listed, so a clean run still tells which rules were checked
+	for name := range descriptions {
+		if _, ok := levels[name]; !ok {
+			levels[name] = ""
+		}
+	}
+	names := make([]string, 0, len(levels))
+	for name := range levels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	rules := make([]sarifRule, 0, len(names))
+	for _, name := range names {
+		rule := sarifRule{ID: name}
+		if description := descriptions[name]; description != "" {
+			rule.ShortDescription = &sarifText{Text: description}
+		}
+		if level := levels[name]; level != "" {
+			rule.DefaultConfig = &sarifConfig{Level: string(level)}
+		}
+		rules = append(rules, rule)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(sarifLog{
+		Version: sarifVersion,
+		Schema:  sarifSchema,
+		Runs: []sarifRun{{
+			Tool:    sarifTool{Driver: sarifDriver{Name: sarifToolName, Version: version, Rules: rules}},
+			Results: results,
+		}},
+	})
+}
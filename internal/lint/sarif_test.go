@@ -0,0 +1,40 @@
+package lint
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteSARIF(t *testing.T) {
+	findings := []Finding{
+		{Rule: RuleRetryPolicy, Severity: SeverityWarning, NodeID: "sub_a_s", Resource: "projects/a/subscriptions/s", Message: "retries too fast"},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteSARIF(&buf, findings, Descriptions, "v1.2.3"))
+
+	var log sarifLog
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &log))
+	assert.Equal(t, "2.1.0", log.Version)
+	require.Len(t, log.Runs, 1)
+	run := log.Runs[0]
+	assert.Equal(t, "gcp-visualizer", run.Tool.Driver.Name)
+	assert.Equal(t, "v1.2.3", run.Tool.Driver.Version)
+
+	require.Len(t, run.Tool.Driver.Rules, 2, "described rules are listed without findings")
+	assert.Equal(t, RuleSensitiveConsumer, run.Tool.Driver.Rules[0].ID)
+	assert.Nil(t, run.Tool.Driver.Rules[0].DefaultConfig)
+	assert.Equal(t, RuleRetryPolicy, run.Tool.Driver.Rules[1].ID)
+	assert.Equal(t, "warning", run.Tool.Driver.Rules[1].DefaultConfig.Level)
+
+	require.Len(t, run.Results, 1)
+	result := run.Results[0]
+	assert.Equal(t, RuleRetryPolicy, result.RuleID)
+	assert.Equal(t, "warning", result.Level)
+	assert.Equal(t, "retries too fast", result.Message.Text)
+	assert.Equal(t, "projects/a/subscriptions/s", result.Locations[0].LogicalLocations[0].FullyQualifiedName)
+}
@@ -0,0 +1,133 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/storage"
+)
+
+// FreshnessLevel classifies how recently a project was synced
+type FreshnessLevel string
+
+const (
+	FreshnessFresh   FreshnessLevel = "fresh"   // synced within the cache TTL
+	FreshnessStale   FreshnessLevel = "stale"   // older than the TTL, within the max age
+	FreshnessExpired FreshnessLevel = "expired" // older than the max age
+	FreshnessNever   FreshnessLevel = "never"   // not synced yet
+)
+
+// Thresholds are the cache ages separating the freshness levels,
+// taken from cache.ttl_hours and cache.max_age_hours
+type Thresholds struct {
+	TTL    time.Duration
+	MaxAge time.Duration
+}
+
+// Level returns the freshness of a project last synced at lastSynced, as of now.
+// A zero lastSynced means the project was never synced.
+func (t Thresholds) Level(lastSynced, now time.Time) FreshnessLevel {
+	if lastSynced.IsZero() {
+		return FreshnessNever
+	}
+	switch age := now.Sub(lastSynced); {
+	case age <= t.TTL:
+		return FreshnessFresh
+	case age <= t.MaxAge:
+		return FreshnessStale
+	default:
+		return FreshnessExpired
+	}
+}
+
+// ProjectFreshness is the freshness history of one project
+type ProjectFreshness struct {
+	Project    string
+	LastSynced time.Time
+	Level      FreshnessLevel   // as of now
+	Days       []FreshnessLevel // as of the end of each day in Freshness.Days
+}
+
+// Freshness is a per-project, per-day heatmap of cache freshness, sorted by project
+type Freshness struct {
+	Thresholds  Thresholds
+	Days        []time.Time // start of each day, oldest first
+	Projects    []*ProjectFreshness
+	GeneratedAt time.Time
+}
+
+// CollectFreshness computes the freshness of the given projects over the last days days,
+// including today. An empty projects slice includes every cached project.
+// A project that only failed to sync shows up as aging, since only completed syncs are recorded.
+func CollectFreshness(ctx context.Context, store storage.Store, projects []string, days int, thresholds Thresholds, now time.Time) (*Freshness, error) {
+	days = max(days, 1)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	first := today.AddDate(0, 0, -(days - 1))
+
+	f := &Freshness{Thresholds: thresholds, GeneratedAt: now}
+	for d := 0; d < days; d++ {
+		f.Days = append(f.Days, first.AddDate(0, 0, d))
+	}
+
+	// Syncs from before the window still decide how fresh the first days were
+	history, err := store.GetProjectSyncHistory(ctx, first.Add(-thresholds.MaxAge))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get project sync history: %w", err)
+	}
+	latest, err := store.GetProjectSyncTimes(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get project sync times: %w", err)
+	}
+
+	included := make(map[string]bool, len(projects))
+	for _, id := range projects {
+		included[id] = true
+	}
+	ids := make(map[string]bool)
+	for id := range latest {
+		ids[id] = true
+	}
+	for id := range history {
+		ids[id] = true
+	}
+
+	for id := range ids {
+		if len(projects) > 0 && !included[id] {
+			continue
+		}
+
+		syncs := history[id]
+		if len(syncs) == 0 && !latest[id].IsZero() {
+			// Synced before the history began
+			syncs = []time.Time{latest[id]}
+		}
+
+		p := &ProjectFreshness{Project: id}
+		if len(syncs) > 0 {
+			p.LastSynced = syncs[len(syncs)-1]
+		}
+		p.Level = thresholds.Level(p.LastSynced, now)
+		for _, day := range f.Days {
+			end := day.AddDate(0, 0, 1)
+			if end.After(now) {
+				end = now
+			}
+			p.Days = append(p.Days, thresholds.Level(lastBefore(syncs, end), end))
+		}
+		f.Projects = append(f.Projects, p)
+	}
+
+	sort.Slice(f.Projects, func(i, j int) bool { return f.Projects[i].Project < f.Projects[j].Project })
+	return f, nil
+}
+
+// lastBefore returns the latest of the sorted times that is not after t, or the zero time
+func lastBefore(sorted []time.Time, t time.Time) time.Time {
+	i := sort.Search(len(sorted), func(i int) bool { return sorted[i].After(t) })
+	if i == 0 {
+		return time.Time{}
+	}
+	return sorted[i-1]
+}
@@ -0,0 +1,46 @@
+package metrics
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestThresholdsLevel(t *testing.T) {
+	th := Thresholds{TTL: time.Hour, MaxAge: 24 * time.Hour}
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, FreshnessNever, th.Level(time.Time{}, now))
+	assert.Equal(t, FreshnessFresh, th.Level(now.Add(-30*time.Minute), now))
+	assert.Equal(t, FreshnessStale, th.Level(now.Add(-2*time.Hour), now))
+	assert.Equal(t, FreshnessExpired, th.Level(now.Add(-48*time.Hour), now))
+}
+
+func TestLastBefore(t *testing.T) {
+	base := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	syncs := []time.Time{base, base.Add(time.Hour), base.Add(3 * time.Hour)}
+
+	assert.True(t, lastBefore(syncs, base.Add(-time.Minute)).IsZero())
+	assert.Equal(t, base.Add(time.Hour), lastBefore(syncs, base.Add(2*time.Hour)))
+	assert.Equal(t, base.Add(3*time.Hour), lastBefore(syncs, base.Add(3*time.Hour)))
+}
+
+func TestCollectFreshness(t *testing.T) {
+	store := setupTestStore(t)
+	ctx := context.Background()
+	require.NoError(t, store.UpdateProjectSyncTime(ctx, "project-a"))
+	now := time.Now()
+
+	f, err := CollectFreshness(ctx, store, []string{"project-a"}, 3, Thresholds{TTL: time.Hour, MaxAge: 24 * time.Hour}, now)
+	require.NoError(t, err)
+
+	require.Len(t, f.Days, 3)
+	require.Len(t, f.Projects, 1)
+	p := f.Projects[0]
+	assert.Equal(t, "project-a", p.Project)
+	assert.Equal(t, FreshnessFresh, p.Level)
+	assert.Equal(t, []FreshnessLevel{FreshnessNever, FreshnessNever, FreshnessFresh}, p.Days)
+}
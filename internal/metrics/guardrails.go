@@ -0,0 +1,86 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/send"
+)
+
+// Guardrails are inventory limits that catch runaway auto-created resources.
+// A zero limit is disabled.
+type Guardrails struct {
+	MaxTopicsPerProject        int
+	MaxSubscriptionsPerProject int
+	MaxGrowthPercent           float64 // growth of the total topic and subscription count since the last scan
+}
+
+// GuardrailWarning is a guardrail exceeded by a scan
+type GuardrailWarning struct {
+	Project string  `json:"project,omitempty"` // empty for inventory-wide warnings
+	Kind    string  `json:"kind"`              // "topics", "subscriptions" or "growth"
+	Value   float64 `json:"value"`
+	Limit   float64 `json:"limit"`
+}
+
+func (w GuardrailWarning) String() string {
+	switch w.Kind {
+	case "growth":
+		return fmt.Sprintf("inventory grew %.1f%% since the last scan (limit %g%%)", w.Value, w.Limit)
+	default:
+		return fmt.Sprintf("project %s has %g %s (limit %g)", w.Project, w.Value, w.Kind, w.Limit)
+	}
+}
+
+// Check compares the inventory after a scan with the one before it.
+// Growth is only checked when before holds any resources, a first scan always grows from nothing.
+func (g Guardrails) Check(before, after *Inventory) []GuardrailWarning {
+	var warnings []GuardrailWarning
+	for _, p := range after.Projects {
+		if g.MaxTopicsPerProject > 0 && p.Topics > g.MaxTopicsPerProject {
+			warnings = append(warnings, GuardrailWarning{
+				Project: p.Project, Kind: "topics",
+				Value: float64(p.Topics), Limit: float64(g.MaxTopicsPerProject),
+			})
+		}
+		if g.MaxSubscriptionsPerProject > 0 && p.Subscriptions > g.MaxSubscriptionsPerProject {
+			warnings = append(warnings, GuardrailWarning{
+				Project: p.Project, Kind: "subscriptions",
+				Value: float64(p.Subscriptions), Limit: float64(g.MaxSubscriptionsPerProject),
+			})
+		}
+	}
+
+	if g.MaxGrowthPercent > 0 && before != nil {
+		previous, current := before.resourceCount(), after.resourceCount()
+		if previous > 0 {
+			growth := float64(current-previous) / float64(previous) * 100
+			if growth > g.MaxGrowthPercent {
+				warnings = append(warnings, GuardrailWarning{Kind: "growth", Value: growth, Limit: g.MaxGrowthPercent})
+			}
+		}
+	}
+	return warnings
+}
+
+// resourceCount returns the number of topics and subscriptions in the inventory
+func (inv *Inventory) resourceCount() int {
+	var n int
+	for _, p := range inv.Projects {
+		n += p.Topics + p.Subscriptions
+	}
+	return n
+}
+
+// NotifyGuardrails posts the warnings as JSON to a webhook URL
+func NotifyGuardrails(ctx context.Context, url string, warnings []GuardrailWarning, now time.Time) error {
+	err := send.JSON(ctx, url, struct {
+		Warnings []GuardrailWarning `json:"warnings"`
+		Time     time.Time          `json:"time"`
+	}{warnings, now}, nil)
+	if err != nil {
+		return fmt.Errorf("failed to notify guardrail warnings: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGuardrails_Check(t *testing.T) {
+	before := &Inventory{Projects: []*ProjectInventory{
+		{Project: "project-a", Topics: 5, Subscriptions: 5},
+	}}
+	after := &Inventory{Projects: []*ProjectInventory{
+		{Project: "project-a", Topics: 5, Subscriptions: 8},
+		{Project: "project-b", Topics: 12, Subscriptions: 1},
+	}}
+
+	g := Guardrails{MaxTopicsPerProject: 10, MaxSubscriptionsPerProject: 7, MaxGrowthPercent: 20}
+	warnings := g.Check(before, after)
+	require.Len(t, warnings, 3)
+
+	assert.Equal(t, GuardrailWarning{Project: "project-a", Kind: "subscriptions", Value: 8, Limit: 7}, warnings[0])
+	assert.Equal(t, GuardrailWarning{Project: "project-b", Kind: "topics", Value: 12, Limit: 10}, warnings[1])
+	assert.Equal(t, "growth", warnings[2].Kind)
+	assert.InDelta(t, 160, warnings[2].Value, 0.01)
+	assert.Equal(t, "inventory grew 160.0% since the last scan (limit 20%)", warnings[2].String())
+	assert.Equal(t, "project project-b has 12 topics (limit 10)", warnings[1].String())
+}
+
+func TestGuardrails_CheckDisabledAndFirstScan(t *testing.T) {
+	after := &Inventory{Projects: []*ProjectInventory{{Project: "project-a", Topics: 5000, Subscriptions: 5000}}}
+
+	assert.Empty(t, Guardrails{}.Check(nil, after), "zero limits are disabled")
+	assert.Empty(t, Guardrails{MaxGrowthPercent: 20}.Check(&Inventory{}, after), "no growth check from an empty cache")
+}
+
+func TestNotifyGuardrails(t *testing.T) {
+	var got struct {
+		Warnings []GuardrailWarning `json:"warnings"`
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+	}))
+	defer srv.Close()
+
+	warnings := []GuardrailWarning{{Project: "project-a", Kind: "subscriptions", Value: 8, Limit: 7}}
+	require.NoError(t, NotifyGuardrails(context.Background(), srv.URL, warnings, time.Now()))
+	assert.Equal(t, warnings, got.Warnings)
+}
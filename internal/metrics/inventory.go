@@ -0,0 +1,200 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/graph"
+	"github.com/NissesSenap/gcp-visualizer/internal/storage"
+)
+
+// ProjectInventory holds the inventory counts of a single project
+type ProjectInventory struct {
+	Project                 string
+	Topics                  int
+	Subscriptions           int
+	SubscriptionsWithoutDLQ int
+	CrossProjectEdges       int // subscriptions in this project reading a topic in another project
+	LastSynced              time.Time
+	Status                  string // why the last scan couldn't collect the project, e.g. storage.ProjectStatusAPIDisabled
+}
+
+// Inventory is a snapshot of the cached inventory, sorted by project
+type Inventory struct {
+	Projects    []*ProjectInventory
+	GeneratedAt time.Time
+}
+
+// Collect computes an inventory snapshot of the given projects.
+// An empty projects slice includes every cached project.
+func Collect(ctx context.Context, store storage.Store, projects []string, now time.Time) (*Inventory, error) {
+	byProject := make(map[string]*ProjectInventory)
+	project := func(id string) *ProjectInventory {
+		p, ok := byProject[id]
+		if !ok {
+			p = &ProjectInventory{Project: id}
+			byProject[id] = p
+		}
+		return p
+	}
+
+	syncTimes, err := store.GetProjectSyncTimes(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get project sync times: %w", err)
+	}
+	included := make(map[string]bool, len(projects))
+	for _, id := range projects {
+		included[id] = true
+	}
+	for id, lastSynced := range syncTimes {
+		if len(projects) == 0 || included[id] {
+			project(id).LastSynced = lastSynced
+		}
+	}
+	statuses, err := store.GetProjectStatuses(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get project statuses: %w", err)
+	}
+	for id, status := range statuses {
+		if len(projects) == 0 || included[id] {
+			project(id).Status = status
+		}
+	}
+
+	topics, err := store.GetAllTopics(ctx, projects)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get topics: %w", err)
+	}
+	for _, topic := range topics {
+		project(topic.ProjectID).Topics++
+	}
+
+	subs, err := store.GetAllSubscriptions(ctx, projects)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get subscriptions: %w", err)
+	}
+	for _, sub := range subs {
+		p := project(sub.ProjectID)
+		p.Subscriptions++
+		if !hasDeadLetterTopic(sub.Metadata) {
+			p.SubscriptionsWithoutDLQ++
+		}
+	}
+
+	g, err := graph.NewBuilder(store).Build(ctx, projects)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build graph: %w", err)
+	}
+	for _, edge := range g.Edges {
+		if edge.Type == graph.EdgeTypeCrossProject {
+			project(g.Nodes[edge.From].Project).CrossProjectEdges++
+		}
+	}
+
+	inv := &Inventory{GeneratedAt: now}
+	for _, p := range byProject {
+		inv.Projects = append(inv.Projects, p)
+	}
+	sort.Slice(inv.Projects, func(i, j int) bool {
+		return inv.Projects[i].Project < inv.Projects[j].Project
+	})
+	return inv, nil
+}
+
+// hasDeadLetterTopic reports whether stored subscription metadata names a dead-letter topic
+func hasDeadLetterTopic(raw string) bool {
+	var metadata struct {
+		DeadLetterTopic string `json:"dead_letter_topic"`
+	}
+	if err := json.Unmarshal([]byte(raw), &metadata); err != nil {
+		return false
+	}
+	return metadata.DeadLetterTopic != ""
+}
+
+// gauge describes one metric family of the Prometheus snapshot
+type gauge struct {
+	name  string
+	help  string
+	value func(p *ProjectInventory, now time.Time) (float64, bool)
+}
+
+var gauges = []gauge{
+	{
+		name: "gcp_visualizer_topics",
+		help: "Number of cached Pub/Sub topics.",
+		value: func(p *ProjectInventory, _ time.Time) (float64, bool) {
+			return float64(p.Topics), true
+		},
+	},
+	{
+		name: "gcp_visualizer_subscriptions",
+		help: "Number of cached Pub/Sub subscriptions.",
+		value: func(p *ProjectInventory, _ time.Time) (float64, bool) {
+			return float64(p.Subscriptions), true
+		},
+	},
+	{
+		name: "gcp_visualizer_subscriptions_without_dlq",
+		help: "Number of cached subscriptions without a dead-letter topic.",
+		value: func(p *ProjectInventory, _ time.Time) (float64, bool) {
+			return float64(p.SubscriptionsWithoutDLQ), true
+		},
+	},
+	{
+		name: "gcp_visualizer_cross_project_edges",
+		help: "Number of subscriptions reading a topic in another project.",
+		value: func(p *ProjectInventory, _ time.Time) (float64, bool) {
+			return float64(p.CrossProjectEdges), true
+		},
+	},
+	{
+		name: "gcp_visualizer_project_api_disabled",
+		help: "1 if the Pub/Sub API was disabled in the project at its last scan.",
+		value: func(p *ProjectInventory, _ time.Time) (float64, bool) {
+			if p.Status == storage.ProjectStatusAPIDisabled {
+				return 1, true
+			}
+			return 0, true
+		},
+	},
+	{
+		name: "gcp_visualizer_cache_age_seconds",
+		help: "Seconds since the project was last synced into the cache.",
+		value: func(p *ProjectInventory, now time.Time) (float64, bool) {
+			if p.LastSynced.IsZero() {
+				return 0, false
+			}
+			return now.Sub(p.LastSynced).Seconds(), true
+		},
+	},
+}
+
+// WritePrometheus writes the inventory as gauges in the Prometheus text exposition format
+func (inv *Inventory) WritePrometheus(w io.Writer) error {
+	var b strings.Builder
+	for _, g := range gauges {
+		fmt.Fprintf(&b, "# HELP %s %s\n", g.name, g.help)
+		fmt.Fprintf(&b, "# TYPE %s gauge\n", g.name)
+		for _, p := range inv.Projects {
+			value, ok := g.value(p, inv.GeneratedAt)
+			if !ok {
+				continue
+			}
+			fmt.Fprintf(&b, "%s{project=\"%s\"} %g\n", g.name, escapeLabelValue(p.Project), value)
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// escapeLabelValue escapes a label value for the Prometheus text format
+func escapeLabelValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
@@ -0,0 +1,92 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupTestStore(t *testing.T) storage.Store {
+	store, err := storage.NewSQLite(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = store.Close() })
+
+	ctx := context.Background()
+	require.NoError(t, store.SaveTopic(ctx, &storage.Topic{
+		Name:             "orders",
+		ProjectID:        "project-a",
+		FullResourceName: "projects/project-a/topics/orders",
+	}))
+	require.NoError(t, store.SaveSubscription(ctx, &storage.Subscription{
+		Name:                  "orders-billing",
+		ProjectID:             "project-a",
+		TopicFullResourceName: "projects/project-a/topics/orders",
+		FullResourceName:      "projects/project-a/subscriptions/orders-billing",
+		Metadata:              `{"labels":{},"dead_letter_topic":"projects/project-a/topics/orders-dlq"}`,
+	}))
+	require.NoError(t, store.SaveSubscription(ctx, &storage.Subscription{
+		Name:                  "orders-email",
+		ProjectID:             "project-b",
+		TopicFullResourceName: "projects/project-a/topics/orders",
+		FullResourceName:      "projects/project-b/subscriptions/orders-email",
+		Metadata:              `{"labels":{}}`,
+	}))
+	return store
+}
+
+func TestCollect(t *testing.T) {
+	store := setupTestStore(t)
+
+	inv, err := Collect(context.Background(), store, nil, time.Now())
+	require.NoError(t, err)
+	require.Len(t, inv.Projects, 2)
+
+	a, b := inv.Projects[0], inv.Projects[1]
+	assert.Equal(t, "project-a", a.Project)
+	assert.Equal(t, 1, a.Topics)
+	assert.Equal(t, 1, a.Subscriptions)
+	assert.Equal(t, 0, a.SubscriptionsWithoutDLQ)
+	assert.Equal(t, 0, a.CrossProjectEdges)
+	assert.False(t, a.LastSynced.IsZero())
+
+	assert.Equal(t, "project-b", b.Project)
+	assert.Equal(t, 0, b.Topics)
+	assert.Equal(t, 1, b.SubscriptionsWithoutDLQ)
+	assert.Equal(t, 1, b.CrossProjectEdges)
+}
+
+func TestCollectFiltersProjects(t *testing.T) {
+	store := setupTestStore(t)
+
+	inv, err := Collect(context.Background(), store, []string{"project-b"}, time.Now())
+	require.NoError(t, err)
+	require.Len(t, inv.Projects, 1)
+	assert.Equal(t, "project-b", inv.Projects[0].Project)
+}
+
+func TestWritePrometheus(t *testing.T) {
+	synced := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	inv := &Inventory{
+		GeneratedAt: synced.Add(90 * time.Second),
+		Projects: []*ProjectInventory{
+			{Project: "project-a", Topics: 3, Subscriptions: 2, SubscriptionsWithoutDLQ: 1, LastSynced: synced},
+			{Project: `odd"project`, CrossProjectEdges: 4},
+		},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, inv.WritePrometheus(&buf))
+	out := buf.String()
+
+	assert.Contains(t, out, "# TYPE gcp_visualizer_topics gauge\n")
+	assert.Contains(t, out, `gcp_visualizer_topics{project="project-a"} 3`+"\n")
+	assert.Contains(t, out, `gcp_visualizer_subscriptions_without_dlq{project="project-a"} 1`+"\n")
+	assert.Contains(t, out, `gcp_visualizer_cross_project_edges{project="odd\"project"} 4`+"\n")
+	assert.Contains(t, out, `gcp_visualizer_cache_age_seconds{project="project-a"} 90`+"\n")
+	assert.NotContains(t, out, `gcp_visualizer_cache_age_seconds{project="odd\"project"}`, "never synced projects have no cache age")
+}
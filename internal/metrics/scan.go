@@ -0,0 +1,181 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/send"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// PushJob is the Pushgateway job name scan metrics are pushed under
+const PushJob = "gcp-visualizer"
+
+// ScanMetrics records what a scan did, for scraping while it runs or pushing
+// to a Pushgateway when it's done. It is safe for concurrent use.
+type ScanMetrics struct {
+	mu       sync.Mutex
+	requests map[apiRequest]int
+	projects map[string]*projectScan
+}
+
+type apiRequest struct {
+	method string
+	code   string
+}
+
+type projectScan struct {
+	duration time.Duration
+	finished bool
+	success  bool
+	stored   map[string]int // resource kind to count
+}
+
+// NewScanMetrics creates an empty recorder
+func NewScanMetrics() *ScanMetrics {
+	return &ScanMetrics{
+		requests: make(map[apiRequest]int),
+		projects: make(map[string]*projectScan),
+	}
+}
+
+// project returns the scan of a project, m.mu must be held
+func (m *ScanMetrics) project(id string) *projectScan {
+	p, ok := m.projects[id]
+	if !ok {
+		p = &projectScan{stored: make(map[string]int)}
+		m.projects[id] = p
+	}
+	return p
+}
+
+// ObserveRequest counts one API request attempt. Retried requests count once per attempt,
+// so retries show up as requests with a retryable code such as Unavailable.
+func (m *ScanMetrics) ObserveRequest(method, code string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests[apiRequest{method, code}]++
+}
+
+// ObserveProject records the outcome of collecting a project
+func (m *ScanMetrics) ObserveProject(projectID string, duration time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	p := m.project(projectID)
+	p.duration = duration
+	p.finished = true
+	p.success = err == nil
+}
+
+// AddStored counts resources written to the cache
+func (m *ScanMetrics) AddStored(projectID, kind string, n int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.project(projectID).stored[kind] += n
+}
+
+// UnaryClientInterceptor counts every gRPC request attempt by method and status code
+func (m *ScanMetrics) UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		m.ObserveRequest(method[strings.LastIndex(method, "/")+1:], status.Code(err).String())
+		return err
+	}
+}
+
+// WritePrometheus writes the metrics in the Prometheus text exposition format
+func (m *ScanMetrics) WritePrometheus(w io.Writer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var b strings.Builder
+
+	requests := make([]apiRequest, 0, len(m.requests))
+	for r := range m.requests {
+		requests = append(requests, r)
+	}
+	sort.Slice(requests, func(i, j int) bool {
+		if requests[i].method != requests[j].method {
+			return requests[i].method < requests[j].method
+		}
+		return requests[i].code < requests[j].code
+	})
+	b.WriteString("# HELP gcp_visualizer_api_requests_total Pub/Sub API request attempts by method and status code.\n")
+	b.WriteString("# TYPE gcp_visualizer_api_requests_total counter\n")
+	for _, r := range requests {
+		fmt.Fprintf(&b, "gcp_visualizer_api_requests_total{method=\"%s\",code=\"%s\"} %d\n",
+			escapeLabelValue(r.method), escapeLabelValue(r.code), m.requests[r])
+	}
+
+	projects := make([]string, 0, len(m.projects))
+	for id := range m.projects {
+		projects = append(projects, id)
+	}
+	sort.Strings(projects)
+
+	b.WriteString("# HELP gcp_visualizer_scan_duration_seconds Duration of the last collection of the project.\n")
+	b.WriteString("# TYPE gcp_visualizer_scan_duration_seconds gauge\n")
+	for _, id := range projects {
+		if p := m.projects[id]; p.finished {
+			fmt.Fprintf(&b, "gcp_visualizer_scan_duration_seconds{project=\"%s\"} %g\n", escapeLabelValue(id), p.duration.Seconds())
+		}
+	}
+
+	b.WriteString("# HELP gcp_visualizer_scan_success Whether the last collection of the project succeeded.\n")
+	b.WriteString("# TYPE gcp_visualizer_scan_success gauge\n")
+	for _, id := range projects {
+		if p := m.projects[id]; p.finished {
+			success := 0
+			if p.success {
+				success = 1
+			}
+			fmt.Fprintf(&b, "gcp_visualizer_scan_success{project=\"%s\"} %d\n", escapeLabelValue(id), success)
+		}
+	}
+
+	b.WriteString("# HELP gcp_visualizer_resources_stored_total Resources written to the cache by kind.\n")
+	b.WriteString("# TYPE gcp_visualizer_resources_stored_total counter\n")
+	for _, id := range projects {
+		p := m.projects[id]
+		kinds := make([]string, 0, len(p.stored))
+		for kind := range p.stored {
+			kinds = append(kinds, kind)
+		}
+		sort.Strings(kinds)
+		for _, kind := range kinds {
+			fmt.Fprintf(&b, "gcp_visualizer_resources_stored_total{project=\"%s\",kind=\"%s\"} %d\n",
+				escapeLabelValue(id), escapeLabelValue(kind), p.stored[kind])
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// ServeHTTP serves the metrics for Prometheus to scrape
+func (m *ScanMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	_ = m.WritePrometheus(w)
+}
+
+// Push replaces the metrics of PushJob on the Pushgateway at url
+func (m *ScanMetrics) Push(ctx context.Context, url string) error {
+	var body bytes.Buffer
+	if err := m.WritePrometheus(&body); err != nil {
+		return err
+	}
+	err := send.Body(ctx, http.MethodPut, strings.TrimSuffix(url, "/")+"/metrics/job/"+PushJob,
+		"text/plain; version=0.0.4", body.Bytes(), nil)
+	if err != nil {
+		return fmt.Errorf("failed to push metrics: %w", err)
+	}
+	return nil
+}
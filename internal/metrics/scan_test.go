@@ -0,0 +1,83 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestScanMetrics_WritePrometheus(t *testing.T) {
+	m := NewScanMetrics()
+	m.ObserveRequest("ListTopics", "OK")
+	m.ObserveRequest("ListTopics", "OK")
+	m.ObserveRequest("ListTopics", "Unavailable")
+	m.AddStored("project-a", "topic", 3)
+	m.AddStored("project-a", "topic", 2)
+	m.ObserveProject("project-a", 1500*time.Millisecond, nil)
+	m.ObserveProject("project-b", time.Second, errors.New("permission denied"))
+
+	var buf bytes.Buffer
+	require.NoError(t, m.WritePrometheus(&buf))
+	out := buf.String()
+
+	assert.Contains(t, out, `gcp_visualizer_api_requests_total{method="ListTopics",code="OK"} 2`)
+	assert.Contains(t, out, `gcp_visualizer_api_requests_total{method="ListTopics",code="Unavailable"} 1`)
+	assert.Contains(t, out, `gcp_visualizer_resources_stored_total{project="project-a",kind="topic"} 5`)
+	assert.Contains(t, out, `gcp_visualizer_scan_duration_seconds{project="project-a"} 1.5`)
+	assert.Contains(t, out, `gcp_visualizer_scan_success{project="project-a"} 1`)
+	assert.Contains(t, out, `gcp_visualizer_scan_success{project="project-b"} 0`)
+}
+
+func TestScanMetrics_UnaryClientInterceptor(t *testing.T) {
+	m := NewScanMetrics()
+	intercept := m.UnaryClientInterceptor()
+	failing := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return status.Error(codes.PermissionDenied, "denied")
+	}
+
+	err := intercept(context.Background(), "/google.pubsub.v1.Publisher/ListTopics", nil, nil, nil, failing)
+	require.Error(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, m.WritePrometheus(&buf))
+	assert.Contains(t, buf.String(), `gcp_visualizer_api_requests_total{method="ListTopics",code="PermissionDenied"} 1`)
+}
+
+func TestScanMetrics_Push(t *testing.T) {
+	var method, path, body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, path = r.Method, r.URL.Path
+		data, _ := io.ReadAll(r.Body)
+		body = string(data)
+	}))
+	defer srv.Close()
+
+	m := NewScanMetrics()
+	m.ObserveProject("project-a", time.Second, nil)
+	require.NoError(t, m.Push(context.Background(), srv.URL+"/"))
+
+	assert.Equal(t, http.MethodPut, method)
+	assert.Equal(t, "/metrics/job/gcp-visualizer", path)
+	assert.Contains(t, body, `gcp_visualizer_scan_success{project="project-a"} 1`)
+}
+
+func TestScanMetrics_PushError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	err := NewScanMetrics().Push(context.Background(), srv.URL)
+	assert.ErrorContains(t, err, "400")
+}
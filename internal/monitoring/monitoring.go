@@ -0,0 +1,167 @@
+// Package monitoring reads live Pub/Sub metrics from Cloud Monitoring
+package monitoring
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	cloudmonitoring "cloud.google.com/go/monitoring/apiv3/v2"
+	"cloud.google.com/go/monitoring/apiv3/v2/monitoringpb"
+	"google.golang.org/api/iterator"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/auth"
+)
+
+// Pub/Sub metric types, see https://cloud.google.com/monitoring/api/metrics_gcp_p_z#gcp-pubsub
+const (
+	metricPublishCount     = "pubsub.googleapis.com/topic/send_message_operation_count"
+	metricUndelivered      = "pubsub.googleapis.com/subscription/num_undelivered_messages"
+	metricOldestUnackedAge = "pubsub.googleapis.com/subscription/oldest_unacked_message_age"
+)
+
+// lookback is how far back to search for the latest point. Pub/Sub metrics are
+// sampled every 60 seconds and can take a few minutes to become visible.
+const lookback = 10 * time.Minute
+
+// Gauge is the latest value of a metric, Valid is false when Cloud Monitoring
+// had no recent data, e.g. for an idle topic
+type Gauge struct {
+	Value float64
+	Valid bool
+}
+
+// TopicMetrics are the live metrics of a topic
+type TopicMetrics struct {
+	PublishRate Gauge // messages per second
+}
+
+// SubscriptionMetrics are the live metrics of a subscription
+type SubscriptionMetrics struct {
+	Backlog          Gauge // undelivered messages
+	OldestUnackedAge Gauge // seconds
+}
+
+// Source reads live metrics by full resource name,
+// e.g. projects/my-project/topics/orders
+type Source interface {
+	TopicMetrics(ctx context.Context, topic string) (*TopicMetrics, error)
+	SubscriptionMetrics(ctx context.Context, subscription string) (*SubscriptionMetrics, error)
+	Close() error
+}
+
+// Client is a Source backed by the Cloud Monitoring API.
+// The caller needs roles/monitoring.viewer on the monitored projects.
+type Client struct {
+	metrics *cloudmonitoring.MetricClient
+	now     func() time.Time
+}
+
+// NewClient creates a Cloud Monitoring client using the given credentials
+func NewClient(ctx context.Context, opts auth.Options) (*Client, error) {
+	clientOpts, err := auth.ClientOptions(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	metrics, err := cloudmonitoring.NewMetricClient(ctx, clientOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create monitoring client: %w", err)
+	}
+	return &Client{metrics: metrics, now: time.Now}, nil
+}
+
+// TopicMetrics returns the current publish rate of a topic
+func (c *Client) TopicMetrics(ctx context.Context, topic string) (*TopicMetrics, error) {
+	project, id, err := splitResourceName(topic, "topics")
+	if err != nil {
+		return nil, err
+	}
+
+	rate, err := c.latest(ctx, project, metricPublishCount, "pubsub_topic", "topic_id", id,
+		monitoringpb.Aggregation_ALIGN_RATE)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read publish rate of %s: %w", topic, err)
+	}
+	return &TopicMetrics{PublishRate: rate}, nil
+}
+
+// SubscriptionMetrics returns the current backlog of a subscription
+func (c *Client) SubscriptionMetrics(ctx context.Context, subscription string) (*SubscriptionMetrics, error) {
+	project, id, err := splitResourceName(subscription, "subscriptions")
+	if err != nil {
+		return nil, err
+	}
+
+	backlog, err := c.latest(ctx, project, metricUndelivered, "pubsub_subscription", "subscription_id", id,
+		monitoringpb.Aggregation_ALIGN_MAX)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backlog of %s: %w", subscription, err)
+	}
+	age, err := c.latest(ctx, project, metricOldestUnackedAge, "pubsub_subscription", "subscription_id", id,
+		monitoringpb.Aggregation_ALIGN_MAX)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read oldest unacked message age of %s: %w", subscription, err)
+	}
+	return &SubscriptionMetrics{Backlog: backlog, OldestUnackedAge: age}, nil
+}
+
+// Close closes the underlying API client
+func (c *Client) Close() error {
+	return c.metrics.Close()
+}
+
+// latest returns the newest aligned point of a metric for a single resource
+func (c *Client) latest(ctx context.Context, project, metricType, resourceType, label, id string,
+	aligner monitoringpb.Aggregation_Aligner) (Gauge, error) {
+	now := c.now()
+	req := &monitoringpb.ListTimeSeriesRequest{
+		Name: "projects/" + project,
+		Filter: fmt.Sprintf(`metric.type = %q AND resource.type = %q AND resource.labels.%s = %q`,
+			metricType, resourceType, label, id),
+		Interval: &monitoringpb.TimeInterval{
+			StartTime: timestamppb.New(now.Add(-lookback)),
+			EndTime:   timestamppb.New(now),
+		},
+		Aggregation: &monitoringpb.Aggregation{
+			AlignmentPeriod:    durationpb.New(time.Minute),
+			PerSeriesAligner:   aligner,
+			CrossSeriesReducer: monitoringpb.Aggregation_REDUCE_SUM,
+		},
+		View: monitoringpb.ListTimeSeriesRequest_FULL,
+	}
+
+	it := c.metrics.ListTimeSeries(ctx, req)
+	ts, err := it.Next()
+	if errors.Is(err, iterator.Done) {
+		return Gauge{}, nil
+	}
+	if err != nil {
+		return Gauge{}, err
+	}
+	// Points are returned newest first
+	if len(ts.GetPoints()) == 0 {
+		return Gauge{}, nil
+	}
+	return Gauge{Value: pointValue(ts.GetPoints()[0].GetValue()), Valid: true}, nil
+}
+
+// pointValue returns a gauge or rate as float64, whichever type the aligner produced
+func pointValue(v *monitoringpb.TypedValue) float64 {
+	if _, ok := v.GetValue().(*monitoringpb.TypedValue_DoubleValue); ok {
+		return v.GetDoubleValue()
+	}
+	return float64(v.GetInt64Value())
+}
+
+// splitResourceName splits projects/P/<collection>/ID into P and ID
+func splitResourceName(name, collection string) (string, string, error) {
+	parts := strings.Split(name, "/")
+	if len(parts) != 4 || parts[0] != "projects" || parts[2] != collection || parts[1] == "" || parts[3] == "" {
+		return "", "", fmt.Errorf("invalid resource name %q, expected projects/PROJECT/%s/ID", name, collection)
+	}
+	return parts[1], parts[3], nil
+}
@@ -0,0 +1,26 @@
+package monitoring
+
+import (
+	"testing"
+
+	"cloud.google.com/go/monitoring/apiv3/v2/monitoringpb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitResourceName(t *testing.T) {
+	project, id, err := splitResourceName("projects/p1/topics/orders", "topics")
+	require.NoError(t, err)
+	assert.Equal(t, "p1", project)
+	assert.Equal(t, "orders", id)
+
+	for _, name := range []string{"orders", "projects/p1/subscriptions/orders", "projects//topics/orders"} {
+		_, _, err := splitResourceName(name, "topics")
+		assert.Error(t, err, name)
+	}
+}
+
+func TestPointValue(t *testing.T) {
+	assert.Equal(t, 1.5, pointValue(&monitoringpb.TypedValue{Value: &monitoringpb.TypedValue_DoubleValue{DoubleValue: 1.5}}))
+	assert.Equal(t, 42.0, pointValue(&monitoringpb.TypedValue{Value: &monitoringpb.TypedValue_Int64Value{Int64Value: 42}}))
+}
@@ -0,0 +1,134 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/lint"
+	"github.com/NissesSenap/gcp-visualizer/internal/send"
+	"github.com/NissesSenap/gcp-visualizer/internal/storage"
+)
+
+// maxSlackItems caps the resources, projects and violations listed per section of a Slack message
+const maxSlackItems = 10
+
+// Summary is the outcome of a scan, posted after it finishes
+type Summary struct {
+	RunID      string         `json:"run_id"`
+	Finished   time.Time      `json:"finished"`
+	Projects   int            `json:"projects"` // scanned
+	Created    []string       `json:"created"`  // full resource names of the topics and subscriptions the scan created
+	Removed    []string       `json:"removed"`  // full resource names of the topics and subscriptions the scan removed
+	Failed     []Failure      `json:"failed_projects"`
+	Violations []lint.Finding `json:"violations"`
+}
+
+// Failure is a project the scan failed to collect
+type Failure struct {
+	ProjectID string `json:"project_id"`
+	Error     string `json:"error"`
+}
+
+// NewSummary returns the summary of the scan runID with the topics and subscriptions it created or
+// removed, as recorded in the changelog since the scan started. The caller adds failures and violations.
+func NewSummary(ctx context.Context, store storage.Store, runID string, since, finished time.Time, projects int) (*Summary, error) {
+	changes, err := store.GetChanges(ctx, since, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get changes: %w", err)
+	}
+
+	s := &Summary{
+		RunID:      runID,
+		Finished:   finished,
+		Projects:   projects,
+		Created:    []string{},
+		Removed:    []string{},
+		Failed:     []Failure{},
+		Violations: []lint.Finding{},
+	}
+	for _, change := range changes {
+		if change.RunID != runID {
+			continue
+		}
+		switch change.ChangeType {
+		case storage.ChangeTypeCreated:
+			s.Created = append(s.Created, change.FullResourceName)
+		case storage.ChangeTypeDeleted:
+			s.Removed = append(s.Removed, change.FullResourceName)
+		}
+	}
+	sort.Strings(s.Created)
+	sort.Strings(s.Removed)
+	return s, nil
+}
+
+// Empty reports whether the scan changed nothing, failed no project and found no violation
+func (s *Summary) Empty() bool {
+	return len(s.Created) == 0 && len(s.Removed) == 0 && len(s.Failed) == 0 && len(s.Violations) == 0
+}
+
+// Webhook posts the summary as JSON to a URL
+func Webhook(ctx context.Context, url string, s *Summary) error {
+	return post(ctx, url, s)
+}
+
+// Slack posts the summary as a message to a Slack incoming webhook
+func Slack(ctx context.Context, url string, s *Summary) error {
+	return post(ctx, url, struct {
+		Text string `json:"text"`
+	}{SlackText(s)})
+}
+
+// SlackText formats the summary as Slack mrkdwn, listing at most ten items per section
+func SlackText(s *Summary) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*gcp-visualizer scan* of %d projects (run `%s`): %d created, %d removed, %d failed projects, %d policy violations",
+		s.Projects, s.RunID, len(s.Created), len(s.Removed), len(s.Failed), len(s.Violations))
+
+	section := func(title string, items []string) {
+		if len(items) == 0 {
+			return
+		}
+		fmt.Fprintf(&b, "\n\n*%s*", title)
+		for i, item := range items {
+			if i == maxSlackItems {
+				fmt.Fprintf(&b, "\n• …and %d more", len(items)-maxSlackItems)
+				break
+			}
+			b.WriteString("\n• " + item)
+		}
+	}
+	section("Created", codeItems(s.Created))
+	section("Removed", codeItems(s.Removed))
+	failed := make([]string, 0, len(s.Failed))
+	for _, f := range s.Failed {
+		failed = append(failed, fmt.Sprintf("`%s`: %s", f.ProjectID, f.Error))
+	}
+	section("Failed projects", failed)
+	violations := make([]string, 0, len(s.Violations))
+	for _, f := range s.Violations {
+		violations = append(violations, fmt.Sprintf("%s `%s`: %s", f.Severity, f.Rule, f.Message))
+	}
+	section("Policy violations", violations)
+	return b.String()
+}
+
+// codeItems formats resource names as inline code
+func codeItems(names []string) []string {
+	items := make([]string, 0, len(names))
+	for _, name := range names {
+		items = append(items, "`"+name+"`")
+	}
+	return items
+}
+
+// post posts body as JSON to url
+func post(ctx context.Context, url string, body any) error {
+	if err := send.JSON(ctx, url, body, nil); err != nil {
+		return fmt.Errorf("failed to send scan notification: %w", err)
+	}
+	return nil
+}
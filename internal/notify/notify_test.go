@@ -0,0 +1,90 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/lint"
+	"github.com/NissesSenap/gcp-visualizer/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewSummary(t *testing.T) {
+	store, err := storage.NewSQLite(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = store.Close() })
+
+	previous := storage.WithRunID(context.Background(), "run-1")
+	require.NoError(t, store.SaveTopic(previous, &storage.Topic{Name: "legacy", ProjectID: "project-a", FullResourceName: "projects/project-a/topics/legacy"}))
+
+	since := time.Now()
+	ctx := storage.WithRunID(context.Background(), "run-2")
+	require.NoError(t, store.SaveTopic(ctx, &storage.Topic{Name: "orders", ProjectID: "project-a", FullResourceName: "projects/project-a/topics/orders"}))
+	require.NoError(t, store.DeleteTopic(ctx, "projects/project-a/topics/legacy"))
+	// An incremental update during the scan
+	require.NoError(t, store.SaveTopic(context.Background(), &storage.Topic{Name: "users", ProjectID: "project-a", FullResourceName: "projects/project-a/topics/users"}))
+
+	s, err := NewSummary(ctx, store, "run-2", since, time.Now(), 1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"projects/project-a/topics/orders"}, s.Created)
+	assert.Equal(t, []string{"projects/project-a/topics/legacy"}, s.Removed)
+	assert.False(t, s.Empty())
+
+	s, err = NewSummary(ctx, store, "run-3", time.Now(), time.Now(), 1)
+	require.NoError(t, err)
+	assert.True(t, s.Empty())
+}
+
+func TestSlackText(t *testing.T) {
+	s := &Summary{
+		RunID:      "run-1",
+		Projects:   3,
+		Failed:     []Failure{{ProjectID: "project-c", Error: "permission denied"}},
+		Violations: []lint.Finding{{Rule: "production-dlq", Severity: lint.SeverityError, Message: "subscription email: needs a dead-letter topic"}},
+	}
+	for i := range 12 {
+		s.Created = append(s.Created, fmt.Sprintf("projects/project-a/topics/t%02d", i))
+	}
+
+	text := SlackText(s)
+	assert.True(t, strings.HasPrefix(text, "*gcp-visualizer scan* of 3 projects (run `run-1`): 12 created, 0 removed, 1 failed projects, 1 policy violations"))
+	assert.Contains(t, text, "• `projects/project-a/topics/t09`\n• …and 2 more")
+	assert.NotContains(t, text, "*Removed*")
+	assert.Contains(t, text, "• `project-c`: permission denied")
+	assert.Contains(t, text, "• error `production-dlq`: subscription email: needs a dead-letter topic")
+}
+
+func TestSlack(t *testing.T) {
+	var body struct {
+		Text string `json:"text"`
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+	}))
+	defer srv.Close()
+
+	require.NoError(t, Slack(context.Background(), srv.URL, &Summary{RunID: "run-1"}))
+	assert.Contains(t, body.Text, "run `run-1`")
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer failing.Close()
+	err := Webhook(context.Background(), failing.URL, &Summary{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "404")
+}
+
+func TestPost_HidesURL(t *testing.T) {
+	err := Slack(context.Background(), "http://127.0.0.1:1/services/T000/B000/secret", &Summary{})
+	require.Error(t, err)
+	assert.NotContains(t, err.Error(), "secret")
+}
@@ -0,0 +1,108 @@
+package query
+
+import (
+	"github.com/NissesSenap/gcp-visualizer/internal/graph"
+)
+
+// Node attributes available to expressions, in addition to every node metadata
+// key (e.g. full_resource_name or labels.team):
+//
+//	id, label, type, project  strings
+//	degree                    number of edges touching the node
+//	fanout                    topics only: number of subscriptions
+//	has_dlq                   subscriptions only: whether a dead-letter topic is set
+//	cross_project             topics: consumed from another project,
+//	                          subscriptions: reading a topic in another project
+const (
+	AttrID           = "id"
+	AttrLabel        = "label"
+	AttrType         = "type"
+	AttrProject      = "project"
+	AttrDegree       = "degree"
+	AttrFanout       = "fanout"
+	AttrHasDLQ       = "has_dlq"
+	AttrCrossProject = "cross_project"
+)
+
+// nodeStats holds the edge-derived attributes of a node
+type nodeStats struct {
+	degree       int
+	fanout       int
+	crossProject bool
+}
+
+// Filter returns the subgraph of nodes matching expr and the edges between them
+func Filter(g *graph.Graph, expr *Expr) *graph.Graph {
+	stats := edgeStats(g)
+
+	keep := make(map[string]bool)
+	for id, n := range g.Nodes {
+		if expr.Match(nodeEnv(n, stats[id])) {
+			keep[id] = true
+		}
+	}
+	return g.Subgraph(keep)
+}
+
+// edgeStats computes the edge-derived attributes of every node in g
+func edgeStats(g *graph.Graph) map[string]*nodeStats {
+	stats := make(map[string]*nodeStats, len(g.Nodes))
+	for id := range g.Nodes {
+		stats[id] = &nodeStats{}
+	}
+
+	for _, edge := range g.Edges {
+		from, to := stats[edge.From], stats[edge.To]
+		if from == nil || to == nil {
+			continue
+		}
+		from.degree++
+		to.degree++
+
+		switch edge.Type {
+		case graph.EdgeTypeSubscribes:
+			to.fanout++
+		case graph.EdgeTypeCrossProject:
+			to.fanout++
+			from.crossProject = true
+			to.crossProject = true
+		}
+	}
+	return stats
+}
+
+// nodeEnv resolves the attributes of a single node
+func nodeEnv(n *graph.Node, stats *nodeStats) Env {
+	return func(name string) (interface{}, bool) {
+		switch name {
+		case AttrID:
+			return n.ID, true
+		case AttrLabel:
+			return n.Label, true
+		case AttrType:
+			return string(n.Type), true
+		case AttrProject:
+			return n.Project, true
+		case AttrDegree:
+			return float64(stats.degree), true
+		case AttrFanout:
+			if n.Type != graph.NodeTypeTopic {
+				return nil, false
+			}
+			return float64(stats.fanout), true
+		case AttrHasDLQ:
+			if n.Type != graph.NodeTypeSubscription {
+				return nil, false
+			}
+			return n.Metadata[graph.DeadLetterTopicKey] != "", true
+		case AttrCrossProject:
+			if n.Type != graph.NodeTypeTopic && n.Type != graph.NodeTypeSubscription {
+				return nil, false
+			}
+			return stats.crossProject, true
+		}
+
+		v, ok := n.Metadata[name]
+		return v, ok
+	}
+}
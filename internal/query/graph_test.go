@@ -0,0 +1,53 @@
+package query
+
+import (
+	"testing"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/graph"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testGraph() *graph.Graph {
+	g := graph.New()
+	g.AddNode(&graph.Node{ID: "topic_prod-a_orders", Label: "orders", Type: graph.NodeTypeTopic, Project: "prod-a"})
+	g.AddNode(&graph.Node{ID: "topic_prod-a_quiet", Label: "quiet", Type: graph.NodeTypeTopic, Project: "prod-a"})
+	g.AddNode(&graph.Node{ID: "sub_prod-a_billing", Label: "billing", Type: graph.NodeTypeSubscription, Project: "prod-a",
+		Metadata: map[string]string{graph.DeadLetterTopicKey: "projects/prod-a/topics/orders-dlq"}})
+	g.AddNode(&graph.Node{ID: "sub_dev-b_email", Label: "email", Type: graph.NodeTypeSubscription, Project: "dev-b",
+		Metadata: map[string]string{"labels.team": "mail"}})
+	g.AddEdge(&graph.Edge{From: "sub_prod-a_billing", To: "topic_prod-a_orders", Type: graph.EdgeTypeSubscribes})
+	g.AddEdge(&graph.Edge{From: "sub_dev-b_email", To: "topic_prod-a_orders", Type: graph.EdgeTypeCrossProject})
+	return g
+}
+
+func filter(t *testing.T, g *graph.Graph, src string) []string {
+	expr, err := Parse(src)
+	require.NoError(t, err)
+
+	var ids []string
+	for id := range Filter(g, expr).Nodes {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func TestFilter(t *testing.T) {
+	g := testGraph()
+
+	assert.ElementsMatch(t, []string{"topic_prod-a_orders"}, filter(t, g, `fanout > 1`))
+	assert.ElementsMatch(t, []string{"sub_dev-b_email"}, filter(t, g, `has_dlq == false`))
+	assert.ElementsMatch(t, []string{"topic_prod-a_orders", "sub_dev-b_email"}, filter(t, g, `cross_project`))
+	assert.ElementsMatch(t, []string{"sub_dev-b_email"}, filter(t, g, `labels.team == "mail"`))
+	assert.ElementsMatch(t, []string{"topic_prod-a_quiet"}, filter(t, g, `degree == 0`))
+}
+
+func TestFilter_KeepsEdgesBetweenMatches(t *testing.T) {
+	expr, err := Parse(`project =~ "prod-.*"`)
+	require.NoError(t, err)
+
+	sub := Filter(testGraph(), expr)
+	assert.Len(t, sub.Nodes, 3)
+	require.Len(t, sub.Edges, 1)
+	assert.Equal(t, "sub_prod-a_billing", sub.Edges[0].From)
+}
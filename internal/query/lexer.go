@@ -0,0 +1,110 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenString
+	tokenNumber
+	tokenOp
+	tokenLParen
+	tokenRParen
+)
+
+// token is a lexical token with its byte offset in the expression
+type token struct {
+	kind  tokenKind
+	text  string
+	value string // unquoted value of string tokens
+	pos   int
+}
+
+// operators are matched longest first
+var operators = []string{"&&", "||", "==", "!=", "=~", "!~", ">=", "<=", ">", "<", "!"}
+
+// lex splits an expression into tokens
+func lex(src string) ([]token, error) {
+	var tokens []token
+	i := 0
+	for i < len(src) {
+		c := rune(src[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '(':
+			tokens = append(tokens, token{kind: tokenLParen, text: "(", pos: i})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{kind: tokenRParen, text: ")", pos: i})
+			i++
+		case c == '"':
+			tok, n, err := lexString(src, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, tok)
+			i += n
+		case c == '-' || unicode.IsDigit(c):
+			j := i + 1
+			for j < len(src) && (unicode.IsDigit(rune(src[j])) || src[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: src[i:j], pos: i})
+			i = j
+		case c == '_' || unicode.IsLetter(c):
+			j := i + 1
+			for j < len(src) && isIdentChar(rune(src[j])) {
+				j++
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: src[i:j], pos: i})
+			i = j
+		default:
+			op := ""
+			for _, candidate := range operators {
+				if strings.HasPrefix(src[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("unexpected character %q at offset %d", c, i)
+			}
+			tokens = append(tokens, token{kind: tokenOp, text: op, pos: i})
+			i += len(op)
+		}
+	}
+	return append(tokens, token{kind: tokenEOF, pos: len(src)}), nil
+}
+
+// lexString reads a double-quoted string starting at src[start], supporting \" and \\ escapes
+func lexString(src string, start int) (token, int, error) {
+	var b strings.Builder
+	for i := start + 1; i < len(src); i++ {
+		switch src[i] {
+		case '\\':
+			if i+1 == len(src) {
+				return token{}, 0, fmt.Errorf("unterminated string at offset %d", start)
+			}
+			i++
+			b.WriteByte(src[i])
+		case '"':
+			return token{kind: tokenString, text: src[start : i+1], value: b.String(), pos: start}, i + 1 - start, nil
+		default:
+			b.WriteByte(src[i])
+		}
+	}
+	return token{}, 0, fmt.Errorf("unterminated string at offset %d", start)
+}
+
+// isIdentChar reports whether c may appear after the first character of an
+// identifier. Dots and dashes allow metadata keys such as labels.cost-center.
+func isIdentChar(c rune) bool {
+	return c == '_' || c == '.' || c == '-' || unicode.IsLetter(c) || unicode.IsDigit(c)
+}
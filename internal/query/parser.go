@@ -0,0 +1,158 @@
+package query
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// parser is a recursive descent parser over the token stream:
+//
+//	or      = and { "||" and }
+//	and     = unary { "&&" unary }
+//	unary   = "!" unary | compare
+//	compare = operand [ op operand ]
+//	operand = "(" or ")" | ident | string | number | "true" | "false"
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parser) unexpected() error {
+	tok := p.peek()
+	if tok.kind == tokenEOF {
+		return fmt.Errorf("unexpected end of expression")
+	}
+	return fmt.Errorf("unexpected %q at offset %d", tok.text, tok.pos)
+}
+
+func (p *parser) isOp(op string) bool {
+	tok := p.peek()
+	return tok.kind == tokenOp && tok.text == op
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("||") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = or{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("&&") {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = and{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if p.isOp("!") {
+		p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return not{operand}, nil
+	}
+	return p.parseCompare()
+}
+
+func (p *parser) parseCompare() (node, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	tok := p.peek()
+	if tok.kind != tokenOp {
+		return left, nil
+	}
+	switch tok.text {
+	case "=~", "!~":
+		p.next()
+		pattern := p.next()
+		if pattern.kind != tokenString {
+			return nil, fmt.Errorf("%s at offset %d needs a string pattern", tok.text, tok.pos)
+		}
+		re, err := regexp.Compile("^(?:" + pattern.value + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern at offset %d: %w", pattern.pos, err)
+		}
+		return match{negate: tok.text == "!~", left: left, re: re}, nil
+	case "==", "!=", "<", "<=", ">", ">=":
+		p.next()
+		right, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		return compare{op: tok.text, left: left, right: right}, nil
+	}
+	return left, nil
+}
+
+func (p *parser) parseOperand() (node, error) {
+	tok := p.peek()
+	switch tok.kind {
+	case tokenLParen:
+		p.next()
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek().kind != tokenRParen {
+			return nil, p.unexpected()
+		}
+		p.next()
+		return inner, nil
+	case tokenString:
+		p.next()
+		return literal{tok.value}, nil
+	case tokenNumber:
+		p.next()
+		f, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q at offset %d", tok.text, tok.pos)
+		}
+		return literal{f}, nil
+	case tokenIdent:
+		p.next()
+		switch tok.text {
+		case "true":
+			return literal{true}, nil
+		case "false":
+			return literal{false}, nil
+		}
+		return attr{tok.text}, nil
+	}
+	return nil, p.unexpected()
+}
@@ -0,0 +1,165 @@
+// Package query implements a small expression language for filtering graph nodes,
+// e.g. `project =~ "prod-.*" && has_dlq == false`.
+//
+// Expressions combine comparisons with &&, || and !, grouped with parentheses.
+// Comparison operators are ==, !=, <, <=, >, >= and the regular expression
+// matches =~ and !~, which must match the whole value. Operands are attribute
+// names, double-quoted strings, numbers, true and false. A bare attribute is
+// true when it is set to a true, non-zero or non-empty value.
+package query
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// Expr is a parsed expression
+type Expr struct {
+	src  string
+	root node
+}
+
+// Env resolves attribute names to values: string, float64 or bool.
+// Attributes that don't apply to an element are reported as not found.
+type Env func(name string) (interface{}, bool)
+
+// Parse parses an expression
+func Parse(src string) (*Expr, error) {
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, fmt.Errorf("invalid expression: %w", err)
+	}
+
+	p := &parser{tokens: tokens}
+	root, err := p.parseOr()
+	if err == nil && p.peek().kind != tokenEOF {
+		err = p.unexpected()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid expression: %w", err)
+	}
+	return &Expr{src: src, root: root}, nil
+}
+
+// String returns the source of the expression
+func (e *Expr) String() string {
+	return e.src
+}
+
+// Match evaluates the expression against env
+func (e *Expr) Match(env Env) bool {
+	return truthy(e.root.eval(env))
+}
+
+// node is an expression tree node
+type node interface {
+	eval(env Env) interface{}
+}
+
+type (
+	literal struct{ value interface{} }
+	attr    struct{ name string }
+	not     struct{ operand node }
+	and     struct{ left, right node }
+	or      struct{ left, right node }
+	compare struct {
+		op          string
+		left, right node
+	}
+	match struct {
+		negate bool
+		left   node
+		re     *regexp.Regexp
+	}
+)
+
+func (n literal) eval(Env) interface{} { return n.value }
+
+func (n attr) eval(env Env) interface{} {
+	if v, ok := env(n.name); ok {
+		return v
+	}
+	return nil
+}
+
+func (n not) eval(env Env) interface{} { return !truthy(n.operand.eval(env)) }
+
+func (n and) eval(env Env) interface{} { return truthy(n.left.eval(env)) && truthy(n.right.eval(env)) }
+
+func (n or) eval(env Env) interface{} { return truthy(n.left.eval(env)) || truthy(n.right.eval(env)) }
+
+func (n match) eval(env Env) interface{} {
+	s, ok := n.left.eval(env).(string)
+	if !ok {
+		// Missing attributes never match, so !~ holds for them
+		return n.negate
+	}
+	return n.re.MatchString(s) != n.negate
+}
+
+func (n compare) eval(env Env) interface{} {
+	left, right := n.left.eval(env), n.right.eval(env)
+
+	switch n.op {
+	case "==":
+		return equal(left, right)
+	case "!=":
+		return !equal(left, right)
+	}
+
+	l, lok := number(left)
+	r, rok := number(right)
+	if !lok || !rok {
+		return false
+	}
+	switch n.op {
+	case "<":
+		return l < r
+	case "<=":
+		return l <= r
+	case ">":
+		return l > r
+	default: // ">="
+		return l >= r
+	}
+}
+
+// equal compares two values. Numbers compare numerically even when one side is
+// a numeric string, such as a label value; missing values are equal to nothing.
+func equal(left, right interface{}) bool {
+	if left == nil || right == nil {
+		return false
+	}
+	if l, ok := number(left); ok {
+		if r, ok := number(right); ok {
+			return l == r
+		}
+	}
+	return left == right
+}
+
+// number converts numbers and numeric strings to float64
+func number(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// truthy reports whether a value counts as true on its own
+func truthy(v interface{}) bool {
+	switch v := v.(type) {
+	case bool:
+		return v
+	case float64:
+		return v != 0
+	case string:
+		return v != ""
+	}
+	return false
+}
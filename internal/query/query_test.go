@@ -0,0 +1,82 @@
+package query
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testEnv(attrs map[string]interface{}) Env {
+	return func(name string) (interface{}, bool) {
+		v, ok := attrs[name]
+		return v, ok
+	}
+}
+
+func TestMatch(t *testing.T) {
+	env := testEnv(map[string]interface{}{
+		"project":     "prod-billing",
+		"type":        "subscription",
+		"has_dlq":     false,
+		"fanout":      4.0,
+		"labels.team": "billing",
+		"labels.tier": "2",
+	})
+
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{`project =~ "prod-.*" && has_dlq == false && fanout > 3`, true},
+		{`project =~ "prod"`, false}, // patterns match the whole value
+		{`project !~ "dev-.*"`, true},
+		{`type == "topic" || labels.team == "billing"`, true},
+		{`!(type == "subscription")`, false},
+		{`fanout >= 4 && fanout <= 4 && fanout != 5`, true},
+		{`labels.tier < 3`, true}, // numeric strings compare as numbers
+		{`labels.tier == 2`, true},
+		{`has_dlq`, false},
+		{`!has_dlq`, true},
+		{`missing == ""`, false}, // missing attributes equal nothing
+		{`missing != "x"`, true},
+		{`missing !~ "x"`, true},
+		{`missing > 0`, false},
+		{`true && (false || fanout > 1)`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			expr, err := Parse(tt.expr)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, expr.Match(env))
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{`project ==`, "unexpected end of expression"},
+		{`(type == "topic"`, "unexpected end of expression"},
+		{`type == "topic")`, `unexpected ")" at offset 15`},
+		{`type = "topic"`, `unexpected character '=' at offset 5`},
+		{`project =~ prod`, "needs a string pattern"},
+		{`project =~ "("`, "invalid pattern"},
+		{`label == "open`, "unterminated string"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			_, err := Parse(tt.expr)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+}
+
+func TestParseEscapedString(t *testing.T) {
+	expr, err := Parse(`label == "say \"hi\""`)
+	require.NoError(t, err)
+	assert.True(t, expr.Match(testEnv(map[string]interface{}{"label": `say "hi"`})))
+}
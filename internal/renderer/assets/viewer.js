@@ -0,0 +1,332 @@
+// Minimal SVG graph viewer for gcp-visualizer HTML output.
+// Node positions are precomputed in Go; this script only draws and handles
+// pan, zoom, search and click-to-expand metadata. Diff pages also get a
+// before/after slider that fades added and removed elements.
+(function () {
+  "use strict";
+
+  var SVG_NS = "http://www.w3.org/2000/svg";
+  var data = GRAPH_DATA;
+  var svg = document.getElementById("canvas");
+  var details = document.getElementById("details");
+  var search = document.getElementById("search");
+  var matches = document.getElementById("matches");
+
+  var nodesById = {};
+  var nodeElems = {};
+  var edgeElems = [];
+  var diffItems = [];
+  var view = { x: -20, y: -20, w: data.width + 40, h: data.height + 40 };
+  var initialView = { x: view.x, y: view.y, w: view.w, h: view.h };
+
+  function el(name, attrs, parent) {
+    var e = document.createElementNS(SVG_NS, name);
+    for (var k in attrs) {
+      e.setAttribute(k, attrs[k]);
+    }
+    if (parent) {
+      parent.appendChild(e);
+    }
+    return e;
+  }
+
+  // diffWrap puts an added or removed element in a group whose opacity follows the diff slider
+  function diffWrap(item, parent) {
+    if (!data.diff || (item.status !== "added" && item.status !== "removed")) {
+      return parent;
+    }
+    var wrap = el("g", { "class": "diff-item" }, parent);
+    diffItems.push({ status: item.status, elem: wrap });
+    return wrap;
+  }
+
+  function applyView() {
+    svg.setAttribute("viewBox", [view.x, view.y, view.w, view.h].join(" "));
+  }
+
+  function draw() {
+    svg.style.background = data.theme.background;
+    var defs = el("defs", {}, svg);
+    var marker = el("marker", {
+      id: "arrow", viewBox: "0 0 10 10", refX: 10, refY: 5,
+      markerWidth: 8, markerHeight: 8, orient: "auto-start-reverse"
+    }, defs);
+    el("path", { d: "M 0 0 L 10 5 L 0 10 z", fill: data.theme.arrow }, marker);
+
+    var root = el("g", {}, svg);
+
+    data.clusters.forEach(function (c) {
+      var g = el("g", { "class": "cluster" }, root);
+      el("rect", { x: c.x, y: c.y, width: c.w, height: c.h, fill: data.theme.cluster, stroke: data.theme.cluster_border, rx: 6 }, g);
+      var t = el("text", { x: c.x + 10, y: c.y + 18, "font-weight": "bold", fill: data.theme.text }, g);
+      t.textContent = c.label;
+    });
+
+    data.nodes.forEach(function (n) {
+      nodesById[n.id] = n;
+    });
+
+    var edgeLayer = el("g", {}, root);
+    data.edges.forEach(function (e) {
+      var from = nodesById[e.from];
+      var to = nodesById[e.to];
+      if (!from || !to) {
+        return;
+      }
+      // Colors and line styles are resolved from the theme in Go
+      var attrs = {
+        x1: from.x + from.w / 2, y1: from.y + from.h / 2,
+        x2: to.x + to.w / 2, y2: to.y + to.h / 2,
+        stroke: e.color, "stroke-width": e.width, "marker-end": "url(#arrow)"
+      };
+      if (e.dash) {
+        attrs["stroke-dasharray"] = e.dash;
+      }
+      if (e.status) {
+        attrs["class"] = e.status;
+      }
+      var parent = diffWrap(e, edgeLayer);
+      var line = el("line", attrs, parent);
+      if (e.label) {
+        var t = el("text", {
+          x: (attrs.x1 + attrs.x2) / 2, y: (attrs.y1 + attrs.y2) / 2 - 3,
+          "text-anchor": "middle", "font-size": 10, fill: data.theme.text
+        }, parent);
+        t.textContent = e.label;
+      }
+      edgeElems.push({ edge: e, elem: line });
+    });
+
+    data.nodes.forEach(function (n) {
+      var g = el("g", { "class": "node" + (n.status ? " " + n.status : "") }, diffWrap(n, root));
+      var shape;
+      if (n.shape === "ellipse") {
+        shape = el("ellipse", { cx: n.x + n.w / 2, cy: n.y + n.h / 2, rx: n.w / 2, ry: n.h / 2 }, g);
+      } else if (n.shape === "diamond") {
+        var points = [[n.x + n.w / 2, n.y], [n.x + n.w, n.y + n.h / 2], [n.x + n.w / 2, n.y + n.h], [n.x, n.y + n.h / 2]];
+        shape = el("polygon", { points: points.join(" ") }, g);
+      } else {
+        shape = el("rect", { x: n.x, y: n.y, width: n.w, height: n.h, rx: 4 }, g);
+      }
+      shape.setAttribute("fill", n.color || "#fff");
+      shape.setAttribute("stroke", n.border || data.theme.node_border);
+      shape.setAttribute("stroke-width", n.border ? 3 : 1);
+      var t = el("text", { x: n.x + n.w / 2, y: n.y + n.h / 2 + 4, "text-anchor": "middle", fill: data.theme.text }, g);
+      t.textContent = n.label;
+      if (n.count) {
+        var bw = Math.max(18, String(n.count).length * 7 + 8);
+        el("ellipse", { cx: n.x + n.w, cy: n.y, rx: bw / 2, ry: 9, fill: data.theme.text }, g);
+        var c = el("text", { x: n.x + n.w, y: n.y + 3.5, "text-anchor": "middle", "font-size": 10, fill: data.theme.background }, g);
+        c.textContent = n.count;
+      }
+      g.addEventListener("click", function (ev) {
+        ev.stopPropagation();
+        showDetails(n);
+      });
+      nodeElems[n.id] = g;
+    });
+  }
+
+  function showDetails(n) {
+    var rows = [["id", n.id], ["type", n.type], ["project", n.project || ""]];
+    if (n.status) {
+      rows.push(["status", n.status]);
+    }
+    var meta = n.metadata || {};
+    Object.keys(meta).sort().forEach(function (k) {
+      rows.push([k, meta[k]]);
+    });
+
+    details.textContent = "";
+    var title = document.createElement("b");
+    title.textContent = n.label;
+    details.appendChild(title);
+    var table = document.createElement("table");
+    rows.forEach(function (r) {
+      var tr = document.createElement("tr");
+      r.forEach(function (v) {
+        var td = document.createElement("td");
+        td.textContent = v;
+        tr.appendChild(td);
+      });
+      table.appendChild(tr);
+    });
+    details.appendChild(table);
+    details.style.display = "block";
+
+    highlightNeighbors(n.id);
+  }
+
+  function highlightNeighbors(id) {
+    var keep = {};
+    keep[id] = true;
+    edgeElems.forEach(function (e) {
+      var connected = e.edge.from === id || e.edge.to === id;
+      if (connected) {
+        keep[e.edge.from] = true;
+        keep[e.edge.to] = true;
+      }
+      e.elem.classList.toggle("dim", !connected);
+    });
+    Object.keys(nodeElems).forEach(function (nid) {
+      nodeElems[nid].classList.toggle("dim", !keep[nid]);
+    });
+  }
+
+  function clearHighlight() {
+    details.style.display = "none";
+    edgeElems.forEach(function (e) { e.elem.classList.remove("dim"); });
+    Object.keys(nodeElems).forEach(function (nid) { nodeElems[nid].classList.remove("dim"); });
+  }
+
+  function runSearch() {
+    var q = search.value.trim().toLowerCase();
+    var found = [];
+    data.nodes.forEach(function (n) {
+      var hit = q !== "" && (n.label.toLowerCase().indexOf(q) >= 0 || n.id.toLowerCase().indexOf(q) >= 0);
+      nodeElems[n.id].classList.toggle("match", hit);
+      if (hit) {
+        found.push(n);
+      }
+    });
+    matches.textContent = q === "" ? "" : found.length + " match(es)";
+    if (found.length > 0) {
+      var n = found[0];
+      view.w = Math.min(initialView.w, 1200);
+      view.h = view.w * (svg.clientHeight / Math.max(svg.clientWidth, 1));
+      view.x = n.x + n.w / 2 - view.w / 2;
+      view.y = n.y + n.h / 2 - view.h / 2;
+      applyView();
+    }
+  }
+
+  function enablePanZoom() {
+    var dragging = false;
+    var last = null;
+
+    svg.addEventListener("mousedown", function (ev) {
+      dragging = true;
+      last = { x: ev.clientX, y: ev.clientY };
+      svg.style.cursor = "grabbing";
+    });
+    window.addEventListener("mouseup", function () {
+      dragging = false;
+      svg.style.cursor = "grab";
+    });
+    window.addEventListener("mousemove", function (ev) {
+      if (!dragging) {
+        return;
+      }
+      var scale = view.w / svg.clientWidth;
+      view.x -= (ev.clientX - last.x) * scale;
+      view.y -= (ev.clientY - last.y) * scale;
+      last = { x: ev.clientX, y: ev.clientY };
+      applyView();
+    });
+    svg.addEventListener("wheel", function (ev) {
+      ev.preventDefault();
+      var factor = ev.deltaY > 0 ? 1.15 : 1 / 1.15;
+      var rect = svg.getBoundingClientRect();
+      var px = view.x + (ev.clientX - rect.left) / rect.width * view.w;
+      var py = view.y + (ev.clientY - rect.top) / rect.height * view.h;
+      view.x = px - (px - view.x) * factor;
+      view.y = py - (py - view.y) * factor;
+      view.w *= factor;
+      view.h *= factor;
+      applyView();
+    }, { passive: false });
+    svg.addEventListener("click", clearHighlight);
+  }
+
+  // setDiffPosition shows the before run at 0, the after run at 1 and a crossfade in between
+  function setDiffPosition(t) {
+    diffItems.forEach(function (item) {
+      var opacity = item.status === "added" ? t : 1 - t;
+      item.elem.style.opacity = opacity;
+      item.elem.style.visibility = opacity === 0 ? "hidden" : "visible";
+    });
+  }
+
+  function enableDiff() {
+    var slider = document.getElementById("diff-slider");
+    var play = document.getElementById("diff-play");
+    var counts = { added: 0, removed: 0, changed: 0 };
+    data.nodes.forEach(function (n) {
+      if (counts[n.status] !== undefined) {
+        counts[n.status]++;
+      }
+    });
+    document.getElementById("diff-summary").textContent =
+      "+" + counts.added + " / -" + counts.removed + " / ~" + counts.changed + " nodes";
+    document.getElementById("diff-controls").hidden = false;
+
+    slider.addEventListener("input", function () {
+      setDiffPosition(slider.value / 100);
+    });
+
+    // Play animates the slider to the opposite run
+    play.addEventListener("click", function () {
+      var from = Number(slider.value);
+      var to = from >= 50 ? 0 : 100;
+      var start = null;
+      function step(ts) {
+        if (start === null) {
+          start = ts;
+        }
+        var p = Math.min((ts - start) / 1500, 1);
+        slider.value = from + (to - from) * p;
+        setDiffPosition(slider.value / 100);
+        if (p < 1) {
+          window.requestAnimationFrame(step);
+        }
+      }
+      window.requestAnimationFrame(step);
+    });
+
+    setDiffPosition(slider.value / 100);
+  }
+
+  // drawLegend fills the legend sidebar with the node and edge type key and the scan summary
+  function drawLegend() {
+    var legend = document.getElementById("legend");
+    var title = document.createElement("b");
+    title.textContent = "Legend";
+    legend.appendChild(title);
+    data.legend.forEach(function (entry) {
+      var row = document.createElement("div");
+      if (entry.fill || entry.stroke) {
+        var sample = document.createElementNS(SVG_NS, "svg");
+        sample.setAttribute("width", 24);
+        sample.setAttribute("height", 10);
+        if (entry.fill) {
+          el("rect", { x: 0.5, y: 0.5, width: 23, height: 9, fill: entry.fill, stroke: data.theme.node_border }, sample);
+        } else {
+          var attrs = { x1: 0, y1: 5, x2: 24, y2: 5, stroke: entry.stroke, "stroke-width": entry.width };
+          if (entry.dash) {
+            attrs["stroke-dasharray"] = entry.dash;
+          }
+          el("line", attrs, sample);
+        }
+        row.appendChild(sample);
+      }
+      row.appendChild(document.createTextNode(entry.label));
+      legend.appendChild(row);
+    });
+    legend.hidden = false;
+  }
+
+  draw();
+  if (data.legend) {
+    drawLegend();
+  }
+  applyView();
+  if (data.diff) {
+    enableDiff();
+  }
+  enablePanZoom();
+  search.addEventListener("input", runSearch);
+  document.getElementById("reset").addEventListener("click", function () {
+    view = { x: initialView.x, y: initialView.y, w: initialView.w, h: initialView.h };
+    applyView();
+  });
+})();
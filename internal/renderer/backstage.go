@@ -0,0 +1,309 @@
+package renderer
+
+import (
+	"fmt"
+	"hash/fnv"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/graph"
+)
+
+// Backstage constants, see https://backstage.io/docs/features/software-catalog/descriptor-format
+const (
+	backstageAPIVersion       = "backstage.io/v1alpha1"
+	backstageLifecycle        = "production"
+	backstageDefaultNamespace = "default"
+	backstageAnnotationPrefix = "gcp-visualizer/"
+	backstageMaxName          = 63
+)
+
+// backstageLabel matches the label keys and values Backstage accepts
+var backstageLabel = regexp.MustCompile(`^[A-Za-z0-9]([-_.A-Za-z0-9]{0,61}[A-Za-z0-9])?$`)
+
+// backstageKinds are the Backstage kind, spec type and name prefix of the node types in the catalog.
+// Identities and project summaries are left out.
+var backstageKinds = map[graph.NodeType]struct{ kind, specType, prefix string }{
+	graph.NodeTypeTopic:            {"Resource", "pubsub-topic", "topic"},
+	graph.NodeTypeSubscription:     {"Resource", "pubsub-subscription", "subscription"},
+	graph.NodeTypeBigQueryTable:    {"Resource", "bigquery-table", "bigquery"},
+	graph.NodeTypeStorageBucket:    {"Resource", "storage-bucket", "bucket"},
+	graph.NodeTypeCloudRunService:  {"Component", "service", "run"},
+	graph.NodeTypeCloudFunction:    {"Component", "function", "function"},
+	graph.NodeTypeDataflowJob:      {"Component", "dataflow-job", "dataflow"},
+	graph.NodeTypeLiteTopic:        {"Resource", "pubsub-lite-topic", "lite-topic"},
+	graph.NodeTypeLiteSubscription: {"Resource", "pubsub-lite-subscription", "lite-subscription"},
+}
+
+// BackstageEntity is an entity of the Backstage software catalog
+type BackstageEntity struct {
+	APIVersion string            `yaml:"apiVersion"`
+	Kind       string            `yaml:"kind"`
+	Metadata   BackstageMetadata `yaml:"metadata"`
+	Spec       BackstageSpec     `yaml:"spec"`
+}
+
+// BackstageMetadata names and describes an entity
+type BackstageMetadata struct {
+	Name        string            `yaml:"name"`
+	Namespace   string            `yaml:"namespace,omitempty"`
+	Title       string            `yaml:"title,omitempty"`
+	Labels      map[string]string `yaml:"labels,omitempty"`
+	Annotations map[string]string `yaml:"annotations,omitempty"`
+}
+
+// BackstageSpec is the spec of a Resource, Component or API entity
+type BackstageSpec struct {
+	Type         string   `yaml:"type"`
+	Lifecycle    string   `yaml:"lifecycle,omitempty"`
+	Owner        string   `yaml:"owner"`
+	Definition   string   `yaml:"definition,omitempty"`
+	DependsOn    []string `yaml:"dependsOn,omitempty"`
+	ProvidesAPIs []string `yaml:"providesApis,omitempty"`
+	ConsumesAPIs []string `yaml:"consumesApis,omitempty"`
+}
+
+// NewBackstageEntities converts a graph to Backstage catalog entities. Topics, subscriptions and the
+// BigQuery tables and buckets they export to are Resources, in a namespace named after their project.
+// Every topic also is an API, so the Cloud Run services, functions and Dataflow jobs reading and
+// writing it, which are Components, consume or provide it. A subscription dependsOn its topic and
+// export, a Component dependsOn the subscriptions and topics it reads. Entities are owned by the
+// group of the node's team, see graph.Ownership, or by owner.
+func NewBackstageEntities(g *graph.Graph, owner string) []*BackstageEntity {
+	ids := make([]string, 0, len(g.Nodes))
+	for id := range g.Nodes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	entities := make(map[string]*BackstageEntity)
+	refs := make(map[string]string)    // node ID -> entity reference
+	apiRefs := make(map[string]string) // topic node ID -> API entity reference
+	var entityOrder []string
+	add := func(ref string, entity *BackstageEntity) {
+		if _, ok := entities[ref]; !ok {
+			entities[ref] = entity
+			entityOrder = append(entityOrder, ref)
+		}
+	}
+
+	for _, id := range ids {
+		node := g.Nodes[id]
+		kind, ok := backstageKinds[node.Type]
+		if !ok {
+			continue
+		}
+		namespace := backstageDefaultNamespace
+		if node.Project != "" {
+			namespace = backstageName(node.Project)
+		}
+		entity := &BackstageEntity{
+			APIVersion: backstageAPIVersion,
+			Kind:       kind.kind,
+			Metadata: BackstageMetadata{
+				Name:        backstageName(kind.prefix + "-" + node.Label),
+				Namespace:   namespace,
+				Title:       node.Label,
+				Labels:      backstageLabels(node),
+				Annotations: backstageAnnotations(node),
+			},
+			Spec: BackstageSpec{Type: kind.specType, Owner: backstageOwner(node, owner)},
+		}
+		if kind.kind == "Component" {
+			entity.Spec.Lifecycle = backstageLifecycle
+		}
+		refs[id] = backstageRef(entity)
+		add(refs[id], entity)
+
+		if node.Type == graph.NodeTypeTopic {
+			api := &BackstageEntity{
+				APIVersion: backstageAPIVersion,
+				Kind:       "API",
+				Metadata: BackstageMetadata{
+					Name:        backstageName(node.Label),
+					Namespace:   namespace,
+					Title:       node.Label,
+					Labels:      entity.Metadata.Labels,
+					Annotations: entity.Metadata.Annotations,
+				},
+				Spec: BackstageSpec{
+					Type:       "asyncapi",
+					Lifecycle:  backstageLifecycle,
+					Owner:      entity.Spec.Owner,
+					Definition: asyncAPIDefinition(node),
+				},
+			}
+			apiRefs[id] = backstageRef(api)
+			add(apiRefs[id], api)
+		}
+	}
+
+	// The topic of every subscription, for the APIs consumed through it
+	topics := make(map[string]string)
+	for _, edge := range g.Edges {
+		if edge.Type == graph.EdgeTypeSubscribes || edge.Type == graph.EdgeTypeCrossProject {
+			topics[edge.From] = edge.To
+		}
+	}
+	consumedAPI := func(id string) string {
+		if topic, ok := topics[id]; ok {
+			id = topic
+		}
+		return apiRefs[id]
+	}
+
+	for _, edge := range g.Edges {
+		from, to := entities[refs[edge.From]], entities[refs[edge.To]]
+		if from == nil || to == nil {
+			continue
+		}
+		switch edge.Type {
+		case graph.EdgeTypeSubscribes, graph.EdgeTypeCrossProject:
+			from.Spec.DependsOn = append(from.Spec.DependsOn, refs[edge.To])
+		case graph.EdgeTypeDelivers:
+			if to.Kind == "Component" {
+				to.Spec.DependsOn = append(to.Spec.DependsOn, refs[edge.From])
+				to.Spec.ConsumesAPIs = appendRef(to.Spec.ConsumesAPIs, consumedAPI(edge.From))
+			} else {
+				from.Spec.DependsOn = append(from.Spec.DependsOn, refs[edge.To])
+			}
+		case graph.EdgeTypeTriggers, graph.EdgeTypeReads:
+			to.Spec.DependsOn = append(to.Spec.DependsOn, refs[edge.From])
+			to.Spec.ConsumesAPIs = appendRef(to.Spec.ConsumesAPIs, consumedAPI(edge.From))
+		case graph.EdgeTypePublishes:
+			from.Spec.ProvidesAPIs = appendRef(from.Spec.ProvidesAPIs, apiRefs[edge.To])
+		}
+	}
+
+	sort.Strings(entityOrder)
+	result := make([]*BackstageEntity, 0, len(entityOrder))
+	for _, ref := range entityOrder {
+		entity := entities[ref]
+		entity.Spec.DependsOn = sortedRefs(entity.Spec.DependsOn)
+		entity.Spec.ProvidesAPIs = sortedRefs(entity.Spec.ProvidesAPIs)
+		entity.Spec.ConsumesAPIs = sortedRefs(entity.Spec.ConsumesAPIs)
+		result = append(result, entity)
+	}
+	return result
+}
+
+// WriteBackstage writes the graph as a Backstage catalog-info.yaml, one YAML document per entity,
+// that a Backstage location can register. See NewBackstageEntities for how resources are described.
+func WriteBackstage(w io.Writer, g *graph.Graph, owner string) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	for _, entity := range NewBackstageEntities(g, owner) {
+		if err := enc.Encode(entity); err != nil {
+			return fmt.Errorf("failed to encode %s: %w", backstageRef(entity), err)
+		}
+	}
+	return enc.Close()
+}
+
+// backstageRef returns the reference of an entity, e.g. resource:project-a/topic-orders
+func backstageRef(entity *BackstageEntity) string {
+	return strings.ToLower(entity.Kind) + ":" + entity.Metadata.Namespace + "/" + entity.Metadata.Name
+}
+
+// backstageName makes s a valid entity name: letters and digits separated by single dashes, underscores or
+// dots, at most 63 characters. Other characters become dashes, longer names are cut and made unique with a hash.
+func backstageName(s string) string {
+	var b strings.Builder
+	separator := false
+	for _, r := range s {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			b.WriteRune(r)
+			separator = false
+			continue
+		}
+		if !separator && b.Len() > 0 {
+			if r != '_' && r != '.' {
+				r = '-'
+			}
+			b.WriteRune(r)
+			separator = true
+		}
+	}
+	name := strings.TrimRight(b.String(), "-_.")
+	if len(name) <= backstageMaxName {
+		return name
+	}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(s))
+	return strings.TrimRight(name[:backstageMaxName-9], "-_.") + fmt.Sprintf("-%08x", h.Sum32())
+}
+
+// backstageOwner returns the group of the node's team, or owner
+func backstageOwner(node *graph.Node, owner string) string {
+	if team := node.Metadata[graph.TeamKey]; team != "" {
+		return "group:" + backstageDefaultNamespace + "/" + backstageName(team)
+	}
+	return owner
+}
+
+// backstageLabels returns the resource labels Backstage accepts as entity labels
+func backstageLabels(node *graph.Node) map[string]string {
+	labels := make(map[string]string)
+	for key, value := range node.Metadata {
+		name, ok := strings.CutPrefix(key, graph.LabelPrefix)
+		if ok && backstageLabel.MatchString(name) && backstageLabel.MatchString(value) {
+			labels[name] = value
+		}
+	}
+	if len(labels) == 0 {
+		return nil
+	}
+	return labels
+}
+
+// backstageAnnotations returns the GCP project and full resource name of a node
+func backstageAnnotations(node *graph.Node) map[string]string {
+	annotations := make(map[string]string)
+	if node.Project != "" {
+		annotations[backstageAnnotationPrefix+"project"] = node.Project
+	}
+	if name := node.Metadata["full_resource_name"]; name != "" {
+		annotations[backstageAnnotationPrefix+"full-resource-name"] = name
+	}
+	if len(annotations) == 0 {
+		return nil
+	}
+	return annotations
+}
+
+// asyncAPIDefinition returns an AsyncAPI document with the topic as its only channel
+func asyncAPIDefinition(node *graph.Node) string {
+	channel := node.Metadata["full_resource_name"]
+	if channel == "" {
+		channel = node.Label
+	}
+	return fmt.Sprintf("asyncapi: 2.6.0\ninfo:\n  title: %q\n  version: \"1\"\nchannels:\n  %q:\n    bindings:\n      googlepubsub:\n        topic: %q\n",
+		node.Label, channel, channel)
+}
+
+// appendRef appends a reference unless it is empty
+func appendRef(refs []string, ref string) []string {
+	if ref == "" {
+		return refs
+	}
+	return append(refs, ref)
+}
+
+// sortedRefs sorts references and drops duplicates
+func sortedRefs(refs []string) []string {
+	sort.Strings(refs)
+	unique := refs[:0]
+	for i, ref := range refs {
+		if i == 0 || ref != refs[i-1] {
+			unique = append(unique, ref)
+		}
+	}
+	if len(unique) == 0 {
+		return nil
+	}
+	return unique
+}
@@ -0,0 +1,68 @@
+package renderer
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/graph"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewBackstageEntities(t *testing.T) {
+	g := testGraph()
+	g.Nodes["topic_a_t"].Metadata = map[string]string{"labels.env": "prod", "labels.x": "-bad", "full_resource_name": "projects/a/topics/t", graph.TeamKey: "payments"}
+	g.AddNode(&graph.Node{ID: "run", Label: "worker", Type: graph.NodeTypeCloudRunService, Project: "b"})
+	g.AddNode(&graph.Node{ID: "df", Label: "enrich", Type: graph.NodeTypeDataflowJob, Project: "a"})
+	g.AddNode(&graph.Node{ID: "sa", Label: "ci", Type: graph.NodeTypeIdentity})
+	g.AddEdge(&graph.Edge{From: "sub_b_s", To: "run", Type: graph.EdgeTypeDelivers})
+	g.AddEdge(&graph.Edge{From: "df", To: "topic_a_t", Type: graph.EdgeTypePublishes})
+	g.AddEdge(&graph.Edge{From: "sa", To: "topic_a_t", Type: graph.EdgeTypePublishes})
+
+	entities := make(map[string]*BackstageEntity)
+	for _, entity := range NewBackstageEntities(g, "group:default/platform") {
+		entities[backstageRef(entity)] = entity
+	}
+	require.Len(t, entities, 6)
+
+	topic := entities["resource:a/topic-t"]
+	require.NotNil(t, topic)
+	assert.Equal(t, "pubsub-topic", topic.Spec.Type)
+	assert.Equal(t, "group:default/payments", topic.Spec.Owner)
+	assert.Equal(t, map[string]string{"env": "prod"}, topic.Metadata.Labels)
+	assert.Equal(t, "projects/a/topics/t", topic.Metadata.Annotations["gcp-visualizer/full-resource-name"])
+
+	api := entities["api:a/t"]
+	require.NotNil(t, api)
+	assert.Equal(t, "asyncapi", api.Spec.Type)
+	assert.Contains(t, api.Spec.Definition, `"projects/a/topics/t"`)
+
+	sub := entities["resource:b/subscription-s"]
+	require.NotNil(t, sub)
+	assert.Equal(t, "group:default/platform", sub.Spec.Owner)
+	assert.Equal(t, []string{"resource:a/topic-t", "resource:default/bucket-gs-bucket"}, sub.Spec.DependsOn)
+
+	worker := entities["component:b/run-worker"]
+	require.NotNil(t, worker)
+	assert.Equal(t, []string{"resource:b/subscription-s"}, worker.Spec.DependsOn)
+	assert.Equal(t, []string{"api:a/t"}, worker.Spec.ConsumesAPIs)
+	assert.Equal(t, []string{"api:a/t"}, entities["component:a/dataflow-enrich"].Spec.ProvidesAPIs)
+}
+
+func TestWriteBackstage(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, WriteBackstage(&buf, testGraph(), "unknown"))
+
+	assert.Equal(t, 4, strings.Count(buf.String(), "apiVersion: backstage.io/v1alpha1"))
+	assert.Contains(t, buf.String(), "---\napiVersion: backstage.io/v1alpha1\nkind: Resource\nmetadata:\n  name: subscription-s\n  namespace: b\n")
+}
+
+func TestBackstageName(t *testing.T) {
+	assert.Equal(t, "gs-bucket", backstageName("gs://bucket"))
+	assert.Equal(t, "orders_v1.created", backstageName("orders_v1.created"))
+
+	long := backstageName(strings.Repeat("a", 100))
+	assert.Len(t, long, 63)
+	assert.NotEqual(t, long, backstageName(strings.Repeat("a", 101)))
+}
@@ -0,0 +1,151 @@
+package renderer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/graph"
+)
+
+// cypherLabels are the Neo4j labels of the node types, besides Resource
+var cypherLabels = map[graph.NodeType]string{
+	graph.NodeTypeTopic:            "Topic",
+	graph.NodeTypeSubscription:     "Subscription",
+	graph.NodeTypeBigQueryTable:    "BigQueryTable",
+	graph.NodeTypeStorageBucket:    "StorageBucket",
+	graph.NodeTypeIdentity:         "Identity",
+	graph.NodeTypeCloudRunService:  "CloudRunService",
+	graph.NodeTypeCloudFunction:    "CloudFunction",
+	graph.NodeTypeDataflowJob:      "DataflowJob",
+	graph.NodeTypeLiteTopic:        "LiteTopic",
+	graph.NodeTypeLiteSubscription: "LiteSubscription",
+	graph.NodeTypeProject:          "ProjectSummary",
+}
+
+// WriteCypher writes the graph as Cypher statements, one per line, that create or update it in Neo4j,
+// e.g. with "cypher-shell -f graph.cypher". Every node is a Resource, also labelled by its type, with
+// its metadata as properties and an IN_PROJECT relationship to its Project. Edges are relationships named
+// after their type, e.g. CROSS_PROJECT. Statements MERGE on the node IDs, so loading a newer export
+// updates the database in place. Output is sorted so it is deterministic.
+func WriteCypher(w io.Writer, g *graph.Graph) error {
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintln(bw, "CREATE CONSTRAINT project_id IF NOT EXISTS FOR (p:Project) REQUIRE p.id IS UNIQUE;")
+	fmt.Fprintln(bw, "CREATE CONSTRAINT resource_id IF NOT EXISTS FOR (r:Resource) REQUIRE r.id IS UNIQUE;")
+
+	ids := make([]string, 0, len(g.Nodes))
+	projects := make(map[string]bool)
+	for id, node := range g.Nodes {
+		ids = append(ids, id)
+		if node.Project != "" {
+			projects[node.Project] = true
+		}
+	}
+	sort.Strings(ids)
+	projectIDs := make([]string, 0, len(projects))
+	for project := range projects {
+		projectIDs = append(projectIDs, project)
+	}
+	sort.Strings(projectIDs)
+
+	for _, project := range projectIDs {
+		fmt.Fprintf(bw, "MERGE (p:Project {id: %s});\n", cypherString(project))
+	}
+
+	for _, id := range ids {
+		node := g.Nodes[id]
+		props := map[string]string{"name": node.Label, "type": string(node.Type)}
+		if node.Project != "" {
+			props["project"] = node.Project
+		}
+		for key, value := range node.Metadata {
+			if _, ok := props[key]; !ok {
+				props[key] = value
+			}
+		}
+		fmt.Fprintf(bw, "MERGE (n:Resource {id: %s}) SET n:%s, n += %s;\n", cypherString(id), cypherLabel(node.Type), cypherMap(props))
+		if node.Project != "" {
+			fmt.Fprintf(bw, "MATCH (n:Resource {id: %s}), (p:Project {id: %s}) MERGE (n)-[:IN_PROJECT]->(p);\n",
+				cypherString(id), cypherString(node.Project))
+		}
+	}
+
+	edges := append([]*graph.Edge(nil), g.Edges...)
+	sort.SliceStable(edges, func(i, j int) bool {
+		if edges[i].From != edges[j].From {
+			return edges[i].From < edges[j].From
+		}
+		return edges[i].To < edges[j].To
+	})
+	for _, edge := range edges {
+		if _, ok := g.Nodes[edge.From]; !ok {
+			continue
+		}
+		if _, ok := g.Nodes[edge.To]; !ok {
+			continue
+		}
+		fmt.Fprintf(bw, "MATCH (a:Resource {id: %s}), (b:Resource {id: %s}) MERGE (a)-[r:%s]->(b)",
+			cypherString(edge.From), cypherString(edge.To), cypherName(strings.ToUpper(string(edge.Type))))
+		if edge.Label != "" {
+			fmt.Fprintf(bw, " SET r.label = %s", cypherString(edge.Label))
+		}
+		if edge.Inferred {
+			fmt.Fprint(bw, " SET r.inferred = true")
+		}
+		fmt.Fprintln(bw, ";")
+	}
+
+	return bw.Flush()
+}
+
+// cypherLabel returns the Neo4j label of a node type, e.g. CloudRunService
+func cypherLabel(nodeType graph.NodeType) string {
+	if label, ok := cypherLabels[nodeType]; ok {
+		return label
+	}
+	var b strings.Builder
+	for _, word := range strings.Split(string(nodeType), "_") {
+		if word != "" {
+			b.WriteString(strings.ToUpper(word[:1]) + word[1:])
+		}
+	}
+	return cypherName(b.String())
+}
+
+// cypherMap formats props as a Cypher map literal with sorted keys
+func cypherMap(props map[string]string) string {
+	keys := make([]string, 0, len(props))
+	for key := range props {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	entries := make([]string, 0, len(keys))
+	for _, key := range keys {
+		entries = append(entries, cypherName(key)+": "+cypherString(props[key]))
+	}
+	return "{" + strings.Join(entries, ", ") + "}"
+}
+
+// cypherName quotes a label, relationship type or property key with backticks
+// unless it is a plain identifier, e.g. `labels.team`
+func cypherName(name string) string {
+	plain := name != ""
+	for i, r := range name {
+		if !(r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || i > 0 && r >= '0' && r <= '9') {
+			plain = false
+			break
+		}
+	}
+	if plain {
+		return name
+	}
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+// cypherString quotes s as a Cypher string literal
+func cypherString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`).Replace(s) + `"`
+}
@@ -0,0 +1,42 @@
+package renderer
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/graph"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteCypher(t *testing.T) {
+	g := testGraph()
+	g.Nodes["topic_a_t"].Metadata = map[string]string{"labels.team": "payments", "full_resource_name": "projects/a/topics/t"}
+	g.AddNode(&graph.Node{ID: "sa", Label: `ci "bot"`, Type: graph.NodeTypeIdentity})
+	g.AddEdge(&graph.Edge{From: "sa", To: "topic_a_t", Type: graph.EdgeTypePublishes, Inferred: true})
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteCypher(&buf, g))
+
+	assert.Equal(t, `CREATE CONSTRAINT project_id IF NOT EXISTS FOR (p:Project) REQUIRE p.id IS UNIQUE;
+CREATE CONSTRAINT resource_id IF NOT EXISTS FOR (r:Resource) REQUIRE r.id IS UNIQUE;
+MERGE (p:Project {id: "a"});
+MERGE (p:Project {id: "b"});
+MERGE (n:Resource {id: "gcs_bucket"}) SET n:StorageBucket, n += {name: "gs://bucket", type: "storage_bucket"};
+MERGE (n:Resource {id: "sa"}) SET n:Identity, n += {name: "ci \"bot\"", type: "identity"};
+MERGE (n:Resource {id: "sub_b_s"}) SET n:Subscription, n += {name: "s", project: "b", type: "subscription"};
+MATCH (n:Resource {id: "sub_b_s"}), (p:Project {id: "b"}) MERGE (n)-[:IN_PROJECT]->(p);
+MERGE (n:Resource {id: "topic_a_t"}) SET n:Topic, n += {full_resource_name: "projects/a/topics/t", `+"`labels.team`"+`: "payments", name: "t", project: "a", type: "topic"};
+MATCH (n:Resource {id: "topic_a_t"}), (p:Project {id: "a"}) MERGE (n)-[:IN_PROJECT]->(p);
+MATCH (a:Resource {id: "sa"}), (b:Resource {id: "topic_a_t"}) MERGE (a)-[r:PUBLISHES]->(b) SET r.inferred = true;
+MATCH (a:Resource {id: "sub_b_s"}), (b:Resource {id: "gcs_bucket"}) MERGE (a)-[r:DELIVERS]->(b);
+MATCH (a:Resource {id: "sub_b_s"}), (b:Resource {id: "topic_a_t"}) MERGE (a)-[r:CROSS_PROJECT]->(b);
+`, buf.String())
+}
+
+func TestCypherName(t *testing.T) {
+	assert.Equal(t, "full_resource_name", cypherName("full_resource_name"))
+	assert.Equal(t, "`labels.team`", cypherName("labels.team"))
+	assert.Equal(t, "`2fa`", cypherName("2fa"))
+	assert.Equal(t, "`a``b`", cypherName("a`b"))
+}
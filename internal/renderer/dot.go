@@ -0,0 +1,166 @@
+package renderer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/graph"
+)
+
+// nodeStyle holds the Graphviz attributes used for a node type, its color is set by the theme
+type nodeStyle struct {
+	shape string
+}
+
+var nodeStyles = map[graph.NodeType]nodeStyle{
+	graph.NodeTypeTopic:            {shape: "invhouse"},
+	graph.NodeTypeSubscription:     {shape: "box"},
+	graph.NodeTypeBigQueryTable:    {shape: "cylinder"},
+	graph.NodeTypeStorageBucket:    {shape: "folder"},
+	graph.NodeTypeIdentity:         {shape: "ellipse"},
+	graph.NodeTypeCloudRunService:  {shape: "component"},
+	graph.NodeTypeCloudFunction:    {shape: "cds"},
+	graph.NodeTypeDataflowJob:      {shape: "hexagon"},
+	graph.NodeTypeLiteTopic:        {shape: "trapezium"},
+	graph.NodeTypeLiteSubscription: {shape: "box3d"},
+	graph.NodeTypeProject:          {shape: "tab"},
+}
+
+// WriteDOT writes the graph in Graphviz DOT format, drawn with opts.
+// Output is deterministic: clusters, nodes and edges are written in sorted order.
+func WriteDOT(w io.Writer, g *graph.Graph, opts Options) error {
+	theme := opts.Theme.orDefault()
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintln(bw, "digraph gcp {")
+	fmt.Fprintln(bw, "  overlap=scale;")
+	fmt.Fprintln(bw, "  splines=line;")
+	fmt.Fprintln(bw, "  compound=true;")
+	fmt.Fprintf(bw, "  bgcolor=%s;\n", quote(theme.Background))
+	fmt.Fprintf(bw, "  fontcolor=%s;\n", quote(theme.Text))
+	fmt.Fprintf(bw, "  node [style=filled, color=%s, fontcolor=%s];\n", quote(theme.NodeBorder), quote(theme.Text))
+	fmt.Fprintf(bw, "  edge [color=%s, fontcolor=%s];\n", quote(theme.Edge.Color), quote(theme.Text))
+
+	// Project clusters
+	projects := make([]string, 0, len(g.Clusters))
+	for projectID := range g.Clusters {
+		projects = append(projects, projectID)
+	}
+	sort.Strings(projects)
+
+	for _, projectID := range projects {
+		cluster := g.Clusters[projectID]
+		fmt.Fprintf(bw, "  subgraph %s {\n", quote(cluster.ID))
+		fmt.Fprintf(bw, "    label=%s;\n", quote(cluster.Label))
+		fmt.Fprintln(bw, "    style=filled;")
+		fmt.Fprintf(bw, "    fillcolor=%s;\n", quote(theme.Cluster))
+		fmt.Fprintf(bw, "    pencolor=%s;\n", quote(theme.ClusterBorder))
+
+		nodeIDs := append([]string(nil), cluster.Nodes...)
+		sort.Strings(nodeIDs)
+		for _, nodeID := range nodeIDs {
+			writeNode(bw, "    ", g.Nodes[nodeID], theme)
+		}
+		fmt.Fprintln(bw, "  }")
+	}
+
+	// Nodes without a cluster, such as those without a project
+	clusterOf := g.ClusterOf()
+	var unclustered []string
+	for id := range g.Nodes {
+		if _, ok := clusterOf[id]; !ok {
+			unclustered = append(unclustered, id)
+		}
+	}
+	sort.Strings(unclustered)
+	for _, nodeID := range unclustered {
+		writeNode(bw, "  ", g.Nodes[nodeID], theme)
+	}
+
+	// Edges
+	edges := append([]*graph.Edge(nil), g.Edges...)
+	sort.SliceStable(edges, func(i, j int) bool {
+		if edges[i].From != edges[j].From {
+			return edges[i].From < edges[j].From
+		}
+		return edges[i].To < edges[j].To
+	})
+	for _, edge := range edges {
+		fmt.Fprintf(bw, "  %s -> %s", quote(edge.From), quote(edge.To))
+		if attrs := edgeAttrs(edge, theme); len(attrs) > 0 {
+			fmt.Fprintf(bw, " [%s]", strings.Join(attrs, ", "))
+		}
+		fmt.Fprintln(bw, ";")
+	}
+
+	// After the clusters, which would otherwise take the position of the legend for their labels
+	if opts.Legend != nil {
+		writeDOTLegend(bw, opts.Legend.entries(g, theme), theme)
+	}
+
+	fmt.Fprintln(bw, "}")
+	return bw.Flush()
+}
+
+// edgeAttrs returns the DOT attributes of an edge, overlay colors take precedence over the theme.
+// Edges drawn in the theme's default edge color leave it to the edge defaults.
+func edgeAttrs(edge *graph.Edge, theme *Theme) []string {
+	var attrs []string
+	style := theme.edgeStyle(edge)
+	switch {
+	case edge.Inferred:
+		// Replaces the style of the edge type, the color still tells the type apart
+		attrs = append(attrs, "style=dotted")
+	case style.Style != "" && style.Style != LineSolid:
+		attrs = append(attrs, "style="+style.Style)
+	}
+	color := style.Color
+	if edge.Color != "" {
+		color = edge.Color
+	}
+	if color != "" && color != theme.Edge.Color {
+		attrs = append(attrs, "color="+quote(color))
+	}
+	if edge.Width > 0 {
+		attrs = append(attrs, "penwidth="+strconv.FormatFloat(edge.Width, 'f', 1, 64))
+	}
+	if edge.Label != "" {
+		attrs = append(attrs, "label="+quote(edge.Label))
+	}
+	return attrs
+}
+
+func writeNode(w io.Writer, indent string, node *graph.Node, theme *Theme) {
+	if node == nil {
+		return
+	}
+	shape := nodeStyles[node.Type].shape
+	if node.Shape != "" {
+		shape = node.Shape
+	}
+	fmt.Fprintf(w, "%s%s [label=%s", indent, quote(node.ID), quote(node.Label))
+	if shape != "" {
+		fmt.Fprintf(w, ", shape=%s", shape)
+	}
+	if color := theme.fillColor(node); color != "" {
+		fmt.Fprintf(w, ", fillcolor=%s", quote(color))
+	}
+	if node.Border != "" {
+		fmt.Fprintf(w, ", color=%s, penwidth=3", quote(node.Border))
+	}
+	if node.Count > 0 {
+		fmt.Fprintf(w, ", xlabel=%s", quote(strconv.Itoa(node.Count)))
+	}
+	fmt.Fprintln(w, "];")
+}
+
+// quote returns s as a double-quoted DOT string
+func quote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + s + `"`
+}
@@ -0,0 +1,114 @@
+package renderer
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/graph"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testGraph() *graph.Graph {
+	g := graph.New()
+	g.AddNode(&graph.Node{ID: "topic_a_t", Label: "t", Type: graph.NodeTypeTopic, Project: "a"})
+	g.AddNode(&graph.Node{ID: "sub_b_s", Label: "s", Type: graph.NodeTypeSubscription, Project: "b"})
+	g.AddNode(&graph.Node{ID: "gcs_bucket", Label: "gs://bucket", Type: graph.NodeTypeStorageBucket})
+	g.AddEdge(&graph.Edge{From: "sub_b_s", To: "topic_a_t", Type: graph.EdgeTypeCrossProject})
+	g.AddEdge(&graph.Edge{From: "sub_b_s", To: "gcs_bucket", Type: graph.EdgeTypeDelivers})
+	return g
+}
+
+func TestWriteDOT(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, WriteDOT(&buf, testGraph(), Options{}))
+	out := buf.String()
+
+	assert.Contains(t, out, "digraph gcp {")
+	assert.Contains(t, out, `subgraph "cluster_a"`)
+	assert.Contains(t, out, `subgraph "cluster_b"`)
+	assert.Contains(t, out, `"topic_a_t" [label="t", shape=invhouse, fillcolor="orange"];`)
+	assert.Contains(t, out, `"gcs_bucket" [label="gs://bucket", shape=folder, fillcolor="khaki"];`)
+	assert.Contains(t, out, `"sub_b_s" -> "topic_a_t" [style=dashed, color="red"];`)
+	assert.Contains(t, out, `"sub_b_s" -> "gcs_bucket" [style=bold, color="blue"];`)
+}
+
+func TestWriteDOT_ColorOverrides(t *testing.T) {
+	g := testGraph()
+	g.Nodes["topic_a_t"].Color = "red"
+	for _, e := range g.Edges {
+		e.Color = "gold"
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteDOT(&buf, g, Options{}))
+	out := buf.String()
+
+	assert.Contains(t, out, `"topic_a_t" [label="t", shape=invhouse, fillcolor="red"];`)
+	assert.Contains(t, out, `"sub_b_s" -> "topic_a_t" [style=dashed, color="gold"];`)
+}
+
+func TestWriteDOT_Inferred(t *testing.T) {
+	g := testGraph()
+	g.AddNode(&graph.Node{ID: "identity_user:dev@example.com", Label: "dev@example.com", Type: graph.NodeTypeIdentity})
+	g.AddEdge(&graph.Edge{From: "identity_user:dev@example.com", To: "topic_a_t", Type: graph.EdgeTypePublishes, Label: "can publish", Inferred: true})
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteDOT(&buf, g, Options{}))
+	assert.Contains(t, buf.String(), `"identity_user:dev@example.com" -> "topic_a_t" [style=dotted, color="teal", label="can publish"];`)
+}
+
+func TestWriteDOT_Deterministic(t *testing.T) {
+	var first, second bytes.Buffer
+	require.NoError(t, WriteDOT(&first, testGraph(), Options{}))
+	require.NoError(t, WriteDOT(&second, testGraph(), Options{}))
+	assert.Equal(t, first.String(), second.String())
+}
+
+func TestQuote(t *testing.T) {
+	assert.Equal(t, `"plain"`, quote("plain"))
+	assert.Equal(t, `"with \"quotes\""`, quote(`with "quotes"`))
+	assert.Equal(t, `"back\\slash"`, quote(`back\slash`))
+}
+
+func TestWriteDOT_Width(t *testing.T) {
+	g := testGraph()
+	for _, e := range g.Edges {
+		e.Width = 3.5
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteDOT(&buf, g, Options{}))
+
+	assert.Contains(t, buf.String(), `"sub_b_s" -> "topic_a_t" [style=dashed, color="red", penwidth=3.5];`)
+}
+
+func TestWriteDOT_Border(t *testing.T) {
+	g := testGraph()
+	g.Nodes["topic_a_t"].Border = "red"
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteDOT(&buf, g, Options{}))
+
+	assert.Contains(t, buf.String(), `"topic_a_t" [label="t", shape=invhouse, fillcolor="orange", color="red", penwidth=3];`)
+}
+
+func TestWriteDOT_EdgeLabels(t *testing.T) {
+	g := testGraph()
+	g.AddNode(&graph.Node{ID: "sub_a_s", Label: "s", Type: graph.NodeTypeSubscription, Project: "a"})
+	g.AddEdge(&graph.Edge{From: "sub_a_s", To: "topic_a_t", Type: graph.EdgeTypeSubscribes, Label: "push, ack 10s"})
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteDOT(&buf, g, Options{}))
+	assert.Contains(t, buf.String(), `"sub_a_s" -> "topic_a_t" [label="push, ack 10s"];`)
+	assert.Contains(t, buf.String(), `"sub_b_s" -> "gcs_bucket" [style=bold, color="blue"];`, "edges without a label get none")
+}
+
+func TestWriteDOT_NodeShape(t *testing.T) {
+	g := graph.New()
+	g.AddNode(&graph.Node{ID: "t", Label: "orders-dlq", Type: graph.NodeTypeTopic, Project: "p", Shape: graph.ShapeDiamond})
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteDOT(&buf, g, Options{}))
+	assert.Contains(t, buf.String(), `"t" [label="orders-dlq", shape=diamond,`)
+}
@@ -0,0 +1,55 @@
+package renderer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/graph"
+)
+
+// ErrFormatNeedsGraphviz is returned for output formats the built-in renderers can't produce
+var ErrFormatNeedsGraphviz = errors.New("only svg and png can be rendered without graphviz")
+
+// NewEmbeddedRenderer returns the pure-Go renderer for format, svg or png, drawing with opts
+func NewEmbeddedRenderer(format string, opts Options) (Renderer, error) {
+	switch format {
+	case "svg":
+		return NewSVGRenderer(opts), nil
+	case "png":
+		return NewPNGRenderer(opts), nil
+	}
+	return nil, fmt.Errorf("%w, not %s", ErrFormatNeedsGraphviz, format)
+}
+
+// FallbackRenderer renders with Graphviz and falls back to the pure-Go SVG and
+// PNG renderers with a warning when the Graphviz binary is not installed.
+type FallbackRenderer struct {
+	primary *GraphvizRenderer
+	warn    io.Writer
+}
+
+// NewFallbackRenderer creates a renderer that prefers primary and writes warnings to warn
+func NewFallbackRenderer(primary *GraphvizRenderer, warn io.Writer) *FallbackRenderer {
+	return &FallbackRenderer{
+		primary: primary,
+		warn:    warn,
+	}
+}
+
+// Render implements Renderer. Formats the built-in renderers can't produce, such
+// as PDF, fail with ErrGraphvizNotFound when Graphviz is missing.
+func (r *FallbackRenderer) Render(ctx context.Context, g *graph.Graph, output string, format string) error {
+	err := r.primary.Render(ctx, g, output, format)
+	if !errors.Is(err, ErrGraphvizNotFound) {
+		return err
+	}
+
+	fallback, fallbackErr := NewEmbeddedRenderer(format, r.primary.opts)
+	if fallbackErr != nil {
+		return fmt.Errorf("%w, which %s output needs: install Graphviz from https://graphviz.org/download/ or render svg or png", ErrGraphvizNotFound, format)
+	}
+	fmt.Fprintf(r.warn, "Warning: %v, using built-in layout\n", ErrGraphvizNotFound)
+	return fallback.Render(ctx, g, output, format)
+}
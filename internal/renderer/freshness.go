@@ -0,0 +1,23 @@
+package renderer
+
+import (
+	_ "embed"
+	"fmt"
+	"html/template"
+	"io"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/metrics"
+)
+
+//go:embed assets/freshness.html
+var freshnessHTML string
+
+var freshnessTemplate = template.Must(template.New("freshness").Parse(freshnessHTML))
+
+// WriteFreshnessHTML writes the sync freshness heatmap as a self-contained HTML page
+func WriteFreshnessHTML(w io.Writer, f *metrics.Freshness) error {
+	if err := freshnessTemplate.Execute(w, f); err != nil {
+		return fmt.Errorf("failed to render HTML: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package renderer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/graph"
+)
+
+// ErrGraphvizNotFound is returned when the Graphviz binary is not installed
+var ErrGraphvizNotFound = errors.New("graphviz binary 'dot' not found in PATH")
+
+// CheckGraphviz returns ErrGraphvizNotFound if the Graphviz binary is not installed,
+// so commands can fail before doing any work
+func CheckGraphviz() error {
+	if _, err := exec.LookPath("dot"); err != nil {
+		return fmt.Errorf("%w: %v", ErrGraphvizNotFound, err)
+	}
+	return nil
+}
+
+// GraphvizRenderer renders graphs by piping DOT into the Graphviz binary
+type GraphvizRenderer struct {
+	layout string
+	opts   Options
+}
+
+// NewGraphvizRenderer creates a renderer using the given layout engine (fdp, dot, neato), drawing with opts
+func NewGraphvizRenderer(layout string, opts Options) *GraphvizRenderer {
+	if layout == "" {
+		layout = "fdp"
+	}
+	return &GraphvizRenderer{layout: layout, opts: opts}
+}
+
+// Render writes the graph to output in the given format (svg, png, pdf)
+func (r *GraphvizRenderer) Render(ctx context.Context, g *graph.Graph, output string, format string) error {
+	binary, err := exec.LookPath("dot")
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrGraphvizNotFound, err)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteDOT(&buf, g, r.opts); err != nil {
+		return fmt.Errorf("failed to write DOT: %w", err)
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, binary, "-K"+r.layout, "-T"+format, "-o", output)
+	cmd.Stdin = &buf
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("graphviz failed: %w: %s", err, stderr.String())
+	}
+	return nil
+}
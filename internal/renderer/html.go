@@ -0,0 +1,236 @@
+package renderer
+
+import (
+	"bufio"
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"text/template"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/graph"
+)
+
+//go:embed assets/viewer.html
+var viewerHTML string
+
+//go:embed assets/viewer.js
+var viewerJS string
+
+var viewerTemplate = template.Must(template.New("viewer").Parse(viewerHTML))
+
+// HTMLRenderer renders graphs as a self-contained interactive HTML page.
+// Layout is computed in Go, so no Graphviz binary is required.
+type HTMLRenderer struct {
+	opts Options
+}
+
+// NewHTMLRenderer creates a new HTML renderer drawing with opts
+func NewHTMLRenderer(opts Options) *HTMLRenderer {
+	return &HTMLRenderer{opts: opts}
+}
+
+// htmlNode is the JSON representation of a node in the viewer
+type htmlNode struct {
+	Box
+	ID       string            `json:"id"`
+	Label    string            `json:"label"`
+	Type     graph.NodeType    `json:"type"`
+	Project  string            `json:"project,omitempty"`
+	Color    string            `json:"color"`
+	Border   string            `json:"border,omitempty"`
+	Shape    string            `json:"shape,omitempty"` // set by style rules, a rectangle if empty
+	Count    int               `json:"count,omitempty"` // resources a collapsed node stands for
+	Metadata map[string]string `json:"metadata,omitempty"`
+	Status   string            `json:"status,omitempty"` // diff mode only
+}
+
+// htmlCluster is the JSON representation of a project cluster in the viewer
+type htmlCluster struct {
+	Box
+	Label string `json:"label"`
+}
+
+// htmlEdge is the JSON representation of an edge in the viewer
+type htmlEdge struct {
+	From     string         `json:"from"`
+	To       string         `json:"to"`
+	Type     graph.EdgeType `json:"type"`
+	Label    string         `json:"label,omitempty"`
+	Color    string         `json:"color"`
+	Dash     string         `json:"dash,omitempty"` // SVG stroke-dasharray, empty for solid lines
+	Inferred bool           `json:"inferred,omitempty"`
+	Width    float64        `json:"width"`
+	Status   string         `json:"status,omitempty"` // diff mode only
+}
+
+// htmlTheme holds the theme colors the viewer draws the page and clusters with
+type htmlTheme struct {
+	Background    string `json:"background"`
+	Text          string `json:"text"`
+	Cluster       string `json:"cluster"`
+	ClusterBorder string `json:"cluster_border"`
+	NodeBorder    string `json:"node_border"`
+	Arrow         string `json:"arrow"`
+}
+
+// htmlLegendEntry is a line of the legend sidebar, see legendEntry
+type htmlLegendEntry struct {
+	Label  string  `json:"label"`
+	Fill   string  `json:"fill,omitempty"`   // node types only
+	Stroke string  `json:"stroke,omitempty"` // edge types only
+	Dash   string  `json:"dash,omitempty"`
+	Width  float64 `json:"width,omitempty"`
+}
+
+// htmlData is the full data set embedded in the page
+type htmlData struct {
+	Diff     bool              `json:"diff"`
+	Theme    htmlTheme         `json:"theme"`
+	Legend   []htmlLegendEntry `json:"legend,omitempty"` // no sidebar if empty
+	Width    float64           `json:"width"`
+	Height   float64           `json:"height"`
+	Clusters []htmlCluster     `json:"clusters"`
+	Nodes    []htmlNode        `json:"nodes"`
+	Edges    []htmlEdge        `json:"edges"`
+}
+
+// Render writes the graph to output as HTML. The format argument is ignored.
+func (r *HTMLRenderer) Render(ctx context.Context, g *graph.Graph, output string, format string) error {
+	f, err := os.Create(output)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", output, err)
+	}
+
+	if err := WriteHTML(f, g, r.opts); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// WriteHTML writes the graph as a self-contained HTML page with pan, zoom,
+// search and click-to-expand metadata, drawn with opts.
+func WriteHTML(w io.Writer, g *graph.Graph, opts Options) error {
+	return writeViewer(w, newHTMLData(g, opts))
+}
+
+// WriteHTMLDiff writes a diff of two runs as a self-contained HTML page with a
+// before/after slider. Added and removed elements fade in and out as the slider
+// moves, and every node keeps its position in both runs.
+func WriteHTMLDiff(w io.Writer, d *graph.Diff, opts Options) error {
+	data := newHTMLData(d.Graph, opts)
+	data.Diff = true
+	for i := range data.Nodes {
+		data.Nodes[i].Status = string(d.Nodes[data.Nodes[i].ID])
+	}
+	for i, edge := range d.Graph.Edges {
+		data.Edges[i].Status = string(d.Edges[graph.EdgeKey(edge)])
+	}
+	return writeViewer(w, data)
+}
+
+// newHTMLData lays out the graph and converts it to the data embedded in the page
+func newHTMLData(g *graph.Graph, opts Options) htmlData {
+	theme := opts.Theme.orDefault()
+	layout := ComputeLayout(g)
+
+	data := htmlData{
+		Theme: htmlTheme{
+			Background:    theme.Background,
+			Text:          theme.Text,
+			Cluster:       theme.Cluster,
+			ClusterBorder: theme.ClusterBorder,
+			NodeBorder:    theme.NodeBorder,
+			Arrow:         theme.Edge.Color,
+		},
+		Width:    layout.Width,
+		Height:   layout.Height,
+		Clusters: []htmlCluster{},
+		Nodes:    []htmlNode{},
+		Edges:    []htmlEdge{},
+	}
+
+	projects := make([]string, 0, len(layout.Clusters))
+	for projectID := range layout.Clusters {
+		projects = append(projects, projectID)
+	}
+	sort.Strings(projects)
+	for _, projectID := range projects {
+		data.Clusters = append(data.Clusters, htmlCluster{
+			Box:   layout.Clusters[projectID],
+			Label: g.Clusters[projectID].Label,
+		})
+	}
+
+	if opts.Legend != nil {
+		for _, entry := range opts.Legend.entries(g, theme) {
+			item := htmlLegendEntry{Label: entry.label, Fill: entry.fill}
+			if entry.stroke != nil {
+				item.Stroke, item.Dash, item.Width = entry.stroke.color, dashArray(*entry.stroke), entry.stroke.width
+			}
+			data.Legend = append(data.Legend, item)
+		}
+	}
+
+	ids := make([]string, 0, len(g.Nodes))
+	for id := range g.Nodes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		node := g.Nodes[id]
+		data.Nodes = append(data.Nodes, htmlNode{
+			Box:      layout.Nodes[id],
+			ID:       node.ID,
+			Label:    node.Label,
+			Type:     node.Type,
+			Project:  node.Project,
+			Color:    theme.fillColor(node),
+			Border:   node.Border,
+			Shape:    node.Shape,
+			Count:    node.Count,
+			Metadata: node.Metadata,
+		})
+	}
+
+	for _, edge := range g.Edges {
+		stroke := theme.stroke(edge)
+		data.Edges = append(data.Edges, htmlEdge{
+			From:     edge.From,
+			To:       edge.To,
+			Type:     edge.Type,
+			Label:    edge.Label,
+			Color:    stroke.color,
+			Dash:     dashArray(stroke),
+			Inferred: edge.Inferred,
+			Width:    stroke.width,
+		})
+	}
+
+	return data
+}
+
+// writeViewer embeds the data in the viewer page and writes it to w
+func writeViewer(w io.Writer, data htmlData) error {
+	// json.Marshal escapes <, > and &, so the data is safe inside a <script> element
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to encode graph data: %w", err)
+	}
+
+	bw := bufio.NewWriter(w)
+	if err := viewerTemplate.Execute(bw, struct {
+		Data   string
+		Script string
+	}{
+		Data:   string(encoded),
+		Script: viewerJS,
+	}); err != nil {
+		return fmt.Errorf("failed to render HTML: %w", err)
+	}
+	return bw.Flush()
+}
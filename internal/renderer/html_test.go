@@ -0,0 +1,90 @@
+package renderer
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"testing"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/graph"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteHTML(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, WriteHTML(&buf, testGraph(), Options{}))
+	out := buf.String()
+
+	assert.Contains(t, out, "<!DOCTYPE html>")
+	assert.Contains(t, out, `id="search"`)
+	assert.Contains(t, out, "function enablePanZoom")
+
+	// The embedded data must be valid JSON containing every node and edge
+	m := regexp.MustCompile(`var GRAPH_DATA = (.*);`).FindStringSubmatch(out)
+	require.Len(t, m, 2)
+	var data htmlData
+	require.NoError(t, json.Unmarshal([]byte(m[1]), &data))
+	assert.Len(t, data.Nodes, 3)
+	assert.Len(t, data.Edges, 2)
+	assert.Len(t, data.Clusters, 2)
+}
+
+func TestWriteHTML_EscapesScript(t *testing.T) {
+	g := graph.New()
+	g.AddNode(&graph.Node{ID: "x", Label: "</script><script>alert(1)</script>", Type: graph.NodeTypeTopic, Project: "p"})
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteHTML(&buf, g, Options{}))
+	assert.NotContains(t, buf.String(), "<script>alert(1)")
+}
+
+func TestComputeLayout(t *testing.T) {
+	g := testGraph()
+	l := ComputeLayout(g)
+
+	require.Len(t, l.Nodes, len(g.Nodes))
+	assert.Len(t, l.Clusters, 2)
+
+	// Every clustered node must lie inside its cluster
+	for id, node := range g.Nodes {
+		box := l.Nodes[id]
+		if node.Project == "" {
+			continue
+		}
+		c := l.Clusters[node.Project]
+		assert.GreaterOrEqual(t, box.X, c.X, id)
+		assert.GreaterOrEqual(t, box.Y, c.Y, id)
+		assert.LessOrEqual(t, box.X+box.W, c.X+c.W, id)
+		assert.LessOrEqual(t, box.Y+box.H, c.Y+c.H, id)
+	}
+	assert.Greater(t, l.Width, 0.0)
+	assert.Greater(t, l.Height, 0.0)
+}
+
+func TestWriteHTMLDiff(t *testing.T) {
+	before := testGraph()
+	after := testGraph()
+	after.AddNode(&graph.Node{ID: "sub_a_new", Label: "new", Type: graph.NodeTypeSubscription, Project: "a"})
+	after.AddEdge(&graph.Edge{From: "sub_a_new", To: "topic_a_t", Type: graph.EdgeTypeSubscribes})
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteHTMLDiff(&buf, graph.Compare(before, after), Options{}))
+	out := buf.String()
+	assert.Contains(t, out, `id="diff-slider"`)
+
+	m := regexp.MustCompile(`var GRAPH_DATA = (.*);`).FindStringSubmatch(out)
+	require.Len(t, m, 2)
+	var data htmlData
+	require.NoError(t, json.Unmarshal([]byte(m[1]), &data))
+	assert.True(t, data.Diff)
+
+	statuses := make(map[string]string)
+	for _, n := range data.Nodes {
+		statuses[n.ID] = n.Status
+	}
+	assert.Equal(t, "added", statuses["sub_a_new"])
+	assert.Equal(t, "unchanged", statuses["topic_a_t"])
+	require.Len(t, data.Edges, 3)
+	assert.Equal(t, "added", data.Edges[2].Status)
+}
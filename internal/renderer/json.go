@@ -0,0 +1,175 @@
+package renderer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/graph"
+)
+
+// JSONSchemaVersion is bumped on any incompatible change to the JSON document.
+// Adding new optional fields or node/edge kinds is not considered incompatible.
+const JSONSchemaVersion = 1
+
+// JSONDocument is the stable JSON representation of a graph
+type JSONDocument struct {
+	SchemaVersion int        `json:"schema_version"`
+	Projects      []string   `json:"projects"`
+	Nodes         []JSONNode `json:"nodes"`
+	Edges         []JSONEdge `json:"edges"`
+}
+
+// JSONNode is a node in the JSON document
+type JSONNode struct {
+	ID       string            `json:"id"`
+	Type     string            `json:"type"`
+	Label    string            `json:"label"`
+	Project  string            `json:"project,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+	Count    int               `json:"count,omitempty"` // resources a collapsed node stands for
+	Team     string            `json:"team,omitempty"`  // owning the node's project, see graph.Ownership
+}
+
+// JSONEdge is an edge in the JSON document, Kind is the relationship kind
+type JSONEdge struct {
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Kind  string `json:"kind"`
+	Label string `json:"label,omitempty"`
+
+	// Inferred edges come from IAM bindings alone, see graph.Edge
+	Inferred bool `json:"inferred,omitempty"`
+}
+
+// JSONRenderer renders graphs as a versioned JSON document
+type JSONRenderer struct{}
+
+// NewJSONRenderer creates a new JSON renderer
+func NewJSONRenderer() *JSONRenderer {
+	return &JSONRenderer{}
+}
+
+// Render writes the graph to output as JSON. The format argument is ignored.
+func (r *JSONRenderer) Render(ctx context.Context, g *graph.Graph, output string, format string) error {
+	f, err := os.Create(output)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", output, err)
+	}
+
+	if err := WriteJSON(f, g); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// NewJSONDocument converts a graph to its JSON document.
+// Projects, nodes and edges are sorted so output is deterministic.
+func NewJSONDocument(g *graph.Graph) *JSONDocument {
+	doc := &JSONDocument{
+		SchemaVersion: JSONSchemaVersion,
+		Projects:      []string{},
+		Nodes:         make([]JSONNode, 0, len(g.Nodes)),
+		Edges:         make([]JSONEdge, 0, len(g.Edges)),
+	}
+
+	// Clusters may be grouped by team, see graph.GroupByTeam
+	projects := make(map[string]bool)
+	for _, node := range g.Nodes {
+		if node.Project != "" && !projects[node.Project] {
+			projects[node.Project] = true
+			doc.Projects = append(doc.Projects, node.Project)
+		}
+	}
+	sort.Strings(doc.Projects)
+
+	for _, node := range g.Nodes {
+		doc.Nodes = append(doc.Nodes, JSONNode{
+			ID:       node.ID,
+			Type:     string(node.Type),
+			Label:    node.Label,
+			Project:  node.Project,
+			Metadata: node.Metadata,
+			Count:    node.Count,
+			Team:     node.Metadata[graph.TeamKey],
+		})
+	}
+	sort.Slice(doc.Nodes, func(i, j int) bool { return doc.Nodes[i].ID < doc.Nodes[j].ID })
+
+	for _, edge := range g.Edges {
+		doc.Edges = append(doc.Edges, JSONEdge{
+			From:     edge.From,
+			To:       edge.To,
+			Kind:     string(edge.Type),
+			Label:    edge.Label,
+			Inferred: edge.Inferred,
+		})
+	}
+	sort.SliceStable(doc.Edges, func(i, j int) bool {
+		if doc.Edges[i].From != doc.Edges[j].From {
+			return doc.Edges[i].From < doc.Edges[j].From
+		}
+		return doc.Edges[i].To < doc.Edges[j].To
+	})
+
+	return doc
+}
+
+// WriteJSON writes the graph as an indented JSON document
+func WriteJSON(w io.Writer, g *graph.Graph) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(NewJSONDocument(g))
+}
+
+// ReadJSON reads a JSON document written by WriteJSON back into a graph.
+// Documents with a newer schema version than this build understands are rejected.
+func ReadJSON(r io.Reader) (*graph.Graph, error) {
+	var doc JSONDocument
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to decode graph document: %w", err)
+	}
+	if doc.SchemaVersion < 1 || doc.SchemaVersion > JSONSchemaVersion {
+		return nil, fmt.Errorf("unsupported graph document schema version %d", doc.SchemaVersion)
+	}
+
+	g := graph.New()
+	for _, node := range doc.Nodes {
+		g.AddNode(&graph.Node{
+			ID:       node.ID,
+			Label:    node.Label,
+			Type:     graph.NodeType(node.Type),
+			Project:  node.Project,
+			Metadata: node.Metadata,
+		})
+	}
+	for _, edge := range doc.Edges {
+		g.AddEdge(&graph.Edge{
+			From:     edge.From,
+			To:       edge.To,
+			Label:    edge.Label,
+			Type:     graph.EdgeType(edge.Kind),
+			Inferred: edge.Inferred,
+		})
+	}
+	return g, nil
+}
+
+// ReadJSONFile reads a JSON document from a file, see ReadJSON
+func ReadJSONFile(path string) (*graph.Graph, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer func() { _ = f.Close() }()
+
+	g, err := ReadJSON(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return g, nil
+}
@@ -0,0 +1,52 @@
+package renderer
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/graph"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteJSON(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, WriteJSON(&buf, testGraph()))
+
+	var doc JSONDocument
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &doc))
+
+	assert.Equal(t, JSONSchemaVersion, doc.SchemaVersion)
+	assert.Equal(t, []string{"a", "b"}, doc.Projects)
+	require.Len(t, doc.Nodes, 3)
+	assert.Equal(t, "gcs_bucket", doc.Nodes[0].ID)
+	assert.Equal(t, string(graph.NodeTypeStorageBucket), doc.Nodes[0].Type)
+	require.Len(t, doc.Edges, 2)
+	assert.Equal(t, string(graph.EdgeTypeDelivers), doc.Edges[0].Kind)
+}
+
+func TestWriteJSON_EmptyGraph(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, WriteJSON(&buf, graph.New()))
+	assert.JSONEq(t, `{"schema_version":1,"projects":[],"nodes":[],"edges":[]}`, buf.String())
+}
+
+func TestReadJSON_RoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, WriteJSON(&buf, testGraph()))
+
+	g, err := ReadJSON(&buf)
+	require.NoError(t, err)
+	require.Len(t, g.Nodes, 3)
+	assert.Equal(t, graph.NodeTypeStorageBucket, g.Nodes["gcs_bucket"].Type)
+	assert.Contains(t, g.Clusters, "a")
+	require.Len(t, g.Edges, 2)
+	assert.Equal(t, graph.EdgeTypeDelivers, g.Edges[0].Type)
+}
+
+func TestReadJSON_RejectsNewerSchema(t *testing.T) {
+	_, err := ReadJSON(strings.NewReader(`{"schema_version": 99, "nodes": [], "edges": []}`))
+	assert.ErrorContains(t, err, "schema version 99")
+}
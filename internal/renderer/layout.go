@@ -0,0 +1,176 @@
+package renderer
+
+import (
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/graph"
+)
+
+// Layout sizes used by the pure-Go layout
+const (
+	nodeHeight     = 36.0
+	nodeMinWidth   = 120.0
+	charWidth      = 7.0
+	nodeGap        = 16.0
+	columnGap      = 80.0
+	clusterPadding = 24.0
+	clusterHeader  = 28.0
+	clusterGap     = 60.0
+)
+
+// Box is a positioned rectangle, X and Y are the top-left corner
+type Box struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+	W float64 `json:"w"`
+	H float64 `json:"h"`
+}
+
+// Layout holds node and cluster positions computed without Graphviz
+type Layout struct {
+	Nodes    map[string]Box
+	Clusters map[string]Box // keyed by project ID
+	Width    float64
+	Height   float64
+}
+
+// columnOf returns the column a node type is placed in within its cluster,
+// so data flows left to right: topics, subscriptions, sinks, then consumers.
+func columnOf(t graph.NodeType) int {
+	switch t {
+	case graph.NodeTypeTopic:
+		return 0
+	case graph.NodeTypeSubscription:
+		return 1
+	case graph.NodeTypeIdentity, graph.NodeTypeCloudRunService, graph.NodeTypeCloudFunction:
+		return 3
+	default:
+		return 2
+	}
+}
+
+// countBadge returns the box of the badge counting the resources of a summary node in b,
+// centered on its top right corner and wide enough for count
+func countBadge(b Box, count int) Box {
+	w := max(18, float64(len(strconv.Itoa(count)))*charWidth+8)
+	return Box{X: b.X + b.W - w/2, Y: b.Y - 9, W: w, H: 18}
+}
+
+// ComputeLayout places every project cluster in a grid and arranges the nodes
+// of each cluster in columns by type. The result is deterministic.
+func ComputeLayout(g *graph.Graph) *Layout {
+	l := &Layout{
+		Nodes:    make(map[string]Box, len(g.Nodes)),
+		Clusters: make(map[string]Box, len(g.Clusters)),
+	}
+
+	// Group node IDs per cluster, nodes without a cluster go into a trailing group
+	clusterOf := g.ClusterOf()
+	groups := make(map[string][]string)
+	for id := range g.Nodes {
+		groups[clusterOf[id]] = append(groups[clusterOf[id]], id)
+	}
+	keys := make([]string, 0, len(groups))
+	for k := range groups {
+		if k != "" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	if _, ok := groups[""]; ok {
+		keys = append(keys, "")
+	}
+
+	// Lay out each group locally, then place groups in a grid
+	type local struct {
+		nodes map[string]Box
+		w, h  float64
+	}
+	locals := make([]local, len(keys))
+	for i, key := range keys {
+		nodes, w, h := layoutGroup(g, groups[key])
+		locals[i] = local{nodes: nodes, w: w, h: h}
+	}
+
+	perRow := int(math.Ceil(math.Sqrt(float64(len(keys)))))
+	if perRow == 0 {
+		perRow = 1
+	}
+
+	y := 0.0
+	for row := 0; row*perRow < len(keys); row++ {
+		x := 0.0
+		rowHeight := 0.0
+		for col := 0; col < perRow; col++ {
+			i := row*perRow + col
+			if i >= len(keys) {
+				break
+			}
+			loc := locals[i]
+			for id, b := range loc.nodes {
+				l.Nodes[id] = Box{X: x + b.X, Y: y + b.Y, W: b.W, H: b.H}
+			}
+			if keys[i] != "" {
+				l.Clusters[keys[i]] = Box{X: x, Y: y, W: loc.w, H: loc.h}
+			}
+			x += loc.w + clusterGap
+			rowHeight = math.Max(rowHeight, loc.h)
+		}
+		l.Width = math.Max(l.Width, x-clusterGap)
+		y += rowHeight + clusterGap
+	}
+	l.Height = math.Max(0, y-clusterGap)
+
+	return l
+}
+
+// layoutGroup arranges nodes in type columns relative to the group origin and
+// returns their boxes along with the total group size including padding.
+func layoutGroup(g *graph.Graph, ids []string) (map[string]Box, float64, float64) {
+	columns := make([][]*graph.Node, 4)
+	for _, id := range ids {
+		node := g.Nodes[id]
+		c := columnOf(node.Type)
+		columns[c] = append(columns[c], node)
+	}
+
+	boxes := make(map[string]Box, len(ids))
+	x := clusterPadding
+	height := 0.0
+	for _, column := range columns {
+		if len(column) == 0 {
+			continue
+		}
+		sort.Slice(column, func(i, j int) bool {
+			if column[i].Label != column[j].Label {
+				return column[i].Label < column[j].Label
+			}
+			return column[i].ID < column[j].ID
+		})
+
+		width := nodeMinWidth
+		for _, node := range column {
+			width = math.Max(width, nodeWidth(node.Label))
+		}
+
+		y := clusterHeader + clusterPadding
+		for _, node := range column {
+			boxes[node.ID] = Box{X: x, Y: y, W: width, H: nodeHeight}
+			y += nodeHeight + nodeGap
+		}
+		height = math.Max(height, y-nodeGap)
+		x += width + columnGap
+	}
+
+	width := x - columnGap + clusterPadding
+	if len(boxes) == 0 {
+		width = 2 * clusterPadding
+	}
+	return boxes, width, height + clusterPadding
+}
+
+func nodeWidth(label string) float64 {
+	return math.Max(nodeMinWidth, float64(len([]rune(label)))*charWidth+2*nodeGap)
+}
@@ -0,0 +1,167 @@
+package renderer
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/graph"
+)
+
+// Legend describes a diagram so it can be shared on its own: a key of the node and edge
+// types it shows, when the projects were scanned, how many are drawn and how the graph was filtered
+type Legend struct {
+	ScannedAt time.Time // latest sync of the drawn projects, zero if unknown
+	Projects  int
+	Filters   []string // e.g. "where: fanout > 3", empty when the graph isn't filtered
+}
+
+// legendEntry is a line of the legend below its title: a node type with a swatch of its
+// color, an edge type with a sample of its line, or text
+type legendEntry struct {
+	label  string
+	fill   string      // node types only
+	stroke *edgeStroke // edge types only
+}
+
+// Geometry of the legend box of the SVG and PNG renderers
+const (
+	legendLineHeight = 16.0
+	legendPadding    = 8.0
+	legendSample     = 24.0 // width of the node swatches and edge samples
+	legendGap        = 20.0 // between the diagram and the legend
+)
+
+// entries returns the lines of the legend of g drawn with theme
+func (l *Legend) entries(g *graph.Graph, theme *Theme) []legendEntry {
+	nodeTypes := make(map[graph.NodeType]bool)
+	for _, node := range g.Nodes {
+		nodeTypes[node.Type] = true
+	}
+	edgeTypes := make(map[graph.EdgeType]bool)
+	inferred := false
+	for _, edge := range g.Edges {
+		edgeTypes[edge.Type] = true
+		inferred = inferred || edge.Inferred
+	}
+
+	var entries []legendEntry
+	for _, nodeType := range sortedKeys(nodeTypes) {
+		entries = append(entries, legendEntry{label: typeLabel(string(nodeType)), fill: theme.Nodes[nodeType]})
+	}
+	for _, edgeType := range sortedKeys(edgeTypes) {
+		stroke := theme.stroke(&graph.Edge{Type: edgeType})
+		entries = append(entries, legendEntry{label: typeLabel(string(edgeType)), stroke: &stroke})
+	}
+	if inferred {
+		stroke := theme.stroke(&graph.Edge{Inferred: true})
+		entries = append(entries, legendEntry{label: "inferred", stroke: &stroke})
+	}
+
+	if !l.ScannedAt.IsZero() {
+		entries = append(entries, legendEntry{label: "Scanned " + l.ScannedAt.UTC().Format("2006-01-02 15:04 UTC")})
+	}
+	if l.Projects == 1 {
+		entries = append(entries, legendEntry{label: "1 project"})
+	} else {
+		entries = append(entries, legendEntry{label: fmt.Sprintf("%d projects", l.Projects)})
+	}
+	for _, filter := range l.Filters {
+		entries = append(entries, legendEntry{label: filter})
+	}
+	return entries
+}
+
+// sortedKeys returns the keys of m, sorted
+func sortedKeys[K ~string](m map[K]bool) []K {
+	keys := make([]K, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
+
+// typeLabel returns a node or edge type as shown in the legend, e.g. "cross project"
+func typeLabel(t string) string {
+	if t == "" {
+		return "other"
+	}
+	return strings.ReplaceAll(t, "_", " ")
+}
+
+// legendBox returns the box of the legend with entries, placed in the top right corner
+// right of a diagram of the given width
+func legendBox(entries []legendEntry, diagramWidth float64) Box {
+	width := float64(len("Legend")) * charWidth
+	for _, entry := range entries {
+		w := float64(len([]rune(entry.label))) * charWidth
+		if entry.fill != "" || entry.stroke != nil {
+			w += legendSample + legendPadding
+		}
+		width = max(width, w)
+	}
+	return Box{
+		X: diagramWidth + legendGap,
+		W: width + 2*legendPadding,
+		H: float64(len(entries)+1)*legendLineHeight + 2*legendPadding,
+	}
+}
+
+// legendLayout returns the lines and box of the legend of g, none without one,
+// and the size of the diagram laid out in l with the legend right of it
+func legendLayout(g *graph.Graph, l *Layout, legend *Legend, theme *Theme) (entries []legendEntry, box Box, width, height float64) {
+	if legend == nil {
+		return nil, Box{}, l.Width, l.Height
+	}
+	entries = legend.entries(g, theme)
+	box = legendBox(entries, l.Width)
+	return entries, box, box.X + box.W, max(l.Height, box.H)
+}
+
+// legendBaseline returns the text baseline of line i of the legend in b, line 0 is its title
+func legendBaseline(b Box, i int) float64 {
+	return b.Y + legendPadding + float64(i+1)*legendLineHeight - 4
+}
+
+// writeDOTLegend writes the legend as the label of the graph, an HTML-like table in its bottom right corner
+func writeDOTLegend(w io.Writer, entries []legendEntry, theme *Theme) {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, `<TABLE BORDER="1" CELLBORDER="0" CELLSPACING="2" BGCOLOR="%s" COLOR="%s">`,
+		html.EscapeString(theme.Background), html.EscapeString(theme.ClusterBorder))
+	sb.WriteString(`<TR><TD COLSPAN="2" ALIGN="LEFT"><B>Legend</B></TD></TR>`)
+	for _, entry := range entries {
+		label := html.EscapeString(entry.label)
+		switch {
+		case entry.fill != "":
+			fmt.Fprintf(&sb, `<TR><TD BGCOLOR="%s" BORDER="1" COLOR="%s"> </TD><TD ALIGN="LEFT">%s</TD></TR>`,
+				html.EscapeString(entry.fill), html.EscapeString(theme.NodeBorder), label)
+		case entry.stroke != nil:
+			fmt.Fprintf(&sb, `<TR><TD><FONT COLOR="%s">%s</FONT></TD><TD ALIGN="LEFT">%s</TD></TR>`,
+				html.EscapeString(entry.stroke.color), lineSample(*entry.stroke), label)
+		default:
+			fmt.Fprintf(&sb, `<TR><TD COLSPAN="2" ALIGN="LEFT">%s</TD></TR>`, label)
+		}
+	}
+	sb.WriteString(`</TABLE>`)
+
+	fmt.Fprintln(w, "  labelloc=b;")
+	fmt.Fprintln(w, "  labeljust=r;")
+	fmt.Fprintf(w, "  label=<%s>;\n", sb.String())
+}
+
+// lineSample draws the line style of stroke in text, for the Graphviz legend
+func lineSample(stroke edgeStroke) string {
+	switch {
+	case stroke.dash == lineDashes[LineDotted]:
+		return "&middot; &middot; &middot;"
+	case stroke.dash[0] > 0:
+		return "- - -"
+	case stroke.width > 1:
+		return "<B>&mdash;&mdash;</B>"
+	}
+	return "&mdash;&mdash;"
+}
@@ -0,0 +1,83 @@
+package renderer
+
+import (
+	"bytes"
+	"encoding/json"
+	"image/png"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/graph"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testLegend() *Legend {
+	return &Legend{
+		ScannedAt: time.Date(2026, 3, 4, 5, 6, 7, 0, time.UTC),
+		Projects:  2,
+		Filters:   []string{"where: fanout > 3"},
+	}
+}
+
+func TestLegend_Entries(t *testing.T) {
+	g := testGraph()
+	g.AddNode(&graph.Node{ID: "identity_user:dev@example.com", Label: "dev@example.com", Type: graph.NodeTypeIdentity})
+	g.AddEdge(&graph.Edge{From: "identity_user:dev@example.com", To: "topic_a_t", Type: graph.EdgeTypePublishes, Inferred: true})
+
+	var labels []string
+	for _, entry := range testLegend().entries(g, DefaultTheme()) {
+		labels = append(labels, entry.label)
+	}
+	assert.Equal(t, []string{
+		"identity", "storage bucket", "subscription", "topic",
+		"cross project", "delivers", "publishes", "inferred",
+		"Scanned 2026-03-04 05:06 UTC", "2 projects", "where: fanout > 3",
+	}, labels)
+}
+
+func TestLegend_EveryFormat(t *testing.T) {
+	g := testGraph()
+	opts := Options{Legend: testLegend()}
+
+	var dot bytes.Buffer
+	require.NoError(t, WriteDOT(&dot, g, opts))
+	assert.Contains(t, dot.String(), "labelloc=b;")
+	assert.Contains(t, dot.String(), `<TD BGCOLOR="orange" BORDER="1" COLOR="#333"> </TD><TD ALIGN="LEFT">topic</TD>`)
+	assert.Contains(t, dot.String(), `<TD COLSPAN="2" ALIGN="LEFT">where: fanout &gt; 3</TD>`)
+
+	var svg bytes.Buffer
+	require.NoError(t, WriteSVG(&svg, g, opts))
+	assert.Contains(t, svg.String(), `<g class="legend">`)
+	assert.Contains(t, svg.String(), ">Scanned 2026-03-04 05:06 UTC</text>")
+	assert.Contains(t, svg.String(), ">where: fanout &gt; 3</text>")
+
+	var html bytes.Buffer
+	require.NoError(t, WriteHTML(&html, g, opts))
+	m := regexp.MustCompile(`var GRAPH_DATA = (.*);`).FindStringSubmatch(html.String())
+	require.Len(t, m, 2)
+	var data htmlData
+	require.NoError(t, json.Unmarshal([]byte(m[1]), &data))
+	assert.Contains(t, data.Legend, htmlLegendEntry{Label: "topic", Fill: "orange"})
+	assert.Contains(t, data.Legend, htmlLegendEntry{Label: "cross project", Stroke: "red", Dash: "6 4", Width: 1})
+	assert.Contains(t, data.Legend, htmlLegendEntry{Label: "2 projects"})
+
+	// The legend is drawn right of the diagram, so the image is wider
+	var without, with bytes.Buffer
+	require.NoError(t, WritePNG(&without, g, Options{}))
+	require.NoError(t, WritePNG(&with, g, opts))
+	plain, err := png.Decode(&without)
+	require.NoError(t, err)
+	legend, err := png.Decode(&with)
+	require.NoError(t, err)
+	assert.Greater(t, legend.Bounds().Dx(), plain.Bounds().Dx())
+}
+
+func TestLegend_Omitted(t *testing.T) {
+	var svg, dot bytes.Buffer
+	require.NoError(t, WriteSVG(&svg, testGraph(), Options{}))
+	require.NoError(t, WriteDOT(&dot, testGraph(), Options{}))
+	assert.NotContains(t, svg.String(), "legend")
+	assert.NotContains(t, dot.String(), "labelloc")
+}
@@ -0,0 +1,155 @@
+package renderer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/graph"
+)
+
+// OpenLineage constants, see https://openlineage.io/docs/spec/naming
+const (
+	openLineageProducer  = "https://github.com/NissesSenap/gcp-visualizer"
+	openLineageSchemaURL = "https://openlineage.io/spec/2-0-2/OpenLineage.json#/definitions/RunEvent"
+	openLineageJobPrefix = "pubsub://"
+)
+
+// LineageEvent is an OpenLineage RunEvent
+type LineageEvent struct {
+	EventType string           `json:"eventType"`
+	EventTime string           `json:"eventTime"`
+	Producer  string           `json:"producer"`
+	SchemaURL string           `json:"schemaURL"`
+	Run       LineageRun       `json:"run"`
+	Job       LineageJob       `json:"job"`
+	Inputs    []LineageDataset `json:"inputs"`
+	Outputs   []LineageDataset `json:"outputs"`
+}
+
+// LineageRun identifies a run of a job
+type LineageRun struct {
+	RunID string `json:"runId"`
+}
+
+// LineageJob identifies a job, here a subscription
+type LineageJob struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+}
+
+// LineageDataset identifies a dataset using the OpenLineage naming conventions
+type LineageDataset struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+}
+
+// OpenLineageRenderer renders graphs as newline-delimited OpenLineage events
+type OpenLineageRenderer struct {
+	now func() time.Time
+}
+
+// NewOpenLineageRenderer creates a new OpenLineage renderer
+func NewOpenLineageRenderer() *OpenLineageRenderer {
+	return &OpenLineageRenderer{now: time.Now}
+}
+
+// Render writes one event per line to output. The format argument is ignored.
+func (r *OpenLineageRenderer) Render(ctx context.Context, g *graph.Graph, output string, format string) error {
+	f, err := os.Create(output)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", output, err)
+	}
+
+	if err := WriteOpenLineage(f, g, r.now()); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// NewLineageEvents converts a graph to OpenLineage events. Every subscription is a
+// job reading its topic and writing to the subscription itself and any BigQuery
+// table or Cloud Storage bucket it exports to, so lineage tools show
+// topic → subscription → sink. Events are sorted by job name.
+func NewLineageEvents(g *graph.Graph, eventTime time.Time) []LineageEvent {
+	var events []LineageEvent
+	for _, node := range g.Nodes {
+		if node.Type != graph.NodeTypeSubscription {
+			continue
+		}
+
+		event := LineageEvent{
+			EventType: "COMPLETE",
+			EventTime: eventTime.UTC().Format(time.RFC3339),
+			Producer:  openLineageProducer,
+			SchemaURL: openLineageSchemaURL,
+			Job: LineageJob{
+				Namespace: openLineageJobPrefix + node.Project,
+				Name:      node.Label,
+			},
+			Inputs:  []LineageDataset{},
+			Outputs: []LineageDataset{lineageDataset(node)},
+		}
+
+		for _, edge := range g.Edges {
+			switch {
+			case edge.From == node.ID && (edge.Type == graph.EdgeTypeSubscribes || edge.Type == graph.EdgeTypeCrossProject):
+				event.Inputs = append(event.Inputs, lineageDataset(g.Nodes[edge.To]))
+			case edge.From == node.ID && edge.Type == graph.EdgeTypeDelivers:
+				event.Outputs = append(event.Outputs, lineageDataset(g.Nodes[edge.To]))
+			}
+		}
+		sort.Slice(event.Outputs[1:], func(i, j int) bool {
+			return event.Outputs[i+1].Namespace+event.Outputs[i+1].Name < event.Outputs[j+1].Namespace+event.Outputs[j+1].Name
+		})
+
+		// Derived from the job and time, so re-exporting the same snapshot gives the same run
+		event.Run.RunID = uuid.NewSHA1(uuid.NameSpaceURL,
+			[]byte(event.Job.Namespace+"/"+event.Job.Name+"@"+event.EventTime)).String()
+		events = append(events, event)
+	}
+
+	sort.Slice(events, func(i, j int) bool {
+		if events[i].Job.Namespace != events[j].Job.Namespace {
+			return events[i].Job.Namespace < events[j].Job.Namespace
+		}
+		return events[i].Job.Name < events[j].Job.Name
+	})
+	return events
+}
+
+// WriteOpenLineage writes the graph as newline-delimited OpenLineage events,
+// the format accepted by the OpenLineage file transport and Marquez/DataHub importers
+func WriteOpenLineage(w io.Writer, g *graph.Graph, eventTime time.Time) error {
+	enc := json.NewEncoder(w)
+	for _, event := range NewLineageEvents(g, eventTime) {
+		if err := enc.Encode(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lineageDataset names a node following the OpenLineage dataset naming spec
+func lineageDataset(node *graph.Node) LineageDataset {
+	switch node.Type {
+	case graph.NodeTypeTopic:
+		return LineageDataset{Namespace: "pubsub", Name: "topic:" + node.Project + ":" + node.Label}
+	case graph.NodeTypeSubscription:
+		return LineageDataset{Namespace: "pubsub", Name: "subscription:" + node.Project + ":" + node.Label}
+	case graph.NodeTypeBigQueryTable:
+		return LineageDataset{Namespace: "bigquery", Name: strings.Replace(node.Label, ":", ".", 1)}
+	case graph.NodeTypeStorageBucket:
+		return LineageDataset{Namespace: node.Label, Name: "/"}
+	default:
+		return LineageDataset{Namespace: string(node.Type), Name: node.Label}
+	}
+}
@@ -0,0 +1,49 @@
+package renderer
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/graph"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteOpenLineage(t *testing.T) {
+	g := testGraph()
+	g.AddNode(&graph.Node{ID: "bq_b.ds.events", Label: "b:ds.events", Type: graph.NodeTypeBigQueryTable, Project: "b"})
+	g.AddEdge(&graph.Edge{From: "sub_b_s", To: "bq_b.ds.events", Type: graph.EdgeTypeDelivers})
+	eventTime := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteOpenLineage(&buf, g, eventTime))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 1, "one event per subscription")
+
+	var event LineageEvent
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &event))
+	assert.Equal(t, "COMPLETE", event.EventType)
+	assert.Equal(t, "2024-05-01T10:00:00Z", event.EventTime)
+	assert.Equal(t, LineageJob{Namespace: "pubsub://b", Name: "s"}, event.Job)
+	assert.Equal(t, []LineageDataset{{Namespace: "pubsub", Name: "topic:a:t"}}, event.Inputs)
+	assert.Equal(t, []LineageDataset{
+		{Namespace: "pubsub", Name: "subscription:b:s"},
+		{Namespace: "bigquery", Name: "b.ds.events"},
+		{Namespace: "gs://bucket", Name: "/"},
+	}, event.Outputs)
+
+	// The run ID is stable for the same snapshot
+	again := NewLineageEvents(g, eventTime)
+	assert.Equal(t, event.Run.RunID, again[0].Run.RunID)
+	assert.Len(t, event.Run.RunID, 36)
+}
+
+func TestWriteOpenLineage_EmptyGraph(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, WriteOpenLineage(&buf, graph.New(), time.Now()))
+	assert.Empty(t, buf.String())
+}
@@ -0,0 +1,335 @@
+package renderer
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/graph"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/image/vector"
+)
+
+// PNGRenderer renders graphs to PNG using the pure-Go layout,
+// so it works without the Graphviz binary installed.
+type PNGRenderer struct {
+	opts Options
+}
+
+// NewPNGRenderer creates a new pure-Go PNG renderer drawing with opts
+func NewPNGRenderer(opts Options) *PNGRenderer {
+	return &PNGRenderer{opts: opts}
+}
+
+// Render writes the graph to output as PNG. The format argument is ignored.
+func (r *PNGRenderer) Render(ctx context.Context, g *graph.Graph, output string, format string) error {
+	f, err := os.Create(output)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", output, err)
+	}
+
+	if err := WritePNG(f, g, r.opts); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// WritePNG draws the graph as WriteSVG does and writes it to w as PNG. Labels use a
+// fixed-width bitmap font, whose glyphs are as wide as the layout's charWidth.
+func WritePNG(w io.Writer, g *graph.Graph, opts Options) error {
+	theme := opts.Theme.orDefault()
+	text := parseColor(theme.Text)
+	l := ComputeLayout(g)
+	entries, legend, width, height := legendLayout(g, l, opts.Legend, theme)
+
+	const margin = 20.0
+	c := &canvas{
+		img: image.NewRGBA(image.Rect(0, 0, int(math.Ceil(width+2*margin)), int(math.Ceil(height+2*margin)))),
+		dx:  margin,
+		dy:  margin,
+	}
+	c.fillRect(Box{X: -margin, Y: -margin, W: width + 2*margin, H: height + 2*margin}, parseColor(theme.Background))
+
+	// Project clusters
+	projects := make([]string, 0, len(l.Clusters))
+	for projectID := range l.Clusters {
+		projects = append(projects, projectID)
+	}
+	sort.Strings(projects)
+	for _, projectID := range projects {
+		b := l.Clusters[projectID]
+		c.fillRect(b, parseColor(theme.Cluster))
+		c.strokeRect(b, parseColor(theme.ClusterBorder), 1)
+		c.text(g.Clusters[projectID].Label, b.X+10, b.Y+18, text)
+	}
+
+	// Edges, drawn before nodes so nodes cover the line ends
+	for _, edge := range g.Edges {
+		from, okFrom := l.Nodes[edge.From]
+		to, okTo := l.Nodes[edge.To]
+		if !okFrom || !okTo {
+			continue
+		}
+		stroke := theme.stroke(edge)
+
+		// End the line at the target's border so the arrowhead stays visible
+		x1, y1 := from.X+from.W/2, from.Y+from.H/2
+		x2, y2 := borderPoint(to, x1, y1)
+		col := parseColor(stroke.color)
+		c.line(x1, y1, x2, y2, col, stroke.width, stroke.dash[0])
+		c.arrowhead(x1, y1, x2, y2, col)
+		if edge.Label != "" {
+			mx, my := (x1+to.X+to.W/2)/2, (y1+to.Y+to.H/2)/2
+			c.text(edge.Label, mx-float64(len(edge.Label))*charWidth/2, my-3, text)
+		}
+	}
+
+	// Nodes
+	ids := make([]string, 0, len(g.Nodes))
+	for id := range g.Nodes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		node := g.Nodes[id]
+		b := l.Nodes[id]
+		outline := shapePoints(b, node.Shape)
+		c.fill(parseColor(theme.fillColor(node)), outline...)
+		if node.Border != "" {
+			c.strokePolygon(outline, parseColor(node.Border), 3)
+		} else {
+			c.strokePolygon(outline, parseColor(theme.NodeBorder), 1)
+		}
+		c.text(node.Label, b.X+b.W/2-float64(len(node.Label))*charWidth/2, b.Y+b.H/2+4, text)
+		if node.Count > 0 {
+			badge := countBadge(b, node.Count)
+			count := strconv.Itoa(node.Count)
+			c.fill(text, shapePoints(badge, graph.ShapeEllipse)...)
+			c.text(count, badge.X+badge.W/2-float64(len(count))*charWidth/2, badge.Y+badge.H/2+4, parseColor(theme.Background))
+		}
+	}
+
+	// Legend, right of the diagram
+	if entries != nil {
+		c.strokeRect(legend, parseColor(theme.ClusterBorder), 1)
+		x := legend.X + legendPadding
+		c.text("Legend", x, legendBaseline(legend, 0), text)
+		for i, entry := range entries {
+			y := legendBaseline(legend, i+1)
+			textX := x
+			switch {
+			case entry.fill != "":
+				swatch := Box{X: x, Y: y - 9, W: legendSample, H: 10}
+				c.fillRect(swatch, parseColor(entry.fill))
+				c.strokeRect(swatch, parseColor(theme.NodeBorder), 1)
+				textX += legendSample + legendPadding
+			case entry.stroke != nil:
+				c.line(x, y-4, x+legendSample, y-4, parseColor(entry.stroke.color), entry.stroke.width, entry.stroke.dash[0])
+				textX += legendSample + legendPadding
+			}
+			c.text(entry.label, textX, y, text)
+		}
+	}
+
+	return png.Encode(w, c.img)
+}
+
+// canvas draws in layout coordinates, offset by the margin
+type canvas struct {
+	img    *image.RGBA
+	dx, dy float64
+}
+
+// fill rasterizes the polygon through points, in layout coordinates
+func (c *canvas) fill(col color.Color, points ...[2]float64) {
+	size := c.img.Bounds().Size()
+	z := vector.NewRasterizer(size.X, size.Y)
+	for i, p := range points {
+		x, y := float32(p[0]+c.dx), float32(p[1]+c.dy)
+		if i == 0 {
+			z.MoveTo(x, y)
+		} else {
+			z.LineTo(x, y)
+		}
+	}
+	z.ClosePath()
+	z.Draw(c.img, c.img.Bounds(), image.NewUniform(col), image.Point{})
+}
+
+func (c *canvas) fillRect(b Box, col color.Color) {
+	c.fill(col, [2]float64{b.X, b.Y}, [2]float64{b.X + b.W, b.Y}, [2]float64{b.X + b.W, b.Y + b.H}, [2]float64{b.X, b.Y + b.H})
+}
+
+// strokeRect draws the border of b, width wide and centered on its edges
+func (c *canvas) strokeRect(b Box, col color.Color, width float64) {
+	c.line(b.X, b.Y, b.X+b.W, b.Y, col, width, 0)
+	c.line(b.X+b.W, b.Y, b.X+b.W, b.Y+b.H, col, width, 0)
+	c.line(b.X+b.W, b.Y+b.H, b.X, b.Y+b.H, col, width, 0)
+	c.line(b.X, b.Y+b.H, b.X, b.Y, col, width, 0)
+}
+
+// strokePolygon draws the closed outline through points, width wide
+func (c *canvas) strokePolygon(points [][2]float64, col color.Color, width float64) {
+	for i, p := range points {
+		q := points[(i+1)%len(points)]
+		c.line(p[0], p[1], q[0], q[1], col, width, 0)
+	}
+}
+
+// line draws a line width wide, in dashes of dash length with equal gaps if dash is positive
+func (c *canvas) line(x1, y1, x2, y2 float64, col color.Color, width, dash float64) {
+	length := math.Hypot(x2-x1, y2-y1)
+	if length == 0 {
+		return
+	}
+	ux, uy := (x2-x1)/length, (y2-y1)/length
+	if dash <= 0 {
+		dash = length
+	}
+	for start := 0.0; start < length; start += 2 * dash {
+		end := math.Min(start+dash, length)
+		c.segment(x1+ux*start, y1+uy*start, x1+ux*end, y1+uy*end, col, width)
+	}
+}
+
+// segment draws a solid line as a rectangle around it, extended by half its width at both ends
+func (c *canvas) segment(x1, y1, x2, y2 float64, col color.Color, width float64) {
+	length := math.Hypot(x2-x1, y2-y1)
+	if length == 0 {
+		return
+	}
+	half := width / 2
+	ux, uy := (x2-x1)/length*half, (y2-y1)/length*half
+	nx, ny := -uy, ux
+	c.fill(col,
+		[2]float64{x1 - ux + nx, y1 - uy + ny},
+		[2]float64{x2 + ux + nx, y2 + uy + ny},
+		[2]float64{x2 + ux - nx, y2 + uy - ny},
+		[2]float64{x1 - ux - nx, y1 - uy - ny},
+	)
+}
+
+// arrowhead draws the head of an arrow from x1,y1 pointing at x2,y2
+func (c *canvas) arrowhead(x1, y1, x2, y2 float64, col color.Color) {
+	const size = 8.0
+	length := math.Hypot(x2-x1, y2-y1)
+	if length == 0 {
+		return
+	}
+	ux, uy := (x2-x1)/length, (y2-y1)/length
+	bx, by := x2-ux*size, y2-uy*size
+	c.fill(col,
+		[2]float64{x2, y2},
+		[2]float64{bx - uy*size/2, by + ux*size/2},
+		[2]float64{bx + uy*size/2, by - ux*size/2},
+	)
+}
+
+// text draws s with its baseline starting at x,y
+func (c *canvas) text(s string, x, y float64, col color.Color) {
+	d := &font.Drawer{
+		Dst:  c.img,
+		Src:  image.NewUniform(col),
+		Face: basicfont.Face7x13,
+		Dot:  fixed.P(int(math.Round(x+c.dx)), int(math.Round(y+c.dy))),
+	}
+	d.DrawString(s)
+}
+
+// shapePoints returns the outline of a node of the given shape in b, its box unless a style rule set another shape
+func shapePoints(b Box, shape string) [][2]float64 {
+	cx, cy := b.X+b.W/2, b.Y+b.H/2
+	switch shape {
+	case graph.ShapeEllipse:
+		const segments = 32
+		points := make([][2]float64, segments)
+		for i := range points {
+			angle := 2 * math.Pi * float64(i) / segments
+			points[i] = [2]float64{cx + b.W/2*math.Cos(angle), cy + b.H/2*math.Sin(angle)}
+		}
+		return points
+	case graph.ShapeDiamond:
+		return [][2]float64{{cx, b.Y}, {b.X + b.W, cy}, {cx, b.Y + b.H}, {b.X, cy}}
+	}
+	return [][2]float64{{b.X, b.Y}, {b.X + b.W, b.Y}, {b.X + b.W, b.Y + b.H}, {b.X, b.Y + b.H}}
+}
+
+// borderPoint returns where the line from x,y to the center of b crosses the border of b
+func borderPoint(b Box, x, y float64) (float64, float64) {
+	cx, cy := b.X+b.W/2, b.Y+b.H/2
+	dx, dy := x-cx, y-cy
+	if dx == 0 && dy == 0 {
+		return cx, cy
+	}
+	scale := math.Inf(1)
+	if dx != 0 {
+		scale = b.W / 2 / math.Abs(dx)
+	}
+	if dy != 0 {
+		scale = math.Min(scale, b.H/2/math.Abs(dy))
+	}
+	if scale >= 1 {
+		// x,y is inside b
+		return cx, cy
+	}
+	return cx + dx*scale, cy + dy*scale
+}
+
+// namedColors are the colors used by the renderers and the default palettes, as in SVG and Graphviz
+var namedColors = map[string]color.RGBA{
+	"aquamarine":   {0x7f, 0xff, 0xd4, 0xff},
+	"black":        {0x00, 0x00, 0x00, 0xff},
+	"blue":         {0x00, 0x00, 0xff, 0xff},
+	"darkorange":   {0xff, 0x8c, 0x00, 0xff},
+	"deepskyblue":  {0x00, 0xbf, 0xff, 0xff},
+	"gold":         {0xff, 0xd7, 0x00, 0xff},
+	"green":        {0x00, 0x80, 0x00, 0xff},
+	"grey":         {0xbe, 0xbe, 0xbe, 0xff},
+	"gray":         {0xbe, 0xbe, 0xbe, 0xff},
+	"khaki":        {0xf0, 0xe6, 0x8c, 0xff},
+	"lightblue":    {0xad, 0xd8, 0xe6, 0xff},
+	"lightgreen":   {0x90, 0xee, 0x90, 0xff},
+	"lightgrey":    {0xd3, 0xd3, 0xd3, 0xff},
+	"lightgray":    {0xd3, 0xd3, 0xd3, 0xff},
+	"lightskyblue": {0x87, 0xce, 0xfa, 0xff},
+	"navy":         {0x00, 0x00, 0x80, 0xff},
+	"orange":       {0xff, 0xa5, 0x00, 0xff},
+	"palegreen":    {0x98, 0xfb, 0x98, 0xff},
+	"pink":         {0xff, 0xc0, 0xcb, 0xff},
+	"plum":         {0xdd, 0xa0, 0xdd, 0xff},
+	"purple":       {0x80, 0x00, 0x80, 0xff},
+	"red":          {0xff, 0x00, 0x00, 0xff},
+	"royalblue":    {0x41, 0x69, 0xe1, 0xff},
+	"teal":         {0x00, 0x80, 0x80, 0xff},
+	"white":        {0xff, 0xff, 0xff, 0xff},
+	"yellow":       {0xff, 0xff, 0x00, 0xff},
+}
+
+// parseColor parses a color name of namedColors or a "#rgb" or "#rrggbb" hex color.
+// Colors it doesn't know, such as other Graphviz names from the config, are drawn grey.
+func parseColor(s string) color.RGBA {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if c, ok := namedColors[s]; ok {
+		return c
+	}
+	if hex, ok := strings.CutPrefix(s, "#"); ok {
+		if len(hex) == 3 {
+			hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+		}
+		if v, err := strconv.ParseUint(hex, 16, 32); err == nil && len(hex) == 6 {
+			return color.RGBA{uint8(v >> 16), uint8(v >> 8), uint8(v), 0xff}
+		}
+	}
+	return namedColors["grey"]
+}
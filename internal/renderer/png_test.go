@@ -0,0 +1,56 @@
+package renderer
+
+import (
+	"bytes"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/graph"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWritePNG(t *testing.T) {
+	g := testGraph()
+	l := ComputeLayout(g)
+
+	var buf bytes.Buffer
+	require.NoError(t, WritePNG(&buf, g, Options{}))
+	img, err := png.Decode(&buf)
+	require.NoError(t, err)
+
+	const margin = 20
+	assert.Equal(t, int(l.Width)+2*margin, img.Bounds().Dx())
+	assert.Equal(t, int(l.Height)+2*margin, img.Bounds().Dy())
+
+	// The top-left corner of a node is inside its border, a pixel further in is its fill
+	topics := 0
+	for id, node := range g.Nodes {
+		if node.Type != graph.NodeTypeTopic {
+			continue
+		}
+		topics++
+		b := l.Nodes[id]
+		x, y := int(b.X)+margin+3, int(b.Y)+margin+3
+		assert.Equal(t, color.RGBAModel.Convert(parseColor(DefaultTheme().fillColor(node))), color.RGBAModel.Convert(img.At(x, y)), id)
+	}
+	assert.NotZero(t, topics)
+}
+
+func TestParseColor(t *testing.T) {
+	tests := []struct {
+		in   string
+		want color.RGBA
+	}{
+		{"lightblue", color.RGBA{0xad, 0xd8, 0xe6, 0xff}},
+		{"Red", color.RGBA{0xff, 0x00, 0x00, 0xff}},
+		{"#555", color.RGBA{0x55, 0x55, 0x55, 0xff}},
+		{"#4169e1", color.RGBA{0x41, 0x69, 0xe1, 0xff}},
+		{"#12345", namedColors["grey"]},
+		{"mediumseagreen", namedColors["grey"]},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, parseColor(tt.in), tt.in)
+	}
+}
@@ -1,4 +1,18 @@
 package renderer
 
-// Renderer package placeholder
-// TODO: Implement Graphviz renderer
+import (
+	"context"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/graph"
+)
+
+// Renderer renders a graph to an output file
+type Renderer interface {
+	Render(ctx context.Context, g *graph.Graph, output string, format string) error
+}
+
+// Options configure how the diagram renderers draw a graph
+type Options struct {
+	Theme  *Theme  // nil is the default theme
+	Legend *Legend // nil draws no legend
+}
@@ -0,0 +1,170 @@
+package renderer
+
+import (
+	"bufio"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/graph"
+)
+
+// SVGRenderer renders graphs to SVG using the pure-Go layout,
+// so it works without the Graphviz binary installed.
+type SVGRenderer struct {
+	opts Options
+}
+
+// NewSVGRenderer creates a new pure-Go SVG renderer drawing with opts
+func NewSVGRenderer(opts Options) *SVGRenderer {
+	return &SVGRenderer{opts: opts}
+}
+
+// Render writes the graph to output as SVG. The format argument is ignored.
+func (r *SVGRenderer) Render(ctx context.Context, g *graph.Graph, output string, format string) error {
+	f, err := os.Create(output)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", output, err)
+	}
+
+	if err := WriteSVG(f, g, r.opts); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// WriteSVG writes the graph as SVG using ComputeLayout, drawn with opts
+func WriteSVG(w io.Writer, g *graph.Graph, opts Options) error {
+	theme := opts.Theme.orDefault()
+	l := ComputeLayout(g)
+	entries, legend, width, height := legendLayout(g, l, opts.Legend, theme)
+	bw := bufio.NewWriter(w)
+
+	const margin = 20.0
+	fmt.Fprintf(bw, `<svg xmlns="http://www.w3.org/2000/svg" width="%.0f" height="%.0f" viewBox="%.0f %.0f %.0f %.0f" font-family="sans-serif" font-size="12" fill="%s">`+"\n",
+		width+2*margin, height+2*margin, -margin, -margin, width+2*margin, height+2*margin, escapeXML(theme.Text))
+	fmt.Fprintf(bw, `<defs><marker id="arrow" viewBox="0 0 10 10" refX="10" refY="5" markerWidth="8" markerHeight="8" orient="auto-start-reverse"><path d="M 0 0 L 10 5 L 0 10 z" fill="%s"/></marker></defs>`+"\n", escapeXML(theme.Edge.Color))
+	fmt.Fprintf(bw, `<rect x="%.0f" y="%.0f" width="%.0f" height="%.0f" fill="%s"/>`+"\n",
+		-margin, -margin, width+2*margin, height+2*margin, escapeXML(theme.Background))
+
+	// Project clusters
+	projects := make([]string, 0, len(l.Clusters))
+	for projectID := range l.Clusters {
+		projects = append(projects, projectID)
+	}
+	sort.Strings(projects)
+	for _, projectID := range projects {
+		b := l.Clusters[projectID]
+		fmt.Fprintf(bw, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" rx="6" fill="%s" stroke="%s"/>`+"\n",
+			b.X, b.Y, b.W, b.H, escapeXML(theme.Cluster), escapeXML(theme.ClusterBorder))
+		fmt.Fprintf(bw, `<text x="%.1f" y="%.1f" font-weight="bold">%s</text>`+"\n", b.X+10, b.Y+18, escapeXML(g.Clusters[projectID].Label))
+	}
+
+	// Edges, drawn before nodes so nodes cover the line ends
+	for _, edge := range g.Edges {
+		from, okFrom := l.Nodes[edge.From]
+		to, okTo := l.Nodes[edge.To]
+		if !okFrom || !okTo {
+			continue
+		}
+		stroke := theme.stroke(edge)
+		extra := ""
+		if stroke.width != 1 {
+			extra += fmt.Sprintf(` stroke-width="%g"`, stroke.width)
+		}
+		if dash := dashArray(stroke); dash != "" {
+			extra += fmt.Sprintf(` stroke-dasharray="%s"`, dash)
+		}
+		fmt.Fprintf(bw, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="%s"%s marker-end="url(#arrow)"/>`+"\n",
+			from.X+from.W/2, from.Y+from.H/2, to.X+to.W/2, to.Y+to.H/2, escapeXML(stroke.color), extra)
+		if edge.Label != "" {
+			fmt.Fprintf(bw, `<text x="%.1f" y="%.1f" text-anchor="middle" font-size="10">%s</text>`+"\n",
+				(from.X+from.W/2+to.X+to.W/2)/2, (from.Y+from.H/2+to.Y+to.H/2)/2-3, escapeXML(edge.Label))
+		}
+	}
+
+	// Nodes
+	ids := make([]string, 0, len(g.Nodes))
+	for id := range g.Nodes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		node := g.Nodes[id]
+		b := l.Nodes[id]
+		border := fmt.Sprintf(`stroke="%s"`, escapeXML(theme.NodeBorder))
+		if node.Border != "" {
+			border = fmt.Sprintf(`stroke="%s" stroke-width="3"`, escapeXML(node.Border))
+		}
+		fmt.Fprintf(bw, `<g><title>%s</title>%s fill="%s" %s/>`,
+			escapeXML(node.ID), svgShape(b, node.Shape), escapeXML(theme.fillColor(node)), border)
+		fmt.Fprintf(bw, `<text x="%.1f" y="%.1f" text-anchor="middle">%s</text>`,
+			b.X+b.W/2, b.Y+b.H/2+4, escapeXML(node.Label))
+		if node.Count > 0 {
+			badge := countBadge(b, node.Count)
+			fmt.Fprintf(bw, `<g class="count"><ellipse cx="%.1f" cy="%.1f" rx="%.1f" ry="%.1f" fill="%s"/><text x="%.1f" y="%.1f" text-anchor="middle" font-size="10" fill="%s">%d</text></g>`,
+				badge.X+badge.W/2, badge.Y+badge.H/2, badge.W/2, badge.H/2, escapeXML(theme.Text),
+				badge.X+badge.W/2, badge.Y+badge.H/2+3.5, escapeXML(theme.Background), node.Count)
+		}
+		fmt.Fprintln(bw, "</g>")
+	}
+
+	if entries != nil {
+		writeSVGLegend(bw, entries, legend, theme)
+	}
+
+	fmt.Fprintln(bw, "</svg>")
+	return bw.Flush()
+}
+
+// svgShape returns the opening of the SVG element drawing a node of the given shape in b,
+// a rounded rectangle unless a style rule set another shape
+func svgShape(b Box, shape string) string {
+	switch shape {
+	case graph.ShapeEllipse:
+		return fmt.Sprintf(`<ellipse cx="%.1f" cy="%.1f" rx="%.1f" ry="%.1f"`, b.X+b.W/2, b.Y+b.H/2, b.W/2, b.H/2)
+	case graph.ShapeDiamond:
+		return fmt.Sprintf(`<polygon points="%.1f,%.1f %.1f,%.1f %.1f,%.1f %.1f,%.1f"`,
+			b.X+b.W/2, b.Y, b.X+b.W, b.Y+b.H/2, b.X+b.W/2, b.Y+b.H, b.X, b.Y+b.H/2)
+	}
+	return fmt.Sprintf(`<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" rx="4"`, b.X, b.Y, b.W, b.H)
+}
+
+// writeSVGLegend draws the legend with entries in b
+func writeSVGLegend(w io.Writer, entries []legendEntry, b Box, theme *Theme) {
+	fmt.Fprintf(w, `<g class="legend"><rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" rx="4" fill="%s" stroke="%s"/>`+"\n",
+		b.X, b.Y, b.W, b.H, escapeXML(theme.Background), escapeXML(theme.ClusterBorder))
+	x := b.X + legendPadding
+	fmt.Fprintf(w, `<text x="%.1f" y="%.1f" font-weight="bold">Legend</text>`+"\n", x, legendBaseline(b, 0))
+	for i, entry := range entries {
+		y := legendBaseline(b, i+1)
+		textX := x
+		switch {
+		case entry.fill != "":
+			fmt.Fprintf(w, `<rect x="%.1f" y="%.1f" width="%.1f" height="10" fill="%s" stroke="%s"/>`,
+				x, y-9, legendSample, escapeXML(entry.fill), escapeXML(theme.NodeBorder))
+			textX += legendSample + legendPadding
+		case entry.stroke != nil:
+			dash := ""
+			if d := dashArray(*entry.stroke); d != "" {
+				dash = fmt.Sprintf(` stroke-dasharray="%s"`, d)
+			}
+			fmt.Fprintf(w, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="%s" stroke-width="%g"%s/>`,
+				x, y-4, x+legendSample, y-4, escapeXML(entry.stroke.color), entry.stroke.width, dash)
+			textX += legendSample + legendPadding
+		}
+		fmt.Fprintf(w, `<text x="%.1f" y="%.1f">%s</text>`+"\n", textX, y, escapeXML(entry.label))
+	}
+	fmt.Fprintln(w, "</g>")
+}
+
+func escapeXML(s string) string {
+	var sb strings.Builder
+	_ = xml.EscapeText(&sb, []byte(s))
+	return sb.String()
+}
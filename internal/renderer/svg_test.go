@@ -0,0 +1,85 @@
+package renderer
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/graph"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteSVG(t *testing.T) {
+	g := testGraph()
+	g.AddNode(&graph.Node{ID: "topic_a_<x>", Label: "a&b", Type: graph.NodeTypeTopic, Project: "a"})
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteSVG(&buf, g, Options{}))
+
+	// Output must be well-formed XML
+	dec := xml.NewDecoder(bytes.NewReader(buf.Bytes()))
+	for {
+		if _, err := dec.Token(); err != nil {
+			require.Equal(t, "EOF", err.Error())
+			break
+		}
+	}
+	assert.Contains(t, buf.String(), "a&amp;b")
+	assert.Contains(t, buf.String(), `stroke="red"`)
+}
+
+func TestFallbackRenderer_NoGraphviz(t *testing.T) {
+	// Empty PATH guarantees the dot binary can't be found
+	t.Setenv("PATH", "")
+
+	dir := t.TempDir()
+	var warn bytes.Buffer
+	r := NewFallbackRenderer(NewGraphvizRenderer("fdp", Options{}), &warn)
+
+	t.Run("svg", func(t *testing.T) {
+		output := filepath.Join(dir, "graph.svg")
+		require.NoError(t, r.Render(context.Background(), testGraph(), output, "svg"))
+		data, err := os.ReadFile(output)
+		require.NoError(t, err)
+		assert.Contains(t, string(data), "<svg")
+		assert.Contains(t, warn.String(), "Warning")
+	})
+
+	t.Run("png", func(t *testing.T) {
+		output := filepath.Join(dir, "graph.png")
+		require.NoError(t, r.Render(context.Background(), testGraph(), output, "png"))
+		f, err := os.Open(output)
+		require.NoError(t, err)
+		defer func() { _ = f.Close() }()
+		_, err = png.Decode(f)
+		assert.NoError(t, err)
+	})
+
+	t.Run("pdf needs graphviz", func(t *testing.T) {
+		output := filepath.Join(dir, "graph.pdf")
+		err := r.Render(context.Background(), testGraph(), output, "pdf")
+		assert.ErrorIs(t, err, ErrGraphvizNotFound)
+		assert.ErrorContains(t, err, "install Graphviz")
+		_, err = os.Stat(output)
+		assert.True(t, os.IsNotExist(err))
+	})
+}
+
+func TestWriteSVG_CountBadge(t *testing.T) {
+	g := graph.New()
+	g.AddNode(&graph.Node{ID: "c", Label: "events-*", Type: graph.NodeTypeTopic, Project: "p", Count: 120})
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteSVG(&buf, g, Options{}))
+	assert.Contains(t, buf.String(), `<g class="count">`)
+	assert.Contains(t, buf.String(), `>120</text>`)
+
+	buf.Reset()
+	require.NoError(t, WriteDOT(&buf, g, Options{}))
+	assert.Contains(t, buf.String(), `xlabel="120"`)
+}
@@ -0,0 +1,328 @@
+package renderer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/graph"
+	"gopkg.in/yaml.v3"
+)
+
+// Theme is the palette diagrams are drawn with, the same in every output format.
+// Overlay colors, such as those of --color-by and --highlight-orphans, still take precedence.
+type Theme struct {
+	Base          string                       `yaml:"base"` // built-in theme the unset fields of a custom theme are taken from, light by default
+	Background    string                       `yaml:"background"`
+	Text          string                       `yaml:"text"`
+	Cluster       string                       `yaml:"cluster"` // fill of the project clusters
+	ClusterBorder string                       `yaml:"cluster_border"`
+	NodeBorder    string                       `yaml:"node_border"`
+	Edge          EdgeStyle                    `yaml:"edge"`  // edges whose relation has no style of its own
+	Nodes         map[graph.NodeType]string    `yaml:"nodes"` // fill color by node type
+	Edges         map[graph.EdgeType]EdgeStyle `yaml:"edges"` // by relation
+}
+
+// EdgeStyle is the color and line style of an edge. Inferred edges are always dotted.
+type EdgeStyle struct {
+	Color string `yaml:"color"`
+	Style string `yaml:"style"` // solid, bold, dashed or dotted, empty is solid
+}
+
+// Line styles of EdgeStyle
+const (
+	LineSolid  = "solid"
+	LineBold   = "bold"
+	LineDashed = "dashed"
+	LineDotted = "dotted"
+)
+
+// Names of the built-in themes
+const (
+	ThemeLight      = "light"
+	ThemeDark       = "dark"
+	ThemeColorblind = "colorblind"
+)
+
+// ErrUnknownTheme is returned by LoadTheme for a name that is neither a built-in theme nor a file
+var ErrUnknownTheme = errors.New("unknown theme")
+
+// themes are the built-in themes. Light is the default, colorblind uses the Okabe-Ito
+// palette, whose colors stay distinguishable with the common forms of color blindness.
+var themes = map[string]*Theme{
+	ThemeLight: {
+		Background:    "white",
+		Text:          "black",
+		Cluster:       "lightgrey",
+		ClusterBorder: "#999",
+		NodeBorder:    "#333",
+		Edge:          EdgeStyle{Color: "#555"},
+		Nodes: map[graph.NodeType]string{
+			graph.NodeTypeTopic:            "orange",
+			graph.NodeTypeSubscription:     "lightgreen",
+			graph.NodeTypeBigQueryTable:    "lightblue",
+			graph.NodeTypeStorageBucket:    "khaki",
+			graph.NodeTypeIdentity:         "plum",
+			graph.NodeTypeCloudRunService:  "lightskyblue",
+			graph.NodeTypeCloudFunction:    "gold",
+			graph.NodeTypeDataflowJob:      "aquamarine",
+			graph.NodeTypeLiteTopic:        "sandybrown",
+			graph.NodeTypeLiteSubscription: "palegreen",
+			graph.NodeTypeProject:          "white",
+		},
+		Edges: map[graph.EdgeType]EdgeStyle{
+			graph.EdgeTypeCrossProject: {Color: "red", Style: LineDashed},
+			graph.EdgeTypeDelivers:     {Color: "blue", Style: LineBold},
+			graph.EdgeTypeConsumes:     {Color: "purple"},
+			graph.EdgeTypeTriggers:     {Color: "darkorange", Style: LineBold},
+			graph.EdgeTypeReads:        {Color: "teal"},
+			graph.EdgeTypePublishes:    {Color: "teal", Style: LineBold},
+		},
+	},
+	ThemeDark: {
+		Background:    "#1e1e1e",
+		Text:          "#e0e0e0",
+		Cluster:       "#2d2d2d",
+		ClusterBorder: "#555",
+		NodeBorder:    "#bbb",
+		Edge:          EdgeStyle{Color: "#999"},
+		Nodes: map[graph.NodeType]string{
+			graph.NodeTypeTopic:            "#a65e00",
+			graph.NodeTypeSubscription:     "#2e6b30",
+			graph.NodeTypeBigQueryTable:    "#1f5a8a",
+			graph.NodeTypeStorageBucket:    "#7a6a1a",
+			graph.NodeTypeIdentity:         "#6a3d75",
+			graph.NodeTypeCloudRunService:  "#23607d",
+			graph.NodeTypeCloudFunction:    "#8a6d00",
+			graph.NodeTypeDataflowJob:      "#1e6e5c",
+			graph.NodeTypeLiteTopic:        "#8a4b1f",
+			graph.NodeTypeLiteSubscription: "#3f7a2a",
+			graph.NodeTypeProject:          "#3a3a3a",
+		},
+		Edges: map[graph.EdgeType]EdgeStyle{
+			graph.EdgeTypeCrossProject: {Color: "#ff6b6b", Style: LineDashed},
+			graph.EdgeTypeDelivers:     {Color: "#64b5f6", Style: LineBold},
+			graph.EdgeTypeConsumes:     {Color: "#ce93d8"},
+			graph.EdgeTypeTriggers:     {Color: "#ffb74d", Style: LineBold},
+			graph.EdgeTypeReads:        {Color: "#4db6ac"},
+			graph.EdgeTypePublishes:    {Color: "#4db6ac", Style: LineBold},
+		},
+	},
+	ThemeColorblind: {
+		Background:    "white",
+		Text:          "black",
+		Cluster:       "#eeeeee",
+		ClusterBorder: "#999",
+		NodeBorder:    "#333",
+		Edge:          EdgeStyle{Color: "#555"},
+		Nodes: map[graph.NodeType]string{
+			graph.NodeTypeTopic:            "#e69f00",
+			graph.NodeTypeSubscription:     "#56b4e9",
+			graph.NodeTypeBigQueryTable:    "#009e73",
+			graph.NodeTypeStorageBucket:    "#f0e442",
+			graph.NodeTypeIdentity:         "#cc79a7",
+			graph.NodeTypeCloudRunService:  "#a6d4f2",
+			graph.NodeTypeCloudFunction:    "#f5c766",
+			graph.NodeTypeDataflowJob:      "#66c5ab",
+			graph.NodeTypeLiteTopic:        "#f2c27a",
+			graph.NodeTypeLiteSubscription: "#9fd0f0",
+			graph.NodeTypeProject:          "white",
+		},
+		Edges: map[graph.EdgeType]EdgeStyle{
+			graph.EdgeTypeCrossProject: {Color: "#d55e00", Style: LineDashed},
+			graph.EdgeTypeDelivers:     {Color: "#0072b2", Style: LineBold},
+			graph.EdgeTypeConsumes:     {Color: "#cc79a7"},
+			graph.EdgeTypeTriggers:     {Color: "#e69f00", Style: LineBold},
+			graph.EdgeTypeReads:        {Color: "#009e73"},
+			graph.EdgeTypePublishes:    {Color: "#009e73", Style: LineBold},
+		},
+	},
+}
+
+// themeEdgeTypes are the relations a theme can style
+var themeEdgeTypes = []graph.EdgeType{
+	graph.EdgeTypeSubscribes,
+	graph.EdgeTypeCrossProject,
+	graph.EdgeTypeDelivers,
+	graph.EdgeTypeConsumes,
+	graph.EdgeTypeTriggers,
+	graph.EdgeTypeReads,
+	graph.EdgeTypePublishes,
+}
+
+// DefaultTheme returns the light theme, used when no theme is configured
+func DefaultTheme() *Theme {
+	return themes[ThemeLight]
+}
+
+// ThemeNames returns the names of the built-in themes, sorted
+func ThemeNames() []string {
+	return slices.Sorted(maps.Keys(themes))
+}
+
+// LoadTheme returns the built-in theme called name, or reads a custom theme from the YAML file
+// at path name. An empty name is the default theme.
+func LoadTheme(name string) (*Theme, error) {
+	if name == "" {
+		return DefaultTheme(), nil
+	}
+	if theme, ok := themes[name]; ok {
+		return theme, nil
+	}
+
+	data, err := os.ReadFile(name)
+	if errors.Is(err, os.ErrNotExist) && !strings.ContainsAny(name, `./\`) {
+		return nil, fmt.Errorf("%w %s, use %s or the path of a theme file", ErrUnknownTheme, name, strings.Join(ThemeNames(), ", "))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read theme: %w", err)
+	}
+	theme, err := ParseTheme(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid theme %s: %w", name, err)
+	}
+	return theme, nil
+}
+
+// ParseTheme parses a custom theme in YAML. Fields it leaves unset, and the colors of the node types
+// and relations it doesn't list, are those of its base theme.
+func ParseTheme(data []byte) (*Theme, error) {
+	var custom Theme
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&custom); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	baseName := custom.Base
+	if baseName == "" {
+		baseName = ThemeLight
+	}
+	base, ok := themes[baseName]
+	if !ok {
+		return nil, fmt.Errorf("unknown base theme %s, use %s", baseName, strings.Join(ThemeNames(), ", "))
+	}
+
+	for nodeType := range custom.Nodes {
+		if _, ok := nodeStyles[nodeType]; !ok {
+			return nil, fmt.Errorf("unknown node type %s", nodeType)
+		}
+	}
+	for edgeType, style := range custom.Edges {
+		if !slices.Contains(themeEdgeTypes, edgeType) {
+			return nil, fmt.Errorf("unknown relation %s", edgeType)
+		}
+		if err := validLineStyle(style.Style); err != nil {
+			return nil, fmt.Errorf("relation %s: %w", edgeType, err)
+		}
+	}
+	if err := validLineStyle(custom.Edge.Style); err != nil {
+		return nil, fmt.Errorf("edge: %w", err)
+	}
+
+	theme := &Theme{
+		Base:          baseName,
+		Background:    or(custom.Background, base.Background),
+		Text:          or(custom.Text, base.Text),
+		Cluster:       or(custom.Cluster, base.Cluster),
+		ClusterBorder: or(custom.ClusterBorder, base.ClusterBorder),
+		NodeBorder:    or(custom.NodeBorder, base.NodeBorder),
+		Edge:          mergeEdgeStyle(custom.Edge, base.Edge),
+		Nodes:         maps.Clone(base.Nodes),
+		Edges:         maps.Clone(base.Edges),
+	}
+	maps.Copy(theme.Nodes, custom.Nodes)
+	for edgeType, style := range custom.Edges {
+		theme.Edges[edgeType] = mergeEdgeStyle(style, base.Edges[edgeType])
+	}
+	return theme, nil
+}
+
+func validLineStyle(style string) error {
+	switch style {
+	case "", LineSolid, LineBold, LineDashed, LineDotted:
+		return nil
+	}
+	return fmt.Errorf("unknown line style %s, use solid, bold, dashed or dotted", style)
+}
+
+// mergeEdgeStyle returns style with its unset fields taken from base
+func mergeEdgeStyle(style, base EdgeStyle) EdgeStyle {
+	return EdgeStyle{Color: or(style.Color, base.Color), Style: or(style.Style, base.Style)}
+}
+
+func or(s, fallback string) string {
+	if s != "" {
+		return s
+	}
+	return fallback
+}
+
+// orDefault returns t, or the default theme if t is nil
+func (t *Theme) orDefault() *Theme {
+	if t == nil {
+		return DefaultTheme()
+	}
+	return t
+}
+
+// fillColor returns the overlay color of a node, or the theme's color for its type
+func (t *Theme) fillColor(node *graph.Node) string {
+	if node.Color != "" {
+		return node.Color
+	}
+	return t.Nodes[node.Type]
+}
+
+// edgeStyle returns the style of the relation of edge, or the theme's default edge style
+func (t *Theme) edgeStyle(edge *graph.Edge) EdgeStyle {
+	if style, ok := t.Edges[edge.Type]; ok {
+		return style
+	}
+	return t.Edge
+}
+
+// edgeStroke is how the built-in renderers draw an edge
+type edgeStroke struct {
+	color string
+	width float64
+	dash  [2]float64 // dash and gap length, zero for solid lines
+}
+
+// lineDashes are the dash and gap lengths of the dashed line styles
+var lineDashes = map[string][2]float64{
+	LineDashed: {6, 4},
+	LineDotted: {2, 3},
+}
+
+// dashArray returns the SVG stroke-dasharray of s, empty for solid lines
+func dashArray(s edgeStroke) string {
+	if s.dash[0] == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%g %g", s.dash[0], s.dash[1])
+}
+
+// stroke returns how edge is drawn, overlay colors and widths take precedence over the theme
+func (t *Theme) stroke(edge *graph.Edge) edgeStroke {
+	style := t.edgeStyle(edge)
+	s := edgeStroke{color: style.Color, width: 1, dash: lineDashes[style.Style]}
+	if style.Style == LineBold {
+		s.width = 2
+	}
+	if edge.Inferred {
+		s.dash = lineDashes[LineDotted]
+	}
+	if edge.Color != "" {
+		s.color = edge.Color
+	}
+	if edge.Width > 0 {
+		s.width = edge.Width
+	}
+	return s
+}
@@ -0,0 +1,135 @@
+package renderer
+
+import (
+	"bytes"
+	"encoding/json"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/graph"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadTheme(t *testing.T) {
+	theme, err := LoadTheme("")
+	require.NoError(t, err)
+	assert.Equal(t, DefaultTheme(), theme)
+
+	for _, name := range ThemeNames() {
+		theme, err := LoadTheme(name)
+		require.NoError(t, err, name)
+		// Every node type and the default edge must have a color
+		for nodeType := range nodeStyles {
+			assert.NotEmpty(t, theme.Nodes[nodeType], "%s %s", name, nodeType)
+		}
+		assert.NotEmpty(t, theme.Edge.Color, name)
+	}
+
+	_, err = LoadTheme("solarized")
+	assert.ErrorIs(t, err, ErrUnknownTheme)
+	assert.ErrorContains(t, err, "colorblind, dark, light")
+
+	_, err = LoadTheme(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.ErrorContains(t, err, "failed to read theme")
+}
+
+func TestLoadTheme_Custom(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "theme.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+base: dark
+background: "#000000"
+nodes:
+  topic: "#ff00ff"
+edges:
+  delivers:
+    style: dashed
+  subscribes:
+    color: "#00ff00"
+`), 0o600))
+
+	theme, err := LoadTheme(path)
+	require.NoError(t, err)
+	dark := themes[ThemeDark]
+
+	assert.Equal(t, "#000000", theme.Background)
+	assert.Equal(t, dark.Text, theme.Text)
+	assert.Equal(t, "#ff00ff", theme.Nodes[graph.NodeTypeTopic])
+	assert.Equal(t, dark.Nodes[graph.NodeTypeSubscription], theme.Nodes[graph.NodeTypeSubscription])
+	assert.Equal(t, EdgeStyle{Color: dark.Edges[graph.EdgeTypeDelivers].Color, Style: LineDashed}, theme.Edges[graph.EdgeTypeDelivers])
+	assert.Equal(t, EdgeStyle{Color: "#00ff00"}, theme.Edges[graph.EdgeTypeSubscribes])
+
+	// The built-in theme is left untouched
+	assert.NotEqual(t, "#ff00ff", dark.Nodes[graph.NodeTypeTopic])
+	assert.NotContains(t, dark.Edges, graph.EdgeTypeSubscribes)
+}
+
+func TestParseTheme_Invalid(t *testing.T) {
+	tests := map[string]string{
+		"unknown field":     "backgroud: black",
+		"unknown base":      "base: solarized",
+		"unknown node type": "nodes:\n  queue: red",
+		"unknown relation":  "edges:\n  calls:\n    color: red",
+		"unknown style":     "edges:\n  delivers:\n    style: wavy",
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParseTheme([]byte(data))
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestTheme_AppliedToEveryFormat(t *testing.T) {
+	theme, err := ParseTheme([]byte(`
+background: "#102030"
+cluster: "#405060"
+nodes:
+  topic: "#a1b2c3"
+edges:
+  cross_project:
+    color: "#d4e5f6"
+    style: dotted
+`))
+	require.NoError(t, err)
+	g := testGraph()
+
+	var dot bytes.Buffer
+	require.NoError(t, WriteDOT(&dot, g, Options{Theme: theme}))
+	assert.Contains(t, dot.String(), `bgcolor="#102030";`)
+	assert.Contains(t, dot.String(), `fillcolor="#405060";`)
+	assert.Contains(t, dot.String(), `"topic_a_t" [label="t", shape=invhouse, fillcolor="#a1b2c3"];`)
+	assert.Contains(t, dot.String(), `"sub_b_s" -> "topic_a_t" [style=dotted, color="#d4e5f6"];`)
+
+	var svg bytes.Buffer
+	require.NoError(t, WriteSVG(&svg, g, Options{Theme: theme}))
+	assert.Contains(t, svg.String(), `fill="#102030"`)
+	assert.Contains(t, svg.String(), `fill="#a1b2c3"`)
+	assert.Contains(t, svg.String(), `stroke="#d4e5f6" stroke-dasharray="2 3"`)
+
+	var html bytes.Buffer
+	require.NoError(t, WriteHTML(&html, g, Options{Theme: theme}))
+	m := regexp.MustCompile(`var GRAPH_DATA = (.*);`).FindStringSubmatch(html.String())
+	require.Len(t, m, 2)
+	var data htmlData
+	require.NoError(t, json.Unmarshal([]byte(m[1]), &data))
+	assert.Equal(t, "#102030", data.Theme.Background)
+	assert.Equal(t, "#405060", data.Theme.Cluster)
+	for _, n := range data.Nodes {
+		if n.ID == "topic_a_t" {
+			assert.Equal(t, "#a1b2c3", n.Color)
+		}
+	}
+	assert.Equal(t, "#d4e5f6", data.Edges[0].Color)
+	assert.Equal(t, "2 3", data.Edges[0].Dash)
+
+	var img bytes.Buffer
+	require.NoError(t, WritePNG(&img, g, Options{Theme: theme}))
+	decoded, err := png.Decode(&img)
+	require.NoError(t, err)
+	assert.Equal(t, color.RGBAModel.Convert(parseColor("#102030")), color.RGBAModel.Convert(decoded.At(0, 0)))
+}
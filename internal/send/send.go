@@ -0,0 +1,63 @@
+// Package send delivers the results of a scan to other services over HTTP:
+// notification webhooks, CMDB imports and the Prometheus Pushgateway.
+package send
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Timeout bounds a single request, so an endpoint that hangs fails the delivery
+// instead of stalling a scheduled scan or the daemon forever
+const Timeout = 30 * time.Second
+
+// client sends every request, replaced in tests
+var client = &http.Client{Timeout: Timeout}
+
+// JSON posts body encoded as JSON to endpoint, with header added to the request
+func JSON(ctx context.Context, endpoint string, body any, header http.Header) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	return Body(ctx, http.MethodPost, endpoint, "application/json", data, header)
+}
+
+// Body sends body with the given method and content type to endpoint, with header
+// added to the request. Responses other than 2xx are errors. Errors never include
+// the URL, which is the credential of webhooks such as Slack's.
+func Body(ctx context.Context, method, endpoint, contentType string, body []byte, header http.Header) error {
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return redact(err)
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return redact(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("endpoint returned %s", resp.Status)
+	}
+	return nil
+}
+
+// redact strips the URL that net/http includes in its errors
+func redact(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err
+	}
+	return err
+}
@@ -0,0 +1,64 @@
+package send
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSON(t *testing.T) {
+	var got struct {
+		Method, ContentType, Authorization string
+		Body                               map[string]string
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got.Method = r.Method
+		got.ContentType = r.Header.Get("Content-Type")
+		got.Authorization = r.Header.Get("Authorization")
+		data, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(data, &got.Body)
+	}))
+	defer srv.Close()
+
+	header := http.Header{"Authorization": {"Bearer secret"}}
+	require.NoError(t, JSON(context.Background(), srv.URL, map[string]string{"text": "hello"}, header))
+	assert.Equal(t, http.MethodPost, got.Method)
+	assert.Equal(t, "application/json", got.ContentType)
+	assert.Equal(t, "Bearer secret", got.Authorization)
+	assert.Equal(t, map[string]string{"text": "hello"}, got.Body)
+}
+
+func TestBody_Errors(t *testing.T) {
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "nope", http.StatusForbidden)
+	}))
+	defer failing.Close()
+
+	err := Body(context.Background(), http.MethodPut, failing.URL+"/hooks/secret", "text/plain", nil, nil)
+	require.Error(t, err)
+	assert.Equal(t, "endpoint returned 403 Forbidden", err.Error())
+
+	// A hung endpoint times out, and the error leaves out the URL
+	release := make(chan struct{})
+	hung := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer hung.Close()
+	defer close(release)
+
+	saved := client
+	client = &http.Client{Timeout: 50 * time.Millisecond}
+	defer func() { client = saved }()
+
+	err = Body(context.Background(), http.MethodPost, hung.URL+"/hooks/secret", "text/plain", nil, nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Client.Timeout exceeded")
+	assert.NotContains(t, err.Error(), "secret")
+}
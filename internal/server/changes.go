@@ -0,0 +1,127 @@
+package server
+
+import (
+	"encoding/xml"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/storage"
+)
+
+// defaultFeedSince is how far back /changes.atom reaches without a since parameter,
+// the default of 'changes list --since'
+const defaultFeedSince = 24 * time.Hour
+
+// atomFeed is the Atom (RFC 4287) document served at /changes.atom
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Link    atomLink    `xml:"link"`
+	Author  atomAuthor  `xml:"author"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr"`
+	Href string `xml:"href,attr"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomEntry struct {
+	ID       string       `xml:"id"`
+	Title    string       `xml:"title"`
+	Updated  string       `xml:"updated"`
+	Category atomCategory `xml:"category"`
+	Content  string       `xml:"content"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+// handleChanges serves the changelog as an Atom feed, newest first. It accepts repeated
+// "project" parameters and "since", a duration such as 24h or an RFC 3339 time.
+func (s *Server) handleChanges(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	now := time.Now()
+	since, err := parseSince(params.Get("since"), now)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	changes, err := s.storage.GetChanges(r.Context(), since, params["project"])
+	if err != nil {
+		s.fail(w, "failed to get changes", err)
+		return
+	}
+
+	feed := atomFeed{
+		ID:      "urn:gcp-visualizer:changes",
+		Title:   "Pub/Sub changes",
+		Updated: now.UTC().Format(time.RFC3339),
+		Link:    atomLink{Rel: "self", Href: r.URL.String()},
+		Author:  atomAuthor{Name: "gcp-visualizer"},
+	}
+	if len(changes) > 0 {
+		feed.Updated = changes[len(changes)-1].ChangedAt.UTC().Format(time.RFC3339)
+	}
+	for _, change := range slices.Backward(changes) {
+		feed.Entries = append(feed.Entries, atomEntry{
+			ID:       fmt.Sprintf("urn:gcp-visualizer:change:%d", change.ID),
+			Title:    fmt.Sprintf("%s %s %s", change.ResourceType, change.FullResourceName, change.ChangeType),
+			Updated:  change.ChangedAt.UTC().Format(time.RFC3339),
+			Category: atomCategory{Term: change.ChangeType},
+			Content:  changeContent(change),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	if _, err := fmt.Fprint(w, xml.Header); err != nil {
+		log.Printf("Failed to write changes feed: %v", err)
+		return
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(feed); err != nil {
+		log.Printf("Failed to write changes feed: %v", err)
+	}
+}
+
+// parseSince reads the since parameter of the changes feed, relative to now
+func parseSince(raw string, now time.Time) (time.Time, error) {
+	if raw == "" {
+		return now.Add(-defaultFeedSince), nil
+	}
+	if d, err := time.ParseDuration(raw); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid since %q, expected a duration such as 24h or an RFC 3339 time", raw)
+}
+
+// changeContent describes a change as plain text: the project, the scan that made it
+// and the metadata before and after
+func changeContent(change *storage.Change) string {
+	lines := []string{"Project: " + change.ProjectID}
+	if change.RunID != "" {
+		lines = append(lines, "Scan run: "+change.RunID)
+	}
+	if change.BeforeMetadata != "" {
+		lines = append(lines, "Before: "+change.BeforeMetadata)
+	}
+	if change.AfterMetadata != "" {
+		lines = append(lines, "After: "+change.AfterMetadata)
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,220 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/graph"
+	"github.com/NissesSenap/gcp-visualizer/internal/query"
+	"github.com/NissesSenap/gcp-visualizer/internal/renderer"
+	"github.com/NissesSenap/gcp-visualizer/internal/storage"
+)
+
+// Server is a read-only HTTP view of the cache: the interactive graph UI at /,
+// a JSON API under /api/ and an Atom feed of the changelog at /changes.atom. Every request reads the cache, so the pages show
+// the latest scan or incremental update without restarting the server.
+//
+// The UI and /api/graph accept repeated "project" parameters and a "where"
+// filter expression, the same as 'generate --projects --where'.
+type Server struct {
+	storage storage.Store
+	mux     *http.ServeMux
+	theme   *renderer.Theme
+}
+
+// New creates a Server reading from store
+func New(store storage.Store) *Server {
+	s := &Server{storage: store, mux: http.NewServeMux()}
+	s.mux.HandleFunc("GET /{$}", s.handleUI)
+	s.mux.HandleFunc("GET /api/projects", s.handleProjects)
+	s.mux.HandleFunc("GET /api/topics", s.handleTopics)
+	s.mux.HandleFunc("GET /api/graph", s.handleGraph)
+	s.mux.HandleFunc("GET /changes.atom", s.handleChanges)
+	return s
+}
+
+// SetTheme sets the theme the graph UI is drawn with, nil is the default theme
+func (s *Server) SetTheme(theme *renderer.Theme) {
+	s.theme = theme
+}
+
+// Handle registers an additional read-only handler, e.g. the saved view images
+func (s *Server) Handle(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, handler)
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Project is an entry of /api/projects
+type Project struct {
+	ProjectID  string     `json:"project_id"`
+	LastSynced *time.Time `json:"last_synced,omitempty"`
+}
+
+// Topic is an entry of /api/topics
+type Topic struct {
+	Name             string            `json:"name"`
+	ProjectID        string            `json:"project_id"`
+	FullResourceName string            `json:"full_resource_name"`
+	Labels           map[string]string `json:"labels,omitempty"`
+	Subscriptions    int               `json:"subscriptions"`
+}
+
+func (s *Server) handleProjects(w http.ResponseWriter, r *http.Request) {
+	projects, err := s.storage.GetAllProjects(r.Context())
+	if err != nil {
+		s.fail(w, "failed to get projects", err)
+		return
+	}
+	syncTimes, err := s.storage.GetProjectSyncTimes(r.Context())
+	if err != nil {
+		s.fail(w, "failed to get project sync times", err)
+		return
+	}
+
+	result := make([]Project, 0, len(projects))
+	for _, id := range projects {
+		p := Project{ProjectID: id}
+		if synced, ok := syncTimes[id]; ok {
+			p.LastSynced = &synced
+		}
+		result = append(result, p)
+	}
+	writeJSON(w, result)
+}
+
+func (s *Server) handleTopics(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	page, err := parsePage(params)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	topics, err := s.storage.ListTopics(r.Context(), storage.TopicQuery{Projects: params["project"]}, page)
+	if err != nil {
+		s.fail(w, "failed to get topics", err)
+		return
+	}
+	// Subscriptions may live in other projects than their topic, only those of the listed topics are counted
+	subscriptions := make(map[string]int, len(topics))
+	for _, t := range topics {
+		subscriptions[t.FullResourceName] = 0
+	}
+	err = storage.EachSubscription(r.Context(), s.storage, nil, func(sub *storage.Subscription) error {
+		if _, ok := subscriptions[sub.TopicFullResourceName]; ok {
+			subscriptions[sub.TopicFullResourceName]++
+		}
+		return nil
+	})
+	if err != nil {
+		s.fail(w, "failed to get subscriptions", err)
+		return
+	}
+
+	result := make([]Topic, 0, len(topics))
+	for _, t := range topics {
+		var metadata struct {
+			Labels map[string]string `json:"labels"`
+		}
+		// Metadata is written by the collector, a topic without it just has no labels
+		_ = json.Unmarshal([]byte(t.Metadata), &metadata)
+		result = append(result, Topic{
+			Name:             t.Name,
+			ProjectID:        t.ProjectID,
+			FullResourceName: t.FullResourceName,
+			Labels:           metadata.Labels,
+			Subscriptions:    subscriptions[t.FullResourceName],
+		})
+	}
+	writeJSON(w, result)
+}
+
+// parsePage reads the limit, offset, sort and desc parameters of a listing
+func parsePage(params url.Values) (storage.Page, error) {
+	page := storage.Page{Sort: params.Get("sort"), Desc: params.Get("desc") == "true"}
+	for name, value := range map[string]*int{"limit": &page.Limit, "offset": &page.Offset} {
+		if raw := params.Get(name); raw != "" {
+			n, err := strconv.Atoi(raw)
+			if err != nil || n < 0 {
+				return page, fmt.Errorf("invalid %s %q, expected a number of at least 0", name, raw)
+			}
+			*value = n
+		}
+	}
+	return page, page.Validate()
+}
+
+func (s *Server) handleGraph(w http.ResponseWriter, r *http.Request) {
+	g, ok := s.graph(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := renderer.WriteJSON(w, g); err != nil {
+		log.Printf("Failed to write graph: %v", err)
+	}
+}
+
+func (s *Server) handleUI(w http.ResponseWriter, r *http.Request) {
+	g, ok := s.graph(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := renderer.WriteHTML(w, g, renderer.Options{Theme: s.theme}); err != nil {
+		log.Printf("Failed to write graph UI: %v", err)
+	}
+}
+
+// graph builds the graph of the projects in the request, filtered by its where
+// expression. Edges inferred from IAM bindings alone are left out unless the request
+// sets show_inferred=true, like generate --show-inferred. On failure it answers the
+// request itself and returns false.
+func (s *Server) graph(w http.ResponseWriter, r *http.Request) (*graph.Graph, bool) {
+	params := r.URL.Query()
+	var where *query.Expr
+	if expr := params.Get("where"); expr != "" {
+		parsed, err := query.Parse(expr)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return nil, false
+		}
+		where = parsed
+	}
+
+	g, err := graph.NewBuilder(s.storage).Build(r.Context(), params["project"])
+	if err != nil {
+		s.fail(w, "failed to build graph", err)
+		return nil, false
+	}
+	if params.Get("show_inferred") != "true" {
+		g = graph.WithoutInferred(g)
+	}
+	if where != nil {
+		g = query.Filter(g, where)
+	}
+	return g, true
+}
+
+// fail logs err and answers with a generic 500, so cache details don't leak to viewers
+func (s *Server) fail(w http.ResponseWriter, msg string, err error) {
+	log.Printf("Failed to serve request: %s: %v", msg, err)
+	http.Error(w, msg, http.StatusInternalServerError)
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		log.Printf("Failed to write response: %v", err)
+	}
+}
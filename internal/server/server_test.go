@@ -0,0 +1,186 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupServer(t *testing.T) *Server {
+	store, err := storage.NewSQLite(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = store.Close() })
+
+	ctx := context.Background()
+	require.NoError(t, store.SaveTopics(ctx, []*storage.Topic{
+		{Name: "orders", ProjectID: "project-a", FullResourceName: "projects/project-a/topics/orders", Metadata: `{"labels":{"team":"checkout"}}`},
+		{Name: "users", ProjectID: "project-b", FullResourceName: "projects/project-b/topics/users", Metadata: `{"labels":{}}`},
+	}))
+	require.NoError(t, store.SaveSubscriptions(ctx, []*storage.Subscription{
+		{Name: "orders-bq", ProjectID: "project-a", TopicFullResourceName: "projects/project-a/topics/orders", FullResourceName: "projects/project-a/subscriptions/orders-bq"},
+		{Name: "orders-email", ProjectID: "project-b", TopicFullResourceName: "projects/project-a/topics/orders", FullResourceName: "projects/project-b/subscriptions/orders-email"},
+	}))
+	require.NoError(t, store.UpdateProjectSyncTime(ctx, "project-a"))
+	return New(store)
+}
+
+func get(t *testing.T, s *Server, target string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+	return rec
+}
+
+func TestServer_Projects(t *testing.T) {
+	rec := get(t, setupServer(t), "/api/projects")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+	var projects []Project
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &projects))
+	require.Len(t, projects, 2)
+	assert.Equal(t, "project-a", projects[0].ProjectID)
+	assert.NotNil(t, projects[0].LastSynced)
+	assert.Equal(t, "project-b", projects[1].ProjectID)
+}
+
+func TestServer_Topics(t *testing.T) {
+	s := setupServer(t)
+
+	var topics []Topic
+	rec := get(t, s, "/api/topics")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &topics))
+	assert.Equal(t, []Topic{
+		{Name: "orders", ProjectID: "project-a", FullResourceName: "projects/project-a/topics/orders", Labels: map[string]string{"team": "checkout"}, Subscriptions: 2},
+		{Name: "users", ProjectID: "project-b", FullResourceName: "projects/project-b/topics/users", Subscriptions: 0},
+	}, topics)
+
+	rec = get(t, s, "/api/topics?project=project-b")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &topics))
+	require.Len(t, topics, 1)
+	assert.Equal(t, "users", topics[0].Name)
+
+	rec = get(t, s, "/api/topics?sort=name&desc=true&limit=1")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &topics))
+	require.Len(t, topics, 1)
+	assert.Equal(t, "users", topics[0].Name)
+
+	var page []Topic
+	rec = get(t, s, "/api/topics?offset=1&limit=5")
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &page))
+	assert.Equal(t, []Topic{{Name: "users", ProjectID: "project-b", FullResourceName: "projects/project-b/topics/users", Subscriptions: 0}}, page)
+
+	assert.Equal(t, http.StatusBadRequest, get(t, s, "/api/topics?limit=-1").Code)
+	assert.Equal(t, http.StatusBadRequest, get(t, s, "/api/topics?sort=size").Code)
+}
+
+func TestServer_Graph(t *testing.T) {
+	s := setupServer(t)
+
+	var g struct {
+		Nodes []struct {
+			ID string `json:"id"`
+		} `json:"nodes"`
+	}
+	rec := get(t, s, "/api/graph?project=project-b")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &g))
+	var ids []string
+	for _, n := range g.Nodes {
+		ids = append(ids, n.ID)
+	}
+	assert.Contains(t, ids, "topic_project-b_users")
+	assert.NotContains(t, ids, "sub_project-a_orders-bq")
+
+	rec = get(t, s, "/api/graph?where="+url.QueryEscape("name =="))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestServer_GraphInferred(t *testing.T) {
+	store, err := storage.NewSQLite(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = store.Close() })
+	ctx := context.Background()
+	require.NoError(t, store.SaveTopic(ctx, &storage.Topic{Name: "orders", ProjectID: "project-a", FullResourceName: "projects/project-a/topics/orders"}))
+	require.NoError(t, store.SaveSubscription(ctx, &storage.Subscription{
+		Name: "orders-email", ProjectID: "project-a", TopicFullResourceName: "projects/project-a/topics/orders", FullResourceName: "projects/project-a/subscriptions/orders-email",
+	}))
+	// Allowed to pull by IAM, never seen pulling
+	require.NoError(t, store.ReplaceSubscriptionConsumers(ctx, "projects/project-a/subscriptions/orders-email", storage.ConsumerSourceIAM, []*storage.SubscriptionConsumer{
+		{ProjectID: "project-a", Principal: "user:alice@example.com", Role: "roles/pubsub.subscriber"},
+	}))
+	s := New(store)
+
+	inferred := func(target string) int {
+		var g struct {
+			Edges []struct {
+				Inferred bool `json:"inferred"`
+			} `json:"edges"`
+		}
+		rec := get(t, s, target)
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &g))
+		n := 0
+		for _, e := range g.Edges {
+			if e.Inferred {
+				n++
+			}
+		}
+		return n
+	}
+	assert.Zero(t, inferred("/api/graph"))
+	assert.Equal(t, 1, inferred("/api/graph?show_inferred=true"))
+}
+
+func TestServer_UI(t *testing.T) {
+	s := setupServer(t)
+
+	rec := get(t, s, "/")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "text/html; charset=utf-8", rec.Header().Get("Content-Type"))
+	assert.Contains(t, rec.Body.String(), "<html")
+
+	assert.Equal(t, http.StatusNotFound, get(t, s, "/missing").Code)
+
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/topics", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
+
+func TestServer_Changes(t *testing.T) {
+	s := setupServer(t)
+
+	rec := get(t, s, "/changes.atom?project=project-a")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/atom+xml; charset=utf-8", rec.Header().Get("Content-Type"))
+
+	var feed atomFeed
+	require.NoError(t, xml.Unmarshal(rec.Body.Bytes(), &feed))
+	require.Len(t, feed.Entries, 2)
+	// Newest first, the subscription was written after its topic
+	assert.Equal(t, "subscription projects/project-a/subscriptions/orders-bq created", feed.Entries[0].Title)
+	assert.Equal(t, "topic projects/project-a/topics/orders created", feed.Entries[1].Title)
+	assert.Equal(t, storage.ChangeTypeCreated, feed.Entries[1].Category.Term)
+	assert.Contains(t, feed.Entries[1].Content, `"team":"checkout"`)
+
+	// Changes before since are left out
+	rec = get(t, s, "/changes.atom?since="+url.QueryEscape(time.Now().Add(time.Hour).Format(time.RFC3339)))
+	require.Equal(t, http.StatusOK, rec.Code)
+	feed = atomFeed{}
+	require.NoError(t, xml.Unmarshal(rec.Body.Bytes(), &feed))
+	assert.Empty(t, feed.Entries)
+
+	rec = get(t, s, "/changes.atom?since=yesterday")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
@@ -0,0 +1,235 @@
+package snapshot
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/storage"
+)
+
+// RenderFunc renders the saved view name in format ("svg" or "png") to the file output
+type RenderFunc func(ctx context.Context, store storage.Store, view, format, output string) error
+
+// contentTypes are the formats a snapshot can be requested in
+var contentTypes = map[string]string{
+	"svg": "image/svg+xml",
+	"png": "image/png",
+}
+
+// Handler serves the saved views as images that can be embedded in wiki pages
+// with a plain <img> tag, e.g. /views/checkout.svg, and an index page listing
+// them at /views/.
+//
+// The stable URL redirects to the same URL with the cache revision in the "rev"
+// query parameter. Revisioned URLs never change content, so wikis and browsers
+// can cache them forever, and the next scan or incremental update changes the
+// redirect target instead.
+type Handler struct {
+	storage storage.Store
+	names   []string // sorted
+	views   map[string]bool
+	render  RenderFunc
+	token   string
+
+	mu       sync.Mutex
+	revision string
+	images   map[string][]byte // keyed by view and format, rendered at revision
+}
+
+// NewHandler creates a Handler for views rendered from store. If token is non-empty,
+// requests must carry it in the "token" query parameter.
+func NewHandler(store storage.Store, views []string, render RenderFunc, token string) *Handler {
+	known := make(map[string]bool, len(views))
+	for _, view := range views {
+		known[view] = true
+	}
+	names := slices.Clone(views)
+	sort.Strings(names)
+	return &Handler{
+		storage: store,
+		names:   names,
+		views:   known,
+		render:  render,
+		token:   token,
+		images:  make(map[string][]byte),
+	}
+}
+
+// ServeHTTP implements http.Handler
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if h.token != "" && subtle.ConstantTimeCompare([]byte(r.URL.Query().Get("token")), []byte(h.token)) != 1 {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if strings.HasSuffix(r.URL.Path, "/") {
+		h.serveIndex(w, r)
+		return
+	}
+
+	file := path.Base(r.URL.Path)
+	format := strings.TrimPrefix(path.Ext(file), ".")
+	view := strings.TrimSuffix(file, path.Ext(file))
+	contentType, ok := contentTypes[format]
+	if !ok || !h.views[view] {
+		http.NotFound(w, r)
+		return
+	}
+
+	revision, err := Revision(r.Context(), h.storage)
+	if err != nil {
+		log.Printf("Failed to read cache revision: %v", err)
+		http.Error(w, "failed to read cache", http.StatusInternalServerError)
+		return
+	}
+
+	if r.URL.Query().Get("rev") != revision {
+		query := r.URL.Query()
+		query.Set("rev", revision)
+		target := url.URL{Path: r.URL.Path, RawQuery: query.Encode()}
+		w.Header().Set("Cache-Control", "no-cache")
+		http.Redirect(w, r, target.String(), http.StatusFound)
+		return
+	}
+
+	image, err := h.image(r.Context(), view, format, revision)
+	if err != nil {
+		log.Printf("Failed to render view %s as %s: %v", view, format, err)
+		http.Error(w, "failed to render view", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	w.Header().Set("ETag", `"`+revision+`"`)
+	_, _ = w.Write(image)
+}
+
+// indexTemplate lists the saved views with links to their images
+var indexTemplate = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Saved views</title></head>
+<body>
+<h1>Saved views</h1>
+{{- if .Views}}
+<ul>
+{{- range .Views}}
+  <li>{{.Name}}: <a href="{{.SVG}}">SVG</a> <a href="{{.PNG}}">PNG</a></li>
+{{- end}}
+</ul>
+{{- else}}
+<p>No views are configured.</p>
+{{- end}}
+</body>
+</html>
+`))
+
+// serveIndex lists the saved views, linking to their images with the token of the request
+func (h *Handler) serveIndex(w http.ResponseWriter, r *http.Request) {
+	type viewLinks struct{ Name, SVG, PNG string }
+	link := func(view, format string) string {
+		u := url.URL{Path: r.URL.Path + url.PathEscape(view) + "." + format}
+		if h.token != "" {
+			u.RawQuery = url.Values{"token": {h.token}}.Encode()
+		}
+		return u.String()
+	}
+	views := make([]viewLinks, 0, len(h.names))
+	for _, name := range h.names {
+		views = append(views, viewLinks{Name: name, SVG: link(name, "svg"), PNG: link(name, "png")})
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	if err := indexTemplate.Execute(w, struct{ Views []viewLinks }{views}); err != nil {
+		log.Printf("Failed to write view index: %v", err)
+	}
+}
+
+// image returns the view rendered at revision, rendering it on first use.
+// Images of older revisions are dropped.
+func (h *Handler) image(ctx context.Context, view, format, revision string) ([]byte, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.revision != revision {
+		h.revision = revision
+		h.images = make(map[string][]byte)
+	}
+	key := view + "." + format
+	if image, ok := h.images[key]; ok {
+		return image, nil
+	}
+
+	dir, err := os.MkdirTemp("", "gcp-visualizer-snapshot-")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	output := filepath.Join(dir, key)
+	if err := h.render(ctx, h.storage, view, format, output); err != nil {
+		return nil, err
+	}
+	image, err := os.ReadFile(output)
+	if err != nil {
+		return nil, err
+	}
+	h.images[key] = image
+	return image, nil
+}
+
+// Revision identifies the current contents of the cache. It changes with every
+// completed scan and with every change recorded since, including the run ID of
+// the scan that made it.
+func Revision(ctx context.Context, store storage.Store) (string, error) {
+	syncTimes, err := store.GetProjectSyncTimes(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get project sync times: %w", err)
+	}
+
+	projects := make([]string, 0, len(syncTimes))
+	var latest time.Time
+	for project, syncedAt := range syncTimes {
+		projects = append(projects, project)
+		if syncedAt.After(latest) {
+			latest = syncedAt
+		}
+	}
+	sort.Strings(projects)
+
+	hash := sha256.New()
+	for _, project := range projects {
+		fmt.Fprintf(hash, "%s=%s\n", project, syncTimes[project].UTC().Format(time.RFC3339Nano))
+	}
+
+	// Changes made by the listener between scans
+	changes, err := store.GetChanges(ctx, latest, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to get changes: %w", err)
+	}
+	if n := len(changes); n > 0 {
+		last := changes[n-1]
+		fmt.Fprintf(hash, "changes=%d last=%d run=%s\n", n, last.ID, last.RunID)
+	}
+	return hex.EncodeToString(hash.Sum(nil))[:12], nil
+}
@@ -0,0 +1,120 @@
+package snapshot
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupStore(t *testing.T) storage.Store {
+	store, err := storage.NewSQLite(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = store.Close() })
+
+	ctx := storage.WithRunID(context.Background(), "run-1")
+	require.NoError(t, store.SaveTopic(ctx, &storage.Topic{Name: "orders", ProjectID: "project-a", FullResourceName: "projects/project-a/topics/orders"}))
+	require.NoError(t, store.UpdateProjectSyncTime(ctx, "project-a"))
+	return store
+}
+
+func TestHandler(t *testing.T) {
+	store := setupStore(t)
+	renders := 0
+	render := func(ctx context.Context, store storage.Store, view, format, output string) error {
+		renders++
+		return os.WriteFile(output, []byte("<svg>"+view+"</svg>"), 0644)
+	}
+	h := NewHandler(store, []string{"checkout"}, render, "")
+
+	get := func(target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		return rec
+	}
+
+	// The stable URL redirects to the current revision
+	rec := get("/views/checkout.svg")
+	require.Equal(t, http.StatusFound, rec.Code)
+	assert.Equal(t, "no-cache", rec.Header().Get("Cache-Control"))
+	location, err := url.Parse(rec.Header().Get("Location"))
+	require.NoError(t, err)
+	assert.Equal(t, "/views/checkout.svg", location.Path)
+	revision := location.Query().Get("rev")
+	require.NotEmpty(t, revision)
+
+	rec = get(location.String())
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "image/svg+xml", rec.Header().Get("Content-Type"))
+	assert.Contains(t, rec.Header().Get("Cache-Control"), "immutable")
+	assert.Equal(t, "<svg>checkout</svg>", rec.Body.String())
+
+	// Images are rendered once per revision
+	get(location.String())
+	assert.Equal(t, 1, renders)
+
+	// A change to the cache moves the redirect to a new revision
+	require.NoError(t, store.SaveTopic(context.Background(), &storage.Topic{Name: "users", ProjectID: "project-a", FullResourceName: "projects/project-a/topics/users"}))
+	rec = get(location.String())
+	require.Equal(t, http.StatusFound, rec.Code)
+	next, err := url.Parse(rec.Header().Get("Location"))
+	require.NoError(t, err)
+	assert.NotEqual(t, revision, next.Query().Get("rev"))
+
+	assert.Equal(t, http.StatusNotFound, get("/views/missing.svg").Code)
+	assert.Equal(t, http.StatusNotFound, get("/views/checkout.pdf").Code)
+}
+
+func TestHandler_Token(t *testing.T) {
+	store := setupStore(t)
+	render := func(ctx context.Context, store storage.Store, view, format, output string) error {
+		return os.WriteFile(output, []byte("png"), 0644)
+	}
+	h := NewHandler(store, []string{"checkout"}, render, "secret")
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/views/checkout.png", nil))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	// The redirect keeps the token
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/views/checkout.png?token=secret", nil))
+	require.Equal(t, http.StatusFound, rec.Code)
+	location, err := url.Parse(rec.Header().Get("Location"))
+	require.NoError(t, err)
+	assert.Equal(t, "secret", location.Query().Get("token"))
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, location.String(), nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "image/png", rec.Header().Get("Content-Type"))
+}
+
+func TestHandler_Index(t *testing.T) {
+	store := setupStore(t)
+	render := func(ctx context.Context, store storage.Store, view, format, output string) error {
+		return os.WriteFile(output, []byte("<svg></svg>"), 0644)
+	}
+	h := NewHandler(store, []string{"payments", "checkout"}, render, "secret")
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/views/", nil))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/views/?token=secret", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "text/html; charset=utf-8", rec.Header().Get("Content-Type"))
+	body := rec.Body.String()
+	assert.Contains(t, body, `<a href="/views/checkout.svg?token=secret">SVG</a>`)
+	assert.Contains(t, body, `<a href="/views/payments.png?token=secret">PNG</a>`)
+	// Sorted by name
+	assert.Less(t, strings.Index(body, "checkout"), strings.Index(body, "payments"))
+}
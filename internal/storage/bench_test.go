@@ -0,0 +1,121 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+// benchResources is the size of a large organization's Pub/Sub inventory
+const benchResources = 50000
+
+// benchBatchSize is the collector's default number of resources per transaction
+const benchBatchSize = 500
+
+func newBenchStorage(b *testing.B) *SQLiteStorage {
+	store, err := NewSQLite(filepath.Join(b.TempDir(), "cache.db"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { _ = store.Close() })
+	return store
+}
+
+func benchTopics(project string, n int) []*Topic {
+	topics := make([]*Topic, n)
+	for i := range topics {
+		name := fmt.Sprintf("topic-%05d", i)
+		topics[i] = &Topic{
+			Name:             name,
+			ProjectID:        project,
+			FullResourceName: "projects/" + project + "/topics/" + name,
+			Metadata:         `{"labels":{"team":"checkout"}}`,
+		}
+	}
+	return topics
+}
+
+func benchSubscriptions(project string, n int) []*Subscription {
+	subs := make([]*Subscription, n)
+	for i := range subs {
+		name := fmt.Sprintf("sub-%05d", i)
+		subs[i] = &Subscription{
+			Name:                  name,
+			ProjectID:             project,
+			TopicFullResourceName: fmt.Sprintf("projects/%s/topics/topic-%05d", project, i),
+			FullResourceName:      "projects/" + project + "/subscriptions/" + name,
+			Metadata:              `{"labels":{},"ack_deadline_seconds":10}`,
+		}
+	}
+	return subs
+}
+
+// saveInBatches writes items the way the collector does, benchBatchSize per transaction
+func saveInBatches[T any](b *testing.B, items []T, save func(context.Context, []T) error) {
+	ctx := context.Background()
+	for start := 0; start < len(items); start += benchBatchSize {
+		if err := save(ctx, items[start:min(start+benchBatchSize, len(items))]); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkSaveTopics writes 50k topics into an empty cache, then rescans them unchanged
+func BenchmarkSaveTopics(b *testing.B) {
+	topics := benchTopics("project-a", benchResources)
+	for b.Loop() {
+		b.StopTimer()
+		store := newBenchStorage(b)
+		b.StartTimer()
+
+		saveInBatches(b, topics, store.SaveTopics)
+		saveInBatches(b, topics, store.SaveTopics)
+	}
+	b.ReportMetric(float64(2*benchResources*b.N)/b.Elapsed().Seconds(), "writes/s")
+}
+
+// BenchmarkSaveSubscriptions writes 50k subscriptions into an empty cache
+func BenchmarkSaveSubscriptions(b *testing.B) {
+	subs := benchSubscriptions("project-a", benchResources)
+	for b.Loop() {
+		b.StopTimer()
+		store := newBenchStorage(b)
+		b.StartTimer()
+
+		saveInBatches(b, subs, store.SaveSubscriptions)
+	}
+	b.ReportMetric(float64(benchResources*b.N)/b.Elapsed().Seconds(), "writes/s")
+}
+
+// BenchmarkTouchTopics records 50k unchanged topics as seen, as an incremental scan does
+func BenchmarkTouchTopics(b *testing.B) {
+	store := newBenchStorage(b)
+	topics := benchTopics("project-a", benchResources)
+	saveInBatches(b, topics, store.SaveTopics)
+	names := make([]string, len(topics))
+	for i, t := range topics {
+		names[i] = t.FullResourceName
+	}
+
+	for b.Loop() {
+		saveInBatches(b, names, store.TouchTopics)
+	}
+}
+
+// BenchmarkListTopics reads 50k topics a page at a time
+func BenchmarkListTopics(b *testing.B) {
+	store := newBenchStorage(b)
+	saveInBatches(b, benchTopics("project-a", benchResources), store.SaveTopics)
+	ctx := context.Background()
+
+	for b.Loop() {
+		n := 0
+		if err := EachTopic(ctx, store, TopicQuery{}, func(*Topic) error { n++; return nil }); err != nil {
+			b.Fatal(err)
+		}
+		if n != benchResources {
+			b.Fatalf("listed %d topics, want %d", n, benchResources)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"sync"
+)
+
+// maxStatementParams bounds the parameters bound to one multi-row statement. The modernc driver
+// looks up the argument of every parameter by scanning all arguments, so binding is quadratic
+// and larger statements get slower per row.
+const maxStatementParams = 256
+
+// The modernc driver compiles the SQL of a statement on every execution, even of a statement
+// prepared with database/sql, so compiling dominated writing a row at a time. Bulk writes
+// instead bind rows in chunks to multi-row statements, whose SQL is built once per row count.
+var bulkSQL sync.Map // bulkKey -> string
+
+type bulkKey struct {
+	prefix, tuple, suffix string
+	rows                  int
+}
+
+// repeatedSQL returns prefix, rows copies of tuple separated by commas, and suffix
+func repeatedSQL(prefix, tuple, suffix string, rows int) string {
+	key := bulkKey{prefix, tuple, suffix, rows}
+	if query, ok := bulkSQL.Load(key); ok {
+		return query.(string)
+	}
+	var b strings.Builder
+	b.Grow(len(prefix) + rows*(len(tuple)+2) + len(suffix))
+	b.WriteString(prefix)
+	for i := range rows {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(tuple)
+	}
+	b.WriteString(suffix)
+	query, _ := bulkSQL.LoadOrStore(key, b.String())
+	return query.(string)
+}
+
+// execRows runs the INSERT prefix with a VALUES tuple per row of args, which holds width
+// arguments per row, with as many rows per statement as fit maxStatementParams
+func execRows(ctx context.Context, tx *writeTx, prefix, tuple string, width int, args []interface{}) error {
+	rows := len(args) / width
+	chunk := max(1, maxStatementParams/width)
+	for start := 0; start < rows; start += chunk {
+		// Stop a cancelled scan between statements, the caller's rollback discards the batch
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		end := min(start+chunk, rows)
+		query := repeatedSQL(prefix+` VALUES `, tuple, ``, end-start)
+		if _, err := tx.ExecContext(ctx, query, args[start*width:end*width]...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// execIn runs query, which ends in "IN (", once per maxStatementParams of values, closing the list
+func execIn(ctx context.Context, tx *writeTx, query string, values []string) error {
+	for start := 0; start < len(values); start += maxStatementParams {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		chunk := values[start:min(start+maxStatementParams, len(values))]
+		if _, err := tx.ExecContext(ctx, repeatedSQL(query, `?`, `)`, len(chunk)), stringArgs(chunk)...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// storedMetadata returns the metadata of the rows of table with the given full resource names, by full resource name
+func storedMetadata(ctx context.Context, tx *writeTx, table string, names []string) (map[string]sql.NullString, error) {
+	stored := make(map[string]sql.NullString, len(names))
+	for start := 0; start < len(names); start += maxStatementParams {
+		chunk := names[start:min(start+maxStatementParams, len(names))]
+		query := repeatedSQL(`SELECT full_resource_name, metadata FROM `+table+` WHERE full_resource_name IN (`, `?`, `)`, len(chunk))
+		rows, err := tx.QueryContext(ctx, query, stringArgs(chunk)...)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var name string
+			var metadata sql.NullString
+			if err := rows.Scan(&name, &metadata); err != nil {
+				_ = rows.Close()
+				return nil, err
+			}
+			stored[name] = metadata
+		}
+		if err := rows.Close(); err != nil {
+			return nil, err
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return stored, nil
+}
+
+// uncached returns the items whose full resource name, returned by name, has no row in table
+func uncached[T any](ctx context.Context, tx *writeTx, table string, items []T, name func(T) string) ([]T, error) {
+	names := make([]string, len(items))
+	for i, item := range items {
+		names[i] = name(item)
+	}
+	stored, err := storedMetadata(ctx, tx, table, names)
+	if err != nil {
+		return nil, err
+	}
+	missing := make([]T, 0, len(items))
+	for _, item := range items {
+		if _, ok := stored[name(item)]; !ok {
+			missing = append(missing, item)
+		}
+	}
+	return missing, nil
+}
+
+// stringArgs converts values to statement arguments
+func stringArgs(values []string) []interface{} {
+	args := make([]interface{}, len(values))
+	for i, v := range values {
+		args[i] = v
+	}
+	return args
+}
@@ -0,0 +1,109 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+type runIDKey struct{}
+
+// WithRunID returns a context whose writes are recorded in the changelog under runID
+func WithRunID(ctx context.Context, runID string) context.Context {
+	return context.WithValue(ctx, runIDKey{}, runID)
+}
+
+// RunIDFromContext returns the run ID set by WithRunID, or an empty string
+func RunIDFromContext(ctx context.Context) string {
+	runID, _ := ctx.Value(runIDKey{}).(string)
+	return runID
+}
+
+// upsert is a resource about to be written, as recorded in the changelog
+type upsert struct {
+	fullResourceName, projectID, metadata string
+}
+
+// recordUpserts compares the stored metadata of every resource with the metadata about
+// to be written, and records a created or updated change for those that differ.
+// It must run before the upserts in the same transaction.
+func recordUpserts(ctx context.Context, tx *writeTx, table, resourceType string, upserts []upsert) error {
+	names := make([]string, len(upserts))
+	for i, u := range upserts {
+		names[i] = u.fullResourceName
+	}
+	stored, err := storedMetadata(ctx, tx, table, names)
+	if err != nil {
+		return err
+	}
+
+	runID := RunIDFromContext(ctx)
+	args := make([]interface{}, 0, 7*len(upserts))
+	for _, u := range upserts {
+		before, ok := stored[u.fullResourceName]
+		changeType := ChangeTypeUpdated
+		switch {
+		case !ok:
+			changeType = ChangeTypeCreated
+		case before.String == u.metadata:
+			continue
+		}
+		args = append(args, runID, resourceType, u.fullResourceName, u.projectID, changeType, before, u.metadata)
+		// A later row of the same batch compares with this one, as it would one row at a time
+		stored[u.fullResourceName] = sql.NullString{String: u.metadata, Valid: true}
+	}
+
+	return execRows(ctx, tx, `
+        INSERT INTO changes
+        (run_id, resource_type, full_resource_name, project_id, change_type, before_metadata, after_metadata, changed_at)`,
+		`(?, ?, ?, ?, ?, ?, ?, `+syncTimestamp+`)`, 7, args)
+}
+
+// recordDeletes records a deleted change for every row of table matching where.
+// It must run before the delete in the same transaction.
+func recordDeletes(ctx context.Context, tx *writeTx, table, resourceType, where string, args ...interface{}) error {
+	_, err := tx.ExecContext(ctx, `
+        INSERT INTO changes
+        (run_id, resource_type, full_resource_name, project_id, change_type, before_metadata, changed_at)
+        SELECT ?, ?, full_resource_name, project_id, ?, metadata, `+syncTimestamp+`
+        FROM `+table+` WHERE `+where,
+		append([]interface{}{RunIDFromContext(ctx), resourceType, ChangeTypeDeleted}, args...)...)
+	return err
+}
+
+// GetChanges returns the changelog entries recorded at or after since, oldest first.
+// An empty projects slice includes every project.
+func (s *SQLiteStorage) GetChanges(ctx context.Context, since time.Time, projects []string) ([]*Change, error) {
+	query := `SELECT id, run_id, resource_type, full_resource_name, project_id, change_type,
+                     before_metadata, after_metadata, changed_at
+              FROM changes
+              WHERE changed_at >= ?`
+	args := []interface{}{since.UTC().Format(syncTimestampLayout)}
+	if len(projects) > 0 {
+		inClause, inArgs := buildInClause(projects)
+		query += fmt.Sprintf(` AND project_id IN (%s)`, inClause)
+		args = append(args, inArgs...)
+	}
+	query += ` ORDER BY id`
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var changes []*Change
+	for rows.Next() {
+		c := &Change{}
+		var before, after sql.NullString
+		if err := rows.Scan(&c.ID, &c.RunID, &c.ResourceType, &c.FullResourceName, &c.ProjectID, &c.ChangeType,
+			&before, &after, &c.ChangedAt); err != nil {
+			return nil, err
+		}
+		c.BeforeMetadata = before.String
+		c.AfterMetadata = after.String
+		changes = append(changes, c)
+	}
+	return changes, rows.Err()
+}
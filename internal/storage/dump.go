@@ -0,0 +1,252 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// DumpFormat identifies the JSON documents written by Export
+const DumpFormat = "gcp-visualizer-dump"
+
+// Dump is a portable copy of every cache table, keyed by table name.
+// Rows map column names to values, timestamps are kept as stored.
+type Dump struct {
+	Format        string                      `json:"format"`
+	SchemaVersion int                         `json:"schema_version"`
+	ExportedAt    time.Time                   `json:"exported_at"`
+	Tables        map[string][]map[string]any `json:"tables"`
+}
+
+// tableColumns describes a cache table
+type tableColumns struct {
+	name       string
+	columns    []string
+	timestamps map[string]bool // TIMESTAMP columns, read as text so they round-trip unchanged
+}
+
+// cacheTables returns every table except the migration bookkeeping, sorted by name
+func (s *SQLiteStorage) cacheTables(ctx context.Context) ([]*tableColumns, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT name FROM sqlite_master
+		WHERE type = 'table' AND name NOT LIKE 'sqlite_%' AND name != 'schema_migrations'
+		ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tables: %w", err)
+	}
+	var tables []*tableColumns
+	for rows.Next() {
+		t := &tableColumns{timestamps: make(map[string]bool)}
+		if err := rows.Scan(&t.name); err != nil {
+			_ = rows.Close()
+			return nil, err
+		}
+		tables = append(tables, t)
+	}
+	_ = rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Read the columns once the table list is closed, ":memory:" has a single connection
+	for _, t := range tables {
+		rows, err := s.db.QueryContext(ctx, "SELECT name, type FROM pragma_table_info(?) ORDER BY cid", t.name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list columns of %s: %w", t.name, err)
+		}
+		for rows.Next() {
+			var column, columnType string
+			if err := rows.Scan(&column, &columnType); err != nil {
+				_ = rows.Close()
+				return nil, err
+			}
+			t.columns = append(t.columns, column)
+			if strings.EqualFold(columnType, "TIMESTAMP") {
+				t.timestamps[column] = true
+			}
+		}
+		_ = rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return tables, nil
+}
+
+// schemaVersion returns the latest applied migration
+func (s *SQLiteStorage) schemaVersion(ctx context.Context) (int, error) {
+	var version int
+	if err := s.db.QueryRowContext(ctx, "SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&version); err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	return version, nil
+}
+
+// Export writes every cache table to w as a JSON Dump
+func (s *SQLiteStorage) Export(ctx context.Context, w io.Writer) error {
+	version, err := s.schemaVersion(ctx)
+	if err != nil {
+		return err
+	}
+	tables, err := s.cacheTables(ctx)
+	if err != nil {
+		return err
+	}
+
+	dump := &Dump{
+		Format:        DumpFormat,
+		SchemaVersion: version,
+		ExportedAt:    time.Now().UTC(),
+		Tables:        make(map[string][]map[string]any, len(tables)),
+	}
+	for _, t := range tables {
+		selects := make([]string, len(t.columns))
+		for i, column := range t.columns {
+			selects[i] = quoteIdentifier(column)
+			if t.timestamps[column] {
+				selects[i] = fmt.Sprintf("CAST(%s AS TEXT)", quoteIdentifier(column))
+			}
+		}
+		query := fmt.Sprintf("SELECT %s FROM %s ORDER BY rowid", strings.Join(selects, ", "), quoteIdentifier(t.name))
+
+		rows, err := s.db.QueryContext(ctx, query)
+		if err != nil {
+			return fmt.Errorf("failed to export %s: %w", t.name, err)
+		}
+		records := make([]map[string]any, 0)
+		for rows.Next() {
+			values := make([]any, len(t.columns))
+			ptrs := make([]any, len(t.columns))
+			for i := range values {
+				ptrs[i] = &values[i]
+			}
+			if err := rows.Scan(ptrs...); err != nil {
+				_ = rows.Close()
+				return fmt.Errorf("failed to export %s: %w", t.name, err)
+			}
+			record := make(map[string]any, len(t.columns))
+			for i, column := range t.columns {
+				if b, ok := values[i].([]byte); ok {
+					values[i] = string(b)
+				}
+				record[column] = values[i]
+			}
+			records = append(records, record)
+		}
+		_ = rows.Close()
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("failed to export %s: %w", t.name, err)
+		}
+		dump.Tables[t.name] = records
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(dump)
+}
+
+// Import replaces the contents of every table in the dump read from r, in a single transaction.
+// Tables missing from the dump are left alone. Dumps of a newer schema are refused.
+func (s *SQLiteStorage) Import(ctx context.Context, r io.Reader) error {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	var dump Dump
+	if err := dec.Decode(&dump); err != nil {
+		return fmt.Errorf("failed to read dump: %w", err)
+	}
+	if dump.Format != DumpFormat {
+		return fmt.Errorf("not a gcp-visualizer dump (format %q)", dump.Format)
+	}
+
+	version, err := s.schemaVersion(ctx)
+	if err != nil {
+		return err
+	}
+	if dump.SchemaVersion > version {
+		return fmt.Errorf("dump has schema version %d, newer than this version's %d", dump.SchemaVersion, version)
+	}
+
+	tables, err := s.cacheTables(ctx)
+	if err != nil {
+		return err
+	}
+	known := make(map[string]map[string]bool, len(tables))
+	for _, t := range tables {
+		known[t.name] = make(map[string]bool, len(t.columns))
+		for _, column := range t.columns {
+			known[t.name][column] = true
+		}
+	}
+	for name, records := range dump.Tables {
+		columns, ok := known[name]
+		if !ok {
+			return fmt.Errorf("dump has unknown table %s", name)
+		}
+		for _, record := range records {
+			for column := range record {
+				if !columns[column] {
+					return fmt.Errorf("dump has unknown column %s.%s", name, column)
+				}
+			}
+		}
+	}
+
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, t := range tables {
+		records, ok := dump.Tables[t.name]
+		if !ok {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM "+quoteIdentifier(t.name)); err != nil {
+			return fmt.Errorf("failed to clear %s: %w", t.name, err)
+		}
+		for _, record := range records {
+			columns := make([]string, 0, len(record))
+			placeholders := make([]string, 0, len(record))
+			args := make([]any, 0, len(record))
+			for _, column := range t.columns {
+				value, ok := record[column]
+				if !ok {
+					continue
+				}
+				columns = append(columns, quoteIdentifier(column))
+				placeholders = append(placeholders, "?")
+				args = append(args, dumpValue(value))
+			}
+			query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+				quoteIdentifier(t.name), strings.Join(columns, ", "), strings.Join(placeholders, ", "))
+			if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+				return fmt.Errorf("failed to import %s: %w", t.name, err)
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+// dumpValue converts a decoded JSON value back into a column value
+func dumpValue(value any) any {
+	n, ok := value.(json.Number)
+	if !ok {
+		return value
+	}
+	if i, err := n.Int64(); err == nil {
+		return i
+	}
+	if f, err := n.Float64(); err == nil {
+		return f
+	}
+	return n.String()
+}
+
+// quoteIdentifier quotes a table or column name for SQLite
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
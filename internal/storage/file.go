@@ -0,0 +1,1950 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FileStorage is a Store kept in memory and persisted as a single JSON file in the
+// Dump format, so a cache file and an 'export' dump are interchangeable.
+// Every write rewrites the whole file, which suits caches of up to tens of
+// thousands of resources. Unlike SQLite it doesn't coordinate writers across
+// processes: the last process to write wins.
+type FileStorage struct {
+	mu    sync.Mutex
+	path  string // empty keeps the cache in memory only
+	state *fileState
+}
+
+// fileState is the in-memory cache. Rows get IDs per table, like SQLite rowids,
+// and a replaced row gets a new ID.
+type fileState struct {
+	projects      map[string]time.Time // last synced
+	statuses      map[string]string    // keyed by project, only projects with a status
+	labels        map[string]string    // JSON encoded labels keyed by project, only projects with labels
+	projectSyncs  []*fileProjectSync
+	topics        map[string]*fileTopic        // keyed by full resource name
+	subscriptions map[string]*fileSubscription // keyed by full resource name
+	destinations  map[string]*fileDestination  // keyed by subscription full resource name
+	consumers     map[consumerKey]*fileConsumer
+	services      map[string]*fileCloudRunService // keyed by full resource name
+	functions     map[string]*fileCloudFunction   // keyed by full resource name
+	jobs          map[string]*fileDataflowJob     // keyed by full resource name
+	resources     map[string]*fileResource        // keyed by full resource name
+	edges         map[edgeKey]*fileEdge
+	metrics       map[metricKey]*fileMetric
+	points        map[pointKey]*MetricPoint
+	changes       []*Change
+	runs          []*ScanRun
+	nextID        map[string]int64 // keyed by table
+}
+
+type fileProjectSync struct {
+	id        int64
+	projectID string
+	syncedAt  time.Time
+}
+
+type fileTopic struct {
+	Topic
+	lastSynced time.Time
+	lastSeen   time.Time
+}
+
+type fileSubscription struct {
+	Subscription
+	lastSynced time.Time
+	lastSeen   time.Time
+}
+
+type fileDestination struct {
+	SubscriptionDestination
+	lastSynced time.Time
+}
+
+type fileCloudRunService struct {
+	CloudRunService
+	lastSynced time.Time
+}
+
+type fileCloudFunction struct {
+	CloudFunction
+	lastSynced time.Time
+}
+
+type fileDataflowJob struct {
+	DataflowJob
+	lastSynced time.Time
+}
+
+type fileResource struct {
+	Resource
+	lastSynced time.Time
+}
+
+type fileEdge struct {
+	ResourceEdge
+	lastSynced time.Time
+}
+
+// edgeKey is the unique key of edges
+type edgeKey struct {
+	source, target, relation string
+}
+
+type fileMetric struct {
+	ResourceMetric
+	lastSynced time.Time
+}
+
+// metricKey is the unique key of resource_metrics
+type metricKey struct {
+	fullResourceName, metric string
+}
+
+// pointKey is the unique key of metric_points, start in Unix milliseconds
+type pointKey struct {
+	fullResourceName, metric string
+	start                    int64
+}
+
+type fileConsumer struct {
+	SubscriptionConsumer
+	lastSeen time.Time
+}
+
+// consumerKey is the unique key of subscription_consumers
+type consumerKey struct {
+	subscription, principal, source string
+}
+
+func newFileState() *fileState {
+	return &fileState{
+		projects:      make(map[string]time.Time),
+		statuses:      make(map[string]string),
+		labels:        make(map[string]string),
+		topics:        make(map[string]*fileTopic),
+		subscriptions: make(map[string]*fileSubscription),
+		destinations:  make(map[string]*fileDestination),
+		consumers:     make(map[consumerKey]*fileConsumer),
+		services:      make(map[string]*fileCloudRunService),
+		functions:     make(map[string]*fileCloudFunction),
+		jobs:          make(map[string]*fileDataflowJob),
+		resources:     make(map[string]*fileResource),
+		edges:         make(map[edgeKey]*fileEdge),
+		metrics:       make(map[metricKey]*fileMetric),
+		points:        make(map[pointKey]*MetricPoint),
+		nextID:        make(map[string]int64),
+	}
+}
+
+// NewFile opens the JSON file cache at path, creating it on the first write.
+// An empty path keeps the cache in memory only.
+func NewFile(path string) (*FileStorage, error) {
+	s := &FileStorage{path: path, state: newFileState()}
+	if path == "" {
+		return s, nil
+	}
+
+	data, err := os.ReadFile(path)
+	switch {
+	case os.IsNotExist(err):
+		return s, nil
+	case err != nil:
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	state, err := decodeFileState(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load %s: %w", path, err)
+	}
+	s.state = state
+	return s, nil
+}
+
+// Close releases nothing, every write is already persisted
+func (s *FileStorage) Close() error {
+	return nil
+}
+
+// update applies fn to the state and persists the result, unless ctx is already
+// done. fn must validate its input before changing anything. If the write fails,
+// the state is reloaded from the last persisted copy.
+func (s *FileStorage) update(ctx context.Context, fn func(st *fileState) error) error {
+	// Within Transaction the lock is held, and the state persisted once it commits
+	if ctx.Value(fileTxKey{}) == s {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return fn(s.state)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := fn(s.state); err != nil {
+		return err
+	}
+	if err := s.persist(ctx); err != nil {
+		s.reload()
+		return err
+	}
+	return nil
+}
+
+// fileTxKey carries the FileStorage of Transaction to the writes made within it
+type fileTxKey struct{}
+
+// Transaction runs fn in a single transaction: the writes made with the context passed to fn
+// are persisted together if fn returns nil, and undone together otherwise. fn must only
+// write, a read waits for the transaction to finish. Within a transaction, Transaction joins it.
+func (s *FileStorage) Transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if ctx.Value(fileTxKey{}) == s {
+		return fn(ctx)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	var before bytes.Buffer
+	if err := encodeFileState(&before, s.state, time.Now()); err != nil {
+		return err
+	}
+	err := fn(context.WithValue(ctx, fileTxKey{}, s))
+	if err == nil {
+		err = s.persist(ctx)
+	}
+	if err != nil {
+		if state, derr := decodeFileState(&before); derr == nil {
+			s.state = state
+		}
+		return err
+	}
+	return nil
+}
+
+// reload restores the persisted state after a failed update
+func (s *FileStorage) reload() {
+	if s.path == "" {
+		return
+	}
+	if reloaded, err := NewFile(s.path); err == nil {
+		s.state = reloaded.state
+	}
+}
+
+// persist atomically replaces the cache file with the current state. Encoding a large cache takes
+// a while, so the file is left alone if ctx is done before or while the state is encoded.
+func (s *FileStorage) persist(ctx context.Context) error {
+	if s.path == "" {
+		return nil
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), "."+filepath.Base(s.path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", s.path, err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if err := encodeFileState(tmp, s.state, time.Now()); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", s.path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", s.path, err)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to write %s: %w", s.path, err)
+	}
+	return nil
+}
+
+func (st *fileState) newID(table string) int64 {
+	st.nextID[table]++
+	return st.nextID[table]
+}
+
+// fileNow returns the current time at the resolution SQLite's syncTimestamp stores
+func fileNow() time.Time {
+	return time.Now().UTC().Truncate(time.Millisecond)
+}
+
+// fileNowSeconds returns the current time at the resolution of SQLite's CURRENT_TIMESTAMP
+func fileNowSeconds() time.Time {
+	return time.Now().UTC().Truncate(time.Second)
+}
+
+// recordUpsert records a created or updated change if metadata differs from the stored one
+func (st *fileState) recordUpsert(ctx context.Context, resourceType, fullResourceName, projectID, before string, exists bool, metadata string) {
+	changeType := ChangeTypeUpdated
+	if !exists {
+		changeType = ChangeTypeCreated
+	} else if before == metadata {
+		return
+	}
+	st.changes = append(st.changes, &Change{
+		ID:               st.newID("changes"),
+		RunID:            RunIDFromContext(ctx),
+		ResourceType:     resourceType,
+		FullResourceName: fullResourceName,
+		ProjectID:        projectID,
+		ChangeType:       changeType,
+		BeforeMetadata:   before,
+		AfterMetadata:    metadata,
+		ChangedAt:        fileNow(),
+	})
+}
+
+// recordDelete records a deleted change for a resource about to be removed
+func (st *fileState) recordDelete(ctx context.Context, resourceType, fullResourceName, projectID, metadata string) {
+	st.changes = append(st.changes, &Change{
+		ID:               st.newID("changes"),
+		RunID:            RunIDFromContext(ctx),
+		ResourceType:     resourceType,
+		FullResourceName: fullResourceName,
+		ProjectID:        projectID,
+		ChangeType:       ChangeTypeDeleted,
+		BeforeMetadata:   metadata,
+		ChangedAt:        fileNow(),
+	})
+}
+
+// ensureProjects marks every project as synced now, like saving resources does in SQLite
+func (st *fileState) ensureProjects(projects []string) {
+	now := fileNowSeconds()
+	for _, project := range projects {
+		st.projects[project] = now
+	}
+}
+
+// SaveTopic inserts or updates a topic
+func (s *FileStorage) SaveTopic(ctx context.Context, topic *Topic) error {
+	return s.SaveTopics(ctx, []*Topic{topic})
+}
+
+// SaveTopics inserts or updates a batch of topics
+func (s *FileStorage) SaveTopics(ctx context.Context, topics []*Topic) error {
+	if len(topics) == 0 {
+		return nil
+	}
+	return s.update(ctx, func(st *fileState) error {
+		st.saveTopics(ctx, topics)
+		return nil
+	})
+}
+
+// AddTopic inserts a topic unless one with its full resource name is cached
+func (s *FileStorage) AddTopic(ctx context.Context, topic *Topic) error {
+	return s.update(ctx, func(st *fileState) error {
+		if _, ok := st.topics[topic.FullResourceName]; !ok {
+			st.saveTopics(ctx, []*Topic{topic})
+		}
+		return nil
+	})
+}
+
+// saveTopics inserts or updates topics in the state
+func (st *fileState) saveTopics(ctx context.Context, topics []*Topic) {
+	projects := make([]string, 0, len(topics))
+	for _, topic := range topics {
+		projects = append(projects, topic.ProjectID)
+	}
+	st.ensureProjects(projects)
+
+	now := fileNow()
+	for _, topic := range topics {
+		existing, ok := st.topics[topic.FullResourceName]
+		before := ""
+		if ok {
+			before = existing.Metadata
+		}
+		st.recordUpsert(ctx, ResourceTypeTopic, topic.FullResourceName, topic.ProjectID, before, ok, topic.Metadata)
+
+		stored := &fileTopic{Topic: *topic, lastSynced: now, lastSeen: now}
+		stored.ID = st.newID("topics")
+		// Stored the way the SQLite columns keep them
+		stored.MessageRetention = topic.MessageRetention.Truncate(time.Second)
+		stored.StorageRegions = splitList(joinList(topic.StorageRegions))
+		st.topics[topic.FullResourceName] = stored
+	}
+}
+
+// GetTopics retrieves all topics for a specific project
+func (s *FileStorage) GetTopics(ctx context.Context, projectID string) ([]*Topic, error) {
+	return s.GetAllTopics(ctx, []string{projectID})
+}
+
+// GetAllTopics retrieves topics for multiple projects, all of them if projects is empty
+func (s *FileStorage) GetAllTopics(ctx context.Context, projects []string) ([]*Topic, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	include := projectFilter(projects)
+	var topics []*Topic
+	for _, t := range s.state.topics {
+		if include(t.ProjectID) {
+			topic := t.Topic
+			topic.StorageRegions = append([]string(nil), t.StorageRegions...)
+			topics = append(topics, &topic)
+		}
+	}
+	sort.Slice(topics, func(i, j int) bool { return topics[i].ID < topics[j].ID })
+	return topics, nil
+}
+
+// QueryTopics retrieves the topics matching q
+func (s *FileStorage) QueryTopics(ctx context.Context, q TopicQuery) ([]*Topic, error) {
+	topics, err := s.GetAllTopics(ctx, q.Projects)
+	if err != nil {
+		return nil, err
+	}
+	matched := topics[:0]
+	for _, t := range topics {
+		switch {
+		case q.KMSKeyName != "" && t.KMSKeyName != q.KMSKeyName:
+		case q.WithoutKMSKey && t.KMSKeyName != "":
+		case q.MinRetention > 0 && t.MessageRetention < q.MinRetention:
+		case q.Region != "" && !slices.Contains(t.StorageRegions, q.Region):
+		default:
+			matched = append(matched, t)
+		}
+	}
+	return matched, nil
+}
+
+// ListTopics retrieves a sorted page of the topics matching q
+func (s *FileStorage) ListTopics(ctx context.Context, q TopicQuery, page Page) ([]*Topic, error) {
+	if err := page.Validate(); err != nil {
+		return nil, err
+	}
+	topics, err := s.QueryTopics(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	return pageOf(page, topics, func(t *Topic) (string, string, string) { return t.Name, t.ProjectID, t.FullResourceName }), nil
+}
+
+// DeleteTopic removes a topic by its full resource name
+func (s *FileStorage) DeleteTopic(ctx context.Context, fullResourceName string) error {
+	return s.update(ctx, func(st *fileState) error {
+		if t, ok := st.topics[fullResourceName]; ok {
+			st.recordDelete(ctx, ResourceTypeTopic, t.FullResourceName, t.ProjectID, t.Metadata)
+			delete(st.topics, fullResourceName)
+		}
+		return nil
+	})
+}
+
+// SaveSubscription inserts or updates a subscription
+func (s *FileStorage) SaveSubscription(ctx context.Context, sub *Subscription) error {
+	return s.SaveSubscriptions(ctx, []*Subscription{sub})
+}
+
+// SaveSubscriptions inserts or updates a batch of subscriptions
+func (s *FileStorage) SaveSubscriptions(ctx context.Context, subs []*Subscription) error {
+	if len(subs) == 0 {
+		return nil
+	}
+	return s.update(ctx, func(st *fileState) error {
+		st.saveSubscriptions(ctx, subs)
+		return nil
+	})
+}
+
+// AddSubscription inserts a subscription unless one with its full resource name is cached
+func (s *FileStorage) AddSubscription(ctx context.Context, sub *Subscription) error {
+	return s.update(ctx, func(st *fileState) error {
+		if _, ok := st.subscriptions[sub.FullResourceName]; !ok {
+			st.saveSubscriptions(ctx, []*Subscription{sub})
+		}
+		return nil
+	})
+}
+
+// saveSubscriptions inserts or updates subscriptions in the state
+func (st *fileState) saveSubscriptions(ctx context.Context, subs []*Subscription) {
+	projects := make([]string, 0, len(subs))
+	for _, sub := range subs {
+		projects = append(projects, sub.ProjectID)
+	}
+	st.ensureProjects(projects)
+
+	now := fileNow()
+	for _, sub := range subs {
+		existing, ok := st.subscriptions[sub.FullResourceName]
+		before := ""
+		if ok {
+			before = existing.Metadata
+		}
+		st.recordUpsert(ctx, ResourceTypeSubscription, sub.FullResourceName, sub.ProjectID, before, ok, sub.Metadata)
+
+		stored := &fileSubscription{Subscription: *sub, lastSynced: now, lastSeen: now}
+		stored.ID = st.newID("subscriptions")
+		st.subscriptions[sub.FullResourceName] = stored
+	}
+}
+
+// GetSubscriptions retrieves all subscriptions for a specific project
+func (s *FileStorage) GetSubscriptions(ctx context.Context, projectID string) ([]*Subscription, error) {
+	return s.GetAllSubscriptions(ctx, []string{projectID})
+}
+
+// GetAllSubscriptions retrieves subscriptions for multiple projects, all of them if projects is empty
+func (s *FileStorage) GetAllSubscriptions(ctx context.Context, projects []string) ([]*Subscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	include := projectFilter(projects)
+	var subs []*Subscription
+	for _, stored := range s.state.subscriptions {
+		if include(stored.ProjectID) {
+			sub := stored.Subscription
+			subs = append(subs, &sub)
+		}
+	}
+	sort.Slice(subs, func(i, j int) bool { return subs[i].ID < subs[j].ID })
+	return subs, nil
+}
+
+// ListSubscriptions retrieves a sorted page of the subscriptions of the given projects, all of them if projects is empty
+func (s *FileStorage) ListSubscriptions(ctx context.Context, projects []string, page Page) ([]*Subscription, error) {
+	if err := page.Validate(); err != nil {
+		return nil, err
+	}
+	subs, err := s.GetAllSubscriptions(ctx, projects)
+	if err != nil {
+		return nil, err
+	}
+	return pageOf(page, subs, func(sub *Subscription) (string, string, string) { return sub.Name, sub.ProjectID, sub.FullResourceName }), nil
+}
+
+// DeleteSubscription removes a subscription, its destination and consumers by full resource name
+func (s *FileStorage) DeleteSubscription(ctx context.Context, fullResourceName string) error {
+	return s.update(ctx, func(st *fileState) error {
+		st.deleteSubscription(ctx, fullResourceName)
+		return nil
+	})
+}
+
+func (st *fileState) deleteSubscription(ctx context.Context, fullResourceName string) {
+	if sub, ok := st.subscriptions[fullResourceName]; ok {
+		st.recordDelete(ctx, ResourceTypeSubscription, sub.FullResourceName, sub.ProjectID, sub.Metadata)
+		delete(st.subscriptions, fullResourceName)
+	}
+	delete(st.destinations, fullResourceName)
+	st.deleteConsumers(fullResourceName, "")
+}
+
+// deleteConsumers removes the consumers of a subscription, only from source unless it is empty
+func (st *fileState) deleteConsumers(subscription, source string) {
+	for key := range st.consumers {
+		if key.subscription == subscription && (source == "" || key.source == source) {
+			delete(st.consumers, key)
+		}
+	}
+}
+
+// TouchTopics records that a scan saw the given topics unchanged, without rewriting them
+func (s *FileStorage) TouchTopics(ctx context.Context, fullResourceNames []string) error {
+	if len(fullResourceNames) == 0 {
+		return nil
+	}
+	return s.update(ctx, func(st *fileState) error {
+		now := fileNow()
+		for _, name := range fullResourceNames {
+			if t, ok := st.topics[name]; ok {
+				t.lastSeen = now
+			}
+		}
+		return nil
+	})
+}
+
+// TouchSubscriptions records that a scan saw the given subscriptions unchanged, without rewriting them
+func (s *FileStorage) TouchSubscriptions(ctx context.Context, fullResourceNames []string) error {
+	if len(fullResourceNames) == 0 {
+		return nil
+	}
+	return s.update(ctx, func(st *fileState) error {
+		now := fileNow()
+		for _, name := range fullResourceNames {
+			if sub, ok := st.subscriptions[name]; ok {
+				sub.lastSeen = now
+			}
+		}
+		return nil
+	})
+}
+
+// DeleteStaleResources removes the rows of a project selected by stale that were last synced
+// (for topics and subscriptions, last seen) before the given time. Removed subscriptions take
+// their destinations and consumers with them. It returns the number of topics and subscriptions removed.
+func (s *FileStorage) DeleteStaleResources(ctx context.Context, projectID string, before time.Time, stale []Stale) (int64, error) {
+	if err := checkStale(stale); err != nil {
+		return 0, err
+	}
+	cutoff := before.UTC().Truncate(time.Millisecond)
+
+	var removed int64
+	err := s.update(ctx, func(st *fileState) error {
+		removed = 0
+		for _, sel := range stale {
+			isStale := func(project, kind string, lastSynced time.Time) bool {
+				return project == projectID && (sel.Kind == "" || kind == sel.Kind) && lastSynced.Before(cutoff)
+			}
+			switch sel.Table {
+			case TableDestinations:
+				deleteStale(st.destinations, func(d *fileDestination) bool { return isStale(d.ProjectID, d.Type, d.lastSynced) })
+			case TableCloudRunServices:
+				deleteStale(st.services, func(svc *fileCloudRunService) bool { return isStale(svc.ProjectID, "", svc.lastSynced) })
+			case TableCloudFunctions:
+				deleteStale(st.functions, func(fn *fileCloudFunction) bool { return isStale(fn.ProjectID, "", fn.lastSynced) })
+			case TableDataflowJobs:
+				deleteStale(st.jobs, func(job *fileDataflowJob) bool { return isStale(job.ProjectID, "", job.lastSynced) })
+			case TableResources:
+				deleteStale(st.resources, func(r *fileResource) bool { return isStale(r.ProjectID, r.Kind, r.lastSynced) })
+			case TableEdges:
+				deleteStale(st.edges, func(e *fileEdge) bool { return isStale(e.ProjectID, e.Relation, e.lastSynced) })
+			case TableResourceMetrics:
+				deleteStale(st.metrics, func(m *fileMetric) bool { return isStale(m.ProjectID, m.Metric, m.lastSynced) })
+			}
+		}
+		if slices.Contains(stale, Stale{Table: TableSubscriptions}) {
+			for _, sub := range sortedByID(st.subscriptions, func(s *fileSubscription) int64 { return s.ID }) {
+				if sub.ProjectID == projectID && sub.lastSeen.Before(cutoff) {
+					st.deleteSubscription(ctx, sub.FullResourceName)
+					removed++
+				}
+			}
+		}
+		if slices.Contains(stale, Stale{Table: TableTopics}) {
+			for _, t := range sortedByID(st.topics, func(t *fileTopic) int64 { return t.ID }) {
+				if t.ProjectID == projectID && t.lastSeen.Before(cutoff) {
+					st.recordDelete(ctx, ResourceTypeTopic, t.FullResourceName, t.ProjectID, t.Metadata)
+					delete(st.topics, t.FullResourceName)
+					removed++
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return removed, nil
+}
+
+// deleteStale removes the rows of m that isStale selects
+func deleteStale[K comparable, V any](m map[K]V, isStale func(V) bool) {
+	for key, row := range m {
+		if isStale(row) {
+			delete(m, key)
+		}
+	}
+}
+
+// SaveSubscriptionDestination inserts or updates the destination of a subscription
+func (s *FileStorage) SaveSubscriptionDestination(ctx context.Context, dest *SubscriptionDestination) error {
+	return s.update(ctx, func(st *fileState) error {
+		stored := &fileDestination{SubscriptionDestination: *dest, lastSynced: fileNow()}
+		stored.ID = st.newID("subscription_destinations")
+		st.destinations[dest.SubscriptionFullResourceName] = stored
+		return nil
+	})
+}
+
+// GetAllSubscriptionDestinations retrieves subscription destinations for multiple projects
+func (s *FileStorage) GetAllSubscriptionDestinations(ctx context.Context, projects []string) ([]*SubscriptionDestination, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	include := projectFilter(projects)
+	var destinations []*SubscriptionDestination
+	for _, stored := range s.state.destinations {
+		if include(stored.ProjectID) {
+			dest := stored.SubscriptionDestination
+			destinations = append(destinations, &dest)
+		}
+	}
+	sort.Slice(destinations, func(i, j int) bool { return destinations[i].ID < destinations[j].ID })
+	return destinations, nil
+}
+
+// SaveCloudRunServices inserts or updates a batch of Cloud Run services
+func (s *FileStorage) SaveCloudRunServices(ctx context.Context, services []*CloudRunService) error {
+	if len(services) == 0 {
+		return nil
+	}
+	return s.update(ctx, func(st *fileState) error {
+		now := fileNow()
+		for _, svc := range services {
+			stored := &fileCloudRunService{CloudRunService: *svc, lastSynced: now}
+			stored.ID = st.newID("cloud_run_services")
+			stored.URLs = splitList(joinList(svc.URLs))
+			st.services[svc.FullResourceName] = stored
+		}
+		return nil
+	})
+}
+
+// GetAllCloudRunServices retrieves Cloud Run services for multiple projects
+func (s *FileStorage) GetAllCloudRunServices(ctx context.Context, projects []string) ([]*CloudRunService, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	include := projectFilter(projects)
+	var services []*CloudRunService
+	for _, stored := range sortedByID(s.state.services, func(svc *fileCloudRunService) int64 { return svc.ID }) {
+		if include(stored.ProjectID) {
+			svc := stored.CloudRunService
+			services = append(services, &svc)
+		}
+	}
+	return services, nil
+}
+
+// SaveCloudFunctions inserts or updates a batch of Cloud Functions
+func (s *FileStorage) SaveCloudFunctions(ctx context.Context, functions []*CloudFunction) error {
+	if len(functions) == 0 {
+		return nil
+	}
+	return s.update(ctx, func(st *fileState) error {
+		now := fileNow()
+		for _, fn := range functions {
+			stored := &fileCloudFunction{CloudFunction: *fn, lastSynced: now}
+			stored.ID = st.newID("cloud_functions")
+			st.functions[fn.FullResourceName] = stored
+		}
+		return nil
+	})
+}
+
+// GetAllCloudFunctions retrieves Cloud Functions for multiple projects
+func (s *FileStorage) GetAllCloudFunctions(ctx context.Context, projects []string) ([]*CloudFunction, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	include := projectFilter(projects)
+	var functions []*CloudFunction
+	for _, stored := range sortedByID(s.state.functions, func(fn *fileCloudFunction) int64 { return fn.ID }) {
+		if include(stored.ProjectID) {
+			fn := stored.CloudFunction
+			functions = append(functions, &fn)
+		}
+	}
+	return functions, nil
+}
+
+// SaveDataflowJobs inserts or updates a batch of Dataflow jobs
+func (s *FileStorage) SaveDataflowJobs(ctx context.Context, jobs []*DataflowJob) error {
+	if len(jobs) == 0 {
+		return nil
+	}
+	return s.update(ctx, func(st *fileState) error {
+		now := fileNow()
+		for _, job := range jobs {
+			stored := &fileDataflowJob{DataflowJob: *job, lastSynced: now}
+			stored.ID = st.newID("dataflow_jobs")
+			stored.Sources = splitList(joinList(job.Sources))
+			stored.Sinks = splitList(joinList(job.Sinks))
+			st.jobs[job.FullResourceName] = stored
+		}
+		return nil
+	})
+}
+
+// GetAllDataflowJobs retrieves Dataflow jobs for multiple projects
+func (s *FileStorage) GetAllDataflowJobs(ctx context.Context, projects []string) ([]*DataflowJob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	include := projectFilter(projects)
+	var jobs []*DataflowJob
+	for _, stored := range sortedByID(s.state.jobs, func(job *fileDataflowJob) int64 { return job.ID }) {
+		if include(stored.ProjectID) {
+			job := stored.DataflowJob
+			jobs = append(jobs, &job)
+		}
+	}
+	return jobs, nil
+}
+
+// SaveResources inserts or updates a batch of generic resources
+func (s *FileStorage) SaveResources(ctx context.Context, resources []*Resource) error {
+	if len(resources) == 0 {
+		return nil
+	}
+	if err := checkGenericKinds(resources); err != nil {
+		return err
+	}
+	return s.update(ctx, func(st *fileState) error {
+		now := fileNow()
+		for _, r := range resources {
+			stored := &fileResource{Resource: *r, lastSynced: now}
+			stored.ID = st.newID("resources")
+			st.resources[r.FullResourceName] = stored
+		}
+		return nil
+	})
+}
+
+// GetResources retrieves the resources of a kind, or of every kind if kind is empty,
+// for multiple projects, including the topics and subscriptions of the typed maps
+func (s *FileStorage) GetResources(ctx context.Context, kind string, projects []string) ([]*Resource, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	include := projectFilter(projects)
+	var resources []*Resource
+	add := func(r Resource) {
+		if (kind == "" || r.Kind == kind) && include(r.ProjectID) {
+			resources = append(resources, &r)
+		}
+	}
+	for _, stored := range s.state.resources {
+		add(stored.Resource)
+	}
+	for _, t := range s.state.topics {
+		add(Resource{ID: t.ID, Kind: ResourceKindTopic, Name: t.Name, ProjectID: t.ProjectID,
+			FullResourceName: t.FullResourceName, Metadata: t.Metadata})
+	}
+	for _, sub := range s.state.subscriptions {
+		add(Resource{ID: sub.ID, Kind: ResourceKindSubscription, Name: sub.Name, ProjectID: sub.ProjectID,
+			FullResourceName: sub.FullResourceName, Metadata: sub.Metadata})
+	}
+	sort.Slice(resources, func(i, j int) bool {
+		if resources[i].Kind != resources[j].Kind {
+			return resources[i].Kind < resources[j].Kind
+		}
+		return resources[i].FullResourceName < resources[j].FullResourceName
+	})
+	return resources, nil
+}
+
+// SaveEdges inserts or refreshes a batch of edges between generic resources
+func (s *FileStorage) SaveEdges(ctx context.Context, edges []*ResourceEdge) error {
+	if len(edges) == 0 {
+		return nil
+	}
+	return s.update(ctx, func(st *fileState) error {
+		now := fileNow()
+		for _, e := range edges {
+			stored := &fileEdge{ResourceEdge: *e, lastSynced: now}
+			stored.ID = st.newID("edges")
+			st.edges[edgeKey{e.Source, e.Target, e.Relation}] = stored
+		}
+		return nil
+	})
+}
+
+// GetEdges retrieves the edges seen by scans of multiple projects, including
+// an edge from each subscription of the typed map to its topic
+func (s *FileStorage) GetEdges(ctx context.Context, projects []string) ([]*ResourceEdge, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	include := projectFilter(projects)
+	var edges []*ResourceEdge
+	for _, stored := range s.state.edges {
+		if include(stored.ProjectID) {
+			e := stored.ResourceEdge
+			edges = append(edges, &e)
+		}
+	}
+	for _, sub := range s.state.subscriptions {
+		if include(sub.ProjectID) && isTopicName(sub.TopicFullResourceName) {
+			edges = append(edges, &ResourceEdge{ID: sub.ID, Source: sub.FullResourceName,
+				Target: sub.TopicFullResourceName, Relation: RelationSubscribes, ProjectID: sub.ProjectID})
+		}
+	}
+	sort.Slice(edges, func(i, j int) bool {
+		a, b := edges[i], edges[j]
+		if a.Source != b.Source {
+			return a.Source < b.Source
+		}
+		if a.Target != b.Target {
+			return a.Target < b.Target
+		}
+		return a.Relation < b.Relation
+	})
+	return edges, nil
+}
+
+// SaveMetrics inserts or refreshes a batch of metrics
+func (s *FileStorage) SaveMetrics(ctx context.Context, metrics []*ResourceMetric) error {
+	if len(metrics) == 0 {
+		return nil
+	}
+	return s.update(ctx, func(st *fileState) error {
+		now := fileNow()
+		for _, m := range metrics {
+			stored := &fileMetric{ResourceMetric: *m, lastSynced: now}
+			stored.ID = st.newID("resource_metrics")
+			// Stored the way the SQLite column keeps it
+			stored.Window = m.Window.Truncate(time.Second)
+			st.metrics[metricKey{m.FullResourceName, m.Metric}] = stored
+		}
+		return nil
+	})
+}
+
+// GetMetrics retrieves the metrics of multiple projects, all of them if projects is empty
+func (s *FileStorage) GetMetrics(ctx context.Context, projects []string) ([]*ResourceMetric, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	include := projectFilter(projects)
+	var metrics []*ResourceMetric
+	for _, stored := range s.state.metrics {
+		if include(stored.ProjectID) {
+			m := stored.ResourceMetric
+			metrics = append(metrics, &m)
+		}
+	}
+	sort.Slice(metrics, func(i, j int) bool {
+		a, b := metrics[i], metrics[j]
+		if a.FullResourceName != b.FullResourceName {
+			return a.FullResourceName < b.FullResourceName
+		}
+		return a.Metric < b.Metric
+	})
+	return metrics, nil
+}
+
+// SaveMetricPoints inserts or replaces a batch of datapoints
+func (s *FileStorage) SaveMetricPoints(ctx context.Context, points []*MetricPoint) error {
+	if len(points) == 0 {
+		return nil
+	}
+	return s.update(ctx, func(st *fileState) error {
+		for _, p := range points {
+			stored := *p
+			stored.ID = st.newID("metric_points")
+			// Stored the way the SQLite column keeps it
+			stored.Start = p.Start.UTC().Truncate(time.Millisecond)
+			st.points[pointKey{p.FullResourceName, p.Metric, stored.Start.UnixMilli()}] = &stored
+		}
+		return nil
+	})
+}
+
+// GetMetricPoints retrieves the datapoints of a project starting at or after since, oldest first
+func (s *FileStorage) GetMetricPoints(ctx context.Context, projectID string, since time.Time) ([]*MetricPoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var points []*MetricPoint
+	for _, stored := range s.state.points {
+		if stored.ProjectID == projectID && !stored.Start.Before(since) {
+			p := *stored
+			points = append(points, &p)
+		}
+	}
+	sort.Slice(points, func(i, j int) bool {
+		a, b := points[i], points[j]
+		if !a.Start.Equal(b.Start) {
+			return a.Start.Before(b.Start)
+		}
+		if a.FullResourceName != b.FullResourceName {
+			return a.FullResourceName < b.FullResourceName
+		}
+		return a.Metric < b.Metric
+	})
+	return points, nil
+}
+
+// DeleteMetricPoints removes the datapoints of a project that start before before
+func (s *FileStorage) DeleteMetricPoints(ctx context.Context, projectID string, before time.Time) error {
+	return s.update(ctx, func(st *fileState) error {
+		for key, p := range st.points {
+			if p.ProjectID == projectID && p.Start.Before(before) {
+				delete(st.points, key)
+			}
+		}
+		return nil
+	})
+}
+
+// isTopicName reports whether name is a topic's full resource name, matching the
+// all_edges view of the SQLite backend; detached subscriptions have "_deleted-topic_"
+func isTopicName(name string) bool {
+	rest, ok := strings.CutPrefix(name, "projects/")
+	return ok && strings.Contains(rest, "/topics/")
+}
+
+// SaveSubscriptionConsumer inserts or refreshes a single consumer
+func (s *FileStorage) SaveSubscriptionConsumer(ctx context.Context, consumer *SubscriptionConsumer) error {
+	return s.update(ctx, func(st *fileState) error {
+		st.saveConsumer(consumer)
+		return nil
+	})
+}
+
+func (st *fileState) saveConsumer(consumer *SubscriptionConsumer) {
+	stored := &fileConsumer{SubscriptionConsumer: *consumer, lastSeen: fileNowSeconds()}
+	stored.ID = st.newID("subscription_consumers")
+	st.consumers[consumerKey{consumer.SubscriptionFullResourceName, consumer.Principal, consumer.Source}] = stored
+}
+
+// ReplaceSubscriptionConsumers replaces every consumer of a subscription from the given source
+func (s *FileStorage) ReplaceSubscriptionConsumers(ctx context.Context, subscriptionFullResourceName, source string, consumers []*SubscriptionConsumer) error {
+	return s.update(ctx, func(st *fileState) error {
+		st.deleteConsumers(subscriptionFullResourceName, source)
+		for _, consumer := range consumers {
+			c := *consumer
+			c.SubscriptionFullResourceName = subscriptionFullResourceName
+			c.Source = source
+			st.saveConsumer(&c)
+		}
+		return nil
+	})
+}
+
+// GetAllSubscriptionConsumers retrieves subscription consumers for multiple projects
+func (s *FileStorage) GetAllSubscriptionConsumers(ctx context.Context, projects []string) ([]*SubscriptionConsumer, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	include := projectFilter(projects)
+	var consumers []*SubscriptionConsumer
+	for _, stored := range s.state.consumers {
+		if include(stored.ProjectID) {
+			consumer := stored.SubscriptionConsumer
+			consumers = append(consumers, &consumer)
+		}
+	}
+	sort.Slice(consumers, func(i, j int) bool { return consumers[i].ID < consumers[j].ID })
+	return consumers, nil
+}
+
+// GetChanges returns the changelog entries recorded at or after since, oldest first.
+// An empty projects slice includes every project.
+func (s *FileStorage) GetChanges(ctx context.Context, since time.Time, projects []string) ([]*Change, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	since = since.UTC().Truncate(time.Millisecond)
+	include := projectFilter(projects)
+	var changes []*Change
+	for _, c := range s.state.changes {
+		if !c.ChangedAt.Before(since) && include(c.ProjectID) {
+			change := *c
+			changes = append(changes, &change)
+		}
+	}
+	return changes, nil
+}
+
+// SaveScanRun records a finished scan
+func (s *FileStorage) SaveScanRun(ctx context.Context, run *ScanRun) error {
+	return s.update(ctx, func(st *fileState) error {
+		for _, stored := range st.runs {
+			if stored.RunID == run.RunID {
+				return fmt.Errorf("scan run %s already exists", run.RunID)
+			}
+		}
+		stored := *run
+		stored.ID = st.newID("scan_runs")
+		// Stored the way the SQLite columns keep them
+		stored.StartedAt = run.StartedAt.UTC().Truncate(time.Millisecond)
+		stored.FinishedAt = run.FinishedAt.UTC().Truncate(time.Millisecond)
+		st.runs = append(st.runs, &stored)
+		return nil
+	})
+}
+
+// GetScanRuns returns the latest scans, newest first. A limit below 1 returns every scan.
+func (s *FileStorage) GetScanRuns(ctx context.Context, limit int) ([]*ScanRun, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	runs := make([]*ScanRun, 0, len(s.state.runs))
+	for _, stored := range s.state.runs {
+		run := *stored
+		runs = append(runs, &run)
+	}
+	sort.Slice(runs, func(i, j int) bool {
+		if !runs[i].StartedAt.Equal(runs[j].StartedAt) {
+			return runs[i].StartedAt.After(runs[j].StartedAt)
+		}
+		return runs[i].ID > runs[j].ID
+	})
+	if limit > 0 && len(runs) > limit {
+		runs = runs[:limit]
+	}
+	return runs, nil
+}
+
+// GetScanRun returns a single scan, or ErrScanRunNotFound
+func (s *FileStorage) GetScanRun(ctx context.Context, runID string) (*ScanRun, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, stored := range s.state.runs {
+		if stored.RunID == runID {
+			run := *stored
+			return &run, nil
+		}
+	}
+	return nil, ErrScanRunNotFound
+}
+
+// GetAllProjects returns all project IDs, sorted
+func (s *FileStorage) GetAllProjects(ctx context.Context) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var projects []string
+	for id := range s.state.projects {
+		projects = append(projects, id)
+	}
+	sort.Strings(projects)
+	return projects, nil
+}
+
+// GetProjectSyncTimes returns the last sync time of every cached project
+func (s *FileStorage) GetProjectSyncTimes(ctx context.Context) (map[string]time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	syncTimes := make(map[string]time.Time, len(s.state.projects))
+	for id, lastSynced := range s.state.projects {
+		syncTimes[id] = lastSynced
+	}
+	return syncTimes, nil
+}
+
+// UpdateProjectSyncTime updates or inserts the last sync time for a project,
+// and records the completed sync in the project's sync history
+func (s *FileStorage) UpdateProjectSyncTime(ctx context.Context, projectID string) error {
+	return s.update(ctx, func(st *fileState) error {
+		now := fileNowSeconds()
+		st.projects[projectID] = now
+		delete(st.statuses, projectID)
+		st.projectSyncs = append(st.projectSyncs, &fileProjectSync{id: st.newID("project_syncs"), projectID: projectID, syncedAt: now})
+		return nil
+	})
+}
+
+// SetProjectStatus records why a project couldn't be collected, e.g. ProjectStatusAPIDisabled.
+// The sync time of a known project is kept, a new one is added as synced now.
+func (s *FileStorage) SetProjectStatus(ctx context.Context, projectID, status string) error {
+	return s.update(ctx, func(st *fileState) error {
+		if _, ok := st.projects[projectID]; !ok {
+			st.projects[projectID] = fileNowSeconds()
+		}
+		if status == "" {
+			delete(st.statuses, projectID)
+		} else {
+			st.statuses[projectID] = status
+		}
+		return nil
+	})
+}
+
+// GetProjectStatuses returns the status of every project that has one
+func (s *FileStorage) GetProjectStatuses(ctx context.Context) (map[string]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	statuses := make(map[string]string, len(s.state.statuses))
+	for id, status := range s.state.statuses {
+		statuses[id] = status
+	}
+	return statuses, nil
+}
+
+// SetProjectLabels replaces the labels of a project, as collected from the Resource Manager API.
+// The sync time of a known project is kept, a new one is added as synced now.
+func (s *FileStorage) SetProjectLabels(ctx context.Context, projectID string, labels map[string]string) error {
+	encoded := ""
+	if len(labels) > 0 {
+		data, err := json.Marshal(labels)
+		if err != nil {
+			return err
+		}
+		encoded = string(data)
+	}
+	return s.update(ctx, func(st *fileState) error {
+		if _, ok := st.projects[projectID]; !ok {
+			st.projects[projectID] = fileNowSeconds()
+		}
+		if encoded == "" {
+			delete(st.labels, projectID)
+		} else {
+			st.labels[projectID] = encoded
+		}
+		return nil
+	})
+}
+
+// GetProjectLabels returns the labels of every project that has any
+func (s *FileStorage) GetProjectLabels(ctx context.Context) (map[string]map[string]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	labels := make(map[string]map[string]string, len(s.state.labels))
+	for id, encoded := range s.state.labels {
+		var projectLabels map[string]string
+		if err := json.Unmarshal([]byte(encoded), &projectLabels); err != nil {
+			return nil, fmt.Errorf("invalid labels of project %s: %w", id, err)
+		}
+		labels[id] = projectLabels
+	}
+	return labels, nil
+}
+
+// GetProjectSyncHistory returns the completed syncs of every project at or after since, oldest first
+func (s *FileStorage) GetProjectSyncHistory(ctx context.Context, since time.Time) (map[string][]time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	since = since.UTC().Truncate(time.Second)
+	syncs := append([]*fileProjectSync(nil), s.state.projectSyncs...)
+	sort.SliceStable(syncs, func(i, j int) bool { return syncs[i].syncedAt.Before(syncs[j].syncedAt) })
+
+	history := make(map[string][]time.Time)
+	for _, sync := range syncs {
+		if !sync.syncedAt.Before(since) {
+			history[sync.projectID] = append(history[sync.projectID], sync.syncedAt)
+		}
+	}
+	return history, nil
+}
+
+// fileDialect is the Dialect of FileStorage, which has no SQL schema to migrate
+type fileDialect struct{}
+
+func (fileDialect) Name() string           { return "file" }
+func (fileDialect) Placeholder(int) string { return "" }
+
+// Dialect returns the dialect of the file backend
+func (s *FileStorage) Dialect() Dialect {
+	return fileDialect{}
+}
+
+// Migrations returns no migrations, the file's layout follows the Dump format of
+// the SQLite schema version it was written with
+func (s *FileStorage) Migrations() []Migration {
+	return nil
+}
+
+// Export writes the whole cache to w as a JSON Dump
+func (s *FileStorage) Export(ctx context.Context, w io.Writer) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return encodeFileState(w, s.state, time.Now())
+}
+
+// Import replaces the contents of every table in the dump read from r.
+// Tables missing from the dump are left alone.
+func (s *FileStorage) Import(ctx context.Context, r io.Reader) error {
+	dump, err := readDump(r)
+	if err != nil {
+		return err
+	}
+	return s.update(ctx, func(st *fileState) error {
+		return st.apply(dump)
+	})
+}
+
+// projectFilter returns whether a project is in projects, every project matches an empty slice
+func projectFilter(projects []string) func(string) bool {
+	if len(projects) == 0 {
+		return func(string) bool { return true }
+	}
+	set := make(map[string]bool, len(projects))
+	for _, p := range projects {
+		set[p] = true
+	}
+	return func(p string) bool { return set[p] }
+}
+
+// sortedByID returns the values of m ordered by ID
+func sortedByID[K comparable, T any](m map[K]T, id func(T) int64) []T {
+	values := make([]T, 0, len(m))
+	for _, v := range m {
+		values = append(values, v)
+	}
+	sort.Slice(values, func(i, j int) bool { return id(values[i]) < id(values[j]) })
+	return values
+}
+
+// fileSchemaVersion is the SQLite schema version whose table layout the file backend writes
+func fileSchemaVersion() int {
+	return sqliteMigrations[len(sqliteMigrations)-1].Version
+}
+
+// readDump decodes and checks a Dump
+func readDump(r io.Reader) (*Dump, error) {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	var dump Dump
+	if err := dec.Decode(&dump); err != nil {
+		return nil, fmt.Errorf("failed to read dump: %w", err)
+	}
+	if dump.Format != DumpFormat {
+		return nil, fmt.Errorf("not a gcp-visualizer dump (format %q)", dump.Format)
+	}
+	if dump.SchemaVersion > fileSchemaVersion() {
+		return nil, fmt.Errorf("dump has schema version %d, newer than this version's %d", dump.SchemaVersion, fileSchemaVersion())
+	}
+	return &dump, nil
+}
+
+// decodeFileState loads a cache file
+func decodeFileState(r io.Reader) (*fileState, error) {
+	dump, err := readDump(r)
+	if err != nil {
+		return nil, err
+	}
+	st := newFileState()
+	if err := st.apply(dump); err != nil {
+		return nil, err
+	}
+	return st, nil
+}
+
+// fileTables are the tables and columns of the Dump written by FileStorage,
+// the same as those of the SQLite schema
+var fileTables = map[string][]string{
+	"projects":                  {"project_id", "last_synced", "status", "labels"},
+	"project_syncs":             {"id", "project_id", "synced_at"},
+	"topics":                    {"id", "name", "project_id", "full_resource_name", "metadata", "message_retention_seconds", "kms_key_name", "storage_regions", "last_synced", "last_seen"},
+	"subscriptions":             {"id", "name", "project_id", "topic_full_resource_name", "full_resource_name", "filter", "metadata", "last_synced", "last_seen"},
+	"subscription_destinations": {"id", "subscription_full_resource_name", "project_id", "destination_type", "resource", "metadata", "last_synced"},
+	"subscription_consumers":    {"id", "subscription_full_resource_name", "project_id", "principal", "source", "role", "last_seen"},
+	"cloud_run_services":        {"id", "name", "project_id", "region", "full_resource_name", "urls", "metadata", "last_synced"},
+	"cloud_functions":           {"id", "name", "project_id", "region", "full_resource_name", "generation", "trigger_topic", "event_type", "metadata", "last_synced"},
+	"dataflow_jobs":             {"id", "name", "project_id", "region", "full_resource_name", "sources", "sinks", "metadata", "last_synced"},
+	"resources":                 {"id", "kind", "name", "project_id", "full_resource_name", "metadata", "last_synced"},
+	"edges":                     {"id", "source", "target", "relation", "project_id", "last_synced"},
+	"resource_metrics":          {"id", "full_resource_name", "project_id", "metric", "value", "window_seconds", "last_synced"},
+	"metric_points":             {"id", "full_resource_name", "project_id", "metric", "start_time", "value"},
+	"changes":                   {"id", "run_id", "resource_type", "full_resource_name", "project_id", "change_type", "before_metadata", "after_metadata", "changed_at"},
+	"scan_runs": {"id", "run_id", "started_at", "finished_at", "projects_attempted", "projects_succeeded",
+		"projects_failed", "projects_skipped", "projects_interrupted", "topics", "subscriptions", "api_calls", "version"},
+}
+
+// encodeFileState writes st to w as a JSON Dump, timestamps are formatted the way SQLite stores them
+func encodeFileState(w io.Writer, st *fileState, exportedAt time.Time) error {
+	dump := &Dump{
+		Format:        DumpFormat,
+		SchemaVersion: fileSchemaVersion(),
+		ExportedAt:    exportedAt.UTC(),
+		Tables:        make(map[string][]map[string]any, len(fileTables)),
+	}
+	for name := range fileTables {
+		dump.Tables[name] = make([]map[string]any, 0)
+	}
+
+	projects := make([]string, 0, len(st.projects))
+	for id := range st.projects {
+		projects = append(projects, id)
+	}
+	sort.Strings(projects)
+	for _, id := range projects {
+		dump.Tables["projects"] = append(dump.Tables["projects"], map[string]any{
+			"project_id":  id,
+			"last_synced": st.projects[id].Format(time.DateTime),
+			"status":      st.statuses[id],
+			"labels":      st.labels[id],
+		})
+	}
+	for _, sync := range st.projectSyncs {
+		dump.Tables["project_syncs"] = append(dump.Tables["project_syncs"], map[string]any{
+			"id":         sync.id,
+			"project_id": sync.projectID,
+			"synced_at":  sync.syncedAt.Format(time.DateTime),
+		})
+	}
+	for _, t := range sortedByID(st.topics, func(t *fileTopic) int64 { return t.ID }) {
+		dump.Tables["topics"] = append(dump.Tables["topics"], map[string]any{
+			"id":                        t.ID,
+			"name":                      t.Name,
+			"project_id":                t.ProjectID,
+			"full_resource_name":        t.FullResourceName,
+			"metadata":                  t.Metadata,
+			"message_retention_seconds": int64(t.MessageRetention / time.Second),
+			"kms_key_name":              t.KMSKeyName,
+			"storage_regions":           joinList(t.StorageRegions),
+			"last_synced":               t.lastSynced.Format(syncTimestampLayout),
+			"last_seen":                 t.lastSeen.Format(syncTimestampLayout),
+		})
+	}
+	for _, sub := range sortedByID(st.subscriptions, func(s *fileSubscription) int64 { return s.ID }) {
+		dump.Tables["subscriptions"] = append(dump.Tables["subscriptions"], map[string]any{
+			"id":                       sub.ID,
+			"name":                     sub.Name,
+			"project_id":               sub.ProjectID,
+			"topic_full_resource_name": sub.TopicFullResourceName,
+			"full_resource_name":       sub.FullResourceName,
+			"filter":                   sub.Filter,
+			"metadata":                 sub.Metadata,
+			"last_synced":              sub.lastSynced.Format(syncTimestampLayout),
+			"last_seen":                sub.lastSeen.Format(syncTimestampLayout),
+		})
+	}
+	for _, dest := range sortedByID(st.destinations, func(d *fileDestination) int64 { return d.ID }) {
+		dump.Tables["subscription_destinations"] = append(dump.Tables["subscription_destinations"], map[string]any{
+			"id":                              dest.ID,
+			"subscription_full_resource_name": dest.SubscriptionFullResourceName,
+			"project_id":                      dest.ProjectID,
+			"destination_type":                dest.Type,
+			"resource":                        dest.Resource,
+			"metadata":                        dest.Metadata,
+			"last_synced":                     dest.lastSynced.Format(syncTimestampLayout),
+		})
+	}
+	for _, c := range sortedByID(st.consumers, func(c *fileConsumer) int64 { return c.ID }) {
+		dump.Tables["subscription_consumers"] = append(dump.Tables["subscription_consumers"], map[string]any{
+			"id":                              c.ID,
+			"subscription_full_resource_name": c.SubscriptionFullResourceName,
+			"project_id":                      c.ProjectID,
+			"principal":                       c.Principal,
+			"source":                          c.Source,
+			"role":                            c.Role,
+			"last_seen":                       c.lastSeen.Format(time.DateTime),
+		})
+	}
+	for _, svc := range sortedByID(st.services, func(svc *fileCloudRunService) int64 { return svc.ID }) {
+		dump.Tables["cloud_run_services"] = append(dump.Tables["cloud_run_services"], map[string]any{
+			"id":                 svc.ID,
+			"name":               svc.Name,
+			"project_id":         svc.ProjectID,
+			"region":             svc.Region,
+			"full_resource_name": svc.FullResourceName,
+			"urls":               joinList(svc.URLs),
+			"metadata":           svc.Metadata,
+			"last_synced":        svc.lastSynced.Format(syncTimestampLayout),
+		})
+	}
+	for _, fn := range sortedByID(st.functions, func(fn *fileCloudFunction) int64 { return fn.ID }) {
+		dump.Tables["cloud_functions"] = append(dump.Tables["cloud_functions"], map[string]any{
+			"id":                 fn.ID,
+			"name":               fn.Name,
+			"project_id":         fn.ProjectID,
+			"region":             fn.Region,
+			"full_resource_name": fn.FullResourceName,
+			"generation":         fn.Generation,
+			"trigger_topic":      fn.TriggerTopic,
+			"event_type":         fn.EventType,
+			"metadata":           fn.Metadata,
+			"last_synced":        fn.lastSynced.Format(syncTimestampLayout),
+		})
+	}
+	for _, job := range sortedByID(st.jobs, func(job *fileDataflowJob) int64 { return job.ID }) {
+		dump.Tables["dataflow_jobs"] = append(dump.Tables["dataflow_jobs"], map[string]any{
+			"id":                 job.ID,
+			"name":               job.Name,
+			"project_id":         job.ProjectID,
+			"region":             job.Region,
+			"full_resource_name": job.FullResourceName,
+			"sources":            joinList(job.Sources),
+			"sinks":              joinList(job.Sinks),
+			"metadata":           job.Metadata,
+			"last_synced":        job.lastSynced.Format(syncTimestampLayout),
+		})
+	}
+	for _, r := range sortedByID(st.resources, func(r *fileResource) int64 { return r.ID }) {
+		dump.Tables["resources"] = append(dump.Tables["resources"], map[string]any{
+			"id":                 r.ID,
+			"kind":               r.Kind,
+			"name":               r.Name,
+			"project_id":         r.ProjectID,
+			"full_resource_name": r.FullResourceName,
+			"metadata":           r.Metadata,
+			"last_synced":        r.lastSynced.Format(syncTimestampLayout),
+		})
+	}
+	for _, e := range sortedByID(st.edges, func(e *fileEdge) int64 { return e.ID }) {
+		dump.Tables["edges"] = append(dump.Tables["edges"], map[string]any{
+			"id":          e.ID,
+			"source":      e.Source,
+			"target":      e.Target,
+			"relation":    e.Relation,
+			"project_id":  e.ProjectID,
+			"last_synced": e.lastSynced.Format(syncTimestampLayout),
+		})
+	}
+	for _, m := range sortedByID(st.metrics, func(m *fileMetric) int64 { return m.ID }) {
+		dump.Tables["resource_metrics"] = append(dump.Tables["resource_metrics"], map[string]any{
+			"id":                 m.ID,
+			"full_resource_name": m.FullResourceName,
+			"project_id":         m.ProjectID,
+			"metric":             m.Metric,
+			"value":              m.Value,
+			"window_seconds":     int64(m.Window / time.Second),
+			"last_synced":        m.lastSynced.Format(syncTimestampLayout),
+		})
+	}
+	for _, p := range sortedByID(st.points, func(p *MetricPoint) int64 { return p.ID }) {
+		dump.Tables["metric_points"] = append(dump.Tables["metric_points"], map[string]any{
+			"id":                 p.ID,
+			"full_resource_name": p.FullResourceName,
+			"project_id":         p.ProjectID,
+			"metric":             p.Metric,
+			"start_time":         p.Start.Format(syncTimestampLayout),
+			"value":              p.Value,
+		})
+	}
+	for _, c := range st.changes {
+		dump.Tables["changes"] = append(dump.Tables["changes"], map[string]any{
+			"id":                 c.ID,
+			"run_id":             c.RunID,
+			"resource_type":      c.ResourceType,
+			"full_resource_name": c.FullResourceName,
+			"project_id":         c.ProjectID,
+			"change_type":        c.ChangeType,
+			"before_metadata":    nullableJSON(c.BeforeMetadata),
+			"after_metadata":     nullableJSON(c.AfterMetadata),
+			"changed_at":         c.ChangedAt.Format(syncTimestampLayout),
+		})
+	}
+	for _, run := range st.runs {
+		dump.Tables["scan_runs"] = append(dump.Tables["scan_runs"], map[string]any{
+			"id":                   run.ID,
+			"run_id":               run.RunID,
+			"started_at":           run.StartedAt.Format(syncTimestampLayout),
+			"finished_at":          run.FinishedAt.Format(syncTimestampLayout),
+			"projects_attempted":   run.ProjectsAttempted,
+			"projects_succeeded":   run.ProjectsSucceeded,
+			"projects_failed":      run.ProjectsFailed,
+			"projects_skipped":     run.ProjectsSkipped,
+			"projects_interrupted": run.ProjectsInterrupted,
+			"topics":               run.Topics,
+			"subscriptions":        run.Subscriptions,
+			"api_calls":            run.APICalls,
+			"version":              run.Version,
+		})
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(dump)
+}
+
+// nullableJSON returns nil for empty metadata, which SQLite stores as NULL in the changelog
+func nullableJSON(metadata string) any {
+	if metadata == "" {
+		return nil
+	}
+	return metadata
+}
+
+// apply replaces the tables of st present in the dump. The whole dump is
+// decoded before st is changed, so an invalid dump leaves st untouched.
+func (st *fileState) apply(dump *Dump) error {
+	for name, records := range dump.Tables {
+		columns, ok := fileTables[name]
+		if !ok {
+			return fmt.Errorf("dump has unknown table %s", name)
+		}
+		known := make(map[string]bool, len(columns))
+		for _, column := range columns {
+			known[column] = true
+		}
+		for _, record := range records {
+			for column := range record {
+				if !known[column] {
+					return fmt.Errorf("dump has unknown column %s.%s", name, column)
+				}
+			}
+		}
+	}
+
+	decoded := newFileState()
+	for name, records := range dump.Tables {
+		for _, record := range records {
+			row := &dumpRow{table: name, record: record}
+			decoded.addRow(row)
+			if row.err != nil {
+				return row.err
+			}
+		}
+	}
+
+	for name := range dump.Tables {
+		switch name {
+		case "projects":
+			st.projects = decoded.projects
+			st.statuses = decoded.statuses
+			st.labels = decoded.labels
+		case "project_syncs":
+			st.projectSyncs = decoded.projectSyncs
+		case "topics":
+			st.topics = decoded.topics
+		case "subscriptions":
+			st.subscriptions = decoded.subscriptions
+		case "subscription_destinations":
+			st.destinations = decoded.destinations
+		case "subscription_consumers":
+			st.consumers = decoded.consumers
+		case "cloud_run_services":
+			st.services = decoded.services
+		case "cloud_functions":
+			st.functions = decoded.functions
+		case "dataflow_jobs":
+			st.jobs = decoded.jobs
+		case "resources":
+			st.resources = decoded.resources
+		case "edges":
+			st.edges = decoded.edges
+		case "resource_metrics":
+			st.metrics = decoded.metrics
+		case "metric_points":
+			st.points = decoded.points
+		case "changes":
+			st.changes = decoded.changes
+		case "scan_runs":
+			st.runs = decoded.runs
+		}
+		st.nextID[name] = decoded.nextID[name]
+	}
+	return nil
+}
+
+// addRow decodes a dump row into st
+func (st *fileState) addRow(row *dumpRow) {
+	id := row.id(st)
+	switch row.table {
+	case "projects":
+		st.projects[row.str("project_id")] = row.time("last_synced")
+		if status := row.str("status"); status != "" {
+			st.statuses[row.str("project_id")] = status
+		}
+		if labels := row.str("labels"); labels != "" {
+			st.labels[row.str("project_id")] = labels
+		}
+	case "project_syncs":
+		st.projectSyncs = append(st.projectSyncs, &fileProjectSync{id: id, projectID: row.str("project_id"), syncedAt: row.time("synced_at")})
+	case "topics":
+		t := &fileTopic{
+			Topic: Topic{
+				ID:               id,
+				Name:             row.str("name"),
+				ProjectID:        row.str("project_id"),
+				FullResourceName: row.str("full_resource_name"),
+				Metadata:         row.str("metadata"),
+				MessageRetention: time.Duration(row.int("message_retention_seconds")) * time.Second,
+				KMSKeyName:       row.str("kms_key_name"),
+				StorageRegions:   splitList(row.str("storage_regions")),
+			},
+			lastSynced: row.time("last_synced"),
+		}
+		t.lastSeen = row.lastSeen(t.lastSynced)
+		st.topics[t.FullResourceName] = t
+	case "subscriptions":
+		sub := &fileSubscription{
+			Subscription: Subscription{
+				ID:                    id,
+				Name:                  row.str("name"),
+				ProjectID:             row.str("project_id"),
+				TopicFullResourceName: row.str("topic_full_resource_name"),
+				FullResourceName:      row.str("full_resource_name"),
+				Metadata:              row.str("metadata"),
+			},
+			lastSynced: row.time("last_synced"),
+		}
+		sub.Filter = row.filter(sub.Metadata)
+		sub.lastSeen = row.lastSeen(sub.lastSynced)
+		st.subscriptions[sub.FullResourceName] = sub
+	case "subscription_destinations":
+		dest := &fileDestination{
+			SubscriptionDestination: SubscriptionDestination{
+				ID:                           id,
+				SubscriptionFullResourceName: row.str("subscription_full_resource_name"),
+				ProjectID:                    row.str("project_id"),
+				Type:                         row.str("destination_type"),
+				Resource:                     row.str("resource"),
+				Metadata:                     row.str("metadata"),
+			},
+			lastSynced: row.time("last_synced"),
+		}
+		st.destinations[dest.SubscriptionFullResourceName] = dest
+	case "subscription_consumers":
+		c := &fileConsumer{
+			SubscriptionConsumer: SubscriptionConsumer{
+				ID:                           id,
+				SubscriptionFullResourceName: row.str("subscription_full_resource_name"),
+				ProjectID:                    row.str("project_id"),
+				Principal:                    row.str("principal"),
+				Source:                       row.str("source"),
+				Role:                         row.str("role"),
+			},
+			lastSeen: row.time("last_seen"),
+		}
+		st.consumers[consumerKey{c.SubscriptionFullResourceName, c.Principal, c.Source}] = c
+	case "cloud_run_services":
+		svc := &fileCloudRunService{
+			CloudRunService: CloudRunService{
+				ID:               id,
+				Name:             row.str("name"),
+				ProjectID:        row.str("project_id"),
+				Region:           row.str("region"),
+				FullResourceName: row.str("full_resource_name"),
+				URLs:             splitList(row.str("urls")),
+				Metadata:         row.str("metadata"),
+			},
+			lastSynced: row.time("last_synced"),
+		}
+		st.services[svc.FullResourceName] = svc
+	case "cloud_functions":
+		fn := &fileCloudFunction{
+			CloudFunction: CloudFunction{
+				ID:               id,
+				Name:             row.str("name"),
+				ProjectID:        row.str("project_id"),
+				Region:           row.str("region"),
+				FullResourceName: row.str("full_resource_name"),
+				Generation:       int(row.int("generation")),
+				TriggerTopic:     row.str("trigger_topic"),
+				EventType:        row.str("event_type"),
+				Metadata:         row.str("metadata"),
+			},
+			lastSynced: row.time("last_synced"),
+		}
+		st.functions[fn.FullResourceName] = fn
+	case "dataflow_jobs":
+		job := &fileDataflowJob{
+			DataflowJob: DataflowJob{
+				ID:               id,
+				Name:             row.str("name"),
+				ProjectID:        row.str("project_id"),
+				Region:           row.str("region"),
+				FullResourceName: row.str("full_resource_name"),
+				Sources:          splitList(row.str("sources")),
+				Sinks:            splitList(row.str("sinks")),
+				Metadata:         row.str("metadata"),
+			},
+			lastSynced: row.time("last_synced"),
+		}
+		st.jobs[job.FullResourceName] = job
+	case "resources":
+		r := &fileResource{
+			Resource: Resource{
+				ID:               id,
+				Kind:             row.str("kind"),
+				Name:             row.str("name"),
+				ProjectID:        row.str("project_id"),
+				FullResourceName: row.str("full_resource_name"),
+				Metadata:         row.str("metadata"),
+			},
+			lastSynced: row.time("last_synced"),
+		}
+		st.resources[r.FullResourceName] = r
+	case "edges":
+		e := &fileEdge{
+			ResourceEdge: ResourceEdge{
+				ID:        id,
+				Source:    row.str("source"),
+				Target:    row.str("target"),
+				Relation:  row.str("relation"),
+				ProjectID: row.str("project_id"),
+			},
+			lastSynced: row.time("last_synced"),
+		}
+		st.edges[edgeKey{e.Source, e.Target, e.Relation}] = e
+	case "resource_metrics":
+		m := &fileMetric{
+			ResourceMetric: ResourceMetric{
+				ID:               id,
+				FullResourceName: row.str("full_resource_name"),
+				ProjectID:        row.str("project_id"),
+				Metric:           row.str("metric"),
+				Value:            row.float("value"),
+				Window:           time.Duration(row.int("window_seconds")) * time.Second,
+			},
+			lastSynced: row.time("last_synced"),
+		}
+		st.metrics[metricKey{m.FullResourceName, m.Metric}] = m
+	case "metric_points":
+		p := &MetricPoint{
+			ID:               id,
+			FullResourceName: row.str("full_resource_name"),
+			ProjectID:        row.str("project_id"),
+			Metric:           row.str("metric"),
+			Start:            row.time("start_time"),
+			Value:            row.float("value"),
+		}
+		st.points[pointKey{p.FullResourceName, p.Metric, p.Start.UnixMilli()}] = p
+	case "changes":
+		st.changes = append(st.changes, &Change{
+			ID:               id,
+			RunID:            row.str("run_id"),
+			ResourceType:     row.str("resource_type"),
+			FullResourceName: row.str("full_resource_name"),
+			ProjectID:        row.str("project_id"),
+			ChangeType:       row.str("change_type"),
+			BeforeMetadata:   row.str("before_metadata"),
+			AfterMetadata:    row.str("after_metadata"),
+			ChangedAt:        row.time("changed_at"),
+		})
+	case "scan_runs":
+		st.runs = append(st.runs, &ScanRun{
+			ID:                  id,
+			RunID:               row.str("run_id"),
+			StartedAt:           row.time("started_at"),
+			FinishedAt:          row.time("finished_at"),
+			ProjectsAttempted:   int(row.int("projects_attempted")),
+			ProjectsSucceeded:   int(row.int("projects_succeeded")),
+			ProjectsFailed:      int(row.int("projects_failed")),
+			ProjectsSkipped:     int(row.int("projects_skipped")),
+			ProjectsInterrupted: int(row.int("projects_interrupted")),
+			Topics:              int(row.int("topics")),
+			Subscriptions:       int(row.int("subscriptions")),
+			APICalls:            row.int("api_calls"),
+			Version:             row.str("version"),
+		})
+	}
+}
+
+// dumpRow reads the columns of a dump record, keeping the first error
+type dumpRow struct {
+	table  string
+	record map[string]any
+	err    error
+}
+
+func (r *dumpRow) fail(column string, value any) {
+	if r.err == nil {
+		r.err = fmt.Errorf("dump has invalid %s.%s: %v", r.table, column, value)
+	}
+}
+
+// str returns a text column, NULL reads as empty
+func (r *dumpRow) str(column string) string {
+	switch v := r.record[column].(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	default:
+		r.fail(column, v)
+		return ""
+	}
+}
+
+// int returns an integer column, NULL reads as zero
+func (r *dumpRow) int(column string) int64 {
+	switch v := r.record[column].(type) {
+	case nil:
+		return 0
+	case json.Number:
+		n, err := v.Int64()
+		if err != nil {
+			r.fail(column, v)
+		}
+		return n
+	default:
+		r.fail(column, v)
+		return 0
+	}
+}
+
+// float returns a real column, NULL reads as zero
+func (r *dumpRow) float(column string) float64 {
+	switch v := r.record[column].(type) {
+	case nil:
+		return 0
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil {
+			r.fail(column, v)
+		}
+		return f
+	default:
+		r.fail(column, v)
+		return 0
+	}
+}
+
+// id returns the id column, or the next free ID of the table if the row has none
+func (r *dumpRow) id(st *fileState) int64 {
+	v, ok := r.record["id"]
+	if !ok || v == nil {
+		return st.newID(r.table)
+	}
+	n, ok := v.(json.Number)
+	if !ok {
+		r.fail("id", v)
+		return 0
+	}
+	id, err := n.Int64()
+	if err != nil {
+		r.fail("id", v)
+		return 0
+	}
+	if id > st.nextID[r.table] {
+		st.nextID[r.table] = id
+	}
+	return id
+}
+
+// time returns a timestamp column in any layout SQLite stores, a missing one reads as now
+func (r *dumpRow) time(column string) time.Time {
+	v, ok := r.record[column]
+	if !ok || v == nil {
+		return fileNow()
+	}
+	s, ok := v.(string)
+	if !ok {
+		r.fail(column, v)
+		return time.Time{}
+	}
+	for _, layout := range []string{syncTimestampLayout, time.DateTime, time.RFC3339Nano} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC()
+		}
+	}
+	r.fail(column, v)
+	return time.Time{}
+}
+
+// lastSeen returns the last_seen column, lastSynced in dumps written before it existed
+func (r *dumpRow) lastSeen(lastSynced time.Time) time.Time {
+	if r.record["last_seen"] == nil {
+		return lastSynced
+	}
+	return r.time("last_seen")
+}
+
+// filter returns the filter column of a subscription, read from its metadata in dumps
+// from before the column, like the SQLite migration adding it does
+func (r *dumpRow) filter(metadata string) string {
+	if _, ok := r.record["filter"]; ok {
+		return r.str("filter")
+	}
+	var stored struct {
+		Filter string `json:"filter"`
+	}
+	_ = json.Unmarshal([]byte(metadata), &stored)
+	return stored.Filter
+}
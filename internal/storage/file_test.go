@@ -0,0 +1,269 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// seedStore writes one of every kind of row, with a changelog update and delete
+func seedStore(t *testing.T, store Store) {
+	t.Helper()
+	ctx := WithRunID(context.Background(), "run-1")
+
+	require.NoError(t, store.SaveTopics(ctx, []*Topic{
+		{Name: "orders", ProjectID: "project-a", FullResourceName: "projects/project-a/topics/orders", Metadata: `{"labels":{}}`},
+		{Name: "users", ProjectID: "project-b", FullResourceName: "projects/project-b/topics/users", Metadata: `{"labels":{}}`},
+	}))
+	require.NoError(t, store.SaveTopic(ctx, &Topic{
+		Name: "orders", ProjectID: "project-a", FullResourceName: "projects/project-a/topics/orders", Metadata: `{"labels":{"team":"checkout"}}`,
+		MessageRetention: 24 * time.Hour, KMSKeyName: "projects/kms/locations/europe/keyRings/pubsub/cryptoKeys/orders",
+		StorageRegions: []string{"europe-west1", "europe-north1"},
+	}))
+	require.NoError(t, store.SaveSubscriptions(ctx, []*Subscription{
+		{Name: "orders-bq", ProjectID: "project-a", TopicFullResourceName: "projects/project-a/topics/orders", FullResourceName: "projects/project-a/subscriptions/orders-bq", Metadata: `{}`},
+		{Name: "orders-email", ProjectID: "project-b", TopicFullResourceName: "projects/project-a/topics/orders", FullResourceName: "projects/project-b/subscriptions/orders-email", Filter: `attributes.channel = "email"`, Metadata: `{}`},
+	}))
+	require.NoError(t, store.SaveSubscriptionDestination(ctx, &SubscriptionDestination{
+		SubscriptionFullResourceName: "projects/project-a/subscriptions/orders-bq", ProjectID: "project-a",
+		Type: DestinationTypeBigQuery, Resource: "project-a.analytics.orders", Metadata: `{}`,
+	}))
+	require.NoError(t, store.ReplaceSubscriptionConsumers(ctx, "projects/project-b/subscriptions/orders-email", ConsumerSourceIAM, []*SubscriptionConsumer{
+		{ProjectID: "project-b", Principal: "user:alice@example.com", Role: "roles/pubsub.subscriber"},
+		{ProjectID: "project-b", Principal: "serviceAccount:mailer@project-b.iam.gserviceaccount.com", Role: "roles/pubsub.subscriber"},
+	}))
+	require.NoError(t, store.SaveCloudRunServices(ctx, []*CloudRunService{{
+		Name: "mailer", ProjectID: "project-b", Region: "europe-west1", FullResourceName: "projects/project-b/locations/europe-west1/services/mailer",
+		URLs: []string{"https://mailer-abc123-ew.a.run.app"}, Metadata: `{}`,
+	}}))
+	require.NoError(t, store.SaveCloudFunctions(ctx, []*CloudFunction{{
+		Name: "notify", ProjectID: "project-b", Region: "europe-west1", FullResourceName: "projects/project-b/locations/europe-west1/functions/notify",
+		Generation: 2, TriggerTopic: "projects/project-a/topics/orders", EventType: "google.cloud.pubsub.topic.v1.messagePublished", Metadata: `{}`,
+	}}))
+	require.NoError(t, store.SaveDataflowJobs(ctx, []*DataflowJob{{
+		Name: "enrich", ProjectID: "project-a", Region: "europe-west1", FullResourceName: "projects/project-a/locations/europe-west1/jobs/2024-01-01_00_00_00-123",
+		Sources: []string{"projects/project-a/subscriptions/orders-sub"}, Sinks: []string{"projects/project-b/topics/users", "projects/project-a/topics/orders"}, Metadata: `{}`,
+	}}))
+	require.NoError(t, store.SaveResources(ctx, []*Resource{{
+		Kind: "scheduler_job", Name: "nightly", ProjectID: "project-a", FullResourceName: "projects/project-a/locations/europe-west1/jobs/nightly", Metadata: `{}`,
+	}}))
+	require.NoError(t, store.SaveEdges(ctx, []*ResourceEdge{{
+		Source: "projects/project-a/locations/europe-west1/jobs/nightly", Target: "projects/project-a/topics/orders", Relation: "publishes_to", ProjectID: "project-a",
+	}}))
+	require.NoError(t, store.SaveMetrics(ctx, []*ResourceMetric{
+		{FullResourceName: "projects/project-a/topics/orders", ProjectID: "project-a", Metric: MetricTopicPublishOperations, Value: 1250, Window: time.Hour},
+		{FullResourceName: "projects/project-a/subscriptions/orders-sub", ProjectID: "project-a", Metric: MetricSubscriptionBacklog, Value: 12.5, Window: time.Hour},
+	}))
+	require.NoError(t, store.SaveMetricPoints(ctx, []*MetricPoint{
+		{FullResourceName: "projects/project-a/topics/orders", ProjectID: "project-a", Metric: MetricTopicPublishOperations,
+			Start: time.Date(2024, 1, 1, 11, 55, 0, 0, time.UTC), Value: 1250},
+	}))
+	require.NoError(t, store.DeleteTopic(ctx, "projects/project-b/topics/users"))
+	require.NoError(t, store.UpdateProjectSyncTime(ctx, "project-a"))
+	require.NoError(t, store.SetProjectStatus(ctx, "project-c", ProjectStatusAPIDisabled))
+	require.NoError(t, store.SetProjectLabels(ctx, "project-a", map[string]string{"team": "payments"}))
+	require.NoError(t, store.SaveScanRun(ctx, &ScanRun{
+		RunID: "run-1", StartedAt: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC), FinishedAt: time.Date(2024, 1, 1, 12, 3, 30, 250e6, time.UTC),
+		ProjectsAttempted: 3, ProjectsSucceeded: 1, ProjectsFailed: 1, ProjectsSkipped: 1, Topics: 1, Subscriptions: 2, Version: "dev",
+	}))
+}
+
+// assertSameCache compares everything the two stores return
+func assertSameCache(t *testing.T, want, got Store) {
+	t.Helper()
+	ctx := context.Background()
+	for _, get := range []func(Store) (any, error){
+		func(s Store) (any, error) { return s.GetAllTopics(ctx, nil) },
+		func(s Store) (any, error) { return s.GetAllSubscriptions(ctx, nil) },
+		func(s Store) (any, error) { return s.GetAllSubscriptionDestinations(ctx, nil) },
+		func(s Store) (any, error) { return s.GetAllSubscriptionConsumers(ctx, nil) },
+		func(s Store) (any, error) { return s.GetAllCloudRunServices(ctx, nil) },
+		func(s Store) (any, error) { return s.GetAllCloudFunctions(ctx, nil) },
+		func(s Store) (any, error) { return s.GetAllDataflowJobs(ctx, nil) },
+		func(s Store) (any, error) { return s.GetResources(ctx, "", nil) },
+		func(s Store) (any, error) { return s.GetEdges(ctx, nil) },
+		func(s Store) (any, error) { return s.GetMetrics(ctx, nil) },
+		func(s Store) (any, error) { return s.GetMetricPoints(ctx, "project-a", time.Time{}) },
+		func(s Store) (any, error) { return s.GetAllProjects(ctx) },
+		func(s Store) (any, error) { return s.GetProjectSyncTimes(ctx) },
+		func(s Store) (any, error) { return s.GetProjectStatuses(ctx) },
+		func(s Store) (any, error) { return s.GetProjectLabels(ctx) },
+		func(s Store) (any, error) { return s.GetProjectSyncHistory(ctx, time.Time{}) },
+		func(s Store) (any, error) { return s.GetChanges(ctx, time.Time{}, nil) },
+		func(s Store) (any, error) { return s.GetScanRuns(ctx, 0) },
+	} {
+		w, err := get(want)
+		require.NoError(t, err)
+		g, err := get(got)
+		require.NoError(t, err)
+		assert.Equal(t, w, g)
+	}
+}
+
+func TestFileStorage_Persists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.json")
+	store, err := NewFile(path)
+	require.NoError(t, err)
+	seedStore(t, store)
+	require.NoError(t, store.Close())
+
+	reopened, err := NewFile(path)
+	require.NoError(t, err)
+	assertSameCache(t, store, reopened)
+
+	projects, err := reopened.GetAllProjects(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"project-a", "project-b", "project-c"}, projects)
+
+	changes, err := reopened.GetChanges(context.Background(), time.Time{}, nil)
+	require.NoError(t, err)
+	var kinds []string
+	for _, c := range changes {
+		kinds = append(kinds, c.ChangeType)
+	}
+	assert.Equal(t, []string{
+		ChangeTypeCreated, ChangeTypeCreated, ChangeTypeUpdated, ChangeTypeCreated, ChangeTypeCreated, ChangeTypeDeleted,
+	}, kinds)
+
+	// No temporary files are left next to the cache
+	entries, err := os.ReadDir(filepath.Dir(path))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestFileStorage_SameAsSQLite(t *testing.T) {
+	sqlite := setupTestStorage(t)
+	seedStore(t, sqlite)
+
+	// The file backend reads SQLite dumps, and SQLite reads the file backend's cache
+	var dump bytes.Buffer
+	require.NoError(t, sqlite.Export(context.Background(), &dump))
+	path := filepath.Join(t.TempDir(), "cache.json")
+	require.NoError(t, os.WriteFile(path, dump.Bytes(), 0644))
+	file, err := NewFile(path)
+	require.NoError(t, err)
+	assertSameCache(t, sqlite, file)
+
+	var again bytes.Buffer
+	require.NoError(t, file.Export(context.Background(), &again))
+	var first, second Dump
+	require.NoError(t, json.Unmarshal(dump.Bytes(), &first))
+	require.NoError(t, json.Unmarshal(again.Bytes(), &second))
+	require.Len(t, second.Tables, len(first.Tables))
+	for name, rows := range first.Tables {
+		assert.ElementsMatch(t, rows, second.Tables[name], name)
+	}
+
+	target := setupTestStorage(t)
+	require.NoError(t, target.Import(context.Background(), bytes.NewReader(again.Bytes())))
+	assertSameCache(t, sqlite, target)
+}
+
+func TestFileStorage_DeleteStaleResources(t *testing.T) {
+	store, err := NewFile("")
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	save := func(name string) {
+		require.NoError(t, store.SaveTopic(ctx, &Topic{Name: name, ProjectID: "project-a", FullResourceName: "projects/project-a/topics/" + name}))
+		require.NoError(t, store.SaveSubscription(ctx, &Subscription{
+			Name: name + "-sub", ProjectID: "project-a", TopicFullResourceName: "projects/project-a/topics/" + name,
+			FullResourceName: "projects/project-a/subscriptions/" + name + "-sub",
+		}))
+		require.NoError(t, store.SaveSubscriptionConsumer(ctx, &SubscriptionConsumer{
+			SubscriptionFullResourceName: "projects/project-a/subscriptions/" + name + "-sub", ProjectID: "project-a",
+			Principal: "user:alice@example.com", Source: ConsumerSourceIAM, Role: "roles/pubsub.subscriber",
+		}))
+	}
+	save("gone")
+	save("kept")
+
+	time.Sleep(5 * time.Millisecond)
+	started := time.Now()
+	save("kept")
+
+	removed, err := store.DeleteStaleResources(ctx, "project-a", started, everyTable)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), removed)
+
+	topics, err := store.GetAllTopics(ctx, nil)
+	require.NoError(t, err)
+	require.Len(t, topics, 1)
+	assert.Equal(t, "kept", topics[0].Name)
+
+	consumers, err := store.GetAllSubscriptionConsumers(ctx, nil)
+	require.NoError(t, err)
+	require.Len(t, consumers, 1)
+	assert.Equal(t, "projects/project-a/subscriptions/kept-sub", consumers[0].SubscriptionFullResourceName)
+
+	changes, err := store.GetChanges(ctx, started, nil)
+	require.NoError(t, err)
+	var deleted []string
+	for _, change := range changes {
+		if change.ChangeType == ChangeTypeDeleted {
+			deleted = append(deleted, change.FullResourceName)
+		}
+	}
+	assert.Equal(t, []string{"projects/project-a/subscriptions/gone-sub", "projects/project-a/topics/gone"}, deleted)
+}
+
+func TestFileStorage_ImportInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.json")
+	store, err := NewFile(path)
+	require.NoError(t, err)
+	seedStore(t, store)
+
+	for name, tc := range map[string]struct{ dump, err string }{
+		"format": {`{"format":"other"}`, "not a gcp-visualizer dump"},
+		"newer":  {`{"format":"gcp-visualizer-dump","schema_version":999}`, "newer"},
+		"table":  {`{"format":"gcp-visualizer-dump","tables":{"users":[]}}`, "unknown table users"},
+		"column": {`{"format":"gcp-visualizer-dump","tables":{"topics":[{"secret":1}]}}`, "unknown column topics.secret"},
+		"value":  {`{"format":"gcp-visualizer-dump","tables":{"topics":[],"changes":[{"changed_at":"yesterday"}]}}`, "invalid changes.changed_at"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			err := store.Import(context.Background(), strings.NewReader(tc.dump))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.err)
+		})
+	}
+
+	// Failed imports left the cache alone
+	topics, err := store.GetAllTopics(context.Background(), nil)
+	require.NoError(t, err)
+	assert.Len(t, topics, 1)
+}
+
+func TestOpen(t *testing.T) {
+	dir := t.TempDir()
+
+	store, err := Open(BackendFile, filepath.Join(dir, "cache.json"), SQLiteOptions{})
+	require.NoError(t, err)
+	assert.IsType(t, &FileStorage{}, store)
+	require.NoError(t, store.Close())
+
+	store, err = Open("", filepath.Join(dir, "cache.db"), SQLiteOptions{BusyTimeout: DefaultBusyTimeout})
+	require.NoError(t, err)
+	assert.IsType(t, &SQLiteStorage{}, store)
+	require.NoError(t, store.Close())
+
+	_, err = Open("bolt", "", SQLiteOptions{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `unknown storage backend "bolt"`)
+}
+
+func TestDefaultDir(t *testing.T) {
+	if runtime.GOOS == "darwin" || runtime.GOOS == "windows" {
+		t.Skip("XDG_CACHE_HOME only applies to other Unix systems")
+	}
+	t.Setenv("XDG_CACHE_HOME", "/home/alice/.xdg-cache")
+	assert.Equal(t, "/home/alice/.xdg-cache/gcp-visualizer", DefaultDir())
+}
@@ -1,23 +1,110 @@
 package storage
 
-import "context"
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
 
 // Store defines the interface for all storage operations
 // This allows swapping SQLite for PostgreSQL in the future
 type Store interface {
 	// Topics
 	SaveTopic(ctx context.Context, topic *Topic) error
+	SaveTopics(ctx context.Context, topics []*Topic) error
+	// AddTopic saves topic unless a topic with its full resource name is cached, keeping what was collected about it
+	AddTopic(ctx context.Context, topic *Topic) error
 	GetTopics(ctx context.Context, projectID string) ([]*Topic, error)
 	GetAllTopics(ctx context.Context, projects []string) ([]*Topic, error)
+	QueryTopics(ctx context.Context, q TopicQuery) ([]*Topic, error)
+	ListTopics(ctx context.Context, q TopicQuery, page Page) ([]*Topic, error)
+	DeleteTopic(ctx context.Context, fullResourceName string) error
 
 	// Subscriptions
 	SaveSubscription(ctx context.Context, sub *Subscription) error
+	SaveSubscriptions(ctx context.Context, subs []*Subscription) error
+	// AddSubscription saves sub unless a subscription with its full resource name is cached, keeping what was collected about it
+	AddSubscription(ctx context.Context, sub *Subscription) error
 	GetSubscriptions(ctx context.Context, projectID string) ([]*Subscription, error)
 	GetAllSubscriptions(ctx context.Context, projects []string) ([]*Subscription, error)
+	ListSubscriptions(ctx context.Context, projects []string, page Page) ([]*Subscription, error)
+	DeleteSubscription(ctx context.Context, fullResourceName string) error
+
+	// Subscription destinations (BigQuery / Cloud Storage sinks)
+	SaveSubscriptionDestination(ctx context.Context, dest *SubscriptionDestination) error
+	GetAllSubscriptionDestinations(ctx context.Context, projects []string) ([]*SubscriptionDestination, error)
+
+	// Subscription consumers (identities pulling from subscriptions)
+	SaveSubscriptionConsumer(ctx context.Context, consumer *SubscriptionConsumer) error
+	ReplaceSubscriptionConsumers(ctx context.Context, subscriptionFullResourceName, source string, consumers []*SubscriptionConsumer) error
+	GetAllSubscriptionConsumers(ctx context.Context, projects []string) ([]*SubscriptionConsumer, error)
+
+	// Cloud Run services (targets of push subscriptions and Eventarc triggers)
+	SaveCloudRunServices(ctx context.Context, services []*CloudRunService) error
+	GetAllCloudRunServices(ctx context.Context, projects []string) ([]*CloudRunService, error)
+
+	// Cloud Functions (1st and 2nd gen, with their event triggers)
+	SaveCloudFunctions(ctx context.Context, functions []*CloudFunction) error
+	GetAllCloudFunctions(ctx context.Context, projects []string) ([]*CloudFunction, error)
+
+	// Dataflow jobs (streaming pipelines reading and writing Pub/Sub)
+	SaveDataflowJobs(ctx context.Context, jobs []*DataflowJob) error
+	GetAllDataflowJobs(ctx context.Context, projects []string) ([]*DataflowJob, error)
+
+	// Generic resources and the edges between them, for services without a table of their own.
+	// Reads include the topics and subscriptions of the typed tables above, and an edge from each
+	// subscription to its topic; those are only written through the typed methods.
+	SaveResources(ctx context.Context, resources []*Resource) error
+	GetResources(ctx context.Context, kind string, projects []string) ([]*Resource, error)
+	SaveEdges(ctx context.Context, edges []*ResourceEdge) error
+	GetEdges(ctx context.Context, projects []string) ([]*ResourceEdge, error)
+
+	// Metrics of topics and subscriptions read from Cloud Monitoring, one value per resource and metric
+	SaveMetrics(ctx context.Context, metrics []*ResourceMetric) error
+	GetMetrics(ctx context.Context, projects []string) ([]*ResourceMetric, error)
+
+	// Datapoints the metrics above are aggregated from, kept for the metrics window of a project
+	SaveMetricPoints(ctx context.Context, points []*MetricPoint) error
+	GetMetricPoints(ctx context.Context, projectID string, since time.Time) ([]*MetricPoint, error)
+	DeleteMetricPoints(ctx context.Context, projectID string, before time.Time) error
+
+	// Topics and subscriptions an incremental scan saw unchanged, recorded as seen without rewriting them
+	TouchTopics(ctx context.Context, fullResourceNames []string) error
+	TouchSubscriptions(ctx context.Context, fullResourceNames []string) error
+
+	// Stale resources (not seen by the latest scan of a project), only in the tables of the collectors that ran
+	DeleteStaleResources(ctx context.Context, projectID string, before time.Time, stale []Stale) (int64, error)
+
+	// Changelog (append-only, written by the topic and subscription writes above)
+	GetChanges(ctx context.Context, since time.Time, projects []string) ([]*Change, error)
+
+	// Scan runs, written once at the end of each scan
+	SaveScanRun(ctx context.Context, run *ScanRun) error
+	GetScanRuns(ctx context.Context, limit int) ([]*ScanRun, error)
+	GetScanRun(ctx context.Context, runID string) (*ScanRun, error)
 
 	// Projects
 	GetAllProjects(ctx context.Context) ([]string, error)
+	GetProjectSyncTimes(ctx context.Context) (map[string]time.Time, error)
+	GetProjectSyncHistory(ctx context.Context, since time.Time) (map[string][]time.Time, error)
 	UpdateProjectSyncTime(ctx context.Context, projectID string) error
+	SetProjectStatus(ctx context.Context, projectID, status string) error
+	GetProjectStatuses(ctx context.Context) (map[string]string, error)
+	SetProjectLabels(ctx context.Context, projectID string, labels map[string]string) error
+	GetProjectLabels(ctx context.Context) (map[string]map[string]string, error)
+
+	// Portability, whole-cache copies in the backend-neutral Dump format
+	Export(ctx context.Context, w io.Writer) error
+	Import(ctx context.Context, r io.Reader) error
+
+	// Transaction runs fn so that the writes made with the context passed to it apply together
+	// or not at all. fn makes its writes one at a time and doesn't read.
+	Transaction(ctx context.Context, fn func(ctx context.Context) error) error
+
+	// Schema, applied with Migrate when the backend is opened
+	Dialect() Dialect
+	Migrations() []Migration
 
 	// Lifecycle
 	Close() error
@@ -30,6 +117,20 @@ type Topic struct {
 	ProjectID        string
 	FullResourceName string
 	Metadata         string // JSON
+
+	// Settings kept in their own indexed columns so topics can be queried by them
+	MessageRetention time.Duration // How long acknowledged messages are kept, zero if they aren't
+	KMSKeyName       string        // Customer-managed encryption key, empty for Google-managed encryption
+	StorageRegions   []string      // Regions the message storage policy allows, sorted; empty if unrestricted
+}
+
+// TopicQuery selects topics by their settings. Zero fields match every topic.
+type TopicQuery struct {
+	Projects      []string
+	KMSKeyName    string        // only topics encrypted with this key
+	WithoutKMSKey bool          // only topics with Google-managed encryption
+	MinRetention  time.Duration // only topics retaining acknowledged messages at least this long
+	Region        string        // only topics whose storage policy allows this region
 }
 
 // Subscription represents a Pub/Sub subscription
@@ -39,5 +140,223 @@ type Subscription struct {
 	ProjectID             string
 	TopicFullResourceName string
 	FullResourceName      string
+	Filter                string // Filter expression on message attributes, empty if every message is delivered
 	Metadata              string // JSON
 }
+
+// Destination types for subscriptions that export directly to another service
+const (
+	DestinationTypeBigQuery     = "bigquery"
+	DestinationTypeCloudStorage = "cloud_storage"
+	DestinationTypeCloudRun     = "cloud_run" // Eventarc trigger delivering to a Cloud Run service
+)
+
+// SubscriptionDestination represents the sink of a BigQuery or Cloud Storage subscription,
+// or the Cloud Run service an Eventarc trigger delivers the subscription's messages to
+type SubscriptionDestination struct {
+	ID                           int64
+	SubscriptionFullResourceName string
+	ProjectID                    string // Project of the subscription
+	Type                         string // DestinationTypeBigQuery, DestinationTypeCloudStorage or DestinationTypeCloudRun
+	Resource                     string // BigQuery table ("project.dataset.table"), bucket name or Cloud Run service full resource name
+	Metadata                     string // JSON
+}
+
+// CloudRunService is a Cloud Run service, matched to the push subscriptions delivering to it
+type CloudRunService struct {
+	ID               int64
+	Name             string
+	ProjectID        string
+	Region           string
+	FullResourceName string   // "projects/{project}/locations/{region}/services/{service}"
+	URLs             []string // Every URL the service is reachable at, sorted
+	Metadata         string   // JSON
+}
+
+// CloudFunction is a Cloud Function and the topic triggering it, if any
+type CloudFunction struct {
+	ID               int64
+	Name             string
+	ProjectID        string
+	Region           string
+	FullResourceName string // "projects/{project}/locations/{region}/functions/{function}"
+	Generation       int    // 1 or 2, zero if unknown
+	TriggerTopic     string // Full resource name of the Pub/Sub topic triggering the function, empty for other triggers
+	EventType        string // Event type of the trigger, empty for HTTP functions
+	Metadata         string // JSON
+}
+
+// DataflowJob is an active Dataflow job and the Pub/Sub resources it reads and writes
+type DataflowJob struct {
+	ID               int64
+	Name             string
+	ProjectID        string
+	Region           string
+	FullResourceName string   // "projects/{project}/locations/{region}/jobs/{job_id}"
+	Sources          []string // Full resource names of the topics and subscriptions the job reads, sorted
+	Sinks            []string // Full resource names of the topics the job writes, sorted
+	Metadata         string   // JSON
+}
+
+// Kinds of the generic resources read from the typed tables
+const (
+	ResourceKindTopic        = "topic"
+	ResourceKindSubscription = "subscription"
+)
+
+// Kinds of the generic resources stored by the built-in collectors
+const (
+	ResourceKindLiteTopic        = "pubsub_lite_topic"
+	ResourceKindLiteSubscription = "pubsub_lite_subscription"
+)
+
+// Relations of the edges stored by the built-in collectors
+const (
+	RelationSubscribes = "subscribes"  // from a subscription, or a Pub/Sub Lite subscription, to its topic
+	RelationPublishes  = "publishes"   // from an IAM member seen publishing to a topic
+	RelationCanPublish = "can_publish" // from an IAM member holding a publisher role on a topic
+	RelationExports    = "exports"     // from a Pub/Sub Lite subscription to the Pub/Sub topic it exports to
+)
+
+// Tables of the cached resources that DeleteStaleResources prunes
+const (
+	TableTopics           = "topics"
+	TableSubscriptions    = "subscriptions" // together with their consumers and destinations
+	TableDestinations     = "subscription_destinations"
+	TableCloudRunServices = "cloud_run_services"
+	TableCloudFunctions   = "cloud_functions"
+	TableDataflowJobs     = "dataflow_jobs"
+	TableResources        = "resources"
+	TableEdges            = "edges"
+	TableResourceMetrics  = "resource_metrics"
+)
+
+// Stale selects the rows of a table that DeleteStaleResources removes when a scan of
+// their project no longer saw them. Several collectors write the destinations, generic
+// resources, edges and metrics, so Kind narrows those tables down to the rows of one.
+type Stale struct {
+	Table string // e.g. TableCloudRunServices
+	Kind  string // destination type, resource kind, edge relation or metric, empty for every row
+}
+
+// Resource is a resource of any kind, stored by collectors of services without a table of their own
+type Resource struct {
+	ID               int64  // Unique within its kind
+	Kind             string // e.g. "scheduler_job", ResourceKindTopic or ResourceKindSubscription
+	Name             string
+	ProjectID        string
+	FullResourceName string
+	Metadata         string // JSON
+}
+
+// ResourceEdge is a directed relation between two resources, identified by full resource name
+type ResourceEdge struct {
+	ID        int64
+	Source    string
+	Target    string
+	Relation  string // e.g. "publishes_to" or RelationSubscribes
+	ProjectID string // Project of the scan that saw the edge, usually the source's
+}
+
+// Cloud Monitoring metric types stored as ResourceMetric.Metric, relative to pubsub.googleapis.com/
+const (
+	MetricTopicPublishOperations = "topic/send_message_operation_count"      // publish calls, summed over the window
+	MetricSubscriptionBacklog    = "subscription/num_undelivered_messages"   // unacknowledged messages, the highest in the window
+	MetricSubscriptionUnackedAge = "subscription/oldest_unacked_message_age" // seconds, the highest in the window
+)
+
+// ResourceMetric is the value of a metric of a topic or subscription over the window ending at the scan
+type ResourceMetric struct {
+	ID               int64
+	FullResourceName string
+	ProjectID        string
+	Metric           string // e.g. MetricTopicPublishOperations
+	Value            float64
+	Window           time.Duration // whole seconds
+}
+
+// MetricPoint is the value of a metric of a topic or subscription over one period of
+// the metrics resolution, summed or the highest the way ResourceMetric is
+type MetricPoint struct {
+	ID               int64
+	FullResourceName string
+	ProjectID        string
+	Metric           string
+	Start            time.Time // start of the period, whole milliseconds in UTC
+	Value            float64
+}
+
+// Statuses of projects a scan couldn't collect, a completed sync clears them
+const (
+	ProjectStatusAPIDisabled = "api_disabled" // the Pub/Sub API isn't enabled in the project
+	ProjectStatusInterrupted = "interrupted"  // the scan was stopped before it completed the project
+)
+
+// Sources of evidence that an identity consumes a subscription
+const (
+	ConsumerSourceIAM      = "iam"       // Holds a subscriber role on the subscription
+	ConsumerSourceAuditLog = "audit_log" // Seen pulling in Data Access audit logs
+)
+
+// SubscriptionConsumer is an identity that pulls, or is allowed to pull, from a subscription
+type SubscriptionConsumer struct {
+	ID                           int64
+	SubscriptionFullResourceName string
+	ProjectID                    string // Project of the subscription
+	Principal                    string // IAM member, e.g. "serviceAccount:app@project.iam.gserviceaccount.com"
+	Source                       string // ConsumerSourceIAM or ConsumerSourceAuditLog
+	Role                         string // IAM role, or the audited method for audit log evidence
+}
+
+// Kinds of change recorded in the changelog
+const (
+	ChangeTypeCreated = "created"
+	ChangeTypeUpdated = "updated"
+	ChangeTypeDeleted = "deleted"
+)
+
+// Resource types recorded in the changelog
+const (
+	ResourceTypeTopic        = "topic"
+	ResourceTypeSubscription = "subscription"
+)
+
+// Change is an entry of the changelog. Before is empty for created resources
+// and After is empty for deleted ones.
+type Change struct {
+	ID               int64
+	RunID            string // Scan that made the change, empty for incremental updates
+	ResourceType     string // ResourceTypeTopic or ResourceTypeSubscription
+	FullResourceName string
+	ProjectID        string
+	ChangeType       string // ChangeTypeCreated, ChangeTypeUpdated or ChangeTypeDeleted
+	BeforeMetadata   string // JSON
+	AfterMetadata    string // JSON
+	ChangedAt        time.Time
+}
+
+// ErrScanRunNotFound is returned by GetScanRun for an unknown run ID
+var ErrScanRunNotFound = errors.New("scan run not found")
+
+// ScanRun is the record of a scan, written when it finishes or is interrupted
+type ScanRun struct {
+	ID         int64
+	RunID      string // as recorded in the changelog
+	StartedAt  time.Time
+	FinishedAt time.Time
+
+	// Projects the scan attempted, and how each of them ended
+	ProjectsAttempted   int
+	ProjectsSucceeded   int
+	ProjectsFailed      int
+	ProjectsSkipped     int // the Pub/Sub API is disabled
+	ProjectsInterrupted int // cut short or never started, see ProjectStatusInterrupted
+
+	// Resources cached for the attempted projects once the scan finished
+	Topics        int
+	Subscriptions int
+
+	APICalls int64 // rate-limited API requests the scan made, see collector.Collector.APICalls
+
+	Version string // of the CLI that ran the scan
+}
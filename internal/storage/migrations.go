@@ -1,12 +1,150 @@
 package storage
 
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Migration is one step of a backend's schema, applied once in version order
+type Migration struct {
+	Version int    // unique and increasing within a backend's migrations
+	Name    string // short description, recorded when applied
+	SQL     string // statements in the backend's own dialect
+}
+
+// Dialect describes the SQL differences between backends that the migration runner relies on
+type Dialect interface {
+	Name() string
+
+	// Placeholder returns the bind parameter for the nth (1-based) argument, e.g. "?" or "$1"
+	Placeholder(n int) string
+}
+
+// migrationsTable records the applied migrations. The DDL is plain enough for every supported dialect.
+const migrationsTable = `
+    CREATE TABLE IF NOT EXISTS schema_migrations (
+        version INTEGER PRIMARY KEY,
+        name TEXT NOT NULL,
+        applied_at TIMESTAMP NOT NULL
+    )`
+
+// Migrate applies the migrations not yet recorded in db, each in its own transaction.
+// It refuses to run against a schema with migrations it doesn't know, as that schema
+// was written by a newer version.
+func Migrate(ctx context.Context, db *sql.DB, dialect Dialect, migrations []Migration) error {
+	for i := 1; i < len(migrations); i++ {
+		if migrations[i].Version <= migrations[i-1].Version {
+			return fmt.Errorf("%s migrations out of order: version %d follows %d",
+				dialect.Name(), migrations[i].Version, migrations[i-1].Version)
+		}
+	}
+
+	if _, err := db.ExecContext(ctx, migrationsTable); err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	applied, err := appliedMigrations(ctx, db)
+	if err != nil {
+		return err
+	}
+	known := make(map[int]bool, len(migrations))
+	for _, m := range migrations {
+		known[m.Version] = true
+	}
+	for version := range applied {
+		if !known[version] {
+			return fmt.Errorf("database schema has unknown %s migration %d, it was written by a newer version", dialect.Name(), version)
+		}
+	}
+
+	record := fmt.Sprintf("INSERT INTO schema_migrations (version, name, applied_at) VALUES (%s, %s, %s)",
+		dialect.Placeholder(1), dialect.Placeholder(2), dialect.Placeholder(3))
+	for _, m := range migrations {
+		if applied[m.Version] {
+			continue
+		}
+		if err := applyMigration(ctx, db, m, record); err != nil {
+			return fmt.Errorf("failed to apply %s migration %d (%s): %w", dialect.Name(), m.Version, m.Name, err)
+		}
+	}
+	return nil
+}
+
+// appliedMigrations returns the versions recorded in schema_migrations
+func appliedMigrations(ctx context.Context, db *sql.DB) (map[int]bool, error) {
+	rows, err := db.QueryContext(ctx, "SELECT version FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	applied := make(map[int]bool)
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			return nil, err
+		}
+		applied[version] = true
+	}
+	return applied, rows.Err()
+}
+
+func applyMigration(ctx context.Context, db *sql.DB, m Migration, record string) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, m.SQL); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, record, m.Version, m.Name, time.Now().UTC()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// sqliteDialect is the Dialect of SQLiteStorage
+type sqliteDialect struct{}
+
+func (sqliteDialect) Name() string           { return "sqlite" }
+func (sqliteDialect) Placeholder(int) string { return "?" }
+
+// Dialect returns the SQL dialect of the SQLite backend
+func (s *SQLiteStorage) Dialect() Dialect {
+	return sqliteDialect{}
+}
+
+// Migrations returns the schema migrations of the SQLite backend.
+// The first one is the schema from before migrations were versioned; its
+// statements are idempotent so caches created back then adopt it cleanly.
+func (s *SQLiteStorage) Migrations() []Migration {
+	return sqliteMigrations
+}
+
 func (s *SQLiteStorage) migrate() error {
-	schema := `
+	return Migrate(context.Background(), s.writer, s.Dialect(), s.Migrations())
+}
+
+var sqliteMigrations = []Migration{
+	{
+		Version: 1,
+		Name:    "initial schema",
+		SQL: `
     CREATE TABLE IF NOT EXISTS projects (
         project_id TEXT PRIMARY KEY,
         last_synced TIMESTAMP DEFAULT CURRENT_TIMESTAMP
     );
 
+    CREATE TABLE IF NOT EXISTS project_syncs (
+        id INTEGER PRIMARY KEY,
+        project_id TEXT NOT NULL,
+        synced_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+    );
+
     CREATE TABLE IF NOT EXISTS topics (
         id INTEGER PRIMARY KEY,
         name TEXT NOT NULL,
@@ -26,14 +164,280 @@ func (s *SQLiteStorage) migrate() error {
         last_synced TIMESTAMP DEFAULT CURRENT_TIMESTAMP
     );
 
+    CREATE TABLE IF NOT EXISTS subscription_destinations (
+        id INTEGER PRIMARY KEY,
+        subscription_full_resource_name TEXT UNIQUE,
+        project_id TEXT NOT NULL,
+        destination_type TEXT NOT NULL,
+        resource TEXT NOT NULL,
+        metadata JSON,
+        last_synced TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+    );
+
+    CREATE TABLE IF NOT EXISTS subscription_consumers (
+        id INTEGER PRIMARY KEY,
+        subscription_full_resource_name TEXT NOT NULL,
+        project_id TEXT NOT NULL,
+        principal TEXT NOT NULL,
+        source TEXT NOT NULL,
+        role TEXT NOT NULL,
+        last_seen TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+        UNIQUE (subscription_full_resource_name, principal, source)
+    );
+
+    CREATE TABLE IF NOT EXISTS changes (
+        id INTEGER PRIMARY KEY,
+        run_id TEXT NOT NULL DEFAULT '',
+        resource_type TEXT NOT NULL,
+        full_resource_name TEXT NOT NULL,
+        project_id TEXT NOT NULL,
+        change_type TEXT NOT NULL,
+        before_metadata JSON,
+        after_metadata JSON,
+        changed_at TIMESTAMP DEFAULT (STRFTIME('%Y-%m-%d %H:%M:%f', 'now'))
+    );
+
     CREATE INDEX IF NOT EXISTS idx_subs_topic
         ON subscriptions(topic_full_resource_name);
     CREATE INDEX IF NOT EXISTS idx_topics_project
         ON topics(project_id);
     CREATE INDEX IF NOT EXISTS idx_subs_project
         ON subscriptions(project_id);
-    `
+    CREATE INDEX IF NOT EXISTS idx_destinations_project
+        ON subscription_destinations(project_id);
+    CREATE INDEX IF NOT EXISTS idx_consumers_project
+        ON subscription_consumers(project_id);
+    CREATE INDEX IF NOT EXISTS idx_project_syncs_synced_at
+        ON project_syncs(synced_at);
+    CREATE INDEX IF NOT EXISTS idx_changes_changed_at
+        ON changes(changed_at);
+    `,
+	},
+	{
+		Version: 2,
+		Name:    "topic settings columns",
+		SQL: `
+    ALTER TABLE topics ADD COLUMN message_retention_seconds INTEGER NOT NULL DEFAULT 0;
+    ALTER TABLE topics ADD COLUMN kms_key_name TEXT NOT NULL DEFAULT '';
+    ALTER TABLE topics ADD COLUMN storage_regions TEXT NOT NULL DEFAULT '';
+
+    CREATE INDEX IF NOT EXISTS idx_topics_retention
+        ON topics(message_retention_seconds);
+    CREATE INDEX IF NOT EXISTS idx_topics_kms_key
+        ON topics(kms_key_name);
+    CREATE INDEX IF NOT EXISTS idx_topics_storage_regions
+        ON topics(storage_regions);
+    `,
+	},
+	{
+		Version: 3,
+		Name:    "cloud run services",
+		SQL: `
+    CREATE TABLE IF NOT EXISTS cloud_run_services (
+        id INTEGER PRIMARY KEY,
+        name TEXT NOT NULL,
+        project_id TEXT NOT NULL,
+        region TEXT NOT NULL,
+        full_resource_name TEXT UNIQUE,
+        urls TEXT NOT NULL DEFAULT '',
+        metadata JSON,
+        last_synced TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+    );
+
+    CREATE INDEX IF NOT EXISTS idx_cloud_run_services_project
+        ON cloud_run_services(project_id);
+    `,
+	},
+	{
+		Version: 4,
+		Name:    "cloud functions",
+		SQL: `
+    CREATE TABLE IF NOT EXISTS cloud_functions (
+        id INTEGER PRIMARY KEY,
+        name TEXT NOT NULL,
+        project_id TEXT NOT NULL,
+        region TEXT NOT NULL,
+        full_resource_name TEXT UNIQUE,
+        generation INTEGER NOT NULL DEFAULT 0,
+        trigger_topic TEXT NOT NULL DEFAULT '',
+        event_type TEXT NOT NULL DEFAULT '',
+        metadata JSON,
+        last_synced TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+    );
+
+    CREATE INDEX IF NOT EXISTS idx_cloud_functions_project
+        ON cloud_functions(project_id);
+    CREATE INDEX IF NOT EXISTS idx_cloud_functions_trigger_topic
+        ON cloud_functions(trigger_topic);
+    `,
+	},
+	{
+		Version: 5,
+		Name:    "dataflow jobs",
+		SQL: `
+    CREATE TABLE IF NOT EXISTS dataflow_jobs (
+        id INTEGER PRIMARY KEY,
+        name TEXT NOT NULL,
+        project_id TEXT NOT NULL,
+        region TEXT NOT NULL,
+        full_resource_name TEXT UNIQUE,
+        sources TEXT NOT NULL DEFAULT '',
+        sinks TEXT NOT NULL DEFAULT '',
+        metadata JSON,
+        last_synced TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+    );
+
+    CREATE INDEX IF NOT EXISTS idx_dataflow_jobs_project
+        ON dataflow_jobs(project_id);
+    `,
+	},
+	{
+		Version: 6,
+		Name:    "generic resources and edges",
+		SQL: `
+    CREATE TABLE IF NOT EXISTS resources (
+        id INTEGER PRIMARY KEY,
+        kind TEXT NOT NULL,
+        name TEXT NOT NULL,
+        project_id TEXT NOT NULL,
+        full_resource_name TEXT UNIQUE,
+        metadata JSON,
+        last_synced TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+    );
+
+    CREATE INDEX IF NOT EXISTS idx_resources_kind_project
+        ON resources(kind, project_id);
+
+    CREATE TABLE IF NOT EXISTS edges (
+        id INTEGER PRIMARY KEY,
+        source TEXT NOT NULL,
+        target TEXT NOT NULL,
+        relation TEXT NOT NULL,
+        project_id TEXT NOT NULL,
+        last_synced TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+        UNIQUE(source, target, relation)
+    );
+
+    CREATE INDEX IF NOT EXISTS idx_edges_project
+        ON edges(project_id);
+
+    -- Typed tables read as generic resources and edges
+    CREATE VIEW IF NOT EXISTS all_resources AS
+        SELECT id, kind, name, project_id, full_resource_name, metadata FROM resources
+        UNION ALL
+        SELECT id, 'topic', name, project_id, full_resource_name, metadata FROM topics
+        UNION ALL
+        SELECT id, 'subscription', name, project_id, full_resource_name, metadata FROM subscriptions;
+
+    CREATE VIEW IF NOT EXISTS all_edges AS
+        SELECT id, source, target, relation, project_id FROM edges
+        UNION ALL
+        SELECT id, full_resource_name, topic_full_resource_name, 'subscribes', project_id FROM subscriptions
+        WHERE topic_full_resource_name LIKE 'projects/%/topics/%';
+    `,
+	},
+	{
+		Version: 7,
+		Name:    "resource metrics",
+		SQL: `
+    CREATE TABLE IF NOT EXISTS resource_metrics (
+        id INTEGER PRIMARY KEY,
+        full_resource_name TEXT NOT NULL,
+        project_id TEXT NOT NULL,
+        metric TEXT NOT NULL,
+        value REAL NOT NULL,
+        window_seconds INTEGER NOT NULL,
+        last_synced TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+        UNIQUE(full_resource_name, metric)
+    );
+
+    CREATE INDEX IF NOT EXISTS idx_resource_metrics_project
+        ON resource_metrics(project_id);
+    `,
+	},
+	{
+		Version: 8,
+		Name:    "project status",
+		SQL: `
+    ALTER TABLE projects ADD COLUMN status TEXT NOT NULL DEFAULT '';
+    `,
+	},
+	{
+		Version: 9,
+		Name:    "scan runs",
+		SQL: `
+    CREATE TABLE IF NOT EXISTS scan_runs (
+        id INTEGER PRIMARY KEY,
+        run_id TEXT NOT NULL UNIQUE,
+        started_at TIMESTAMP NOT NULL,
+        finished_at TIMESTAMP NOT NULL,
+        projects_attempted INTEGER NOT NULL DEFAULT 0,
+        projects_succeeded INTEGER NOT NULL DEFAULT 0,
+        projects_failed INTEGER NOT NULL DEFAULT 0,
+        projects_skipped INTEGER NOT NULL DEFAULT 0,
+        projects_interrupted INTEGER NOT NULL DEFAULT 0,
+        topics INTEGER NOT NULL DEFAULT 0,
+        subscriptions INTEGER NOT NULL DEFAULT 0,
+        version TEXT NOT NULL DEFAULT ''
+    );
+
+    CREATE INDEX IF NOT EXISTS idx_scan_runs_started_at
+        ON scan_runs(started_at);
+    `,
+	},
+	{
+		Version: 10,
+		Name:    "project labels",
+		SQL: `
+    ALTER TABLE projects ADD COLUMN labels TEXT NOT NULL DEFAULT '';
+    `,
+	},
+	{
+		// last_synced is when a row was last written, last_seen when a scan last listed it;
+		// an incremental scan only moves last_seen of resources that didn't change
+		Version: 11,
+		Name:    "last seen",
+		SQL: `
+    ALTER TABLE topics ADD COLUMN last_seen TIMESTAMP;
+    ALTER TABLE subscriptions ADD COLUMN last_seen TIMESTAMP;
+    UPDATE topics SET last_seen = last_synced;
+    UPDATE subscriptions SET last_seen = last_synced;
+    `,
+	},
+	{
+		Version: 12,
+		Name:    "scan run api calls",
+		SQL: `
+    ALTER TABLE scan_runs ADD COLUMN api_calls INTEGER NOT NULL DEFAULT 0;
+    `,
+	},
+	{
+		// Filters were kept in the metadata only, so existing subscriptions get theirs from there
+		Version: 13,
+		Name:    "subscription filter",
+		SQL: `
+    ALTER TABLE subscriptions ADD COLUMN filter TEXT NOT NULL DEFAULT '';
+    UPDATE subscriptions SET filter = COALESCE(json_extract(metadata, '$.filter'), '')
+        WHERE json_valid(metadata);
+    `,
+	},
+	{
+		// The datapoints behind resource_metrics, so a scan only fetches those newer than the last
+		Version: 14,
+		Name:    "metric points",
+		SQL: `
+    CREATE TABLE IF NOT EXISTS metric_points (
+        id INTEGER PRIMARY KEY,
+        full_resource_name TEXT NOT NULL,
+        project_id TEXT NOT NULL,
+        metric TEXT NOT NULL,
+        start_time TIMESTAMP NOT NULL,
+        value REAL NOT NULL,
+        UNIQUE(full_resource_name, metric, start_time)
+    );
 
-	_, err := s.db.Exec(schema)
-	return err
+    CREATE INDEX IF NOT EXISTS idx_metric_points_project
+        ON metric_points(project_id, start_time);
+    `,
+	},
 }
@@ -0,0 +1,44 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Backends selectable with storage.backend in the config
+const (
+	BackendSQLite = "sqlite"
+	BackendFile   = "file"
+)
+
+// DefaultDir returns the directory holding the cache unless cache.path is set:
+// $XDG_CACHE_HOME/gcp-visualizer, ~/.cache/gcp-visualizer if unset, or the
+// platform's equivalent. It falls back to the temp directory without a home directory.
+func DefaultDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "gcp-visualizer")
+}
+
+// Open opens the cache of the given backend at path. An empty backend is
+// SQLite, and an empty path is the backend's default file in DefaultDir.
+// The file backend ignores opts.
+func Open(backend, path string, opts SQLiteOptions) (Store, error) {
+	switch backend {
+	case "", BackendSQLite:
+		if path == "" {
+			path = filepath.Join(DefaultDir(), "cache.db")
+		}
+		return NewSQLiteWithOptions(path, opts)
+	case BackendFile:
+		if path == "" {
+			path = filepath.Join(DefaultDir(), "cache.json")
+		}
+		return NewFile(path)
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q, expected %q or %q", backend, BackendSQLite, BackendFile)
+	}
+}
@@ -2,14 +2,47 @@ package storage
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
 	"fmt"
+	"slices"
+	"sort"
 	"strings"
+	"time"
 )
 
+// syncTimestamp is the SQL expression stored in last_synced by collector writes.
+// It has millisecond resolution so a scan can tell its own writes apart from
+// those of a scan that finished moments earlier.
+const syncTimestamp = `STRFTIME('%Y-%m-%d %H:%M:%f', 'now')`
+
+// syncTimestampLayout formats a time.Time the way syncTimestamp stores it
+const syncTimestampLayout = "2006-01-02 15:04:05.000"
+
 // SaveTopic inserts or updates a topic
 func (s *SQLiteStorage) SaveTopic(ctx context.Context, topic *Topic) error {
-	// Start transaction to ensure project is also saved
-	tx, err := s.db.BeginTx(ctx, nil)
+	return s.SaveTopics(ctx, []*Topic{topic})
+}
+
+// SaveTopics inserts or updates a batch of topics in a single transaction
+func (s *SQLiteStorage) SaveTopics(ctx context.Context, topics []*Topic) error {
+	return s.saveTopics(ctx, topics, false)
+}
+
+// AddTopic inserts a topic unless one with its full resource name is cached
+func (s *SQLiteStorage) AddTopic(ctx context.Context, topic *Topic) error {
+	return s.saveTopics(ctx, []*Topic{topic}, true)
+}
+
+// saveTopics inserts or updates topics in a single transaction, or with addOnly
+// inserts those that aren't cached and leaves the others as they are
+func (s *SQLiteStorage) saveTopics(ctx context.Context, topics []*Topic, addOnly bool) error {
+	if len(topics) == 0 {
+		return nil
+	}
+
+	// Start transaction to ensure projects are also saved
+	tx, err := s.begin(ctx)
 	if err != nil {
 		return err
 	}
@@ -19,24 +52,47 @@ func (s *SQLiteStorage) SaveTopic(ctx context.Context, topic *Topic) error {
 		}
 	}()
 
-	// Ensure project exists in projects table
-	projectQuery := `
-        INSERT OR REPLACE INTO projects (project_id, last_synced)
-        VALUES (?, CURRENT_TIMESTAMP)`
-	if _, err = tx.ExecContext(ctx, projectQuery, topic.ProjectID); err != nil {
+	if addOnly {
+		if topics, err = uncached(ctx, tx, "topics", topics, func(t *Topic) string { return t.FullResourceName }); err != nil {
+			return err
+		}
+		if len(topics) == 0 {
+			err = tx.Commit()
+			return err
+		}
+	}
+
+	projects := make([]string, 0, len(topics))
+	for _, topic := range topics {
+		projects = append(projects, topic.ProjectID)
+	}
+	if err = ensureProjects(ctx, tx, projects); err != nil {
+		return err
+	}
+
+	upserts := make([]upsert, 0, len(topics))
+	args := make([]interface{}, 0, 7*len(topics))
+	for _, topic := range topics {
+		upserts = append(upserts, upsert{topic.FullResourceName, topic.ProjectID, topic.Metadata})
+		args = append(args,
+			topic.Name,
+			topic.ProjectID,
+			topic.FullResourceName,
+			topic.Metadata,
+			int64(topic.MessageRetention/time.Second),
+			topic.KMSKeyName,
+			joinList(topic.StorageRegions))
+	}
+	if err = recordUpserts(ctx, tx, "topics", ResourceTypeTopic, upserts); err != nil {
 		return err
 	}
 
-	// Insert or update topic
-	topicQuery := `
+	// Insert or update topics
+	if err = execRows(ctx, tx, `
         INSERT OR REPLACE INTO topics
-        (name, project_id, full_resource_name, metadata, last_synced)
-        VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP)`
-	if _, err = tx.ExecContext(ctx, topicQuery,
-		topic.Name,
-		topic.ProjectID,
-		topic.FullResourceName,
-		topic.Metadata); err != nil {
+        (name, project_id, full_resource_name, metadata,
+         message_retention_seconds, kms_key_name, storage_regions, last_synced, last_seen)`,
+		`(?, ?, ?, ?, ?, ?, ?, `+syncTimestamp+`, `+syncTimestamp+`)`, 7, args); err != nil {
 		return err
 	}
 
@@ -46,7 +102,7 @@ func (s *SQLiteStorage) SaveTopic(ctx context.Context, topic *Topic) error {
 
 // GetTopics retrieves all topics for a specific project
 func (s *SQLiteStorage) GetTopics(ctx context.Context, projectID string) ([]*Topic, error) {
-	query := `SELECT id, name, project_id, full_resource_name, metadata
+	query := `SELECT ` + topicColumns + `
               FROM topics
               WHERE project_id = ?`
 
@@ -56,22 +112,14 @@ func (s *SQLiteStorage) GetTopics(ctx context.Context, projectID string) ([]*Top
 	}
 	defer func() { _ = rows.Close() }()
 
-	var topics []*Topic
-	for rows.Next() {
-		t := &Topic{}
-		if err := rows.Scan(&t.ID, &t.Name, &t.ProjectID, &t.FullResourceName, &t.Metadata); err != nil {
-			return nil, err
-		}
-		topics = append(topics, t)
-	}
-	return topics, rows.Err()
+	return scanTopics(rows)
 }
 
 // GetAllTopics retrieves topics for multiple projects
 func (s *SQLiteStorage) GetAllTopics(ctx context.Context, projects []string) ([]*Topic, error) {
 	if len(projects) == 0 {
 		// Return all topics if no projects specified
-		query := `SELECT id, name, project_id, full_resource_name, metadata FROM topics`
+		query := `SELECT ` + topicColumns + ` FROM topics`
 		rows, err := s.db.QueryContext(ctx, query)
 		if err != nil {
 			return nil, err
@@ -84,9 +132,9 @@ func (s *SQLiteStorage) GetAllTopics(ctx context.Context, projects []string) ([]
 	// Build parameterized IN clause - safe from SQL injection as we use placeholders
 	// and pass values separately via args
 	inClause, args := buildInClause(projects)
-	query := fmt.Sprintf(`SELECT id, name, project_id, full_resource_name, metadata
+	query := fmt.Sprintf(`SELECT %s
                            FROM topics
-                           WHERE project_id IN (%s)`, inClause)
+                           WHERE project_id IN (%s)`, topicColumns, inClause)
 
 	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
@@ -97,10 +145,113 @@ func (s *SQLiteStorage) GetAllTopics(ctx context.Context, projects []string) ([]
 	return scanTopics(rows)
 }
 
+// QueryTopics retrieves the topics matching q, using the indexed settings columns
+func (s *SQLiteStorage) QueryTopics(ctx context.Context, q TopicQuery) ([]*Topic, error) {
+	where, args := topicWhere(q)
+	rows, err := s.db.QueryContext(ctx, `SELECT `+topicColumns+` FROM topics`+where+` ORDER BY id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	return scanTopics(rows)
+}
+
+// ListTopics retrieves a sorted page of the topics matching q
+func (s *SQLiteStorage) ListTopics(ctx context.Context, q TopicQuery, page Page) ([]*Topic, error) {
+	if err := page.Validate(); err != nil {
+		return nil, err
+	}
+	where, args := topicWhere(q)
+	orderBy, pageArgs := page.orderBy()
+	rows, err := s.db.QueryContext(ctx, `SELECT `+topicColumns+` FROM topics`+where+orderBy, append(args, pageArgs...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	return scanTopics(rows)
+}
+
+// topicWhere returns the WHERE clause selecting the topics matching q, empty if q matches every topic
+func topicWhere(q TopicQuery) (string, []interface{}) {
+	var (
+		where []string
+		args  []interface{}
+	)
+	if len(q.Projects) > 0 {
+		inClause, inArgs := buildInClause(q.Projects)
+		where = append(where, fmt.Sprintf(`project_id IN (%s)`, inClause))
+		args = append(args, inArgs...)
+	}
+	if q.KMSKeyName != "" {
+		where = append(where, `kms_key_name = ?`)
+		args = append(args, q.KMSKeyName)
+	}
+	if q.WithoutKMSKey {
+		where = append(where, `kms_key_name = ''`)
+	}
+	if q.MinRetention > 0 {
+		where = append(where, `message_retention_seconds >= ?`)
+		args = append(args, int64(q.MinRetention/time.Second))
+	}
+	if q.Region != "" {
+		where = append(where, `INSTR(',' || storage_regions || ',', ?) > 0`)
+		args = append(args, ","+q.Region+",")
+	}
+	if len(where) == 0 {
+		return "", nil
+	}
+	return ` WHERE ` + strings.Join(where, ` AND `), args
+}
+
+// DeleteTopic removes a topic by its full resource name
+func (s *SQLiteStorage) DeleteTopic(ctx context.Context, fullResourceName string) error {
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if err = recordDeletes(ctx, tx, "topics", ResourceTypeTopic, `full_resource_name = ?`, fullResourceName); err != nil {
+		return err
+	}
+	if _, err = tx.ExecContext(ctx, `DELETE FROM topics WHERE full_resource_name = ?`, fullResourceName); err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	return err
+}
+
 // SaveSubscription inserts or updates a subscription
 func (s *SQLiteStorage) SaveSubscription(ctx context.Context, sub *Subscription) error {
-	// Start transaction to ensure project is also saved
-	tx, err := s.db.BeginTx(ctx, nil)
+	return s.SaveSubscriptions(ctx, []*Subscription{sub})
+}
+
+// SaveSubscriptions inserts or updates a batch of subscriptions in a single transaction
+func (s *SQLiteStorage) SaveSubscriptions(ctx context.Context, subs []*Subscription) error {
+	return s.saveSubscriptions(ctx, subs, false)
+}
+
+// AddSubscription inserts a subscription unless one with its full resource name is cached
+func (s *SQLiteStorage) AddSubscription(ctx context.Context, sub *Subscription) error {
+	return s.saveSubscriptions(ctx, []*Subscription{sub}, true)
+}
+
+// saveSubscriptions inserts or updates subscriptions in a single transaction, or with
+// addOnly inserts those that aren't cached and leaves the others as they are
+func (s *SQLiteStorage) saveSubscriptions(ctx context.Context, subs []*Subscription, addOnly bool) error {
+	if len(subs) == 0 {
+		return nil
+	}
+
+	// Start transaction to ensure projects are also saved
+	tx, err := s.begin(ctx)
 	if err != nil {
 		return err
 	}
@@ -110,25 +261,45 @@ func (s *SQLiteStorage) SaveSubscription(ctx context.Context, sub *Subscription)
 		}
 	}()
 
-	// Ensure project exists in projects table
-	projectQuery := `
-        INSERT OR REPLACE INTO projects (project_id, last_synced)
-        VALUES (?, CURRENT_TIMESTAMP)`
-	if _, err = tx.ExecContext(ctx, projectQuery, sub.ProjectID); err != nil {
+	if addOnly {
+		if subs, err = uncached(ctx, tx, "subscriptions", subs, func(s *Subscription) string { return s.FullResourceName }); err != nil {
+			return err
+		}
+		if len(subs) == 0 {
+			err = tx.Commit()
+			return err
+		}
+	}
+
+	projects := make([]string, 0, len(subs))
+	for _, sub := range subs {
+		projects = append(projects, sub.ProjectID)
+	}
+	if err = ensureProjects(ctx, tx, projects); err != nil {
+		return err
+	}
+
+	upserts := make([]upsert, 0, len(subs))
+	args := make([]interface{}, 0, 6*len(subs))
+	for _, sub := range subs {
+		upserts = append(upserts, upsert{sub.FullResourceName, sub.ProjectID, sub.Metadata})
+		args = append(args,
+			sub.Name,
+			sub.ProjectID,
+			sub.TopicFullResourceName,
+			sub.FullResourceName,
+			sub.Filter,
+			sub.Metadata)
+	}
+	if err = recordUpserts(ctx, tx, "subscriptions", ResourceTypeSubscription, upserts); err != nil {
 		return err
 	}
 
-	// Insert or update subscription
-	subscriptionQuery := `
+	// Insert or update subscriptions
+	if err = execRows(ctx, tx, `
         INSERT OR REPLACE INTO subscriptions
-        (name, project_id, topic_full_resource_name, full_resource_name, metadata, last_synced)
-        VALUES (?, ?, ?, ?, ?, CURRENT_TIMESTAMP)`
-	if _, err = tx.ExecContext(ctx, subscriptionQuery,
-		sub.Name,
-		sub.ProjectID,
-		sub.TopicFullResourceName,
-		sub.FullResourceName,
-		sub.Metadata); err != nil {
+        (name, project_id, topic_full_resource_name, full_resource_name, filter, metadata, last_synced, last_seen)`,
+		`(?, ?, ?, ?, ?, ?, `+syncTimestamp+`, `+syncTimestamp+`)`, 6, args); err != nil {
 		return err
 	}
 
@@ -138,7 +309,7 @@ func (s *SQLiteStorage) SaveSubscription(ctx context.Context, sub *Subscription)
 
 // GetSubscriptions retrieves all subscriptions for a specific project
 func (s *SQLiteStorage) GetSubscriptions(ctx context.Context, projectID string) ([]*Subscription, error) {
-	query := `SELECT id, name, project_id, topic_full_resource_name, full_resource_name, metadata
+	query := `SELECT ` + subscriptionColumns + `
               FROM subscriptions
               WHERE project_id = ?`
 
@@ -155,7 +326,7 @@ func (s *SQLiteStorage) GetSubscriptions(ctx context.Context, projectID string)
 func (s *SQLiteStorage) GetAllSubscriptions(ctx context.Context, projects []string) ([]*Subscription, error) {
 	if len(projects) == 0 {
 		// Return all subscriptions if no projects specified
-		query := `SELECT id, name, project_id, topic_full_resource_name, full_resource_name, metadata
+		query := `SELECT ` + subscriptionColumns + `
                   FROM subscriptions`
 		rows, err := s.db.QueryContext(ctx, query)
 		if err != nil {
@@ -169,9 +340,9 @@ func (s *SQLiteStorage) GetAllSubscriptions(ctx context.Context, projects []stri
 	// Build parameterized IN clause - safe from SQL injection as we use placeholders
 	// and pass values separately via args
 	inClause, args := buildInClause(projects)
-	query := fmt.Sprintf(`SELECT id, name, project_id, topic_full_resource_name, full_resource_name, metadata
+	query := fmt.Sprintf(`SELECT %s
                            FROM subscriptions
-                           WHERE project_id IN (%s)`, inClause)
+                           WHERE project_id IN (%s)`, subscriptionColumns, inClause)
 
 	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
@@ -182,52 +353,1071 @@ func (s *SQLiteStorage) GetAllSubscriptions(ctx context.Context, projects []stri
 	return scanSubscriptions(rows)
 }
 
-// GetAllProjects returns all unique project IDs from the database
-func (s *SQLiteStorage) GetAllProjects(ctx context.Context) ([]string, error) {
-	query := `SELECT DISTINCT project_id FROM projects ORDER BY project_id`
+// ListSubscriptions retrieves a sorted page of the subscriptions of the given projects, all of them if projects is empty
+func (s *SQLiteStorage) ListSubscriptions(ctx context.Context, projects []string, page Page) ([]*Subscription, error) {
+	if err := page.Validate(); err != nil {
+		return nil, err
+	}
+	query := `SELECT ` + subscriptionColumns + `
+              FROM subscriptions`
+	var args []interface{}
+	if len(projects) > 0 {
+		var inClause string
+		inClause, args = buildInClause(projects)
+		query += ` WHERE project_id IN (` + inClause + `)`
+	}
+	orderBy, pageArgs := page.orderBy()
+	rows, err := s.db.QueryContext(ctx, query+orderBy, append(args, pageArgs...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
 
-	rows, err := s.db.QueryContext(ctx, query)
+	return scanSubscriptions(rows)
+}
+
+// DeleteSubscription removes a subscription, its destination and consumers by full resource name
+func (s *SQLiteStorage) DeleteSubscription(ctx context.Context, fullResourceName string) error {
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if err = recordDeletes(ctx, tx, "subscriptions", ResourceTypeSubscription, `full_resource_name = ?`, fullResourceName); err != nil {
+		return err
+	}
+	if _, err = tx.ExecContext(ctx, `DELETE FROM subscriptions WHERE full_resource_name = ?`, fullResourceName); err != nil {
+		return err
+	}
+	if _, err = tx.ExecContext(ctx, `DELETE FROM subscription_destinations WHERE subscription_full_resource_name = ?`, fullResourceName); err != nil {
+		return err
+	}
+	if _, err = tx.ExecContext(ctx, `DELETE FROM subscription_consumers WHERE subscription_full_resource_name = ?`, fullResourceName); err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	return err
+}
+
+// TouchTopics records that a scan saw the given topics unchanged, without rewriting them
+func (s *SQLiteStorage) TouchTopics(ctx context.Context, fullResourceNames []string) error {
+	return s.touch(ctx, "topics", fullResourceNames)
+}
+
+// TouchSubscriptions records that a scan saw the given subscriptions unchanged, without rewriting them
+func (s *SQLiteStorage) TouchSubscriptions(ctx context.Context, fullResourceNames []string) error {
+	return s.touch(ctx, "subscriptions", fullResourceNames)
+}
+
+// touch refreshes last_seen of the given rows of table in one transaction
+func (s *SQLiteStorage) touch(ctx context.Context, table string, fullResourceNames []string) error {
+	if len(fullResourceNames) == 0 {
+		return nil
+	}
+
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if err = execIn(ctx, tx, `UPDATE `+table+` SET last_seen = `+syncTimestamp+` WHERE full_resource_name IN (`, fullResourceNames); err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	return err
+}
+
+// DeleteStaleResources removes the rows of a project selected by stale that were last synced
+// (for topics and subscriptions, last seen) before the given time. Removed subscriptions take
+// their destinations and consumers with them. Only the tables of the collectors that ran are
+// pruned, the rows of the others weren't refreshed. It returns the number of topics and
+// subscriptions removed.
+func (s *SQLiteStorage) DeleteStaleResources(ctx context.Context, projectID string, before time.Time, stale []Stale) (int64, error) {
+	if err := checkStale(stale); err != nil {
+		return 0, err
+	}
+	cutoff := before.UTC().Format(syncTimestampLayout)
+
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	// Topics and subscriptions an incremental scan saw unchanged only have last_seen refreshed;
+	// rows restored from a dump without last_seen fall back to last_synced
+	if slices.Contains(stale, Stale{Table: TableSubscriptions}) {
+		staleSubscriptions := `SELECT full_resource_name FROM subscriptions
+                               WHERE project_id = ? AND COALESCE(last_seen, last_synced) < ?`
+		for _, table := range []string{"subscription_consumers", "subscription_destinations"} {
+			if _, err = tx.ExecContext(ctx, `DELETE FROM `+table+`
+                WHERE subscription_full_resource_name IN (`+staleSubscriptions+`)`, projectID, cutoff); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	// Other services aren't in the changelog, nor counted as removed
+	for _, st := range stale {
+		if st.Table == TableTopics || st.Table == TableSubscriptions {
+			continue
+		}
+		query := `DELETE FROM ` + st.Table + ` WHERE project_id = ? AND last_synced < ?`
+		args := []interface{}{projectID, cutoff}
+		if st.Kind != "" {
+			query += ` AND ` + staleKindColumns[st.Table] + ` = ?`
+			args = append(args, st.Kind)
+		}
+		if _, err = tx.ExecContext(ctx, query, args...); err != nil {
+			return 0, err
+		}
+	}
+
+	var removed int64
+	for _, table := range []struct{ table, resourceType string }{
+		{TableSubscriptions, ResourceTypeSubscription},
+		{TableTopics, ResourceTypeTopic},
+	} {
+		if !slices.Contains(stale, Stale{Table: table.table}) {
+			continue
+		}
+		where := `project_id = ? AND COALESCE(last_seen, last_synced) < ?`
+		if err = recordDeletes(ctx, tx, table.table, table.resourceType, where, projectID, cutoff); err != nil {
+			return 0, err
+		}
+		var res sql.Result
+		if res, err = tx.ExecContext(ctx, `DELETE FROM `+table.table+` WHERE `+where, projectID, cutoff); err != nil {
+			return 0, err
+		}
+		var n int64
+		if n, err = res.RowsAffected(); err != nil {
+			return 0, err
+		}
+		removed += n
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+	return removed, nil
+}
+
+// SaveSubscriptionDestination inserts or updates the destination of a subscription
+func (s *SQLiteStorage) SaveSubscriptionDestination(ctx context.Context, dest *SubscriptionDestination) error {
+	query := `
+        INSERT OR REPLACE INTO subscription_destinations
+        (subscription_full_resource_name, project_id, destination_type, resource, metadata, last_synced)
+        VALUES (?, ?, ?, ?, ?, ` + syncTimestamp + `)`
+
+	_, err := s.exec(ctx, query,
+		dest.SubscriptionFullResourceName,
+		dest.ProjectID,
+		dest.Type,
+		dest.Resource,
+		dest.Metadata)
+	return err
+}
+
+// GetAllSubscriptionDestinations retrieves subscription destinations for multiple projects
+func (s *SQLiteStorage) GetAllSubscriptionDestinations(ctx context.Context, projects []string) ([]*SubscriptionDestination, error) {
+	query := `SELECT id, subscription_full_resource_name, project_id, destination_type, resource, metadata
+              FROM subscription_destinations`
+	var args []interface{}
+	if len(projects) > 0 {
+		// Build parameterized IN clause - safe from SQL injection as we use placeholders
+		// and pass values separately via args
+		var inClause string
+		inClause, args = buildInClause(projects)
+		query = fmt.Sprintf("%s WHERE project_id IN (%s)", query, inClause)
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer func() { _ = rows.Close() }()
 
-	var projects []string
+	var destinations []*SubscriptionDestination
 	for rows.Next() {
-		var projectID string
-		if err := rows.Scan(&projectID); err != nil {
+		d := &SubscriptionDestination{}
+		if err := rows.Scan(&d.ID, &d.SubscriptionFullResourceName, &d.ProjectID, &d.Type, &d.Resource, &d.Metadata); err != nil {
 			return nil, err
 		}
-		projects = append(projects, projectID)
+		destinations = append(destinations, d)
 	}
-	return projects, rows.Err()
+	return destinations, rows.Err()
 }
 
-// UpdateProjectSyncTime updates or inserts the last sync time for a project
-func (s *SQLiteStorage) UpdateProjectSyncTime(ctx context.Context, projectID string) error {
-	query := `
-        INSERT OR REPLACE INTO projects (project_id, last_synced)
-        VALUES (?, CURRENT_TIMESTAMP)`
+// SaveCloudRunServices inserts or updates a batch of Cloud Run services in a single transaction
+func (s *SQLiteStorage) SaveCloudRunServices(ctx context.Context, services []*CloudRunService) error {
+	if len(services) == 0 {
+		return nil
+	}
+
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	stmt, err := tx.PrepareContext(ctx, `
+        INSERT OR REPLACE INTO cloud_run_services
+        (name, project_id, region, full_resource_name, urls, metadata, last_synced)
+        VALUES (?, ?, ?, ?, ?, ?, `+syncTimestamp+`)`)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = stmt.Close() }()
+
+	for _, svc := range services {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		if _, err = stmt.ExecContext(ctx,
+			svc.Name,
+			svc.ProjectID,
+			svc.Region,
+			svc.FullResourceName,
+			joinList(svc.URLs),
+			svc.Metadata); err != nil {
+			return err
+		}
+	}
 
-	_, err := s.db.ExecContext(ctx, query, projectID)
+	err = tx.Commit()
 	return err
 }
 
-// Helper function to scan topics from rows
-func scanTopics(rows interface {
-	Next() bool
-	Scan(...interface{}) error
-	Err() error
-}) ([]*Topic, error) {
-	var topics []*Topic
+// GetAllCloudRunServices retrieves Cloud Run services for multiple projects
+func (s *SQLiteStorage) GetAllCloudRunServices(ctx context.Context, projects []string) ([]*CloudRunService, error) {
+	query := `SELECT id, name, project_id, region, full_resource_name, urls, metadata
+              FROM cloud_run_services`
+	var args []interface{}
+	if len(projects) > 0 {
+		var inClause string
+		inClause, args = buildInClause(projects)
+		query = fmt.Sprintf("%s WHERE project_id IN (%s)", query, inClause)
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var services []*CloudRunService
 	for rows.Next() {
-		t := &Topic{}
-		if err := rows.Scan(&t.ID, &t.Name, &t.ProjectID, &t.FullResourceName, &t.Metadata); err != nil {
+		svc := &CloudRunService{}
+		var urls string
+		if err := rows.Scan(&svc.ID, &svc.Name, &svc.ProjectID, &svc.Region, &svc.FullResourceName, &urls, &svc.Metadata); err != nil {
 			return nil, err
 		}
-		topics = append(topics, t)
+		svc.URLs = splitList(urls)
+		services = append(services, svc)
 	}
-	return topics, rows.Err()
+	return services, rows.Err()
+}
+
+// SaveCloudFunctions inserts or updates a batch of Cloud Functions in a single transaction
+func (s *SQLiteStorage) SaveCloudFunctions(ctx context.Context, functions []*CloudFunction) error {
+	if len(functions) == 0 {
+		return nil
+	}
+
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	stmt, err := tx.PrepareContext(ctx, `
+        INSERT OR REPLACE INTO cloud_functions
+        (name, project_id, region, full_resource_name, generation, trigger_topic, event_type, metadata, last_synced)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, `+syncTimestamp+`)`)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = stmt.Close() }()
+
+	for _, fn := range functions {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		if _, err = stmt.ExecContext(ctx,
+			fn.Name,
+			fn.ProjectID,
+			fn.Region,
+			fn.FullResourceName,
+			fn.Generation,
+			fn.TriggerTopic,
+			fn.EventType,
+			fn.Metadata); err != nil {
+			return err
+		}
+	}
+
+	err = tx.Commit()
+	return err
+}
+
+// GetAllCloudFunctions retrieves Cloud Functions for multiple projects
+func (s *SQLiteStorage) GetAllCloudFunctions(ctx context.Context, projects []string) ([]*CloudFunction, error) {
+	query := `SELECT id, name, project_id, region, full_resource_name, generation, trigger_topic, event_type, metadata
+              FROM cloud_functions`
+	var args []interface{}
+	if len(projects) > 0 {
+		var inClause string
+		inClause, args = buildInClause(projects)
+		query = fmt.Sprintf("%s WHERE project_id IN (%s)", query, inClause)
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var functions []*CloudFunction
+	for rows.Next() {
+		fn := &CloudFunction{}
+		if err := rows.Scan(&fn.ID, &fn.Name, &fn.ProjectID, &fn.Region, &fn.FullResourceName,
+			&fn.Generation, &fn.TriggerTopic, &fn.EventType, &fn.Metadata); err != nil {
+			return nil, err
+		}
+		functions = append(functions, fn)
+	}
+	return functions, rows.Err()
+}
+
+// SaveDataflowJobs inserts or updates a batch of Dataflow jobs in a single transaction
+func (s *SQLiteStorage) SaveDataflowJobs(ctx context.Context, jobs []*DataflowJob) error {
+	if len(jobs) == 0 {
+		return nil
+	}
+
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	stmt, err := tx.PrepareContext(ctx, `
+        INSERT OR REPLACE INTO dataflow_jobs
+        (name, project_id, region, full_resource_name, sources, sinks, metadata, last_synced)
+        VALUES (?, ?, ?, ?, ?, ?, ?, `+syncTimestamp+`)`)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = stmt.Close() }()
+
+	for _, job := range jobs {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		if _, err = stmt.ExecContext(ctx,
+			job.Name,
+			job.ProjectID,
+			job.Region,
+			job.FullResourceName,
+			joinList(job.Sources),
+			joinList(job.Sinks),
+			job.Metadata); err != nil {
+			return err
+		}
+	}
+
+	err = tx.Commit()
+	return err
+}
+
+// GetAllDataflowJobs retrieves Dataflow jobs for multiple projects
+func (s *SQLiteStorage) GetAllDataflowJobs(ctx context.Context, projects []string) ([]*DataflowJob, error) {
+	query := `SELECT id, name, project_id, region, full_resource_name, sources, sinks, metadata
+              FROM dataflow_jobs`
+	var args []interface{}
+	if len(projects) > 0 {
+		var inClause string
+		inClause, args = buildInClause(projects)
+		query = fmt.Sprintf("%s WHERE project_id IN (%s)", query, inClause)
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var jobs []*DataflowJob
+	for rows.Next() {
+		job := &DataflowJob{}
+		var sources, sinks string
+		if err := rows.Scan(&job.ID, &job.Name, &job.ProjectID, &job.Region, &job.FullResourceName,
+			&sources, &sinks, &job.Metadata); err != nil {
+			return nil, err
+		}
+		job.Sources = splitList(sources)
+		job.Sinks = splitList(sinks)
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+// SaveResources inserts or updates a batch of generic resources in a single transaction
+func (s *SQLiteStorage) SaveResources(ctx context.Context, resources []*Resource) error {
+	if len(resources) == 0 {
+		return nil
+	}
+	if err := checkGenericKinds(resources); err != nil {
+		return err
+	}
+
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	stmt, err := tx.PrepareContext(ctx, `
+        INSERT OR REPLACE INTO resources
+        (kind, name, project_id, full_resource_name, metadata, last_synced)
+        VALUES (?, ?, ?, ?, ?, `+syncTimestamp+`)`)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = stmt.Close() }()
+
+	for _, r := range resources {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		if _, err = stmt.ExecContext(ctx, r.Kind, r.Name, r.ProjectID, r.FullResourceName, r.Metadata); err != nil {
+			return err
+		}
+	}
+
+	err = tx.Commit()
+	return err
+}
+
+// GetResources retrieves the resources of a kind, or of every kind if kind is empty,
+// for multiple projects, including the topics and subscriptions of the typed tables
+func (s *SQLiteStorage) GetResources(ctx context.Context, kind string, projects []string) ([]*Resource, error) {
+	query := `SELECT id, kind, name, project_id, full_resource_name, metadata
+              FROM all_resources`
+	var conditions []string
+	var args []interface{}
+	if kind != "" {
+		conditions = append(conditions, "kind = ?")
+		args = append(args, kind)
+	}
+	if len(projects) > 0 {
+		inClause, inArgs := buildInClause(projects)
+		conditions = append(conditions, fmt.Sprintf("project_id IN (%s)", inClause))
+		args = append(args, inArgs...)
+	}
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY kind, full_resource_name"
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var resources []*Resource
+	for rows.Next() {
+		r := &Resource{}
+		var metadata sql.NullString
+		if err := rows.Scan(&r.ID, &r.Kind, &r.Name, &r.ProjectID, &r.FullResourceName, &metadata); err != nil {
+			return nil, err
+		}
+		r.Metadata = metadata.String
+		resources = append(resources, r)
+	}
+	return resources, rows.Err()
+}
+
+// SaveEdges inserts or refreshes a batch of edges between generic resources in a single transaction
+func (s *SQLiteStorage) SaveEdges(ctx context.Context, edges []*ResourceEdge) error {
+	if len(edges) == 0 {
+		return nil
+	}
+
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	stmt, err := tx.PrepareContext(ctx, `
+        INSERT OR REPLACE INTO edges
+        (source, target, relation, project_id, last_synced)
+        VALUES (?, ?, ?, ?, `+syncTimestamp+`)`)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = stmt.Close() }()
+
+	for _, e := range edges {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		if _, err = stmt.ExecContext(ctx, e.Source, e.Target, e.Relation, e.ProjectID); err != nil {
+			return err
+		}
+	}
+
+	err = tx.Commit()
+	return err
+}
+
+// GetEdges retrieves the edges seen by scans of multiple projects, including
+// an edge from each subscription of the typed table to its topic
+func (s *SQLiteStorage) GetEdges(ctx context.Context, projects []string) ([]*ResourceEdge, error) {
+	query := `SELECT id, source, target, relation, project_id
+              FROM all_edges`
+	var args []interface{}
+	if len(projects) > 0 {
+		var inClause string
+		inClause, args = buildInClause(projects)
+		query = fmt.Sprintf("%s WHERE project_id IN (%s)", query, inClause)
+	}
+	query += " ORDER BY source, target, relation"
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var edges []*ResourceEdge
+	for rows.Next() {
+		e := &ResourceEdge{}
+		if err := rows.Scan(&e.ID, &e.Source, &e.Target, &e.Relation, &e.ProjectID); err != nil {
+			return nil, err
+		}
+		edges = append(edges, e)
+	}
+	return edges, rows.Err()
+}
+
+// SaveMetrics inserts or refreshes a batch of metrics in a single transaction
+func (s *SQLiteStorage) SaveMetrics(ctx context.Context, metrics []*ResourceMetric) error {
+	if len(metrics) == 0 {
+		return nil
+	}
+
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	stmt, err := tx.PrepareContext(ctx, `
+        INSERT OR REPLACE INTO resource_metrics
+        (full_resource_name, project_id, metric, value, window_seconds, last_synced)
+        VALUES (?, ?, ?, ?, ?, `+syncTimestamp+`)`)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = stmt.Close() }()
+
+	for _, m := range metrics {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		if _, err = stmt.ExecContext(ctx, m.FullResourceName, m.ProjectID, m.Metric, m.Value,
+			int64(m.Window/time.Second)); err != nil {
+			return err
+		}
+	}
+
+	err = tx.Commit()
+	return err
+}
+
+// GetMetrics retrieves the metrics of multiple projects, all of them if projects is empty
+func (s *SQLiteStorage) GetMetrics(ctx context.Context, projects []string) ([]*ResourceMetric, error) {
+	query := `SELECT id, full_resource_name, project_id, metric, value, window_seconds
+              FROM resource_metrics`
+	var args []interface{}
+	if len(projects) > 0 {
+		var inClause string
+		inClause, args = buildInClause(projects)
+		query = fmt.Sprintf("%s WHERE project_id IN (%s)", query, inClause)
+	}
+	query += " ORDER BY full_resource_name, metric"
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var metrics []*ResourceMetric
+	for rows.Next() {
+		m := &ResourceMetric{}
+		var windowSeconds int64
+		if err := rows.Scan(&m.ID, &m.FullResourceName, &m.ProjectID, &m.Metric, &m.Value, &windowSeconds); err != nil {
+			return nil, err
+		}
+		m.Window = time.Duration(windowSeconds) * time.Second
+		metrics = append(metrics, m)
+	}
+	return metrics, rows.Err()
+}
+
+// SaveMetricPoints inserts or replaces a batch of datapoints in a single transaction
+func (s *SQLiteStorage) SaveMetricPoints(ctx context.Context, points []*MetricPoint) error {
+	if len(points) == 0 {
+		return nil
+	}
+
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	stmt, err := tx.PrepareContext(ctx, `
+        INSERT OR REPLACE INTO metric_points
+        (full_resource_name, project_id, metric, start_time, value)
+        VALUES (?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = stmt.Close() }()
+
+	for _, p := range points {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		if _, err = stmt.ExecContext(ctx, p.FullResourceName, p.ProjectID, p.Metric,
+			p.Start.UTC().Format(syncTimestampLayout), p.Value); err != nil {
+			return err
+		}
+	}
+
+	err = tx.Commit()
+	return err
+}
+
+// GetMetricPoints retrieves the datapoints of a project starting at or after since, oldest first
+func (s *SQLiteStorage) GetMetricPoints(ctx context.Context, projectID string, since time.Time) ([]*MetricPoint, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT id, full_resource_name, project_id, metric, start_time, value
+        FROM metric_points WHERE project_id = ? AND start_time >= ?
+        ORDER BY start_time, full_resource_name, metric`, projectID, since.UTC().Format(syncTimestampLayout))
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var points []*MetricPoint
+	for rows.Next() {
+		p := &MetricPoint{}
+		if err := rows.Scan(&p.ID, &p.FullResourceName, &p.ProjectID, &p.Metric, &p.Start, &p.Value); err != nil {
+			return nil, err
+		}
+		p.Start = p.Start.UTC()
+		points = append(points, p)
+	}
+	return points, rows.Err()
+}
+
+// DeleteMetricPoints removes the datapoints of a project that start before before
+func (s *SQLiteStorage) DeleteMetricPoints(ctx context.Context, projectID string, before time.Time) error {
+	_, err := s.exec(ctx, "DELETE FROM metric_points WHERE project_id = ? AND start_time < ?",
+		projectID, before.UTC().Format(syncTimestampLayout))
+	return err
+}
+
+// staleKindColumns are the columns Stale.Kind matches, keyed by table
+var staleKindColumns = map[string]string{
+	TableDestinations:    "destination_type",
+	TableResources:       "kind",
+	TableEdges:           "relation",
+	TableResourceMetrics: "metric",
+}
+
+// checkStale returns an error for the tables DeleteStaleResources doesn't prune, or can't narrow down by kind
+func checkStale(stale []Stale) error {
+	for _, st := range stale {
+		switch st.Table {
+		case TableTopics, TableSubscriptions, TableCloudRunServices, TableCloudFunctions, TableDataflowJobs:
+			if st.Kind != "" {
+				return fmt.Errorf("stale %s can't be selected by kind", st.Table)
+			}
+		case TableDestinations, TableResources, TableEdges, TableResourceMetrics:
+		default:
+			return fmt.Errorf("unknown table %q to remove stale resources from", st.Table)
+		}
+	}
+	return nil
+}
+
+// checkGenericKinds refuses generic resources of the kinds kept in typed tables
+func checkGenericKinds(resources []*Resource) error {
+	for _, r := range resources {
+		switch r.Kind {
+		case "":
+			return fmt.Errorf("resource %s has no kind", r.FullResourceName)
+		case ResourceKindTopic, ResourceKindSubscription:
+			return fmt.Errorf("resource %s: %s resources are saved with their typed methods", r.FullResourceName, r.Kind)
+		}
+	}
+	return nil
+}
+
+// SaveSubscriptionConsumer inserts or refreshes a single consumer
+func (s *SQLiteStorage) SaveSubscriptionConsumer(ctx context.Context, consumer *SubscriptionConsumer) error {
+	query := `
+        INSERT OR REPLACE INTO subscription_consumers
+        (subscription_full_resource_name, project_id, principal, source, role, last_seen)
+        VALUES (?, ?, ?, ?, ?, CURRENT_TIMESTAMP)`
+
+	_, err := s.exec(ctx, query,
+		consumer.SubscriptionFullResourceName,
+		consumer.ProjectID,
+		consumer.Principal,
+		consumer.Source,
+		consumer.Role)
+	return err
+}
+
+// ReplaceSubscriptionConsumers replaces every consumer of a subscription from the given
+// source, so bindings that have been removed don't linger in the cache
+func (s *SQLiteStorage) ReplaceSubscriptionConsumers(ctx context.Context, subscriptionFullResourceName, source string, consumers []*SubscriptionConsumer) error {
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	deleteQuery := `DELETE FROM subscription_consumers WHERE subscription_full_resource_name = ? AND source = ?`
+	if _, err = tx.ExecContext(ctx, deleteQuery, subscriptionFullResourceName, source); err != nil {
+		return err
+	}
+
+	insertQuery := `
+        INSERT OR REPLACE INTO subscription_consumers
+        (subscription_full_resource_name, project_id, principal, source, role, last_seen)
+        VALUES (?, ?, ?, ?, ?, CURRENT_TIMESTAMP)`
+	for _, consumer := range consumers {
+		if _, err = tx.ExecContext(ctx, insertQuery,
+			subscriptionFullResourceName,
+			consumer.ProjectID,
+			consumer.Principal,
+			source,
+			consumer.Role); err != nil {
+			return err
+		}
+	}
+
+	err = tx.Commit()
+	return err
+}
+
+// GetAllSubscriptionConsumers retrieves subscription consumers for multiple projects
+func (s *SQLiteStorage) GetAllSubscriptionConsumers(ctx context.Context, projects []string) ([]*SubscriptionConsumer, error) {
+	query := `SELECT id, subscription_full_resource_name, project_id, principal, source, role
+              FROM subscription_consumers`
+	var args []interface{}
+	if len(projects) > 0 {
+		var inClause string
+		inClause, args = buildInClause(projects)
+		query = fmt.Sprintf("%s WHERE project_id IN (%s)", query, inClause)
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var consumers []*SubscriptionConsumer
+	for rows.Next() {
+		c := &SubscriptionConsumer{}
+		if err := rows.Scan(&c.ID, &c.SubscriptionFullResourceName, &c.ProjectID, &c.Principal, &c.Source, &c.Role); err != nil {
+			return nil, err
+		}
+		consumers = append(consumers, c)
+	}
+	return consumers, rows.Err()
+}
+
+// GetAllProjects returns all unique project IDs from the database
+func (s *SQLiteStorage) GetAllProjects(ctx context.Context) ([]string, error) {
+	query := `SELECT DISTINCT project_id FROM projects ORDER BY project_id`
+
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var projects []string
+	for rows.Next() {
+		var projectID string
+		if err := rows.Scan(&projectID); err != nil {
+			return nil, err
+		}
+		projects = append(projects, projectID)
+	}
+	return projects, rows.Err()
+}
+
+// GetProjectSyncTimes returns the last sync time of every cached project
+func (s *SQLiteStorage) GetProjectSyncTimes(ctx context.Context) (map[string]time.Time, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT project_id, last_synced FROM projects`)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	syncTimes := make(map[string]time.Time)
+	for rows.Next() {
+		var projectID string
+		var lastSynced time.Time
+		if err := rows.Scan(&projectID, &lastSynced); err != nil {
+			return nil, err
+		}
+		syncTimes[projectID] = lastSynced
+	}
+	return syncTimes, rows.Err()
+}
+
+// UpdateProjectSyncTime updates or inserts the last sync time for a project,
+// and records the completed sync in the project's sync history
+func (s *SQLiteStorage) UpdateProjectSyncTime(ctx context.Context, projectID string) error {
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if _, err = tx.ExecContext(ctx, `
+        INSERT INTO projects (project_id, last_synced, status)
+        VALUES (?, CURRENT_TIMESTAMP, '')
+        ON CONFLICT(project_id) DO UPDATE SET last_synced = excluded.last_synced, status = ''`, projectID); err != nil {
+		return err
+	}
+	if _, err = tx.ExecContext(ctx, `
+        INSERT INTO project_syncs (project_id, synced_at)
+        VALUES (?, CURRENT_TIMESTAMP)`, projectID); err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	return err
+}
+
+// SetProjectStatus records why a project couldn't be collected, e.g. ProjectStatusAPIDisabled.
+// The sync time of a known project is kept, a new one is added as synced now.
+func (s *SQLiteStorage) SetProjectStatus(ctx context.Context, projectID, status string) error {
+	_, err := s.exec(ctx, `
+        INSERT INTO projects (project_id, last_synced, status)
+        VALUES (?, CURRENT_TIMESTAMP, ?)
+        ON CONFLICT(project_id) DO UPDATE SET status = excluded.status`, projectID, status)
+	return err
+}
+
+// GetProjectStatuses returns the status of every project that has one
+func (s *SQLiteStorage) GetProjectStatuses(ctx context.Context) (map[string]string, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT project_id, status FROM projects WHERE status != ''`)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	statuses := make(map[string]string)
+	for rows.Next() {
+		var projectID, status string
+		if err := rows.Scan(&projectID, &status); err != nil {
+			return nil, err
+		}
+		statuses[projectID] = status
+	}
+	return statuses, rows.Err()
+}
+
+// SetProjectLabels replaces the labels of a project, as collected from the Resource Manager API.
+// The sync time of a known project is kept, a new one is added as synced now.
+func (s *SQLiteStorage) SetProjectLabels(ctx context.Context, projectID string, labels map[string]string) error {
+	encoded := ""
+	if len(labels) > 0 {
+		data, err := json.Marshal(labels)
+		if err != nil {
+			return err
+		}
+		encoded = string(data)
+	}
+	_, err := s.exec(ctx, `
+        INSERT INTO projects (project_id, last_synced, labels)
+        VALUES (?, CURRENT_TIMESTAMP, ?)
+        ON CONFLICT(project_id) DO UPDATE SET labels = excluded.labels`, projectID, encoded)
+	return err
+}
+
+// GetProjectLabels returns the labels of every project that has any
+func (s *SQLiteStorage) GetProjectLabels(ctx context.Context) (map[string]map[string]string, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT project_id, labels FROM projects WHERE labels != ''`)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	labels := make(map[string]map[string]string)
+	for rows.Next() {
+		var projectID, encoded string
+		if err := rows.Scan(&projectID, &encoded); err != nil {
+			return nil, err
+		}
+		var projectLabels map[string]string
+		if err := json.Unmarshal([]byte(encoded), &projectLabels); err != nil {
+			return nil, fmt.Errorf("invalid labels of project %s: %w", projectID, err)
+		}
+		labels[projectID] = projectLabels
+	}
+	return labels, rows.Err()
+}
+
+// GetProjectSyncHistory returns the completed syncs of every project at or after since, oldest first
+func (s *SQLiteStorage) GetProjectSyncHistory(ctx context.Context, since time.Time) (map[string][]time.Time, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT project_id, synced_at FROM project_syncs
+        WHERE synced_at >= ? ORDER BY synced_at`, since.UTC().Format(time.DateTime))
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	history := make(map[string][]time.Time)
+	for rows.Next() {
+		var projectID string
+		var syncedAt time.Time
+		if err := rows.Scan(&projectID, &syncedAt); err != nil {
+			return nil, err
+		}
+		history[projectID] = append(history[projectID], syncedAt)
+	}
+	return history, rows.Err()
+}
+
+// ensureProjects makes sure every distinct project exists in the projects table
+func ensureProjects(ctx context.Context, tx *writeTx, projects []string) error {
+	seen := make(map[string]bool, len(projects))
+	for _, project := range projects {
+		if seen[project] {
+			continue
+		}
+		seen[project] = true
+
+		if _, err := tx.ExecContext(ctx, `
+        INSERT INTO projects (project_id, last_synced)
+        VALUES (?, CURRENT_TIMESTAMP)
+        ON CONFLICT(project_id) DO UPDATE SET last_synced = excluded.last_synced`, project); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// topicColumns are the topics columns read by scanTopics
+const topicColumns = `id, name, project_id, full_resource_name, metadata,
+       message_retention_seconds, kms_key_name, storage_regions`
+
+// subscriptionColumns are the subscriptions columns read by scanSubscriptions
+const subscriptionColumns = `id, name, project_id, topic_full_resource_name, full_resource_name, filter, metadata`
+
+// Helper function to scan topics from rows
+func scanTopics(rows interface {
+	Next() bool
+	Scan(...interface{}) error
+	Err() error
+}) ([]*Topic, error) {
+	var topics []*Topic
+	for rows.Next() {
+		t := &Topic{}
+		var retention int64
+		var regions string
+		if err := rows.Scan(&t.ID, &t.Name, &t.ProjectID, &t.FullResourceName, &t.Metadata,
+			&retention, &t.KMSKeyName, &regions); err != nil {
+			return nil, err
+		}
+		t.MessageRetention = time.Duration(retention) * time.Second
+		t.StorageRegions = splitList(regions)
+		topics = append(topics, t)
+	}
+	return topics, rows.Err()
+}
+
+// joinList stores a list column, such as a topic's storage regions, as sorted
+// comma-separated values
+func joinList(values []string) string {
+	sorted := append([]string(nil), values...)
+	sort.Strings(sorted)
+	return strings.Join(sorted, ",")
+}
+
+// splitList reads values stored by joinList, nil if there are none
+func splitList(values string) []string {
+	if values == "" {
+		return nil
+	}
+	return strings.Split(values, ",")
 }
 
 // Helper function to scan subscriptions from rows
@@ -239,7 +1429,7 @@ func scanSubscriptions(rows interface {
 	var subscriptions []*Subscription
 	for rows.Next() {
 		s := &Subscription{}
-		if err := rows.Scan(&s.ID, &s.Name, &s.ProjectID, &s.TopicFullResourceName, &s.FullResourceName, &s.Metadata); err != nil {
+		if err := rows.Scan(&s.ID, &s.Name, &s.ProjectID, &s.TopicFullResourceName, &s.FullResourceName, &s.Filter, &s.Metadata); err != nil {
 			return nil, err
 		}
 		subscriptions = append(subscriptions, s)
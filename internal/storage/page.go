@@ -0,0 +1,141 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Sort orders of paginated topic and subscription reads
+const (
+	SortFullName = ""        // by full resource name, the default
+	SortName     = "name"    // by short name
+	SortProject  = "project" // by project, then short name
+)
+
+// PageSize is how many topics or subscriptions EachTopic and EachSubscription read at a time
+const PageSize = 1000
+
+// Page selects a sorted slice of a topic or subscription listing. Ties are broken by full
+// resource name, so consecutive pages neither repeat nor skip resources unless the cache is
+// written in between.
+type Page struct {
+	Sort   string // SortFullName, SortName or SortProject
+	Desc   bool   // descending instead of ascending
+	Limit  int    // at most this many, all of them if below 1
+	Offset int    // skipped ahead of the first
+}
+
+// Validate checks the sort order of p
+func (p Page) Validate() error {
+	switch p.Sort {
+	case SortFullName, SortName, SortProject:
+		return nil
+	default:
+		return fmt.Errorf("unknown sort %q, expected %q or %q", p.Sort, SortName, SortProject)
+	}
+}
+
+// orderBy returns the ORDER BY, LIMIT and OFFSET clauses of p for the topics or subscriptions table
+func (p Page) orderBy() (string, []interface{}) {
+	columns := []string{"full_resource_name"}
+	switch p.Sort {
+	case SortName:
+		columns = []string{"name", "full_resource_name"}
+	case SortProject:
+		columns = []string{"project_id", "name", "full_resource_name"}
+	}
+	if p.Desc {
+		for i := range columns {
+			columns[i] += " DESC"
+		}
+	}
+	clause := ` ORDER BY ` + strings.Join(columns, ", ")
+
+	if p.Limit < 1 && p.Offset < 1 {
+		return clause, nil
+	}
+	limit := p.Limit
+	if limit < 1 {
+		limit = -1 // SQLite requires a LIMIT ahead of OFFSET, -1 has none
+	}
+	return clause + ` LIMIT ? OFFSET ?`, []interface{}{limit, max(p.Offset, 0)}
+}
+
+// pageOf sorts items the way orderBy does and returns the page of them
+func pageOf[T any](p Page, items []T, key func(T) (name, project, fullName string)) []T {
+	sort.Slice(items, func(i, j int) bool {
+		ni, pi, fi := key(items[i])
+		nj, pj, fj := key(items[j])
+		a := []string{fi}
+		b := []string{fj}
+		switch p.Sort {
+		case SortName:
+			a, b = []string{ni, fi}, []string{nj, fj}
+		case SortProject:
+			a, b = []string{pi, ni, fi}, []string{pj, nj, fj}
+		}
+		if p.Desc {
+			a, b = b, a
+		}
+		for k := range a {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return false
+	})
+
+	start := min(max(p.Offset, 0), len(items))
+	items = items[start:]
+	if p.Limit > 0 && p.Limit < len(items) {
+		items = items[:p.Limit]
+	}
+	return items
+}
+
+// EachTopic calls fn with the topics matching q in full resource name order, reading PageSize
+// of them at a time so a whole organization's topics are never in memory at once
+func EachTopic(ctx context.Context, store Store, q TopicQuery, fn func(*Topic) error) error {
+	for offset := 0; ; offset += PageSize {
+		// Not every backend checks ctx on reads
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		topics, err := store.ListTopics(ctx, q, Page{Limit: PageSize, Offset: offset})
+		if err != nil {
+			return err
+		}
+		for _, t := range topics {
+			if err := fn(t); err != nil {
+				return err
+			}
+		}
+		if len(topics) < PageSize {
+			return nil
+		}
+	}
+}
+
+// EachSubscription calls fn with the subscriptions of the given projects, all of them if projects
+// is empty, in full resource name order, reading PageSize of them at a time
+func EachSubscription(ctx context.Context, store Store, projects []string, fn func(*Subscription) error) error {
+	for offset := 0; ; offset += PageSize {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		subs, err := store.ListSubscriptions(ctx, projects, Page{Limit: PageSize, Offset: offset})
+		if err != nil {
+			return err
+		}
+		for _, sub := range subs {
+			if err := fn(sub); err != nil {
+				return err
+			}
+		}
+		if len(subs) < PageSize {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+const scanRunColumns = `id, run_id, started_at, finished_at, projects_attempted, projects_succeeded,
+        projects_failed, projects_skipped, projects_interrupted, topics, subscriptions, api_calls, version`
+
+// SaveScanRun records a finished scan
+func (s *SQLiteStorage) SaveScanRun(ctx context.Context, run *ScanRun) error {
+	_, err := s.exec(ctx, `
+        INSERT INTO scan_runs
+        (run_id, started_at, finished_at, projects_attempted, projects_succeeded,
+         projects_failed, projects_skipped, projects_interrupted, topics, subscriptions, api_calls, version)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		run.RunID, run.StartedAt.UTC().Format(syncTimestampLayout), run.FinishedAt.UTC().Format(syncTimestampLayout),
+		run.ProjectsAttempted, run.ProjectsSucceeded, run.ProjectsFailed, run.ProjectsSkipped, run.ProjectsInterrupted,
+		run.Topics, run.Subscriptions, run.APICalls, run.Version)
+	return err
+}
+
+// GetScanRuns returns the latest scans, newest first. A limit below 1 returns every scan.
+func (s *SQLiteStorage) GetScanRuns(ctx context.Context, limit int) ([]*ScanRun, error) {
+	query := `SELECT ` + scanRunColumns + ` FROM scan_runs ORDER BY started_at DESC, id DESC`
+	var args []interface{}
+	if limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, limit)
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var runs []*ScanRun
+	for rows.Next() {
+		run, err := scanScanRun(rows)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
+
+// GetScanRun returns a single scan, or ErrScanRunNotFound
+func (s *SQLiteStorage) GetScanRun(ctx context.Context, runID string) (*ScanRun, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT `+scanRunColumns+` FROM scan_runs WHERE run_id = ?`, runID)
+	run, err := scanScanRun(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrScanRunNotFound
+	}
+	return run, err
+}
+
+// scanScanRun reads a row of scanRunColumns
+func scanScanRun(row interface{ Scan(dest ...any) error }) (*ScanRun, error) {
+	run := &ScanRun{}
+	err := row.Scan(&run.ID, &run.RunID, &run.StartedAt, &run.FinishedAt, &run.ProjectsAttempted, &run.ProjectsSucceeded,
+		&run.ProjectsFailed, &run.ProjectsSkipped, &run.ProjectsInterrupted, &run.Topics, &run.Subscriptions, &run.APICalls, &run.Version)
+	if err != nil {
+		return nil, err
+	}
+	return run, nil
+}
@@ -2,51 +2,113 @@ package storage
 
 import (
 	"database/sql"
+	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
+	"time"
 
 	_ "modernc.org/sqlite"
 )
 
+// DefaultBusyTimeout is how long a SQLite write waits for a lock held by another process
+const DefaultBusyTimeout = 5 * time.Second
+
+// SQLiteOptions tunes the connections of a SQLite cache
+type SQLiteOptions struct {
+	// BusyTimeout is how long a write waits for a lock held by another process, e.g. a
+	// concurrent scan, before failing with SQLITE_BUSY. Zero fails at once.
+	BusyTimeout time.Duration
+}
+
+// SQLiteStorage reads through a pool of connections and writes through a single one.
+// SQLite serializes writers anyway; queueing them on one connection means concurrent
+// collections wait their turn, bounded by their context, instead of failing with SQLITE_BUSY.
 type SQLiteStorage struct {
-	db *sql.DB
+	db     *sql.DB // readers
+	writer *sql.DB // the single writer, db itself for ":memory:"
 }
 
 // NewSQLite creates a new SQLite storage backend
-// For production: uses /tmp/gcp-visualizer/cache.db
+// For production: uses cache.db in DefaultDir
 // For testing: use ":memory:" as dbPath
 func NewSQLite(dbPath string) (*SQLiteStorage, error) {
-	// Create directory for file-based databases
-	if dbPath != ":memory:" {
-		dir := filepath.Dir(dbPath)
-		if err := os.MkdirAll(dir, 0755); err != nil {
+	return NewSQLiteWithOptions(dbPath, SQLiteOptions{BusyTimeout: DefaultBusyTimeout})
+}
+
+// NewSQLiteWithOptions creates a new SQLite storage backend with the given options
+func NewSQLiteWithOptions(dbPath string, opts SQLiteOptions) (*SQLiteStorage, error) {
+	if dbPath == ":memory:" {
+		db, err := sql.Open("sqlite", dbPath)
+		if err != nil {
 			return nil, err
 		}
+		// Every connection to ":memory:" opens a separate database, so share a single one
+		db.SetMaxOpenConns(1)
+		s := &SQLiteStorage{db: db, writer: db}
+		return s, s.migrate()
 	}
 
-	db, err := sql.Open("sqlite", dbPath)
-	if err != nil {
+	// Create directory for file-based databases
+	dir := filepath.Dir(dbPath)
+	if err := os.MkdirAll(dir, 0755); err != nil {
 		return nil, err
 	}
 
-	// Set pragmas for performance
+	// Writers wait for a lock held by another process instead of failing with SQLITE_BUSY,
+	// and transactions take the write lock up front so they can't deadlock on upgrade.
+	// Pragmas in the DSN apply to every connection of the pools.
+	dsn := fmt.Sprintf("%s?_pragma=busy_timeout(%d)&_pragma=synchronous(NORMAL)&_txlock=immediate",
+		dbPath, opts.BusyTimeout.Milliseconds())
+
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	// WAL lets readers run alongside the writer
 	if _, err := db.Exec("PRAGMA journal_mode=WAL"); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	writer, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		_ = db.Close()
 		return nil, err
 	}
-	if _, err := db.Exec("PRAGMA synchronous=NORMAL"); err != nil {
+	writer.SetMaxOpenConns(1)
+	// Keep the connections open, each one opened again re-reads the schema and loses its page cache
+	writer.SetMaxIdleConns(1)
+	writer.SetConnMaxIdleTime(0)
+	db.SetMaxOpenConns(readers())
+	db.SetMaxIdleConns(readers())
+
+	s := &SQLiteStorage{db: db, writer: writer}
+	if err := s.migrate(); err != nil {
+		_ = s.Close()
 		return nil, err
 	}
+	return s, nil
+}
 
-	s := &SQLiteStorage{db: db}
-	return s, s.migrate()
+// readers returns the size of the reader pool, one connection per CPU and at least four.
+// SQLite reads in WAL mode don't block each other, more connections than CPUs only contend.
+func readers() int {
+	return max(4, runtime.NumCPU())
 }
 
-// NewDefaultSQLite creates storage in /tmp/gcp-visualizer/
+// NewDefaultSQLite creates storage in DefaultDir
 func NewDefaultSQLite() (*SQLiteStorage, error) {
-	dbPath := filepath.Join("/tmp", "gcp-visualizer", "cache.db")
+	dbPath := filepath.Join(DefaultDir(), "cache.db")
 	return NewSQLite(dbPath)
 }
 
 func (s *SQLiteStorage) Close() error {
-	return s.db.Close()
+	err := s.db.Close()
+	if s.writer != s.db {
+		if werr := s.writer.Close(); err == nil {
+			err = werr
+		}
+	}
+	return err
 }
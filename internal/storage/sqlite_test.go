@@ -1,8 +1,20 @@
 package storage
 
 import (
+	"bytes"
 	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -46,6 +58,7 @@ func TestSaveSubscription(t *testing.T) {
 		ProjectID:             "test-project",
 		TopicFullResourceName: "projects/test-project/topics/test-topic",
 		FullResourceName:      "projects/test-project/subscriptions/test-sub",
+		Filter:                `attributes.kind = "email"`,
 	}
 
 	err := store.SaveSubscription(ctx, sub)
@@ -56,6 +69,7 @@ func TestSaveSubscription(t *testing.T) {
 	assert.Len(t, subs, 1)
 	assert.Equal(t, "test-sub", subs[0].Name)
 	assert.Equal(t, "projects/test-project/topics/test-topic", subs[0].TopicFullResourceName)
+	assert.Equal(t, `attributes.kind = "email"`, subs[0].Filter)
 }
 
 func TestGetAllTopics(t *testing.T) {
@@ -150,6 +164,20 @@ func TestUpdateProjectSyncTime(t *testing.T) {
 	projects, err := store.GetAllProjects(ctx)
 	require.NoError(t, err)
 	assert.Contains(t, projects, "test-project")
+
+	syncTimes, err := store.GetProjectSyncTimes(ctx)
+	require.NoError(t, err)
+	require.Contains(t, syncTimes, "test-project")
+	assert.WithinDuration(t, time.Now(), syncTimes["test-project"], time.Minute)
+
+	// Every completed sync is kept in the history
+	history, err := store.GetProjectSyncHistory(ctx, time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Len(t, history["test-project"], 2)
+
+	history, err = store.GetProjectSyncHistory(ctx, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	assert.Empty(t, history)
 }
 
 func TestCrossProjectSubscription(t *testing.T) {
@@ -205,3 +233,1478 @@ func TestUpsertTopic(t *testing.T) {
 	assert.Len(t, topics, 1)
 	assert.Equal(t, `{"version": 2}`, topics[0].Metadata)
 }
+
+func TestSaveAndGetSubscriptionDestinations(t *testing.T) {
+	store := setupTestStorage(t)
+	ctx := context.Background()
+
+	bq := &SubscriptionDestination{
+		SubscriptionFullResourceName: "projects/project-a/subscriptions/bq-sub",
+		ProjectID:                    "project-a",
+		Type:                         DestinationTypeBigQuery,
+		Resource:                     "project-a.dataset.table",
+		Metadata:                     "{}",
+	}
+	gcs := &SubscriptionDestination{
+		SubscriptionFullResourceName: "projects/project-b/subscriptions/gcs-sub",
+		ProjectID:                    "project-b",
+		Type:                         DestinationTypeCloudStorage,
+		Resource:                     "my-bucket",
+		Metadata:                     "{}",
+	}
+
+	require.NoError(t, store.SaveSubscriptionDestination(ctx, bq))
+	require.NoError(t, store.SaveSubscriptionDestination(ctx, gcs))
+
+	// Saving again should update, not duplicate
+	bq.Resource = "project-a.dataset.other_table"
+	require.NoError(t, store.SaveSubscriptionDestination(ctx, bq))
+
+	all, err := store.GetAllSubscriptionDestinations(ctx, nil)
+	require.NoError(t, err)
+	assert.Len(t, all, 2)
+
+	filtered, err := store.GetAllSubscriptionDestinations(ctx, []string{"project-a"})
+	require.NoError(t, err)
+	require.Len(t, filtered, 1)
+	assert.Equal(t, DestinationTypeBigQuery, filtered[0].Type)
+	assert.Equal(t, "project-a.dataset.other_table", filtered[0].Resource)
+}
+
+func TestDeleteTopicAndSubscription(t *testing.T) {
+	store := setupTestStorage(t)
+	ctx := context.Background()
+
+	require.NoError(t, store.SaveTopic(ctx, &Topic{
+		Name:             "topic",
+		ProjectID:        "p",
+		FullResourceName: "projects/p/topics/topic",
+	}))
+	require.NoError(t, store.SaveSubscription(ctx, &Subscription{
+		Name:                  "sub",
+		ProjectID:             "p",
+		TopicFullResourceName: "projects/p/topics/topic",
+		FullResourceName:      "projects/p/subscriptions/sub",
+	}))
+	require.NoError(t, store.SaveSubscriptionDestination(ctx, &SubscriptionDestination{
+		SubscriptionFullResourceName: "projects/p/subscriptions/sub",
+		ProjectID:                    "p",
+		Type:                         DestinationTypeCloudStorage,
+		Resource:                     "bucket",
+	}))
+
+	require.NoError(t, store.DeleteTopic(ctx, "projects/p/topics/topic"))
+	require.NoError(t, store.DeleteSubscription(ctx, "projects/p/subscriptions/sub"))
+
+	topics, err := store.GetTopics(ctx, "p")
+	require.NoError(t, err)
+	assert.Empty(t, topics)
+
+	subs, err := store.GetSubscriptions(ctx, "p")
+	require.NoError(t, err)
+	assert.Empty(t, subs)
+
+	dests, err := store.GetAllSubscriptionDestinations(ctx, nil)
+	require.NoError(t, err)
+	assert.Empty(t, dests)
+
+	// Deleting something that doesn't exist is not an error
+	assert.NoError(t, store.DeleteTopic(ctx, "projects/p/topics/missing"))
+}
+
+func TestSubscriptionConsumers(t *testing.T) {
+	store := setupTestStorage(t)
+	ctx := context.Background()
+	subName := "projects/p/subscriptions/sub"
+
+	require.NoError(t, store.ReplaceSubscriptionConsumers(ctx, subName, ConsumerSourceIAM, []*SubscriptionConsumer{
+		{ProjectID: "p", Principal: "serviceAccount:old@p.iam.gserviceaccount.com", Role: "roles/pubsub.subscriber"},
+	}))
+	require.NoError(t, store.SaveSubscriptionConsumer(ctx, &SubscriptionConsumer{
+		SubscriptionFullResourceName: subName,
+		ProjectID:                    "p",
+		Principal:                    "serviceAccount:app@p.iam.gserviceaccount.com",
+		Source:                       ConsumerSourceAuditLog,
+		Role:                         "google.pubsub.v1.Subscriber.Pull",
+	}))
+
+	// Replacing IAM consumers drops removed bindings but keeps audit log evidence
+	require.NoError(t, store.ReplaceSubscriptionConsumers(ctx, subName, ConsumerSourceIAM, []*SubscriptionConsumer{
+		{ProjectID: "p", Principal: "serviceAccount:app@p.iam.gserviceaccount.com", Role: "roles/pubsub.subscriber"},
+	}))
+
+	consumers, err := store.GetAllSubscriptionConsumers(ctx, []string{"p"})
+	require.NoError(t, err)
+	require.Len(t, consumers, 2)
+	for _, c := range consumers {
+		assert.Equal(t, "serviceAccount:app@p.iam.gserviceaccount.com", c.Principal)
+		assert.Equal(t, subName, c.SubscriptionFullResourceName)
+	}
+
+	require.NoError(t, store.DeleteSubscription(ctx, subName))
+	consumers, err = store.GetAllSubscriptionConsumers(ctx, nil)
+	require.NoError(t, err)
+	assert.Empty(t, consumers)
+}
+
+func TestConcurrentWrites(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "cache.db")
+	store, err := NewSQLite(dbPath)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = store.Close() })
+	ctx := context.Background()
+
+	const writers = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			name := fmt.Sprintf("topic-%d", i)
+			errs <- store.SaveTopic(ctx, &Topic{
+				Name:             name,
+				ProjectID:        "p",
+				FullResourceName: "projects/p/topics/" + name,
+			})
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	topics, err := store.GetTopics(ctx, "p")
+	require.NoError(t, err)
+	assert.Len(t, topics, writers)
+}
+
+func TestConcurrentWrites_SingleWriter(t *testing.T) {
+	// Without a busy timeout, writers contending for the lock would fail with SQLITE_BUSY
+	dbPath := filepath.Join(t.TempDir(), "cache.db")
+	store, err := NewSQLiteWithOptions(dbPath, SQLiteOptions{})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = store.Close() })
+	ctx := context.Background()
+
+	const writers = 40
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*writers)
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			name := fmt.Sprintf("topic-%d", i)
+			errs <- store.SaveTopics(ctx, []*Topic{{Name: name, ProjectID: "p", FullResourceName: "projects/p/topics/" + name}})
+			_, err := store.GetTopics(ctx, "p")
+			errs <- err
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	// A queued writer gives up with its context
+	tx, err := store.writer.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	cancelled, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, store.SaveTopic(cancelled, &Topic{Name: "late", ProjectID: "p", FullResourceName: "projects/p/topics/late"}), context.DeadlineExceeded)
+	require.NoError(t, tx.Rollback())
+}
+
+func TestBusyTimeout(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "cache.db")
+	holder, err := NewSQLite(dbPath)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = holder.Close() })
+	ctx := context.Background()
+	topic := &Topic{Name: "orders", ProjectID: "p", FullResourceName: "projects/p/topics/orders"}
+
+	// Another process holding the write lock
+	tx, err := holder.writer.BeginTx(ctx, nil)
+	require.NoError(t, err)
+
+	impatient, err := NewSQLiteWithOptions(dbPath, SQLiteOptions{})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = impatient.Close() })
+	require.Error(t, impatient.SaveTopic(ctx, topic))
+
+	patient, err := NewSQLiteWithOptions(dbPath, SQLiteOptions{BusyTimeout: 5 * time.Second})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = patient.Close() })
+	time.AfterFunc(50*time.Millisecond, func() { _ = tx.Rollback() })
+	require.NoError(t, patient.SaveTopic(ctx, topic))
+}
+
+func TestSaveTopicsAndSubscriptionsBatch(t *testing.T) {
+	store := setupTestStorage(t)
+	ctx := context.Background()
+
+	var topics []*Topic
+	var subs []*Subscription
+	for i := 0; i < 50; i++ {
+		project := fmt.Sprintf("project-%d", i%3)
+		topicName := fmt.Sprintf("projects/%s/topics/topic-%d", project, i)
+		topics = append(topics, &Topic{
+			Name:             fmt.Sprintf("topic-%d", i),
+			ProjectID:        project,
+			FullResourceName: topicName,
+		})
+		subs = append(subs, &Subscription{
+			Name:                  fmt.Sprintf("sub-%d", i),
+			ProjectID:             project,
+			TopicFullResourceName: topicName,
+			FullResourceName:      fmt.Sprintf("projects/%s/subscriptions/sub-%d", project, i),
+		})
+	}
+
+	require.NoError(t, store.SaveTopics(ctx, topics))
+	require.NoError(t, store.SaveSubscriptions(ctx, subs))
+	require.NoError(t, store.SaveTopics(ctx, nil))
+
+	allTopics, err := store.GetAllTopics(ctx, nil)
+	require.NoError(t, err)
+	assert.Len(t, allTopics, 50)
+
+	allSubs, err := store.GetAllSubscriptions(ctx, nil)
+	require.NoError(t, err)
+	assert.Len(t, allSubs, 50)
+
+	projects, err := store.GetAllProjects(ctx)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"project-0", "project-1", "project-2"}, projects)
+
+	// Re-saving a batch upserts instead of duplicating
+	topics[0].Metadata = `{"labels":{"team":"a"}}`
+	require.NoError(t, store.SaveTopics(ctx, topics[:1]))
+	projectTopics, err := store.GetTopics(ctx, "project-0")
+	require.NoError(t, err)
+	assert.Len(t, projectTopics, 17)
+}
+
+func TestSaveTopics_Cancelled(t *testing.T) {
+	file, err := NewFile("")
+	require.NoError(t, err)
+
+	for name, store := range map[string]Store{"sqlite": setupTestStorage(t), "file": file} {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			err := store.SaveTopics(ctx, []*Topic{
+				{Name: "orders", ProjectID: "project-a", FullResourceName: "projects/project-a/topics/orders"},
+				{Name: "users", ProjectID: "project-a", FullResourceName: "projects/project-a/topics/users"},
+			})
+			require.ErrorIs(t, err, context.Canceled)
+
+			// Nothing of the batch is kept
+			topics, err := store.GetAllTopics(context.Background(), nil)
+			require.NoError(t, err)
+			assert.Empty(t, topics)
+			projects, err := store.GetAllProjects(context.Background())
+			require.NoError(t, err)
+			assert.Empty(t, projects)
+		})
+	}
+}
+
+// everyTable selects every cached row of a project for DeleteStaleResources
+var everyTable = []Stale{
+	{Table: TableTopics}, {Table: TableSubscriptions}, {Table: TableDestinations},
+	{Table: TableCloudRunServices}, {Table: TableCloudFunctions}, {Table: TableDataflowJobs},
+	{Table: TableResources}, {Table: TableEdges}, {Table: TableResourceMetrics},
+}
+
+func TestDeleteStaleResources(t *testing.T) {
+	store := setupTestStorage(t)
+	ctx := context.Background()
+
+	save := func(name string) {
+		require.NoError(t, store.SaveTopic(ctx, &Topic{
+			Name:             name,
+			ProjectID:        "project-a",
+			FullResourceName: "projects/project-a/topics/" + name,
+		}))
+		require.NoError(t, store.SaveSubscription(ctx, &Subscription{
+			Name:                  name + "-sub",
+			ProjectID:             "project-a",
+			TopicFullResourceName: "projects/project-a/topics/" + name,
+			FullResourceName:      "projects/project-a/subscriptions/" + name + "-sub",
+		}))
+		require.NoError(t, store.SaveSubscriptionDestination(ctx, &SubscriptionDestination{
+			SubscriptionFullResourceName: "projects/project-a/subscriptions/" + name + "-sub",
+			ProjectID:                    "project-a",
+			Type:                         DestinationTypeBigQuery,
+			Resource:                     "project-a.analytics." + name,
+		}))
+		require.NoError(t, store.SaveSubscriptionConsumer(ctx, &SubscriptionConsumer{
+			SubscriptionFullResourceName: "projects/project-a/subscriptions/" + name + "-sub",
+			ProjectID:                    "project-a",
+			Principal:                    "serviceAccount:worker@project-a.iam.gserviceaccount.com",
+			Source:                       ConsumerSourceIAM,
+			Role:                         "roles/pubsub.subscriber",
+		}))
+	}
+
+	// Previous scan saw both resources, the latest scan only "kept"
+	save("gone")
+	save("kept")
+	require.NoError(t, store.SaveTopic(ctx, &Topic{
+		Name:             "other",
+		ProjectID:        "project-b",
+		FullResourceName: "projects/project-b/topics/other",
+	}))
+
+	time.Sleep(5 * time.Millisecond)
+	started := time.Now()
+	save("kept")
+
+	removed, err := store.DeleteStaleResources(ctx, "project-a", started, everyTable)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), removed)
+
+	topics, err := store.GetAllTopics(ctx, nil)
+	require.NoError(t, err)
+	var names []string
+	for _, topic := range topics {
+		names = append(names, topic.Name)
+	}
+	assert.ElementsMatch(t, []string{"kept", "other"}, names)
+
+	subs, err := store.GetAllSubscriptions(ctx, nil)
+	require.NoError(t, err)
+	require.Len(t, subs, 1)
+	assert.Equal(t, "kept-sub", subs[0].Name)
+
+	dests, err := store.GetAllSubscriptionDestinations(ctx, nil)
+	require.NoError(t, err)
+	require.Len(t, dests, 1)
+	assert.Equal(t, "projects/project-a/subscriptions/kept-sub", dests[0].SubscriptionFullResourceName)
+
+	consumers, err := store.GetAllSubscriptionConsumers(ctx, nil)
+	require.NoError(t, err)
+	require.Len(t, consumers, 1)
+	assert.Equal(t, "projects/project-a/subscriptions/kept-sub", consumers[0].SubscriptionFullResourceName)
+
+	changes, err := store.GetChanges(ctx, started, nil)
+	require.NoError(t, err)
+	var deleted []string
+	for _, change := range changes {
+		if change.ChangeType == ChangeTypeDeleted {
+			deleted = append(deleted, change.FullResourceName)
+		}
+	}
+	assert.ElementsMatch(t, []string{"projects/project-a/topics/gone", "projects/project-a/subscriptions/gone-sub"}, deleted)
+}
+
+func TestDeleteStaleResources_OnlySelected(t *testing.T) {
+	ctx := context.Background()
+	file, err := NewFile("")
+	require.NoError(t, err)
+
+	for name, store := range map[string]Store{"sqlite": setupTestStorage(t), "file": file} {
+		t.Run(name, func(t *testing.T) {
+			require.NoError(t, store.SaveTopic(ctx, &Topic{Name: "gone", ProjectID: "project-a", FullResourceName: "projects/project-a/topics/gone"}))
+			require.NoError(t, store.SaveCloudRunServices(ctx, []*CloudRunService{
+				{Name: "worker", ProjectID: "project-a", Region: "europe-west1", FullResourceName: "projects/project-a/locations/europe-west1/services/worker"},
+			}))
+			require.NoError(t, store.SaveEdges(ctx, []*ResourceEdge{
+				{Source: "user:alice@example.com", Target: "projects/project-a/topics/gone", Relation: RelationPublishes, ProjectID: "project-a"},
+				{Source: "user:bob@example.com", Target: "projects/project-a/topics/gone", Relation: RelationCanPublish, ProjectID: "project-a"},
+			}))
+
+			time.Sleep(5 * time.Millisecond)
+			started := time.Now()
+
+			// Only the Pub/Sub collector ran
+			removed, err := store.DeleteStaleResources(ctx, "project-a", started, []Stale{
+				{Table: TableTopics}, {Table: TableSubscriptions}, {Table: TableEdges, Kind: RelationCanPublish},
+			})
+			require.NoError(t, err)
+			assert.Equal(t, int64(1), removed)
+
+			services, err := store.GetAllCloudRunServices(ctx, nil)
+			require.NoError(t, err)
+			assert.Len(t, services, 1)
+			edges, err := store.GetEdges(ctx, nil)
+			require.NoError(t, err)
+			var relations []string
+			for _, edge := range edges {
+				relations = append(relations, edge.Relation)
+			}
+			assert.Equal(t, []string{RelationPublishes}, relations)
+
+			_, err = store.DeleteStaleResources(ctx, "project-a", started, []Stale{{Table: "projects"}})
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestTouchResources(t *testing.T) {
+	ctx := context.Background()
+	file, err := NewFile("")
+	require.NoError(t, err)
+
+	for name, store := range map[string]Store{"sqlite": setupTestStorage(t), "file": file} {
+		t.Run(name, func(t *testing.T) {
+			for _, name := range []string{"gone", "kept"} {
+				require.NoError(t, store.SaveTopic(ctx, &Topic{Name: name, ProjectID: "project-a", FullResourceName: "projects/project-a/topics/" + name}))
+				require.NoError(t, store.SaveSubscription(ctx, &Subscription{
+					Name: name + "-sub", ProjectID: "project-a", TopicFullResourceName: "projects/project-a/topics/" + name,
+					FullResourceName: "projects/project-a/subscriptions/" + name + "-sub",
+				}))
+			}
+
+			time.Sleep(5 * time.Millisecond)
+			started := time.Now()
+			require.NoError(t, store.TouchTopics(ctx, []string{"projects/project-a/topics/kept", "projects/project-a/topics/unknown"}))
+			require.NoError(t, store.TouchSubscriptions(ctx, []string{"projects/project-a/subscriptions/kept-sub"}))
+
+			removed, err := store.DeleteStaleResources(ctx, "project-a", started, everyTable)
+			require.NoError(t, err)
+			assert.Equal(t, int64(2), removed)
+
+			topics, err := store.GetAllTopics(ctx, nil)
+			require.NoError(t, err)
+			require.Len(t, topics, 1)
+			assert.Equal(t, "kept", topics[0].Name)
+			subs, err := store.GetAllSubscriptions(ctx, nil)
+			require.NoError(t, err)
+			require.Len(t, subs, 1)
+			assert.Equal(t, "kept-sub", subs[0].Name)
+
+			// Touching isn't a change
+			changes, err := store.GetChanges(ctx, started, nil)
+			require.NoError(t, err)
+			for _, change := range changes {
+				assert.Equal(t, ChangeTypeDeleted, change.ChangeType)
+			}
+		})
+	}
+}
+
+func TestTransaction(t *testing.T) {
+	ctx := context.Background()
+	file, err := NewFile(filepath.Join(t.TempDir(), "cache.json"))
+	require.NoError(t, err)
+
+	for name, store := range map[string]Store{"sqlite": setupTestStorage(t), "file": file} {
+		t.Run(name, func(t *testing.T) {
+			orders := &Topic{Name: "orders", ProjectID: "project-a", FullResourceName: "projects/project-a/topics/orders", Metadata: `{"labels":{}}`}
+			require.NoError(t, store.SaveTopic(ctx, orders))
+
+			// A failed transaction rolls back every write made within it
+			err := store.Transaction(ctx, func(ctx context.Context) error {
+				require.NoError(t, store.SaveTopic(ctx, &Topic{Name: "payments", ProjectID: "project-a", FullResourceName: "projects/project-a/topics/payments"}))
+				require.NoError(t, store.DeleteTopic(ctx, orders.FullResourceName))
+				return errors.New("collection failed")
+			})
+			require.EqualError(t, err, "collection failed")
+			topics, err := store.GetTopics(ctx, "project-a")
+			require.NoError(t, err)
+			require.Len(t, topics, 1)
+			assert.Equal(t, "orders", topics[0].Name)
+
+			// Staged writes are applied in order, in one transaction, once committed
+			staged := NewStaged(store)
+			require.NoError(t, staged.SaveTopics(ctx, []*Topic{{Name: "payments", ProjectID: "project-a", FullResourceName: "projects/project-a/topics/payments"}}))
+			require.NoError(t, staged.SaveTopic(ctx, &Topic{Name: "orders", ProjectID: "project-a", FullResourceName: orders.FullResourceName, Metadata: `{"labels":{"team":"checkout"}}`}))
+			require.NoError(t, staged.UpdateProjectSyncTime(ctx, "project-a"))
+			assert.Equal(t, 2, staged.Resources())
+
+			topics, err = staged.GetTopics(ctx, "project-a")
+			require.NoError(t, err)
+			assert.Len(t, topics, 1, "reads don't see staged writes")
+
+			require.NoError(t, staged.Commit(ctx))
+			assert.Zero(t, staged.Resources())
+			topics, err = store.GetTopics(ctx, "project-a")
+			require.NoError(t, err)
+			require.Len(t, topics, 2)
+			for _, topic := range topics {
+				if topic.Name == "orders" {
+					assert.Equal(t, `{"labels":{"team":"checkout"}}`, topic.Metadata)
+				}
+			}
+			history, err := store.GetProjectSyncHistory(ctx, time.Time{})
+			require.NoError(t, err)
+			assert.Len(t, history["project-a"], 1)
+
+			// Discarded writes are never applied
+			require.NoError(t, staged.DeleteTopic(ctx, orders.FullResourceName))
+			staged.Discard()
+			require.NoError(t, staged.Commit(ctx))
+			topics, err = store.GetTopics(ctx, "project-a")
+			require.NoError(t, err)
+			assert.Len(t, topics, 2)
+		})
+	}
+
+	// The file backend persisted the committed state only
+	reopened, err := NewFile(file.path)
+	require.NoError(t, err)
+	topics, err := reopened.GetTopics(ctx, "project-a")
+	require.NoError(t, err)
+	assert.Len(t, topics, 2)
+}
+
+// cancelledWhileWriting is a context cancelled once a write has begun: the first Err reports
+// nil, every later one context.Canceled
+type cancelledWhileWriting struct {
+	context.Context
+	calls atomic.Int32
+}
+
+func (c *cancelledWhileWriting) Err() error {
+	if c.calls.Add(1) > 1 {
+		return context.Canceled
+	}
+	return nil
+}
+
+func TestCancelledWrites(t *testing.T) {
+	ctx := context.Background()
+	file, err := NewFile(filepath.Join(t.TempDir(), "cache.json"))
+	require.NoError(t, err)
+
+	for name, store := range map[string]Store{"sqlite": setupTestStorage(t), "file": file} {
+		t.Run(name, func(t *testing.T) {
+			require.NoError(t, store.SaveTopic(ctx, &Topic{Name: "orders", ProjectID: "project-a", FullResourceName: "projects/project-a/topics/orders"}))
+
+			// Staged writes stop at the first one after the cancellation, and none are applied
+			staged := NewStaged(store)
+			require.NoError(t, staged.SaveTopic(ctx, &Topic{Name: "payments", ProjectID: "project-a", FullResourceName: "projects/project-a/topics/payments"}))
+			require.NoError(t, staged.DeleteTopic(ctx, "projects/project-a/topics/orders"))
+			err := staged.Commit(&cancelledWhileWriting{Context: ctx})
+			require.ErrorIs(t, err, context.Canceled)
+
+			topics, err := store.GetTopics(ctx, "project-a")
+			require.NoError(t, err)
+			require.Len(t, topics, 1)
+			assert.Equal(t, "orders", topics[0].Name)
+
+			cancelled, cancel := context.WithCancel(ctx)
+			cancel()
+			called := false
+			err = EachTopic(cancelled, store, TopicQuery{}, func(*Topic) error {
+				called = true
+				return nil
+			})
+			require.ErrorIs(t, err, context.Canceled)
+			assert.False(t, called)
+		})
+	}
+
+	// A write cancelled while the file was encoded leaves it and the cache as they were
+	err = file.SaveTopic(&cancelledWhileWriting{Context: ctx}, &Topic{Name: "refunds", ProjectID: "project-a", FullResourceName: "projects/project-a/topics/refunds"})
+	require.ErrorIs(t, err, context.Canceled)
+	topics, err := file.GetTopics(ctx, "project-a")
+	require.NoError(t, err)
+	assert.Len(t, topics, 1)
+	reopened, err := NewFile(file.path)
+	require.NoError(t, err)
+	topics, err = reopened.GetTopics(ctx, "project-a")
+	require.NoError(t, err)
+	assert.Len(t, topics, 1)
+}
+
+func TestChangelog(t *testing.T) {
+	store := setupTestStorage(t)
+	start := time.Now().Add(-time.Second)
+	ctx := WithRunID(context.Background(), "run-1")
+
+	topic := &Topic{Name: "orders", ProjectID: "project-a", FullResourceName: "projects/project-a/topics/orders", Metadata: `{"labels":{}}`}
+	require.NoError(t, store.SaveTopic(ctx, topic))
+	// Saving the same metadata again is not a change
+	require.NoError(t, store.SaveTopic(ctx, topic))
+
+	topic.Metadata = `{"labels":{"team":"checkout"}}`
+	require.NoError(t, store.SaveTopic(context.Background(), topic))
+	require.NoError(t, store.SaveTopic(ctx, &Topic{Name: "users", ProjectID: "project-b", FullResourceName: "projects/project-b/topics/users"}))
+	require.NoError(t, store.DeleteTopic(ctx, "projects/project-a/topics/orders"))
+
+	changes, err := store.GetChanges(context.Background(), start, []string{"project-a"})
+	require.NoError(t, err)
+	require.Len(t, changes, 3)
+
+	assert.Equal(t, ChangeTypeCreated, changes[0].ChangeType)
+	assert.Equal(t, "run-1", changes[0].RunID)
+	assert.Equal(t, ResourceTypeTopic, changes[0].ResourceType)
+	assert.Empty(t, changes[0].BeforeMetadata)
+	assert.Equal(t, `{"labels":{}}`, changes[0].AfterMetadata)
+	assert.WithinDuration(t, time.Now(), changes[0].ChangedAt, time.Minute)
+
+	assert.Equal(t, ChangeTypeUpdated, changes[1].ChangeType)
+	assert.Empty(t, changes[1].RunID, "writes without a run ID are incremental updates")
+	assert.Equal(t, `{"labels":{}}`, changes[1].BeforeMetadata)
+	assert.Equal(t, `{"labels":{"team":"checkout"}}`, changes[1].AfterMetadata)
+
+	assert.Equal(t, ChangeTypeDeleted, changes[2].ChangeType)
+	assert.Equal(t, `{"labels":{"team":"checkout"}}`, changes[2].BeforeMetadata)
+	assert.Empty(t, changes[2].AfterMetadata)
+
+	all, err := store.GetChanges(context.Background(), start, nil)
+	require.NoError(t, err)
+	assert.Len(t, all, 4)
+
+	recent, err := store.GetChanges(context.Background(), time.Now().Add(time.Minute), nil)
+	require.NoError(t, err)
+	assert.Empty(t, recent)
+}
+
+func TestSaveTopics_LargeBatch(t *testing.T) {
+	store := setupTestStorage(t)
+	start := time.Now().Add(-time.Second)
+	ctx := context.Background()
+
+	// More rows than fit one statement, with a topic repeated at the end of the batch
+	topics := make([]*Topic, 0, 301)
+	for i := range 300 {
+		name := fmt.Sprintf("topic-%03d", i)
+		topics = append(topics, &Topic{Name: name, ProjectID: "project-a", FullResourceName: "projects/project-a/topics/" + name, Metadata: `{"labels":{}}`})
+	}
+	topics = append(topics, &Topic{Name: "topic-000", ProjectID: "project-a", FullResourceName: "projects/project-a/topics/topic-000", Metadata: `{"labels":{"team":"checkout"}}`})
+	require.NoError(t, store.SaveTopics(ctx, topics))
+
+	saved, err := store.GetTopics(ctx, "project-a")
+	require.NoError(t, err)
+	require.Len(t, saved, 300)
+	for _, topic := range saved {
+		if topic.Name == "topic-000" {
+			assert.Equal(t, `{"labels":{"team":"checkout"}}`, topic.Metadata, "the last write of a name wins")
+		}
+	}
+
+	changes, err := store.GetChanges(ctx, start, nil)
+	require.NoError(t, err)
+	require.Len(t, changes, 301)
+	assert.Equal(t, ChangeTypeCreated, changes[0].ChangeType)
+	assert.Equal(t, ChangeTypeUpdated, changes[300].ChangeType)
+	assert.Equal(t, `{"labels":{}}`, changes[300].BeforeMetadata)
+
+	names := make([]string, 0, len(saved))
+	for _, topic := range saved {
+		names = append(names, topic.FullResourceName)
+	}
+	require.NoError(t, store.TouchTopics(ctx, names))
+}
+
+func TestMigrate(t *testing.T) {
+	store, err := NewSQLite(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = store.Close() })
+	ctx := context.Background()
+	db, dialect := store.db, store.Dialect()
+
+	// Opening the store applied the SQLite migrations
+	var version int
+	require.NoError(t, db.QueryRowContext(ctx, "SELECT MAX(version) FROM schema_migrations").Scan(&version))
+	assert.Equal(t, store.Migrations()[len(store.Migrations())-1].Version, version)
+
+	migrations := append(store.Migrations(), Migration{
+		Version: 100, Name: "add notes", SQL: "CREATE TABLE notes (id INTEGER PRIMARY KEY, body TEXT)",
+	})
+	require.NoError(t, Migrate(ctx, db, dialect, migrations))
+	// Already applied migrations are skipped, so rerunning doesn't recreate the table
+	require.NoError(t, Migrate(ctx, db, dialect, migrations))
+	_, err = db.ExecContext(ctx, "INSERT INTO notes (body) VALUES ('hello')")
+	require.NoError(t, err)
+
+	// A failing migration is rolled back and not recorded
+	broken := append(migrations, Migration{Version: 101, Name: "broken", SQL: "CREATE TABLE broken (id INTEGER); NOT SQL"})
+	err = Migrate(ctx, db, dialect, broken)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "migration 101 (broken)")
+	var count int
+	require.NoError(t, db.QueryRowContext(ctx, "SELECT COUNT(*) FROM schema_migrations WHERE version = 101").Scan(&count))
+	assert.Zero(t, count)
+
+	// A schema from a newer version is refused
+	err = Migrate(ctx, db, dialect, store.Migrations())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "newer version")
+
+	err = Migrate(ctx, db, dialect, []Migration{{Version: 2}, {Version: 1}})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "out of order")
+}
+
+func TestMigrate_SubscriptionFilter(t *testing.T) {
+	db, err := sql.Open("sqlite", ":memory:")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = db.Close() })
+	ctx := context.Background()
+
+	// A cache from before the filter column kept filters in the metadata only
+	filter := slices.IndexFunc(sqliteMigrations, func(m Migration) bool { return m.Name == "subscription filter" })
+	require.NoError(t, Migrate(ctx, db, sqliteDialect{}, sqliteMigrations[:filter]))
+	_, err = db.ExecContext(ctx, `INSERT INTO subscriptions (name, project_id, topic_full_resource_name, full_resource_name, metadata) VALUES
+		('orders-eu', 'p', 'projects/p/topics/orders', 'projects/p/subscriptions/orders-eu', '{"filter":"attributes.region = \"eu\""}'),
+		('orders-all', 'p', 'projects/p/topics/orders', 'projects/p/subscriptions/orders-all', '{}')`)
+	require.NoError(t, err)
+
+	require.NoError(t, Migrate(ctx, db, sqliteDialect{}, sqliteMigrations))
+	store := &SQLiteStorage{db: db, writer: db}
+	subs, err := store.GetSubscriptions(ctx, "p")
+	require.NoError(t, err)
+	filters := make(map[string]string)
+	for _, sub := range subs {
+		filters[sub.Name] = sub.Filter
+	}
+	assert.Equal(t, map[string]string{"orders-eu": `attributes.region = "eu"`, "orders-all": ""}, filters)
+}
+
+func TestMigrate_UnversionedCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.db")
+
+	// A cache written before migrations were versioned has the initial tables but no schema_migrations
+	db, err := sql.Open("sqlite", path)
+	require.NoError(t, err)
+	ctx := context.Background()
+	_, err = db.ExecContext(ctx, sqliteMigrations[0].SQL)
+	require.NoError(t, err)
+	_, err = db.ExecContext(ctx, `INSERT INTO topics (name, project_id, full_resource_name, metadata)
+		VALUES ('orders', 'project-a', 'projects/project-a/topics/orders', '{}')`)
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	// Opening adopts the schema and applies the later migrations without losing the cached resources
+	store, err := NewSQLite(path)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = store.Close() })
+
+	version, err := store.schemaVersion(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, sqliteMigrations[len(sqliteMigrations)-1].Version, version)
+
+	topics, err := store.GetAllTopics(ctx, nil)
+	require.NoError(t, err)
+	require.Len(t, topics, 1)
+	assert.Equal(t, "orders", topics[0].Name)
+	assert.Zero(t, topics[0].MessageRetention)
+	assert.Empty(t, topics[0].StorageRegions)
+}
+
+func TestQueryTopics(t *testing.T) {
+	for name, open := range map[string]func(t *testing.T) Store{
+		"sqlite": setupTestStorage,
+		"file": func(t *testing.T) Store {
+			store, err := NewFile("")
+			require.NoError(t, err)
+			return store
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			store := open(t)
+			ctx := context.Background()
+			require.NoError(t, store.SaveTopics(ctx, []*Topic{
+				{
+					Name: "orders", ProjectID: "project-a", FullResourceName: "projects/project-a/topics/orders",
+					MessageRetention: 7 * 24 * time.Hour, KMSKeyName: "projects/kms/locations/europe/keyRings/pubsub/cryptoKeys/orders",
+					StorageRegions: []string{"europe-west1", "europe-north1"},
+				},
+				{
+					Name: "users", ProjectID: "project-b", FullResourceName: "projects/project-b/topics/users",
+					MessageRetention: time.Hour + 500*time.Millisecond, StorageRegions: []string{"us-central1"},
+				},
+				{Name: "audit", ProjectID: "project-b", FullResourceName: "projects/project-b/topics/audit"},
+			}))
+
+			names := func(q TopicQuery) []string {
+				topics, err := store.QueryTopics(ctx, q)
+				require.NoError(t, err)
+				var result []string
+				for _, t := range topics {
+					result = append(result, t.Name)
+				}
+				return result
+			}
+			assert.Equal(t, []string{"orders", "users", "audit"}, names(TopicQuery{}))
+			assert.Equal(t, []string{"users", "audit"}, names(TopicQuery{Projects: []string{"project-b"}}))
+			assert.Equal(t, []string{"orders"}, names(TopicQuery{KMSKeyName: "projects/kms/locations/europe/keyRings/pubsub/cryptoKeys/orders"}))
+			assert.Equal(t, []string{"users", "audit"}, names(TopicQuery{WithoutKMSKey: true}))
+			assert.Equal(t, []string{"orders", "users"}, names(TopicQuery{MinRetention: time.Hour}))
+			assert.Equal(t, []string{"orders"}, names(TopicQuery{MinRetention: 24 * time.Hour}))
+			assert.Equal(t, []string{"orders"}, names(TopicQuery{Region: "europe-west1"}))
+			assert.Empty(t, names(TopicQuery{Region: "europe"}))
+
+			topics, err := store.GetAllTopics(ctx, []string{"project-a"})
+			require.NoError(t, err)
+			require.Len(t, topics, 1)
+			assert.Equal(t, 7*24*time.Hour, topics[0].MessageRetention)
+			assert.Equal(t, []string{"europe-north1", "europe-west1"}, topics[0].StorageRegions)
+
+			// Retention is kept in whole seconds
+			topics, err = store.GetAllTopics(ctx, []string{"project-b"})
+			require.NoError(t, err)
+			assert.Equal(t, time.Hour, topics[0].MessageRetention)
+		})
+	}
+}
+
+func TestListTopicsAndSubscriptions(t *testing.T) {
+	for name, open := range map[string]func(t *testing.T) Store{
+		"sqlite": setupTestStorage,
+		"file": func(t *testing.T) Store {
+			store, err := NewFile("")
+			require.NoError(t, err)
+			return store
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			store := open(t)
+			ctx := context.Background()
+			for _, ref := range []string{"project-b/orders", "project-a/payments", "project-a/orders", "project-c/audit"} {
+				project, topic, _ := strings.Cut(ref, "/")
+				require.NoError(t, store.SaveTopic(ctx, &Topic{Name: topic, ProjectID: project, FullResourceName: "projects/" + project + "/topics/" + topic}))
+				require.NoError(t, store.SaveSubscription(ctx, &Subscription{
+					Name: topic + "-sub", ProjectID: project, TopicFullResourceName: "projects/" + project + "/topics/" + topic,
+					FullResourceName: "projects/" + project + "/subscriptions/" + topic + "-sub",
+				}))
+			}
+			names := func(topics []*Topic) []string {
+				var names []string
+				for _, t := range topics {
+					names = append(names, t.ProjectID+"/"+t.Name)
+				}
+				return names
+			}
+
+			topics, err := store.ListTopics(ctx, TopicQuery{}, Page{})
+			require.NoError(t, err)
+			assert.Equal(t, []string{"project-a/orders", "project-a/payments", "project-b/orders", "project-c/audit"}, names(topics))
+
+			topics, err = store.ListTopics(ctx, TopicQuery{}, Page{Sort: SortName, Desc: true})
+			require.NoError(t, err)
+			assert.Equal(t, []string{"project-a/payments", "project-b/orders", "project-a/orders", "project-c/audit"}, names(topics))
+
+			topics, err = store.ListTopics(ctx, TopicQuery{Projects: []string{"project-a", "project-b"}}, Page{Sort: SortProject, Limit: 2, Offset: 1})
+			require.NoError(t, err)
+			assert.Equal(t, []string{"project-a/payments", "project-b/orders"}, names(topics))
+
+			topics, err = store.ListTopics(ctx, TopicQuery{}, Page{Offset: 3})
+			require.NoError(t, err)
+			assert.Equal(t, []string{"project-c/audit"}, names(topics))
+
+			subs, err := store.ListSubscriptions(ctx, []string{"project-a"}, Page{Sort: SortName, Limit: 1})
+			require.NoError(t, err)
+			require.Len(t, subs, 1)
+			assert.Equal(t, "orders-sub", subs[0].Name)
+
+			_, err = store.ListSubscriptions(ctx, nil, Page{Sort: "size"})
+			assert.ErrorContains(t, err, `unknown sort "size"`)
+
+			var walked []string
+			require.NoError(t, EachSubscription(ctx, store, nil, func(sub *Subscription) error {
+				walked = append(walked, sub.FullResourceName)
+				return nil
+			}))
+			assert.Len(t, walked, 4)
+			assert.True(t, sort.StringsAreSorted(walked))
+		})
+	}
+}
+
+func TestExportImport(t *testing.T) {
+	source := setupTestStorage(t)
+	ctx := WithRunID(context.Background(), "run-1")
+
+	require.NoError(t, source.SaveTopic(ctx, &Topic{Name: "orders", ProjectID: "project-a", FullResourceName: "projects/project-a/topics/orders", Metadata: `{"labels":{"team":"checkout"}}`}))
+	require.NoError(t, source.SaveSubscription(ctx, &Subscription{
+		Name: "orders-bq", ProjectID: "project-a", TopicFullResourceName: "projects/project-a/topics/orders",
+		FullResourceName: "projects/project-a/subscriptions/orders-bq", Metadata: `{"labels":{}}`,
+	}))
+	require.NoError(t, source.SaveSubscriptionDestination(ctx, &SubscriptionDestination{
+		SubscriptionFullResourceName: "projects/project-a/subscriptions/orders-bq", ProjectID: "project-a",
+		Type: DestinationTypeBigQuery, Resource: "project-a.analytics.orders", Metadata: `{}`,
+	}))
+	require.NoError(t, source.SaveSubscriptionConsumer(ctx, &SubscriptionConsumer{
+		SubscriptionFullResourceName: "projects/project-a/subscriptions/orders-bq", ProjectID: "project-a",
+		Principal: "user:alice@example.com", Source: ConsumerSourceIAM, Role: "roles/pubsub.subscriber",
+	}))
+	require.NoError(t, source.UpdateProjectSyncTime(ctx, "project-a"))
+
+	var dump bytes.Buffer
+	require.NoError(t, source.Export(ctx, &dump))
+	assert.Contains(t, dump.String(), `"format": "gcp-visualizer-dump"`)
+
+	// Existing rows of the dumped tables are replaced
+	target := setupTestStorage(t)
+	require.NoError(t, target.SaveTopic(ctx, &Topic{Name: "local", ProjectID: "project-z", FullResourceName: "projects/project-z/topics/local"}))
+	require.NoError(t, target.Import(ctx, bytes.NewReader(dump.Bytes())))
+
+	for _, get := range []func(Store) (any, error){
+		func(s Store) (any, error) { return s.GetAllTopics(ctx, nil) },
+		func(s Store) (any, error) { return s.GetAllSubscriptions(ctx, nil) },
+		func(s Store) (any, error) { return s.GetAllSubscriptionDestinations(ctx, nil) },
+		func(s Store) (any, error) { return s.GetAllSubscriptionConsumers(ctx, nil) },
+		func(s Store) (any, error) { return s.GetProjectSyncTimes(ctx) },
+		func(s Store) (any, error) { return s.GetProjectSyncHistory(ctx, time.Time{}) },
+		func(s Store) (any, error) { return s.GetChanges(ctx, time.Time{}, nil) },
+	} {
+		want, err := get(source)
+		require.NoError(t, err)
+		got, err := get(target)
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+	}
+
+	// Exporting the import gives the same tables back
+	var again bytes.Buffer
+	require.NoError(t, target.Export(ctx, &again))
+	var first, second Dump
+	require.NoError(t, json.Unmarshal(dump.Bytes(), &first))
+	require.NoError(t, json.Unmarshal(again.Bytes(), &second))
+	assert.Equal(t, first.Tables, second.Tables)
+}
+
+func TestImport_Invalid(t *testing.T) {
+	store := setupTestStorage(t)
+	ctx := context.Background()
+
+	for name, tc := range map[string]struct{ dump, err string }{
+		"format":  {`{"format":"other"}`, "not a gcp-visualizer dump"},
+		"newer":   {`{"format":"gcp-visualizer-dump","schema_version":999}`, "newer"},
+		"table":   {`{"format":"gcp-visualizer-dump","tables":{"users":[]}}`, "unknown table users"},
+		"column":  {`{"format":"gcp-visualizer-dump","tables":{"topics":[{"secret":1}]}}`, "unknown column topics.secret"},
+		"garbage": {`not json`, "failed to read dump"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			err := store.Import(ctx, strings.NewReader(tc.dump))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.err)
+		})
+	}
+}
+
+func TestCloudRunServices(t *testing.T) {
+	for name, open := range map[string]func(t *testing.T) Store{
+		"sqlite": setupTestStorage,
+		"file": func(t *testing.T) Store {
+			store, err := NewFile("")
+			require.NoError(t, err)
+			return store
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			store := open(t)
+			ctx := context.Background()
+			service := func(project, name string) *CloudRunService {
+				return &CloudRunService{
+					Name:             name,
+					ProjectID:        project,
+					Region:           "europe-west1",
+					FullResourceName: "projects/" + project + "/locations/europe-west1/services/" + name,
+					URLs:             []string{"https://" + name + "-abc123-ew.a.run.app", "https://" + name + "-123456.europe-west1.run.app"},
+					Metadata:         `{}`,
+				}
+			}
+			require.NoError(t, store.SaveCloudRunServices(ctx, []*CloudRunService{
+				service("project-a", "mailer"),
+				service("project-a", "gone"),
+				service("project-b", "billing"),
+			}))
+
+			all, err := store.GetAllCloudRunServices(ctx, nil)
+			require.NoError(t, err)
+			require.Len(t, all, 3)
+			assert.Equal(t, []string{"https://mailer-123456.europe-west1.run.app", "https://mailer-abc123-ew.a.run.app"}, all[0].URLs)
+
+			// A later scan of project-a only sees mailer
+			time.Sleep(5 * time.Millisecond)
+			started := time.Now()
+			require.NoError(t, store.SaveCloudRunServices(ctx, []*CloudRunService{service("project-a", "mailer")}))
+			removed, err := store.DeleteStaleResources(ctx, "project-a", started, everyTable)
+			require.NoError(t, err)
+			assert.Zero(t, removed, "only topics and subscriptions are counted")
+
+			services, err := store.GetAllCloudRunServices(ctx, []string{"project-a"})
+			require.NoError(t, err)
+			require.Len(t, services, 1)
+			assert.Equal(t, "mailer", services[0].Name)
+			assert.Equal(t, "europe-west1", services[0].Region)
+
+			services, err = store.GetAllCloudRunServices(ctx, []string{"project-b"})
+			require.NoError(t, err)
+			assert.Len(t, services, 1)
+		})
+	}
+}
+
+func TestCloudFunctions(t *testing.T) {
+	for name, open := range map[string]func(t *testing.T) Store{
+		"sqlite": setupTestStorage,
+		"file": func(t *testing.T) Store {
+			store, err := NewFile("")
+			require.NoError(t, err)
+			return store
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			store := open(t)
+			ctx := context.Background()
+			function := func(project, name string, generation int, topic string) *CloudFunction {
+				fn := &CloudFunction{
+					Name:             name,
+					ProjectID:        project,
+					Region:           "europe-west1",
+					FullResourceName: "projects/" + project + "/locations/europe-west1/functions/" + name,
+					Generation:       generation,
+					TriggerTopic:     topic,
+					Metadata:         `{}`,
+				}
+				if topic != "" {
+					fn.EventType = "google.cloud.pubsub.topic.v1.messagePublished"
+				}
+				return fn
+			}
+			require.NoError(t, store.SaveCloudFunctions(ctx, []*CloudFunction{
+				function("project-a", "resize", 1, "projects/project-a/topics/uploads"),
+				function("project-a", "gone", 2, ""),
+				function("project-b", "notify", 2, "projects/project-a/topics/orders"),
+			}))
+
+			all, err := store.GetAllCloudFunctions(ctx, nil)
+			require.NoError(t, err)
+			require.Len(t, all, 3)
+			assert.Equal(t, 1, all[0].Generation)
+			assert.Equal(t, "projects/project-a/topics/uploads", all[0].TriggerTopic)
+			assert.Equal(t, "google.cloud.pubsub.topic.v1.messagePublished", all[0].EventType)
+			assert.Empty(t, all[1].EventType)
+
+			// A later scan of project-a only sees resize
+			time.Sleep(5 * time.Millisecond)
+			started := time.Now()
+			require.NoError(t, store.SaveCloudFunctions(ctx, []*CloudFunction{function("project-a", "resize", 1, "projects/project-a/topics/uploads")}))
+			_, err = store.DeleteStaleResources(ctx, "project-a", started, everyTable)
+			require.NoError(t, err)
+
+			functions, err := store.GetAllCloudFunctions(ctx, []string{"project-a"})
+			require.NoError(t, err)
+			require.Len(t, functions, 1)
+			assert.Equal(t, "resize", functions[0].Name)
+
+			functions, err = store.GetAllCloudFunctions(ctx, []string{"project-b"})
+			require.NoError(t, err)
+			assert.Len(t, functions, 1)
+		})
+	}
+}
+
+func TestDataflowJobs(t *testing.T) {
+	for name, open := range map[string]func(t *testing.T) Store{
+		"sqlite": setupTestStorage,
+		"file": func(t *testing.T) Store {
+			store, err := NewFile("")
+			require.NoError(t, err)
+			return store
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			store := open(t)
+			ctx := context.Background()
+			job := func(project, id string, sources, sinks []string) *DataflowJob {
+				return &DataflowJob{
+					Name:             "pipeline-" + id,
+					ProjectID:        project,
+					Region:           "europe-west1",
+					FullResourceName: "projects/" + project + "/locations/europe-west1/jobs/" + id,
+					Sources:          sources,
+					Sinks:            sinks,
+					Metadata:         `{}`,
+				}
+			}
+			require.NoError(t, store.SaveDataflowJobs(ctx, []*DataflowJob{
+				job("project-a", "1", []string{"projects/project-a/topics/raw"}, []string{"projects/project-a/topics/enriched", "projects/project-a/topics/dead-letter"}),
+				job("project-a", "2", []string{"projects/project-a/subscriptions/clicks"}, nil),
+				job("project-b", "3", nil, []string{"projects/project-a/topics/raw"}),
+			}))
+
+			all, err := store.GetAllDataflowJobs(ctx, nil)
+			require.NoError(t, err)
+			require.Len(t, all, 3)
+			assert.Equal(t, []string{"projects/project-a/topics/raw"}, all[0].Sources)
+			assert.Equal(t, []string{"projects/project-a/topics/dead-letter", "projects/project-a/topics/enriched"}, all[0].Sinks, "sinks are sorted")
+			assert.Nil(t, all[1].Sinks)
+
+			// A later scan of project-a only sees the first job
+			time.Sleep(5 * time.Millisecond)
+			started := time.Now()
+			require.NoError(t, store.SaveDataflowJobs(ctx, []*DataflowJob{
+				job("project-a", "1", []string{"projects/project-a/topics/raw"}, []string{"projects/project-a/topics/enriched"}),
+			}))
+			_, err = store.DeleteStaleResources(ctx, "project-a", started, everyTable)
+			require.NoError(t, err)
+
+			jobs, err := store.GetAllDataflowJobs(ctx, []string{"project-a"})
+			require.NoError(t, err)
+			require.Len(t, jobs, 1)
+			assert.Equal(t, "pipeline-1", jobs[0].Name)
+			assert.Equal(t, []string{"projects/project-a/topics/enriched"}, jobs[0].Sinks)
+
+			jobs, err = store.GetAllDataflowJobs(ctx, []string{"project-b"})
+			require.NoError(t, err)
+			assert.Len(t, jobs, 1)
+		})
+	}
+}
+
+func TestGenericResources(t *testing.T) {
+	for name, open := range map[string]func(t *testing.T) Store{
+		"sqlite": setupTestStorage,
+		"file": func(t *testing.T) Store {
+			store, err := NewFile("")
+			require.NoError(t, err)
+			return store
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			store := open(t)
+			ctx := context.Background()
+
+			require.NoError(t, store.SaveTopic(ctx, &Topic{Name: "orders", ProjectID: "project-a", FullResourceName: "projects/project-a/topics/orders", Metadata: `{}`}))
+			require.NoError(t, store.SaveSubscription(ctx, &Subscription{
+				Name: "orders-sub", ProjectID: "project-a", FullResourceName: "projects/project-a/subscriptions/orders-sub",
+				TopicFullResourceName: "projects/project-a/topics/orders", Metadata: `{}`,
+			}))
+			require.NoError(t, store.SaveSubscription(ctx, &Subscription{
+				Name: "detached", ProjectID: "project-a", FullResourceName: "projects/project-a/subscriptions/detached",
+				TopicFullResourceName: "_deleted-topic_", Metadata: `{}`,
+			}))
+
+			job := func(project, name string) *Resource {
+				return &Resource{
+					Kind:             "scheduler_job",
+					Name:             name,
+					ProjectID:        project,
+					FullResourceName: "projects/" + project + "/locations/europe-west1/jobs/" + name,
+					Metadata:         `{"schedule":"0 * * * *"}`,
+				}
+			}
+			require.NoError(t, store.SaveResources(ctx, []*Resource{job("project-a", "hourly"), job("project-a", "gone"), job("project-b", "nightly")}))
+			require.NoError(t, store.SaveEdges(ctx, []*ResourceEdge{
+				{Source: job("project-a", "hourly").FullResourceName, Target: "projects/project-a/topics/orders", Relation: "publishes_to", ProjectID: "project-a"},
+				{Source: job("project-a", "gone").FullResourceName, Target: "projects/project-a/topics/orders", Relation: "publishes_to", ProjectID: "project-a"},
+			}))
+
+			err := store.SaveResources(ctx, []*Resource{{Kind: ResourceKindTopic, Name: "sneaky", ProjectID: "project-a", FullResourceName: "projects/project-a/topics/sneaky"}})
+			require.Error(t, err, "topics are only written through their typed methods")
+
+			all, err := store.GetResources(ctx, "", []string{"project-a"})
+			require.NoError(t, err)
+			var kinds []string
+			for _, r := range all {
+				kinds = append(kinds, r.Kind+":"+r.Name)
+			}
+			assert.Equal(t, []string{"scheduler_job:gone", "scheduler_job:hourly", "subscription:detached", "subscription:orders-sub", "topic:orders"}, kinds)
+
+			jobs, err := store.GetResources(ctx, "scheduler_job", nil)
+			require.NoError(t, err)
+			assert.Len(t, jobs, 3)
+			assert.JSONEq(t, `{"schedule":"0 * * * *"}`, jobs[0].Metadata)
+
+			edges, err := store.GetEdges(ctx, []string{"project-a"})
+			require.NoError(t, err)
+			require.Len(t, edges, 3, "detached subscriptions have no edge to a topic")
+			assert.Equal(t, RelationSubscribes, edges[2].Relation)
+			assert.Equal(t, "projects/project-a/subscriptions/orders-sub", edges[2].Source)
+
+			// A later scan of project-a only sees the hourly job
+			time.Sleep(5 * time.Millisecond)
+			started := time.Now()
+			require.NoError(t, store.SaveTopic(ctx, &Topic{Name: "orders", ProjectID: "project-a", FullResourceName: "projects/project-a/topics/orders", Metadata: `{}`}))
+			require.NoError(t, store.SaveResources(ctx, []*Resource{job("project-a", "hourly")}))
+			require.NoError(t, store.SaveEdges(ctx, []*ResourceEdge{
+				{Source: job("project-a", "hourly").FullResourceName, Target: "projects/project-a/topics/orders", Relation: "publishes_to", ProjectID: "project-a"},
+			}))
+			_, err = store.DeleteStaleResources(ctx, "project-a", started, everyTable)
+			require.NoError(t, err)
+
+			jobs, err = store.GetResources(ctx, "scheduler_job", []string{"project-a"})
+			require.NoError(t, err)
+			require.Len(t, jobs, 1)
+			assert.Equal(t, "hourly", jobs[0].Name)
+
+			edges, err = store.GetEdges(ctx, nil)
+			require.NoError(t, err)
+			require.Len(t, edges, 1)
+			assert.Equal(t, "publishes_to", edges[0].Relation)
+
+			jobs, err = store.GetResources(ctx, "scheduler_job", []string{"project-b"})
+			require.NoError(t, err)
+			assert.Len(t, jobs, 1)
+		})
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	for name, open := range map[string]func(t *testing.T) Store{
+		"sqlite": setupTestStorage,
+		"file": func(t *testing.T) Store {
+			store, err := NewFile("")
+			require.NoError(t, err)
+			return store
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			store := open(t)
+			ctx := context.Background()
+
+			orders := &ResourceMetric{FullResourceName: "projects/project-a/topics/orders", ProjectID: "project-a",
+				Metric: MetricTopicPublishOperations, Value: 1250, Window: time.Hour}
+			require.NoError(t, store.SaveMetrics(ctx, []*ResourceMetric{
+				orders,
+				{FullResourceName: "projects/project-a/subscriptions/orders-sub", ProjectID: "project-a",
+					Metric: MetricSubscriptionBacklog, Value: 12.5, Window: time.Hour},
+				{FullResourceName: "projects/project-b/topics/users", ProjectID: "project-b",
+					Metric: MetricTopicPublishOperations, Value: 3, Window: 90 * time.Minute},
+			}))
+
+			metrics, err := store.GetMetrics(ctx, []string{"project-a"})
+			require.NoError(t, err)
+			require.Len(t, metrics, 2)
+			assert.Equal(t, "projects/project-a/subscriptions/orders-sub", metrics[0].FullResourceName)
+			assert.Equal(t, 12.5, metrics[0].Value)
+			assert.Equal(t, time.Hour, metrics[0].Window)
+
+			// A later scan of project-a refreshes the topic's throughput, the subscription is gone
+			time.Sleep(5 * time.Millisecond)
+			started := time.Now()
+			orders.Value = 980
+			require.NoError(t, store.SaveMetrics(ctx, []*ResourceMetric{orders}))
+			_, err = store.DeleteStaleResources(ctx, "project-a", started, everyTable)
+			require.NoError(t, err)
+
+			metrics, err = store.GetMetrics(ctx, nil)
+			require.NoError(t, err)
+			require.Len(t, metrics, 2)
+			assert.Equal(t, "projects/project-a/topics/orders", metrics[0].FullResourceName)
+			assert.Equal(t, 980.0, metrics[0].Value)
+			assert.Equal(t, 90*time.Minute, metrics[1].Window)
+		})
+	}
+}
+
+func TestMetricPoints(t *testing.T) {
+	for name, open := range map[string]func(t *testing.T) Store{
+		"sqlite": setupTestStorage,
+		"file": func(t *testing.T) Store {
+			store, err := NewFile("")
+			require.NoError(t, err)
+			return store
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			store := open(t)
+			ctx := context.Background()
+
+			at := func(minute int) time.Time { return time.Date(2024, 1, 1, 12, minute, 0, 0, time.UTC) }
+			point := func(project, name string, minute int, value float64) *MetricPoint {
+				return &MetricPoint{FullResourceName: "projects/" + project + "/topics/" + name, ProjectID: project,
+					Metric: MetricTopicPublishOperations, Start: at(minute), Value: value}
+			}
+			require.NoError(t, store.SaveMetricPoints(ctx, []*MetricPoint{
+				point("project-a", "orders", 0, 10),
+				point("project-a", "orders", 5, 20),
+				point("project-a", "users", 5, 1),
+				point("project-b", "orders", 5, 7),
+			}))
+
+			points, err := store.GetMetricPoints(ctx, "project-a", at(5))
+			require.NoError(t, err)
+			require.Len(t, points, 2)
+			assert.Equal(t, "projects/project-a/topics/orders", points[0].FullResourceName)
+			assert.Equal(t, at(5), points[0].Start)
+			assert.Equal(t, 20.0, points[0].Value)
+
+			// A point for the same period replaces the stored one, old ones are pruned per project
+			require.NoError(t, store.SaveMetricPoints(ctx, []*MetricPoint{point("project-a", "orders", 5, 25)}))
+			require.NoError(t, store.DeleteMetricPoints(ctx, "project-a", at(5)))
+
+			points, err = store.GetMetricPoints(ctx, "project-a", time.Time{})
+			require.NoError(t, err)
+			require.Len(t, points, 2)
+			assert.Equal(t, 25.0, points[0].Value)
+			assert.Equal(t, "projects/project-a/topics/users", points[1].FullResourceName)
+
+			points, err = store.GetMetricPoints(ctx, "project-b", time.Time{})
+			require.NoError(t, err)
+			require.Len(t, points, 1)
+		})
+	}
+}
+
+func TestProjectStatus(t *testing.T) {
+	for name, open := range map[string]func(t *testing.T) Store{
+		"sqlite": setupTestStorage,
+		"file": func(t *testing.T) Store {
+			store, err := NewFile("")
+			require.NoError(t, err)
+			return store
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			store := open(t)
+			ctx := context.Background()
+
+			require.NoError(t, store.SetProjectStatus(ctx, "project-a", ProjectStatusAPIDisabled))
+			require.NoError(t, store.SetProjectStatus(ctx, "project-b", ProjectStatusAPIDisabled))
+			statuses, err := store.GetProjectStatuses(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, map[string]string{"project-a": ProjectStatusAPIDisabled, "project-b": ProjectStatusAPIDisabled}, statuses)
+
+			projects, err := store.GetAllProjects(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, []string{"project-a", "project-b"}, projects)
+
+			// Saving resources keeps the status, a completed sync clears it
+			require.NoError(t, store.SaveTopic(ctx, &Topic{Name: "orders", ProjectID: "project-a", FullResourceName: "projects/project-a/topics/orders"}))
+			statuses, err = store.GetProjectStatuses(ctx)
+			require.NoError(t, err)
+			assert.Len(t, statuses, 2)
+
+			require.NoError(t, store.UpdateProjectSyncTime(ctx, "project-a"))
+			require.NoError(t, store.SetProjectStatus(ctx, "project-b", ""))
+			statuses, err = store.GetProjectStatuses(ctx)
+			require.NoError(t, err)
+			assert.Empty(t, statuses)
+		})
+	}
+}
+
+func TestProjectLabels(t *testing.T) {
+	for name, open := range map[string]func(t *testing.T) Store{
+		"sqlite": setupTestStorage,
+		"file": func(t *testing.T) Store {
+			store, err := NewFile("")
+			require.NoError(t, err)
+			return store
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			store := open(t)
+			ctx := context.Background()
+
+			require.NoError(t, store.SetProjectLabels(ctx, "project-a", map[string]string{"team": "payments", "env": "prod"}))
+			require.NoError(t, store.SetProjectLabels(ctx, "project-b", nil))
+			labels, err := store.GetProjectLabels(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, map[string]map[string]string{"project-a": {"team": "payments", "env": "prod"}}, labels)
+
+			projects, err := store.GetAllProjects(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, []string{"project-a", "project-b"}, projects)
+
+			// A completed sync keeps the labels, the next collection replaces them
+			require.NoError(t, store.UpdateProjectSyncTime(ctx, "project-a"))
+			require.NoError(t, store.SetProjectLabels(ctx, "project-a", map[string]string{"team": "checkout"}))
+			labels, err = store.GetProjectLabels(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, map[string]map[string]string{"project-a": {"team": "checkout"}}, labels)
+		})
+	}
+}
+
+func TestScanRuns(t *testing.T) {
+	for name, open := range map[string]func(t *testing.T) Store{
+		"sqlite": setupTestStorage,
+		"file": func(t *testing.T) Store {
+			store, err := NewFile("")
+			require.NoError(t, err)
+			return store
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			store := open(t)
+			ctx := context.Background()
+
+			started := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+			for i, runID := range []string{"run-1", "run-2", "run-3"} {
+				require.NoError(t, store.SaveScanRun(ctx, &ScanRun{
+					RunID:             runID,
+					StartedAt:         started.Add(time.Duration(i) * time.Hour),
+					FinishedAt:        started.Add(time.Duration(i)*time.Hour + time.Minute),
+					ProjectsAttempted: 2,
+					ProjectsSucceeded: 2 - i%2,
+					ProjectsFailed:    i % 2,
+					Topics:            10 + i,
+					APICalls:          int64(100 * i),
+					Version:           "v1.2.3",
+				}))
+			}
+			assert.Error(t, store.SaveScanRun(ctx, &ScanRun{RunID: "run-1", StartedAt: started, FinishedAt: started}))
+
+			runs, err := store.GetScanRuns(ctx, 2)
+			require.NoError(t, err)
+			require.Len(t, runs, 2)
+			assert.Equal(t, "run-3", runs[0].RunID)
+			assert.Equal(t, "run-2", runs[1].RunID)
+			assert.Equal(t, 1, runs[1].ProjectsFailed)
+			assert.Equal(t, int64(200), runs[0].APICalls)
+
+			run, err := store.GetScanRun(ctx, "run-1")
+			require.NoError(t, err)
+			assert.True(t, started.Equal(run.StartedAt))
+			assert.Equal(t, time.Minute, run.FinishedAt.Sub(run.StartedAt))
+			assert.Equal(t, 10, run.Topics)
+			assert.Equal(t, "v1.2.3", run.Version)
+
+			_, err = store.GetScanRun(ctx, "run-4")
+			assert.ErrorIs(t, err, ErrScanRunNotFound)
+		})
+	}
+}
+
+func TestAddTopicAndSubscription(t *testing.T) {
+	file, err := NewFile(filepath.Join(t.TempDir(), "cache.json"))
+	require.NoError(t, err)
+	for name, store := range map[string]Store{"sqlite": setupTestStorage(t), "file": file} {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			require.NoError(t, store.SaveTopic(ctx, &Topic{
+				Name: "orders", ProjectID: "p", FullResourceName: "projects/p/topics/orders", Metadata: `{"labels":{"team":"checkout"}}`,
+			}))
+			require.NoError(t, store.SaveSubscription(ctx, &Subscription{
+				Name: "orders-eu", ProjectID: "p", TopicFullResourceName: "projects/p/topics/orders", FullResourceName: "projects/p/subscriptions/orders-eu",
+				Filter: `attributes.region = "eu"`, Metadata: `{}`,
+			}))
+
+			// Cached resources keep what was collected about them
+			require.NoError(t, store.AddTopic(ctx, &Topic{Name: "orders", ProjectID: "p", FullResourceName: "projects/p/topics/orders", Metadata: `{}`}))
+			require.NoError(t, store.AddSubscription(ctx, &Subscription{
+				Name: "orders-eu", ProjectID: "p", TopicFullResourceName: "projects/p/topics/orders", FullResourceName: "projects/p/subscriptions/orders-eu", Metadata: `{}`,
+			}))
+			// and new ones are added
+			require.NoError(t, store.AddTopic(ctx, &Topic{Name: "users", ProjectID: "p", FullResourceName: "projects/p/topics/users", Metadata: `{}`}))
+			require.NoError(t, store.AddSubscription(ctx, &Subscription{
+				Name: "users-all", ProjectID: "p", TopicFullResourceName: "projects/p/topics/users", FullResourceName: "projects/p/subscriptions/users-all", Metadata: `{}`,
+			}))
+
+			topics, err := store.GetTopics(ctx, "p")
+			require.NoError(t, err)
+			metadata := make(map[string]string)
+			for _, topic := range topics {
+				metadata[topic.Name] = topic.Metadata
+			}
+			assert.Equal(t, map[string]string{"orders": `{"labels":{"team":"checkout"}}`, "users": `{}`}, metadata)
+
+			subs, err := store.GetSubscriptions(ctx, "p")
+			require.NoError(t, err)
+			filters := make(map[string]string)
+			for _, sub := range subs {
+				filters[sub.Name] = sub.Filter
+			}
+			assert.Equal(t, map[string]string{"orders-eu": `attributes.region = "eu"`, "users-all": ""}, filters)
+
+			changes, err := store.GetChanges(ctx, time.Time{}, nil)
+			require.NoError(t, err)
+			assert.Len(t, changes, 4, "adding a cached resource records no change")
+		})
+	}
+}
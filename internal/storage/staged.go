@@ -0,0 +1,259 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// Staged is a Store that holds its writes back until Commit applies them to the underlying store
+// in a single transaction, so a collection that fails midway leaves the cache as it was. Reads go
+// to the underlying store and don't see the staged writes. It is safe for concurrent use.
+type Staged struct {
+	Store // the underlying store, serving reads
+
+	mu        sync.Mutex
+	writes    []stagedWrite
+	resources int
+}
+
+// stagedWrite applies a staged write to the underlying store
+type stagedWrite func(ctx context.Context, store Store) error
+
+// NewStaged returns a Staged holding back the writes to store
+func NewStaged(store Store) *Staged {
+	return &Staged{Store: store}
+}
+
+// stage holds back a write of n resources, unless ctx is already done
+func (s *Staged) stage(ctx context.Context, n int, write stagedWrite) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.writes = append(s.writes, write)
+	s.resources += n
+	return nil
+}
+
+// Resources returns the number of resources written since the last Commit or Discard
+func (s *Staged) Resources() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.resources
+}
+
+// Commit applies the staged writes to the underlying store in the order they were made, in a
+// single transaction, and clears them. If a write fails, none of them are applied.
+func (s *Staged) Commit(ctx context.Context) error {
+	s.mu.Lock()
+	writes := s.writes
+	s.writes, s.resources = nil, 0
+	s.mu.Unlock()
+
+	if len(writes) == 0 {
+		return nil
+	}
+	return s.Store.Transaction(ctx, func(ctx context.Context) error {
+		for _, write := range writes {
+			// A cancelled scan stops between writes even if the underlying store doesn't check ctx
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := write(ctx, s.Store); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Discard drops the staged writes
+func (s *Staged) Discard() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.writes, s.resources = nil, 0
+}
+
+// Transaction runs fn, whose writes are staged like any other and committed together by Commit
+func (s *Staged) Transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+// Close discards the staged writes, leaving the underlying store open
+func (s *Staged) Close() error {
+	s.Discard()
+	return nil
+}
+
+func (s *Staged) SaveTopic(ctx context.Context, topic *Topic) error {
+	return s.SaveTopics(ctx, []*Topic{topic})
+}
+
+func (s *Staged) SaveTopics(ctx context.Context, topics []*Topic) error {
+	return s.stage(ctx, len(topics), func(ctx context.Context, store Store) error {
+		return store.SaveTopics(ctx, topics)
+	})
+}
+
+func (s *Staged) AddTopic(ctx context.Context, topic *Topic) error {
+	return s.stage(ctx, 1, func(ctx context.Context, store Store) error {
+		return store.AddTopic(ctx, topic)
+	})
+}
+
+func (s *Staged) DeleteTopic(ctx context.Context, fullResourceName string) error {
+	return s.stage(ctx, 1, func(ctx context.Context, store Store) error {
+		return store.DeleteTopic(ctx, fullResourceName)
+	})
+}
+
+func (s *Staged) SaveSubscription(ctx context.Context, sub *Subscription) error {
+	return s.SaveSubscriptions(ctx, []*Subscription{sub})
+}
+
+func (s *Staged) SaveSubscriptions(ctx context.Context, subs []*Subscription) error {
+	return s.stage(ctx, len(subs), func(ctx context.Context, store Store) error {
+		return store.SaveSubscriptions(ctx, subs)
+	})
+}
+
+func (s *Staged) AddSubscription(ctx context.Context, sub *Subscription) error {
+	return s.stage(ctx, 1, func(ctx context.Context, store Store) error {
+		return store.AddSubscription(ctx, sub)
+	})
+}
+
+func (s *Staged) DeleteSubscription(ctx context.Context, fullResourceName string) error {
+	return s.stage(ctx, 1, func(ctx context.Context, store Store) error {
+		return store.DeleteSubscription(ctx, fullResourceName)
+	})
+}
+
+func (s *Staged) SaveSubscriptionDestination(ctx context.Context, dest *SubscriptionDestination) error {
+	return s.stage(ctx, 1, func(ctx context.Context, store Store) error {
+		return store.SaveSubscriptionDestination(ctx, dest)
+	})
+}
+
+func (s *Staged) SaveSubscriptionConsumer(ctx context.Context, consumer *SubscriptionConsumer) error {
+	return s.stage(ctx, 1, func(ctx context.Context, store Store) error {
+		return store.SaveSubscriptionConsumer(ctx, consumer)
+	})
+}
+
+func (s *Staged) ReplaceSubscriptionConsumers(ctx context.Context, subscriptionFullResourceName, source string, consumers []*SubscriptionConsumer) error {
+	return s.stage(ctx, len(consumers), func(ctx context.Context, store Store) error {
+		return store.ReplaceSubscriptionConsumers(ctx, subscriptionFullResourceName, source, consumers)
+	})
+}
+
+func (s *Staged) SaveCloudRunServices(ctx context.Context, services []*CloudRunService) error {
+	return s.stage(ctx, len(services), func(ctx context.Context, store Store) error {
+		return store.SaveCloudRunServices(ctx, services)
+	})
+}
+
+func (s *Staged) SaveCloudFunctions(ctx context.Context, functions []*CloudFunction) error {
+	return s.stage(ctx, len(functions), func(ctx context.Context, store Store) error {
+		return store.SaveCloudFunctions(ctx, functions)
+	})
+}
+
+func (s *Staged) SaveDataflowJobs(ctx context.Context, jobs []*DataflowJob) error {
+	return s.stage(ctx, len(jobs), func(ctx context.Context, store Store) error {
+		return store.SaveDataflowJobs(ctx, jobs)
+	})
+}
+
+func (s *Staged) SaveResources(ctx context.Context, resources []*Resource) error {
+	// Checked now, so the caller sees the error rather than the Commit
+	if err := checkGenericKinds(resources); err != nil {
+		return err
+	}
+	return s.stage(ctx, len(resources), func(ctx context.Context, store Store) error {
+		return store.SaveResources(ctx, resources)
+	})
+}
+
+func (s *Staged) SaveEdges(ctx context.Context, edges []*ResourceEdge) error {
+	return s.stage(ctx, len(edges), func(ctx context.Context, store Store) error {
+		return store.SaveEdges(ctx, edges)
+	})
+}
+
+func (s *Staged) SaveMetrics(ctx context.Context, metrics []*ResourceMetric) error {
+	return s.stage(ctx, len(metrics), func(ctx context.Context, store Store) error {
+		return store.SaveMetrics(ctx, metrics)
+	})
+}
+
+func (s *Staged) SaveMetricPoints(ctx context.Context, points []*MetricPoint) error {
+	return s.stage(ctx, 0, func(ctx context.Context, store Store) error {
+		return store.SaveMetricPoints(ctx, points)
+	})
+}
+
+func (s *Staged) DeleteMetricPoints(ctx context.Context, projectID string, before time.Time) error {
+	return s.stage(ctx, 0, func(ctx context.Context, store Store) error {
+		return store.DeleteMetricPoints(ctx, projectID, before)
+	})
+}
+
+func (s *Staged) TouchTopics(ctx context.Context, fullResourceNames []string) error {
+	return s.stage(ctx, len(fullResourceNames), func(ctx context.Context, store Store) error {
+		return store.TouchTopics(ctx, fullResourceNames)
+	})
+}
+
+func (s *Staged) TouchSubscriptions(ctx context.Context, fullResourceNames []string) error {
+	return s.stage(ctx, len(fullResourceNames), func(ctx context.Context, store Store) error {
+		return store.TouchSubscriptions(ctx, fullResourceNames)
+	})
+}
+
+// DeleteStaleResources stages the removal and returns zero, the number removed is only known once committed
+func (s *Staged) DeleteStaleResources(ctx context.Context, projectID string, before time.Time, stale []Stale) (int64, error) {
+	return 0, s.stage(ctx, 0, func(ctx context.Context, store Store) error {
+		_, err := store.DeleteStaleResources(ctx, projectID, before, stale)
+		return err
+	})
+}
+
+func (s *Staged) SaveScanRun(ctx context.Context, run *ScanRun) error {
+	return s.stage(ctx, 0, func(ctx context.Context, store Store) error {
+		return store.SaveScanRun(ctx, run)
+	})
+}
+
+func (s *Staged) UpdateProjectSyncTime(ctx context.Context, projectID string) error {
+	return s.stage(ctx, 0, func(ctx context.Context, store Store) error {
+		return store.UpdateProjectSyncTime(ctx, projectID)
+	})
+}
+
+func (s *Staged) SetProjectStatus(ctx context.Context, projectID, status string) error {
+	return s.stage(ctx, 0, func(ctx context.Context, store Store) error {
+		return store.SetProjectStatus(ctx, projectID, status)
+	})
+}
+
+func (s *Staged) SetProjectLabels(ctx context.Context, projectID string, labels map[string]string) error {
+	return s.stage(ctx, 0, func(ctx context.Context, store Store) error {
+		return store.SetProjectLabels(ctx, projectID, labels)
+	})
+}
+
+// Import reads the dump now and stages replacing the tables it holds
+func (s *Staged) Import(ctx context.Context, r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	return s.stage(ctx, 0, func(ctx context.Context, store Store) error {
+		return store.Import(ctx, bytes.NewReader(data))
+	})
+}
@@ -0,0 +1,103 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+)
+
+// txKey carries the transaction of SQLiteStorage.Transaction to the writes made within it
+type txKey struct{}
+
+// projectTx is the transaction of SQLiteStorage.Transaction
+type projectTx struct {
+	store *SQLiteStorage
+	tx    *sql.Tx
+}
+
+// Transaction runs fn in a single transaction: the writes made with the context passed to fn
+// commit together if fn returns nil, and roll back together otherwise. fn must make its writes
+// one at a time and only write, a read doesn't see them. Within a transaction, Transaction
+// joins it.
+func (s *SQLiteStorage) Transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if s.txFrom(ctx) != nil {
+		return fn(ctx)
+	}
+
+	tx, err := s.writer.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(context.WithValue(ctx, txKey{}, &projectTx{store: s, tx: tx})); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// txFrom returns the transaction of Transaction that ctx carries, nil outside of one
+func (s *SQLiteStorage) txFrom(ctx context.Context) *sql.Tx {
+	if t, ok := ctx.Value(txKey{}).(*projectTx); ok && t.store == s {
+		return t.tx
+	}
+	return nil
+}
+
+// writeTx is the transaction of a single write. Within the transaction of Transaction it is a
+// savepoint, so a failed write rolls back alone as it would outside of one.
+type writeTx struct {
+	*sql.Tx
+	savepoint bool
+	done      bool
+}
+
+// begin starts the transaction of a write
+func (s *SQLiteStorage) begin(ctx context.Context) (*writeTx, error) {
+	if tx := s.txFrom(ctx); tx != nil {
+		if _, err := tx.ExecContext(ctx, `SAVEPOINT write`); err != nil {
+			return nil, err
+		}
+		return &writeTx{Tx: tx, savepoint: true}, nil
+	}
+	tx, err := s.writer.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &writeTx{Tx: tx}, nil
+}
+
+// Commit commits the write, or releases its savepoint into the enclosing transaction
+func (t *writeTx) Commit() error {
+	if !t.savepoint {
+		return t.Tx.Commit()
+	}
+	if t.done {
+		return sql.ErrTxDone
+	}
+	t.done = true
+	_, err := t.Exec(`RELEASE write`)
+	return err
+}
+
+// Rollback rolls back the write, leaving the enclosing transaction as it was before it
+func (t *writeTx) Rollback() error {
+	if !t.savepoint {
+		return t.Tx.Rollback()
+	}
+	if t.done {
+		return sql.ErrTxDone
+	}
+	t.done = true
+	if _, err := t.Exec(`ROLLBACK TO write`); err != nil {
+		return err
+	}
+	_, err := t.Exec(`RELEASE write`)
+	return err
+}
+
+// exec runs a write of a single statement, within the transaction of Transaction if ctx carries one
+func (s *SQLiteStorage) exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	if tx := s.txFrom(ctx); tx != nil {
+		return tx.ExecContext(ctx, query, args...)
+	}
+	return s.writer.ExecContext(ctx, query, args...)
+}
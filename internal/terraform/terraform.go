@@ -0,0 +1,151 @@
+package terraform
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Resource types of the Google provider managing topics and subscriptions
+const (
+	TypeTopic        = "google_pubsub_topic"
+	TypeSubscription = "google_pubsub_subscription"
+)
+
+// resource is a resource of a state file, or of the "terraform show -json" output
+type resource struct {
+	Address string `json:"address"` // show -json only
+	Module  string `json:"module"`  // state files only, empty in the root module
+	Mode    string `json:"mode"`
+	Type    string `json:"type"`
+	Name    string `json:"name"`
+
+	Instances []struct { // state files only
+		IndexKey   any            `json:"index_key"`
+		Attributes map[string]any `json:"attributes"`
+	} `json:"instances"`
+	Values map[string]any `json:"values"` // show -json only
+}
+
+// module is a module of the "terraform show -json" output
+type module struct {
+	Resources    []resource `json:"resources"`
+	ChildModules []module   `json:"child_modules"`
+}
+
+// state is a Terraform state file, version 4, or the output of "terraform show -json",
+// which has the resources in values instead
+type state struct {
+	Resources []resource `json:"resources"`
+	Values    *struct {
+		RootModule module `json:"root_module"`
+	} `json:"values"`
+}
+
+// Load reads Terraform state files, or "terraform show -json" outputs, and returns the address of every
+// Pub/Sub topic and subscription they manage by its full resource name. Paths may be glob patterns.
+func Load(paths []string) (map[string]string, error) {
+	managed := make(map[string]string)
+	for _, pattern := range paths {
+		files, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid terraform state pattern %s: %w", pattern, err)
+		}
+		if len(files) == 0 {
+			return nil, fmt.Errorf("terraform state %s: no such file", pattern)
+		}
+		for _, file := range files {
+			if err := loadFile(file, managed); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return managed, nil
+}
+
+// loadFile adds the topics and subscriptions of a state file to managed
+func loadFile(path string, managed map[string]string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read terraform state: %w", err)
+	}
+	var s state
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("invalid terraform state %s: %w", path, err)
+	}
+
+	for _, r := range s.Resources {
+		if r.Mode != "managed" {
+			continue
+		}
+		for _, instance := range r.Instances {
+			if name := fullResourceName(r.Type, instance.Attributes); name != "" {
+				managed[name] = stateAddress(r, instance.IndexKey)
+			}
+		}
+	}
+	if s.Values != nil {
+		addModule(s.Values.RootModule, managed)
+	}
+	return nil
+}
+
+// addModule adds the topics and subscriptions of a "terraform show -json" module and its children to managed
+func addModule(m module, managed map[string]string) {
+	for _, r := range m.Resources {
+		if r.Mode != "managed" {
+			continue
+		}
+		if name := fullResourceName(r.Type, r.Values); name != "" {
+			managed[name] = r.Address
+		}
+	}
+	for _, child := range m.ChildModules {
+		addModule(child, managed)
+	}
+}
+
+// fullResourceName returns the full resource name of a topic or subscription from its attributes,
+// empty for other resources. The id attribute is the full resource name; for resources without
+// it, the name may be short or full.
+func fullResourceName(resourceType string, attributes map[string]any) string {
+	collection := ""
+	switch resourceType {
+	case TypeTopic:
+		collection = "topics"
+	case TypeSubscription:
+		collection = "subscriptions"
+	default:
+		return ""
+	}
+	id, _ := attributes["id"].(string)
+	if strings.HasPrefix(id, "projects/") {
+		return id
+	}
+	name, _ := attributes["name"].(string)
+	if strings.HasPrefix(name, "projects/") {
+		return name
+	}
+	project, _ := attributes["project"].(string)
+	if project == "" || name == "" {
+		return ""
+	}
+	return "projects/" + project + "/" + collection + "/" + name
+}
+
+// stateAddress returns the address of a state file resource instance, e.g. module.events.google_pubsub_topic.orders["eu"]
+func stateAddress(r resource, indexKey any) string {
+	address := r.Type + "." + r.Name
+	if r.Module != "" {
+		address = r.Module + "." + address
+	}
+	switch key := indexKey.(type) {
+	case string:
+		address += fmt.Sprintf("[%q]", key)
+	case float64:
+		address += fmt.Sprintf("[%d]", int(key))
+	}
+	return address
+}
@@ -0,0 +1,65 @@
+package terraform
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "events.tfstate", `{
+  "version": 4,
+  "resources": [
+    {"mode": "managed", "type": "google_pubsub_topic", "name": "orders",
+     "instances": [{"attributes": {"id": "projects/project-a/topics/orders", "name": "orders", "project": "project-a"}}]},
+    {"module": "module.consumers", "mode": "managed", "type": "google_pubsub_subscription", "name": "sub",
+     "instances": [
+       {"index_key": "email", "attributes": {"id": "projects/project-b/subscriptions/orders-email", "name": "orders-email"}},
+       {"index_key": 1, "attributes": {"name": "orders-audit", "project": "project-b"}}
+     ]},
+    {"mode": "data", "type": "google_pubsub_topic", "name": "remote",
+     "instances": [{"attributes": {"id": "projects/project-c/topics/remote"}}]},
+    {"mode": "managed", "type": "google_storage_bucket", "name": "archive",
+     "instances": [{"attributes": {"id": "archive"}}]}
+  ]
+}`)
+	writeFile(t, dir, "show.json", `{
+  "format_version": "1.0",
+  "values": {"root_module": {
+    "resources": [{"address": "google_pubsub_topic.users", "mode": "managed", "type": "google_pubsub_topic", "name": "users",
+                   "values": {"id": "projects/project-b/topics/users"}}],
+    "child_modules": [{"resources": [{"address": "module.dlq.google_pubsub_topic.dead", "mode": "managed", "type": "google_pubsub_topic", "name": "dead",
+                                      "values": {"name": "projects/project-b/topics/dead"}}]}]
+  }}
+}`)
+
+	managed, err := Load([]string{filepath.Join(dir, "*.tfstate"), filepath.Join(dir, "show.json")})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"projects/project-a/topics/orders":              "google_pubsub_topic.orders",
+		"projects/project-b/subscriptions/orders-email": `module.consumers.google_pubsub_subscription.sub["email"]`,
+		"projects/project-b/subscriptions/orders-audit": "module.consumers.google_pubsub_subscription.sub[1]",
+		"projects/project-b/topics/users":               "google_pubsub_topic.users",
+		"projects/project-b/topics/dead":                "module.dlq.google_pubsub_topic.dead",
+	}, managed)
+}
+
+func TestLoad_Errors(t *testing.T) {
+	dir := t.TempDir()
+	_, err := Load([]string{filepath.Join(dir, "missing.tfstate")})
+	assert.ErrorContains(t, err, "no such file")
+
+	_, err = Load([]string{writeFile(t, dir, "broken.tfstate", "{")})
+	assert.ErrorContains(t, err, "invalid terraform state")
+}
@@ -0,0 +1,245 @@
+package verify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/graph"
+	"github.com/NissesSenap/gcp-visualizer/internal/storage"
+)
+
+// Kinds of differences between the declared and the cached topology
+const (
+	KindMissingTopic           = "missing-topic"            // declared topic that doesn't exist
+	KindMissingSubscription    = "missing-subscription"     // declared subscriber that doesn't exist
+	KindUnexpectedSubscription = "unexpected-subscription"  // subscription to a declared topic matching none of its subscribers
+	KindMissingDeadLetter      = "missing-dead-letter"      // subscription without a dead-letter topic where one is required
+	KindUnexpectedCrossProject = "unexpected-cross-project" // subscription to a topic in another project the declaration doesn't allow
+)
+
+// Declaration is the expected topology of the Pub/Sub resources
+type Declaration struct {
+	Topics []Topic `yaml:"topics"`
+
+	// AllowedCrossProject lists the projects allowed to subscribe to topics of other projects.
+	// Left out, cross-project subscriptions aren't checked; an empty list allows none.
+	AllowedCrossProject []CrossProjectLink `yaml:"allowed_cross_project"`
+
+	// RequireDeadLetter requires a dead-letter topic on every subscription
+	RequireDeadLetter bool `yaml:"require_dead_letter"`
+}
+
+// Topic is a declared topic and the subscriptions expected to read it
+type Topic struct {
+	Name string `yaml:"name"` // full resource name, projects/PROJECT/topics/TOPIC
+
+	// Subscribers are the full resource names of the expected subscriptions, or glob patterns of them.
+	// Names without a pattern must exist; any subscription matching none of them is unexpected.
+	Subscribers []string `yaml:"subscribers"`
+
+	// RequireDeadLetter requires a dead-letter topic on the subscriptions of this topic
+	RequireDeadLetter bool `yaml:"require_dead_letter"`
+}
+
+// CrossProjectLink allows subscriptions in the From projects to read topics of the To projects, both glob patterns
+type CrossProjectLink struct {
+	From string `yaml:"from"` // consuming project
+	To   string `yaml:"to"`   // producing project
+}
+
+// Finding is a difference between the declared and the cached topology
+type Finding struct {
+	Kind     string `json:"kind"`
+	Resource string `json:"resource"` // full resource name
+	Message  string `json:"message"`
+}
+
+// Load reads a declaration from a YAML file, rejecting unknown fields so typos don't pass unnoticed
+func Load(file string) (*Declaration, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read declaration: %w", err)
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	var d Declaration
+	if err := dec.Decode(&d); err != nil {
+		return nil, fmt.Errorf("invalid declaration %s: %w", file, err)
+	}
+	if err := d.validate(); err != nil {
+		return nil, fmt.Errorf("invalid declaration %s: %w", file, err)
+	}
+	return &d, nil
+}
+
+// validate checks the topic names and glob patterns of d
+func (d *Declaration) validate() error {
+	for i, topic := range d.Topics {
+		if project, name := graph.ParseTopicReference(topic.Name); project == "" || name == "" {
+			return fmt.Errorf("topics[%d]: name %q is not a full resource name, projects/PROJECT/topics/TOPIC", i, topic.Name)
+		}
+		for _, pattern := range topic.Subscribers {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("topics[%d].subscribers: invalid pattern %q", i, pattern)
+			}
+		}
+	}
+	for i, link := range d.AllowedCrossProject {
+		for _, pattern := range []string{link.From, link.To} {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("allowed_cross_project[%d]: invalid pattern %q", i, pattern)
+			}
+		}
+	}
+	return nil
+}
+
+// Verify compares the cached topics and subscriptions of the given projects with d and returns
+// the differences, sorted by resource and kind. An empty projects slice checks every cached project.
+func Verify(ctx context.Context, store storage.Store, d *Declaration, projects []string) ([]Finding, error) {
+	topics, err := store.GetAllTopics(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get topics: %w", err)
+	}
+	// Subscribers of a declared topic may be in any project
+	subs, err := store.GetAllSubscriptions(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get subscriptions: %w", err)
+	}
+
+	included := func(project string) bool {
+		if len(projects) == 0 {
+			return true
+		}
+		for _, p := range projects {
+			if p == project {
+				return true
+			}
+		}
+		return false
+	}
+
+	cachedTopics := make(map[string]bool, len(topics))
+	for _, topic := range topics {
+		cachedTopics[topic.FullResourceName] = true
+	}
+	byTopic := make(map[string][]*storage.Subscription)
+	cachedSubs := make(map[string]bool, len(subs))
+	for _, sub := range subs {
+		byTopic[sub.TopicFullResourceName] = append(byTopic[sub.TopicFullResourceName], sub)
+		cachedSubs[sub.FullResourceName] = true
+	}
+
+	var findings []Finding
+	requireDeadLetter := make(map[string]bool) // topics whose subscriptions need a dead-letter topic
+	for _, topic := range d.Topics {
+		// Subscriptions of the included projects may read topics of others
+		if topic.RequireDeadLetter {
+			requireDeadLetter[topic.Name] = true
+		}
+		project, _ := graph.ParseTopicReference(topic.Name)
+		if !included(project) {
+			continue
+		}
+		if !cachedTopics[topic.Name] {
+			findings = append(findings, Finding{
+				Kind:     KindMissingTopic,
+				Resource: topic.Name,
+				Message:  "declared topic does not exist",
+			})
+			continue
+		}
+
+		for _, subscriber := range topic.Subscribers {
+			if !strings.ContainsAny(subscriber, "*?[") && !cachedSubs[subscriber] {
+				findings = append(findings, Finding{
+					Kind:     KindMissingSubscription,
+					Resource: subscriber,
+					Message:  fmt.Sprintf("declared subscriber of %s does not exist", topic.Name),
+				})
+			}
+		}
+		for _, sub := range byTopic[topic.Name] {
+			if !matchesAny(topic.Subscribers, sub.FullResourceName) {
+				findings = append(findings, Finding{
+					Kind:     KindUnexpectedSubscription,
+					Resource: sub.FullResourceName,
+					Message:  fmt.Sprintf("subscribes to %s but is not a declared subscriber", topic.Name),
+				})
+			}
+		}
+	}
+
+	for _, sub := range subs {
+		if !included(sub.ProjectID) {
+			continue
+		}
+		if (d.RequireDeadLetter || requireDeadLetter[sub.TopicFullResourceName]) && deadLetterTopic(sub.Metadata) == "" {
+			findings = append(findings, Finding{
+				Kind:     KindMissingDeadLetter,
+				Resource: sub.FullResourceName,
+				Message:  "subscription has no dead-letter topic",
+			})
+		}
+
+		producer, name := graph.ParseTopicReference(sub.TopicFullResourceName)
+		if d.AllowedCrossProject == nil || name == "" || producer == sub.ProjectID {
+			continue
+		}
+		if !d.allowsCrossProject(sub.ProjectID, producer) {
+			findings = append(findings, Finding{
+				Kind:     KindUnexpectedCrossProject,
+				Resource: sub.FullResourceName,
+				Message:  fmt.Sprintf("project %s subscribes to %s of project %s, which is not an allowed cross-project link", sub.ProjectID, name, producer),
+			})
+		}
+	}
+
+	sort.Slice(findings, func(i, j int) bool {
+		if findings[i].Resource != findings[j].Resource {
+			return findings[i].Resource < findings[j].Resource
+		}
+		return findings[i].Kind < findings[j].Kind
+	})
+	return findings, nil
+}
+
+// allowsCrossProject reports whether a subscription in project consumer may read a topic of project producer
+func (d *Declaration) allowsCrossProject(consumer, producer string) bool {
+	for _, link := range d.AllowedCrossProject {
+		from, _ := path.Match(link.From, consumer)
+		to, _ := path.Match(link.To, producer)
+		if from && to {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesAny reports whether name matches one of the glob patterns
+func matchesAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// deadLetterTopic returns the dead-letter topic in stored subscription metadata, empty if it has none
+func deadLetterTopic(metadata string) string {
+	var m struct {
+		DeadLetterTopic string `json:"dead_letter_topic"`
+	}
+	// Metadata is written by the collector, a subscription without it has no dead-letter policy
+	_ = json.Unmarshal([]byte(metadata), &m)
+	return m.DeadLetterTopic
+}
@@ -0,0 +1,82 @@
+package verify
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeDeclaration(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "architecture.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestVerify(t *testing.T) {
+	store, err := storage.NewSQLite(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = store.Close() })
+	ctx := context.Background()
+
+	require.NoError(t, store.SaveTopic(ctx, &storage.Topic{Name: "orders", ProjectID: "payments", FullResourceName: "projects/payments/topics/orders"}))
+	for _, sub := range []*storage.Subscription{
+		{Name: "orders-email", ProjectID: "payments", TopicFullResourceName: "projects/payments/topics/orders", FullResourceName: "projects/payments/subscriptions/orders-email",
+			Metadata: `{"dead_letter_topic": "projects/payments/topics/orders-dlq"}`},
+		{Name: "orders-debug", ProjectID: "payments", TopicFullResourceName: "projects/payments/topics/orders", FullResourceName: "projects/payments/subscriptions/orders-debug"},
+		{Name: "orders-bi", ProjectID: "analytics", TopicFullResourceName: "projects/payments/topics/orders", FullResourceName: "projects/analytics/subscriptions/orders-bi"},
+		{Name: "orders-ml", ProjectID: "ml", TopicFullResourceName: "projects/payments/topics/orders", FullResourceName: "projects/ml/subscriptions/orders-ml"},
+	} {
+		require.NoError(t, store.SaveSubscription(ctx, sub))
+	}
+
+	d, err := Load(writeDeclaration(t, `
+topics:
+  - name: projects/payments/topics/orders
+    subscribers:
+      - projects/payments/subscriptions/orders-email
+      - projects/payments/subscriptions/orders-audit
+      - projects/*/subscriptions/orders-bi
+      - projects/ml/subscriptions/orders-ml
+    require_dead_letter: true
+  - name: projects/payments/topics/refunds
+allowed_cross_project:
+  - from: analytics
+    to: payments
+`))
+	require.NoError(t, err)
+
+	findings, err := Verify(ctx, store, d, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []Finding{
+		{Kind: KindMissingDeadLetter, Resource: "projects/analytics/subscriptions/orders-bi", Message: "subscription has no dead-letter topic"},
+		{Kind: KindMissingDeadLetter, Resource: "projects/ml/subscriptions/orders-ml", Message: "subscription has no dead-letter topic"},
+		{Kind: KindUnexpectedCrossProject, Resource: "projects/ml/subscriptions/orders-ml", Message: "project ml subscribes to orders of project payments, which is not an allowed cross-project link"},
+		{Kind: KindMissingSubscription, Resource: "projects/payments/subscriptions/orders-audit", Message: "declared subscriber of projects/payments/topics/orders does not exist"},
+		{Kind: KindMissingDeadLetter, Resource: "projects/payments/subscriptions/orders-debug", Message: "subscription has no dead-letter topic"},
+		{Kind: KindUnexpectedSubscription, Resource: "projects/payments/subscriptions/orders-debug", Message: "subscribes to projects/payments/topics/orders but is not a declared subscriber"},
+		{Kind: KindMissingTopic, Resource: "projects/payments/topics/refunds", Message: "declared topic does not exist"},
+	}, findings)
+
+	// Only the subscriptions of the analytics project
+	findings, err = Verify(ctx, store, d, []string{"analytics"})
+	require.NoError(t, err)
+	require.Len(t, findings, 1)
+	assert.Equal(t, KindMissingDeadLetter, findings[0].Kind)
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	_, err := Load(writeDeclaration(t, "topics:\n  - name: orders\n"))
+	assert.ErrorContains(t, err, "not a full resource name")
+
+	_, err = Load(writeDeclaration(t, "topics:\n  - name: projects/p/topics/t\n    subscriber: [x]\n"))
+	assert.ErrorContains(t, err, "field subscriber not found")
+
+	_, err = Load(writeDeclaration(t, "allowed_cross_project:\n  - from: \"[\"\n    to: p\n"))
+	assert.ErrorContains(t, err, "invalid pattern")
+}
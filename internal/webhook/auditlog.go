@@ -0,0 +1,104 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Audit log method names of the Pub/Sub admin operations the listener applies
+const (
+	methodCreateTopic        = "google.pubsub.v1.Publisher.CreateTopic"
+	methodDeleteTopic        = "google.pubsub.v1.Publisher.DeleteTopic"
+	methodCreateSubscription = "google.pubsub.v1.Subscriber.CreateSubscription"
+	methodDeleteSubscription = "google.pubsub.v1.Subscriber.DeleteSubscription"
+)
+
+// consumeMethods are the Data Access audit log methods that show an identity consuming a subscription
+var consumeMethods = map[string]bool{
+	"google.pubsub.v1.Subscriber.Pull":          true,
+	"google.pubsub.v1.Subscriber.StreamingPull": true,
+	"google.pubsub.v1.Subscriber.Acknowledge":   true,
+}
+
+// pushEnvelope is the body of a Pub/Sub push request
+type pushEnvelope struct {
+	Message *struct {
+		Data       []byte            `json:"data"` // base64 decoded by encoding/json
+		Attributes map[string]string `json:"attributes"`
+		MessageID  string            `json:"messageId"`
+	} `json:"message"`
+	Subscription string `json:"subscription"`
+}
+
+// logEntry is the subset of a Cloud Logging LogEntry the listener needs
+type logEntry struct {
+	ProtoPayload auditLog `json:"protoPayload"`
+}
+
+// auditLog is the subset of a google.cloud.audit.AuditLog payload the listener needs
+type auditLog struct {
+	ServiceName  string `json:"serviceName"`
+	MethodName   string `json:"methodName"`
+	ResourceName string `json:"resourceName"`
+	AuthInfo     struct {
+		PrincipalEmail string `json:"principalEmail"`
+	} `json:"authenticationInfo"`
+	Status *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"status"`
+	Request  map[string]interface{} `json:"request"`
+	Response map[string]interface{} `json:"response"`
+}
+
+// decodeLogEntry decodes a request body that is either a Pub/Sub push envelope
+// wrapping a LogEntry (log sink -> topic -> push subscription) or a raw LogEntry
+func decodeLogEntry(body []byte) (*logEntry, error) {
+	var envelope pushEnvelope
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return nil, fmt.Errorf("invalid JSON body: %w", err)
+	}
+	if envelope.Message != nil {
+		body = envelope.Message.Data
+	}
+
+	var entry logEntry
+	if err := json.Unmarshal(body, &entry); err != nil {
+		return nil, fmt.Errorf("invalid log entry: %w", err)
+	}
+	return &entry, nil
+}
+
+// failed reports whether the audited operation returned an error
+func (a *auditLog) failed() bool {
+	return a.Status != nil && a.Status.Code != 0
+}
+
+// subscriptionTopic returns the topic of a CreateSubscription call,
+// preferring the response (the created resource) over the request
+func (a *auditLog) subscriptionTopic() string {
+	for _, m := range []map[string]interface{}{a.Response, a.Request} {
+		if topic, ok := m["topic"].(string); ok && topic != "" {
+			return topic
+		}
+	}
+	return ""
+}
+
+// parseResourceName splits "projects/{project}/{collection}/{name}" into its parts
+func parseResourceName(fullResourceName string) (project, collection, name string, err error) {
+	parts := strings.Split(fullResourceName, "/")
+	if len(parts) != 4 || parts[0] != "projects" || parts[1] == "" || parts[3] == "" {
+		return "", "", "", fmt.Errorf("invalid resource name %q", fullResourceName)
+	}
+	return parts[1], parts[2], parts[3], nil
+}
+
+// principalMember converts an audit log principal email to an IAM member string
+func principalMember(email string) string {
+	if strings.HasSuffix(email, ".gserviceaccount.com") {
+		return "serviceAccount:" + email
+	}
+	return "user:" + email
+}
@@ -0,0 +1,127 @@
+package webhook
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/storage"
+)
+
+// maxBodyBytes bounds the size of a single push request
+const maxBodyBytes = 1 << 20
+
+// Handler receives Cloud Audit Log events about Pub/Sub topic and subscription
+// create/delete operations and applies them to the cache incrementally.
+// Data Access events for pulls are recorded as subscription consumer evidence.
+type Handler struct {
+	storage storage.Store
+	token   string
+}
+
+// NewHandler creates a Handler writing to store. If token is non-empty, requests
+// must carry it in the "token" query parameter, which is how Pub/Sub push
+// subscriptions are usually authenticated. The listen command always sets one.
+func NewHandler(store storage.Store, token string) *Handler {
+	return &Handler{storage: store, token: token}
+}
+
+// ServeHTTP implements http.Handler.
+// Events that are ignored are still acknowledged with 204 so Pub/Sub doesn't redeliver them.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if h.token != "" && subtle.ConstantTimeCompare([]byte(r.URL.Query().Get("token")), []byte(h.token)) != 1 {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxBodyBytes))
+	if err != nil {
+		http.Error(w, "failed to read body", http.StatusBadRequest)
+		return
+	}
+
+	entry, err := decodeLogEntry(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.apply(r.Context(), &entry.ProtoPayload); err != nil {
+		log.Printf("Failed to apply audit event %s on %s: %v",
+			entry.ProtoPayload.MethodName, entry.ProtoPayload.ResourceName, err)
+		// 5xx makes Pub/Sub retry the delivery
+		http.Error(w, "failed to apply event", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// apply updates the cache according to a single audit log record
+func (h *Handler) apply(ctx context.Context, a *auditLog) error {
+	if a.failed() {
+		return nil
+	}
+
+	switch a.MethodName {
+	case methodCreateTopic:
+		project, _, name, err := parseResourceName(a.ResourceName)
+		if err != nil {
+			return nil
+		}
+		// A topic that is cached already keeps what was collected about it
+		return h.storage.AddTopic(ctx, &storage.Topic{
+			Name:             name,
+			ProjectID:        project,
+			FullResourceName: a.ResourceName,
+			Metadata:         "{}",
+		})
+
+	case methodDeleteTopic:
+		return h.storage.DeleteTopic(ctx, a.ResourceName)
+
+	case methodCreateSubscription:
+		project, _, name, err := parseResourceName(a.ResourceName)
+		if err != nil {
+			return nil
+		}
+		topic := a.subscriptionTopic()
+		if topic == "" {
+			return fmt.Errorf("create subscription event has no topic")
+		}
+		return h.storage.AddSubscription(ctx, &storage.Subscription{
+			Name:                  name,
+			ProjectID:             project,
+			TopicFullResourceName: topic,
+			FullResourceName:      a.ResourceName,
+			Metadata:              "{}",
+		})
+
+	case methodDeleteSubscription:
+		return h.storage.DeleteSubscription(ctx, a.ResourceName)
+	}
+
+	if consumeMethods[a.MethodName] && a.AuthInfo.PrincipalEmail != "" {
+		project, collection, _, err := parseResourceName(a.ResourceName)
+		if err != nil || collection != "subscriptions" {
+			return nil
+		}
+		return h.storage.SaveSubscriptionConsumer(ctx, &storage.SubscriptionConsumer{
+			SubscriptionFullResourceName: a.ResourceName,
+			ProjectID:                    project,
+			Principal:                    principalMember(a.AuthInfo.PrincipalEmail),
+			Source:                       storage.ConsumerSourceAuditLog,
+			Role:                         a.MethodName,
+		})
+	}
+
+	// Not an operation we track
+	return nil
+}
@@ -0,0 +1,200 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/NissesSenap/gcp-visualizer/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupTestHandler(t *testing.T, token string) (*Handler, storage.Store) {
+	store, err := storage.NewSQLite(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = store.Close() })
+	return NewHandler(store, token), store
+}
+
+// pushBody wraps an audit log payload in a Pub/Sub push envelope
+func pushBody(t *testing.T, payload map[string]interface{}) []byte {
+	entry, err := json.Marshal(map[string]interface{}{"protoPayload": payload})
+	require.NoError(t, err)
+
+	body, err := json.Marshal(map[string]interface{}{
+		"message": map[string]interface{}{
+			"data":      base64.StdEncoding.EncodeToString(entry),
+			"messageId": "1",
+		},
+		"subscription": "projects/ops/subscriptions/audit-push",
+	})
+	require.NoError(t, err)
+	return body
+}
+
+func post(h http.Handler, target string, body []byte) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, target, bytes.NewReader(body)))
+	return rec
+}
+
+func TestHandler_TopicLifecycle(t *testing.T) {
+	h, store := setupTestHandler(t, "")
+	ctx := context.Background()
+
+	rec := post(h, "/", pushBody(t, map[string]interface{}{
+		"methodName":   methodCreateTopic,
+		"resourceName": "projects/p/topics/orders",
+	}))
+	require.Equal(t, http.StatusNoContent, rec.Code)
+
+	topics, err := store.GetTopics(ctx, "p")
+	require.NoError(t, err)
+	require.Len(t, topics, 1)
+	assert.Equal(t, "orders", topics[0].Name)
+
+	rec = post(h, "/", pushBody(t, map[string]interface{}{
+		"methodName":   methodDeleteTopic,
+		"resourceName": "projects/p/topics/orders",
+	}))
+	require.Equal(t, http.StatusNoContent, rec.Code)
+
+	topics, err = store.GetTopics(ctx, "p")
+	require.NoError(t, err)
+	assert.Empty(t, topics)
+}
+
+func TestHandler_CreateSubscription(t *testing.T) {
+	h, store := setupTestHandler(t, "")
+
+	rec := post(h, "/", pushBody(t, map[string]interface{}{
+		"methodName":   methodCreateSubscription,
+		"resourceName": "projects/p2/subscriptions/orders-email",
+		"request": map[string]interface{}{
+			"name":  "projects/p2/subscriptions/orders-email",
+			"topic": "projects/p/topics/orders",
+		},
+	}))
+	require.Equal(t, http.StatusNoContent, rec.Code)
+
+	subs, err := store.GetSubscriptions(context.Background(), "p2")
+	require.NoError(t, err)
+	require.Len(t, subs, 1)
+	assert.Equal(t, "projects/p/topics/orders", subs[0].TopicFullResourceName)
+}
+
+func TestHandler_CreateKeepsCollectedMetadata(t *testing.T) {
+	h, store := setupTestHandler(t, "")
+	ctx := context.Background()
+	require.NoError(t, store.SaveTopic(ctx, &storage.Topic{
+		Name: "orders", ProjectID: "p", FullResourceName: "projects/p/topics/orders", Metadata: `{"labels":{"team":"checkout"}}`,
+	}))
+	require.NoError(t, store.SaveSubscription(ctx, &storage.Subscription{
+		Name: "orders-eu", ProjectID: "p", TopicFullResourceName: "projects/p/topics/orders", FullResourceName: "projects/p/subscriptions/orders-eu",
+		Filter: `attributes.region = "eu"`, Metadata: `{"filter":"attributes.region = \"eu\""}`,
+	}))
+
+	// A late or redelivered create event for a cached resource doesn't wipe what the scan collected
+	rec := post(h, "/", pushBody(t, map[string]interface{}{
+		"methodName":   methodCreateTopic,
+		"resourceName": "projects/p/topics/orders",
+	}))
+	require.Equal(t, http.StatusNoContent, rec.Code)
+	rec = post(h, "/", pushBody(t, map[string]interface{}{
+		"methodName":   methodCreateSubscription,
+		"resourceName": "projects/p/subscriptions/orders-eu",
+		"request":      map[string]interface{}{"topic": "projects/p/topics/orders"},
+	}))
+	require.Equal(t, http.StatusNoContent, rec.Code)
+
+	topics, err := store.GetTopics(ctx, "p")
+	require.NoError(t, err)
+	require.Len(t, topics, 1)
+	assert.Equal(t, `{"labels":{"team":"checkout"}}`, topics[0].Metadata)
+	subs, err := store.GetSubscriptions(ctx, "p")
+	require.NoError(t, err)
+	require.Len(t, subs, 1)
+	assert.Equal(t, `attributes.region = "eu"`, subs[0].Filter)
+}
+
+func TestHandler_RawLogEntry(t *testing.T) {
+	h, store := setupTestHandler(t, "")
+
+	entry, err := json.Marshal(map[string]interface{}{
+		"protoPayload": map[string]interface{}{
+			"methodName":   methodCreateTopic,
+			"resourceName": "projects/p/topics/raw",
+		},
+	})
+	require.NoError(t, err)
+
+	rec := post(h, "/", entry)
+	require.Equal(t, http.StatusNoContent, rec.Code)
+
+	topics, err := store.GetTopics(context.Background(), "p")
+	require.NoError(t, err)
+	assert.Len(t, topics, 1)
+}
+
+func TestHandler_IgnoresFailedAndUnknownEvents(t *testing.T) {
+	h, store := setupTestHandler(t, "")
+
+	rec := post(h, "/", pushBody(t, map[string]interface{}{
+		"methodName":   methodCreateTopic,
+		"resourceName": "projects/p/topics/denied",
+		"status":       map[string]interface{}{"code": 7, "message": "PERMISSION_DENIED"},
+	}))
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+
+	rec = post(h, "/", pushBody(t, map[string]interface{}{
+		"methodName":   "google.pubsub.v1.Publisher.Publish",
+		"resourceName": "projects/p/topics/other",
+	}))
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+
+	topics, err := store.GetTopics(context.Background(), "p")
+	require.NoError(t, err)
+	assert.Empty(t, topics)
+}
+
+func TestHandler_Token(t *testing.T) {
+	h, _ := setupTestHandler(t, "secret")
+	body := pushBody(t, map[string]interface{}{"methodName": "noop"})
+
+	assert.Equal(t, http.StatusUnauthorized, post(h, "/", body).Code)
+	assert.Equal(t, http.StatusUnauthorized, post(h, "/?token=wrong", body).Code)
+	assert.Equal(t, http.StatusNoContent, post(h, "/?token=secret", body).Code)
+}
+
+func TestHandler_BadRequests(t *testing.T) {
+	h, _ := setupTestHandler(t, "")
+
+	assert.Equal(t, http.StatusBadRequest, post(h, "/", []byte("not json")).Code)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
+
+func TestHandler_PullRecordsConsumer(t *testing.T) {
+	h, store := setupTestHandler(t, "")
+
+	rec := post(h, "/", pushBody(t, map[string]interface{}{
+		"methodName":         "google.pubsub.v1.Subscriber.StreamingPull",
+		"resourceName":       "projects/p/subscriptions/orders-worker",
+		"authenticationInfo": map[string]interface{}{"principalEmail": "worker@p.iam.gserviceaccount.com"},
+	}))
+	require.Equal(t, http.StatusNoContent, rec.Code)
+
+	consumers, err := store.GetAllSubscriptionConsumers(context.Background(), []string{"p"})
+	require.NoError(t, err)
+	require.Len(t, consumers, 1)
+	assert.Equal(t, "serviceAccount:worker@p.iam.gserviceaccount.com", consumers[0].Principal)
+	assert.Equal(t, storage.ConsumerSourceAuditLog, consumers[0].Source)
+	assert.Equal(t, "projects/p/subscriptions/orders-worker", consumers[0].SubscriptionFullResourceName)
+}